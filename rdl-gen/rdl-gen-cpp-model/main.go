@@ -0,0 +1,495 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate a C++17 header with the model structs of the types in an RDL schema, and their
+// nlohmann::json to_json/from_json functions
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	pNamespace := flag.String("namespace", "", "The C++ namespace of the generated code, e.g. acme::sample. Default is the schema namespace")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			err = ExportToCppModel(&schema, *pOutdir, *pNamespace)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+var cppKeywords = map[string]bool{
+	"alignas": true, "alignof": true, "and": true, "and_eq": true, "asm": true, "auto": true, "bitand": true,
+	"bitor": true, "bool": true, "break": true, "case": true, "catch": true, "char": true, "class": true,
+	"compl": true, "const": true, "const_cast": true, "constexpr": true, "continue": true, "decltype": true,
+	"default": true, "delete": true, "do": true, "double": true, "dynamic_cast": true, "else": true,
+	"enum": true, "explicit": true, "export": true, "extern": true, "false": true, "float": true, "for": true,
+	"friend": true, "goto": true, "if": true, "inline": true, "int": true, "long": true, "mutable": true,
+	"namespace": true, "new": true, "noexcept": true, "not": true, "not_eq": true, "nullptr": true,
+	"operator": true, "or": true, "or_eq": true, "private": true, "protected": true, "public": true,
+	"register": true, "reinterpret_cast": true, "return": true, "short": true, "signed": true, "sizeof": true,
+	"static": true, "static_assert": true, "static_cast": true, "struct": true, "switch": true, "template": true,
+	"this": true, "thread_local": true, "throw": true, "true": true, "try": true, "typedef": true, "typeid": true,
+	"typename": true, "union": true, "unsigned": true, "using": true, "virtual": true, "void": true,
+	"volatile": true, "wchar_t": true, "while": true, "xor": true, "xor_eq": true, "NULL": true,
+}
+
+// cppName returns the name of the member or enumerator, the name itself unless it is reserved, e.g. default_.
+func cppName(name string) string {
+	if cppKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// cppString returns the C++ string literal of s.
+func cppString(s string) string {
+	return strconv.Quote(s)
+}
+
+// cppNamespace returns the namespace of the generated code, that of the -x namespace option, or
+// else the one of the schema, with :: for its dots, or else the lowercase name of the schema.
+func cppNamespace(schema *rdl.Schema, namespace string) string {
+	if namespace != "" {
+		return namespace
+	}
+	if schema.Namespace != "" {
+		return strings.Replace(string(schema.Namespace), ".", "::", -1)
+	}
+	return strings.ToLower(string(schema.Name))
+}
+
+type cppModelGenerator struct {
+	registry rdl.TypeRegistry
+	schema   *rdl.Schema
+	writer   *bufio.Writer
+	err      error
+}
+
+// ExportToCppModel generates a C++17 header, <name>.hpp, with a struct for each struct type in the
+// schema, optional fields as std::optional, an enum class for each enum type, a struct holding a
+// std::variant for each union type, and an alias for the other named types. Each struct and enum
+// has the to_json and from_json functions nlohmann::json finds by argument dependent lookup, so
+// that j.get<Contact>() and json(contact) convert them. The fields of the structs are flattened,
+// not inherited.
+func ExportToCppModel(schema *rdl.Schema, outdir string, namespace string) error {
+	out, file, _, err := outputWriter(outdir, strings.ToLower(string(schema.Name)), ".hpp")
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	gen := &cppModelGenerator{rdl.NewTypeRegistry(schema), schema, out, nil}
+	gen.emit("//\n// This file generated by rdl-gen-cpp-model. Do not modify!\n//\n")
+	gen.emitComment(schema.Comment, "")
+	gen.emit("#pragma once\n\n")
+	for _, h := range []string{"cstdint", "map", "optional", "stdexcept", "string", "variant", "vector"} {
+		gen.emit(fmt.Sprintf("#include <%s>\n", h))
+	}
+	gen.emit("\n#include <nlohmann/json.hpp>\n")
+	ns := cppNamespace(schema, namespace)
+	gen.emit(fmt.Sprintf("\nnamespace %s {\n", ns))
+	for _, t := range schema.Types {
+		tName, _, _ := rdl.TypeInfo(t)
+		if strings.HasPrefix(string(tName), "rdl.") {
+			continue
+		}
+		gen.emitType(t)
+	}
+	gen.emit(fmt.Sprintf("\n} // namespace %s\n", ns))
+	out.Flush()
+	return gen.err
+}
+
+func (gen *cppModelGenerator) emit(s string) {
+	if gen.err == nil {
+		_, err := gen.writer.WriteString(s)
+		if err != nil {
+			gen.err = err
+		}
+	}
+}
+
+func (gen *cppModelGenerator) emitComment(comment string, indent string) {
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			gen.emit(strings.TrimRight(indent+"// "+line, " ") + "\n")
+		}
+	}
+}
+
+func (gen *cppModelGenerator) emitType(t *rdl.Type) {
+	tName, tType, tComment := rdl.TypeInfo(t)
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		gen.emitStruct(t)
+	case rdl.TypeVariantUnionTypeDef:
+		gen.emitUnion(t.UnionTypeDef)
+	case rdl.TypeVariantEnumTypeDef:
+		gen.emitEnum(t.EnumTypeDef)
+	case rdl.TypeVariantArrayTypeDef:
+		gen.emitAlias(tName, gen.cppType(rdl.TypeRef(tName), t.ArrayTypeDef.Items, true), tComment)
+	case rdl.TypeVariantMapTypeDef:
+		gen.emitAlias(tName, gen.cppType(rdl.TypeRef(tName), t.MapTypeDef.Items, true), tComment)
+	case rdl.TypeVariantAliasTypeDef:
+		gen.emitAlias(tName, gen.cppType(tType, "", false), tComment)
+	default:
+		gen.emitAlias(tName, gen.cppType(rdl.TypeRef(tName), "", true), tComment)
+	}
+}
+
+func (gen *cppModelGenerator) emitAlias(name rdl.TypeName, ctype string, comment string) {
+	gen.emit("\n")
+	gen.emitComment(comment, "")
+	gen.emit(fmt.Sprintf("using %s = %s;\n", name, ctype))
+}
+
+// hasStruct tells whether the type is generated as a struct or an enum class.
+func (gen *cppModelGenerator) hasStruct(t *rdl.Type) bool {
+	tName, _, _ := rdl.TypeInfo(t)
+	if tName == "Struct" || strings.HasPrefix(string(tName), "rdl.") {
+		return false
+	}
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef, rdl.TypeVariantUnionTypeDef, rdl.TypeVariantEnumTypeDef:
+		return true
+	}
+	return false
+}
+
+// isUserType tells whether the type is defined by the schema, and so has an alias.
+func (gen *cppModelGenerator) isUserType(t *rdl.Type) bool {
+	tName, tType, _ := rdl.TypeInfo(t)
+	if strings.HasPrefix(string(tName), "rdl.") {
+		return false
+	}
+	for _, st := range gen.schema.Types {
+		if n, _, _ := rdl.TypeInfo(st); n == tName {
+			return rdl.TypeRef(tName) != tType
+		}
+	}
+	return false
+}
+
+// cppType returns the C++ type of the type reference, the name of its struct, enum, or alias if
+// it has one, unless base is set, for the alias of the type itself.
+func (gen *cppModelGenerator) cppType(rdlType rdl.TypeRef, items rdl.TypeRef, base bool) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil || t.Variant == 0 {
+		gen.err = fmt.Errorf("Cannot find type '%s'", rdlType)
+		return "nlohmann::json"
+	}
+	tName, _, _ := rdl.TypeInfo(t)
+	if gen.hasStruct(t) {
+		return string(tName)
+	}
+	if !base && rdl.TypeRef(tName) == rdlType && gen.isUserType(t) {
+		return string(tName)
+	}
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeAny, rdl.BaseTypeStruct:
+		return "nlohmann::json"
+	case rdl.BaseTypeBool:
+		return "bool"
+	case rdl.BaseTypeInt8:
+		return "std::int8_t"
+	case rdl.BaseTypeInt16:
+		return "std::int16_t"
+	case rdl.BaseTypeInt32:
+		return "std::int32_t"
+	case rdl.BaseTypeInt64:
+		return "std::int64_t"
+	case rdl.BaseTypeFloat32:
+		return "float"
+	case rdl.BaseTypeFloat64:
+		return "double"
+	case rdl.BaseTypeArray:
+		return "std::vector<" + gen.cppType(gen.collectionItems(t, items), "", false) + ">"
+	case rdl.BaseTypeMap:
+		return "std::map<std::string, " + gen.cppType(gen.collectionItems(t, items), "", false) + ">"
+	default: //String, Symbol, UUID, Timestamp, and Bytes, as their base64 text
+		return "std::string"
+	}
+}
+
+func (gen *cppModelGenerator) collectionItems(t *rdl.Type, items rdl.TypeRef) rdl.TypeRef {
+	switch t.Variant {
+	case rdl.TypeVariantArrayTypeDef:
+		items = t.ArrayTypeDef.Items
+	case rdl.TypeVariantMapTypeDef:
+		items = t.MapTypeDef.Items
+	}
+	if items == "" {
+		return "Any"
+	}
+	return items
+}
+
+// enumName returns the name of the enum type the type derives from.
+func (gen *cppModelGenerator) enumName(rdlType rdl.TypeRef) string {
+	t := gen.registry.FindType(rdlType)
+	for t != nil && t.Variant != rdl.TypeVariantEnumTypeDef {
+		_, tType, _ := rdl.TypeInfo(t)
+		t = gen.registry.FindType(tType)
+	}
+	if t == nil {
+		return string(rdlType)
+	}
+	return string(t.EnumTypeDef.Name)
+}
+
+func (gen *cppModelGenerator) literal(f *rdl.StructFieldDef) string {
+	switch v := f.Default.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeEnum {
+			return gen.enumName(f.Type) + "::" + cppName(v)
+		}
+		return cppString(v)
+	case float64:
+		switch gen.registry.FindBaseType(f.Type) {
+		case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+			return fmt.Sprintf("%d", int64(v))
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	}
+	return fmt.Sprintf("%v", f.Default)
+}
+
+// enumWireName returns the string the enum element is written as: its x_wire annotation, or else
+// its symbol.
+func enumWireName(elem *rdl.EnumElementDef) string {
+	if wire := strings.TrimSpace(elem.Annotations["x_wire"]); wire != "" {
+		return wire
+	}
+	return string(elem.Symbol)
+}
+
+// enumReadNames returns the strings the enum element is read from besides its wire name: its
+// symbol, if it is written as another one, and its x_aliases.
+func enumReadNames(elem *rdl.EnumElementDef) []string {
+	var names []string
+	if enumWireName(elem) != string(elem.Symbol) {
+		names = append(names, string(elem.Symbol))
+	}
+	for _, alias := range strings.Split(elem.Annotations["x_aliases"], ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
+
+func (gen *cppModelGenerator) emitEnum(et *rdl.EnumTypeDef) {
+	gen.emit("\n")
+	gen.emitComment(et.Comment, "")
+	gen.emit(fmt.Sprintf("enum class %s {\n", et.Name))
+	for _, elem := range et.Elements {
+		gen.emit(fmt.Sprintf("    %s,", cppName(string(elem.Symbol))))
+		if elem.Comment != "" {
+			gen.emit(" // " + elem.Comment)
+		}
+		gen.emit("\n")
+	}
+	gen.emit("};\n")
+	gen.emit(fmt.Sprintf("\ninline void to_json(nlohmann::json& j, const %s& v) {\n", et.Name))
+	gen.emit("    switch (v) {\n")
+	for _, elem := range et.Elements {
+		gen.emit(fmt.Sprintf("    case %s::%s:\n", et.Name, cppName(string(elem.Symbol))))
+		gen.emit(fmt.Sprintf("        j = %s;\n", cppString(enumWireName(elem))))
+		gen.emit("        break;\n")
+	}
+	gen.emit("    }\n")
+	gen.emit("}\n")
+	gen.emit(fmt.Sprintf("\ninline void from_json(const nlohmann::json& j, %s& v) {\n", et.Name))
+	gen.emit("    const auto& s = j.get_ref<const std::string&>();\n")
+	for _, elem := range et.Elements {
+		//the wire name, or the symbol or an alias
+		var conditions []string
+		for _, name := range append([]string{enumWireName(elem)}, enumReadNames(elem)...) {
+			conditions = append(conditions, "s == "+cppString(name))
+		}
+		gen.emit(fmt.Sprintf("    if (%s) {\n", strings.Join(conditions, " || ")))
+		gen.emit(fmt.Sprintf("        v = %s::%s;\n", et.Name, cppName(string(elem.Symbol))))
+		gen.emit("        return;\n")
+		gen.emit("    }\n")
+	}
+	gen.emit(fmt.Sprintf("    throw std::invalid_argument(%s + s);\n", cppString("Bad "+string(et.Name)+": ")))
+	gen.emit("}\n")
+}
+
+func (gen *cppModelGenerator) emitStruct(t *rdl.Type) {
+	st := t.StructTypeDef
+	fields := flattenedFields(gen.registry, t)
+	gen.emit("\n")
+	gen.emitComment(st.Comment, "")
+	gen.emit(fmt.Sprintf("struct %s {\n", st.Name))
+	ctypes := make([]string, 0, len(fields))
+	for _, f := range fields {
+		ctype := gen.cppType(f.Type, f.Items, false)
+		ctypes = append(ctypes, ctype)
+		decl := ctype
+		if f.Optional && f.Default == nil && ctype != "nlohmann::json" {
+			decl = "std::optional<" + ctype + ">"
+		}
+		decl += " " + cppName(string(f.Name))
+		if f.Default != nil {
+			decl += " = " + gen.literal(f)
+		}
+		gen.emit("    " + decl + ";")
+		if f.Comment != "" {
+			gen.emit(" // " + f.Comment)
+		}
+		gen.emit("\n")
+	}
+	gen.emit("};\n")
+	gen.emit(fmt.Sprintf("\ninline void to_json(nlohmann::json& j, const %s& v) {\n", st.Name))
+	gen.emit("    j = nlohmann::json::object();\n")
+	if len(fields) == 0 {
+		gen.emit("    (void)v;\n")
+	}
+	for i, f := range fields {
+		name := cppName(string(f.Name))
+		key := cppString(string(f.Name))
+		if !f.Optional || f.Default != nil {
+			gen.emit(fmt.Sprintf("    j[%s] = v.%s;\n", key, name))
+		} else if ctypes[i] == "nlohmann::json" {
+			gen.emit(fmt.Sprintf("    if (!v.%s.is_null()) {\n", name))
+			gen.emit(fmt.Sprintf("        j[%s] = v.%s;\n", key, name))
+			gen.emit("    }\n")
+		} else {
+			gen.emit(fmt.Sprintf("    if (v.%s) {\n", name))
+			gen.emit(fmt.Sprintf("        j[%s] = *v.%s;\n", key, name))
+			gen.emit("    }\n")
+		}
+	}
+	gen.emit("}\n")
+	gen.emit(fmt.Sprintf("\ninline void from_json(const nlohmann::json& j, %s& v) {\n", st.Name))
+	for i, f := range fields {
+		name := cppName(string(f.Name))
+		key := cppString(string(f.Name))
+		if !f.Optional && f.Default == nil {
+			gen.emit(fmt.Sprintf("    j.at(%s).get_to(v.%s);\n", key, name))
+			continue
+		}
+		gen.emit(fmt.Sprintf("    if (auto it = j.find(%s); it != j.end() && !it->is_null()) {\n", key))
+		if f.Default != nil || ctypes[i] == "nlohmann::json" {
+			gen.emit(fmt.Sprintf("        it->get_to(v.%s);\n", name))
+		} else {
+			gen.emit(fmt.Sprintf("        v.%s = it->get<%s>();\n", name, ctypes[i]))
+		}
+		gen.emit("    }\n")
+	}
+	if len(fields) == 0 {
+		gen.emit("    (void)j;\n")
+		gen.emit("    (void)v;\n")
+	}
+	gen.emit("}\n")
+}
+
+func (gen *cppModelGenerator) emitUnion(ut *rdl.UnionTypeDef) {
+	ctypes := make([]string, 0, len(ut.Variants))
+	for _, v := range ut.Variants {
+		ctypes = append(ctypes, gen.cppType(v, "", false))
+	}
+	gen.emit("\n")
+	gen.emitComment(ut.Comment, "")
+	gen.emit(fmt.Sprintf("struct %s {\n", ut.Name))
+	gen.emit(fmt.Sprintf("    std::variant<%s> value;\n", strings.Join(ctypes, ", ")))
+	gen.emit("};\n")
+	gen.emit(fmt.Sprintf("\ninline void to_json(nlohmann::json& j, const %s& v) {\n", ut.Name))
+	gen.emit("    j = nlohmann::json::object();\n")
+	gen.emit("    switch (v.value.index()) {\n")
+	for i, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("    case %d:\n", i))
+		gen.emit(fmt.Sprintf("        j[%s] = std::get<%d>(v.value);\n", cppString(string(v)), i))
+		gen.emit("        break;\n")
+	}
+	gen.emit("    }\n")
+	gen.emit("}\n")
+	gen.emit(fmt.Sprintf("\ninline void from_json(const nlohmann::json& j, %s& v) {\n", ut.Name))
+	gen.emit("    if (!j.is_object() || j.size() != 1) {\n")
+	gen.emit(fmt.Sprintf("        throw std::invalid_argument(%s);\n", cppString(string(ut.Name)+": expected exactly one variant")))
+	gen.emit("    }\n")
+	for i, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("    if (auto it = j.find(%s); it != j.end()) {\n", cppString(string(v))))
+		gen.emit(fmt.Sprintf("        v.value.emplace<%d>(it->get<%s>());\n", i, ctypes[i]))
+		gen.emit("        return;\n")
+		gen.emit("    }\n")
+	}
+	gen.emit(fmt.Sprintf("    throw std::invalid_argument(%s + j.begin().key());\n", cppString(string(ut.Name)+": bad variant: ")))
+	gen.emit("}\n")
+}
@@ -0,0 +1,649 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate Dart model classes for the types in an RDL schema, null safe, with fromJson/toJson
+// methods written out, so that they need no code generation step of their own
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			err = ExportToDartModel(&schema, *pOutdir)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func uncapitalize(text string) string {
+	return strings.ToLower(text[0:1]) + text[1:]
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+var dartKeywords = map[string]bool{
+	"abstract": true, "as": true, "assert": true, "async": true, "await": true, "break": true, "case": true,
+	"catch": true, "class": true, "const": true, "continue": true, "covariant": true, "default": true,
+	"deferred": true, "do": true, "dynamic": true, "else": true, "enum": true, "export": true, "extends": true,
+	"extension": true, "external": true, "factory": true, "false": true, "final": true, "finally": true,
+	"for": true, "function": true, "get": true, "hide": true, "if": true, "implements": true, "import": true,
+	"in": true, "interface": true, "is": true, "late": true, "library": true, "mixin": true, "new": true,
+	"null": true, "on": true, "operator": true, "part": true, "required": true, "rethrow": true, "return": true,
+	"set": true, "show": true, "static": true, "super": true, "switch": true, "sync": true, "this": true,
+	"throw": true, "true": true, "try": true, "typedef": true, "var": true, "void": true, "while": true,
+	"with": true, "yield": true, "hashCode": true, "runtimeType": true, "toString": true, "toJson": true,
+}
+
+// dartName returns the name of the field, the name itself unless it is reserved, e.g. default_.
+func dartName(name string) string {
+	if dartKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// dartEnumName returns the lowerCamelCase name of the value of the enum symbol, e.g. notFound for
+// NOT_FOUND, as the names, values, and index of the enum values are taken.
+func dartEnumName(symbol string) string {
+	var b strings.Builder
+	upper := false
+	for i, r := range symbol {
+		if r == '_' {
+			upper = b.Len() > 0
+			continue
+		}
+		if upper {
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		} else if strings.ToUpper(symbol) == symbol || i == 0 {
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	s := b.String()
+	switch s {
+	case "", "values", "index", "name", "value":
+		return s + "_"
+	}
+	return dartName(s)
+}
+
+// dartFileName returns the snake_case name of the file of the schema, e.g. contact_service.
+func dartFileName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) && runes[i-1] != '_' {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// dartString returns the single quoted Dart string literal of s.
+func dartString(s string) string {
+	q := strconv.Quote(s)
+	q = q[1 : len(q)-1]
+	q = strings.Replace(q, "\\\"", "\"", -1)
+	q = strings.Replace(q, "'", "\\'", -1)
+	q = strings.Replace(q, "$", "\\$", -1)
+	return "'" + q + "'"
+}
+
+type dartModelGenerator struct {
+	registry rdl.TypeRegistry
+	schema   *rdl.Schema
+	writer   *bufio.Writer
+	err      error
+}
+
+// ExportToDartModel generates a Dart library with a class for each struct and union type in the
+// schema, an enum for each enum type, and a typedef for the other named types. The classes are
+// immutable, with a const constructor taking the fields as named parameters, required unless
+// optional or defaulted, and the optional ones nullable. Their fromJson factory and toJson method
+// convert from and to the values of jsonDecode and jsonEncode, as json_serializable would. The
+// fields of the classes are flattened, not inherited.
+func ExportToDartModel(schema *rdl.Schema, outdir string) error {
+	out, file, _, err := outputWriter(outdir, dartFileName(string(schema.Name)), ".dart")
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	gen := &dartModelGenerator{rdl.NewTypeRegistry(schema), schema, out, nil}
+	gen.emit("//\n// This file generated by rdl-gen-dart-model. Do not modify!\n//\n")
+	if schema.Comment != "" {
+		gen.emitComment(schema.Comment, "")
+		gen.emit("library;\n")
+	}
+	if gen.usesBytes() {
+		gen.emit("\nimport 'dart:convert';\n")
+	}
+	for _, t := range schema.Types {
+		tName, _, _ := rdl.TypeInfo(t)
+		if strings.HasPrefix(string(tName), "rdl.") {
+			continue
+		}
+		gen.emitType(t)
+	}
+	out.Flush()
+	return gen.err
+}
+
+func (gen *dartModelGenerator) emit(s string) {
+	if gen.err == nil {
+		_, err := gen.writer.WriteString(s)
+		if err != nil {
+			gen.err = err
+		}
+	}
+}
+
+func (gen *dartModelGenerator) emitComment(comment string, indent string) {
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			gen.emit(strings.TrimRight(indent+"/// "+line, " ") + "\n")
+		}
+	}
+}
+
+// usesBytes tells whether any type of the schema refers to Bytes, decoded with dart:convert.
+func (gen *dartModelGenerator) usesBytes() bool {
+	isBytes := func(tref rdl.TypeRef) bool {
+		return tref != "" && gen.registry.FindBaseType(tref) == rdl.BaseTypeBytes
+	}
+	for _, t := range gen.schema.Types {
+		switch t.Variant {
+		case rdl.TypeVariantStructTypeDef:
+			for _, f := range t.StructTypeDef.Fields {
+				if isBytes(f.Type) || isBytes(f.Items) {
+					return true
+				}
+			}
+		case rdl.TypeVariantArrayTypeDef:
+			if isBytes(t.ArrayTypeDef.Items) {
+				return true
+			}
+		case rdl.TypeVariantMapTypeDef:
+			if isBytes(t.MapTypeDef.Items) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (gen *dartModelGenerator) emitType(t *rdl.Type) {
+	tName, tType, tComment := rdl.TypeInfo(t)
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		gen.emitStruct(t)
+	case rdl.TypeVariantUnionTypeDef:
+		gen.emitUnion(t.UnionTypeDef)
+	case rdl.TypeVariantEnumTypeDef:
+		gen.emitEnum(t.EnumTypeDef)
+	case rdl.TypeVariantArrayTypeDef:
+		gen.emitTypedef(tName, gen.dartType(rdl.TypeRef(tName), t.ArrayTypeDef.Items, true), tComment)
+	case rdl.TypeVariantMapTypeDef:
+		gen.emitTypedef(tName, gen.dartType(rdl.TypeRef(tName), t.MapTypeDef.Items, true), tComment)
+	case rdl.TypeVariantAliasTypeDef:
+		gen.emitTypedef(tName, gen.dartType(tType, "", false), tComment)
+	default:
+		gen.emitTypedef(tName, gen.dartType(rdl.TypeRef(tName), "", true), tComment)
+	}
+}
+
+func (gen *dartModelGenerator) emitTypedef(name rdl.TypeName, dtype string, comment string) {
+	gen.emit("\n")
+	gen.emitComment(comment, "")
+	gen.emit(fmt.Sprintf("typedef %s = %s;\n", name, dtype))
+}
+
+// hasClass tells whether the type is generated as a class or an enum.
+func (gen *dartModelGenerator) hasClass(t *rdl.Type) bool {
+	tName, _, _ := rdl.TypeInfo(t)
+	if tName == "Struct" || strings.HasPrefix(string(tName), "rdl.") {
+		return false
+	}
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef, rdl.TypeVariantUnionTypeDef, rdl.TypeVariantEnumTypeDef:
+		return true
+	}
+	return false
+}
+
+// dartType returns the Dart type of the type reference, the name of its class, enum, or typedef
+// if it has one, unless base is set, for the typedef of the type itself.
+func (gen *dartModelGenerator) dartType(rdlType rdl.TypeRef, items rdl.TypeRef, base bool) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil || t.Variant == 0 {
+		gen.err = fmt.Errorf("Cannot find type '%s'", rdlType)
+		return "dynamic"
+	}
+	tName, _, _ := rdl.TypeInfo(t)
+	if gen.hasClass(t) {
+		return string(tName)
+	}
+	if !base && rdl.TypeRef(tName) == rdlType && gen.isUserType(t) {
+		return string(tName)
+	}
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeAny:
+		return "dynamic"
+	case rdl.BaseTypeBool:
+		return "bool"
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+		return "int"
+	case rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return "double"
+	case rdl.BaseTypeTimestamp:
+		return "DateTime"
+	case rdl.BaseTypeBytes:
+		return "List<int>"
+	case rdl.BaseTypeStruct:
+		return "Map<String, dynamic>"
+	case rdl.BaseTypeArray:
+		items = gen.collectionItems(t, items)
+		return "List<" + gen.dartType(items, "", false) + ">"
+	case rdl.BaseTypeMap:
+		return "Map<String, " + gen.dartType(gen.collectionItems(t, items), "", false) + ">"
+	default: //String, Symbol, UUID
+		return "String"
+	}
+}
+
+// isUserType tells whether the type is defined by the schema, and so has a typedef.
+func (gen *dartModelGenerator) isUserType(t *rdl.Type) bool {
+	tName, tType, _ := rdl.TypeInfo(t)
+	if strings.HasPrefix(string(tName), "rdl.") {
+		return false
+	}
+	for _, st := range gen.schema.Types {
+		if n, _, _ := rdl.TypeInfo(st); n == tName {
+			return rdl.TypeRef(tName) != tType
+		}
+	}
+	return false
+}
+
+func (gen *dartModelGenerator) collectionItems(t *rdl.Type, items rdl.TypeRef) rdl.TypeRef {
+	switch t.Variant {
+	case rdl.TypeVariantArrayTypeDef:
+		items = t.ArrayTypeDef.Items
+	case rdl.TypeVariantMapTypeDef:
+		items = t.MapTypeDef.Items
+	}
+	if items == "" {
+		return "Any"
+	}
+	return items
+}
+
+// fromJSON returns the expression converting expr, the non-null decoded JSON of the type, to its
+// Dart value.
+func (gen *dartModelGenerator) fromJSON(expr string, rdlType rdl.TypeRef, items rdl.TypeRef, depth int) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil {
+		return expr
+	}
+	tName, _, _ := rdl.TypeInfo(t)
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeAny:
+		return expr
+	case rdl.BaseTypeBool:
+		return expr + " as bool"
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+		return "(" + expr + " as num).toInt()"
+	case rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return "(" + expr + " as num).toDouble()"
+	case rdl.BaseTypeTimestamp:
+		return "DateTime.parse(" + expr + " as String)"
+	case rdl.BaseTypeBytes:
+		return "base64Decode(" + expr + " as String)"
+	case rdl.BaseTypeStruct:
+		if gen.hasClass(t) {
+			return fmt.Sprintf("%s.fromJson(%s as Map<String, dynamic>)", tName, expr)
+		}
+		return expr + " as Map<String, dynamic>"
+	case rdl.BaseTypeUnion:
+		return fmt.Sprintf("%s.fromJson(%s as Map<String, dynamic>)", tName, expr)
+	case rdl.BaseTypeEnum:
+		return fmt.Sprintf("%s.fromJson(%s as String)", gen.enumName(rdlType), expr)
+	case rdl.BaseTypeArray:
+		e := fmt.Sprintf("e%d", depth)
+		conv := gen.fromJSON(e, gen.collectionItems(t, items), "", depth+1)
+		return fmt.Sprintf("(%s as List<dynamic>).map((%s) => %s).toList()", expr, e, conv)
+	case rdl.BaseTypeMap:
+		k := fmt.Sprintf("k%d", depth)
+		e := fmt.Sprintf("e%d", depth)
+		conv := gen.fromJSON(e, gen.collectionItems(t, items), "", depth+1)
+		return fmt.Sprintf("(%s as Map<String, dynamic>).map((%s, %s) => MapEntry(%s, %s))", expr, k, e, k, conv)
+	default: //String, Symbol, UUID
+		return expr + " as String"
+	}
+}
+
+// toJSON returns the expression converting expr, the non-null Dart value of the type, to the value
+// jsonEncode writes.
+func (gen *dartModelGenerator) toJSON(expr string, rdlType rdl.TypeRef, items rdl.TypeRef, depth int) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil {
+		return expr
+	}
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeTimestamp:
+		return expr + ".toUtc().toIso8601String()"
+	case rdl.BaseTypeBytes:
+		return "base64Encode(" + expr + ")"
+	case rdl.BaseTypeStruct:
+		if gen.hasClass(t) {
+			return expr + ".toJson()"
+		}
+	case rdl.BaseTypeUnion, rdl.BaseTypeEnum:
+		return expr + ".toJson()"
+	case rdl.BaseTypeArray:
+		e := fmt.Sprintf("e%d", depth)
+		conv := gen.toJSON(e, gen.collectionItems(t, items), "", depth+1)
+		if conv != e {
+			return fmt.Sprintf("%s.map((%s) => %s).toList()", expr, e, conv)
+		}
+	case rdl.BaseTypeMap:
+		k := fmt.Sprintf("k%d", depth)
+		e := fmt.Sprintf("e%d", depth)
+		conv := gen.toJSON(e, gen.collectionItems(t, items), "", depth+1)
+		if conv != e {
+			return fmt.Sprintf("%s.map((%s, %s) => MapEntry(%s, %s))", expr, k, e, k, conv)
+		}
+	}
+	return expr
+}
+
+// enumName returns the name of the enum type the type derives from.
+func (gen *dartModelGenerator) enumName(rdlType rdl.TypeRef) string {
+	t := gen.registry.FindType(rdlType)
+	for t != nil && t.Variant != rdl.TypeVariantEnumTypeDef {
+		_, tType, _ := rdl.TypeInfo(t)
+		t = gen.registry.FindType(tType)
+	}
+	if t == nil {
+		return string(rdlType)
+	}
+	return string(t.EnumTypeDef.Name)
+}
+
+// enumWireName returns the string the enum element is written as: its x_wire annotation, or else
+// its symbol.
+func enumWireName(elem *rdl.EnumElementDef) string {
+	if wire := strings.TrimSpace(elem.Annotations["x_wire"]); wire != "" {
+		return wire
+	}
+	return string(elem.Symbol)
+}
+
+// enumReadNames returns the strings the enum element is read from besides its wire name: its
+// symbol, if it is written as another one, and its x_aliases.
+func enumReadNames(elem *rdl.EnumElementDef) []string {
+	var names []string
+	if enumWireName(elem) != string(elem.Symbol) {
+		names = append(names, string(elem.Symbol))
+	}
+	for _, alias := range strings.Split(elem.Annotations["x_aliases"], ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
+
+func (gen *dartModelGenerator) literal(f *rdl.StructFieldDef) string {
+	switch v := f.Default.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeEnum {
+			return gen.enumName(f.Type) + "." + dartEnumName(v)
+		}
+		return dartString(v)
+	case float64:
+		switch gen.registry.FindBaseType(f.Type) {
+		case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+			return fmt.Sprintf("%d", int64(v))
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	}
+	return fmt.Sprintf("%v", f.Default)
+}
+
+func (gen *dartModelGenerator) emitEnum(et *rdl.EnumTypeDef) {
+	gen.emit("\n")
+	gen.emitComment(et.Comment, "")
+	gen.emit(fmt.Sprintf("enum %s {\n", et.Name))
+	for i, elem := range et.Elements {
+		gen.emitComment(elem.Comment, "  ")
+		sep := ","
+		if i == len(et.Elements)-1 {
+			sep = ";"
+		}
+		gen.emit(fmt.Sprintf("  %s(%s)%s\n", dartEnumName(string(elem.Symbol)), dartString(enumWireName(elem)), sep))
+	}
+	gen.emit("\n  final String value;\n\n")
+	gen.emit(fmt.Sprintf("  const %s(this.value);\n\n", et.Name))
+	//the values are the wire names, and the other strings the elements are read from are looked up first
+	var read []string
+	for _, elem := range et.Elements {
+		for _, r := range enumReadNames(elem) {
+			read = append(read, fmt.Sprintf("%s: %s.%s", dartString(r), et.Name, dartEnumName(string(elem.Symbol))))
+		}
+	}
+	lookup := ""
+	if len(read) > 0 {
+		gen.emit(fmt.Sprintf("  static const Map<String, %s> _read = {%s};\n\n", et.Name, strings.Join(read, ", ")))
+		lookup = "_read[value] ?? "
+	}
+	gen.emit(fmt.Sprintf("  static %s fromJson(String value) {\n", et.Name))
+	gen.emit(fmt.Sprintf("    return %s%s.values.firstWhere((e) => e.value == value,\n", lookup, et.Name))
+	gen.emit(fmt.Sprintf("        orElse: () => throw ArgumentError.value(value, 'value', 'not a %s'));\n", et.Name))
+	gen.emit("  }\n\n")
+	gen.emit("  String toJson() => value;\n")
+	gen.emit("}\n")
+}
+
+// dartField is a field of a class, or a variant of a union.
+type dartField struct {
+	def      *rdl.StructFieldDef
+	name     string
+	dtype    string
+	nullable bool
+}
+
+func (gen *dartModelGenerator) dartFields(fields []*rdl.StructFieldDef) []*dartField {
+	result := make([]*dartField, 0, len(fields))
+	for _, f := range fields {
+		dtype := gen.dartType(f.Type, f.Items, false)
+		nullable := f.Optional && f.Default == nil && dtype != "dynamic"
+		result = append(result, &dartField{f, dartName(string(f.Name)), dtype, nullable})
+	}
+	return result
+}
+
+// emitClass emits the fields, the constructor, fromJson, and toJson of a struct or union class.
+func (gen *dartModelGenerator) emitClass(name rdl.TypeName, comment string, fields []*dartField) {
+	gen.emit("\n")
+	gen.emitComment(comment, "")
+	gen.emit(fmt.Sprintf("class %s {\n", name))
+	for _, f := range fields {
+		gen.emitComment(f.def.Comment, "  ")
+		dtype := f.dtype
+		if f.nullable {
+			dtype += "?"
+		}
+		gen.emit(fmt.Sprintf("  final %s %s;\n", dtype, f.name))
+	}
+	if len(fields) > 0 {
+		gen.emit("\n")
+		var params []string
+		for _, f := range fields {
+			if f.def.Default != nil {
+				params = append(params, fmt.Sprintf("this.%s = %s", f.name, gen.literal(f.def)))
+			} else if f.def.Optional {
+				params = append(params, "this."+f.name)
+			} else {
+				params = append(params, "required this."+f.name)
+			}
+		}
+		gen.emit(fmt.Sprintf("  const %s({\n", name))
+		for _, p := range params {
+			gen.emit("    " + p + ",\n")
+		}
+		gen.emit("  });\n")
+	} else {
+		gen.emit(fmt.Sprintf("  const %s();\n", name))
+	}
+	gen.emit(fmt.Sprintf("\n  factory %s.fromJson(Map<String, dynamic> json) {\n", name))
+	if len(fields) == 0 {
+		gen.emit(fmt.Sprintf("    return const %s();\n", name))
+	} else {
+		gen.emit(fmt.Sprintf("    return %s(\n", name))
+		for _, f := range fields {
+			value := fmt.Sprintf("json[%s]", dartString(string(f.def.Name)))
+			conv := gen.fromJSON(value, f.def.Type, f.def.Items, 1)
+			if conv != value {
+				if f.def.Default != nil {
+					conv = fmt.Sprintf("%s == null ? %s : %s", value, gen.literal(f.def), conv)
+				} else if f.def.Optional {
+					conv = fmt.Sprintf("%s == null ? null : %s", value, conv)
+				}
+			}
+			gen.emit(fmt.Sprintf("      %s: %s,\n", f.name, conv))
+		}
+		gen.emit("    );\n")
+	}
+	gen.emit("  }\n")
+	gen.emit("\n  Map<String, dynamic> toJson() {\n")
+	if len(fields) == 0 {
+		gen.emit("    return <String, dynamic>{};\n")
+		gen.emit("  }\n")
+		return
+	}
+	gen.emit("    return <String, dynamic>{\n")
+	for _, f := range fields {
+		key := dartString(string(f.def.Name))
+		if f.nullable {
+			conv := gen.toJSON(f.name+"!", f.def.Type, f.def.Items, 1)
+			if conv == f.name+"!" {
+				conv = f.name
+			}
+			gen.emit(fmt.Sprintf("      if (%s != null) %s: %s,\n", f.name, key, conv))
+		} else if f.def.Optional && f.dtype == "dynamic" {
+			gen.emit(fmt.Sprintf("      if (%s != null) %s: %s,\n", f.name, key, f.name))
+		} else {
+			gen.emit(fmt.Sprintf("      %s: %s,\n", key, gen.toJSON(f.name, f.def.Type, f.def.Items, 1)))
+		}
+	}
+	gen.emit("    };\n")
+	gen.emit("  }\n")
+}
+
+func (gen *dartModelGenerator) emitStruct(t *rdl.Type) {
+	st := t.StructTypeDef
+	gen.emitClass(st.Name, st.Comment, gen.dartFields(flattenedFields(gen.registry, t)))
+	gen.emit("}\n")
+}
+
+func (gen *dartModelGenerator) emitUnion(ut *rdl.UnionTypeDef) {
+	var defs []*rdl.StructFieldDef
+	for _, v := range ut.Variants {
+		defs = append(defs, &rdl.StructFieldDef{Name: rdl.Identifier(v), Type: v, Optional: true})
+	}
+	fields := gen.dartFields(defs)
+	for _, f := range fields {
+		f.name = dartName(uncapitalize(string(f.def.Name)))
+	}
+	gen.emitClass(ut.Name, ut.Comment, fields)
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		names = append(names, f.name)
+	}
+	gen.emit("\n  /// isValid tells whether exactly one variant is set.\n")
+	gen.emit(fmt.Sprintf("  bool get isValid => [%s].where((v) => v != null).length == 1;\n", strings.Join(names, ", ")))
+	gen.emit("}\n")
+}
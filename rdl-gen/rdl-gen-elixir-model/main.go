@@ -0,0 +1,934 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate Elixir structs with typespecs for the types in an RDL schema, with enums as atoms, the
+// validation of the constraints of the schema, and their Jason encoding and decoding
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			err = ExportToElixirModel(&schema, *pOutdir)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func capitalize(text string) string {
+	return strings.ToUpper(text[0:1]) + text[1:]
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+// elixirFloat returns the Elixir literal of the float, which needs a fraction, e.g. 1.0e-5 for 1e-05.
+func elixirFloat(f float64, bitSize int) string {
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	mantissa, exp := s, ""
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		mantissa, exp = s[:i], "e"+strings.Replace(s[i+1:], "+", "", 1)
+	}
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	return mantissa + exp
+}
+
+func numberString(n *rdl.Number) string {
+	switch n.Variant {
+	case rdl.NumberVariantInt8:
+		return fmt.Sprintf("%d", *n.Int8)
+	case rdl.NumberVariantInt16:
+		return fmt.Sprintf("%d", *n.Int16)
+	case rdl.NumberVariantInt32:
+		return fmt.Sprintf("%d", *n.Int32)
+	case rdl.NumberVariantInt64:
+		return fmt.Sprintf("%d", *n.Int64)
+	case rdl.NumberVariantFloat32:
+		return elixirFloat(float64(*n.Float32), 32)
+	case rdl.NumberVariantFloat64:
+		return elixirFloat(*n.Float64, 64)
+	}
+	return "nil"
+}
+
+var elixirReserved = map[string]bool{
+	"after": true, "and": true, "catch": true, "do": true, "else": true, "end": true, "false": true,
+	"fn": true, "in": true, "nil": true, "not": true, "or": true, "rescue": true, "true": true,
+	"when": true,
+}
+
+// elixirName returns the snake_case name of the struct key or enum atom, e.g. last_name for lastName.
+func elixirName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) && runes[i-1] != '_' {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	s := b.String()
+	if elixirReserved[s] {
+		return s + "_"
+	}
+	return s
+}
+
+// elixirFileName returns the snake_case name of the file of the schema, e.g. contact_service.
+func elixirFileName(name string) string {
+	return strings.TrimSuffix(elixirName(name), "_")
+}
+
+// elixirEscape escapes the text for an Elixir string or heredoc, which interpolate #{...}.
+func elixirEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString("\\\\")
+		case '"':
+			b.WriteString("\\\"")
+		case '#':
+			b.WriteString("\\#")
+		case '\n':
+			b.WriteString("\\n")
+		case '\r':
+			b.WriteString("\\r")
+		case '\t':
+			b.WriteString("\\t")
+		default:
+			if r < 0x20 {
+				b.WriteString(fmt.Sprintf("\\x%02X", r))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// elixirString returns the double quoted Elixir string literal of s, without interpolation.
+func elixirString(s string) string {
+	return "\"" + elixirEscape(s) + "\""
+}
+
+const elixirPrelude = `
+defmodule {{module}}.Support do
+  @moduledoc false
+
+  # the helpers of the generated modules, which raise an ArgumentError for an invalid value, and
+  # return {:error, message} from their non-bang functions
+
+  def wrap(fun) do
+    {:ok, fun.()}
+  rescue
+    e in [ArgumentError, KeyError] -> {:error, Exception.message(e)}
+  end
+
+  def required(map, key, owner) do
+    case Map.get(map, key) do
+      nil -> raise ArgumentError, "#{owner}: missing required field '#{key}'"
+      value -> value
+    end
+  end
+
+  def map!(map, _owner) when is_map(map), do: map
+  def map!(value, owner), do: raise(ArgumentError, "#{owner}: expected a map, got #{inspect(value)}")
+
+  def default(nil, default), do: default
+  def default(value, _default), do: value
+
+  def convert(nil, _fun), do: nil
+  def convert(value, fun), do: fun.(value)
+
+  def list(values, fun) when is_list(values), do: Enum.map(values, &convert(&1, fun))
+  def list(value, _fun), do: raise(ArgumentError, "expected a list, got #{inspect(value)}")
+
+  def map(values, fun) when is_map(values), do: Map.new(values, fn {k, v} -> {k, convert(v, fun)} end)
+  def map(value, _fun), do: raise(ArgumentError, "expected a map, got #{inspect(value)}")
+
+  def decode64!(value) when is_binary(value) do
+    case Base.decode64(value) do
+      {:ok, bytes} -> bytes
+      :error -> raise ArgumentError, "invalid base64 value #{inspect(value)}"
+    end
+  end
+
+  def decode64!(value), do: raise(ArgumentError, "expected a base64 string, got #{inspect(value)}")
+
+  # dump returns the JSON map of the fields of a struct, without the unset ones.
+  def dump(fields) do
+    for {key, value} <- fields, value != nil, into: %{}, do: {key, value}
+  end
+
+  def check_required(context, nil), do: raise(ArgumentError, "#{context}: missing required value")
+  def check_required(_context, _value), do: :ok
+
+  def check_string(_context, nil, _opts), do: :ok
+
+  def check_string(context, value, opts) do
+    pattern = Keyword.get(opts, :pattern)
+    values = Keyword.get(opts, :values)
+    min_size = Keyword.get(opts, :min_size)
+    max_size = Keyword.get(opts, :max_size)
+
+    if pattern && !Regex.match?(Regex.compile!(pattern), value) do
+      raise ArgumentError, "#{context}: value #{inspect(value)} does not match pattern #{inspect(pattern)}"
+    end
+
+    if values && value not in values do
+      raise ArgumentError, "#{context}: value #{inspect(value)} is not one of #{inspect(values)}"
+    end
+
+    if min_size && String.length(value) < min_size do
+      raise ArgumentError, "#{context}: value #{inspect(value)} is shorter than #{min_size}"
+    end
+
+    if max_size && String.length(value) > max_size do
+      raise ArgumentError, "#{context}: value #{inspect(value)} is longer than #{max_size}"
+    end
+
+    :ok
+  end
+
+  def check_number(_context, nil, _opts), do: :ok
+
+  def check_number(context, value, opts) do
+    min = Keyword.get(opts, :min)
+    max = Keyword.get(opts, :max)
+
+    if min && value < min do
+      raise ArgumentError, "#{context}: value #{inspect(value)} is less than #{min}"
+    end
+
+    if max && value > max do
+      raise ArgumentError, "#{context}: value #{inspect(value)} is greater than #{max}"
+    end
+
+    :ok
+  end
+
+  def check_size(_context, nil, _opts), do: :ok
+
+  def check_size(context, value, opts) do
+    n = if is_map(value), do: map_size(value), else: length(value)
+    size = Keyword.get(opts, :size)
+    min_size = Keyword.get(opts, :min_size)
+    max_size = Keyword.get(opts, :max_size)
+
+    if size && n != size do
+      raise ArgumentError, "#{context}: size #{n} is not #{size}"
+    end
+
+    if min_size && n < min_size do
+      raise ArgumentError, "#{context}: size #{n} is less than #{min_size}"
+    end
+
+    if max_size && n > max_size do
+      raise ArgumentError, "#{context}: size #{n} is greater than #{max_size}"
+    end
+
+    :ok
+  end
+
+  def check_enum(_context, nil, _values), do: :ok
+
+  def check_enum(context, value, values) do
+    if value not in values do
+      raise ArgumentError, "#{context}: value #{inspect(value)} is not one of #{inspect(values)}"
+    end
+
+    :ok
+  end
+end
+`
+
+type elixirModelGenerator struct {
+	registry rdl.TypeRegistry
+	schema   *rdl.Schema
+	module   string
+	writer   *bufio.Writer
+	err      error
+}
+
+// ExportToElixirModel generates an Elixir file with a module for each struct, union, and enum
+// type in the schema, under a module named after the schema, and a Types module with the typespecs
+// of its other types. The struct and union modules define a struct with its @type t, new/1 and
+// validate/1 to build and check one, from_map/1 and to_map/1 to convert it from and to the JSON
+// map, decode/1 to parse it with Jason, and a Jason.Encoder implementation. The enums are atoms,
+// e.g. :fast for FAST, their modules converting them with cast/1 and dump/1. The fields of the
+// structs are flattened, not inherited, and the other types are the Elixir values of their JSON,
+// but Bytes, decoded from their base64 text.
+func ExportToElixirModel(schema *rdl.Schema, outdir string) error {
+	out, file, _, err := outputWriter(outdir, elixirFileName(string(schema.Name)), ".ex")
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	gen := &elixirModelGenerator{rdl.NewTypeRegistry(schema), schema, capitalize(string(schema.Name)), out, nil}
+	gen.emit("#\n# This file generated by rdl-gen-elixir-model. Do not modify!\n#\n")
+	if schema.Comment != "" {
+		gen.emitComment(schema.Comment, "")
+	}
+	gen.emit(strings.Replace(elixirPrelude, "{{module}}", gen.module, -1))
+	gen.emitTypes()
+	for _, t := range schema.Types {
+		tName, _, _ := rdl.TypeInfo(t)
+		if strings.HasPrefix(string(tName), "rdl.") {
+			continue
+		}
+		switch t.Variant {
+		case rdl.TypeVariantStructTypeDef:
+			gen.emitStruct(t)
+		case rdl.TypeVariantUnionTypeDef:
+			gen.emitUnion(t.UnionTypeDef)
+		case rdl.TypeVariantEnumTypeDef:
+			gen.emitEnum(t.EnumTypeDef)
+		}
+	}
+	out.Flush()
+	return gen.err
+}
+
+func (gen *elixirModelGenerator) emit(s string) {
+	if gen.err == nil {
+		_, err := gen.writer.WriteString(s)
+		if err != nil {
+			gen.err = err
+		}
+	}
+}
+
+func (gen *elixirModelGenerator) emitComment(comment string, indent string) {
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			gen.emit(strings.TrimRight(indent+"# "+line, " ") + "\n")
+		}
+	}
+}
+
+func (gen *elixirModelGenerator) emitModuleDoc(comment string) {
+	if comment == "" {
+		return
+	}
+	gen.emit("  @moduledoc \"\"\"\n")
+	for _, line := range strings.Split(comment, "\n") {
+		gen.emit(strings.TrimRight("  "+elixirEscape(line), " ") + "\n")
+	}
+	gen.emit("  \"\"\"\n\n")
+}
+
+// hasModule tells whether the type is generated as a module, a struct or an enum.
+func (gen *elixirModelGenerator) hasModule(t *rdl.Type) bool {
+	tName, _, _ := rdl.TypeInfo(t)
+	if tName == "Struct" || strings.HasPrefix(string(tName), "rdl.") {
+		return false
+	}
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef, rdl.TypeVariantUnionTypeDef, rdl.TypeVariantEnumTypeDef:
+		return true
+	}
+	return false
+}
+
+// isUserType tells whether the type is defined by the schema, and so has a typespec.
+func (gen *elixirModelGenerator) isUserType(t *rdl.Type) bool {
+	tName, tType, _ := rdl.TypeInfo(t)
+	if strings.HasPrefix(string(tName), "rdl.") {
+		return false
+	}
+	for _, st := range gen.schema.Types {
+		if n, _, _ := rdl.TypeInfo(st); n == tName {
+			return rdl.TypeRef(tName) != tType
+		}
+	}
+	return false
+}
+
+// typeSpec returns the typespec of the type reference, the t() of its module or its typespec in
+// the Types module if it has one, unless base is set, for the typespec of the type itself.
+func (gen *elixirModelGenerator) typeSpec(rdlType rdl.TypeRef, items rdl.TypeRef, base bool) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil || t.Variant == 0 {
+		gen.err = fmt.Errorf("Cannot find type '%s'", rdlType)
+		return "term()"
+	}
+	tName, _, _ := rdl.TypeInfo(t)
+	if gen.hasModule(t) {
+		return gen.module + "." + string(tName) + ".t()"
+	}
+	if !base && rdl.TypeRef(tName) == rdlType && gen.isUserType(t) {
+		return gen.module + ".Types." + elixirName(string(tName)) + "()"
+	}
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeAny:
+		return "term()"
+	case rdl.BaseTypeStruct:
+		return "map()"
+	case rdl.BaseTypeBool:
+		return "boolean()"
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+		return "integer()"
+	case rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return "number()"
+	case rdl.BaseTypeBytes:
+		return "binary()"
+	case rdl.BaseTypeArray:
+		if items = gen.collectionItems(t, items); items == "" {
+			return "list()"
+		}
+		return "[" + gen.typeSpec(items, "", false) + "]"
+	case rdl.BaseTypeMap:
+		if items = gen.collectionItems(t, items); items == "" {
+			return "map()"
+		}
+		return "%{optional(String.t()) => " + gen.typeSpec(items, "", false) + "}"
+	default: //String, Symbol, UUID, and Timestamp
+		return "String.t()"
+	}
+}
+
+// collectionItems returns the type of the items of the array or map type, declared along its
+// derivation chain, or items, for the Array<X> and Map<K,X> of a field.
+func (gen *elixirModelGenerator) collectionItems(t *rdl.Type, items rdl.TypeRef) rdl.TypeRef {
+	for t != nil {
+		switch t.Variant {
+		case rdl.TypeVariantArrayTypeDef:
+			if t.ArrayTypeDef.Items != "" {
+				return t.ArrayTypeDef.Items
+			}
+		case rdl.TypeVariantMapTypeDef:
+			if t.MapTypeDef.Items != "" {
+				return t.MapTypeDef.Items
+			}
+		}
+		tName, tType, _ := rdl.TypeInfo(t)
+		if rdl.TypeRef(tName) == tType {
+			break
+		}
+		t = gen.registry.FindType(tType)
+	}
+	return items
+}
+
+// emitTypes emits the Types module, with the typespecs of the types without a module.
+func (gen *elixirModelGenerator) emitTypes() {
+	var specs []string
+	for _, t := range gen.schema.Types {
+		tName, _, tComment := rdl.TypeInfo(t)
+		if gen.hasModule(t) || !gen.isUserType(t) {
+			continue
+		}
+		var spec string
+		if t.Variant == rdl.TypeVariantAliasTypeDef {
+			spec = gen.typeSpec(t.AliasTypeDef.Type, "", false)
+		} else {
+			spec = gen.typeSpec(rdl.TypeRef(tName), "", true)
+		}
+		var b strings.Builder
+		if tComment != "" {
+			b.WriteString(fmt.Sprintf("  @typedoc %s\n", elixirString(tComment)))
+		}
+		b.WriteString(fmt.Sprintf("  @type %s :: %s\n", elixirName(string(tName)), spec))
+		specs = append(specs, b.String())
+	}
+	if len(specs) == 0 {
+		return
+	}
+	gen.emit(fmt.Sprintf("\ndefmodule %s.Types do\n", gen.module))
+	gen.emit(fmt.Sprintf("  @moduledoc %s\n\n", elixirString("The typespecs of the types of the "+string(gen.schema.Name)+" schema that are not structs or enums.")))
+	gen.emit(strings.Join(specs, "\n"))
+	gen.emit("end\n")
+}
+
+// enumName returns the name of the enum type the type derives from.
+func (gen *elixirModelGenerator) enumName(rdlType rdl.TypeRef) string {
+	t := gen.registry.FindType(rdlType)
+	for t != nil && t.Variant != rdl.TypeVariantEnumTypeDef {
+		_, tType, _ := rdl.TypeInfo(t)
+		t = gen.registry.FindType(tType)
+	}
+	if t == nil {
+		return string(rdlType)
+	}
+	return string(t.EnumTypeDef.Name)
+}
+
+// converter returns the expression turning expr, the non-nil JSON value of the type, into its
+// Elixir value, if decode is set, or the other way around, or expr itself if they are the same.
+func (gen *elixirModelGenerator) converter(expr string, rdlType rdl.TypeRef, items rdl.TypeRef, decode bool, depth int) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil || t.Variant == 0 {
+		gen.err = fmt.Errorf("Cannot find type '%s'", rdlType)
+		return expr
+	}
+	if gen.hasModule(t) {
+		tName, _, _ := rdl.TypeInfo(t)
+		if t.Variant == rdl.TypeVariantEnumTypeDef {
+			if decode {
+				return fmt.Sprintf("%s.%s.cast!(%s)", gen.module, tName, expr)
+			}
+			return fmt.Sprintf("%s.%s.dump(%s)", gen.module, tName, expr)
+		}
+		if decode {
+			return fmt.Sprintf("%s.%s.from_map!(%s)", gen.module, tName, expr)
+		}
+		return fmt.Sprintf("%s.%s.to_map(%s)", gen.module, tName, expr)
+	}
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeBytes:
+		if decode {
+			return fmt.Sprintf("Support.decode64!(%s)", expr)
+		}
+		return fmt.Sprintf("Base.encode64(%s)", expr)
+	case rdl.BaseTypeArray, rdl.BaseTypeMap:
+		items = gen.collectionItems(t, items)
+		if items == "" {
+			return expr
+		}
+		v := fmt.Sprintf("v%d", depth)
+		conv := gen.converter(v, items, "", decode, depth+1)
+		if conv == v {
+			return expr
+		}
+		each := "list"
+		if gen.registry.BaseType(t) == rdl.BaseTypeMap {
+			each = "map"
+		}
+		return fmt.Sprintf("Support.%s(%s, fn %s -> %s end)", each, expr, v, conv)
+	}
+	return expr
+}
+
+// typeConstraints collects the restrictions declared along the type's derivation chain, closest
+// definition first, as the keyword list of the checkers of Support.
+func (gen *elixirModelGenerator) typeConstraints(rdlType rdl.TypeRef) (string, []string) {
+	var args []string
+	seen := make(map[string]bool)
+	add := func(key string, val string) {
+		if !seen[key] {
+			seen[key] = true
+			args = append(args, key+": "+val)
+		}
+	}
+	kind := ""
+	t := gen.registry.FindType(rdlType)
+	for t != nil {
+		tName, tType, _ := rdl.TypeInfo(t)
+		switch t.Variant {
+		case rdl.TypeVariantStringTypeDef:
+			kind = "check_string"
+			st := t.StringTypeDef
+			if st.Pattern != "" {
+				add("pattern", elixirString("\\A(?:"+st.Pattern+")\\z"))
+			}
+			if st.Values != nil {
+				quoted := make([]string, 0, len(st.Values))
+				for _, v := range st.Values {
+					quoted = append(quoted, elixirString(v))
+				}
+				add("values", "["+strings.Join(quoted, ", ")+"]")
+			}
+			if st.MinSize != nil {
+				add("min_size", fmt.Sprintf("%d", *st.MinSize))
+			}
+			if st.MaxSize != nil {
+				add("max_size", fmt.Sprintf("%d", *st.MaxSize))
+			}
+		case rdl.TypeVariantNumberTypeDef:
+			kind = "check_number"
+			nt := t.NumberTypeDef
+			if nt.Min != nil {
+				add("min", numberString(nt.Min))
+			}
+			if nt.Max != nil {
+				add("max", numberString(nt.Max))
+			}
+		case rdl.TypeVariantArrayTypeDef, rdl.TypeVariantMapTypeDef:
+			kind = "check_size"
+			var size, minSize, maxSize *int32
+			if t.ArrayTypeDef != nil {
+				size, minSize, maxSize = t.ArrayTypeDef.Size, t.ArrayTypeDef.MinSize, t.ArrayTypeDef.MaxSize
+			} else {
+				size, minSize, maxSize = t.MapTypeDef.Size, t.MapTypeDef.MinSize, t.MapTypeDef.MaxSize
+			}
+			if size != nil {
+				add("size", fmt.Sprintf("%d", *size))
+			}
+			if minSize != nil {
+				add("min_size", fmt.Sprintf("%d", *minSize))
+			}
+			if maxSize != nil {
+				add("max_size", fmt.Sprintf("%d", *maxSize))
+			}
+		}
+		if rdl.TypeRef(tName) == tType {
+			break
+		}
+		t = gen.registry.FindType(tType)
+	}
+	if len(args) == 0 {
+		return "", nil
+	}
+	return kind, args
+}
+
+func (gen *elixirModelGenerator) literal(f *rdl.StructFieldDef) string {
+	switch v := f.Default.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeEnum {
+			return ":" + elixirName(v)
+		}
+		return elixirString(v)
+	case float64:
+		switch gen.registry.FindBaseType(f.Type) {
+		case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+			return fmt.Sprintf("%d", int64(v))
+		}
+		return elixirFloat(v, 64)
+	}
+	return fmt.Sprintf("%v", f.Default)
+}
+
+func (gen *elixirModelGenerator) emitEnum(et *rdl.EnumTypeDef) {
+	var atoms []string
+	for _, elem := range et.Elements {
+		atoms = append(atoms, ":"+elixirName(string(elem.Symbol)))
+	}
+	gen.emit(fmt.Sprintf("\ndefmodule %s.%s do\n", gen.module, et.Name))
+	gen.emitModuleDoc(et.Comment)
+	gen.emit(fmt.Sprintf("  @type t :: %s\n\n", strings.Join(atoms, " | ")))
+	gen.emit("  @spec values() :: [t()]\n")
+	gen.emit(fmt.Sprintf("  def values, do: [%s]\n\n", strings.Join(atoms, ", ")))
+	gen.emit("  @doc \"Returns the atom of the wire name of the enum, or of its symbol or an alias.\"\n")
+	gen.emit("  @spec cast(String.t()) :: {:ok, t()} | {:error, String.t()}\n")
+	for i, elem := range et.Elements {
+		if elem.Comment != "" {
+			gen.emitComment(elem.Comment, "  ")
+		}
+		for _, name := range append([]string{enumWireName(elem)}, enumReadNames(elem)...) {
+			gen.emit(fmt.Sprintf("  def cast(%s), do: {:ok, %s}\n", elixirString(name), atoms[i]))
+		}
+	}
+	gen.emit(fmt.Sprintf("  def cast(value), do: {:error, \"%s: value #{inspect(value)} is not one of %s\"}\n\n", et.Name, elixirEscape(strings.Join(wireNames(et), ", "))))
+	gen.emit("  @spec cast!(String.t()) :: t()\n")
+	gen.emit("  def cast!(value) do\n")
+	gen.emit("    case cast(value) do\n")
+	gen.emit("      {:ok, atom} -> atom\n")
+	gen.emit("      {:error, message} -> raise ArgumentError, message\n")
+	gen.emit("    end\n")
+	gen.emit("  end\n\n")
+	gen.emit("  @doc \"Returns the wire name of the atom of the enum.\"\n")
+	gen.emit("  @spec dump(t()) :: String.t()\n")
+	for i, elem := range et.Elements {
+		gen.emit(fmt.Sprintf("  def dump(%s), do: %s\n", atoms[i], elixirString(enumWireName(elem))))
+	}
+	gen.emit("end\n")
+}
+
+func wireNames(et *rdl.EnumTypeDef) []string {
+	var s []string
+	for _, elem := range et.Elements {
+		s = append(s, enumWireName(elem))
+	}
+	return s
+}
+
+// enumWireName returns the string the enum element is written as: its x_wire annotation, or else
+// its symbol.
+func enumWireName(elem *rdl.EnumElementDef) string {
+	if wire := strings.TrimSpace(elem.Annotations["x_wire"]); wire != "" {
+		return wire
+	}
+	return string(elem.Symbol)
+}
+
+// enumReadNames returns the strings the enum element is read from besides its wire name: its
+// symbol, if it is written as another one, and its x_aliases.
+func enumReadNames(elem *rdl.EnumElementDef) []string {
+	var names []string
+	if enumWireName(elem) != string(elem.Symbol) {
+		names = append(names, string(elem.Symbol))
+	}
+	for _, alias := range strings.Split(elem.Annotations["x_aliases"], ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
+
+// emitCommon emits the functions of the struct and union modules but from_map! and validate!.
+func (gen *elixirModelGenerator) emitCommon(name string) {
+	gen.emit(fmt.Sprintf("  alias %s.Support\n\n", gen.module))
+	gen.emit("  @doc \"Builds the struct from the keyword list or map of its fields, and validates it.\"\n")
+	gen.emit("  @spec new(keyword() | map()) :: {:ok, t()} | {:error, String.t()}\n")
+	gen.emit("  def new(fields \\\\ []), do: Support.wrap(fn -> validate!(struct!(__MODULE__, fields)) end)\n\n")
+	gen.emit("  @spec validate(t()) :: {:ok, t()} | {:error, String.t()}\n")
+	gen.emit("  def validate(value), do: Support.wrap(fn -> validate!(value) end)\n\n")
+	gen.emit("  @doc \"Converts the JSON map, as Jason decodes it, to the struct, and validates it.\"\n")
+	gen.emit("  @spec from_map(map()) :: {:ok, t()} | {:error, String.t()}\n")
+	gen.emit("  def from_map(map), do: Support.wrap(fn -> from_map!(map) end)\n\n")
+	gen.emit("  @spec decode(iodata()) :: {:ok, t()} | {:error, term()}\n")
+	gen.emit("  def decode(json) do\n")
+	gen.emit("    with {:ok, map} <- Jason.decode(json), do: from_map(map)\n")
+	gen.emit("  end\n\n")
+	gen.emit("  defimpl Jason.Encoder do\n")
+	gen.emit(fmt.Sprintf("    def encode(value, opts), do: Jason.Encode.map(%s.%s.to_map(value), opts)\n", gen.module, name))
+	gen.emit("  end\n")
+}
+
+// emitStructDef emits the @type t and the defstruct of the module.
+func (gen *elixirModelGenerator) emitStructDef(names []string, specs []string, defaults []string) {
+	if len(names) == 0 {
+		gen.emit("  @type t :: %__MODULE__{}\n\n")
+		gen.emit("  defstruct []\n\n")
+		return
+	}
+	gen.emit("  @type t :: %__MODULE__{\n")
+	for i, name := range names {
+		sep := ","
+		if i == len(names)-1 {
+			sep = ""
+		}
+		gen.emit(fmt.Sprintf("          %s: %s%s\n", name, specs[i], sep))
+	}
+	gen.emit("        }\n\n")
+	gen.emit("  defstruct ")
+	for i, name := range names {
+		if i > 0 {
+			gen.emit(",\n            ")
+		}
+		gen.emit(fmt.Sprintf("%s: %s", name, defaults[i]))
+	}
+	gen.emit("\n\n")
+}
+
+// emitToMap emits the to_map function, converting the struct to its JSON map.
+func (gen *elixirModelGenerator) emitToMap(jsonNames []string, names []string, types []rdl.TypeRef, items []rdl.TypeRef) {
+	gen.emit("\n  @doc \"Converts the struct to its JSON map, without the unset fields.\"\n")
+	gen.emit("  @spec to_map(t()) :: map()\n")
+	if len(names) == 0 {
+		gen.emit("  def to_map(%__MODULE__{}), do: %{}\n")
+		return
+	}
+	gen.emit("  def to_map(%__MODULE__{} = value) do\n")
+	gen.emit("    Support.dump([\n")
+	for i, name := range names {
+		expr := "value." + name
+		if conv := gen.converter("v", types[i], items[i], false, 1); conv != "v" {
+			expr = fmt.Sprintf("Support.convert(%s, fn v -> %s end)", expr, conv)
+		}
+		sep := ","
+		if i == len(names)-1 {
+			sep = ""
+		}
+		gen.emit(fmt.Sprintf("      {%s, %s}%s\n", elixirString(jsonNames[i]), expr, sep))
+	}
+	gen.emit("    ])\n")
+	gen.emit("  end\n")
+}
+
+func (gen *elixirModelGenerator) emitStruct(t *rdl.Type) {
+	st := t.StructTypeDef
+	fields := flattenedFields(gen.registry, t)
+	var names, specs, defaults, jsonNames []string
+	var types, items []rdl.TypeRef
+	for _, f := range fields {
+		names = append(names, elixirName(string(f.Name)))
+		jsonNames = append(jsonNames, string(f.Name))
+		types = append(types, f.Type)
+		items = append(items, f.Items)
+		spec := gen.typeSpec(f.Type, f.Items, false)
+		if f.Optional && f.Default == nil {
+			spec += " | nil"
+		}
+		specs = append(specs, spec)
+		if f.Default != nil {
+			defaults = append(defaults, gen.literal(f))
+		} else {
+			defaults = append(defaults, "nil")
+		}
+	}
+	gen.emit(fmt.Sprintf("\ndefmodule %s.%s do\n", gen.module, st.Name))
+	gen.emitModuleDoc(st.Comment)
+	for _, f := range fields {
+		if f.Comment != "" {
+			gen.emit(fmt.Sprintf("  # %s: %s\n", elixirName(string(f.Name)), f.Comment))
+		}
+	}
+	gen.emitStructDef(names, specs, defaults)
+	gen.emitCommon(string(st.Name))
+	gen.emit("\n  @spec validate!(t()) :: t()\n")
+	gen.emit("  def validate!(%__MODULE__{} = value) do\n")
+	for i, f := range fields {
+		context := elixirString(string(st.Name) + "." + string(f.Name))
+		if !f.Optional {
+			gen.emit(fmt.Sprintf("    Support.check_required(%s, value.%s)\n", context, names[i]))
+		}
+		if kind, args := gen.typeConstraints(f.Type); kind != "" {
+			gen.emit(fmt.Sprintf("    Support.%s(%s, value.%s, %s)\n", kind, context, names[i], strings.Join(args, ", ")))
+		}
+		if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeEnum {
+			gen.emit(fmt.Sprintf("    Support.check_enum(%s, value.%s, %s.%s.values())\n", context, names[i], gen.module, gen.enumName(f.Type)))
+		}
+	}
+	gen.emit("    value\n")
+	gen.emit("  end\n")
+	gen.emit("\n  @spec from_map!(map()) :: t()\n")
+	if len(fields) == 0 {
+		gen.emit(fmt.Sprintf("  def from_map!(map) do\n    Support.map!(map, %s)\n    %%__MODULE__{}\n  end\n", elixirString(string(st.Name))))
+	} else {
+		gen.emit("  def from_map!(map) do\n")
+		gen.emit(fmt.Sprintf("    map = Support.map!(map, %s)\n\n", elixirString(string(st.Name))))
+		gen.emit("    validate!(%__MODULE__{\n")
+		for i, f := range fields {
+			var expr string
+			if f.Optional || f.Default != nil {
+				expr = fmt.Sprintf("Map.get(map, %s)", elixirString(string(f.Name)))
+			} else {
+				expr = fmt.Sprintf("Support.required(map, %s, %s)", elixirString(string(f.Name)), elixirString(string(st.Name)))
+			}
+			if conv := gen.converter("v", f.Type, f.Items, true, 1); conv != "v" {
+				expr = fmt.Sprintf("Support.convert(%s, fn v -> %s end)", expr, conv)
+			}
+			if f.Default != nil {
+				expr = fmt.Sprintf("Support.default(%s, %s)", expr, gen.literal(f))
+			}
+			sep := ","
+			if i == len(fields)-1 {
+				sep = ""
+			}
+			gen.emit(fmt.Sprintf("      %s: %s%s\n", names[i], expr, sep))
+		}
+		gen.emit("    })\n")
+		gen.emit("  end\n")
+	}
+	gen.emitToMap(jsonNames, names, types, items)
+	gen.emit("end\n")
+}
+
+func (gen *elixirModelGenerator) emitUnion(ut *rdl.UnionTypeDef) {
+	var names, specs, defaults, jsonNames []string
+	var types, items []rdl.TypeRef
+	for _, v := range ut.Variants {
+		names = append(names, elixirName(string(v)))
+		jsonNames = append(jsonNames, string(v))
+		types = append(types, v)
+		items = append(items, "")
+		specs = append(specs, gen.typeSpec(v, "", false)+" | nil")
+		defaults = append(defaults, "nil")
+	}
+	gen.emit(fmt.Sprintf("\ndefmodule %s.%s do\n", gen.module, ut.Name))
+	gen.emitModuleDoc(ut.Comment)
+	gen.emit("  # exactly one of the variants is set\n")
+	gen.emitStructDef(names, specs, defaults)
+	gen.emitCommon(string(ut.Name))
+	gen.emit("\n  @spec validate!(t()) :: t()\n")
+	gen.emit("  def validate!(%__MODULE__{} = value) do\n")
+	vals := make([]string, 0, len(names))
+	for _, name := range names {
+		vals = append(vals, "value."+name)
+	}
+	gen.emit(fmt.Sprintf("    if Enum.count([%s], &(&1 != nil)) != 1 do\n", strings.Join(vals, ", ")))
+	gen.emit(fmt.Sprintf("      raise ArgumentError, %s\n", elixirString(string(ut.Name)+": exactly one variant must be set")))
+	gen.emit("    end\n\n")
+	gen.emit("    value\n")
+	gen.emit("  end\n")
+	gen.emit("\n  @spec from_map!(map()) :: t()\n")
+	gen.emit("  def from_map!(map) do\n")
+	gen.emit(fmt.Sprintf("    map = Support.map!(map, %s)\n\n", elixirString(string(ut.Name))))
+	gen.emit("    validate!(%__MODULE__{\n")
+	for i, v := range ut.Variants {
+		expr := fmt.Sprintf("Map.get(map, %s)", elixirString(string(v)))
+		if conv := gen.converter("v", v, "", true, 1); conv != "v" {
+			expr = fmt.Sprintf("Support.convert(%s, fn v -> %s end)", expr, conv)
+		}
+		sep := ","
+		if i == len(ut.Variants)-1 {
+			sep = ""
+		}
+		gen.emit(fmt.Sprintf("      %s: %s%s\n", names[i], expr, sep))
+	}
+	gen.emit("    })\n")
+	gen.emit("  end\n")
+	gen.emitToMap(jsonNames, names, types, items)
+	gen.emit("end\n")
+}
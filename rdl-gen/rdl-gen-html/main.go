@@ -0,0 +1,712 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate a static HTML documentation page for an RDL schema, with the styles and scripts it
+// needs inlined, and optionally a "try it" form per resource to send requests to a server
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	pTryIt := flag.String("tryit", "false", "Add a form to each resource to send requests to a server")
+	pBaseURL := flag.String("url", "", "The initial base URL of the server for the try it forms")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			tryIt, _ := strconv.ParseBool(*pTryIt)
+			err = ExportToHTML(&schema, *pOutdir, tryIt, *pBaseURL)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func capitalize(text string) string {
+	return strings.ToUpper(text[0:1]) + text[1:]
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+// the view of the schema the template renders
+
+type htmlPage struct {
+	Title      string
+	Comment    string
+	Attributes [][]string
+	Groups     []*htmlGroup
+	Types      []*htmlType
+	TryIt      bool
+	BaseURL    string
+}
+
+type htmlGroup struct {
+	Name      string
+	Type      template.HTML
+	Resources []*htmlResource
+}
+
+type htmlResource struct {
+	Anchor     string
+	Method     string
+	Path       string
+	Comment    string
+	Deprecated string
+	Auth       string
+	Inputs     []*htmlParam
+	Outputs    []*htmlParam
+	Expected   [][]template.HTML
+	Exceptions [][]template.HTML
+	HasBody    bool
+}
+
+type htmlParam struct {
+	Name    string
+	Type    template.HTML
+	Source  string
+	Key     string
+	Options string
+	Comment string
+}
+
+type htmlType struct {
+	Name        string
+	Anchor      string
+	Comment     string
+	Deprecated  string
+	Description template.HTML
+	Fields      []*htmlField
+	Rows        [][]template.HTML
+	RowHeader   []string
+}
+
+type htmlField struct {
+	Name       string
+	Type       template.HTML
+	Options    string
+	Comment    string
+	Deprecated string
+	From       template.HTML
+}
+
+type htmlGenerator struct {
+	registry rdl.TypeRegistry
+	schema   *rdl.Schema
+}
+
+// ExportToHTML writes the HTML documentation of the schema to index.html in the output
+// directory (or to the named .html file, or stdout).
+func ExportToHTML(schema *rdl.Schema, outdir string, tryIt bool, baseURL string) error {
+	out, file, _, err := outputWriter(outdir, "index", ".html")
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	gen := &htmlGenerator{rdl.NewTypeRegistry(schema), schema}
+	t := template.Must(template.New("html").Parse(htmlTemplate))
+	err = t.Execute(out, gen.page(tryIt, baseURL))
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func typeAnchor(name rdl.TypeName) string {
+	return "type-" + strings.ToLower(string(name))
+}
+
+// typeRef renders the type reference, linked to its definition if the schema defines it.
+func (gen *htmlGenerator) typeRef(ref rdl.TypeRef) template.HTML {
+	s := html.EscapeString(string(ref))
+	t := gen.registry.FindType(ref)
+	if t != nil {
+		tName, tType, _ := rdl.TypeInfo(t)
+		if rdl.TypeRef(tName) != tType {
+			return template.HTML("<a href=\"#" + typeAnchor(tName) + "\">" + s + "</a>")
+		}
+	}
+	return template.HTML(s)
+}
+
+func (gen *htmlGenerator) fieldTypeRef(ref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef) template.HTML {
+	s := gen.typeRef(ref)
+	if keys != "" {
+		s += "&lt;" + gen.typeRef(keys) + "," + gen.typeRef(items) + "&gt;"
+	} else if items != "" {
+		s += "&lt;" + gen.typeRef(items) + "&gt;"
+	}
+	return s
+}
+
+func literalString(lit interface{}) string {
+	switch v := lit.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(lit)
+}
+
+func optionsString(optional bool, def interface{}) string {
+	var options []string
+	if optional {
+		options = append(options, "optional")
+	}
+	if def != nil {
+		options = append(options, "default="+literalString(def))
+	}
+	return strings.Join(options, ", ")
+}
+
+func (gen *htmlGenerator) page(tryIt bool, baseURL string) *htmlPage {
+	schema := gen.schema
+	category := "Schema"
+	if len(schema.Resources) > 0 {
+		category = "API"
+	}
+	page := &htmlPage{Title: category, Comment: schema.Comment, TryIt: tryIt, BaseURL: baseURL}
+	if schema.Name != "" {
+		page.Title = "The " + capitalize(string(schema.Name)) + " " + category
+	}
+	if schema.Namespace != "" {
+		page.Attributes = append(page.Attributes, []string{"namespace", string(schema.Namespace)})
+	}
+	if schema.Version != nil {
+		page.Attributes = append(page.Attributes, []string{"version", fmt.Sprintf("%d", *schema.Version)})
+	}
+	groups := make(map[string]*htmlGroup)
+	for i, r := range schema.Resources {
+		g, ok := groups[string(r.Type)]
+		if !ok {
+			g = &htmlGroup{Name: string(r.Type), Type: gen.typeRef(r.Type)}
+			groups[g.Name] = g
+			page.Groups = append(page.Groups, g)
+		}
+		g.Resources = append(g.Resources, gen.resource(r, i))
+	}
+	for _, t := range schema.Types {
+		page.Types = append(page.Types, gen.typeDef(t))
+	}
+	return page
+}
+
+func (gen *htmlGenerator) resource(r *rdl.Resource, index int) *htmlResource {
+	path := r.Path
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	res := &htmlResource{
+		Anchor:     fmt.Sprintf("resource-%d", index),
+		Method:     strings.ToUpper(r.Method),
+		Path:       path,
+		Comment:    r.Comment,
+		Deprecated: deprecation(r.Annotations),
+	}
+	if r.Auth != nil {
+		if r.Auth.Authenticate {
+			res.Auth = "authenticated"
+		} else if r.Auth.Action != "" {
+			res.Auth = fmt.Sprintf("authorized for action '%s' on resource '%s'", r.Auth.Action, r.Auth.Resource)
+		}
+	}
+	for _, in := range r.Inputs {
+		p := &htmlParam{Name: string(in.Name), Type: gen.typeRef(in.Type), Options: optionsString(in.Optional, in.Default), Comment: in.Comment}
+		switch {
+		case in.PathParam:
+			p.Source, p.Key = "path", string(in.Name)
+		case in.QueryParam != "":
+			p.Source, p.Key = "query", in.QueryParam
+		case in.Header != "":
+			p.Source, p.Key = "header", in.Header
+		default:
+			p.Source = "body"
+			res.HasBody = true
+		}
+		res.Inputs = append(res.Inputs, p)
+	}
+	for _, out := range r.Outputs {
+		res.Outputs = append(res.Outputs, &htmlParam{Name: string(out.Name), Type: gen.typeRef(out.Type), Source: "header", Key: out.Header, Options: optionsString(out.Optional, nil), Comment: out.Comment})
+	}
+	expected := r.Expected
+	if expected == "" {
+		expected = "OK"
+	}
+	for _, e := range append([]string{expected}, r.Alternatives...) {
+		var t template.HTML
+		if e != "NO_CONTENT" && e != "NOT_MODIFIED" {
+			t = gen.typeRef(r.Type)
+		}
+		res.Expected = append(res.Expected, []template.HTML{template.HTML(html.EscapeString(rdl.StatusCode(e) + " " + rdl.StatusMessage(e))), t})
+	}
+	codes := make([]string, 0, len(r.Exceptions))
+	for code := range r.Exceptions {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return rdl.StatusCode(codes[i]) < rdl.StatusCode(codes[j]) })
+	for _, code := range codes {
+		e := r.Exceptions[code]
+		res.Exceptions = append(res.Exceptions, []template.HTML{
+			template.HTML(html.EscapeString(rdl.StatusCode(code) + " " + rdl.StatusMessage(code))),
+			gen.typeRef(rdl.TypeRef(e.Type)),
+			template.HTML(html.EscapeString(e.Comment)),
+		})
+	}
+	return res
+}
+
+func escaped(s string) template.HTML {
+	return template.HTML(html.EscapeString(s))
+}
+
+func code(s string) template.HTML {
+	return "<code>" + escaped(s) + "</code>"
+}
+
+func sizeRows(rows [][]template.HTML, size *int32, minSize *int32, maxSize *int32) [][]template.HTML {
+	if size != nil {
+		rows = append(rows, []template.HTML{"size", escaped(fmt.Sprint(*size))})
+	}
+	if minSize != nil {
+		rows = append(rows, []template.HTML{"minSize", escaped(fmt.Sprint(*minSize))})
+	}
+	if maxSize != nil {
+		rows = append(rows, []template.HTML{"maxSize", escaped(fmt.Sprint(*maxSize))})
+	}
+	return rows
+}
+
+func numberString(n *rdl.Number) string {
+	switch n.Variant {
+	case rdl.NumberVariantInt8:
+		return fmt.Sprint(*n.Int8)
+	case rdl.NumberVariantInt16:
+		return fmt.Sprint(*n.Int16)
+	case rdl.NumberVariantInt32:
+		return fmt.Sprint(*n.Int32)
+	case rdl.NumberVariantInt64:
+		return fmt.Sprint(*n.Int64)
+	case rdl.NumberVariantFloat32:
+		return strconv.FormatFloat(float64(*n.Float32), 'g', -1, 32)
+	case rdl.NumberVariantFloat64:
+		return strconv.FormatFloat(*n.Float64, 'g', -1, 64)
+	}
+	return ""
+}
+
+// typeAnnotations returns the extended annotations of the type, whatever its variant.
+func typeAnnotations(t *rdl.Type) map[rdl.ExtendedAnnotation]string {
+	switch t.Variant {
+	case rdl.TypeVariantAliasTypeDef:
+		return t.AliasTypeDef.Annotations
+	case rdl.TypeVariantStringTypeDef:
+		return t.StringTypeDef.Annotations
+	case rdl.TypeVariantNumberTypeDef:
+		return t.NumberTypeDef.Annotations
+	case rdl.TypeVariantArrayTypeDef:
+		return t.ArrayTypeDef.Annotations
+	case rdl.TypeVariantMapTypeDef:
+		return t.MapTypeDef.Annotations
+	case rdl.TypeVariantStructTypeDef:
+		return t.StructTypeDef.Annotations
+	case rdl.TypeVariantEnumTypeDef:
+		return t.EnumTypeDef.Annotations
+	case rdl.TypeVariantUnionTypeDef:
+		return t.UnionTypeDef.Annotations
+	case rdl.TypeVariantBytesTypeDef:
+		return t.BytesTypeDef.Annotations
+	}
+	return nil
+}
+
+// deprecation returns the reason the definition is deprecated for, if it is annotated with
+// x_deprecated, or else the empty string.
+func deprecation(annotations map[rdl.ExtendedAnnotation]string) string {
+	reason, ok := annotations["x_deprecated"]
+	if !ok {
+		return ""
+	}
+	if reason = strings.TrimSpace(reason); reason == "" {
+		reason = "No longer to be used."
+	}
+	return reason
+}
+
+func (gen *htmlGenerator) typeDef(t *rdl.Type) *htmlType {
+	tName, tType, tComment := rdl.TypeInfo(t)
+	ht := &htmlType{Name: string(tName), Anchor: typeAnchor(tName), Comment: tComment, Deprecated: deprecation(typeAnnotations(t))}
+	derived := code(string(tName)) + " is a " + gen.typeRef(tType)
+	optionHeader := []string{"Option", "Value"}
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		ht.Description = derived + " with the following fields:"
+		for _, f := range flattenedFields(gen.registry, t) {
+			hf := &htmlField{Name: string(f.Name), Type: gen.fieldTypeRef(f.Type, f.Items, f.Keys), Options: optionsString(f.Optional, f.Default), Comment: f.Comment, Deprecated: deprecation(f.Annotations)}
+			ht.Fields = append(ht.Fields, hf)
+		}
+		//mark the fields inherited from the supertypes
+		inherited := len(ht.Fields) - len(t.StructTypeDef.Fields)
+		for st := gen.registry.FindType(tType); st != nil && st.Variant == rdl.TypeVariantStructTypeDef && inherited > 0; st = gen.registry.FindType(st.StructTypeDef.Type) {
+			own := len(st.StructTypeDef.Fields)
+			for i := inherited - own; i < inherited; i++ {
+				ht.Fields[i].From = gen.typeRef(rdl.TypeRef(st.StructTypeDef.Name))
+			}
+			inherited -= own
+		}
+		if len(ht.Fields) == 0 {
+			ht.Description = derived + " with no specified fields."
+		}
+	case rdl.TypeVariantStringTypeDef:
+		st := t.StringTypeDef
+		if st.Pattern != "" {
+			ht.Rows = append(ht.Rows, []template.HTML{"pattern", code(st.Pattern)})
+		}
+		if len(st.Values) > 0 {
+			ht.Rows = append(ht.Rows, []template.HTML{"values", code(strings.Join(st.Values, ", "))})
+		}
+		ht.Rows = sizeRows(ht.Rows, nil, st.MinSize, st.MaxSize)
+	case rdl.TypeVariantNumberTypeDef:
+		nt := t.NumberTypeDef
+		if nt.Min != nil {
+			ht.Rows = append(ht.Rows, []template.HTML{"min", escaped(numberString(nt.Min))})
+		}
+		if nt.Max != nil {
+			ht.Rows = append(ht.Rows, []template.HTML{"max", escaped(numberString(nt.Max))})
+		}
+	case rdl.TypeVariantBytesTypeDef:
+		bt := t.BytesTypeDef
+		ht.Rows = sizeRows(ht.Rows, bt.Size, bt.MinSize, bt.MaxSize)
+	case rdl.TypeVariantArrayTypeDef:
+		at := t.ArrayTypeDef
+		derived = code(string(tName)) + " is an " + gen.typeRef(tType) + " of " + gen.typeRef(at.Items)
+		ht.Rows = sizeRows(ht.Rows, at.Size, at.MinSize, at.MaxSize)
+	case rdl.TypeVariantMapTypeDef:
+		mt := t.MapTypeDef
+		derived = code(string(tName)) + " is a " + gen.typeRef(tType) + " of " + gen.typeRef(mt.Keys) + " to " + gen.typeRef(mt.Items)
+		ht.Rows = sizeRows(ht.Rows, mt.Size, mt.MinSize, mt.MaxSize)
+	case rdl.TypeVariantEnumTypeDef:
+		derived = code(string(tName)) + " is an Enum of the following values:"
+		optionHeader = []string{"Value", "Description"}
+		for _, e := range t.EnumTypeDef.Elements {
+			value, comment := code(string(e.Symbol)), escaped(e.Comment)
+			if reason := deprecation(e.Annotations); reason != "" {
+				value = "<s>" + value + "</s>"
+				if comment != "" {
+					comment = " " + comment
+				}
+				comment = "<strong>Deprecated:</strong> " + escaped(reason) + comment
+			}
+			ht.Rows = append(ht.Rows, []template.HTML{value, comment})
+		}
+	case rdl.TypeVariantUnionTypeDef:
+		derived = code(string(tName)) + " is a Union of the following types:"
+		optionHeader = []string{"Variant"}
+		for _, v := range t.UnionTypeDef.Variants {
+			ht.Rows = append(ht.Rows, []template.HTML{gen.typeRef(v)})
+		}
+	case rdl.TypeVariantAliasTypeDef:
+		derived = code(string(tName)) + " is an alias of " + gen.typeRef(tType)
+	}
+	if ht.Description == "" {
+		ht.Description = derived
+		if len(ht.Rows) > 0 && t.Variant != rdl.TypeVariantEnumTypeDef && t.Variant != rdl.TypeVariantUnionTypeDef {
+			ht.Description += " with the following options:"
+		} else if len(ht.Rows) == 0 {
+			ht.Description += "."
+		}
+	}
+	if len(ht.Rows) > 0 {
+		ht.RowHeader = optionHeader
+	}
+	return ht
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; line-height: 1.5; }
+nav { position: fixed; top: 0; bottom: 0; left: 0; width: 16em; overflow-y: auto; padding: 1em; background: #f5f6f8; border-right: 1px solid #ddd; box-sizing: border-box; }
+nav h3 { margin: 1em 0 0.3em; font-size: 0.9em; text-transform: uppercase; color: #666; }
+nav ul { list-style: none; margin: 0; padding: 0; }
+nav li { margin: 0.1em 0; font-size: 0.9em; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+main { margin-left: 16em; padding: 1em 2em 4em; max-width: 60em; }
+a { color: #0b5cad; text-decoration: none; }
+a:hover { text-decoration: underline; }
+code { font-family: Menlo, Consolas, monospace; font-size: 0.9em; }
+table { border-collapse: collapse; margin: 0.5em 0 1em; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f5f6f8; }
+section.resource, section.type { border-top: 1px solid #eee; padding-top: 0.5em; }
+.method { display: inline-block; min-width: 4.5em; padding: 0.1em 0.4em; border-radius: 3px; color: #fff; font-size: 0.8em; font-weight: bold; text-align: center; }
+.GET { background: #2f7d32; } .PUT { background: #b26a00; } .POST { background: #0b5cad; } .DELETE { background: #b3261e; } .PATCH { background: #6a1b9a; } .OPTIONS { background: #555; }
+.console { position: sticky; top: 0; background: #fff; padding: 0.5em 0; border-bottom: 1px solid #ddd; z-index: 1; }
+.console input { width: 20em; }
+form.tryit { background: #f5f6f8; padding: 0.5em 1em; border-radius: 4px; }
+form.tryit label { display: block; margin: 0.3em 0; }
+form.tryit label span { display: inline-block; min-width: 10em; }
+form.tryit textarea { width: 100%; height: 8em; font-family: Menlo, Consolas, monospace; }
+pre.result { background: #222; color: #eee; padding: 0.5em; overflow-x: auto; display: none; }
+</style>
+</head>
+<body>
+<nav>
+<a href="#top"><strong>{{.Title}}</strong></a>
+{{- if .Groups}}
+<h3>Resources</h3>
+<ul>
+{{- range .Groups}}{{range .Resources}}
+<li><a href="#{{.Anchor}}"><span class="method {{.Method}}">{{.Method}}</span> {{if .Deprecated}}<s>{{.Path}}</s>{{else}}{{.Path}}{{end}}</a></li>
+{{- end}}{{end}}
+</ul>
+{{- end}}
+{{- if .Types}}
+<h3>Types</h3>
+<ul>
+{{- range .Types}}
+<li><a href="#{{.Anchor}}">{{if .Deprecated}}<s>{{.Name}}</s>{{else}}{{.Name}}{{end}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+</nav>
+<main>
+<h1 id="top">{{.Title}}</h1>
+{{- if .Comment}}
+<p>{{.Comment}}</p>
+{{- end}}
+{{- if .Attributes}}
+<table>
+<tr><th>Attribute</th><th>Value</th></tr>
+{{- range .Attributes}}
+<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .TryIt}}
+<div class="console">
+<label>Base URL <input id="base-url" value="{{.BaseURL}}" placeholder="http://localhost:4080/api"></label>
+<label>Credentials <input id="creds-header" placeholder="header"> <input id="creds-token" placeholder="token"></label>
+</div>
+{{- end}}
+{{- if .Groups}}
+<h2>Resources</h2>
+{{- $tryIt := .TryIt}}
+{{- range .Groups}}
+<h3>{{.Type}}</h3>
+{{- range .Resources}}
+<section class="resource" id="{{.Anchor}}">
+<h4><span class="method {{.Method}}">{{.Method}}</span> {{if .Deprecated}}<s><code>{{.Path}}</code></s>{{else}}<code>{{.Path}}</code>{{end}}</h4>
+{{- if .Deprecated}}
+<p><strong>Deprecated:</strong> {{.Deprecated}}</p>
+{{- end}}
+{{- if .Comment}}
+<p>{{.Comment}}</p>
+{{- end}}
+{{- if .Auth}}
+<p><em>Requires the caller to be {{.Auth}}.</em></p>
+{{- end}}
+{{- if .Inputs}}
+<p>Request parameters:</p>
+<table>
+<tr><th>Name</th><th>Type</th><th>Source</th><th>Options</th><th>Description</th></tr>
+{{- range .Inputs}}
+<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{.Source}}{{if and .Key (ne .Source "path")}}: {{.Key}}{{end}}</td><td>{{.Options}}</td><td>{{.Comment}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Outputs}}
+<p>Response parameters:</p>
+<table>
+<tr><th>Name</th><th>Type</th><th>Destination</th><th>Options</th><th>Description</th></tr>
+{{- range .Outputs}}
+<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>header: {{.Key}}</td><td>{{.Options}}</td><td>{{.Comment}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<p>Responses:</p>
+<table>
+<tr><th>Code</th><th>Type</th></tr>
+{{- range .Expected}}
+<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+{{- if .Exceptions}}
+<p>Exceptions:</p>
+<table>
+<tr><th>Code</th><th>Type</th><th>Description</th></tr>
+{{- range .Exceptions}}
+<tr><td>{{index . 0}}</td><td>{{index . 1}}</td><td>{{index . 2}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if $tryIt}}
+<form class="tryit" data-method="{{.Method}}" data-path="{{.Path}}" onsubmit="return tryIt(this)">
+{{- range .Inputs}}
+{{- if eq .Source "body"}}
+<label><span>{{.Name}} (body)</span><textarea data-source="body" placeholder="JSON"></textarea></label>
+{{- else}}
+<label><span>{{.Name}} ({{.Source}})</span><input data-source="{{.Source}}" data-key="{{.Key}}"></label>
+{{- end}}
+{{- end}}
+<button type="submit">Try it</button>
+<pre class="result"></pre>
+</form>
+{{- end}}
+</section>
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Types}}
+<h2>Types</h2>
+{{- range .Types}}
+<section class="type" id="{{.Anchor}}">
+<h3>{{if .Deprecated}}<s>{{.Name}}</s>{{else}}{{.Name}}{{end}}</h3>
+{{- if .Deprecated}}
+<p><strong>Deprecated:</strong> {{.Deprecated}}</p>
+{{- end}}
+{{- if .Comment}}
+<p>{{.Comment}}</p>
+{{- end}}
+<p>{{.Description}}</p>
+{{- if .Fields}}
+<table>
+<tr><th>Name</th><th>Type</th><th>Options</th><th>Description</th><th>Notes</th></tr>
+{{- range .Fields}}
+<tr><td>{{if .Deprecated}}<s>{{.Name}}</s>{{else}}{{.Name}}{{end}}</td><td>{{.Type}}</td><td>{{.Options}}</td><td>{{if .Deprecated}}<strong>Deprecated:</strong> {{.Deprecated}}{{if .Comment}} {{end}}{{end}}{{.Comment}}</td><td>{{if .From}}from {{.From}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Rows}}
+<table>
+<tr>{{range .RowHeader}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+</section>
+{{- end}}
+{{- end}}
+</main>
+{{- if .TryIt}}
+<script>
+function tryIt(form) {
+  var base = document.getElementById("base-url").value.replace(/\/+$/, "");
+  var path = form.getAttribute("data-path");
+  var query = [];
+  var headers = {"Accept": "application/json"};
+  var body = undefined;
+  var credsHeader = document.getElementById("creds-header").value;
+  if (credsHeader) {
+    headers[credsHeader] = document.getElementById("creds-token").value;
+  }
+  var fields = form.querySelectorAll("[data-source]");
+  for (var i = 0; i < fields.length; i++) {
+    var f = fields[i];
+    var source = f.getAttribute("data-source");
+    var key = f.getAttribute("data-key");
+    if (source === "path") {
+      path = path.replace("{" + key + "}", encodeURIComponent(f.value));
+    } else if (f.value === "") {
+      continue;
+    } else if (source === "query") {
+      query.push(encodeURIComponent(key) + "=" + encodeURIComponent(f.value));
+    } else if (source === "header") {
+      headers[key] = f.value;
+    } else if (source === "body") {
+      body = f.value;
+      headers["Content-Type"] = "application/json";
+    }
+  }
+  var url = base + path + (query.length > 0 ? "?" + query.join("&") : "");
+  var result = form.querySelector("pre.result");
+  result.style.display = "block";
+  result.textContent = form.getAttribute("data-method") + " " + url + "\n...";
+  fetch(url, {method: form.getAttribute("data-method"), headers: headers, body: body}).then(function (response) {
+    return response.text().then(function (text) {
+      try {
+        text = JSON.stringify(JSON.parse(text), null, 2);
+      } catch (e) {
+      }
+      result.textContent = form.getAttribute("data-method") + " " + url + "\n" + response.status + " " + response.statusText + "\n\n" + text;
+    });
+  }).catch(function (err) {
+    result.textContent = form.getAttribute("data-method") + " " + url + "\n" + err;
+  });
+  return false;
+}
+</script>
+{{- end}}
+</body>
+</html>
+`
@@ -21,14 +21,21 @@ import (
 func main() {
 	pOutdir := flag.String("o", ".", "Output directory")
 	flag.String("s", "", "RDL source file")
+	pSplit := flag.String("split", "false", "Generate an index page, and a page per resource group and per type")
 	flag.Parse()
 	data, err := ioutil.ReadAll(os.Stdin)
 	if err == nil {
 		var schema rdl.Schema
 		err = json.Unmarshal(data, &schema)
 		if err == nil {
-			ExportToMarkdown(&schema, *pOutdir)
-			os.Exit(0)
+			if *pSplit == "true" {
+				err = ExportToMarkdownPages(&schema, *pOutdir)
+			} else {
+				err = ExportToMarkdown(&schema, *pOutdir)
+			}
+			if err == nil {
+				os.Exit(0)
+			}
 		}
 	}
 	fmt.Fprintf(os.Stderr, "*** %v\n", err)
@@ -173,6 +180,38 @@ func ExportToMarkdown(schema *rdl.Schema, outdir string) error {
 		defer file.Close()
 	}
 	registry := rdl.NewTypeRegistry(schema)
+	formatSchemaHeader(out, schema)
+
+	if schema.Resources != nil {
+		fmt.Fprintf(out, "\n## Resources\n")
+		groups := groupResources(schema.Resources)
+		groupNames := make([]string, 0, len(groups))
+		for group := range groups {
+			groupNames = append(groupNames, group)
+		}
+		sort.Strings(groupNames)
+		for _, group := range groupNames {
+			lstRez := groups[group]
+			fmt.Fprintf(out, "\n### [%s](#%s)\n", group, group)
+			//too much? formatType(out, schema, schema.FindType(group))
+			for _, rez := range lstRez {
+				//ideally, sort by method here to be consistent
+				formatResource(out, registry, rez)
+			}
+		}
+	}
+
+	if len(schema.Types) > 0 {
+		fmt.Fprintf(out, "\n## Types\n")
+		for _, typeDef := range schema.Types {
+			formatType(out, registry, typeDef)
+		}
+	}
+	out.Flush()
+	return nil
+}
+
+func formatSchemaHeader(out io.Writer, schema *rdl.Schema) {
 	category := "schema"
 	if schema.Resources != nil {
 		category = "API"
@@ -199,30 +238,128 @@ func ExportToMarkdown(schema *rdl.Schema, outdir string) error {
 		fmt.Fprintf(out, "This %s has the following attributes:\n\n", category)
 		formatTable(out, []string{"Attribute", "Value"}, rows)
 	}
+}
 
-	if schema.Resources != nil {
-		fmt.Fprintf(out, "\n## Resources\n")
-		groups := groupResources(schema.Resources)
-		for group, lstRez := range groups {
-			fmt.Fprintf(out, "\n### [%s](#%s)\n", group, group)
-			//too much? formatType(out, schema, schema.FindType(group))
+//splitTypeDir is the path from the page being written to the type pages, when the output is
+//split into pages, and nil when it is a single document.
+var splitTypeDir *string
+
+//typeLink returns the link to the definition of the type
+func typeLink(name rdl.TypeName) string {
+	if splitTypeDir != nil {
+		return *splitTypeDir + string(name) + ".md"
+	}
+	return "#" + strings.ToLower(string(name))
+}
+
+//fromType returns the note for an option or field inherited from the type
+func fromType(name rdl.TypeName) string {
+	return "[from [" + string(name) + "](" + typeLink(name) + ")]"
+}
+
+//ExportToMarkdownPages exports a markdown rendering of the schema as a set of pages: an index.md,
+//a page per group of resources (by resource type) in resources/, and a page per type in types/.
+//Types are linked to their pages throughout.
+func ExportToMarkdownPages(schema *rdl.Schema, outdir string) error {
+	registry := rdl.NewTypeRegistry(schema)
+	writePage := func(dir string, name string, typeDir string, body func(out io.Writer)) error {
+		dir = filepath.Join(outdir, dir)
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return err
+		}
+		out, file, _, err := outputWriter(dir, name, ".md")
+		if err != nil {
+			return err
+		}
+		splitTypeDir = &typeDir
+		body(out)
+		err = out.Flush()
+		file.Close()
+		return err
+	}
+	groups := groupResources(schema.Resources)
+	groupNames := make([]string, 0, len(groups))
+	for group := range groups {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+	err := writePage("", "index", "types/", func(out io.Writer) {
+		formatSchemaHeader(out, schema)
+		if len(groupNames) > 0 {
+			fmt.Fprintf(out, "\n## Resources\n\n")
+			for _, group := range groupNames {
+				fmt.Fprintf(out, "- <a name=\"%s-resources\"></a>[%s](resources/%s.md)\n", strings.ToLower(group), group, group)
+				for _, rez := range groups[group] {
+					fmt.Fprintf(out, "    - [%s](resources/%s.md#%s)\n", struck(strings.ToUpper(rez.Method)+" "+rez.Path, rez.Annotations), group, resourceAnchor(rez))
+				}
+			}
+		}
+		if len(schema.Types) > 0 {
+			fmt.Fprintf(out, "\n## Types\n\n")
+			for _, typeDef := range schema.Types {
+				tName, _, tComment := rdl.TypeInfo(typeDef)
+				anchor := strings.ToLower(string(tName))
+				label := struck(string(tName), typeAnnotations(typeDef))
+				if tComment != "" {
+					fmt.Fprintf(out, "- <a name=\"%s\"></a>[%s](types/%s.md) - %s\n", anchor, label, tName, tComment)
+				} else {
+					fmt.Fprintf(out, "- <a name=\"%s\"></a>[%s](types/%s.md)\n", anchor, label, tName)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, group := range groupNames {
+		lstRez := groups[group]
+		err = writePage("resources", group, "../types/", func(out io.Writer) {
+			fmt.Fprintf(out, "# %s Resources\n\n", group)
+			fmt.Fprintf(out, "[Index](../index.md)\n")
+			if registry.FindType(rdl.TypeRef(group)) != nil {
+				fmt.Fprintf(out, "\nThe resources of type [%s](%s).\n", group, typeLink(rdl.TypeName(group)))
+			}
 			for _, rez := range lstRez {
-				//ideally, sort by method here to be consistent
+				fmt.Fprintf(out, "\n<a name=\"%s\"></a>\n", resourceAnchor(rez))
 				formatResource(out, registry, rez)
 			}
+		})
+		if err != nil {
+			return err
 		}
 	}
-
-	if len(schema.Types) > 0 {
-		fmt.Fprintf(out, "\n## Types\n")
-		for _, typeDef := range schema.Types {
+	for _, typeDef := range schema.Types {
+		tName, _, _ := rdl.TypeInfo(typeDef)
+		err = writePage("types", string(tName), "", func(out io.Writer) {
+			fmt.Fprintf(out, "[Index](../index.md)\n")
 			formatType(out, registry, typeDef)
+		})
+		if err != nil {
+			return err
 		}
 	}
-	out.Flush()
 	return nil
 }
 
+//resourceAnchor returns the anchor of the resource on its group's page, i.e. get-contacts-name
+func resourceAnchor(rez *rdl.Resource) string {
+	anchor := strings.ToLower(rez.Method)
+	word := false
+	for _, c := range strings.ToLower(rez.Path) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			if !word {
+				anchor += "-"
+			}
+			anchor += string(c)
+			word = true
+		} else {
+			word = false
+		}
+	}
+	return anchor
+}
+
 func groupResources(resources []*rdl.Resource) map[string][]*rdl.Resource {
 	groups := map[string][]*rdl.Resource{}
 	for _, rez := range resources {
@@ -237,11 +374,69 @@ func groupResources(resources []*rdl.Resource) map[string][]*rdl.Resource {
 	return groups
 }
 
+// typeAnnotations returns the extended annotations of the type, whatever its variant.
+func typeAnnotations(t *rdl.Type) map[rdl.ExtendedAnnotation]string {
+	switch t.Variant {
+	case rdl.TypeVariantAliasTypeDef:
+		return t.AliasTypeDef.Annotations
+	case rdl.TypeVariantStringTypeDef:
+		return t.StringTypeDef.Annotations
+	case rdl.TypeVariantNumberTypeDef:
+		return t.NumberTypeDef.Annotations
+	case rdl.TypeVariantArrayTypeDef:
+		return t.ArrayTypeDef.Annotations
+	case rdl.TypeVariantMapTypeDef:
+		return t.MapTypeDef.Annotations
+	case rdl.TypeVariantStructTypeDef:
+		return t.StructTypeDef.Annotations
+	case rdl.TypeVariantEnumTypeDef:
+		return t.EnumTypeDef.Annotations
+	case rdl.TypeVariantUnionTypeDef:
+		return t.UnionTypeDef.Annotations
+	case rdl.TypeVariantBytesTypeDef:
+		return t.BytesTypeDef.Annotations
+	}
+	return nil
+}
+
+// deprecation returns the reason the definition is deprecated for, if it is annotated with
+// x_deprecated, and whether it is.
+func deprecation(annotations map[rdl.ExtendedAnnotation]string) (string, bool) {
+	reason, ok := annotations["x_deprecated"]
+	if !ok {
+		return "", false
+	}
+	if reason = strings.TrimSpace(reason); reason == "" {
+		reason = "No longer to be used."
+	}
+	return reason, true
+}
+
+// struck returns the name of the definition, struck through if it is deprecated.
+func struck(name string, annotations map[rdl.ExtendedAnnotation]string) string {
+	if _, ok := deprecation(annotations); ok {
+		return "~~" + name + "~~"
+	}
+	return name
+}
+
+// deprecatedComment returns the comment of the definition, preceded by the reason it is
+// deprecated for, if it is.
+func deprecatedComment(comment string, annotations map[rdl.ExtendedAnnotation]string) string {
+	if reason, ok := deprecation(annotations); ok {
+		if comment != "" && !strings.HasSuffix(reason, ".") {
+			reason += "."
+		}
+		return strings.TrimSpace("**Deprecated:** " + reason + " " + comment)
+	}
+	return comment
+}
+
 func formatType(out io.Writer, registry rdl.TypeRegistry, typeDef *rdl.Type) {
 	tName, _, tComment := rdl.TypeInfo(typeDef)
-	fmt.Fprintf(out, "\n### %s\n", tName)
-	if tComment != "" {
-		fmt.Fprintf(out, "%s", formatBlock(tComment, 0, 80, ""))
+	fmt.Fprintf(out, "\n### %s\n", struck(string(tName), typeAnnotations(typeDef)))
+	if comment := deprecatedComment(tComment, typeAnnotations(typeDef)); comment != "" {
+		fmt.Fprintf(out, "%s", formatBlock(comment, 0, 80, ""))
 	}
 	types := typeStack(registry, typeDef)
 	name := string(tName)
@@ -292,7 +487,7 @@ func annotate(registry rdl.TypeRegistry, typename rdl.TypeRef) string {
 	if t != nil {
 		tName, tType, _ := rdl.TypeInfo(t)
 		if tType != rdl.TypeRef(tName) {
-			return "[" + string(typename) + "](#" + strings.ToLower(string(typename)) + ")"
+			return "[" + string(typename) + "](" + typeLink(rdl.TypeName(typename)) + ")"
 		}
 	}
 	return string(typename)
@@ -306,7 +501,7 @@ func formatStructType(out io.Writer, registry rdl.TypeRegistry, types []*rdl.Typ
 		case rdl.TypeVariantStructTypeDef:
 			t := types[i].StructTypeDef
 			for _, f := range t.Fields {
-				fn := f.Name
+				fn := struck(string(f.Name), f.Annotations)
 				ft := annotate(registry, f.Type)
 				if f.Keys != "" {
 					ft = ft + "&lt;" + annotate(registry, f.Keys) + "," + annotate(registry, f.Items) + "&gt;"
@@ -327,13 +522,10 @@ func formatStructType(out io.Writer, registry rdl.TypeRegistry, types []*rdl.Typ
 						fo += ", default=" + s
 					}
 				}
-				fc := ""
-				if f.Comment != "" {
-					fc += f.Comment
-				}
+				fc := deprecatedComment(f.Comment, f.Annotations)
 				ff := ""
 				if t != topType {
-					ff = "[from [" + string(t.Name) + "](#" + strings.ToLower(string(t.Name)) + ")]"
+					ff = fromType(t.Name)
 				}
 				row := []string{string(fn), ft, fo, fc, ff}
 				rows = append(rows, row)
@@ -370,7 +562,7 @@ func formatArrayType(out io.Writer, registry rdl.TypeRegistry, name string, type
 		t := types[i].ArrayTypeDef
 		if t != nil {
 			if t != topType {
-				c = "[from [" + string(t.Name) + "](#" + strings.ToLower(string(t.Name)) + ")]"
+				c = fromType(t.Name)
 			}
 			if t.Size != nil {
 				size = &[]string{"minSize", fmt.Sprintf("%d", *t.Size), c}
@@ -410,7 +602,7 @@ func formatMapType(out io.Writer, registry rdl.TypeRegistry, name string, types
 		if t != nil {
 			c := ""
 			if t != topType {
-				c = "[from [" + string(t.Name) + "](#" + strings.ToLower(string(t.Name)) + ")]"
+				c = fromType(t.Name)
 			}
 			if t.Size != nil {
 				size = &[]string{"minSize", fmt.Sprintf("%d", *t.Size), c}
@@ -453,7 +645,7 @@ func formatStringType(out io.Writer, registry rdl.TypeRegistry, name string, typ
 			t := types[i].StringTypeDef
 			c := ""
 			if t != topType {
-				c = "[from [" + string(t.Name) + "](#" + strings.ToLower(string(t.Name)) + ")]"
+				c = fromType(t.Name)
 			}
 			if t.Pattern != "" {
 				pattern = &[]string{"pattern", "`\"" + t.Pattern + "`\"", c}
@@ -500,13 +692,13 @@ func formatNumberType(out io.Writer, registry rdl.TypeRegistry, name string, typ
 			t := types[i].NumberTypeDef
 			c := ""
 			if t != topType {
-				c = "[from [" + string(t.Name) + "](#" + strings.ToLower(string(t.Name)) + ")]"
+				c = fromType(t.Name)
 			}
 			if t.Min != nil {
-				minVal = &[]string{"min", fmt.Sprintf("%v", *t.Min), c}
+				minVal = &[]string{"min", numberString(t.Min), c}
 			}
 			if t.Max != nil {
-				maxVal = &[]string{"max", fmt.Sprintf("%v", *t.Max), c}
+				maxVal = &[]string{"max", numberString(t.Max), c}
 			}
 		}
 	}
@@ -539,7 +731,7 @@ func formatBytesType(out io.Writer, registry rdl.TypeRegistry, name string, type
 			t := types[i].BytesTypeDef
 			c := ""
 			if t != topType {
-				c = "[from [" + string(t.Name) + "](#" + strings.ToLower(string(t.Name)) + ")]"
+				c = fromType(t.Name)
 			}
 			if t.MinSize != nil {
 				minSize = &[]string{"minSize", fmt.Sprintf("%d", *t.MinSize), c}
@@ -567,14 +759,32 @@ func formatEnumType(out io.Writer, registry rdl.TypeRegistry, typeDef *rdl.EnumT
 	fmt.Fprintf(out, "`%s` is an `Enum` of the following values:\n\n", typeDef.Name)
 	var rows [][]string
 	for _, elem := range typeDef.Elements {
-		vn := string(elem.Symbol)
-		s := elem.Comment
+		vn := struck(string(elem.Symbol), elem.Annotations)
+		s := deprecatedComment(elem.Comment, elem.Annotations)
 		row := []string{vn, s}
 		rows = append(rows, row)
 	}
 	formatTable(out, []string{"Value", "Description"}, rows)
 }
 
+func numberString(n *rdl.Number) string {
+	switch n.Variant {
+	case rdl.NumberVariantInt8:
+		return fmt.Sprint(*n.Int8)
+	case rdl.NumberVariantInt16:
+		return fmt.Sprint(*n.Int16)
+	case rdl.NumberVariantInt32:
+		return fmt.Sprint(*n.Int32)
+	case rdl.NumberVariantInt64:
+		return fmt.Sprint(*n.Int64)
+	case rdl.NumberVariantFloat32:
+		return fmt.Sprint(*n.Float32)
+	case rdl.NumberVariantFloat64:
+		return fmt.Sprint(*n.Float64)
+	}
+	return ""
+}
+
 func formatTable(out io.Writer, header []string, rows [][]string) {
 	columns := len(header)
 	widths := make([]int, columns)
@@ -610,9 +820,9 @@ func formatTable(out io.Writer, header []string, rows [][]string) {
 }
 
 func formatResource(out io.Writer, registry rdl.TypeRegistry, rez *rdl.Resource) {
-	fmt.Fprintf(out, "\n#### %s %s\n", strings.ToUpper(rez.Method), rez.Path)
-	if rez.Comment != "" {
-		fmt.Fprintf(out, "%s", formatBlock(rez.Comment, 0, 80, ""))
+	fmt.Fprintf(out, "\n#### %s\n", struck(strings.ToUpper(rez.Method)+" "+rez.Path, rez.Annotations))
+	if comment := deprecatedComment(rez.Comment, rez.Annotations); comment != "" {
+		fmt.Fprintf(out, "%s", formatBlock(comment, 0, 80, ""))
 	}
 	if len(rez.Inputs) > 0 {
 		var rows [][]string
@@ -699,7 +909,7 @@ func formatResource(out io.Writer, registry rdl.TypeRegistry, rez *rdl.Resource)
 		}
 		results = append(results, []string{rdl.StatusCode(e) + " " + rdl.StatusMessage(e), s})
 	} else {
-		results = append(results, []string{"200 " + rdl.StatusMessage("OK"), string(rez.Type)})
+		results = append(results, []string{"200 " + rdl.StatusMessage("OK"), annotate(registry, rez.Type)})
 	}
 	if len(rez.Alternatives) > 0 {
 		for _, v := range rez.Alternatives {
@@ -0,0 +1,631 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate a runnable mock HTTP server (in Go or Java) for the resources in an RDL schema.
+// Every resource answers with an example payload built from the schema types, using the
+// status code it is expected to return.
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	pLang := flag.String("lang", "go", "The language of the generated server: go or java")
+	pBasePath := flag.String("b", "", "Base path of the resources")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			err = ExportToMockServer(&schema, *pOutdir, *pLang, *pBasePath)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func capitalize(text string) string {
+	return strings.ToUpper(text[0:1]) + text[1:]
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+func numberValue(n *rdl.Number) interface{} {
+	switch {
+	case n.Int8 != nil:
+		return *n.Int8
+	case n.Int16 != nil:
+		return *n.Int16
+	case n.Int32 != nil:
+		return *n.Int32
+	case n.Int64 != nil:
+		return *n.Int64
+	case n.Float32 != nil:
+		return *n.Float32
+	case n.Float64 != nil:
+		return *n.Float64
+	}
+	return 0
+}
+
+// mockResource is the canned response of one resource
+type mockResource struct {
+	Method   string
+	Path     string
+	Segments []string
+	Status   string
+	Headers  [][2]string
+	Body     string
+}
+
+// ExportToMockServer generates the mock server for the schema's resources, in the given language.
+func ExportToMockServer(schema *rdl.Schema, outdir string, lang string, basePath string) error {
+	if len(schema.Resources) == 0 {
+		return fmt.Errorf("The schema '%s' defines no resources to mock", schema.Name)
+	}
+	mocks, err := mockResources(schema)
+	if err != nil {
+		return err
+	}
+	name := "anonymous"
+	if schema.Name != "" {
+		name = string(schema.Name)
+	}
+	var fileName, ext, tmpl string
+	switch lang {
+	case "go":
+		fileName, ext, tmpl = "mock_server", ".go", goMockServerTemplate
+	case "java":
+		fileName, ext, tmpl = capitalize(name)+"MockServer", ".java", javaMockServerTemplate
+	default:
+		return fmt.Errorf("Unsupported mock server language '%s' (expected go or java)", lang)
+	}
+	out, file, _, err := outputWriter(outdir, fileName, ext)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	err = generateMockServer(out, tmpl, name, strings.TrimSuffix(basePath, "/"), mocks)
+	out.Flush()
+	return err
+}
+
+func generateMockServer(out io.Writer, tmpl string, name string, basePath string, mocks []*mockResource) error {
+	funcMap := template.FuncMap{
+		"cName":      func() string { return capitalize(name) },
+		"name":       func() string { return name },
+		"basePath":   func() string { return basePath },
+		"goString":   func(s string) string { return fmt.Sprintf("%q", s) },
+		"javaString": javaString,
+		"javaSegments": func(segments []string) string {
+			quoted := make([]string, 0, len(segments))
+			for _, seg := range segments {
+				quoted = append(quoted, javaString(seg))
+			}
+			return "new String[] {" + strings.Join(quoted, ", ") + "}"
+		},
+		"javaHeaders": func(headers [][2]string) string {
+			quoted := make([]string, 0, 2*len(headers))
+			for _, h := range headers {
+				quoted = append(quoted, javaString(h[0]), javaString(h[1]))
+			}
+			return "new String[] {" + strings.Join(quoted, ", ") + "}"
+		},
+	}
+	t := template.Must(template.New("mock").Funcs(funcMap).Parse(tmpl))
+	return t.Execute(out, mocks)
+}
+
+func javaString(s string) string {
+	buf := []rune{'"'}
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c == '\n':
+			buf = append(buf, '\\', 'n')
+		case c == '\r':
+			buf = append(buf, '\\', 'r')
+		case c == '\t':
+			buf = append(buf, '\\', 't')
+		case c < ' ':
+			buf = append(buf, []rune(fmt.Sprintf("\\u%04x", c))...)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(append(buf, '"'))
+}
+
+// mockResources builds the canned responses. Resources whose paths have fewer variables come
+// first, so that a literal path segment wins over a variable one when both match a request.
+func mockResources(schema *rdl.Schema) ([]*mockResource, error) {
+	ex := &exampleBuilder{registry: rdl.NewTypeRegistry(schema), active: make(map[rdl.TypeRef]bool)}
+	var mocks []*mockResource
+	for _, r := range schema.Resources {
+		path := r.Path
+		if i := strings.Index(path, "?"); i >= 0 {
+			path = path[:i]
+		}
+		var segments []string
+		for _, seg := range strings.Split(strings.Trim(path, "/"), "/") {
+			if seg != "" {
+				segments = append(segments, seg)
+			}
+		}
+		expected := r.Expected
+		if expected == "" {
+			expected = "OK"
+		}
+		status := rdl.StatusCode(expected)
+		if _, err := strconv.Atoi(status); err != nil {
+			return nil, fmt.Errorf("Unknown expected status '%s' for resource %s %s", expected, r.Method, r.Path)
+		}
+		mock := &mockResource{Method: strings.ToUpper(r.Method), Path: path, Segments: segments, Status: status}
+		for _, out := range r.Outputs {
+			mock.Headers = append(mock.Headers, [2]string{out.Header, ex.headerValue(string(out.Name), out.Type)})
+		}
+		if status != "204" && status != "304" {
+			j, err := json.Marshal(ex.value(string(r.Type), r.Type, "", ""))
+			if err != nil {
+				return nil, err
+			}
+			mock.Body = string(j)
+		}
+		mocks = append(mocks, mock)
+	}
+	variables := func(m *mockResource) int {
+		n := 0
+		for _, seg := range m.Segments {
+			if strings.HasPrefix(seg, "{") {
+				n++
+			}
+		}
+		return n
+	}
+	sort.SliceStable(mocks, func(i, j int) bool { return variables(mocks[i]) < variables(mocks[j]) })
+	return mocks, nil
+}
+
+// exampleBuilder makes example values for schema types: field defaults where the schema has
+// them, otherwise the smallest value the type's constraints allow.
+type exampleBuilder struct {
+	registry rdl.TypeRegistry
+	active   map[rdl.TypeRef]bool //the struct types being built, to stop on recursive types
+}
+
+func (ex *exampleBuilder) headerValue(name string, tref rdl.TypeRef) string {
+	switch v := ex.value(name, tref, "", "").(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		j, _ := json.Marshal(v)
+		return string(j)
+	}
+}
+
+func (ex *exampleBuilder) value(name string, tref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef) interface{} {
+	t := ex.registry.FindType(tref)
+	if t == nil {
+		return nil
+	}
+	switch ex.registry.BaseType(t) {
+	case rdl.BaseTypeBool:
+		return false
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		if t.Variant == rdl.TypeVariantNumberTypeDef {
+			if nt := t.NumberTypeDef; nt.Min != nil {
+				return numberValue(nt.Min)
+			} else if nt.Max != nil {
+				return numberValue(nt.Max)
+			}
+		}
+		return 0
+	case rdl.BaseTypeString, rdl.BaseTypeSymbol:
+		s := name
+		if t.Variant == rdl.TypeVariantStringTypeDef {
+			st := t.StringTypeDef
+			if len(st.Values) > 0 {
+				return st.Values[0]
+			}
+			if st.MaxSize != nil && int(*st.MaxSize) < len(s) {
+				s = s[:*st.MaxSize]
+			}
+			if st.MinSize != nil && int(*st.MinSize) > len(s) {
+				s += strings.Repeat("x", int(*st.MinSize)-len(s))
+			}
+		}
+		return s
+	case rdl.BaseTypeTimestamp:
+		return "2015-01-01T00:00:00.000Z"
+	case rdl.BaseTypeUUID:
+		return "00000000-0000-0000-0000-000000000000"
+	case rdl.BaseTypeBytes:
+		return ""
+	case rdl.BaseTypeEnum:
+		if t.Variant == rdl.TypeVariantEnumTypeDef && len(t.EnumTypeDef.Elements) > 0 {
+			return enumWireName(t.EnumTypeDef.Elements[0])
+		}
+		return ""
+	case rdl.BaseTypeArray:
+		if items == "" && t.Variant == rdl.TypeVariantArrayTypeDef {
+			items = t.ArrayTypeDef.Items
+		}
+		list := make([]interface{}, 0, 1)
+		if items != "" && items != "Any" {
+			if item := ex.value(name, items, "", ""); item != nil {
+				list = append(list, item)
+			}
+		}
+		return list
+	case rdl.BaseTypeMap:
+		if t.Variant == rdl.TypeVariantMapTypeDef {
+			if items == "" {
+				items = t.MapTypeDef.Items
+			}
+			if keys == "" {
+				keys = t.MapTypeDef.Keys
+			}
+		}
+		m := make(map[string]interface{})
+		if items != "" && items != "Any" {
+			key := "key"
+			if k, ok := ex.value("key", keys, "", "").(string); ok && k != "" {
+				key = k
+			}
+			if item := ex.value(name, items, "", ""); item != nil {
+				m[key] = item
+			}
+		}
+		return m
+	case rdl.BaseTypeStruct:
+		if ex.active[tref] {
+			return nil
+		}
+		ex.active[tref] = true
+		defer delete(ex.active, tref)
+		m := make(map[string]interface{})
+		for _, f := range flattenedFields(ex.registry, t) {
+			if f.Default != nil {
+				m[string(f.Name)] = enumWireDefault(ex.registry, f.Type, f.Default)
+			} else if v := ex.value(string(f.Name), f.Type, f.Items, f.Keys); v != nil {
+				m[string(f.Name)] = v
+			} else if !f.Optional {
+				m[string(f.Name)] = map[string]interface{}{}
+			}
+		}
+		return m
+	case rdl.BaseTypeUnion:
+		if t.Variant == rdl.TypeVariantUnionTypeDef {
+			for _, v := range t.UnionTypeDef.Variants {
+				if val := ex.value(name, v, "", ""); val != nil {
+					return val
+				}
+			}
+		}
+		return nil
+	}
+	return map[string]interface{}{}
+}
+
+const goMockServerTemplate = `//
+// This file generated by rdl-gen-mock-server. Do not modify!
+//
+
+// A mock server for the {{name}} resources. Every resource answers with an example payload
+// built from the schema. Run it with "go run mock_server.go [-addr :4080] [-b basepath]".
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type mockResource struct {
+	method   string
+	segments []string
+	status   int
+	headers  [][2]string
+	body     string
+}
+
+var resources = []*mockResource{
+{{- range .}}
+	{
+		method:   {{goString .Method}},
+		segments: []string{ {{- range $i, $s := .Segments}}{{if $i}}, {{end}}{{goString $s}}{{end -}} },
+		status:   {{.Status}},
+		headers:  [][2]string{ {{- range $i, $h := .Headers}}{{if $i}}, {{end}}{ {{- goString (index $h 0)}}, {{goString (index $h 1) -}} }{{end -}} },
+		body:     {{goString .Body}},
+	},
+{{- end}}
+}
+
+func (r *mockResource) matches(segments []string) bool {
+	if len(segments) != len(r.segments) {
+		return false
+	}
+	for i, seg := range r.segments {
+		if !strings.HasPrefix(seg, "{") && seg != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write([]byte("{\"code\":" + strconv.Itoa(code) + ",\"message\":\"" + http.StatusText(code) + "\"}"))
+}
+
+func main() {
+	addr := flag.String("addr", ":4080", "the address to listen on")
+	base := flag.String("b", {{goString basePath}}, "the base path of the resources")
+	flag.Parse()
+	http.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		path := req.URL.Path
+		if !strings.HasPrefix(path, *base) {
+			writeError(w, http.StatusNotFound)
+			return
+		}
+		var segments []string
+		for _, seg := range strings.Split(strings.Trim(path[len(*base):], "/"), "/") {
+			if seg != "" {
+				segments = append(segments, seg)
+			}
+		}
+		found := false
+		for _, r := range resources {
+			if !r.matches(segments) {
+				continue
+			}
+			found = true
+			if r.method != req.Method {
+				continue
+			}
+			for _, h := range r.headers {
+				w.Header().Set(h[0], h[1])
+			}
+			if r.body != "" {
+				w.Header().Set("Content-Type", "application/json")
+			}
+			w.WriteHeader(r.status)
+			w.Write([]byte(r.body))
+			log.Printf("%s %s -> %d\n", req.Method, req.URL.Path, r.status)
+			return
+		}
+		if found {
+			writeError(w, http.StatusMethodNotAllowed)
+		} else {
+			writeError(w, http.StatusNotFound)
+		}
+	})
+	log.Printf("{{name}} mock server listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+`
+
+const javaMockServerTemplate = `//
+// This file generated by rdl-gen-mock-server. Do not modify!
+//
+
+import com.sun.net.httpserver.HttpExchange;
+import com.sun.net.httpserver.HttpServer;
+import java.io.IOException;
+import java.io.OutputStream;
+import java.net.InetSocketAddress;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.List;
+
+//
+// A mock server for the {{name}} resources. Every resource answers with an example payload
+// built from the schema. Run it with "java {{cName}}MockServer.java [port]".
+//
+public class {{cName}}MockServer {
+
+    static final String BASE_PATH = {{javaString basePath}};
+
+    static final class MockResource {
+        final String method;
+        final String[] segments;
+        final int status;
+        final String[] headers;
+        final String body;
+
+        MockResource(String method, String[] segments, int status, String[] headers, String body) {
+            this.method = method;
+            this.segments = segments;
+            this.status = status;
+            this.headers = headers;
+            this.body = body;
+        }
+
+        boolean matches(List<String> path) {
+            if (path.size() != segments.length) {
+                return false;
+            }
+            for (int i = 0; i < segments.length; i++) {
+                if (!segments[i].startsWith("{") && !segments[i].equals(path.get(i))) {
+                    return false;
+                }
+            }
+            return true;
+        }
+    }
+
+    static final MockResource[] RESOURCES = {
+{{- range .}}
+        new MockResource({{javaString .Method}}, {{javaSegments .Segments}}, {{.Status}}, {{javaHeaders .Headers}},
+            {{javaString .Body}}),{{end}}
+    };
+
+    public static void main(String[] args) throws IOException {
+        int port = args.length > 0 ? Integer.parseInt(args[0]) : 4080;
+        HttpServer server = HttpServer.create(new InetSocketAddress(port), 0);
+        server.createContext("/", {{cName}}MockServer::handle);
+        server.start();
+        System.out.println("{{name}} mock server listening on port " + port);
+    }
+
+    static void handle(HttpExchange exchange) throws IOException {
+        String path = exchange.getRequestURI().getPath();
+        if (!path.startsWith(BASE_PATH)) {
+            respond(exchange, 404, null, error(404, "Not Found"));
+            return;
+        }
+        List<String> segments = new ArrayList<String>();
+        for (String seg : path.substring(BASE_PATH.length()).split("/")) {
+            if (!seg.isEmpty()) {
+                segments.add(seg);
+            }
+        }
+        boolean found = false;
+        for (MockResource r : RESOURCES) {
+            if (!r.matches(segments)) {
+                continue;
+            }
+            found = true;
+            if (r.method.equals(exchange.getRequestMethod())) {
+                respond(exchange, r.status, r.headers, r.body);
+                System.out.println(exchange.getRequestMethod() + " " + path + " -> " + r.status);
+                return;
+            }
+        }
+        if (found) {
+            respond(exchange, 405, null, error(405, "Method Not Allowed"));
+        } else {
+            respond(exchange, 404, null, error(404, "Not Found"));
+        }
+    }
+
+    static String error(int code, String message) {
+        return "{\"code\":" + code + ",\"message\":\"" + message + "\"}";
+    }
+
+    static void respond(HttpExchange exchange, int status, String[] headers, String body) throws IOException {
+        if (headers != null) {
+            for (int i = 0; i + 1 < headers.length; i += 2) {
+                exchange.getResponseHeaders().set(headers[i], headers[i + 1]);
+            }
+        }
+        byte[] bytes = body.getBytes(StandardCharsets.UTF_8);
+        if (bytes.length == 0) {
+            exchange.sendResponseHeaders(status, -1);
+        } else {
+            exchange.getResponseHeaders().set("Content-Type", "application/json");
+            exchange.sendResponseHeaders(status, bytes.length);
+            try (OutputStream os = exchange.getResponseBody()) {
+                os.write(bytes);
+            }
+        }
+        exchange.close();
+    }
+}
+`
+
+// enumWireName returns the string the enum element is written as: its x_wire annotation, or else
+// its symbol.
+func enumWireName(el *rdl.EnumElementDef) string {
+	if wire := strings.TrimSpace(el.Annotations["x_wire"]); wire != "" {
+		return wire
+	}
+	return string(el.Symbol)
+}
+
+// enumWireDefault returns the default value as it is written: the wire name of the element, if
+// the type is an enum.
+func enumWireDefault(reg rdl.TypeRegistry, tref rdl.TypeRef, def interface{}) interface{} {
+	if def == nil {
+		return nil
+	}
+	for t := reg.FindType(tref); t != nil; {
+		if t.Variant == rdl.TypeVariantEnumTypeDef {
+			for _, el := range t.EnumTypeDef.Elements {
+				if string(el.Symbol) == fmt.Sprint(def) {
+					return enumWireName(el)
+				}
+			}
+			break
+		}
+		_, super, _ := rdl.TypeInfo(t)
+		if super == tref {
+			break
+		}
+		tref = super
+		t = reg.FindType(tref)
+	}
+	return def
+}
@@ -0,0 +1,751 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate Python 3 model classes (dataclasses, or pydantic models) for the types in an RDL schema
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	pPydantic := flag.String("pydantic", "false", "Generate pydantic models instead of dataclasses")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			pydantic, _ := strconv.ParseBool(*pPydantic)
+			err = ExportToPythonModel(&schema, *pOutdir, pydantic)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func uncapitalize(text string) string {
+	return strings.ToLower(text[0:1]) + text[1:]
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+func numberString(n *rdl.Number) string {
+	switch n.Variant {
+	case rdl.NumberVariantInt8:
+		return fmt.Sprintf("%d", *n.Int8)
+	case rdl.NumberVariantInt16:
+		return fmt.Sprintf("%d", *n.Int16)
+	case rdl.NumberVariantInt32:
+		return fmt.Sprintf("%d", *n.Int32)
+	case rdl.NumberVariantInt64:
+		return fmt.Sprintf("%d", *n.Int64)
+	case rdl.NumberVariantFloat32:
+		return fmt.Sprintf("%g", *n.Float32)
+	case rdl.NumberVariantFloat64:
+		return fmt.Sprintf("%g", *n.Float64)
+	}
+	return "None"
+}
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true, "async": true,
+	"await": true, "break": true, "class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true, "global": true, "if": true,
+	"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+	"pass": true, "raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+func pythonName(name string) string {
+	if pythonKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+const pythonDataclassPrelude = `from __future__ import annotations
+
+import dataclasses
+import re
+from dataclasses import dataclass, field
+from enum import Enum
+from typing import Any, Dict, List, Optional
+
+
+def _required(d: Dict[str, Any], key: str, owner: str) -> Any:
+    if key not in d or d[key] is None:
+        raise ValueError("%s: missing required field '%s'" % (owner, key))
+    return d[key]
+
+
+def _convert(value: Any, fn: Any) -> Any:
+    return None if value is None else fn(value)
+
+
+def _check_required(context: str, value: Any) -> None:
+    if value is None:
+        raise ValueError("%s: missing required value" % context)
+
+
+def _check_string(context: str, value: Optional[str], pattern: Optional[str] = None, values: Optional[List[str]] = None,
+                  min_size: Optional[int] = None, max_size: Optional[int] = None) -> None:
+    if value is None:
+        return
+    if pattern is not None and re.fullmatch(pattern, value) is None:
+        raise ValueError("%s: value %r does not match pattern %r" % (context, value, pattern))
+    if values is not None and value not in values:
+        raise ValueError("%s: value %r is not one of %r" % (context, value, values))
+    if min_size is not None and len(value) < min_size:
+        raise ValueError("%s: value %r is shorter than %d" % (context, value, min_size))
+    if max_size is not None and len(value) > max_size:
+        raise ValueError("%s: value %r is longer than %d" % (context, value, max_size))
+
+
+def _check_number(context: str, value: Any, min: Any = None, max: Any = None) -> None:
+    if value is None:
+        return
+    if min is not None and value < min:
+        raise ValueError("%s: value %r is less than %r" % (context, value, min))
+    if max is not None and value > max:
+        raise ValueError("%s: value %r is greater than %r" % (context, value, max))
+
+
+def _check_size(context: str, value: Any, size: Optional[int] = None, min_size: Optional[int] = None,
+                max_size: Optional[int] = None) -> None:
+    if value is None:
+        return
+    if size is not None and len(value) != size:
+        raise ValueError("%s: size %d is not %d" % (context, len(value), size))
+    if min_size is not None and len(value) < min_size:
+        raise ValueError("%s: size %d is less than %d" % (context, len(value), min_size))
+    if max_size is not None and len(value) > max_size:
+        raise ValueError("%s: size %d is greater than %d" % (context, len(value), max_size))
+
+
+def _to_json(value: Any) -> Any:
+    if dataclasses.is_dataclass(value):
+        result = {}
+        for f in dataclasses.fields(value):
+            v = getattr(value, f.name)
+            if v is not None:
+                result[f.metadata.get("json", f.name)] = _to_json(v)
+        return result
+    if isinstance(value, Enum):
+        return value.value
+    if isinstance(value, list):
+        return [_to_json(v) for v in value]
+    if isinstance(value, dict):
+        return {k: _to_json(v) for k, v in value.items()}
+    return value
+`
+
+const pythonPydanticPrelude = `from __future__ import annotations
+
+from enum import Enum
+from typing import Any, Dict, List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field, model_validator
+`
+
+type pythonModelGenerator struct {
+	registry  rdl.TypeRegistry
+	schema    *rdl.Schema
+	writer    *bufio.Writer
+	err       error
+	pydantic  bool
+	userTypes map[rdl.TypeRef]bool
+}
+
+// ExportToPythonModel generates a Python 3 module with a model class for each type in the schema.
+// By default the classes are dataclasses that validate themselves in __post_init__; with pydantic set,
+// they are pydantic (v2) models with equivalent field constraints.
+func ExportToPythonModel(schema *rdl.Schema, outdir string, pydantic bool) error {
+	out, file, _, err := outputWriter(outdir, string(schema.Name), ".py")
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	gen := &pythonModelGenerator{rdl.NewTypeRegistry(schema), schema, out, nil, pydantic, make(map[rdl.TypeRef]bool)}
+	gen.emit("#\n# This file generated by rdl-gen-python-model. Do not modify!\n#\n")
+	if schema.Comment != "" {
+		gen.emit(fmt.Sprintf("\"\"\"%s\"\"\"\n", schema.Comment))
+	}
+	if pydantic {
+		gen.emit(pythonPydanticPrelude)
+	} else {
+		gen.emit(pythonDataclassPrelude)
+	}
+	for _, t := range schema.Types {
+		tName, _, _ := rdl.TypeInfo(t)
+		if strings.HasPrefix(string(tName), "rdl.") {
+			continue
+		}
+		gen.userTypes[rdl.TypeRef(tName)] = true
+		gen.emitType(t)
+	}
+	out.Flush()
+	return gen.err
+}
+
+func (gen *pythonModelGenerator) emit(s string) {
+	if gen.err == nil {
+		_, err := gen.writer.WriteString(s)
+		if err != nil {
+			gen.err = err
+		}
+	}
+}
+
+func (gen *pythonModelGenerator) emitDocstring(comment string, indent string) {
+	if comment != "" {
+		gen.emit(fmt.Sprintf("%s\"\"\"%s\"\"\"\n", indent, strings.Replace(comment, "\"\"\"", "'''", -1)))
+	}
+}
+
+func (gen *pythonModelGenerator) emitType(t *rdl.Type) {
+	tName, tType, tComment := rdl.TypeInfo(t)
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		gen.emitStruct(t)
+	case rdl.TypeVariantUnionTypeDef:
+		gen.emitUnion(t.UnionTypeDef)
+	case rdl.TypeVariantEnumTypeDef:
+		gen.emitEnum(t.EnumTypeDef)
+	case rdl.TypeVariantArrayTypeDef:
+		gen.emitAlias(tName, gen.pythonType(tType, t.ArrayTypeDef.Items, ""), tComment)
+	case rdl.TypeVariantMapTypeDef:
+		gen.emitAlias(tName, gen.pythonType(tType, t.MapTypeDef.Items, t.MapTypeDef.Keys), tComment)
+	default:
+		gen.emitAlias(tName, gen.pythonType(tType, "", ""), tComment)
+	}
+}
+
+func (gen *pythonModelGenerator) emitAlias(name rdl.TypeName, ptype string, comment string) {
+	gen.emit("\n\n")
+	if comment != "" {
+		gen.emit(fmt.Sprintf("# %s\n", comment))
+	}
+	gen.emit(fmt.Sprintf("%s = %s\n", name, ptype))
+}
+
+// pythonType returns the type hint for the type reference. Named scalar, array and map types refer
+// to the alias emitted for them, everything else maps onto the builtin Python types.
+func (gen *pythonModelGenerator) pythonType(rdlType rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil || t.Variant == 0 {
+		gen.err = fmt.Errorf("Cannot find type '%s'", rdlType)
+		return "Any"
+	}
+	bt := gen.registry.BaseType(t)
+	switch bt {
+	case rdl.BaseTypeAny:
+		return "Any"
+	case rdl.BaseTypeStruct:
+		if rdlType == "Struct" || strings.HasPrefix(string(rdlType), "rdl.") {
+			return "Dict[str, Any]"
+		}
+		return string(rdlType)
+	case rdl.BaseTypeUnion, rdl.BaseTypeEnum:
+		return string(rdlType)
+	}
+	if gen.userTypes[rdlType] {
+		return string(rdlType)
+	}
+	switch bt {
+	case rdl.BaseTypeBool:
+		return "bool"
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+		return "int"
+	case rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return "float"
+	case rdl.BaseTypeArray:
+		if t.Variant == rdl.TypeVariantArrayTypeDef && rdl.TypeRef(t.ArrayTypeDef.Name) == rdlType {
+			items = t.ArrayTypeDef.Items
+		}
+		if items == "" {
+			items = "Any"
+		}
+		return "List[" + gen.pythonType(items, "", "") + "]"
+	case rdl.BaseTypeMap:
+		if t.Variant == rdl.TypeVariantMapTypeDef && rdl.TypeRef(t.MapTypeDef.Name) == rdlType {
+			keys = t.MapTypeDef.Keys
+			items = t.MapTypeDef.Items
+		}
+		if keys == "" {
+			keys = "String"
+		}
+		if items == "" {
+			items = "Any"
+		}
+		return "Dict[" + gen.pythonType(keys, "", "") + ", " + gen.pythonType(items, "", "") + "]"
+	default: //String, Symbol, UUID, Timestamp, Bytes
+		return "str"
+	}
+}
+
+// converter returns a Python callable that turns the decoded JSON for the type into its model
+// representation, or "" if the JSON value can be used as is.
+func (gen *pythonModelGenerator) converter(rdlType rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil || t.Variant == 0 {
+		return ""
+	}
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeStruct:
+		if rdlType == "Struct" || strings.HasPrefix(string(rdlType), "rdl.") {
+			return ""
+		}
+		return string(rdlType) + ".from_dict"
+	case rdl.BaseTypeUnion:
+		return string(rdlType) + ".from_dict"
+	case rdl.BaseTypeEnum:
+		return string(rdlType)
+	case rdl.BaseTypeArray:
+		if t.Variant == rdl.TypeVariantArrayTypeDef {
+			items = t.ArrayTypeDef.Items
+		}
+		if items == "" {
+			return ""
+		}
+		conv := gen.converter(items, "", "")
+		if conv == "" {
+			return ""
+		}
+		return fmt.Sprintf("lambda v: [_convert(x, %s) for x in v]", conv)
+	case rdl.BaseTypeMap:
+		if t.Variant == rdl.TypeVariantMapTypeDef {
+			items = t.MapTypeDef.Items
+		}
+		if items == "" {
+			return ""
+		}
+		conv := gen.converter(items, "", "")
+		if conv == "" {
+			return ""
+		}
+		return fmt.Sprintf("lambda v: {k: _convert(x, %s) for k, x in v.items()}", conv)
+	}
+	return ""
+}
+
+// typeConstraints collects the restrictions declared along the type's derivation chain, closest
+// definition first, as python keyword arguments for the dataclass checkers.
+func (gen *pythonModelGenerator) typeConstraints(rdlType rdl.TypeRef) (string, []string) {
+	var args []string
+	seen := make(map[string]bool)
+	add := func(key string, val string) {
+		if !seen[key] {
+			seen[key] = true
+			args = append(args, key+"="+val)
+		}
+	}
+	kind := ""
+	t := gen.registry.FindType(rdlType)
+	for t != nil {
+		tName, tType, _ := rdl.TypeInfo(t)
+		switch t.Variant {
+		case rdl.TypeVariantStringTypeDef:
+			kind = "_check_string"
+			st := t.StringTypeDef
+			if st.Pattern != "" {
+				add("pattern", fmt.Sprintf("r%q", st.Pattern))
+			}
+			if st.Values != nil {
+				add("values", fmt.Sprintf("[%s]", quotedList(st.Values)))
+			}
+			if st.MinSize != nil {
+				add("min_size", fmt.Sprintf("%d", *st.MinSize))
+			}
+			if st.MaxSize != nil {
+				add("max_size", fmt.Sprintf("%d", *st.MaxSize))
+			}
+		case rdl.TypeVariantNumberTypeDef:
+			kind = "_check_number"
+			nt := t.NumberTypeDef
+			if nt.Min != nil {
+				add("min", numberString(nt.Min))
+			}
+			if nt.Max != nil {
+				add("max", numberString(nt.Max))
+			}
+		case rdl.TypeVariantArrayTypeDef, rdl.TypeVariantMapTypeDef:
+			kind = "_check_size"
+			var size, minSize, maxSize *int32
+			if t.ArrayTypeDef != nil {
+				size, minSize, maxSize = t.ArrayTypeDef.Size, t.ArrayTypeDef.MinSize, t.ArrayTypeDef.MaxSize
+			} else {
+				size, minSize, maxSize = t.MapTypeDef.Size, t.MapTypeDef.MinSize, t.MapTypeDef.MaxSize
+			}
+			if size != nil {
+				add("size", fmt.Sprintf("%d", *size))
+			}
+			if minSize != nil {
+				add("min_size", fmt.Sprintf("%d", *minSize))
+			}
+			if maxSize != nil {
+				add("max_size", fmt.Sprintf("%d", *maxSize))
+			}
+		}
+		if rdl.TypeRef(tName) == tType {
+			break
+		}
+		t = gen.registry.FindType(tType)
+	}
+	if len(args) == 0 {
+		return "", nil
+	}
+	return kind, args
+}
+
+// pydanticConstraints maps the dataclass checker arguments onto pydantic Field arguments.
+func pydanticConstraints(args []string) []string {
+	var result []string
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		switch kv[0] {
+		case "pattern":
+			p, _ := strconv.Unquote(kv[1][1:])
+			result = append(result, fmt.Sprintf("pattern=r%q", "^(?:"+p+")$"))
+		case "min_size":
+			result = append(result, "min_length="+kv[1])
+		case "max_size":
+			result = append(result, "max_length="+kv[1])
+		case "size":
+			result = append(result, "min_length="+kv[1], "max_length="+kv[1])
+		case "min":
+			result = append(result, "ge="+kv[1])
+		case "max":
+			result = append(result, "le="+kv[1])
+		}
+	}
+	return result
+}
+
+func quotedList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", v))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (gen *pythonModelGenerator) literal(f *rdl.StructFieldDef) string {
+	switch v := f.Default.(type) {
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case string:
+		if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeEnum {
+			return string(f.Type) + "." + v
+		}
+		return fmt.Sprintf("%q", v)
+	case float64:
+		switch gen.registry.FindBaseType(f.Type) {
+		case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+			return fmt.Sprintf("%d", int64(v))
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprintf("%v", f.Default)
+}
+
+// enumWireName returns the string the enum element is written as: its x_wire annotation, or else
+// its symbol.
+func enumWireName(elem *rdl.EnumElementDef) string {
+	if wire := strings.TrimSpace(elem.Annotations["x_wire"]); wire != "" {
+		return wire
+	}
+	return string(elem.Symbol)
+}
+
+// enumReadNames returns the strings the enum element is read from besides its wire name: its
+// symbol, if it is written as another one, and its x_aliases.
+func enumReadNames(elem *rdl.EnumElementDef) []string {
+	var names []string
+	if enumWireName(elem) != string(elem.Symbol) {
+		names = append(names, string(elem.Symbol))
+	}
+	for _, alias := range strings.Split(elem.Annotations["x_aliases"], ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
+
+// emitEnum emits the enum class, whose values are the wire names of the elements. The other
+// strings an element is read from are looked up in _missing_.
+func (gen *pythonModelGenerator) emitEnum(et *rdl.EnumTypeDef) {
+	gen.emit(fmt.Sprintf("\n\nclass %s(str, Enum):\n", et.Name))
+	gen.emitDocstring(et.Comment, "    ")
+	var read []string
+	for _, elem := range et.Elements {
+		name := pythonName(string(elem.Symbol))
+		gen.emit(fmt.Sprintf("    %s = %q", name, enumWireName(elem)))
+		if elem.Comment != "" {
+			gen.emit("  # " + elem.Comment)
+		}
+		gen.emit("\n")
+		for _, r := range enumReadNames(elem) {
+			read = append(read, fmt.Sprintf("%q: cls.%s", r, name))
+		}
+	}
+	if len(read) > 0 {
+		gen.emit("\n    @classmethod\n")
+		gen.emit(fmt.Sprintf("    def _missing_(cls, value: object) -> Optional[%s]:\n", et.Name))
+		gen.emit(fmt.Sprintf("        return {%s}.get(value)\n", strings.Join(read, ", ")))
+	}
+}
+
+func (gen *pythonModelGenerator) emitStruct(t *rdl.Type) {
+	st := t.StructTypeDef
+	fields := flattenedFields(gen.registry, t)
+	//dataclass fields without a default value must precede the ones that have one
+	var ordered []*rdl.StructFieldDef
+	for _, f := range fields {
+		if !f.Optional && f.Default == nil {
+			ordered = append(ordered, f)
+		}
+	}
+	for _, f := range fields {
+		if f.Optional || f.Default != nil {
+			ordered = append(ordered, f)
+		}
+	}
+	if gen.pydantic {
+		gen.emit(fmt.Sprintf("\n\nclass %s(BaseModel):\n", st.Name))
+		gen.emitDocstring(st.Comment, "    ")
+		gen.emit("    model_config = ConfigDict(populate_by_name=True")
+		if st.Closed {
+			gen.emit(", extra=\"forbid\"")
+		}
+		gen.emit(")\n")
+		if len(fields) > 0 {
+			gen.emit("\n")
+		}
+		for _, f := range fields {
+			gen.emitPydanticField(f)
+		}
+		gen.emitPydanticMethods(string(st.Name))
+		return
+	}
+	gen.emit(fmt.Sprintf("\n\n@dataclass\nclass %s:\n", st.Name))
+	gen.emitDocstring(st.Comment, "    ")
+	if len(fields) == 0 {
+		gen.emit("    pass\n")
+	}
+	for _, f := range ordered {
+		fname := pythonName(string(f.Name))
+		ptype := gen.pythonType(f.Type, f.Items, f.Keys)
+		if f.Optional && f.Default == nil {
+			ptype = "Optional[" + ptype + "]"
+		}
+		gen.emit(fmt.Sprintf("    %s: %s", fname, ptype))
+		var fieldArgs []string
+		if f.Default != nil {
+			fieldArgs = append(fieldArgs, "default="+gen.literal(f))
+		} else if f.Optional {
+			fieldArgs = append(fieldArgs, "default=None")
+		}
+		if fname != string(f.Name) {
+			fieldArgs = append(fieldArgs, fmt.Sprintf("metadata={\"json\": %q}", f.Name))
+			gen.emit(" = field(" + strings.Join(fieldArgs, ", ") + ")")
+		} else if len(fieldArgs) > 0 {
+			gen.emit(" = " + strings.TrimPrefix(fieldArgs[0], "default="))
+		}
+		if f.Comment != "" {
+			gen.emit("  # " + f.Comment)
+		}
+		gen.emit("\n")
+	}
+	var checks []string
+	for _, f := range fields {
+		fname := pythonName(string(f.Name))
+		context := fmt.Sprintf("%q", string(st.Name)+"."+string(f.Name))
+		if !f.Optional {
+			checks = append(checks, fmt.Sprintf("_check_required(%s, self.%s)", context, fname))
+		}
+		kind, args := gen.typeConstraints(f.Type)
+		if kind != "" {
+			checks = append(checks, fmt.Sprintf("%s(%s, self.%s, %s)", kind, context, fname, strings.Join(args, ", ")))
+		}
+	}
+	if len(checks) > 0 {
+		gen.emit("\n    def __post_init__(self) -> None:\n")
+		for _, check := range checks {
+			gen.emit("        " + check + "\n")
+		}
+	}
+	gen.emit(fmt.Sprintf("\n    @classmethod\n    def from_dict(cls, d: Dict[str, Any]) -> %s:\n", st.Name))
+	if len(fields) == 0 {
+		gen.emit("        return cls()\n")
+	} else {
+		gen.emit("        return cls(\n")
+		for _, f := range fields {
+			var expr string
+			if f.Default != nil {
+				expr = fmt.Sprintf("d.get(%q, %s)", f.Name, gen.literal(f))
+			} else if f.Optional {
+				expr = fmt.Sprintf("d.get(%q)", f.Name)
+			} else {
+				expr = fmt.Sprintf("_required(d, %q, %q)", f.Name, st.Name)
+			}
+			if conv := gen.converter(f.Type, f.Items, f.Keys); conv != "" {
+				expr = fmt.Sprintf("_convert(%s, %s)", expr, conv)
+			}
+			gen.emit(fmt.Sprintf("            %s=%s,\n", pythonName(string(f.Name)), expr))
+		}
+		gen.emit("        )\n")
+	}
+	gen.emit("\n    def to_dict(self) -> Dict[str, Any]:\n        return _to_json(self)\n")
+}
+
+func (gen *pythonModelGenerator) emitPydanticField(f *rdl.StructFieldDef) {
+	fname := pythonName(string(f.Name))
+	ptype := gen.pythonType(f.Type, f.Items, f.Keys)
+	if f.Optional && f.Default == nil {
+		ptype = "Optional[" + ptype + "]"
+	}
+	var args []string
+	if f.Default != nil {
+		args = append(args, gen.literal(f))
+	} else if f.Optional {
+		args = append(args, "None")
+	}
+	if fname != string(f.Name) {
+		args = append(args, fmt.Sprintf("alias=%q", f.Name))
+	}
+	_, constraints := gen.typeConstraints(f.Type)
+	constraints = pydanticConstraints(constraints)
+	if len(constraints) > 0 && len(args) == 0 {
+		args = append(args, "...")
+	}
+	args = append(args, constraints...)
+	gen.emit(fmt.Sprintf("    %s: %s", fname, ptype))
+	if len(args) == 1 && fname == string(f.Name) && len(constraints) == 0 {
+		gen.emit(" = " + args[0])
+	} else if len(args) > 0 {
+		gen.emit(" = Field(" + strings.Join(args, ", ") + ")")
+	}
+	if f.Comment != "" {
+		gen.emit("  # " + f.Comment)
+	}
+	gen.emit("\n")
+}
+
+func (gen *pythonModelGenerator) emitPydanticMethods(name string) {
+	gen.emit(fmt.Sprintf("\n    @classmethod\n    def from_dict(cls, d: Dict[str, Any]) -> %s:\n        return cls.model_validate(d)\n", name))
+	gen.emit("\n    def to_dict(self) -> Dict[str, Any]:\n        return self.model_dump(mode=\"json\", by_alias=True, exclude_none=True)\n")
+}
+
+func (gen *pythonModelGenerator) emitUnion(ut *rdl.UnionTypeDef) {
+	var fnames []string
+	for _, v := range ut.Variants {
+		fnames = append(fnames, pythonName(uncapitalize(string(v))))
+	}
+	if gen.pydantic {
+		gen.emit(fmt.Sprintf("\n\nclass %s(BaseModel):\n", ut.Name))
+		gen.emitDocstring(ut.Comment, "    ")
+		gen.emit("    model_config = ConfigDict(populate_by_name=True)\n\n")
+		for i, v := range ut.Variants {
+			gen.emit(fmt.Sprintf("    %s: Optional[%s] = Field(None, alias=%q)\n", fnames[i], gen.pythonType(v, "", ""), v))
+		}
+		gen.emit("\n    @model_validator(mode=\"after\")\n")
+		gen.emit(fmt.Sprintf("    def check_variant(self) -> %s:\n", ut.Name))
+		gen.emit(fmt.Sprintf("        if [%s].count(None) != %d:\n", selfList(fnames), len(fnames)-1))
+		gen.emit(fmt.Sprintf("            raise ValueError(\"%s: exactly one variant must be set\")\n", ut.Name))
+		gen.emit("        return self\n")
+		gen.emitPydanticMethods(string(ut.Name))
+		return
+	}
+	gen.emit(fmt.Sprintf("\n\n@dataclass\nclass %s:\n", ut.Name))
+	gen.emitDocstring(ut.Comment, "    ")
+	for i, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("    %s: Optional[%s] = field(default=None, metadata={\"json\": %q})\n", fnames[i], gen.pythonType(v, "", ""), v))
+	}
+	gen.emit("\n    def __post_init__(self) -> None:\n")
+	gen.emit(fmt.Sprintf("        if [%s].count(None) != %d:\n", selfList(fnames), len(fnames)-1))
+	gen.emit(fmt.Sprintf("            raise ValueError(\"%s: exactly one variant must be set\")\n", ut.Name))
+	gen.emit(fmt.Sprintf("\n    @classmethod\n    def from_dict(cls, d: Dict[str, Any]) -> %s:\n", ut.Name))
+	gen.emit("        return cls(\n")
+	for i, v := range ut.Variants {
+		expr := fmt.Sprintf("d.get(%q)", v)
+		if conv := gen.converter(v, "", ""); conv != "" {
+			expr = fmt.Sprintf("_convert(%s, %s)", expr, conv)
+		}
+		gen.emit(fmt.Sprintf("            %s=%s,\n", fnames[i], expr))
+	}
+	gen.emit("        )\n")
+	gen.emit("\n    def to_dict(self) -> Dict[str, Any]:\n        return _to_json(self)\n")
+}
+
+func selfList(names []string) string {
+	refs := make([]string, 0, len(names))
+	for _, n := range names {
+		refs = append(refs, "self."+n)
+	}
+	return strings.Join(refs, ", ")
+}
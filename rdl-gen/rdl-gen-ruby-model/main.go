@@ -0,0 +1,716 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate Ruby model classes for the types in an RDL schema, with attribute readers, JSON
+// conversion, enum modules, and the validation of the constraints of the schema
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			err = ExportToRubyModel(&schema, *pOutdir)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func capitalize(text string) string {
+	return strings.ToUpper(text[0:1]) + text[1:]
+}
+
+func uncapitalize(text string) string {
+	return strings.ToLower(text[0:1]) + text[1:]
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+func numberString(n *rdl.Number) string {
+	switch n.Variant {
+	case rdl.NumberVariantInt8:
+		return fmt.Sprintf("%d", *n.Int8)
+	case rdl.NumberVariantInt16:
+		return fmt.Sprintf("%d", *n.Int16)
+	case rdl.NumberVariantInt32:
+		return fmt.Sprintf("%d", *n.Int32)
+	case rdl.NumberVariantInt64:
+		return fmt.Sprintf("%d", *n.Int64)
+	case rdl.NumberVariantFloat32:
+		return strconv.FormatFloat(float64(*n.Float32), 'g', -1, 32)
+	case rdl.NumberVariantFloat64:
+		return strconv.FormatFloat(*n.Float64, 'g', -1, 64)
+	}
+	return "nil"
+}
+
+var rubyKeywords = map[string]bool{
+	"BEGIN": true, "END": true, "alias": true, "and": true, "begin": true, "break": true, "case": true,
+	"class": true, "def": true, "defined?": true, "do": true, "else": true, "elsif": true, "end": true,
+	"ensure": true, "false": true, "for": true, "if": true, "in": true, "module": true, "next": true,
+	"nil": true, "not": true, "or": true, "redo": true, "rescue": true, "retry": true, "return": true,
+	"self": true, "super": true, "then": true, "true": true, "undef": true, "unless": true, "until": true,
+	"when": true, "while": true, "yield": true,
+}
+
+// rubyName returns the snake_case name of the attribute for the field, e.g. last_name for lastName.
+func rubyName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) && runes[i-1] != '_' {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	s := b.String()
+	if rubyKeywords[s] {
+		return s + "_"
+	}
+	return s
+}
+
+// rubyFileName returns the snake_case name of the file of the schema, e.g. contact_service.
+func rubyFileName(name string) string {
+	return strings.TrimSuffix(rubyName(name), "_")
+}
+
+// rubyString returns the double quoted Ruby string literal of s, without interpolation.
+func rubyString(s string) string {
+	return strings.Replace(strconv.Quote(s), "#", "\\#", -1)
+}
+
+// rubyConstant returns the name of the constant of the enum symbol, e.g. FAST for fast.
+func rubyConstant(symbol string) string {
+	return strings.ToUpper(symbol)
+}
+
+const rubyPrelude = `
+require "json"
+
+module {{module}}
+  #
+  # Support - the helpers of the generated model classes.
+  #
+  module Support
+    #
+    # Model - included by the model classes, which define to_h and from_h.
+    #
+    module Model
+      def self.included(base)
+        base.extend(ClassMethods)
+      end
+
+      module ClassMethods
+        def from_json(json)
+          from_h(JSON.parse(json))
+        end
+      end
+
+      def to_json(*args)
+        to_h.to_json(*args)
+      end
+
+      def ==(other)
+        other.class == self.class && other.to_h == to_h
+      end
+
+      alias eql? ==
+
+      def hash
+        [self.class, to_h].hash
+      end
+    end
+
+    def self.required(h, key, owner)
+      raise ArgumentError, "#{owner}: missing required field '#{key}'" if h[key].nil?
+      h[key]
+    end
+
+    def self.convert(value)
+      value.nil? ? nil : yield(value)
+    end
+
+    # dump returns the JSON value of the fields of a model, without the unset ones.
+    def self.dump(fields)
+      fields.compact.transform_values { |v| to_json_value(v) }
+    end
+
+    def self.to_json_value(value)
+      case value
+      when Model
+        value.to_h
+      when Array
+        value.map { |v| to_json_value(v) }
+      when Hash
+        value.transform_values { |v| to_json_value(v) }
+      else
+        value
+      end
+    end
+
+    def self.check_required(context, value)
+      raise ArgumentError, "#{context}: missing required value" if value.nil?
+    end
+
+    def self.check_string(context, value, pattern: nil, values: nil, min_size: nil, max_size: nil)
+      return if value.nil?
+      if pattern && value !~ pattern
+        raise ArgumentError, "#{context}: value #{value.inspect} does not match pattern #{pattern.source.inspect}"
+      end
+      if values && !values.include?(value)
+        raise ArgumentError, "#{context}: value #{value.inspect} is not one of #{values.inspect}"
+      end
+      if min_size && value.length < min_size
+        raise ArgumentError, "#{context}: value #{value.inspect} is shorter than #{min_size}"
+      end
+      if max_size && value.length > max_size
+        raise ArgumentError, "#{context}: value #{value.inspect} is longer than #{max_size}"
+      end
+    end
+
+    def self.check_number(context, value, min: nil, max: nil)
+      return if value.nil?
+      raise ArgumentError, "#{context}: value #{value.inspect} is less than #{min}" if min && value < min
+      raise ArgumentError, "#{context}: value #{value.inspect} is greater than #{max}" if max && value > max
+    end
+
+    def self.check_size(context, value, size: nil, min_size: nil, max_size: nil)
+      return if value.nil?
+      raise ArgumentError, "#{context}: size #{value.size} is not #{size}" if size && value.size != size
+      raise ArgumentError, "#{context}: size #{value.size} is less than #{min_size}" if min_size && value.size < min_size
+      raise ArgumentError, "#{context}: size #{value.size} is greater than #{max_size}" if max_size && value.size > max_size
+    end
+
+    def self.check_enum(context, value, values)
+      return if value.nil?
+      raise ArgumentError, "#{context}: value #{value.inspect} is not one of #{values.inspect}" unless values.include?(value)
+    end
+  end
+`
+
+type rubyModelGenerator struct {
+	registry rdl.TypeRegistry
+	schema   *rdl.Schema
+	writer   *bufio.Writer
+	err      error
+}
+
+// ExportToRubyModel generates a Ruby file with a module named after the schema, holding a class
+// for each struct and union type in the schema, and a module of constants for each enum type. The
+// classes take their fields as keyword arguments, validate them, and convert from and to JSON with
+// from_json and to_json (from_h and to_h for the parsed hashes). The fields of the classes are
+// flattened, not inherited, and the other types are the Ruby values of their JSON.
+func ExportToRubyModel(schema *rdl.Schema, outdir string) error {
+	out, file, _, err := outputWriter(outdir, rubyFileName(string(schema.Name)), ".rb")
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	gen := &rubyModelGenerator{rdl.NewTypeRegistry(schema), schema, out, nil}
+	gen.emit("#\n# This file generated by rdl-gen-ruby-model. Do not modify!\n#\n")
+	if schema.Comment != "" {
+		gen.emitComment(schema.Comment, "")
+	}
+	gen.emit(strings.Replace(rubyPrelude, "{{module}}", capitalize(string(schema.Name)), -1))
+	for _, t := range schema.Types {
+		tName, _, _ := rdl.TypeInfo(t)
+		if strings.HasPrefix(string(tName), "rdl.") {
+			continue
+		}
+		gen.emitType(t)
+	}
+	gen.emit("end\n")
+	out.Flush()
+	return gen.err
+}
+
+func (gen *rubyModelGenerator) emit(s string) {
+	if gen.err == nil {
+		_, err := gen.writer.WriteString(s)
+		if err != nil {
+			gen.err = err
+		}
+	}
+}
+
+func (gen *rubyModelGenerator) emitComment(comment string, indent string) {
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			gen.emit(strings.TrimRight(indent+"# "+line, " ") + "\n")
+		}
+	}
+}
+
+func (gen *rubyModelGenerator) emitType(t *rdl.Type) {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		gen.emitStruct(t)
+	case rdl.TypeVariantUnionTypeDef:
+		gen.emitUnion(t.UnionTypeDef)
+	case rdl.TypeVariantEnumTypeDef:
+		gen.emitEnum(t.EnumTypeDef)
+	}
+	//the other types have no class, their values are those of their JSON
+}
+
+// isModel tells whether the type has a generated class.
+func (gen *rubyModelGenerator) isModel(rdlType rdl.TypeRef) bool {
+	t := gen.registry.FindType(rdlType)
+	if t == nil {
+		return false
+	}
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef, rdl.TypeVariantUnionTypeDef:
+		tName, _, _ := rdl.TypeInfo(t)
+		return tName != "Struct" && !strings.HasPrefix(string(tName), "rdl.")
+	}
+	return false
+}
+
+// converter returns the expression turning expr, the non-nil JSON value of the type, into its
+// model value, or expr itself if the JSON value is the model value.
+func (gen *rubyModelGenerator) converter(expr string, rdlType rdl.TypeRef, items rdl.TypeRef, depth int) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil || t.Variant == 0 {
+		gen.err = fmt.Errorf("Cannot find type '%s'", rdlType)
+		return expr
+	}
+	if gen.isModel(rdlType) {
+		tName, _, _ := rdl.TypeInfo(t)
+		return fmt.Sprintf("%s.from_h(%s)", tName, expr)
+	}
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeArray, rdl.BaseTypeMap:
+		if t.Variant == rdl.TypeVariantArrayTypeDef {
+			items = t.ArrayTypeDef.Items
+		} else if t.Variant == rdl.TypeVariantMapTypeDef {
+			items = t.MapTypeDef.Items
+		}
+		if items == "" {
+			return expr
+		}
+		e := fmt.Sprintf("e%d", depth)
+		v := fmt.Sprintf("v%d", depth)
+		conv := gen.converter(v, items, "", depth+1)
+		if conv == v {
+			return expr
+		}
+		each := "map"
+		if gen.registry.BaseType(t) == rdl.BaseTypeMap {
+			each = "transform_values"
+		}
+		return fmt.Sprintf("%s.%s { |%s| Support.convert(%s) { |%s| %s } }", expr, each, e, e, v, conv)
+	case rdl.BaseTypeEnum:
+		if et := gen.enumType(rdlType); et != nil && hasEnumReadNames(et) {
+			return fmt.Sprintf("%s.read(%s)", et.Name, expr)
+		}
+	}
+	return expr
+}
+
+// typeConstraints collects the restrictions declared along the type's derivation chain, closest
+// definition first, as Ruby keyword arguments for the checkers of Support.
+func (gen *rubyModelGenerator) typeConstraints(rdlType rdl.TypeRef) (string, []string) {
+	var args []string
+	seen := make(map[string]bool)
+	add := func(key string, val string) {
+		if !seen[key] {
+			seen[key] = true
+			args = append(args, key+": "+val)
+		}
+	}
+	kind := ""
+	t := gen.registry.FindType(rdlType)
+	for t != nil {
+		tName, tType, _ := rdl.TypeInfo(t)
+		switch t.Variant {
+		case rdl.TypeVariantStringTypeDef:
+			kind = "check_string"
+			st := t.StringTypeDef
+			if st.Pattern != "" {
+				add("pattern", fmt.Sprintf("Regexp.new(%s)", rubyString("\\A(?:"+st.Pattern+")\\z")))
+			}
+			if st.Values != nil {
+				add("values", fmt.Sprintf("[%s]", quotedList(st.Values)))
+			}
+			if st.MinSize != nil {
+				add("min_size", fmt.Sprintf("%d", *st.MinSize))
+			}
+			if st.MaxSize != nil {
+				add("max_size", fmt.Sprintf("%d", *st.MaxSize))
+			}
+		case rdl.TypeVariantNumberTypeDef:
+			kind = "check_number"
+			nt := t.NumberTypeDef
+			if nt.Min != nil {
+				add("min", numberString(nt.Min))
+			}
+			if nt.Max != nil {
+				add("max", numberString(nt.Max))
+			}
+		case rdl.TypeVariantArrayTypeDef, rdl.TypeVariantMapTypeDef:
+			kind = "check_size"
+			var size, minSize, maxSize *int32
+			if t.ArrayTypeDef != nil {
+				size, minSize, maxSize = t.ArrayTypeDef.Size, t.ArrayTypeDef.MinSize, t.ArrayTypeDef.MaxSize
+			} else {
+				size, minSize, maxSize = t.MapTypeDef.Size, t.MapTypeDef.MinSize, t.MapTypeDef.MaxSize
+			}
+			if size != nil {
+				add("size", fmt.Sprintf("%d", *size))
+			}
+			if minSize != nil {
+				add("min_size", fmt.Sprintf("%d", *minSize))
+			}
+			if maxSize != nil {
+				add("max_size", fmt.Sprintf("%d", *maxSize))
+			}
+		}
+		if rdl.TypeRef(tName) == tType {
+			break
+		}
+		t = gen.registry.FindType(tType)
+	}
+	if len(args) == 0 {
+		return "", nil
+	}
+	return kind, args
+}
+
+func quotedList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, rubyString(v))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (gen *rubyModelGenerator) literal(f *rdl.StructFieldDef) string {
+	switch v := f.Default.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeEnum {
+			return gen.enumName(f.Type) + "::" + rubyConstant(v)
+		}
+		return rubyString(v)
+	case float64:
+		switch gen.registry.FindBaseType(f.Type) {
+		case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+			return fmt.Sprintf("%d", int64(v))
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	}
+	return fmt.Sprintf("%v", f.Default)
+}
+
+func (gen *rubyModelGenerator) emitEnum(et *rdl.EnumTypeDef) {
+	gen.emit("\n")
+	gen.emitComment(et.Comment, "  ")
+	gen.emit(fmt.Sprintf("  module %s\n", et.Name))
+	var names []string
+	for _, elem := range et.Elements {
+		name := rubyConstant(string(elem.Symbol))
+		names = append(names, name)
+		gen.emit(fmt.Sprintf("    %s = %s", name, rubyString(enumWireName(elem))))
+		if elem.Comment != "" {
+			gen.emit(" # " + elem.Comment)
+		}
+		gen.emit("\n")
+	}
+	gen.emit("\n    def self.values\n")
+	gen.emit(fmt.Sprintf("      [%s]\n", strings.Join(names, ", ")))
+	gen.emit("    end\n")
+	if hasEnumReadNames(et) {
+		//the other strings the elements are read from, mapped to their values
+		var read []string
+		for i, elem := range et.Elements {
+			for _, r := range enumReadNames(elem) {
+				read = append(read, fmt.Sprintf("%s => %s", rubyString(r), names[i]))
+			}
+		}
+		gen.emit("\n    def self.read(value)\n")
+		gen.emit(fmt.Sprintf("      { %s }.fetch(value, value)\n", strings.Join(read, ", ")))
+		gen.emit("    end\n")
+	}
+	gen.emit("  end\n")
+}
+
+// emitInitialize emits the attribute readers and the constructor taking the fields as keyword
+// arguments, validating them.
+func (gen *rubyModelGenerator) emitInitialize(names []string, defaults []string) {
+	if len(names) > 0 {
+		symbols := make([]string, 0, len(names))
+		params := make([]string, 0, len(names))
+		for i, name := range names {
+			symbols = append(symbols, ":"+name)
+			if defaults[i] == "" {
+				params = append(params, name+":")
+			} else {
+				params = append(params, name+": "+defaults[i])
+			}
+		}
+		gen.emit(fmt.Sprintf("    attr_reader %s\n\n", strings.Join(symbols, ", ")))
+		gen.emit(fmt.Sprintf("    def initialize(%s)\n", strings.Join(params, ", ")))
+	} else {
+		gen.emit("    def initialize\n")
+	}
+	for _, name := range names {
+		gen.emit(fmt.Sprintf("      @%s = %s\n", name, name))
+	}
+	gen.emit("      validate!\n")
+	gen.emit("    end\n")
+}
+
+func (gen *rubyModelGenerator) emitStruct(t *rdl.Type) {
+	st := t.StructTypeDef
+	fields := flattenedFields(gen.registry, t)
+	gen.emit("\n")
+	gen.emitComment(st.Comment, "  ")
+	gen.emit(fmt.Sprintf("  class %s\n", st.Name))
+	gen.emit("    include Support::Model\n\n")
+	var names, defaults []string
+	for _, f := range fields {
+		names = append(names, rubyName(string(f.Name)))
+		if f.Default != nil {
+			defaults = append(defaults, gen.literal(f))
+		} else if f.Optional {
+			defaults = append(defaults, "nil")
+		} else {
+			defaults = append(defaults, "")
+		}
+	}
+	for _, f := range fields {
+		if f.Comment != "" {
+			gen.emit(fmt.Sprintf("    # %s: %s\n", rubyName(string(f.Name)), f.Comment))
+		}
+	}
+	gen.emitInitialize(names, defaults)
+	gen.emit("\n    def validate!\n")
+	for i, f := range fields {
+		context := rubyString(string(st.Name) + "." + string(f.Name))
+		if !f.Optional {
+			gen.emit(fmt.Sprintf("      Support.check_required(%s, @%s)\n", context, names[i]))
+		}
+		if kind, args := gen.typeConstraints(f.Type); kind != "" {
+			gen.emit(fmt.Sprintf("      Support.%s(%s, @%s, %s)\n", kind, context, names[i], strings.Join(args, ", ")))
+		}
+		if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeEnum {
+			gen.emit(fmt.Sprintf("      Support.check_enum(%s, @%s, %s.values)\n", context, names[i], gen.enumName(f.Type)))
+		}
+	}
+	gen.emit("      self\n")
+	gen.emit("    end\n")
+	gen.emit("\n    def self.from_h(h)\n")
+	if len(fields) == 0 {
+		gen.emit("      new\n")
+	} else {
+		gen.emit("      new(\n")
+		for i, f := range fields {
+			var expr string
+			if f.Default != nil {
+				expr = fmt.Sprintf("h.fetch(%s, %s)", rubyString(string(f.Name)), gen.literal(f))
+			} else if f.Optional {
+				expr = fmt.Sprintf("h[%s]", rubyString(string(f.Name)))
+			} else {
+				expr = fmt.Sprintf("Support.required(h, %s, %s)", rubyString(string(f.Name)), rubyString(string(st.Name)))
+			}
+			if conv := gen.converter("v", f.Type, f.Items, 1); conv != "v" {
+				expr = fmt.Sprintf("Support.convert(%s) { |v| %s }", expr, conv)
+			}
+			gen.emit(fmt.Sprintf("        %s: %s,\n", names[i], expr))
+		}
+		gen.emit("      )\n")
+	}
+	gen.emit("    end\n")
+	gen.emitToH(fields, names)
+	gen.emit("  end\n")
+}
+
+// enumName returns the name of the enum type the type derives from.
+func (gen *rubyModelGenerator) enumName(rdlType rdl.TypeRef) string {
+	et := gen.enumType(rdlType)
+	if et == nil {
+		return string(rdlType)
+	}
+	return string(et.Name)
+}
+
+// enumType returns the enum type the type derives from, or nil.
+func (gen *rubyModelGenerator) enumType(rdlType rdl.TypeRef) *rdl.EnumTypeDef {
+	t := gen.registry.FindType(rdlType)
+	for t != nil && t.Variant != rdl.TypeVariantEnumTypeDef {
+		_, tType, _ := rdl.TypeInfo(t)
+		t = gen.registry.FindType(tType)
+	}
+	if t == nil {
+		return nil
+	}
+	return t.EnumTypeDef
+}
+
+// enumWireName returns the string the enum element is written as: its x_wire annotation, or else
+// its symbol.
+func enumWireName(elem *rdl.EnumElementDef) string {
+	if wire := strings.TrimSpace(elem.Annotations["x_wire"]); wire != "" {
+		return wire
+	}
+	return string(elem.Symbol)
+}
+
+// enumReadNames returns the strings the enum element is read from besides its wire name: its
+// symbol, if it is written as another one, and its x_aliases.
+func enumReadNames(elem *rdl.EnumElementDef) []string {
+	var names []string
+	if enumWireName(elem) != string(elem.Symbol) {
+		names = append(names, string(elem.Symbol))
+	}
+	for _, alias := range strings.Split(elem.Annotations["x_aliases"], ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
+
+// hasEnumReadNames tells whether an element of the enum is read from another string than its
+// wire name.
+func hasEnumReadNames(et *rdl.EnumTypeDef) bool {
+	for _, elem := range et.Elements {
+		if len(enumReadNames(elem)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (gen *rubyModelGenerator) emitToH(fields []*rdl.StructFieldDef, names []string) {
+	gen.emit("\n    def to_h\n")
+	if len(fields) == 0 {
+		gen.emit("      {}\n")
+	} else {
+		gen.emit("      Support.dump(\n")
+		for i, f := range fields {
+			gen.emit(fmt.Sprintf("        %s => @%s,\n", rubyString(string(f.Name)), names[i]))
+		}
+		gen.emit("      )\n")
+	}
+	gen.emit("    end\n")
+}
+
+func (gen *rubyModelGenerator) emitUnion(ut *rdl.UnionTypeDef) {
+	var names []string
+	var defaults []string
+	var fields []*rdl.StructFieldDef
+	for _, v := range ut.Variants {
+		names = append(names, rubyName(uncapitalize(string(v))))
+		defaults = append(defaults, "nil")
+		fields = append(fields, &rdl.StructFieldDef{Name: rdl.Identifier(v), Type: v})
+	}
+	gen.emit("\n")
+	gen.emitComment(ut.Comment, "  ")
+	gen.emit(fmt.Sprintf("  class %s\n", ut.Name))
+	gen.emit("    include Support::Model\n\n")
+	gen.emitInitialize(names, defaults)
+	gen.emit("\n    def validate!\n")
+	ivars := make([]string, 0, len(names))
+	for _, name := range names {
+		ivars = append(ivars, "@"+name)
+	}
+	gen.emit(fmt.Sprintf("      unless [%s].compact.size == 1\n", strings.Join(ivars, ", ")))
+	gen.emit(fmt.Sprintf("        raise ArgumentError, %s\n", rubyString(string(ut.Name)+": exactly one variant must be set")))
+	gen.emit("      end\n")
+	gen.emit("      self\n")
+	gen.emit("    end\n")
+	gen.emit("\n    def self.from_h(h)\n")
+	gen.emit("      new(\n")
+	for i, v := range ut.Variants {
+		expr := fmt.Sprintf("h[%s]", rubyString(string(v)))
+		if conv := gen.converter("v", v, "", 1); conv != "v" {
+			expr = fmt.Sprintf("Support.convert(%s) { |v| %s }", expr, conv)
+		}
+		gen.emit(fmt.Sprintf("        %s: %s,\n", names[i], expr))
+	}
+	gen.emit("      )\n")
+	gen.emit("    end\n")
+	gen.emitToH(fields, names)
+	gen.emit("  end\n")
+}
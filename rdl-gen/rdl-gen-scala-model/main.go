@@ -0,0 +1,540 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate Scala case classes for the types in an RDL schema, with circe or play-json codecs
+// in their companion objects if asked for
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	pJSON := flag.String("json", "", "Generate JSON codecs with this library: circe or play")
+	pPackage := flag.String("package", "", "The package of the generated code. Default is the schema namespace")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			err = ExportToScalaModel(&schema, *pOutdir, *pJSON, *pPackage)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func capitalize(text string) string {
+	return strings.ToUpper(text[0:1]) + text[1:]
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+var scalaKeywords = map[string]bool{
+	"abstract": true, "case": true, "catch": true, "class": true, "def": true, "do": true, "else": true,
+	"extends": true, "false": true, "final": true, "finally": true, "for": true, "forSome": true, "if": true,
+	"implicit": true, "import": true, "lazy": true, "match": true, "new": true, "null": true, "object": true,
+	"override": true, "package": true, "private": true, "protected": true, "return": true, "sealed": true,
+	"super": true, "this": true, "throw": true, "trait": true, "try": true, "true": true, "type": true,
+	"val": true, "var": true, "while": true, "with": true, "yield": true, "given": true, "enum": true,
+	"export": true, "then": true,
+}
+
+func scalaName(name string) string {
+	if scalaKeywords[name] {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+type scalaModelGenerator struct {
+	registry rdl.TypeRegistry
+	schema   *rdl.Schema
+	writer   *bufio.Writer
+	err      error
+	codecs   string
+	helpers  string
+}
+
+// ExportToScalaModel generates a Scala source file with a case class for each struct type in the
+// schema, a sealed trait for each union, and a sealed class with a case object per symbol for each
+// enum. Other named types are replaced by the Scala types they are defined with. With codecs set
+// to circe or play, each companion object also has the implicit JSON codecs for the type, which
+// read and write the same JSON as the Go and Java models.
+func ExportToScalaModel(schema *rdl.Schema, outdir string, codecs string, pkg string) error {
+	switch codecs {
+	case "", "circe", "play":
+	default:
+		return fmt.Errorf("Unsupported JSON library '%s' (expected circe or play)", codecs)
+	}
+	name := "anonymous"
+	if schema.Name != "" {
+		name = string(schema.Name)
+	}
+	out, file, _, err := outputWriter(outdir, capitalize(name)+"Model", ".scala")
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	gen := &scalaModelGenerator{rdl.NewTypeRegistry(schema), schema, out, nil, codecs, capitalize(name) + "Json"}
+	if pkg == "" {
+		pkg = string(schema.Namespace)
+	}
+	gen.emit("//\n// This file generated by rdl-gen-scala-model. Do not modify!\n//\n")
+	if pkg != "" {
+		gen.emit(fmt.Sprintf("package %s\n", pkg))
+	}
+	switch codecs {
+	case "circe":
+		gen.emit("\nimport io.circe.{Decoder, DecodingFailure, Encoder, Json}\nimport io.circe.syntax._\n")
+	case "play":
+		gen.emit("\nimport play.api.libs.json._\n")
+	}
+	if codecs != "" {
+		gen.emitHelpers()
+	}
+	for _, t := range schema.Types {
+		tName, _, _ := rdl.TypeInfo(t)
+		if strings.HasPrefix(string(tName), "rdl.") {
+			continue
+		}
+		switch t.Variant {
+		case rdl.TypeVariantStructTypeDef:
+			gen.emitStruct(t)
+		case rdl.TypeVariantUnionTypeDef:
+			gen.emitUnion(t.UnionTypeDef)
+		case rdl.TypeVariantEnumTypeDef:
+			gen.emitEnum(t.EnumTypeDef)
+		}
+	}
+	out.Flush()
+	return gen.err
+}
+
+func (gen *scalaModelGenerator) emit(s string) {
+	if gen.err == nil {
+		_, err := gen.writer.WriteString(s)
+		if err != nil {
+			gen.err = err
+		}
+	}
+}
+
+func (gen *scalaModelGenerator) emitComment(comment string, indent string) {
+	if comment != "" {
+		gen.emit(fmt.Sprintf("%s/** %s */\n", indent, strings.Replace(comment, "*/", "* /", -1)))
+	}
+}
+
+// scalaType returns the Scala type for the type reference. Struct, union, and enum types are the
+// generated classes, other named types are replaced by the types they are defined with.
+func (gen *scalaModelGenerator) scalaType(rdlType rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef) string {
+	t := gen.registry.FindType(rdlType)
+	if t == nil || t.Variant == 0 {
+		gen.err = fmt.Errorf("Cannot find type '%s'", rdlType)
+		return "Any"
+	}
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeAny:
+		return gen.anyType()
+	case rdl.BaseTypeStruct:
+		if rdlType == "Struct" || strings.HasPrefix(string(rdlType), "rdl.") {
+			return gen.anyType()
+		}
+		return string(rdlType)
+	case rdl.BaseTypeUnion, rdl.BaseTypeEnum:
+		return string(rdlType)
+	case rdl.BaseTypeBool:
+		return "Boolean"
+	case rdl.BaseTypeInt8:
+		return "Byte"
+	case rdl.BaseTypeInt16:
+		return "Short"
+	case rdl.BaseTypeInt32:
+		return "Int"
+	case rdl.BaseTypeInt64:
+		return "Long"
+	case rdl.BaseTypeFloat32:
+		return "Float"
+	case rdl.BaseTypeFloat64:
+		return "Double"
+	case rdl.BaseTypeTimestamp:
+		return "java.time.Instant"
+	case rdl.BaseTypeBytes:
+		return "Array[Byte]"
+	case rdl.BaseTypeArray:
+		if t.Variant == rdl.TypeVariantArrayTypeDef && rdl.TypeRef(t.ArrayTypeDef.Name) == rdlType {
+			items = t.ArrayTypeDef.Items
+		}
+		if items == "" {
+			items = "Any"
+		}
+		return "Seq[" + gen.scalaType(items, "", "") + "]"
+	case rdl.BaseTypeMap:
+		if t.Variant == rdl.TypeVariantMapTypeDef && rdl.TypeRef(t.MapTypeDef.Name) == rdlType {
+			items = t.MapTypeDef.Items
+		}
+		if items == "" {
+			items = "Any"
+		}
+		//the keys are strings in JSON, whatever string type the schema declares for them
+		return "Map[String, " + gen.scalaType(items, "", "") + "]"
+	default: //String, Symbol, UUID
+		return "String"
+	}
+}
+
+func (gen *scalaModelGenerator) anyType() string {
+	switch gen.codecs {
+	case "circe":
+		return "Json"
+	case "play":
+		return "JsValue"
+	}
+	return "Any"
+}
+
+func (gen *scalaModelGenerator) literal(f *rdl.StructFieldDef) string {
+	switch v := f.Default.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeEnum {
+			return string(f.Type) + "." + scalaName(v)
+		}
+		return fmt.Sprintf("%q", v)
+	case float64:
+		switch gen.registry.FindBaseType(f.Type) {
+		case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32:
+			return fmt.Sprintf("%d", int64(v))
+		case rdl.BaseTypeInt64:
+			return fmt.Sprintf("%dL", int64(v))
+		case rdl.BaseTypeFloat32:
+			return strconv.FormatFloat(v, 'g', -1, 32) + "f"
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	}
+	return fmt.Sprintf("%v", f.Default)
+}
+
+// emitHelpers emits the codecs of the types whose JSON form the library does not have the RDL
+// way: Bytes as base64, and Timestamps with milliseconds.
+func (gen *scalaModelGenerator) emitHelpers() {
+	gen.emit(fmt.Sprintf("\nobject %s {\n", gen.helpers))
+	gen.emit("  private val timestampFormat = java.time.format.DateTimeFormatter.ofPattern(\"yyyy-MM-dd'T'HH:mm:ss.SSS'Z'\").withZone(java.time.ZoneOffset.UTC)\n\n")
+	switch gen.codecs {
+	case "circe":
+		gen.emit("  implicit val bytesEncoder: Encoder[Array[Byte]] = Encoder.encodeString.contramap(java.util.Base64.getEncoder.encodeToString(_))\n")
+		gen.emit("  implicit val bytesDecoder: Decoder[Array[Byte]] = Decoder.decodeString.emapTry(s => scala.util.Try(java.util.Base64.getDecoder.decode(s)))\n")
+		gen.emit("  implicit val timestampEncoder: Encoder[java.time.Instant] = Encoder.encodeString.contramap(timestampFormat.format(_))\n")
+	case "play":
+		gen.emit("  implicit val bytesWrites: Writes[Array[Byte]] = Writes(b => JsString(java.util.Base64.getEncoder.encodeToString(b)))\n")
+		gen.emit("  implicit val bytesReads: Reads[Array[Byte]] = Reads.StringReads.flatMap { s =>\n")
+		gen.emit("    scala.util.Try(java.util.Base64.getDecoder.decode(s)).fold(_ => Reads(_ => JsError(\"error.expected.base64\")), b => Reads.pure(b))\n")
+		gen.emit("  }\n")
+		gen.emit("  implicit val timestampWrites: Writes[java.time.Instant] = Writes(t => JsString(timestampFormat.format(t)))\n")
+	}
+	gen.emit("}\n")
+}
+
+func (gen *scalaModelGenerator) emitStruct(t *rdl.Type) {
+	st := t.StructTypeDef
+	fields := flattenedFields(gen.registry, t)
+	gen.emit("\n")
+	gen.emitComment(st.Comment, "")
+	gen.emit(fmt.Sprintf("final case class %s(", st.Name))
+	for i, f := range fields {
+		if i > 0 {
+			gen.emit(",")
+		}
+		stype := gen.scalaType(f.Type, f.Items, f.Keys)
+		if f.Optional && f.Default == nil {
+			stype = "Option[" + stype + "]"
+		}
+		gen.emit(fmt.Sprintf("\n  %s: %s", scalaName(string(f.Name)), stype))
+		if f.Default != nil {
+			gen.emit(" = " + gen.literal(f))
+		} else if f.Optional {
+			gen.emit(" = None")
+		}
+		if f.Comment != "" {
+			gen.emit(" /* " + strings.Replace(f.Comment, "*/", "* /", -1) + " */")
+		}
+	}
+	if len(fields) > 0 {
+		gen.emit("\n")
+	}
+	gen.emit(")\n")
+	switch gen.codecs {
+	case "circe":
+		gen.emitCirceStructCodecs(string(st.Name), fields)
+	case "play":
+		gen.emitPlayStructCodecs(string(st.Name), fields)
+	}
+}
+
+// emitCirceStructCodecs emits the encoder, leaving out the fields that are None, and the decoder,
+// filling in the defaults of the fields that are not in the JSON.
+func (gen *scalaModelGenerator) emitCirceStructCodecs(name string, fields []*rdl.StructFieldDef) {
+	gen.emit(fmt.Sprintf("\nobject %s {\n", name))
+	gen.emit(fmt.Sprintf("  import %s._\n\n", gen.helpers))
+	gen.emit(fmt.Sprintf("  implicit lazy val encoder: Encoder[%s] = Encoder.instance { v =>\n", name))
+	gen.emit("    Json.obj(\n")
+	for i, f := range fields {
+		sep := ","
+		if i == len(fields)-1 {
+			sep = ""
+		}
+		gen.emit(fmt.Sprintf("      %q -> v.%s.asJson%s\n", f.Name, scalaName(string(f.Name)), sep))
+	}
+	gen.emit("    ).dropNullValues\n")
+	gen.emit("  }\n\n")
+	gen.emit(fmt.Sprintf("  implicit lazy val decoder: Decoder[%s] = Decoder.instance { c =>\n", name))
+	if len(fields) == 0 {
+		gen.emit(fmt.Sprintf("    c.as[io.circe.JsonObject].map(_ => %s())\n", name))
+	} else {
+		gen.emit("    for {\n")
+		var args []string
+		for i, f := range fields {
+			stype := gen.scalaType(f.Type, f.Items, f.Keys)
+			v := fmt.Sprintf("f%d", i)
+			args = append(args, v)
+			switch {
+			case f.Default != nil:
+				gen.emit(fmt.Sprintf("      %s <- c.downField(%q).as[Option[%s]].map(_.getOrElse(%s))\n", v, f.Name, stype, gen.literal(f)))
+			case f.Optional:
+				gen.emit(fmt.Sprintf("      %s <- c.downField(%q).as[Option[%s]]\n", v, f.Name, stype))
+			default:
+				gen.emit(fmt.Sprintf("      %s <- c.downField(%q).as[%s]\n", v, f.Name, stype))
+			}
+		}
+		gen.emit(fmt.Sprintf("    } yield %s(%s)\n", name, strings.Join(args, ", ")))
+	}
+	gen.emit("  }\n")
+	gen.emit("}\n")
+}
+
+// emitPlayStructCodecs emits the Writes, leaving out the fields that are None, and the Reads,
+// filling in the defaults of the fields that are not in the JSON.
+func (gen *scalaModelGenerator) emitPlayStructCodecs(name string, fields []*rdl.StructFieldDef) {
+	gen.emit(fmt.Sprintf("\nobject %s {\n", name))
+	gen.emit(fmt.Sprintf("  import %s._\n\n", gen.helpers))
+	gen.emit(fmt.Sprintf("  implicit lazy val writes: Writes[%s] = Writes { v =>\n", name))
+	gen.emit("    JsObject(Seq[Option[(String, JsValue)]](\n")
+	for i, f := range fields {
+		sep := ","
+		if i == len(fields)-1 {
+			sep = ""
+		}
+		fname := scalaName(string(f.Name))
+		if f.Optional && f.Default == nil {
+			gen.emit(fmt.Sprintf("      v.%s.map(x => %q -> Json.toJson(x))%s\n", fname, f.Name, sep))
+		} else {
+			gen.emit(fmt.Sprintf("      Some(%q -> Json.toJson(v.%s))%s\n", f.Name, fname, sep))
+		}
+	}
+	gen.emit("    ).flatten)\n")
+	gen.emit("  }\n\n")
+	gen.emit(fmt.Sprintf("  implicit lazy val reads: Reads[%s] = Reads { js =>\n", name))
+	if len(fields) == 0 {
+		gen.emit(fmt.Sprintf("    js.validate[JsObject].map(_ => %s())\n", name))
+	} else {
+		gen.emit("    for {\n")
+		var args []string
+		for i, f := range fields {
+			stype := gen.scalaType(f.Type, f.Items, f.Keys)
+			v := fmt.Sprintf("f%d", i)
+			args = append(args, v)
+			switch {
+			case f.Default != nil:
+				gen.emit(fmt.Sprintf("      %s <- (js \\ %q).validateOpt[%s].map(_.getOrElse(%s))\n", v, f.Name, stype, gen.literal(f)))
+			case f.Optional:
+				gen.emit(fmt.Sprintf("      %s <- (js \\ %q).validateOpt[%s]\n", v, f.Name, stype))
+			default:
+				gen.emit(fmt.Sprintf("      %s <- (js \\ %q).validate[%s]\n", v, f.Name, stype))
+			}
+		}
+		gen.emit(fmt.Sprintf("    } yield %s(%s)\n", name, strings.Join(args, ", ")))
+	}
+	gen.emit("  }\n")
+	gen.emit("}\n")
+}
+
+// emitUnion emits a sealed trait with a case class wrapping each variant. In JSON a union is an
+// object with the name of the variant as its only field.
+func (gen *scalaModelGenerator) emitUnion(ut *rdl.UnionTypeDef) {
+	name := string(ut.Name)
+	gen.emit("\n")
+	gen.emitComment(ut.Comment, "")
+	gen.emit(fmt.Sprintf("sealed trait %s\n", name))
+	gen.emit(fmt.Sprintf("\nobject %s {\n", name))
+	if gen.codecs != "" {
+		gen.emit(fmt.Sprintf("  import %s._\n\n", gen.helpers))
+	}
+	for _, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("  final case class %sVariant(value: %s) extends %s\n", v, gen.scalaType(v, "", ""), name))
+	}
+	switch gen.codecs {
+	case "circe":
+		gen.emit(fmt.Sprintf("\n  implicit lazy val encoder: Encoder[%s] = Encoder.instance {\n", name))
+		for _, v := range ut.Variants {
+			gen.emit(fmt.Sprintf("    case %sVariant(value) => Json.obj(%q -> value.asJson)\n", v, v))
+		}
+		gen.emit("  }\n\n")
+		gen.emit(fmt.Sprintf("  implicit lazy val decoder: Decoder[%s] = Decoder.instance { c =>\n", name))
+		gen.emit("    c.keys.flatMap(_.headOption) match {\n")
+		for _, v := range ut.Variants {
+			gen.emit(fmt.Sprintf("      case Some(%q) => c.downField(%q).as[%s].map(%sVariant(_))\n", v, v, gen.scalaType(v, "", ""), v))
+		}
+		gen.emit(fmt.Sprintf("      case _ => Left(DecodingFailure(\"%s: no valid variant present\", c.history))\n", name))
+		gen.emit("    }\n")
+		gen.emit("  }\n")
+	case "play":
+		gen.emit(fmt.Sprintf("\n  implicit lazy val writes: Writes[%s] = Writes {\n", name))
+		for _, v := range ut.Variants {
+			gen.emit(fmt.Sprintf("    case %sVariant(value) => Json.obj(%q -> Json.toJson(value))\n", v, v))
+		}
+		gen.emit("  }\n\n")
+		gen.emit(fmt.Sprintf("  implicit lazy val reads: Reads[%s] = Reads { js =>\n", name))
+		gen.emit("    js.validate[JsObject].map(_.keys.headOption).flatMap {\n")
+		for _, v := range ut.Variants {
+			gen.emit(fmt.Sprintf("      case Some(%q) => (js \\ %q).validate[%s].map(%sVariant(_))\n", v, v, gen.scalaType(v, "", ""), v))
+		}
+		gen.emit(fmt.Sprintf("      case _ => JsError(\"%s: no valid variant present\")\n", name))
+		gen.emit("    }\n")
+		gen.emit("  }\n")
+	}
+	gen.emit("}\n")
+}
+
+// emitEnum emits a sealed class with a case object for each symbol, written in JSON as its wire
+// name, and read from it, its symbol, or an alias.
+func (gen *scalaModelGenerator) emitEnum(et *rdl.EnumTypeDef) {
+	name := string(et.Name)
+	gen.emit("\n")
+	gen.emitComment(et.Comment, "")
+	gen.emit(fmt.Sprintf("sealed abstract class %s(val value: String) {\n  override def toString: String = value\n}\n", name))
+	gen.emit(fmt.Sprintf("\nobject %s {\n", name))
+	var symbols []string
+	for _, elem := range et.Elements {
+		sym := scalaName(string(elem.Symbol))
+		symbols = append(symbols, sym)
+		gen.emit(fmt.Sprintf("  case object %s extends %s(%q)", sym, name, enumWireName(elem)))
+		if elem.Comment != "" {
+			gen.emit(" // " + elem.Comment)
+		}
+		gen.emit("\n")
+	}
+	gen.emit(fmt.Sprintf("\n  val values: Seq[%s] = Seq(%s)\n\n", name, strings.Join(symbols, ", ")))
+	var read []string
+	for i, elem := range et.Elements {
+		for _, r := range enumReadNames(elem) {
+			read = append(read, fmt.Sprintf("%q -> %s", r, symbols[i]))
+		}
+	}
+	if len(read) > 0 {
+		gen.emit(fmt.Sprintf("  private val read: Map[String, %s] = Map(%s)\n\n", name, strings.Join(read, ", ")))
+		gen.emit(fmt.Sprintf("  def fromString(s: String): Option[%s] = values.find(_.value == s).orElse(read.get(s))\n", name))
+	} else {
+		gen.emit(fmt.Sprintf("  def fromString(s: String): Option[%s] = values.find(_.value == s)\n", name))
+	}
+	switch gen.codecs {
+	case "circe":
+		gen.emit(fmt.Sprintf("\n  implicit val encoder: Encoder[%s] = Encoder.encodeString.contramap(_.value)\n", name))
+		gen.emit(fmt.Sprintf("  implicit val decoder: Decoder[%s] = Decoder.decodeString.emap(s => fromString(s).toRight(\"%s: invalid value \" + s))\n", name, name))
+	case "play":
+		gen.emit(fmt.Sprintf("\n  implicit val writes: Writes[%s] = Writes(e => JsString(e.value))\n", name))
+		gen.emit(fmt.Sprintf("  implicit val reads: Reads[%s] = Reads(js => js.validate[String].flatMap(s => fromString(s).fold[JsResult[%s]](JsError(\"%s: invalid value \" + s))(JsSuccess(_))))\n", name, name, name))
+	}
+	gen.emit("}\n")
+}
+
+// enumWireName returns the string the enum element is written as: its x_wire annotation, or else
+// its symbol.
+func enumWireName(elem *rdl.EnumElementDef) string {
+	if wire := strings.TrimSpace(elem.Annotations["x_wire"]); wire != "" {
+		return wire
+	}
+	return string(elem.Symbol)
+}
+
+// enumReadNames returns the strings the enum element is read from besides its wire name: its
+// symbol, if it is written as another one, and its x_aliases.
+func enumReadNames(elem *rdl.EnumElementDef) []string {
+	var names []string
+	if enumWireName(elem) != string(elem.Symbol) {
+		names = append(names, string(elem.Symbol))
+	}
+	for _, alias := range strings.Split(elem.Annotations["x_aliases"], ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
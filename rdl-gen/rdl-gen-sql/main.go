@@ -0,0 +1,403 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate the SQL DDL (CREATE TABLE and CREATE INDEX statements) for the struct types of an
+// RDL schema that are annotated as tables, for postgres or mysql
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	pDialect := flag.String("dialect", "postgres", "The SQL dialect: postgres or mysql")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			err = ExportToSQL(&schema, *pOutdir, *pDialect)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+type sqlGenerator struct {
+	registry rdl.TypeRegistry
+	schema   *rdl.Schema
+	writer   *bufio.Writer
+	err      error
+	dialect  string
+}
+
+// sqlColumn is a column of a table, from a field of the struct.
+type sqlColumn struct {
+	name    string
+	sqlType string
+	notNull bool
+	def     string
+	primary bool
+	unique  bool
+	index   bool
+}
+
+// ExportToSQL generates a SQL file with a CREATE TABLE statement for each struct type of the
+// schema annotated with x_db_table, the name of its table. Each field, inherited ones first, is
+// a column named as the field, with the SQL type of the dialect for its RDL type, NOT NULL unless
+// it is optional, and the DEFAULT of the field. Arrays, maps, structs, unions, and Any are JSON
+// columns. The x_db annotation of a field is a comma-separated list of primary (the primary key,
+// of one or more columns), unique, and index (a CREATE INDEX for the column), x_db_column sets
+// the name of the column, and x_db_type its SQL type, e.g.
+//
+//	type Contact Struct (x_db_table="contacts") {
+//	    String id (x_db="primary");
+//	    String email (x_db="unique", x_db_column="email_address");
+//	    Timestamp modified (x_db="index");
+//	    Array<String> tags (optional);
+//	}
+func ExportToSQL(schema *rdl.Schema, outdir string, dialect string) error {
+	switch dialect {
+	case "postgres", "mysql":
+	default:
+		return fmt.Errorf("Unsupported SQL dialect '%s' (expected postgres or mysql)", dialect)
+	}
+	name := "anonymous"
+	if schema.Name != "" {
+		name = string(schema.Name)
+	}
+	out, file, _, err := outputWriter(outdir, name, ".sql")
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	gen := &sqlGenerator{rdl.NewTypeRegistry(schema), schema, out, nil, dialect}
+	gen.emit(fmt.Sprintf("--\n-- This file generated by rdl-gen-sql (%s). Do not modify!\n--\n", dialect))
+	for _, t := range schema.Types {
+		if t.Variant != rdl.TypeVariantStructTypeDef {
+			continue
+		}
+		if table, ok := t.StructTypeDef.Annotations["x_db_table"]; ok {
+			gen.emitTable(t, table)
+		}
+	}
+	out.Flush()
+	return gen.err
+}
+
+func (gen *sqlGenerator) emit(s string) {
+	if gen.err == nil {
+		_, err := gen.writer.WriteString(s)
+		if err != nil {
+			gen.err = err
+		}
+	}
+}
+
+// quote returns the identifier quoted for the dialect, so that it keeps the case of the field and
+// may be a keyword, e.g. "default".
+func (gen *sqlGenerator) quote(name string) string {
+	if gen.dialect == "mysql" {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+	return "\"" + strings.Replace(name, "\"", "\"\"", -1) + "\""
+}
+
+func sqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func (gen *sqlGenerator) emitTable(t *rdl.Type, table string) {
+	st := t.StructTypeDef
+	if table == "" {
+		gen.err = fmt.Errorf("Missing table name of x_db_table for type '%s'", st.Name)
+		return
+	}
+	var columns []*sqlColumn
+	var primary []string
+	for _, f := range flattenedFields(gen.registry, t) {
+		c := gen.column(f)
+		if gen.err != nil {
+			return
+		}
+		if c.primary {
+			primary = append(primary, gen.quote(c.name))
+		}
+		columns = append(columns, c)
+	}
+	gen.emit("\n")
+	if st.Comment != "" {
+		gen.emit(fmt.Sprintf("-- %s\n", strings.Replace(st.Comment, "\n", "\n-- ", -1)))
+	}
+	gen.emit(fmt.Sprintf("CREATE TABLE %s (", gen.quote(table)))
+	for i, c := range columns {
+		if i > 0 {
+			gen.emit(",")
+		}
+		gen.emit(fmt.Sprintf("\n  %s %s", gen.quote(c.name), c.sqlType))
+		if c.notNull {
+			gen.emit(" NOT NULL")
+		}
+		if c.def != "" {
+			gen.emit(" DEFAULT " + c.def)
+		}
+		if c.unique {
+			gen.emit(" UNIQUE")
+		}
+	}
+	if len(primary) > 0 {
+		gen.emit(fmt.Sprintf(",\n  PRIMARY KEY (%s)", strings.Join(primary, ", ")))
+	}
+	gen.emit("\n);\n")
+	for _, c := range columns {
+		if c.index {
+			index := gen.quote(table + "_" + c.name + "_idx")
+			gen.emit(fmt.Sprintf("CREATE INDEX %s ON %s (%s);\n", index, gen.quote(table), gen.quote(c.name)))
+		}
+	}
+}
+
+// column returns the column of the field, from its type and its x_db annotations.
+func (gen *sqlGenerator) column(f *rdl.StructFieldDef) *sqlColumn {
+	c := &sqlColumn{name: string(f.Name), notNull: !f.Optional}
+	if name, ok := f.Annotations["x_db_column"]; ok && name != "" {
+		c.name = name
+	}
+	if options, ok := f.Annotations["x_db"]; ok {
+		for _, option := range strings.Split(options, ",") {
+			switch strings.TrimSpace(option) {
+			case "primary":
+				c.primary = true
+				c.notNull = true
+			case "unique":
+				c.unique = true
+			case "index":
+				c.index = true
+			case "":
+			default:
+				gen.err = fmt.Errorf("Unknown x_db option '%s' for field '%s' (expected primary, unique, or index)", option, f.Name)
+				return c
+			}
+		}
+	}
+	if f.Default != nil {
+		c.def = gen.literal(f)
+	}
+	if sqlType, ok := f.Annotations["x_db_type"]; ok && sqlType != "" {
+		c.sqlType = sqlType
+	} else {
+		c.sqlType = gen.sqlType(f.Type, c)
+	}
+	return c
+}
+
+// sqlType returns the SQL type of the column for the RDL type.
+func (gen *sqlGenerator) sqlType(tref rdl.TypeRef, c *sqlColumn) string {
+	t := gen.registry.FindType(tref)
+	if t == nil {
+		gen.err = fmt.Errorf("Cannot find type '%s'", tref)
+		return ""
+	}
+	mysql := gen.dialect == "mysql"
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeBool:
+		return "BOOLEAN"
+	case rdl.BaseTypeInt8:
+		if mysql {
+			return "TINYINT"
+		}
+		return "SMALLINT"
+	case rdl.BaseTypeInt16:
+		return "SMALLINT"
+	case rdl.BaseTypeInt32:
+		if mysql {
+			return "INT"
+		}
+		return "INTEGER"
+	case rdl.BaseTypeInt64:
+		return "BIGINT"
+	case rdl.BaseTypeFloat32:
+		if mysql {
+			return "FLOAT"
+		}
+		return "REAL"
+	case rdl.BaseTypeFloat64:
+		if mysql {
+			return "DOUBLE"
+		}
+		return "DOUBLE PRECISION"
+	case rdl.BaseTypeString, rdl.BaseTypeSymbol:
+		if maxSize := gen.maxSize(t); maxSize != nil {
+			return fmt.Sprintf("VARCHAR(%d)", *maxSize)
+		}
+		if mysql && (c.primary || c.unique || c.index || c.def != "") {
+			//mysql cannot index a TEXT without a prefix length, nor give it a default
+			return "VARCHAR(255)"
+		}
+		return "TEXT"
+	case rdl.BaseTypeUUID:
+		if mysql {
+			return "CHAR(36)"
+		}
+		return "UUID"
+	case rdl.BaseTypeTimestamp:
+		if mysql {
+			return "DATETIME(3)"
+		}
+		return "TIMESTAMP WITH TIME ZONE"
+	case rdl.BaseTypeBytes:
+		if mysql {
+			if maxSize := gen.maxSize(t); maxSize != nil {
+				return fmt.Sprintf("VARBINARY(%d)", *maxSize)
+			}
+			return "BLOB"
+		}
+		return "BYTEA"
+	case rdl.BaseTypeEnum:
+		var symbols []string
+		for _, elem := range gen.enumElements(t) {
+			symbols = append(symbols, sqlString(string(elem.Symbol)))
+		}
+		if mysql {
+			return "ENUM(" + strings.Join(symbols, ", ") + ")"
+		}
+		return fmt.Sprintf("TEXT CHECK (%s IN (%s))", gen.quote(c.name), strings.Join(symbols, ", "))
+	default: //Array, Map, Struct, Union, Any
+		if mysql {
+			return "JSON"
+		}
+		return "JSONB"
+	}
+}
+
+// maxSize returns the maxSize constraint of the string or bytes type, its own or inherited, if any.
+func (gen *sqlGenerator) maxSize(t *rdl.Type) *int32 {
+	for t != nil {
+		var super rdl.TypeRef
+		switch t.Variant {
+		case rdl.TypeVariantStringTypeDef:
+			if t.StringTypeDef.MaxSize != nil {
+				return t.StringTypeDef.MaxSize
+			}
+			if rdl.TypeRef(t.StringTypeDef.Name) == t.StringTypeDef.Type {
+				return nil
+			}
+			super = t.StringTypeDef.Type
+		case rdl.TypeVariantBytesTypeDef:
+			if t.BytesTypeDef.MaxSize != nil {
+				return t.BytesTypeDef.MaxSize
+			}
+			if rdl.TypeRef(t.BytesTypeDef.Name) == t.BytesTypeDef.Type {
+				return nil
+			}
+			super = t.BytesTypeDef.Type
+		case rdl.TypeVariantAliasTypeDef:
+			if rdl.TypeRef(t.AliasTypeDef.Name) == t.AliasTypeDef.Type {
+				return nil
+			}
+			super = t.AliasTypeDef.Type
+		default:
+			return nil
+		}
+		t = gen.registry.FindType(super)
+	}
+	return nil
+}
+
+// enumElements returns the symbols of the enum type, or of the one it is an alias of.
+func (gen *sqlGenerator) enumElements(t *rdl.Type) []*rdl.EnumElementDef {
+	for t != nil {
+		switch t.Variant {
+		case rdl.TypeVariantEnumTypeDef:
+			return t.EnumTypeDef.Elements
+		case rdl.TypeVariantAliasTypeDef:
+			if rdl.TypeRef(t.AliasTypeDef.Name) == t.AliasTypeDef.Type {
+				return nil
+			}
+			t = gen.registry.FindType(t.AliasTypeDef.Type)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// literal returns the SQL literal of the default value of the field.
+func (gen *sqlGenerator) literal(f *rdl.StructFieldDef) string {
+	switch v := f.Default.(type) {
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case string:
+		return sqlString(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return sqlString(fmt.Sprintf("%v", f.Default))
+}
@@ -24,14 +24,20 @@ func main() {
 	pOutdir := flag.String("o", ".", "Output directory")
 	flag.String("s", "", "RDL source file")
 	basePath := flag.String("b", "/api", "Base path")
+	security := &swaggerSecurity{}
+	flag.StringVar(&security.Scheme, "security", "apikey", "Security scheme of the resources that authenticate or authorize: apikey or oauth2")
+	flag.StringVar(&security.Header, "security-header", "Authorization", "Header carrying the credentials of the apikey security scheme")
+	flag.StringVar(&security.OAuth2URL, "oauth2-url", "", "Authorization URL of the oauth2 security scheme")
 	flag.Parse()
 	data, err := ioutil.ReadAll(os.Stdin)
 	if err == nil {
 		var schema rdl.Schema
 		err = json.Unmarshal(data, &schema)
 		if err == nil {
-			ExportToSwagger(&schema, *pOutdir, *basePath)
-			os.Exit(0)
+			err = ExportToSwagger(&schema, *pOutdir, *basePath, security)
+			if err == nil {
+				os.Exit(0)
+			}
 		}
 	}
 	fmt.Fprintf(os.Stderr, "*** %v\n", err)
@@ -66,9 +72,9 @@ func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.Fi
 
 // ExportToSwagger exports the RDL schema to Swagger 2.0 format,
 //   and serves it up on the specified server endpoint is provided, or outputs to stdout otherwise.
-func ExportToSwagger(schema *rdl.Schema, outdir string, basePath string) error {
+func ExportToSwagger(schema *rdl.Schema, outdir string, basePath string, security *swaggerSecurity) error {
 	sname := string(schema.Name)
-	swaggerData, err := swagger(schema, basePath)
+	swaggerData, err := swagger(schema, basePath, security)
 	if err != nil {
 		return err
 	}
@@ -115,7 +121,7 @@ func ExportToSwagger(schema *rdl.Schema, outdir string, basePath string) error {
 	return http.ListenAndServe(outdir, nil)
 }
 
-func swagger(schema *rdl.Schema, basePath string) (*SwaggerDoc, error) {
+func swagger(schema *rdl.Schema, basePath string, security *swaggerSecurity) (*SwaggerDoc, error) {
 	reg := rdl.NewTypeRegistry(schema)
 	sname := string(schema.Name)
 	swag := new(SwaggerDoc)
@@ -138,8 +144,16 @@ func swagger(schema *rdl.Schema, basePath string) (*SwaggerDoc, error) {
 	if schema.Comment != "" {
 		swag.Info.Description = schema.Comment
 	}
+	swag.ExternalDocs = externalDocs(schema.Annotations)
 	if len(schema.Resources) > 0 {
+		defs, err := security.definitions(schema)
+		if err != nil {
+			return nil, err
+		}
+		swag.SecurityDefinitions = defs
 		paths := make(map[string]map[string]*SwaggerAction)
+		ids := operationIDs(schema.Resources)
+		tagged := make(map[string]bool)
 		for _, r := range schema.Resources {
 			path := r.Path
 			actions, ok := paths[path]
@@ -153,12 +167,20 @@ func swagger(schema *rdl.Schema, basePath string) (*SwaggerDoc, error) {
 				action = new(SwaggerAction)
 			}
 			action.Summary = r.Comment
-			tag := string(r.Type)       //fixme: RDL has no tags, the type is actually too fine grain for this
-			action.Tags = []string{tag} //multiple tags include the resource in multiple sections
+			_, action.Deprecated = r.Annotations["x_deprecated"]
+			action.Tags = resourceTags(r) //multiple tags include the resource in multiple sections
+			for _, tag := range action.Tags {
+				if !tagged[tag] {
+					tagged[tag] = true
+					swag.Tags = append(swag.Tags, &SwaggerTag{Name: tag})
+				}
+			}
+			action.OperationID = ids[r]
+			action.ExternalDocs = externalDocs(r.Annotations)
 			action.Produces = []string{"application/json"}
 			var ins []*SwaggerParameter
 			if len(r.Inputs) > 0 {
-				if r.Method == "POST" || r.Method == "PUT" {
+				if r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH" {
 					action.Consumes = []string{"application/json"}
 				}
 				for _, in := range r.Inputs {
@@ -185,6 +207,22 @@ func swagger(schema *rdl.Schema, basePath string) (*SwaggerDoc, error) {
 					param.Type = ptype
 					param.Format = pformat
 					param.Schema = ref
+					if param.In != "body" {
+						//only a body parameter has a schema, the others carry the constraints themselves
+						c := swaggerConstraints(reg, in.Type, in.Annotations)
+						if c.Enum != nil {
+							param.Type = "string"
+							param.Schema = nil
+						}
+						param.Pattern = c.Pattern
+						param.MinLength = c.MinLength
+						param.MaxLength = c.MaxLength
+						param.Minimum = c.Minimum
+						param.Maximum = c.Maximum
+						param.Enum = c.Enum
+						param.Example = c.Example
+						param.Default = enumWireDefault(reg, in.Type, in.Default)
+					}
 
 					if strings.Contains(in.QueryParam, "[]") {
 						param.CollectionFormat = "multi"
@@ -209,11 +247,10 @@ func swagger(schema *rdl.Schema, basePath string) (*SwaggerDoc, error) {
 				}
 			}
 			action.Responses = responses
+			action.Security, action.Authorize = security.requirement(r)
 			//responses -> r.expected and r.exceptions
-			//security -> r.auth
 			//r.outputs?
 			//action.description?
-			//action.operationId IGNORE
 
 			actions[meth] = action
 			paths[path] = actions
@@ -222,9 +259,14 @@ func swagger(schema *rdl.Schema, basePath string) (*SwaggerDoc, error) {
 	}
 	if len(schema.Types) > 0 {
 		defs := make(map[string]*SwaggerType)
+		generic := genericTypes(schema)
 		for _, t := range schema.Types {
+			if tName, _, _ := rdl.TypeInfo(t); generic[tName] {
+				continue
+			}
 			ref := makeSwaggerTypeDef(reg, t)
 			if ref != nil {
+				ref.ExternalDocs = externalDocs(typeAnnotations(t))
 				tName, _, _ := rdl.TypeInfo(t)
 				defs[string(tName)] = ref
 			}
@@ -251,6 +293,27 @@ func swagger(schema *rdl.Schema, basePath string) (*SwaggerDoc, error) {
 	return swag, nil
 }
 
+// genericTypes returns the generic struct types of the schema, those with x_type_params, and
+// their type parameters. Only the instantiations of a generic type, expanded to plain structs
+// as the schema is parsed, are defined.
+func genericTypes(schema *rdl.Schema) map[rdl.TypeName]bool {
+	generic := make(map[rdl.TypeName]bool)
+	for _, t := range schema.Types {
+		if t.Variant != rdl.TypeVariantStructTypeDef {
+			continue
+		}
+		params, ok := t.StructTypeDef.Annotations["x_type_params"]
+		if !ok {
+			continue
+		}
+		generic[t.StructTypeDef.Name] = true
+		for _, p := range strings.Split(params, ",") {
+			generic[rdl.TypeName(strings.TrimSpace(p))] = true
+		}
+	}
+	return generic
+}
+
 func addSwaggerResponse(responses map[string]*SwaggerResponse, errType string, sym string, errComment string) {
 	code := rdl.StatusCode(sym)
 	var schema *SwaggerType
@@ -267,11 +330,11 @@ func addSwaggerResponse(responses map[string]*SwaggerResponse, errType string, s
 
 func makeSwaggerTypeRef(reg rdl.TypeRegistry, itemTypeName rdl.TypeRef) (string, string, *SwaggerType) {
 	itype := string(itemTypeName)
-	switch reg.FindBaseType(itemTypeName) {
+	switch bt := reg.FindBaseType(itemTypeName); bt {
 	case rdl.BaseTypeInt8:
 		return "string", "byte", nil
 	case rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
-		return "integer", strings.ToLower(itype), nil
+		return "integer", strings.ToLower(bt.String()), nil
 	case rdl.BaseTypeFloat32:
 		return "number", "float", nil
 	case rdl.BaseTypeFloat64:
@@ -280,6 +343,8 @@ func makeSwaggerTypeRef(reg rdl.TypeRegistry, itemTypeName rdl.TypeRef) (string,
 		return "string", "", nil
 	case rdl.BaseTypeTimestamp:
 		return "string", "date-time", nil
+	case rdl.BaseTypeBytes:
+		return "string", "byte", nil
 	case rdl.BaseTypeUUID, rdl.BaseTypeSymbol:
 		return "string", strings.ToLower(itype), nil
 	default:
@@ -296,6 +361,7 @@ func makeSwaggerTypeDef(reg rdl.TypeRegistry, t *rdl.Type) *SwaggerType {
 	case rdl.TypeVariantStructTypeDef:
 		typedef := t.StructTypeDef
 		st.Description = typedef.Comment
+		_, st.Deprecated = typedef.Annotations["x_deprecated"]
 		props := make(map[string]*SwaggerType)
 		var required []string
 		if len(typedef.Fields) > 0 {
@@ -305,8 +371,15 @@ func makeSwaggerTypeDef(reg rdl.TypeRegistry, t *rdl.Type) *SwaggerType {
 				}
 				ft := reg.FindType(f.Type)
 				fbt := reg.BaseType(ft)
-				prop := new(SwaggerType)
+				prop := swaggerConstraints(reg, f.Type, f.Annotations)
 				prop.Description = f.Comment
+				//swagger 2.0 only deprecates operations, so schemas and properties carry an extension
+				_, prop.Deprecated = f.Annotations["x_deprecated"]
+				prop.Default = enumWireDefault(reg, f.Type, f.Default)
+				//a nullable field may be sent as an explicit null, which swagger 2.0 has no way to say
+				if v, ok := f.Annotations["x_nullable"]; ok && v != "false" {
+					prop.Nullable = true
+				}
 				switch fbt {
 				case rdl.BaseTypeArray:
 					prop.Type = "array"
@@ -323,17 +396,34 @@ func makeSwaggerTypeDef(reg rdl.TypeRegistry, t *rdl.Type) *SwaggerType {
 							items.Type = "integer"
 							items.Format = strings.ToLower(fitems)
 						default:
-							items.Ref = "#/definitions/" + fitems
+							if reg.FindBaseType(f.Items) == rdl.BaseTypeBytes {
+								items.Type, items.Format = "string", "byte"
+							} else {
+								items.Ref = "#/definitions/" + fitems
+							}
 						}
 						prop.Items = items
 					}
 				case rdl.BaseTypeString:
 					prop.Type = strings.ToLower(fbt.String())
+				case rdl.BaseTypeBytes:
+					//base64, as the JSON of the generated models
+					prop.Type = "string"
+					prop.Format = "byte"
 				case rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeInt16:
 					prop.Type = "integer"
 					prop.Format = strings.ToLower(fbt.String())
-				case rdl.BaseTypeStruct:
+				case rdl.BaseTypeFloat32:
+					prop.Type = "number"
+					prop.Format = "float"
+				case rdl.BaseTypeFloat64:
+					prop.Type = "number"
+					prop.Format = "double"
+				case rdl.BaseTypeBool:
+					prop.Type = "boolean"
+				case rdl.BaseTypeStruct, rdl.BaseTypeEnum:
 					prop.Ref = "#/definitions/" + string(f.Type)
+					prop.Enum = nil
 				case rdl.BaseTypeMap:
 					prop.Type = "object"
 					if f.Items != "" {
@@ -346,7 +436,11 @@ func makeSwaggerTypeDef(reg rdl.TypeRegistry, t *rdl.Type) *SwaggerType {
 							items.Type = "integer"
 							items.Format = strings.ToLower(fitems)
 						default:
-							items.Ref = "#/definitions/" + fitems
+							if reg.FindBaseType(f.Items) == rdl.BaseTypeBytes {
+								items.Type, items.Format = "string", "byte"
+							} else {
+								items.Ref = "#/definitions/" + fitems
+							}
 						}
 						prop.AdditionalProperties = items
 					}
@@ -363,6 +457,10 @@ func makeSwaggerTypeDef(reg rdl.TypeRegistry, t *rdl.Type) *SwaggerType {
 	case rdl.TypeVariantArrayTypeDef:
 		typedef := t.ArrayTypeDef
 		st.Type = bt.String()
+		st.MinItems, st.MaxItems = typedef.MinSize, typedef.MaxSize
+		if typedef.Size != nil {
+			st.MinItems, st.MaxItems = typedef.Size, typedef.Size
+		}
 		if typedef.Items != "Any" {
 			items := new(SwaggerType)
 			switch reg.FindBaseType(typedef.Items) {
@@ -371,6 +469,8 @@ func makeSwaggerTypeDef(reg rdl.TypeRegistry, t *rdl.Type) *SwaggerType {
 			case rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeInt16:
 				items.Type = "integer"
 				items.Format = strings.ToLower(string(typedef.Items))
+			case rdl.BaseTypeBytes:
+				items.Type, items.Format = "string", "byte"
 			default:
 				items.Ref = "#/definitions/" + string(typedef.Items)
 			}
@@ -380,15 +480,22 @@ func makeSwaggerTypeDef(reg rdl.TypeRegistry, t *rdl.Type) *SwaggerType {
 		typedef := t.EnumTypeDef
 		var tmp []string
 		for _, el := range typedef.Elements {
-			tmp = append(tmp, string(el.Symbol))
+			tmp = append(tmp, enumWireName(el))
+			if _, ok := el.Annotations["x_deprecated"]; ok {
+				st.DeprecatedValues = append(st.DeprecatedValues, enumWireName(el))
+			}
 		}
+		st.Type = "string"
+		st.Description = typedef.Comment
 		st.Enum = tmp
+		_, st.Deprecated = typedef.Annotations["x_deprecated"]
+		st.Example = swaggerExample(typedef.Annotations)
 	case rdl.TypeVariantUnionTypeDef:
 		typedef := t.UnionTypeDef
 		fmt.Println("[" + typedef.Name + ": Swagger doesn't support unions]")
 	default:
 		switch bt {
-		case rdl.BaseTypeString, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		case rdl.BaseTypeString, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64, rdl.BaseTypeBytes:
 			return nil
 		default:
 			panic(fmt.Sprintf("whoops: %v", t))
@@ -397,16 +504,311 @@ func makeSwaggerTypeDef(reg rdl.TypeRegistry, t *rdl.Type) *SwaggerType {
 	return st
 }
 
+// swaggerConstraints returns a SwaggerType with the validation keywords for the RDL type, i.e.
+// the pattern, values, sizes, and range of a string or numeric type, and the sizes of a bytes
+// type, as the lengths of its base64 string, including those inherited from the types it is
+// derived from, the symbols of an enum, and the example given by the x_example annotation of
+// the field or parameter, or else of the type.
+func swaggerConstraints(reg rdl.TypeRegistry, tref rdl.TypeRef, annotations map[rdl.ExtendedAnnotation]string) *SwaggerType {
+	c := new(SwaggerType)
+	c.Example = swaggerExample(annotations)
+	t := reg.FindType(tref)
+	for t != nil {
+		tName, tType, _ := rdl.TypeInfo(t)
+		if rdl.TypeRef(tName) == tType {
+			break
+		}
+		switch t.Variant {
+		case rdl.TypeVariantStringTypeDef:
+			td := t.StringTypeDef
+			if c.Pattern == "" {
+				c.Pattern = td.Pattern
+			}
+			if c.Enum == nil {
+				c.Enum = td.Values
+			}
+			if c.MinLength == nil {
+				c.MinLength = td.MinSize
+			}
+			if c.MaxLength == nil {
+				c.MaxLength = td.MaxSize
+			}
+			if c.Example == nil {
+				c.Example = swaggerExample(td.Annotations)
+			}
+		case rdl.TypeVariantBytesTypeDef:
+			td := t.BytesTypeDef
+			minSize, maxSize := td.MinSize, td.MaxSize
+			if td.Size != nil {
+				minSize, maxSize = td.Size, td.Size
+			}
+			if c.MinLength == nil {
+				c.MinLength = base64Length(minSize)
+			}
+			if c.MaxLength == nil {
+				c.MaxLength = base64Length(maxSize)
+			}
+		case rdl.TypeVariantNumberTypeDef:
+			td := t.NumberTypeDef
+			if c.Minimum == nil && td.Min != nil {
+				c.Minimum = swaggerNumber(*td.Min)
+			}
+			if c.Maximum == nil && td.Max != nil {
+				c.Maximum = swaggerNumber(*td.Max)
+			}
+			if c.Example == nil {
+				c.Example = swaggerExample(td.Annotations)
+			}
+		case rdl.TypeVariantAliasTypeDef:
+			if c.Example == nil {
+				c.Example = swaggerExample(t.AliasTypeDef.Annotations)
+			}
+		case rdl.TypeVariantEnumTypeDef:
+			if c.Enum == nil {
+				for _, el := range t.EnumTypeDef.Elements {
+					c.Enum = append(c.Enum, enumWireName(el))
+				}
+			}
+		}
+		t = reg.FindType(tType)
+	}
+	return c
+}
+
+// base64Length returns the length of the padded base64 string of that many bytes.
+func base64Length(size *int32) *int32 {
+	if size == nil {
+		return nil
+	}
+	n := (*size + 2) / 3 * 4
+	return &n
+}
+
+// swaggerExample returns the value of the x_example annotation, as JSON if it parses as such
+// (e.g. x_example="42"), or else as a string.
+func swaggerExample(annotations map[rdl.ExtendedAnnotation]string) interface{} {
+	example, ok := annotations["x_example"]
+	if !ok {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(example), &value); err == nil {
+		return value
+	}
+	return example
+}
+
+// resourceTags returns the tags grouping the operation in Swagger UI: those of the x_tags (or
+// x_tag) annotation of the resource, or else the first segment of its path, e.g. contacts for
+// /contacts/{name}, or else its type.
+func resourceTags(r *rdl.Resource) []string {
+	annotation, ok := r.Annotations["x_tags"]
+	if !ok {
+		annotation = r.Annotations["x_tag"]
+	}
+	var tags []string
+	for _, tag := range strings.Split(annotation, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		segment := strings.SplitN(strings.TrimPrefix(r.Path, "/"), "/", 2)[0]
+		if i := strings.IndexAny(segment, "?{"); i >= 0 {
+			segment = segment[:i]
+		}
+		if segment == "" {
+			segment = string(r.Type)
+		}
+		tags = []string{segment}
+	}
+	return tags
+}
+
+// operationIDs returns the operationIds of the resources: their names, or else their method and
+// type, e.g. getContact, as go-client and java-client name their methods. A name taken by an
+// earlier resource gets a number, e.g. getContact2, so that they are unique and stable.
+func operationIDs(resources []*rdl.Resource) map[*rdl.Resource]string {
+	ids := make(map[*rdl.Resource]string, len(resources))
+	taken := make(map[string]bool, len(resources))
+	for _, r := range resources {
+		id := string(r.Name)
+		if id == "" {
+			id = strings.ToLower(r.Method) + strings.Replace(string(r.Type), ".", "_", -1)
+		}
+		unique := id
+		for n := 2; taken[unique]; n++ {
+			unique = fmt.Sprintf("%s%d", id, n)
+		}
+		taken[unique] = true
+		ids[r] = unique
+	}
+	return ids
+}
+
+// externalDocs returns the link of the x_docs annotation, e.g. x_docs="https://example.com/doc",
+// or nil if there is none.
+func externalDocs(annotations map[rdl.ExtendedAnnotation]string) *SwaggerExternalDocs {
+	if url := strings.TrimSpace(annotations["x_docs"]); url != "" {
+		return &SwaggerExternalDocs{URL: url}
+	}
+	return nil
+}
+
+// typeAnnotations returns the extended annotations of the type, whatever its variant.
+func typeAnnotations(t *rdl.Type) map[rdl.ExtendedAnnotation]string {
+	switch t.Variant {
+	case rdl.TypeVariantAliasTypeDef:
+		return t.AliasTypeDef.Annotations
+	case rdl.TypeVariantStringTypeDef:
+		return t.StringTypeDef.Annotations
+	case rdl.TypeVariantNumberTypeDef:
+		return t.NumberTypeDef.Annotations
+	case rdl.TypeVariantArrayTypeDef:
+		return t.ArrayTypeDef.Annotations
+	case rdl.TypeVariantMapTypeDef:
+		return t.MapTypeDef.Annotations
+	case rdl.TypeVariantStructTypeDef:
+		return t.StructTypeDef.Annotations
+	case rdl.TypeVariantEnumTypeDef:
+		return t.EnumTypeDef.Annotations
+	case rdl.TypeVariantUnionTypeDef:
+		return t.UnionTypeDef.Annotations
+	}
+	return nil
+}
+
+func swaggerNumber(n rdl.Number) *float64 {
+	var f float64
+	switch n.Variant {
+	case rdl.NumberVariantInt8:
+		f = float64(*n.Int8)
+	case rdl.NumberVariantInt16:
+		f = float64(*n.Int16)
+	case rdl.NumberVariantInt32:
+		f = float64(*n.Int32)
+	case rdl.NumberVariantInt64:
+		f = float64(*n.Int64)
+	case rdl.NumberVariantFloat32:
+		f = float64(*n.Float32)
+	case rdl.NumberVariantFloat64:
+		f = *n.Float64
+	}
+	return &f
+}
+
+// swaggerSecurity is how the authenticate and authorize declarations of the resources are
+// described: as an apiKey in a header, or as oauth2 scopes named after the action and resource
+// of the authorize declaration, e.g. "read:things.{id}".
+type swaggerSecurity struct {
+	Scheme    string
+	Header    string
+	OAuth2URL string
+}
+
+func swaggerScope(auth *rdl.ResourceAuth) string {
+	return auth.Action + ":" + auth.Resource
+}
+
+// definitions returns the securityDefinitions of the schema, nil if no resource needs credentials.
+func (sec *swaggerSecurity) definitions(schema *rdl.Schema) (map[string]*SwaggerSecurityScheme, error) {
+	scopes := make(map[string]string)
+	secured := false
+	for _, r := range schema.Resources {
+		if r.Auth == nil {
+			continue
+		}
+		if r.Auth.Action != "" {
+			scopes[swaggerScope(r.Auth)] = "authorize " + r.Auth.Action + " on " + r.Auth.Resource
+			secured = true
+		} else if r.Auth.Authenticate {
+			secured = true
+		}
+	}
+	if !secured {
+		return nil, nil
+	}
+	switch sec.Scheme {
+	case "apikey":
+		return map[string]*SwaggerSecurityScheme{
+			"apiKey": {Type: "apiKey", Name: sec.Header, In: "header"},
+		}, nil
+	case "oauth2":
+		if sec.OAuth2URL == "" {
+			return nil, fmt.Errorf("The oauth2 security scheme needs its authorization URL (-x oauth2-url=<url>)")
+		}
+		return map[string]*SwaggerSecurityScheme{
+			"oauth2": {Type: "oauth2", Flow: "implicit", AuthorizationURL: sec.OAuth2URL, Scopes: &scopes},
+		}, nil
+	}
+	return nil, fmt.Errorf("Unsupported swagger security scheme: %s", sec.Scheme)
+}
+
+// requirement returns the security requirement of the resource, and the authorize declaration
+// of the resource for the apiKey scheme, that has no scopes to express it.
+func (sec *swaggerSecurity) requirement(r *rdl.Resource) ([]map[string][]string, *SwaggerAuthorize) {
+	if r.Auth == nil || (r.Auth.Action == "" && !r.Auth.Authenticate) {
+		return nil, nil
+	}
+	if sec.Scheme == "oauth2" {
+		scopes := []string{}
+		if r.Auth.Action != "" {
+			scopes = append(scopes, swaggerScope(r.Auth))
+		}
+		return []map[string][]string{{"oauth2": scopes}}, nil
+	}
+	var authorize *SwaggerAuthorize
+	if r.Auth.Action != "" {
+		authorize = &SwaggerAuthorize{Action: r.Auth.Action, Resource: r.Auth.Resource, Domain: r.Auth.Domain}
+	}
+	return []map[string][]string{{"apiKey": {}}}, authorize
+}
+
 // SwaggerDoc is a representation of the top level object in swagger 2.0
 type SwaggerDoc struct {
 	Swagger string       `json:"swagger"`
 	Info    *SwaggerInfo `json:"info"`
 	//Host        string                               `json:"host"`
-	BasePath    string                               `json:"basePath"`
-	Schemes     []string                             `json:"schemes"`
-	Paths       map[string]map[string]*SwaggerAction `json:"paths,omitempty"`
-	Security    *map[string][]string                 `json:"security,omitempty"`
-	Definitions map[string]*SwaggerType              `json:"definitions,omitempty"`
+	BasePath     string                               `json:"basePath"`
+	Schemes      []string                             `json:"schemes"`
+	Paths        map[string]map[string]*SwaggerAction `json:"paths,omitempty"`
+	Security     *map[string][]string                 `json:"security,omitempty"`
+	Definitions  map[string]*SwaggerType              `json:"definitions,omitempty"`
+	Tags         []*SwaggerTag                        `json:"tags,omitempty"`
+	ExternalDocs *SwaggerExternalDocs                 `json:"externalDocs,omitempty"`
+
+	SecurityDefinitions map[string]*SwaggerSecurityScheme `json:"securityDefinitions,omitempty"`
+}
+
+// SwaggerTag - a group of operations in Swagger UI, listed in the order they first appear
+type SwaggerTag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// SwaggerExternalDocs - a link to more documentation, from an x_docs annotation
+type SwaggerExternalDocs struct {
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+}
+
+// SwaggerSecurityScheme -
+type SwaggerSecurityScheme struct {
+	Type             string             `json:"type"`
+	Description      string             `json:"description,omitempty"`
+	Name             string             `json:"name,omitempty"`
+	In               string             `json:"in,omitempty"`
+	Flow             string             `json:"flow,omitempty"`
+	AuthorizationURL string             `json:"authorizationUrl,omitempty"`
+	Scopes           *map[string]string `json:"scopes,omitempty"`
+}
+
+// SwaggerAuthorize - the x-authorize extension of an operation, with the authorize declaration
+// of its resource
+type SwaggerAuthorize struct {
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+	Domain   string `json:"domain,omitempty"`
 }
 
 // SwaggerInfo -
@@ -434,15 +836,18 @@ type SwaggerLicense struct {
 
 // SwaggerAction -
 type SwaggerAction struct {
-	Tags        []string                    `json:"tags,omitempty"`
-	Summary     string                      `json:"summary,omitempty"`
-	Description string                      `json:"description,omitempty"`
-	OperationID string                      `json:"operationId,omitempty"`
-	Consumes    []string                    `json:"consumes,omitempty"`
-	Produces    []string                    `json:"produces,omitempty"`
-	Parameters  []*SwaggerParameter         `json:"parameters,omitempty"`
-	Responses   map[string]*SwaggerResponse `json:"responses,omitempty"`
-	Security    map[string][]string         `json:"security,omitempty"`
+	Tags         []string                    `json:"tags,omitempty"`
+	Summary      string                      `json:"summary,omitempty"`
+	Description  string                      `json:"description,omitempty"`
+	OperationID  string                      `json:"operationId,omitempty"`
+	Consumes     []string                    `json:"consumes,omitempty"`
+	Produces     []string                    `json:"produces,omitempty"`
+	Parameters   []*SwaggerParameter         `json:"parameters,omitempty"`
+	Responses    map[string]*SwaggerResponse `json:"responses,omitempty"`
+	Security     []map[string][]string       `json:"security,omitempty"`
+	Authorize    *SwaggerAuthorize           `json:"x-authorize,omitempty"`
+	Deprecated   bool                        `json:"deprecated,omitempty"`
+	ExternalDocs *SwaggerExternalDocs        `json:"externalDocs,omitempty"`
 }
 
 // SwaggerParameter -
@@ -456,6 +861,14 @@ type SwaggerParameter struct {
 	Description      string       `json:"description,omitempty"`
 	Required         bool         `json:"required"`
 	CollectionFormat string       `json:"collectionFormat,omitempty"`
+	Default          interface{}  `json:"default,omitempty"`
+	Pattern          string       `json:"pattern,omitempty"`
+	MinLength        *int32       `json:"minLength,omitempty"`
+	MaxLength        *int32       `json:"maxLength,omitempty"`
+	Minimum          *float64     `json:"minimum,omitempty"`
+	Maximum          *float64     `json:"maximum,omitempty"`
+	Enum             []string     `json:"enum,omitempty"`
+	Example          interface{}  `json:"x-example,omitempty"`
 }
 
 // SwaggerResponse -
@@ -476,6 +889,52 @@ type SwaggerType struct {
 	Ref                  string                  `json:"$ref,omitempty"`
 	Enum                 []string                `json:"enum,omitempty"`
 	AdditionalProperties *SwaggerType            `json:"additionalProperties,omitempty"`
+	Default              interface{}             `json:"default,omitempty"`
+	MinLength            *int32                  `json:"minLength,omitempty"`
+	MaxLength            *int32                  `json:"maxLength,omitempty"`
+	Minimum              *float64                `json:"minimum,omitempty"`
+	Maximum              *float64                `json:"maximum,omitempty"`
+	MinItems             *int32                  `json:"minItems,omitempty"`
+	MaxItems             *int32                  `json:"maxItems,omitempty"`
+	Example              interface{}             `json:"example,omitempty"`
+	Nullable             bool                    `json:"x-nullable,omitempty"`
+	Deprecated           bool                    `json:"x-deprecated,omitempty"`
+	DeprecatedValues     []string                `json:"x-deprecated-values,omitempty"`
+	ExternalDocs         *SwaggerExternalDocs    `json:"externalDocs,omitempty"`
+}
+
+// enumWireName returns the string the enum element is written as: its x_wire annotation, or else
+// its symbol. The x_aliases it is also read from are not listed, as they are never written.
+func enumWireName(el *rdl.EnumElementDef) string {
+	if wire := strings.TrimSpace(el.Annotations["x_wire"]); wire != "" {
+		return wire
+	}
+	return string(el.Symbol)
+}
+
+// enumWireDefault returns the default value as it is written: the wire name of the element, if
+// the type is an enum.
+func enumWireDefault(reg rdl.TypeRegistry, tref rdl.TypeRef, def interface{}) interface{} {
+	if def == nil {
+		return nil
+	}
+	for t := reg.FindType(tref); t != nil; {
+		if t.Variant == rdl.TypeVariantEnumTypeDef {
+			for _, el := range t.EnumTypeDef.Elements {
+				if string(el.Symbol) == fmt.Sprint(def) {
+					return enumWireName(el)
+				}
+			}
+			break
+		}
+		_, super, _ := rdl.TypeInfo(t)
+		if super == tref {
+			break
+		}
+		tref = super
+		t = reg.FindType(tref)
+	}
+	return def
 }
 
 /*
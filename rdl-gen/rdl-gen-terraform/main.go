@@ -0,0 +1,976 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// generate the scaffolding of a Terraform provider (terraform-plugin-sdk/v2) for the resources
+// of an RDL schema. Each struct type with a GET resource on an item path with a single path
+// parameter, e.g. GET "/things/{name}", and a POST on the collection or a PUT on the item path
+// to create it, becomes a Terraform resource. Its attributes are the fields of the struct, its
+// id the field named like the path parameter, and its CRUD functions call the client generated
+// by go-client (without -t), updating with the PUT and deleting with the DELETE on the item
+// path if the schema has them. Resources that do not fit are listed in a comment, to be written
+// by hand.
+//
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	pOutdir := flag.String("o", "", "Output directory")
+	flag.String("s", "", "RDL source file")
+	pClient := flag.String("client", "", "Import path of the package generated by go-client (default is the schema name)")
+	pPackage := flag.String("package", "provider", "Package name of the generated provider")
+	flag.Parse()
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		var schema rdl.Schema
+		err = json.Unmarshal(data, &schema)
+		if err == nil {
+			err = ExportToTerraform(&schema, *pOutdir, *pClient, *pPackage)
+			if err == nil {
+				os.Exit(0)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "*** %v\n", err)
+	os.Exit(1)
+}
+
+func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.File, string, error) {
+	sname := "anonymous"
+	if strings.HasSuffix(outdir, ext) {
+		name = filepath.Base(outdir)
+		sname = name[:len(name)-len(ext)]
+		outdir = filepath.Dir(outdir)
+	}
+	if name != "" {
+		sname = name
+	}
+	if outdir == "" {
+		return bufio.NewWriter(os.Stdout), nil, sname, nil
+	}
+	outfile := sname
+	if !strings.HasSuffix(outfile, ext) {
+		outfile += ext
+	}
+	path := filepath.Join(outdir, outfile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	writer := bufio.NewWriter(f)
+	return writer, f, sname, nil
+}
+
+func capitalize(text string) string {
+	return strings.ToUpper(text[0:1]) + text[1:]
+}
+
+func uncapitalize(text string) string {
+	return strings.ToLower(text[0:1]) + text[1:]
+}
+
+func addFields(reg rdl.TypeRegistry, dst []*rdl.StructFieldDef, t *rdl.Type) []*rdl.StructFieldDef {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		st := t.StructTypeDef
+		if st.Type != "Struct" {
+			dst = addFields(reg, dst, reg.FindType(st.Type))
+		}
+		for _, f := range st.Fields {
+			dst = append(dst, f)
+		}
+	}
+	return dst
+}
+
+func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
+	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
+}
+
+// snakeCase turns a field name into a Terraform attribute name, e.g. maxSize into max_size.
+func snakeCase(name string) string {
+	var buf []rune
+	runes := []rune(name)
+	for i, c := range runes {
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 && (runes[i-1] < 'A' || runes[i-1] > 'Z') && runes[i-1] != '_' {
+				buf = append(buf, '_')
+			}
+			c += 'a' - 'A'
+		}
+		buf = append(buf, c)
+	}
+	return string(buf)
+}
+
+// reservedAttributes are the names Terraform keeps for itself in a resource.
+var reservedAttributes = map[string]bool{"connection": true, "count": true, "depends_on": true, "id": true, "lifecycle": true, "provider": true, "provisioner": true}
+
+// attributeName returns the Terraform attribute name of a field of the struct type. A name that
+// Terraform reserves is prefixed with the type, e.g. the count of a Contact is contact_count.
+func attributeName(tref rdl.TypeRef, f *rdl.StructFieldDef) string {
+	name := snakeCase(string(f.Name))
+	if reservedAttributes[name] {
+		name = snakeCase(string(tref)) + "_" + name
+	}
+	return name
+}
+
+// resourcePath returns the path of the resource without its query, with the path parameters
+// unnamed, so that the paths of the resources on the same item compare equal.
+func resourcePath(r *rdl.Resource) string {
+	path := r.Path
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, "{") {
+			segments[i] = "{}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathParams(r *rdl.Resource) []*rdl.ResourceInput {
+	var params []*rdl.ResourceInput
+	for _, in := range r.Inputs {
+		if in.PathParam {
+			params = append(params, in)
+		}
+	}
+	return params
+}
+
+func bodyInput(r *rdl.Resource) *rdl.ResourceInput {
+	for _, in := range r.Inputs {
+		if !in.PathParam && in.QueryParam == "" && in.Header == "" {
+			return in
+		}
+	}
+	return nil
+}
+
+// tfEntity is a struct type managed as a Terraform resource, with the resources of the schema
+// that create, read, update, and delete it.
+type tfEntity struct {
+	Type     rdl.TypeRef
+	Key      *rdl.ResourceInput
+	KeyField *rdl.StructFieldDef
+	Create   *rdl.Resource
+	Read     *rdl.Resource
+	Update   *rdl.Resource
+	Delete   *rdl.Resource
+}
+
+type terraformGenerator struct {
+	registry rdl.TypeRegistry
+	schema   *rdl.Schema
+	pkg      string
+	client   string
+	entities []*tfEntity
+	skipped  []string
+	types    []rdl.TypeRef //the struct types that need schema, expand, and flatten functions
+	seen     map[rdl.TypeRef]bool
+	imports  map[string]bool
+	buf      strings.Builder
+}
+
+// ExportToTerraform generates the provider for the schema's resources.
+func ExportToTerraform(schema *rdl.Schema, outdir string, clientImport string, pkg string) error {
+	if schema.Name == "" {
+		return fmt.Errorf("The schema has no name to name the provider after")
+	}
+	name := strings.ToLower(string(schema.Name))
+	if clientImport == "" {
+		clientImport = name
+	}
+	gen := &terraformGenerator{
+		registry: rdl.NewTypeRegistry(schema),
+		schema:   schema,
+		pkg:      pkg,
+		client:   clientImport,
+		seen:     make(map[rdl.TypeRef]bool),
+		imports:  make(map[string]bool),
+	}
+	gen.findEntities()
+	if len(gen.entities) == 0 {
+		return fmt.Errorf("No resource of the schema '%s' can be managed by Terraform, i.e. created and read on a path with a single parameter", schema.Name)
+	}
+	body := gen.generate()
+	out, file, _, err := outputWriter(outdir, name+"_provider", ".go")
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	source := []byte(gen.header() + body)
+	if formatted, err := format.Source(source); err == nil {
+		source = formatted
+	}
+	_, err = out.Write(source)
+	if err == nil {
+		err = out.Flush()
+	}
+	return err
+}
+
+// findEntities pairs the resources of the schema up into entities.
+func (gen *terraformGenerator) findEntities() {
+	used := make(map[*rdl.Resource]bool)
+	for _, r := range gen.schema.Resources {
+		if r.Method != "GET" || r.Annotations["x_stream"] != "" {
+			continue
+		}
+		t := gen.registry.FindType(r.Type)
+		if t == nil || t.Variant != rdl.TypeVariantStructTypeDef {
+			continue
+		}
+		params := pathParams(r)
+		if len(params) != 1 || !gen.isKeyType(params[0].Type) {
+			continue
+		}
+		e := &tfEntity{Type: r.Type, Key: params[0], Read: r}
+		for _, f := range flattenedFields(gen.registry, t) {
+			if strings.EqualFold(string(f.Name), string(e.Key.Name)) && gen.isKeyType(f.Type) {
+				e.KeyField = f
+			}
+		}
+		if e.KeyField == nil {
+			gen.skipped = append(gen.skipped, fmt.Sprintf("%s %s: the %s has no field %s to identify it", r.Method, r.Path, r.Type, e.Key.Name))
+			used[r] = true
+			continue
+		}
+		item := resourcePath(r)
+		collection := item[:strings.LastIndex(item, "/")]
+		for _, other := range gen.schema.Resources {
+			body := bodyInput(other)
+			switch {
+			case other.Method == "POST" && resourcePath(other) == collection && body != nil && body.Type == r.Type && other.Type == r.Type:
+				if e.Create == nil {
+					e.Create = other
+				}
+			case other.Method == "PUT" && resourcePath(other) == item && body != nil && body.Type == r.Type:
+				if e.Update == nil {
+					e.Update = other
+				}
+			case other.Method == "DELETE" && resourcePath(other) == item:
+				if e.Delete == nil {
+					e.Delete = other
+				}
+			}
+		}
+		if e.Create == nil && e.Update != nil && !e.KeyField.Optional {
+			//a PUT on the item path creates it as well
+			e.Create = e.Update
+		}
+		used[r] = true
+		if e.Create == nil {
+			gen.skipped = append(gen.skipped, fmt.Sprintf("%s %s: there is no POST or PUT to create the %s", r.Method, r.Path, r.Type))
+			continue
+		}
+		for _, other := range []*rdl.Resource{e.Create, e.Update, e.Delete} {
+			if other != nil {
+				used[other] = true
+			}
+		}
+		gen.entities = append(gen.entities, e)
+	}
+	for _, r := range gen.schema.Resources {
+		if !used[r] {
+			gen.skipped = append(gen.skipped, fmt.Sprintf("%s %s", r.Method, r.Path))
+		}
+	}
+	sort.SliceStable(gen.entities, func(i, j int) bool { return gen.entities[i].Type < gen.entities[j].Type })
+}
+
+// isKeyType tells whether values of the type can be the id of a Terraform resource.
+func (gen *terraformGenerator) isKeyType(tref rdl.TypeRef) bool {
+	switch gen.registry.FindBaseType(tref) {
+	case rdl.BaseTypeString, rdl.BaseTypeSymbol, rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+		return true
+	}
+	return false
+}
+
+func (gen *terraformGenerator) emit(format string, args ...interface{}) {
+	fmt.Fprintf(&gen.buf, format, args...)
+}
+
+func (gen *terraformGenerator) header() string {
+	var imports []string
+	for imp := range gen.imports {
+		imports = append(imports, imp)
+	}
+	imports = append(imports, "context", "github.com/ardielle/ardielle-go/rdl", "github.com/hashicorp/terraform-plugin-sdk/v2/diag", "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema")
+	sort.Strings(imports)
+	s := "//\n// This file generated by rdl-gen-terraform\n//\n\n"
+	s += "package " + gen.pkg + "\n\nimport (\n"
+	for _, imp := range imports {
+		s += fmt.Sprintf("\t%q\n", imp)
+	}
+	s += fmt.Sprintf("\n\t%s %q\n)\n", gen.clientPackage(), gen.client)
+	return s
+}
+
+func (gen *terraformGenerator) clientPackage() string {
+	return strings.ToLower(string(gen.schema.Name))
+}
+
+func (gen *terraformGenerator) clientType() string {
+	return gen.clientPackage() + "." + capitalize(string(gen.schema.Name)) + "Client"
+}
+
+func (gen *terraformGenerator) goTypeName(tref rdl.TypeRef) string {
+	return gen.clientPackage() + "." + capitalize(strings.Replace(string(tref), ".", "_", -1))
+}
+
+func (gen *terraformGenerator) generate() string {
+	name := strings.ToLower(string(gen.schema.Name))
+	env := strings.ToUpper(name)
+	gen.emit("\n// Provider returns the Terraform provider for the %s service.\n", gen.schema.Name)
+	gen.emit("func Provider() *schema.Provider {\n")
+	gen.emit("\treturn &schema.Provider{\n")
+	gen.emit("\t\tSchema: map[string]*schema.Schema{\n")
+	gen.emit("\t\t\t\"endpoint\": {\n\t\t\t\tType:        schema.TypeString,\n\t\t\t\tRequired:    true,\n")
+	gen.emit("\t\t\t\tDefaultFunc: schema.EnvDefaultFunc(%q, nil),\n", env+"_ENDPOINT")
+	gen.emit("\t\t\t\tDescription: %q,\n\t\t\t},\n", "The URL of the "+string(gen.schema.Name)+" service")
+	gen.emit("\t\t\t\"credentials_header\": {\n\t\t\t\tType:        schema.TypeString,\n\t\t\t\tOptional:    true,\n")
+	gen.emit("\t\t\t\tDescription: %q,\n\t\t\t},\n", "The header to send the credentials in")
+	gen.emit("\t\t\t\"credentials\": {\n\t\t\t\tType:        schema.TypeString,\n\t\t\t\tOptional:    true,\n\t\t\t\tSensitive:   true,\n")
+	gen.emit("\t\t\t\tDefaultFunc: schema.EnvDefaultFunc(%q, nil),\n", env+"_CREDENTIALS")
+	gen.emit("\t\t\t\tDescription: %q,\n\t\t\t},\n", "The credentials to send in the credentials_header")
+	gen.emit("\t\t},\n")
+	gen.emit("\t\tResourcesMap: map[string]*schema.Resource{\n")
+	for _, e := range gen.entities {
+		gen.emit("\t\t\t%q: resource%s(),\n", name+"_"+snakeCase(string(e.Type)), capitalize(string(e.Type)))
+	}
+	gen.emit("\t\t},\n")
+	gen.emit("\t\tConfigureContextFunc: providerConfigure,\n")
+	gen.emit("\t}\n}\n\n")
+	gen.emit("func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {\n")
+	gen.emit("\tclient := %s.NewClient(d.Get(\"endpoint\").(string), nil)\n", gen.clientPackage())
+	gen.emit("\tif header, ok := d.GetOk(\"credentials_header\"); ok {\n")
+	gen.emit("\t\tclient.AddCredentials(header.(string), d.Get(\"credentials\").(string))\n")
+	gen.emit("\t}\n")
+	gen.emit("\treturn &client, nil\n}\n\n")
+	gen.emit("// resourceDataMap returns the attributes of the resource that are set, by name.\n")
+	gen.emit("func resourceDataMap(d *schema.ResourceData, attributes map[string]*schema.Schema) map[string]interface{} {\n")
+	gen.emit("\tm := make(map[string]interface{})\n")
+	gen.emit("\tfor name := range attributes {\n\t\tif v, ok := d.GetOk(name); ok {\n\t\t\tm[name] = v\n\t\t}\n\t}\n")
+	gen.emit("\treturn m\n}\n\n")
+	gen.emit("// notFound tells whether the error is the 404 response of the service.\n")
+	gen.emit("func notFound(err error) bool {\n")
+	gen.emit("\trerr, ok := err.(rdl.ResourceError)\n")
+	gen.emit("\treturn ok && rerr.Code == 404\n}\n")
+	if len(gen.skipped) > 0 {
+		gen.emit("\n//\n// The resources that are not managed by the provider:\n")
+		for _, s := range gen.skipped {
+			gen.emit("//   %s\n", s)
+		}
+		gen.emit("//\n")
+	}
+	for _, e := range gen.entities {
+		gen.useType(e.Type)
+		gen.emitEntity(e)
+	}
+	for i := 0; i < len(gen.types); i++ {
+		//the functions of a struct add the struct types of its fields as they go
+		gen.emitStructFunctions(gen.types[i])
+	}
+	return gen.buf.String()
+}
+
+func (gen *terraformGenerator) useType(tref rdl.TypeRef) {
+	if !gen.seen[tref] {
+		gen.seen[tref] = true
+		gen.types = append(gen.types, tref)
+	}
+}
+
+func (gen *terraformGenerator) emitEntity(e *tfEntity) {
+	cName := capitalize(string(e.Type))
+	lName := uncapitalize(string(e.Type))
+	keyAttr := attributeName(e.Type, e.KeyField)
+	gen.emit("\nfunc resource%s() *schema.Resource {\n", cName)
+	gen.emit("\tattributes := %sSchema()\n", lName)
+	if e.Update == nil {
+		gen.emit("\tfor _, attribute := range attributes {\n\t\tattribute.ForceNew = true\n\t}\n")
+	} else {
+		gen.emit("\tattributes[%q].ForceNew = true\n", keyAttr)
+	}
+	gen.emit("\treturn &schema.Resource{\n")
+	if t := gen.registry.FindType(e.Type); t != nil && t.StructTypeDef.Comment != "" {
+		gen.emit("\t\tDescription:   %q,\n", t.StructTypeDef.Comment)
+	}
+	gen.emit("\t\tCreateContext: resource%sCreate,\n", cName)
+	gen.emit("\t\tReadContext:   resource%sRead,\n", cName)
+	if e.Update != nil {
+		gen.emit("\t\tUpdateContext: resource%sUpdate,\n", cName)
+	}
+	gen.emit("\t\tDeleteContext: resource%sDelete,\n", cName)
+	gen.emit("\t\tImporter: &schema.ResourceImporter{\n\t\t\tStateContext: schema.ImportStatePassthroughContext,\n\t\t},\n")
+	gen.emit("\t\tSchema: attributes,\n")
+	gen.emit("\t}\n}\n")
+
+	//create
+	gen.emit("\nfunc resource%sCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {\n", cName)
+	gen.emit("\tclient := meta.(*%s)\n", gen.clientType())
+	gen.emit("\tobj, err := expand%s(resourceDataMap(d, resource%s().Schema))\n", cName, cName)
+	gen.emit("\tif err != nil {\n\t\treturn diag.FromErr(err)\n\t}\n")
+	keyField := "obj." + capitalize(string(e.KeyField.Name))
+	if e.Create.Method == "POST" && !gen.noContent(e.Create) {
+		gen.emit("\t%s\n", gen.call(e.Create, "obj", "created", "", true))
+		gen.emit("\tif err != nil {\n\t\treturn diag.FromErr(err)\n\t}\n")
+		keyField = "created." + capitalize(string(e.KeyField.Name))
+	} else {
+		gen.emit("\t%s\n", gen.call(e.Create, "obj", "", gen.keyArg(e, keyField), true))
+		gen.emit("\tif err != nil {\n\t\treturn diag.FromErr(err)\n\t}\n")
+	}
+	if gen.isPointer(e.KeyField) {
+		gen.emit("\tif %s == nil {\n\t\treturn diag.Errorf(%q)\n\t}\n", keyField, "the created "+string(e.Type)+" has no "+string(e.KeyField.Name))
+		keyField = "*" + keyField
+	}
+	gen.emit("\td.SetId(fmt.Sprint(%s))\n", keyField)
+	gen.imports["fmt"] = true
+	gen.emit("\treturn resource%sRead(ctx, d, meta)\n}\n", cName)
+
+	//read
+	gen.emit("\nfunc resource%sRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {\n", cName)
+	gen.emit("\tclient := meta.(*%s)\n", gen.clientType())
+	key, parsed := gen.emitKey(e)
+	gen.emit("\t%s\n", gen.call(e.Read, "", "obj", key, parsed))
+	gen.emit("\tif err != nil {\n\t\tif notFound(err) {\n\t\t\td.SetId(\"\")\n\t\t\treturn nil\n\t\t}\n\t\treturn diag.FromErr(err)\n\t}\n")
+	gen.emit("\tif obj == nil {\n\t\treturn nil\n\t}\n")
+	gen.emit("\tfor name, value := range flatten%s(obj) {\n", cName)
+	gen.emit("\t\tif err := d.Set(name, value); err != nil {\n\t\t\treturn diag.FromErr(err)\n\t\t}\n\t}\n")
+	gen.emit("\treturn nil\n}\n")
+
+	//update
+	if e.Update != nil {
+		gen.emit("\nfunc resource%sUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {\n", cName)
+		gen.emit("\tclient := meta.(*%s)\n", gen.clientType())
+		gen.emit("\tobj, err := expand%s(resourceDataMap(d, resource%s().Schema))\n", cName, cName)
+		gen.emit("\tif err != nil {\n\t\treturn diag.FromErr(err)\n\t}\n")
+		key, _ := gen.emitKey(e)
+		gen.emit("\t%s\n", gen.call(e.Update, "obj", "", key, true))
+		gen.emit("\tif err != nil {\n\t\treturn diag.FromErr(err)\n\t}\n")
+		gen.emit("\treturn resource%sRead(ctx, d, meta)\n}\n", cName)
+	}
+
+	//delete
+	gen.emit("\nfunc resource%sDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {\n", cName)
+	if e.Delete != nil {
+		gen.emit("\tclient := meta.(*%s)\n", gen.clientType())
+		key, parsed := gen.emitKey(e)
+		gen.emit("\t%s\n", gen.call(e.Delete, "", "", key, parsed))
+		gen.emit("\tif err != nil && !notFound(err) {\n\t\treturn diag.FromErr(err)\n\t}\n")
+	} else {
+		gen.emit("\t//the schema has no DELETE for the %s, so it is only removed from the state\n", e.Type)
+	}
+	gen.emit("\td.SetId(\"\")\n")
+	gen.emit("\treturn nil\n}\n")
+}
+
+// emitKey emits the conversion of the resource id to the type of the path parameter, if need
+// be, and returns the expression of the key, and whether err is declared by the conversion.
+func (gen *terraformGenerator) emitKey(e *tfEntity) (string, bool) {
+	switch bt := gen.registry.FindBaseType(e.Key.Type); bt {
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+		gen.imports["strconv"] = true
+		bits := strings.TrimPrefix(bt.String(), "Int")
+		gen.emit("\tkey, err := strconv.ParseInt(d.Id(), 10, %s)\n", bits)
+		gen.emit("\tif err != nil {\n\t\treturn diag.FromErr(err)\n\t}\n")
+		return strings.ToLower(bt.String()) + "(key)", true
+	case rdl.BaseTypeSymbol:
+		return "rdl.Symbol(d.Id())", false
+	}
+	return "d.Id()", false
+}
+
+// keyArg converts the key field of the object to the type of the path parameter.
+func (gen *terraformGenerator) keyArg(e *tfEntity, field string) string {
+	if gen.isPointer(e.KeyField) {
+		field = "*" + field
+	}
+	switch bt := gen.registry.FindBaseType(e.Key.Type); bt {
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+		return strings.ToLower(bt.String()) + "(" + field + ")"
+	case rdl.BaseTypeSymbol:
+		return "rdl.Symbol(" + field + ")"
+	}
+	return field
+}
+
+func (gen *terraformGenerator) noContent(r *rdl.Resource) bool {
+	return r.Expected == "NO_CONTENT" && r.Alternatives == nil
+}
+
+// call returns the statement calling the client method of the resource, as go-client names it,
+// with the key and body given, and the zero or default value of the other inputs. The result,
+// if named, is assigned to the variable.
+func (gen *terraformGenerator) call(r *rdl.Resource, body string, result string, key string, errDeclared bool) string {
+	method := strings.ToLower(r.Method) + capitalize(strings.Replace(string(r.Type), ".", "", -1))
+	args := []string{"ctx"}
+	for _, in := range r.Inputs {
+		switch {
+		case in.PathParam:
+			args = append(args, key)
+		case in.QueryParam == "" && in.Header == "":
+			method = strings.ToLower(r.Method) + string(in.Type)
+			if body == "" {
+				body = gen.inputValue(in)
+			}
+			args = append(args, body)
+		default:
+			args = append(args, gen.inputValue(in))
+		}
+	}
+	call := fmt.Sprintf("client.%s(%s)", capitalize(method), strings.Join(args, ", "))
+	assign := " := "
+	if errDeclared && (result == "" || gen.noContent(r)) {
+		assign = " = "
+	}
+	if gen.noContent(r) {
+		return "err" + assign + call
+	}
+	results := []string{"_"}
+	if result != "" {
+		results[0] = result
+	}
+	for range r.Outputs {
+		results = append(results, "_")
+	}
+	results = append(results, "err")
+	return strings.Join(results, ", ") + assign + call
+}
+
+// inputValue is the value passed for an input the provider has no value for.
+func (gen *terraformGenerator) inputValue(in *rdl.ResourceInput) string {
+	bt := gen.registry.FindBaseType(in.Type)
+	switch bt {
+	case rdl.BaseTypeString:
+		if s, ok := in.Default.(string); ok {
+			return fmt.Sprintf("%q", s)
+		}
+		return "\"\""
+	case rdl.BaseTypeSymbol:
+		return "\"\""
+	case rdl.BaseTypeBool:
+		if in.Optional {
+			return "nil"
+		}
+		if b, ok := in.Default.(bool); ok && b {
+			return "true"
+		}
+		return "false"
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		if in.Optional {
+			return "nil"
+		}
+		if n, ok := in.Default.(float64); ok {
+			return fmt.Sprint(n)
+		}
+		return "0"
+	case rdl.BaseTypeEnum:
+		if s, ok := in.Default.(string); ok {
+			return fmt.Sprintf("%s.New%s(%q)", gen.clientPackage(), capitalize(string(in.Type)), s)
+		}
+		if in.Optional {
+			return "nil"
+		}
+		return "0"
+	}
+	return "nil"
+}
+
+// isPointer tells whether go-model declares the field as a pointer to its (scalar) type.
+func (gen *terraformGenerator) isPointer(f *rdl.StructFieldDef) bool {
+	if !f.Optional {
+		return false
+	}
+	switch gen.registry.FindBaseType(f.Type) {
+	case rdl.BaseTypeBool, rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64,
+		rdl.BaseTypeTimestamp, rdl.BaseTypeUUID, rdl.BaseTypeEnum:
+		return true
+	}
+	return false
+}
+
+// scalar describes how a scalar type is held by Terraform and by the Go client.
+type scalar struct {
+	tfType   string //the schema.ValueType
+	tfGo     string //the Go type of the Terraform value
+	toGo     string //the conversion of a Terraform value v to the client type, %s being v
+	fromGo   string //the conversion of a client value to the Terraform value, %s being the value
+	parse    bool   //toGo returns an error as well
+	values   []string
+	nonEmpty bool //an empty Terraform value means unset
+}
+
+func (gen *terraformGenerator) scalar(tref rdl.TypeRef) *scalar {
+	t := gen.registry.FindType(tref)
+	if t == nil {
+		return nil
+	}
+	switch bt := gen.registry.BaseType(t); bt {
+	case rdl.BaseTypeString:
+		s := &scalar{tfType: "schema.TypeString", tfGo: "string", toGo: "%s", fromGo: "%s"}
+		if t.Variant == rdl.TypeVariantStringTypeDef {
+			s.values = t.StringTypeDef.Values
+		}
+		return s
+	case rdl.BaseTypeSymbol:
+		return &scalar{tfType: "schema.TypeString", tfGo: "string", toGo: "rdl.Symbol(%s)", fromGo: "string(%s)"}
+	case rdl.BaseTypeBool:
+		return &scalar{tfType: "schema.TypeBool", tfGo: "bool", toGo: "%s", fromGo: "%s"}
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+		return &scalar{tfType: "schema.TypeInt", tfGo: "int", toGo: strings.ToLower(bt.String()) + "(%s)", fromGo: "int(%s)"}
+	case rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return &scalar{tfType: "schema.TypeFloat", tfGo: "float64", toGo: strings.ToLower(bt.String()) + "(%s)", fromGo: "float64(%s)"}
+	case rdl.BaseTypeTimestamp:
+		return &scalar{tfType: "schema.TypeString", tfGo: "string", toGo: "rdl.TimestampParse(%s)", fromGo: "%s.String()", parse: true, nonEmpty: true}
+	case rdl.BaseTypeUUID:
+		return &scalar{tfType: "schema.TypeString", tfGo: "string", toGo: "rdl.ParseUUID(%s)", fromGo: "%s.String()", nonEmpty: true}
+	case rdl.BaseTypeEnum:
+		var values []string
+		for _, elem := range t.EnumTypeDef.Elements {
+			values = append(values, string(elem.Symbol))
+		}
+		return &scalar{tfType: "schema.TypeString", tfGo: "string", toGo: gen.clientPackage() + ".New" + capitalize(string(tref)) + "(%s)", fromGo: "%s.String()", values: values, nonEmpty: true}
+	}
+	return nil
+}
+
+// itemGoType is the Go type of the items of an array or map, as go-client declares them.
+func (gen *terraformGenerator) itemGoType(tref rdl.TypeRef) string {
+	switch bt := gen.registry.FindBaseType(tref); bt {
+	case rdl.BaseTypeString:
+		return "string"
+	case rdl.BaseTypeSymbol:
+		return "rdl.Symbol"
+	case rdl.BaseTypeBool:
+		return "bool"
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return strings.ToLower(bt.String())
+	case rdl.BaseTypeTimestamp:
+		return "rdl.Timestamp"
+	case rdl.BaseTypeUUID:
+		return "rdl.UUID"
+	case rdl.BaseTypeEnum:
+		return gen.goTypeName(tref)
+	case rdl.BaseTypeStruct:
+		return "*" + gen.goTypeName(tref)
+	}
+	return ""
+}
+
+// fieldKind sorts the fields into those held as scalars, lists of scalars, maps of scalars,
+// nested blocks (a struct, or a list of structs), and those that are not mapped.
+func (gen *terraformGenerator) fieldKind(f *rdl.StructFieldDef) (kind string, items rdl.TypeRef, collection string) {
+	t := gen.registry.FindType(f.Type)
+	if t == nil {
+		return "", "", ""
+	}
+	isStruct := func(tref rdl.TypeRef) bool {
+		st := gen.registry.FindType(tref)
+		return st != nil && st.Variant == rdl.TypeVariantStructTypeDef
+	}
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeStruct:
+		if isStruct(f.Type) {
+			return "block", f.Type, ""
+		}
+		return "", "", ""
+	case rdl.BaseTypeArray:
+		items := f.Items
+		collection = "[]"
+		if t.Variant == rdl.TypeVariantArrayTypeDef && t.ArrayTypeDef.Name != "Array" {
+			items = t.ArrayTypeDef.Items
+			collection = gen.goTypeName(f.Type)
+		}
+		if isStruct(items) {
+			return "blocks", items, collection
+		}
+		if gen.scalar(items) != nil {
+			return "list", items, collection
+		}
+	case rdl.BaseTypeMap:
+		keys, items := f.Keys, f.Items
+		collection = "map"
+		if t.Variant == rdl.TypeVariantMapTypeDef && t.MapTypeDef.Name != "Map" {
+			keys, items = t.MapTypeDef.Keys, t.MapTypeDef.Items
+			collection = gen.goTypeName(f.Type)
+		}
+		if gen.registry.FindBaseType(keys) == rdl.BaseTypeString {
+			if s := gen.scalar(items); s != nil && !s.parse && s.values == nil && gen.registry.FindBaseType(items) != rdl.BaseTypeUUID && gen.registry.FindBaseType(items) != rdl.BaseTypeEnum {
+				return "map", items, collection
+			}
+		}
+	default:
+		if gen.scalar(f.Type) != nil {
+			return "scalar", f.Type, ""
+		}
+	}
+	return "", "", ""
+}
+
+func (gen *terraformGenerator) emitStructFunctions(tref rdl.TypeRef) {
+	t := gen.registry.FindType(tref)
+	fields := flattenedFields(gen.registry, t)
+	cName := capitalize(string(tref))
+	lName := uncapitalize(string(tref))
+	goName := gen.goTypeName(tref)
+
+	//the attributes
+	gen.emit("\nfunc %sSchema() map[string]*schema.Schema {\n", lName)
+	gen.emit("\treturn map[string]*schema.Schema{\n")
+	for _, f := range fields {
+		kind, items, _ := gen.fieldKind(f)
+		attr := attributeName(tref, f)
+		if kind == "" || ((kind == "block" || kind == "blocks") && gen.recursive(tref, items)) {
+			gen.emit("\t\t//%s: the %s field is not mapped\n", attr, f.Type)
+			continue
+		}
+		gen.emit("\t\t%q: {\n", attr)
+		switch kind {
+		case "scalar":
+			s := gen.scalar(items)
+			gen.emit("\t\t\tType: %s,\n", s.tfType)
+			if s.values != nil {
+				gen.imports["github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"] = true
+				quoted := make([]string, 0, len(s.values))
+				for _, v := range s.values {
+					quoted = append(quoted, fmt.Sprintf("%q", v))
+				}
+				gen.emit("\t\t\tValidateFunc: validation.StringInSlice([]string{%s}, false),\n", strings.Join(quoted, ", "))
+			}
+		case "list", "map":
+			s := gen.scalar(items)
+			if kind == "list" {
+				gen.emit("\t\t\tType: schema.TypeList,\n")
+			} else {
+				gen.emit("\t\t\tType: schema.TypeMap,\n")
+			}
+			gen.emit("\t\t\tElem: &schema.Schema{Type: %s},\n", s.tfType)
+		case "block", "blocks":
+			gen.useType(items)
+			gen.emit("\t\t\tType: schema.TypeList,\n")
+			if kind == "block" {
+				gen.emit("\t\t\tMaxItems: 1,\n")
+			}
+			gen.emit("\t\t\tElem: &schema.Resource{Schema: %sSchema()},\n", uncapitalize(string(items)))
+		}
+		if f.Optional || f.Default != nil {
+			gen.emit("\t\t\tOptional: true,\n")
+			if f.Default != nil && kind == "scalar" {
+				gen.emit("\t\t\tDefault: %s,\n", gen.defaultValue(f))
+			}
+		} else {
+			gen.emit("\t\t\tRequired: true,\n")
+		}
+		if f.Comment != "" {
+			gen.emit("\t\t\tDescription: %q,\n", f.Comment)
+		}
+		gen.emit("\t\t},\n")
+	}
+	gen.emit("\t}\n}\n")
+
+	//from the attributes to the client type
+	gen.emit("\nfunc expand%s(m map[string]interface{}) (*%s, error) {\n", cName, goName)
+	gen.emit("\to := &%s{}\n", goName)
+	for _, f := range fields {
+		kind, items, collection := gen.fieldKind(f)
+		if kind == "" || ((kind == "block" || kind == "blocks") && gen.recursive(tref, items)) {
+			continue
+		}
+		attr := attributeName(tref, f)
+		field := "o." + capitalize(string(f.Name))
+		switch kind {
+		case "scalar":
+			s := gen.scalar(items)
+			cond := "ok"
+			if s.nonEmpty || (f.Optional && s.tfGo == "string") {
+				cond = "ok && v != \"\""
+			}
+			gen.emit("\tif v, ok := m[%q].(%s); %s {\n", attr, s.tfGo, cond)
+			if s.parse {
+				gen.emit("\t\tx, err := %s\n", fmt.Sprintf(s.toGo, "v"))
+				gen.emit("\t\tif err != nil {\n\t\t\treturn nil, fmt.Errorf(\"%s: %%v\", err)\n\t\t}\n", attr)
+				gen.imports["fmt"] = true
+			} else if gen.isPointer(f) {
+				gen.emit("\t\tx := %s\n", fmt.Sprintf(s.toGo, "v"))
+			} else {
+				gen.emit("\t\t%s = %s\n", field, fmt.Sprintf(s.toGo, "v"))
+			}
+			if gen.isPointer(f) {
+				gen.emit("\t\t%s = &x\n", field)
+			} else if s.parse {
+				gen.emit("\t\t%s = x\n", field)
+			}
+			gen.emit("\t}\n")
+		case "list":
+			s := gen.scalar(items)
+			gen.emit("\tif v, ok := m[%q].([]interface{}); ok {\n", attr)
+			gen.emit("\t\t%s = make(%s, 0, len(v))\n", field, gen.collectionType(collection, "", items))
+			gen.emit("\t\tfor _, item := range v {\n")
+			gen.emit("\t\t\tv, _ := item.(%s)\n", s.tfGo)
+			if s.parse {
+				gen.emit("\t\t\tx, err := %s\n", fmt.Sprintf(s.toGo, "v"))
+				gen.emit("\t\t\tif err != nil {\n\t\t\t\treturn nil, fmt.Errorf(\"%s: %%v\", err)\n\t\t\t}\n", attr)
+				gen.imports["fmt"] = true
+				gen.emit("\t\t\t%s = append(%s, x)\n", field, field)
+			} else {
+				gen.emit("\t\t\t%s = append(%s, %s)\n", field, field, fmt.Sprintf(s.toGo, "v"))
+			}
+			gen.emit("\t\t}\n\t}\n")
+		case "map":
+			s := gen.scalar(items)
+			gen.emit("\tif v, ok := m[%q].(map[string]interface{}); ok {\n", attr)
+			gen.emit("\t\t%s = make(%s)\n", field, gen.collectionType(collection, "string", items))
+			gen.emit("\t\tfor k, item := range v {\n")
+			gen.emit("\t\t\tv, _ := item.(%s)\n", s.tfGo)
+			gen.emit("\t\t\t%s[k] = %s\n", field, fmt.Sprintf(s.toGo, "v"))
+			gen.emit("\t\t}\n\t}\n")
+		case "block":
+			gen.emit("\tif v, ok := m[%q].([]interface{}); ok && len(v) > 0 && v[0] != nil {\n", attr)
+			gen.emit("\t\tx, err := expand%s(v[0].(map[string]interface{}))\n", capitalize(string(items)))
+			gen.emit("\t\tif err != nil {\n\t\t\treturn nil, err\n\t\t}\n")
+			gen.emit("\t\t%s = x\n", field)
+			gen.emit("\t}\n")
+		case "blocks":
+			gen.emit("\tif v, ok := m[%q].([]interface{}); ok {\n", attr)
+			gen.emit("\t\t%s = make(%s, 0, len(v))\n", field, gen.collectionType(collection, "", items))
+			gen.emit("\t\tfor _, item := range v {\n")
+			gen.emit("\t\t\tif item == nil {\n\t\t\t\tcontinue\n\t\t\t}\n")
+			gen.emit("\t\t\tx, err := expand%s(item.(map[string]interface{}))\n", capitalize(string(items)))
+			gen.emit("\t\t\tif err != nil {\n\t\t\t\treturn nil, err\n\t\t\t}\n")
+			gen.emit("\t\t\t%s = append(%s, x)\n", field, field)
+			gen.emit("\t\t}\n\t}\n")
+		}
+	}
+	gen.emit("\treturn o, nil\n}\n")
+
+	//from the client type to the attributes
+	gen.emit("\nfunc flatten%s(o *%s) map[string]interface{} {\n", cName, goName)
+	gen.emit("\tm := make(map[string]interface{})\n")
+	for _, f := range fields {
+		kind, items, _ := gen.fieldKind(f)
+		if kind == "" || ((kind == "block" || kind == "blocks") && gen.recursive(tref, items)) {
+			continue
+		}
+		attr := attributeName(tref, f)
+		field := "o." + capitalize(string(f.Name))
+		switch kind {
+		case "scalar":
+			s := gen.scalar(items)
+			if gen.isPointer(f) {
+				value := "*" + field
+				if strings.HasPrefix(s.fromGo, "%s.") {
+					value = "(" + value + ")"
+				}
+				gen.emit("\tif %s != nil {\n\t\tm[%q] = %s\n\t}\n", field, attr, fmt.Sprintf(s.fromGo, value))
+			} else if gen.registry.FindBaseType(items) == rdl.BaseTypeUUID {
+				gen.emit("\tif %s != nil {\n\t\tm[%q] = %s\n\t}\n", field, attr, fmt.Sprintf(s.fromGo, field))
+			} else {
+				gen.emit("\tm[%q] = %s\n", attr, fmt.Sprintf(s.fromGo, field))
+			}
+		case "list":
+			s := gen.scalar(items)
+			gen.emit("\tif %s != nil {\n", field)
+			gen.emit("\t\tlist := make([]interface{}, 0, len(%s))\n", field)
+			gen.emit("\t\tfor _, item := range %s {\n", field)
+			gen.emit("\t\t\tlist = append(list, %s)\n", fmt.Sprintf(s.fromGo, "item"))
+			gen.emit("\t\t}\n\t\tm[%q] = list\n\t}\n", attr)
+		case "map":
+			s := gen.scalar(items)
+			gen.emit("\tif %s != nil {\n", field)
+			gen.emit("\t\tvalues := make(map[string]interface{}, len(%s))\n", field)
+			gen.emit("\t\tfor k, item := range %s {\n", field)
+			gen.emit("\t\t\tvalues[string(k)] = %s\n", fmt.Sprintf(s.fromGo, "item"))
+			gen.emit("\t\t}\n\t\tm[%q] = values\n\t}\n", attr)
+		case "block":
+			gen.emit("\tif %s != nil {\n", field)
+			gen.emit("\t\tm[%q] = []interface{}{flatten%s(%s)}\n", attr, capitalize(string(items)), field)
+			gen.emit("\t}\n")
+		case "blocks":
+			gen.emit("\tif %s != nil {\n", field)
+			gen.emit("\t\tlist := make([]interface{}, 0, len(%s))\n", field)
+			gen.emit("\t\tfor _, item := range %s {\n", field)
+			gen.emit("\t\t\tif item != nil {\n\t\t\t\tlist = append(list, flatten%s(item))\n\t\t\t}\n", capitalize(string(items)))
+			gen.emit("\t\t}\n\t\tm[%q] = list\n\t}\n", attr)
+		}
+	}
+	gen.emit("\treturn m\n}\n")
+}
+
+// collectionType returns the Go type of an array (no keys) or map field.
+func (gen *terraformGenerator) collectionType(collection string, keys string, items rdl.TypeRef) string {
+	switch collection {
+	case "[]":
+		return "[]" + gen.itemGoType(items)
+	case "map":
+		return "map[" + keys + "]" + gen.itemGoType(items)
+	}
+	return collection
+}
+
+// recursive tells whether the struct type is nested in itself through the given field type,
+// which Terraform schemas cannot express.
+func (gen *terraformGenerator) recursive(tref rdl.TypeRef, nested rdl.TypeRef) bool {
+	visited := make(map[rdl.TypeRef]bool)
+	var reaches func(from rdl.TypeRef) bool
+	reaches = func(from rdl.TypeRef) bool {
+		if from == tref {
+			return true
+		}
+		if visited[from] {
+			return false
+		}
+		visited[from] = true
+		t := gen.registry.FindType(from)
+		if t == nil {
+			return false
+		}
+		for _, f := range flattenedFields(gen.registry, t) {
+			if kind, items, _ := gen.fieldKind(f); (kind == "block" || kind == "blocks") && reaches(items) {
+				return true
+			}
+		}
+		return false
+	}
+	return reaches(nested)
+}
+
+func (gen *terraformGenerator) defaultValue(f *rdl.StructFieldDef) string {
+	switch v := f.Default.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case float64:
+		switch gen.registry.FindBaseType(f.Type) {
+		case rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+			s := fmt.Sprint(v)
+			if !strings.ContainsAny(s, ".e") {
+				s += ".0"
+			}
+			return s
+		}
+		return fmt.Sprint(int64(v))
+	}
+	return fmt.Sprint(f.Default)
+}
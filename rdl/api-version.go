@@ -0,0 +1,102 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strconv"
+	"strings"
+)
+
+//
+// A schema can declare the variants of its resources for several versions of its API side by
+// side, by annotating them with the versions they belong to, e.g. x_version="1" or
+// x_version="1,2". Resources without the annotation belong to every version. generate
+// --api-version keeps only the resources of one version, so each version gets its own client
+// and server from the same schema.
+//
+
+// resourceVersions returns the API versions listed in the x_version annotation of the resource,
+// or nil if it belongs to every version.
+func resourceVersions(r *rdl.Resource) []string {
+	annotation, ok := r.Annotations["x_version"]
+	if !ok {
+		return nil
+	}
+	var versions []string
+	for _, v := range strings.Split(annotation, ",") {
+		if v = normalizeAPIVersion(v); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// normalizeAPIVersion makes "v2", "V2", and "2" the same version.
+func normalizeAPIVersion(version string) string {
+	version = strings.TrimSpace(version)
+	if len(version) > 1 && (version[0] == 'v' || version[0] == 'V') {
+		version = version[1:]
+	}
+	return version
+}
+
+// inAPIVersion tells whether the resource belongs to the API version.
+func inAPIVersion(r *rdl.Resource, version string) bool {
+	versions := resourceVersions(r)
+	if versions == nil {
+		return true
+	}
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesAPIVersion tells whether two resources belong to a common API version, i.e. would both
+// be generated for it.
+func sharesAPIVersion(r1 *rdl.Resource, r2 *rdl.Resource) bool {
+	versions := resourceVersions(r1)
+	if versions == nil {
+		return true
+	}
+	for _, v := range versions {
+		if inAPIVersion(r2, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectAPIVersion drops the resources of the schema that do not belong to the API version. A
+// numeric version also becomes the version of the schema, which the Java generators put in the
+// default base path, e.g. /name/v2.
+func selectAPIVersion(schema *rdl.Schema, version string) error {
+	version = normalizeAPIVersion(version)
+	if version == "" {
+		return nil
+	}
+	declared := false
+	var resources []*rdl.Resource
+	for _, r := range schema.Resources {
+		if inAPIVersion(r, version) {
+			resources = append(resources, r)
+			if resourceVersions(r) != nil {
+				declared = true
+			}
+		}
+	}
+	if !declared {
+		return fmt.Errorf("No resource of the schema is annotated with x_version for API version %s", version)
+	}
+	schema.Resources = resources
+	if n, err := strconv.ParseInt(version, 10, 32); err == nil {
+		v := int32(n)
+		schema.Version = &v
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//
+// generate accepts several schema files, directories of them, or glob patterns, and generates
+// each schema in turn. A schema may refer to the types of another schema of the batch without
+// including it: the file that defines a type the schema does not resolve is included for it,
+// and the resources that come along with the include are dropped, so that only the types are
+// shared. With an output directory, each schema is generated into the subdirectory named after
+// its package, except by the Java generators, which lay out their output by namespace anyway.
+//
+
+var undefinedTypeError = regexp.MustCompile(`(?:No such type|Undefined type): ([A-Za-z_][A-Za-z0-9_]*)`)
+var typeDefinition = regexp.MustCompile(`(?m)^\s*type\s+([A-Za-z_][A-Za-z0-9_]*)`)
+
+// schemaFiles expands the schema arguments of generate: a directory stands for the .rdl files
+// in it, and a pattern for the files it matches.
+func schemaFiles(args []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, arg := range args {
+		if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+			matches, _ := filepath.Glob(filepath.Join(arg, "*.rdl"))
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("No schema files in %s", arg)
+			}
+			sort.Strings(matches)
+			for _, m := range matches {
+				add(m)
+			}
+		} else if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("No schema files match %s", arg)
+			}
+			sort.Strings(matches)
+			for _, m := range matches {
+				add(m)
+			}
+		} else {
+			add(arg)
+		}
+	}
+	return files, nil
+}
+
+// readSchemaBatch parses the schema files, resolving the types each refers to from the others.
+func readSchemaBatch(files []string, pretty bool, warning bool, strict bool, includePath []string) ([]*rdl.Schema, error) {
+	definedIn := make(map[string]string)
+	for _, file := range files {
+		if filepath.Ext(file) == ".json" {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range typeDefinition.FindAllStringSubmatch(string(data), -1) {
+			if _, ok := definedIn[m[1]]; !ok {
+				definedIn[m[1]] = file
+			}
+		}
+	}
+	schemas := make([]*rdl.Schema, 0, len(files))
+	for _, file := range files {
+		var schema *rdl.Schema
+		var name rdl.Identifier
+		var err error
+		if filepath.Ext(file) == ".json" {
+			schema, name, err = readSchema(file, pretty, warning, strict, includePath)
+		} else {
+			schema, name, err = readSharingTypes(file, definedIn, pretty, warning, strict, includePath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if schema.Name == "" {
+			schema.Name = name
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// readSharingTypes parses the schema file, including the files of the batch that define the
+// types it refers to but does not define, one at a time as the parser reports them.
+func readSharingTypes(file string, definedIn map[string]string, pretty bool, warning bool, strict bool, includePath []string) (*rdl.Schema, rdl.Identifier, error) {
+	var shared []string
+	for {
+		schema, err := parseWithSharedTypes(file, includePath, shared, pretty, strict, warning)
+		if err == nil {
+			sharedNames := make(map[string]bool)
+			for _, f := range shared {
+				sharedNames[filepath.Base(f)] = true
+			}
+			var resources []*rdl.Resource
+			for _, r := range schema.Resources {
+				if !sharedNames[r.Annotations["x_included_from"]] {
+					resources = append(resources, r)
+				}
+			}
+			schema.Resources = resources
+			base := filepath.Base(file)
+			return schema, rdl.Identifier(strings.TrimSuffix(base, filepath.Ext(base))), nil
+		}
+		m := undefinedTypeError.FindStringSubmatch(err.Error())
+		if m == nil {
+			return nil, "", err
+		}
+		other, ok := definedIn[m[1]]
+		if !ok || other == file || containsString(shared, other) {
+			return nil, "", err
+		}
+		shared = append(shared, other)
+	}
+}
+
+// batchOutputDir returns the directory the generator writes the output for the schema to.
+func batchOutputDir(outdir string, generator string, schema *rdl.Schema, config *GenerationConfig) string {
+	if outdir == "" || strings.HasPrefix(generator, "java-") {
+		return outdir
+	}
+	return filepath.Join(outdir, generationPackage(schema, config.targetNamespace(schema, generator, "")))
+}
+
+// generateBatch runs the generators for each of the schemas, in the order of their files.
+func generateBatch(banner string, flavors string, outdir string, librdl string, prefixEnums bool, preciseTypes bool, ns string, config *GenerationConfig, schemas []*rdl.Schema, files []string, untaggedUnions []string, base string, externalOptions []string, apiVersion string, tags string) error {
+	if ns != "" {
+		return fmt.Errorf("Cannot use --ns with several schemas, each is generated in its own namespace")
+	}
+	if outdir != "" && filepath.Ext(outdir) != "" {
+		return fmt.Errorf("The output for several schemas must be a directory, not %s", outdir)
+	}
+	targets := strings.Split(flavors, ",")
+	for i, schema := range schemas {
+		if err := selectAPIVersion(schema, apiVersion); err != nil {
+			return fmt.Errorf("%s: %v", files[i], err)
+		}
+		if err := selectTags(schema, tags); err != nil {
+			return fmt.Errorf("%s: %v", files[i], err)
+		}
+		for _, target := range targets {
+			s := schema
+			if len(targets) > 1 {
+				copied, err := copySchema(schema)
+				if err != nil {
+					return err
+				}
+				s = copied
+			}
+			dir := batchOutputDir(outdir, target, s, config)
+			if dir != "" {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					return err
+				}
+			}
+			err := generateTargets(banner, target, dir, librdl, prefixEnums, preciseTypes, ns, config, s, files[i], untaggedUnions, base, externalOptions)
+			if err != nil {
+				return fmt.Errorf("%s: %v", files[i], err)
+			}
+		}
+	}
+	return nil
+}
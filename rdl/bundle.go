@@ -0,0 +1,224 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//
+// generate -o - and --single-file bundle the output of the generators that write many files,
+// for build pipelines that want a stream rather than a directory tree. The generator writes
+// into a scratch directory, as --dry-run does, and then
+//
+//   -o -                   writes a tar stream of the files to stdout
+//   --single-file [-o f]   writes them as one file, to f or to stdout
+//
+// A single file of Go files is a Go file, of their package, with their imports merged. Other
+// files are concatenated, each preceded by a comment naming it.
+//
+
+// bundleGenerate runs regenerate, passing it the scratch directory to generate to, and writes
+// the files it generates as a tar stream or a single file, as outfile and singleFile tell.
+func bundleGenerate(outfile string, singleFile bool, regenerate func(string) error) error {
+	if singleFile && outfile != "" && outfile != "-" {
+		if fi, err := os.Stat(outfile); err == nil && fi.IsDir() {
+			return fmt.Errorf("generate --single-file needs an output file (-o), not the directory %s", outfile)
+		}
+	}
+	tmp, err := ioutil.TempDir("", "rdl-bundle")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	err = regenerate(tmp)
+	takeGenerated() //the files are in the scratch directory, nothing is recorded
+	if err != nil {
+		return err
+	}
+	names, err := bundledFiles(tmp)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("The generator wrote no files to bundle")
+	}
+	var data []byte
+	if singleFile {
+		data, err = singleFileBundle(tmp, names)
+	} else {
+		data, err = tarBundle(tmp, names)
+	}
+	if err != nil {
+		return err
+	}
+	if outfile == "" || outfile == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outfile), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(outfile, data, 0644); err != nil {
+		return err
+	}
+	recordGenerated(outfile)
+	return nil
+}
+
+// bundledFiles returns the files under the directory, relative to it, in order.
+func bundledFiles(dir string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && filepath.Base(rel) != manifestName {
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// tarBundle returns the tar stream of the files, with fixed times, so that it is the same for
+// the same output.
+func tarBundle(dir string, names []string) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Unix(0, 0), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// singleFileBundle returns the files as one: a Go file if they all are, or else their
+// concatenation.
+func singleFileBundle(dir string, names []string) ([]byte, error) {
+	goFiles := true
+	for _, name := range names {
+		if filepath.Ext(name) != ".go" {
+			goFiles = false
+		}
+	}
+	if goFiles {
+		return mergeGoFiles(dir, names)
+	}
+	var buf bytes.Buffer
+	for i, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(bundleSeparator(name) + "\n")
+		buf.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// bundleSeparator returns the comment naming the file in a concatenation, in its syntax.
+func bundleSeparator(name string) string {
+	switch filepath.Ext(name) {
+	case ".py", ".sh", ".yaml", ".yml", ".tf", ".toml":
+		return "# ---- " + name + " ----"
+	case ".md", ".html", ".xml":
+		return "<!-- ---- " + name + " ---- -->"
+	}
+	return "// ---- " + name + " ----"
+}
+
+// mergeGoFiles returns the Go files of one package as a single one, with the header comment of
+// the first, the imports of all of them, and their declarations in order.
+func mergeGoFiles(dir string, names []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkg := ""
+	header := ""
+	imports := make(map[string]bool)
+	var importList []string
+	var bodies []string
+	for _, name := range names {
+		src, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if pkg == "" {
+			pkg = file.Name.Name
+			header = string(src[:fset.Position(file.Package).Offset])
+		} else if file.Name.Name != pkg {
+			return nil, fmt.Errorf("Cannot bundle the Go files of packages %s and %s into a single file", pkg, file.Name.Name)
+		}
+		for _, spec := range file.Imports {
+			imp := spec.Path.Value
+			if spec.Name != nil {
+				imp = spec.Name.Name + " " + imp
+			}
+			if !imports[imp] {
+				imports[imp] = true
+				importList = append(importList, imp)
+			}
+		}
+		//the declarations follow the package clause and the imports
+		end := file.Name.End()
+		for _, decl := range file.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+				end = gd.End()
+			}
+		}
+		bodies = append(bodies, strings.TrimSpace(string(src[fset.Position(end).Offset:])))
+	}
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("package " + pkg + "\n")
+	if len(importList) > 0 {
+		sort.Strings(importList)
+		buf.WriteString("\nimport (\n")
+		for _, imp := range importList {
+			buf.WriteString("\t" + imp + "\n")
+		}
+		buf.WriteString(")\n")
+	}
+	for _, body := range bodies {
+		if body != "" {
+			buf.WriteString("\n" + body + "\n")
+		}
+	}
+	return format.Source(buf.Bytes())
+}
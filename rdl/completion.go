@@ -0,0 +1,459 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+//
+// The completion command prints a completion script of rdl for bash, zsh, or fish, completing the
+// commands and their options, the generators (those listed by the usage and the rdl-gen-* ones found
+// in $PATH when completing), and the -x options of the generators. The generators and -x options
+// are read from the usage, so that the scripts list them as the help does.
+//
+
+// completionCommand describes a command for the completion scripts. The kind of an option value or
+// argument is file, dir, generator, xoption, text (not completed), or a list of words like a|b, and
+// a kind ending with ... is that of the remaining arguments.
+type completionCommand struct {
+	name    string
+	help    string
+	options []completionOption
+	args    []string
+}
+
+type completionOption struct {
+	name       string
+	value      string //the kind of the value, empty for a flag
+	repeatable bool
+	help       string
+}
+
+var completionGlobalOptions = []completionOption{
+	{"-p", "", false, "show errors and non-exported results in a prettier way"},
+	{"-w", "", false, "suppress warnings"},
+	{"-s", "", false, "parse in strict mode"},
+	{"-I", "dir", true, "add the directory to the search path for included files"},
+}
+
+var completionCommands = []completionCommand{
+	{"help", "print extended help information", nil, nil},
+	{"version", "print the version of rdl", nil, nil},
+	{"parse", "parse the rdl file, to check syntax", []completionOption{
+		{"--positions", "", false, "print the schema as JSON, with the position of each type, field, and resource"},
+	}, []string{"file"}},
+	{"validate", "validate a data file for adherence to the schema", []completionOption{
+		{"-t", "text", false, "the name of the type of the data"},
+	}, []string{"file..."}},
+	{"generate", "generate output from the schema, using the generator", []completionOption{
+		{"-o", "file", false, "output file or directory, or - for a tar stream"},
+		{"-b", "text", false, "base path of the URL for server and client generators"},
+		{"-e", "", false, "prefix enum constants with their type name"},
+		{"-t", "", false, "generate precise type models"},
+		{"-l", "text", false, "import this package as rdl for the base types"},
+		{"-u", "text", true, "serialize this union type as an untagged union"},
+		{"-x", "xoption", true, "set an option of the generator"},
+		{"--ns", "text", false, "namespace for the generated code"},
+		{"--config", "file", false, "file mapping the namespaces of schemas to packages"},
+		{"--prune", "", false, "delete the files no longer generated"},
+		{"--watch", "", false, "regenerate whenever the schema changes"},
+		{"--dry-run", "", false, "print a diff of the files the generation would change"},
+		{"--single-file", "", false, "write the generated files as one"},
+		{"--api-version", "text", false, "generate only the resources of this API version"},
+		{"--tags", "text", false, "generate only the resources with these x_tags"},
+		{"--template", "dir", false, "render the Go templates of the directory instead of a generator"},
+	}, []string{"generator", "file..."}},
+	{"lint", "check the schema for style and correctness problems", []completionOption{
+		{"-c", "file", false, "JSON file setting the severity of lint rules"},
+		{"-r", "text", true, "set the severity of a lint rule, e.g. missing-comment=off"},
+	}, []string{"file"}},
+	{"fmt", "print the schema in canonical rdl formatting", []completionOption{
+		{"-w", "", false, "write the result to the schema file"},
+		{"-d", "", false, "print a diff of the changes"},
+	}, []string{"file"}},
+	{"explain", "print the resolved definition of a type of the schema", nil, []string{"file", "text"}},
+	{"stats", "print the counts, nesting, and dependencies of the types and resources", []completionOption{
+		{"--json", "", false, "print the stats as JSON"},
+	}, []string{"file"}},
+	{"graph", "print the dependency graph of the types and resources", []completionOption{
+		{"-o", "dot|mermaid|json", false, "the format of the graph"},
+	}, []string{"file"}},
+	{"verify-compat", "check the responses of a live server against the schema", []completionOption{
+		{"-H", "text", true, "a header to send with each request"},
+		{"-p", "text", true, "the value of a parameter, e.g. name=joe"},
+		{"--annotated", "", false, "probe only the resources annotated with x_verify"},
+	}, []string{"file", "text"}},
+	{"lsp", "run a language server for rdl files over stdin and stdout", nil, nil},
+	{"repl", "load the schema and query its types and resources interactively", nil, []string{"file"}},
+	{"completion", "print the completion script for a shell", nil, []string{"bash|zsh|fish"}},
+}
+
+// printCompletion prints the completion script for the shell.
+func printCompletion(shell string, out io.Writer) error {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion()
+	case "zsh":
+		script = zshCompletion()
+	case "fish":
+		script = fishCompletion()
+	default:
+		return fmt.Errorf("Unsupported shell '%s', use bash, zsh, or fish", shell)
+	}
+	_, err := io.WriteString(out, script)
+	return err
+}
+
+// usageSection returns the lines of the section of the usage with the header.
+func usageSection(header func(string) bool) []string {
+	var lines []string
+	in := false
+	for _, line := range strings.Split(usageText, "\n") {
+		if line != "" && !strings.HasPrefix(line, " ") {
+			in = header(line)
+			continue
+		}
+		if in {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// usageEntries returns the names listed in the sections of the usage with the header, i.e. the
+// first words of their lines indented by two spaces, without those keep rejects, in order and
+// without duplicates.
+func usageEntries(header func(string) bool, keep func(string) bool) []string {
+	var entries []string
+	seen := make(map[string]bool)
+	for _, line := range usageSection(header) {
+		if !strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "   ") {
+			continue
+		}
+		name := strings.Fields(line)[0]
+		if keep(name) && !seen[name] {
+			seen[name] = true
+			entries = append(entries, name)
+		}
+	}
+	return entries
+}
+
+// completionGenerators returns the generators listed by the usage.
+func completionGenerators() []string {
+	return usageEntries(func(header string) bool {
+		return strings.HasPrefix(header, "Generators ")
+	}, func(name string) bool {
+		return !strings.HasPrefix(name, "-") && !strings.Contains(name, "<")
+	})
+}
+
+// completionXOptions returns the -x options of the generators listed by the usage, e.g. clone=true.
+func completionXOptions() []string {
+	options := usageEntries(func(header string) bool {
+		return strings.HasSuffix(header, "(set with -x key=value):")
+	}, func(name string) bool {
+		return strings.Contains(name, "=")
+	})
+	sort.Strings(options)
+	return options
+}
+
+func commandNames() []string {
+	var names []string
+	for _, c := range completionCommands {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+const bashCompletionTemplate = `# bash completion for rdl, e.g. in ~/.bashrc: source <(rdl completion bash)
+
+# _rdl_value prints the kind of the value of the option of the command, if it takes one
+_rdl_value() {
+    case "$1 $2" in
+{{values}}    esac
+}
+
+# _rdl_options prints the options of the command
+_rdl_options() {
+    case $1 in
+{{options}}    esac
+}
+
+# _rdl_args prints the kinds of the arguments of the command
+_rdl_args() {
+    case $1 in
+{{args}}    esac
+}
+
+_rdl_complete() {
+    local kind=$1 cur=$2 prefix=""
+    case $kind in
+        file) COMPREPLY=($(compgen -f -- "$cur")) ;;
+        dir) COMPREPLY=($(compgen -d -- "$cur")) ;;
+        xoption) COMPREPLY=($(compgen -W "{{xoptions}}" -- "$cur")) ;;
+        generator)
+            # several generators are separated by commas, e.g. go-model,go-client
+            if [[ $cur == *,* ]]; then
+                prefix="${cur%,*},"
+            fi
+            COMPREPLY=($(compgen -P "$prefix" -W "{{generators}} $(compgen -c rdl-gen- | sed 's/^rdl-gen-//')" -- "${cur##*,}"))
+            ;;
+        *"|"*) COMPREPLY=($(compgen -W "${kind//|/ }" -- "$cur")) ;;
+    esac
+}
+
+_rdl() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local cmd="" word kind positional=0 i
+    local -a kinds
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        if [[ -n $(_rdl_value "$cmd" "$word") ]]; then
+            ((i++))
+        elif [[ $word != -* ]]; then
+            if [[ -z $cmd ]]; then
+                cmd=$word
+            else
+                ((positional++))
+            fi
+        fi
+    done
+    kind=$(_rdl_value "$cmd" "$prev")
+    if [[ -n $kind ]]; then
+        _rdl_complete "$kind" "$cur"
+        return
+    fi
+    if [[ -z $cmd ]]; then
+        COMPREPLY=($(compgen -W "{{commands}} $(_rdl_options "")" -- "$cur"))
+        return
+    fi
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$(_rdl_options "$cmd")" -- "$cur"))
+        return
+    fi
+    kinds=($(_rdl_args "$cmd"))
+    kind="${kinds[positional]}"
+    if [[ -z $kind && ${#kinds[@]} -gt 0 && ${kinds[${#kinds[@]}-1]} == *... ]]; then
+        kind="${kinds[${#kinds[@]}-1]}"
+    fi
+    _rdl_complete "${kind%...}" "$cur"
+}
+
+complete -o filenames -F _rdl rdl
+`
+
+func bashCompletion() string {
+	var values, options, args strings.Builder
+	addValues := func(cmd string, opts []completionOption) {
+		var names []string
+		for _, o := range opts {
+			if o.value != "" {
+				values.WriteString(fmt.Sprintf("        %q) echo %q ;;\n", cmd+" "+o.name, o.value))
+			}
+			names = append(names, o.name)
+		}
+		if len(names) > 0 {
+			options.WriteString(fmt.Sprintf("        %q) echo %q ;;\n", cmd, strings.Join(names, " ")))
+		}
+	}
+	addValues("", completionGlobalOptions)
+	for _, c := range completionCommands {
+		addValues(c.name, c.options)
+		if len(c.args) > 0 {
+			args.WriteString(fmt.Sprintf("        %s) echo %q ;;\n", c.name, strings.Join(c.args, " ")))
+		}
+	}
+	return strings.NewReplacer(
+		"{{values}}", values.String(),
+		"{{options}}", options.String(),
+		"{{args}}", args.String(),
+		"{{commands}}", strings.Join(commandNames(), " "),
+		"{{generators}}", strings.Join(completionGenerators(), " "),
+		"{{xoptions}}", strings.Join(completionXOptions(), " "),
+	).Replace(bashCompletionTemplate)
+}
+
+const zshCompletionTemplate = `#compdef rdl
+# zsh completion for rdl, e.g. saved as _rdl in a directory of $fpath: rdl completion zsh > _rdl
+
+_rdl_generators() {
+    local -a generators
+    generators=({{generators}} ${${(k)commands[(I)rdl-gen-*]}#rdl-gen-})
+    _values -s , generator $generators
+}
+
+_rdl() {
+    local curcontext="$curcontext" state line
+    local -a subcommands
+    _arguments -C \
+{{globals}}        '1:command:->command' \
+        '*::argument:->argument'
+    case $state in
+        command)
+            subcommands=(
+{{commands}}            )
+            _describe command subcommands
+            ;;
+        argument)
+            curcontext="${curcontext%:*:*}:rdl-$line[1]:"
+            case $line[1] in
+{{arguments}}            esac
+            ;;
+    esac
+}
+
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+    _rdl "$@"
+else
+    compdef _rdl rdl
+fi
+`
+
+// zshQuote returns the text in single quotes.
+func zshQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "'\\''", -1) + "'"
+}
+
+// zshAction returns the _arguments action completing the kind of value.
+func zshAction(kind string) string {
+	switch kind {
+	case "file":
+		return "_files"
+	case "dir":
+		return "_files -/"
+	case "generator":
+		return "_rdl_generators"
+	case "xoption":
+		return "(" + strings.Join(completionXOptions(), " ") + ")"
+	case "text":
+		return " "
+	}
+	return "(" + strings.Replace(kind, "|", " ", -1) + ")"
+}
+
+func zshSpecs(options []completionOption, args []string) []string {
+	var specs []string
+	for _, o := range options {
+		help := strings.NewReplacer("[", "\\[", "]", "\\]", ":", "\\:").Replace(o.help)
+		spec := o.name + "[" + help + "]"
+		if o.repeatable {
+			spec = "*" + spec
+		}
+		if o.value != "" {
+			spec += ":" + strings.TrimPrefix(o.name, "-") + ":" + zshAction(o.value)
+		}
+		specs = append(specs, spec)
+	}
+	for i, kind := range args {
+		if strings.HasSuffix(kind, "...") {
+			kind = strings.TrimSuffix(kind, "...")
+			specs = append(specs, "*:"+kind+":"+zshAction(kind))
+		} else {
+			specs = append(specs, fmt.Sprintf("%d:%s:%s", i+1, strings.Replace(kind, "|", " or ", -1), zshAction(kind)))
+		}
+	}
+	return specs
+}
+
+func zshCompletion() string {
+	var globals, commands, arguments strings.Builder
+	for _, spec := range zshSpecs(completionGlobalOptions, nil) {
+		globals.WriteString("        " + zshQuote(spec) + " \\\n")
+	}
+	for _, c := range completionCommands {
+		commands.WriteString("                " + zshQuote(c.name+":"+c.help) + "\n")
+		specs := zshSpecs(c.options, c.args)
+		if len(specs) == 0 {
+			continue
+		}
+		arguments.WriteString(fmt.Sprintf("                %s)\n", c.name))
+		arguments.WriteString("                    _arguments")
+		for _, spec := range specs {
+			arguments.WriteString(" \\\n                        " + zshQuote(spec))
+		}
+		arguments.WriteString("\n                    ;;\n")
+	}
+	return strings.NewReplacer(
+		"{{globals}}", globals.String(),
+		"{{commands}}", commands.String(),
+		"{{arguments}}", arguments.String(),
+		"{{generators}}", strings.Join(completionGenerators(), " "),
+	).Replace(zshCompletionTemplate)
+}
+
+const fishCompletionTemplate = `# fish completion for rdl, e.g. rdl completion fish > ~/.config/fish/completions/rdl.fish
+
+function __rdl_generators
+    printf '%s\n' {{generators}}
+    complete -C rdl-gen- | string replace -r '^rdl-gen-([^\t]+).*' '$1'
+end
+
+complete -c rdl -f
+{{completions}}`
+
+// fishQuote returns the text in single quotes.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer("\\", "\\\\", "'", "\\'").Replace(s) + "'"
+}
+
+// fishValue returns the arguments of complete completing the kind of value.
+func fishValue(kind string) string {
+	switch kind {
+	case "file":
+		return "-F"
+	case "dir":
+		return "-x -a '(__fish_complete_directories)'"
+	case "generator":
+		return "-a '(__rdl_generators)'"
+	case "xoption":
+		return "-x -a " + fishQuote(strings.Join(completionXOptions(), " "))
+	case "text":
+		return "-x"
+	}
+	return "-x -a " + fishQuote(strings.Replace(kind, "|", " ", -1))
+}
+
+func fishCompletion() string {
+	var b strings.Builder
+	option := func(condition string, o completionOption) {
+		b.WriteString("complete -c rdl -n " + condition)
+		if strings.HasPrefix(o.name, "--") {
+			b.WriteString(" -l " + o.name[2:])
+		} else {
+			b.WriteString(" -s " + o.name[1:])
+		}
+		if o.value != "" {
+			b.WriteString(" " + fishValue(o.value))
+		}
+		b.WriteString(" -d " + fishQuote(o.help) + "\n")
+	}
+	for _, o := range completionGlobalOptions {
+		option("__fish_use_subcommand", o)
+	}
+	for _, c := range completionCommands {
+		b.WriteString(fmt.Sprintf("complete -c rdl -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.help)))
+	}
+	for _, c := range completionCommands {
+		condition := fishQuote("__fish_seen_subcommand_from " + c.name)
+		for _, o := range c.options {
+			option(condition, o)
+		}
+		for _, kind := range c.args {
+			if kind = strings.TrimSuffix(kind, "..."); kind != "text" {
+				b.WriteString(fmt.Sprintf("complete -c rdl -n %s %s\n", condition, fishValue(kind)))
+			}
+		}
+	}
+	return strings.NewReplacer(
+		"{{generators}}", strings.Join(completionGenerators(), " "),
+		"{{completions}}", b.String(),
+	).Replace(fishCompletionTemplate)
+}
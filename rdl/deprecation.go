@@ -0,0 +1,45 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// A type, field, enum element, or resource annotated with x_deprecated, set to the reason (or
+// empty), is marked deprecated in the generated code: a "Deprecated:" paragraph in its Go
+// comment, which linters and editors flag at its uses, and @Deprecated in Java.
+//
+
+// deprecation returns the reason the annotated definition is deprecated for, and whether it is.
+func deprecation(annotations map[rdl.ExtendedAnnotation]string) (string, bool) {
+	reason, ok := annotations["x_deprecated"]
+	if !ok {
+		return "", false
+	}
+	if reason = strings.TrimSpace(reason); reason == "" {
+		reason = "No longer to be used."
+	}
+	return reason, true
+}
+
+// goDeprecatedComment returns the Deprecated paragraph of the Go comment of a deprecated
+// definition at the indent, or "".
+func goDeprecatedComment(annotations map[rdl.ExtendedAnnotation]string, indent string) string {
+	if reason, ok := deprecation(annotations); ok {
+		return indent + "// Deprecated: " + reason + "\n"
+	}
+	return ""
+}
+
+// javaDeprecated returns the @Deprecated annotation of a deprecated definition at the indent, or
+// "".
+func javaDeprecated(annotations map[rdl.ExtendedAnnotation]string, indent string) string {
+	if _, ok := deprecation(annotations); ok {
+		return indent + "@Deprecated\n"
+	}
+	return ""
+}
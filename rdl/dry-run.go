@@ -0,0 +1,145 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+//
+// generate --dry-run generates into a scratch directory, as --watch does, and prints a unified
+// diff of every output file that would change, a new file being diffed against /dev/null,
+// without writing anything. With --prune, the stale files that would be deleted are diffed
+// as deletions. It exits with status 1 if anything would change, so that CI can check that the
+// generated code checked in is up to date with the schema.
+//
+
+// dryRunGenerate runs regenerate, passing it the scratch directory (or file) to generate to,
+// prints the diff of its output against the files in outfile, and returns whether they differ.
+func dryRunGenerate(outfile string, generator string, prune bool, regenerate func(string) error) (bool, error) {
+	if outfile == "" {
+		return false, fmt.Errorf("generate --dry-run needs an output file or directory (-o)")
+	}
+	tmp, err := ioutil.TempDir("", "rdl-dry-run")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(tmp)
+	root := outputRoot(outfile)
+	target := tmp
+	if root != outfile {
+		target = filepath.Join(tmp, filepath.Base(outfile))
+	}
+	err = regenerate(target)
+	takeGenerated() //the files are in the scratch directory, nothing is recorded
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	current := make(map[string]bool)
+	err = filepath.Walk(tmp, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(tmp, path)
+		if err != nil || filepath.Base(rel) == manifestName {
+			return err
+		}
+		current[filepath.ToSlash(rel)] = true
+		dest := filepath.Join(root, rel)
+		if _, err := os.Stat(dest); err != nil {
+			changed = true
+			return printDiff(os.DevNull, os.DevNull, dest, path)
+		}
+		if same, err := sameContent(dest, path); err != nil || same {
+			return err
+		}
+		changed = true
+		return printDiff(dest+".orig", dest, dest, path)
+	})
+	if err != nil || !prune {
+		return changed, err
+	}
+	stale, err := staleOutputFiles(root, generator, current)
+	for _, path := range stale {
+		changed = true
+		if err := printDiff(path, path, os.DevNull, os.DevNull); err != nil {
+			return changed, err
+		}
+	}
+	return changed, err
+}
+
+// exitOnChanges exits with status 1 if the dry run found changes, after reporting its error.
+func exitOnChanges(changed bool, err error) {
+	exitOnError(err)
+	if changed {
+		os.Exit(1)
+	}
+}
+
+func sameContent(a string, b string) (bool, error) {
+	dataA, err := ioutil.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	dataB, err := ioutil.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(dataA, dataB), nil
+}
+
+// printDiff prints the unified diff of the two files, labeled as given.
+func printDiff(oldLabel string, oldPath string, newLabel string, newPath string) error {
+	var out bytes.Buffer
+	cmd := exec.Command("diff", "-u", "--label", oldLabel, "--label", newLabel, oldPath, newPath)
+	cmd.Stdout = &out
+	err := cmd.Run()
+	fmt.Print(out.String())
+	if e, ok := err.(*exec.ExitError); ok && e.ExitCode() == 1 {
+		return nil //diff exits with 1 when the files differ
+	}
+	return err
+}
+
+// staleOutputFiles returns the files the manifest of the output directory lists for the
+// generator, that it no longer generates, and that --prune would delete.
+func staleOutputFiles(root string, generator string, current map[string]bool) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, manifestName))
+	if err != nil {
+		return nil, nil
+	}
+	manifest := &GenerationManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("Cannot read %s: %v", filepath.Join(root, manifestName), err)
+	}
+	others := make(map[string]bool)
+	for gen, names := range manifest.Generators {
+		if gen != generator {
+			for _, name := range names {
+				others[name] = true
+			}
+		}
+	}
+	var stale []string
+	for _, name := range manifest.Generators[generator] {
+		local, ok := localFileName(name)
+		if !ok || current[name] || others[name] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, local)); err == nil {
+			stale = append(stale, filepath.Join(root, local))
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// Enum element annotations for the Go and Java models, so that constants can be renamed without
+// changing the wire format:
+//
+//   type Color enum {
+//       RED (x_wire="red"),
+//       GREEN (x_wire="green", x_aliases="VERT,GRUN"),
+//       BLUE (x_deprecated="use GREEN")
+//   }
+//
+// An element with x_wire is written as that string instead of its symbol. The symbols of
+// x_aliases, a comma-separated list, are read as the element but never written, as are its symbol
+// and its wire value. An element with x_deprecated, whose value is the reason, is marked
+// deprecated: a Deprecated comment in Go, @Deprecated in Java.
+//
+
+// enumWireName returns the string the element is written as.
+func enumWireName(elem *rdl.EnumElementDef) string {
+	if wire := strings.TrimSpace(elem.Annotations["x_wire"]); wire != "" {
+		return wire
+	}
+	return string(elem.Symbol)
+}
+
+// enumReadNames returns the strings the element is read from, besides its wire name: its symbol,
+// if it is written as another one, and its aliases.
+func enumReadNames(elem *rdl.EnumElementDef) []string {
+	var names []string
+	if enumWireName(elem) != string(elem.Symbol) {
+		names = append(names, string(elem.Symbol))
+	}
+	for _, alias := range strings.Split(elem.Annotations["x_aliases"], ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
+
+// hasEnumWireNames tells whether an element of the enum is written as another string than its
+// symbol.
+func hasEnumWireNames(et *rdl.EnumTypeDef) bool {
+	for _, elem := range et.Elements {
+		if enumWireName(elem) != string(elem.Symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEnumReadNames tells whether an element of the enum is read from another string than its
+// wire name.
+func hasEnumReadNames(et *rdl.EnumTypeDef) bool {
+	for _, elem := range et.Elements {
+		if len(enumReadNames(elem)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEnumNames returns an error if two elements of the enum are written as or read from the
+// same string.
+func checkEnumNames(et *rdl.EnumTypeDef) error {
+	seen := make(map[string]rdl.Identifier)
+	for _, elem := range et.Elements {
+		for _, name := range append([]string{enumWireName(elem)}, enumReadNames(elem)...) {
+			if other, ok := seen[name]; ok && other != elem.Symbol {
+				return fmt.Errorf("Enum %s: '%s' names both %s and %s", et.Name, name, other, elem.Symbol)
+			}
+			seen[name] = elem.Symbol
+		}
+	}
+	return nil
+}
+
+// enumTypeDef returns the enum the type reference names, or nil.
+func enumTypeDef(reg rdl.TypeRegistry, tref rdl.TypeRef) *rdl.EnumTypeDef {
+	for t := reg.FindType(tref); t != nil; {
+		if t.Variant == rdl.TypeVariantEnumTypeDef {
+			return t.EnumTypeDef
+		}
+		_, super, _ := rdl.TypeInfo(t)
+		if super == tref {
+			return nil
+		}
+		tref = super
+		t = reg.FindType(tref)
+	}
+	return nil
+}
+
+// enumWireValue returns the string the symbol of the enum is written as.
+func enumWireValue(reg rdl.TypeRegistry, tref rdl.TypeRef, symbol string) string {
+	if et := enumTypeDef(reg, tref); et != nil {
+		for _, elem := range et.Elements {
+			if string(elem.Symbol) == symbol {
+				return enumWireName(elem)
+			}
+		}
+	}
+	return symbol
+}
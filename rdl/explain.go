@@ -0,0 +1,311 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"sort"
+	"strings"
+)
+
+//
+// The explain command prints what the generators see of a type once the schema is resolved: its
+// definition, the chain of types it derives from, the constraints it ends up with (its own and
+// inherited ones), the fields of a struct including those of its supertypes, and the types and
+// resources that refer to it, directly or through other types.
+//
+
+type typeExplainer struct {
+	schema   *rdl.Schema
+	registry rdl.TypeRegistry
+	inline   map[rdl.TypeRef]*rdl.Type //the types of the fields declared with constraints
+	f        *schemaFormatter
+}
+
+// explainType prints the resolved definition of the named type of the schema.
+func explainType(schema *rdl.Schema, typeName string) error {
+	reg := rdl.NewTypeRegistry(schema)
+	t := reg.FindType(rdl.TypeRef(typeName))
+	if t == nil {
+		return fmt.Errorf("Type '%s' is not defined in the schema", typeName)
+	}
+	fmt.Print(newTypeExplainer(schema, reg).explain(t))
+	return nil
+}
+
+func newTypeExplainer(schema *rdl.Schema, reg rdl.TypeRegistry) *typeExplainer {
+	inline := inlineFieldTypes(schema)
+	return &typeExplainer{schema: schema, registry: reg, inline: inline, f: &schemaFormatter{registry: reg, inline: inline}}
+}
+
+func (e *typeExplainer) explain(t *rdl.Type) string {
+	tName, _, _ := rdl.TypeInfo(t)
+	f := e.f
+	if t.Variant == rdl.TypeVariantBaseType {
+		f.printf("%s is a base type\n", tName)
+	} else {
+		f.formatType(t)
+	}
+	f.printf("\nBase types: %s\n", strings.Join(e.chain(t), " -> "))
+	if constraints := e.constraints(t, tName); len(constraints) > 0 {
+		f.printf("\nConstraints:\n")
+		for _, c := range constraints {
+			f.printf("    %s\n", c)
+		}
+	}
+	if e.registry.BaseType(t) == rdl.BaseTypeStruct && t.Variant == rdl.TypeVariantStructTypeDef {
+		f.printf("\nFields:\n")
+		e.fields(t, tName)
+	}
+	refs := e.references(tName)
+	if len(refs) == 0 {
+		f.printf("\nNot referenced by any type or resource\n")
+	} else {
+		f.printf("\nReferenced by:\n")
+		for _, ref := range refs {
+			f.printf("    %s\n", ref)
+		}
+	}
+	return f.buf.String()
+}
+
+// chain returns the names of the type and of the types it derives from, down to its base type.
+func (e *typeExplainer) chain(t *rdl.Type) []string {
+	var names []string
+	seen := make(map[rdl.TypeName]bool)
+	for t != nil {
+		tName, tType, _ := rdl.TypeInfo(t)
+		if seen[tName] {
+			break
+		}
+		seen[tName] = true
+		names = append(names, string(tName))
+		if rdl.TypeRef(tName) == tType {
+			break
+		}
+		t = e.registry.FindType(tType)
+	}
+	return names
+}
+
+// constraints returns the effective constraints of the type, in the form of the type options,
+// each with the type that sets it if that is not self, e.g. the type itself.
+func (e *typeExplainer) constraints(t *rdl.Type, self rdl.TypeName) []string {
+	var result []string
+	set := make(map[string]bool)
+	add := func(owner rdl.TypeName, option string, value string) {
+		if set[option] {
+			return
+		}
+		set[option] = true
+		c := option + "=" + value
+		if owner != self {
+			c += " (from " + string(owner) + ")"
+		}
+		result = append(result, c)
+	}
+	size := func(owner rdl.TypeName, size *int32, minSize *int32, maxSize *int32) {
+		if size != nil {
+			add(owner, "size", fmt.Sprint(*size))
+		}
+		if minSize != nil {
+			add(owner, "minSize", fmt.Sprint(*minSize))
+		}
+		if maxSize != nil {
+			add(owner, "maxSize", fmt.Sprint(*maxSize))
+		}
+	}
+	seen := make(map[rdl.TypeName]bool)
+	for t != nil {
+		owner, tType, _ := rdl.TypeInfo(t)
+		if seen[owner] || rdl.TypeRef(owner) == tType {
+			break
+		}
+		seen[owner] = true
+		switch t.Variant {
+		case rdl.TypeVariantStringTypeDef:
+			td := t.StringTypeDef
+			if td.Pattern != "" {
+				add(owner, "pattern", fmt.Sprintf("%q", td.Pattern))
+			}
+			if td.Values != nil {
+				quoted := make([]string, 0, len(td.Values))
+				for _, v := range td.Values {
+					quoted = append(quoted, fmt.Sprintf("%q", v))
+				}
+				add(owner, "values", "["+strings.Join(quoted, ",")+"]")
+			}
+			size(owner, nil, td.MinSize, td.MaxSize)
+		case rdl.TypeVariantNumberTypeDef:
+			td := t.NumberTypeDef
+			if td.Min != nil {
+				add(owner, "min", numericValueString(*td.Min))
+			}
+			if td.Max != nil {
+				add(owner, "max", numericValueString(*td.Max))
+			}
+		case rdl.TypeVariantBytesTypeDef:
+			td := t.BytesTypeDef
+			size(owner, td.Size, td.MinSize, td.MaxSize)
+		case rdl.TypeVariantArrayTypeDef:
+			td := t.ArrayTypeDef
+			if td.Items != "" && td.Items != "Any" {
+				add(owner, "items", string(td.Items))
+			}
+			size(owner, td.Size, td.MinSize, td.MaxSize)
+		case rdl.TypeVariantMapTypeDef:
+			td := t.MapTypeDef
+			if td.Keys != "" && td.Keys != "Any" {
+				add(owner, "keys", string(td.Keys))
+			}
+			if td.Items != "" && td.Items != "Any" {
+				add(owner, "items", string(td.Items))
+			}
+			size(owner, td.Size, td.MinSize, td.MaxSize)
+		case rdl.TypeVariantStructTypeDef:
+			if t.StructTypeDef.Closed {
+				add(owner, "closed", "true")
+			}
+		}
+		t = e.registry.FindType(tType)
+	}
+	return result
+}
+
+// fields prints the fields of the struct, the inherited ones first, as the generators flatten
+// them, each as declared followed by the constraints of its type.
+func (e *typeExplainer) fields(t *rdl.Type, tName rdl.TypeName) {
+	st := t.StructTypeDef
+	if st.Type != "Struct" {
+		if super := e.registry.FindType(st.Type); super != nil && super.Variant == rdl.TypeVariantStructTypeDef {
+			e.fields(super, tName)
+		}
+	}
+	for _, fd := range st.Fields {
+		var options []string
+		tref := fd.Type
+		if inline := e.inline[fd.Type]; inline != nil {
+			tref = inlineBaseType(inline)
+			options = constraintOptions(inline)
+		}
+		if fd.Optional {
+			options = append(options, "optional")
+		}
+		if fd.Default != nil {
+			options = append(options, "default="+e.f.literal(fd.Type, fd.Default))
+		}
+		from := ""
+		if st.Name != tName {
+			from = " (from " + string(st.Name) + ")"
+		}
+		e.f.printf("    %s %s%s;%s\n", formatTypeRef(tref, fd.Items, fd.Keys), fd.Name, formatOptions(options, fd.Annotations), from)
+		//those set in the declaration of the field, and else by its type
+		self := rdl.TypeName("")
+		if e.inline[fd.Type] != nil {
+			self = rdl.TypeName(fd.Type)
+		}
+		if ft := e.registry.FindType(fd.Type); ft != nil {
+			for _, c := range e.constraints(ft, self) {
+				e.f.printf("        %s\n", c)
+			}
+		}
+	}
+}
+
+// typeRefs returns the types a type refers to, each with a description of where.
+func typeRefs(t *rdl.Type) map[rdl.TypeRef][]string {
+	refs := make(map[rdl.TypeRef][]string)
+	use := func(where string, list ...rdl.TypeRef) {
+		for _, ref := range list {
+			if ref != "" {
+				refs[ref] = append(refs[ref], where)
+			}
+		}
+	}
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		use("supertype", t.StructTypeDef.Type)
+		for _, f := range t.StructTypeDef.Fields {
+			use("field "+string(f.Name), f.Type, f.Items, f.Keys)
+		}
+	case rdl.TypeVariantArrayTypeDef:
+		use("supertype", t.ArrayTypeDef.Type)
+		use("items", t.ArrayTypeDef.Items)
+	case rdl.TypeVariantMapTypeDef:
+		use("supertype", t.MapTypeDef.Type)
+		use("keys", t.MapTypeDef.Keys)
+		use("items", t.MapTypeDef.Items)
+	case rdl.TypeVariantUnionTypeDef:
+		use("variant", t.UnionTypeDef.Variants...)
+	default:
+		_, tType, _ := rdl.TypeInfo(t)
+		use("supertype", tType)
+	}
+	return refs
+}
+
+// references describes the types and resources that refer to the type. A resource that does so
+// through other types (e.g. a struct with a field of the type) names the type it uses.
+func (e *typeExplainer) references(tName rdl.TypeName) []string {
+	var result []string
+	//contains has the type and every type that contains it, e.g. as a field or array items
+	contains := map[rdl.TypeRef]bool{rdl.TypeRef(tName): true}
+	for changed := true; changed; {
+		changed = false
+		for _, t := range declaredTypes(e.schema, e.inline) {
+			name, _, _ := rdl.TypeInfo(t)
+			if contains[rdl.TypeRef(name)] {
+				continue
+			}
+			for ref := range inlineTypeRefs(t, e.inline) {
+				if contains[ref] {
+					contains[rdl.TypeRef(name)] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	for _, t := range declaredTypes(e.schema, e.inline) {
+		name, _, _ := rdl.TypeInfo(t)
+		if wheres, ok := inlineTypeRefs(t, e.inline)[rdl.TypeRef(tName)]; ok && name != tName {
+			result = append(result, fmt.Sprintf("type %s (%s)", name, strings.Join(wheres, ", ")))
+		}
+	}
+	for _, r := range e.schema.Resources {
+		refs := make(map[rdl.TypeRef][]string)
+		use := func(where string, ref rdl.TypeRef) {
+			refs[ref] = append(refs[ref], where)
+		}
+		use("resource type", r.Type)
+		for _, in := range r.Inputs {
+			use("input "+string(in.Name), in.Type)
+		}
+		for _, out := range r.Outputs {
+			use("output "+string(out.Name), out.Type)
+		}
+		for code, ex := range r.Exceptions {
+			use("exception "+code, rdl.TypeRef(ex.Type))
+		}
+		var wheres []string
+		for ref, list := range refs {
+			if !contains[ref] {
+				continue
+			}
+			for _, where := range list {
+				if ref == rdl.TypeRef(tName) {
+					wheres = append(wheres, where)
+				} else {
+					wheres = append(wheres, where+" via "+string(ref))
+				}
+			}
+		}
+		if len(wheres) > 0 {
+			sort.Strings(wheres)
+			result = append(result, fmt.Sprintf("resource %s %s (%s)", r.Method, r.Path, strings.Join(wheres, ", ")))
+		}
+	}
+	return result
+}
@@ -0,0 +1,404 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//
+// The fmt command reprints a schema in canonical form: four space indents, options in a fixed
+// order followed by the annotations sorted by name, and comments rewrapped. The schema is
+// printed from its parsed form, so comments the parser does not keep (i.e. block comments and
+// comments not attached to a definition) are dropped.
+//
+
+const formatColumns = 100
+
+// formatSchemaFile formats the schema parsed from the file. It prints the result to stdout, or
+// rewrites the file if write is set, or prints a diff against the file if diff is set.
+func formatSchemaFile(schema *rdl.Schema, schemaFile string, write bool, diff bool) error {
+	formatted := formatSchema(schema)
+	if !write && !diff {
+		fmt.Print(formatted)
+		return nil
+	}
+	original, err := ioutil.ReadFile(schemaFile)
+	if err != nil {
+		return err
+	}
+	if string(original) == formatted {
+		return nil
+	}
+	if diff {
+		f, err := ioutil.TempFile("", "rdl-fmt")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(formatted)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		return printDiff(schemaFile+".orig", schemaFile, schemaFile, f.Name())
+	}
+	fi, err := os.Stat(schemaFile)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(schemaFile, []byte(formatted), fi.Mode())
+}
+
+type schemaFormatter struct {
+	registry rdl.TypeRegistry
+	inline   map[rdl.TypeRef]*rdl.Type
+	buf      bytes.Buffer
+}
+
+func formatSchema(schema *rdl.Schema) string {
+	f := &schemaFormatter{registry: rdl.NewTypeRegistry(schema), inline: inlineFieldTypes(schema)}
+	f.comment(schema.Comment, "")
+	if schema.Namespace != "" {
+		f.printf("namespace %s;\n", schema.Namespace)
+	}
+	if schema.Name != "" {
+		f.printf("name %s;\n", schema.Name)
+	}
+	if schema.Version != nil {
+		f.printf("version %d;\n", *schema.Version)
+	}
+	if schema.Base != "" {
+		f.printf("base %q;\n", schema.Base)
+	}
+	//definitions from included files are replaced by the include directive
+	var includes []string
+	included := make(map[string]bool)
+	include := func(annotations map[rdl.ExtendedAnnotation]string) bool {
+		name, ok := annotations["x_included_from"]
+		if ok && !included[name] {
+			included[name] = true
+			includes = append(includes, name)
+		}
+		return ok
+	}
+	var types []*rdl.Type
+	//the types of the fields declared with constraints are printed in their declarations
+	for _, t := range declaredTypes(schema, f.inline) {
+		if !include(typeAnnotations(t)) {
+			types = append(types, t)
+		}
+	}
+	var resources []*rdl.Resource
+	for _, r := range schema.Resources {
+		if !include(r.Annotations) {
+			resources = append(resources, r)
+		}
+	}
+	if len(includes) > 0 {
+		f.printf("\n")
+		for _, name := range includes {
+			f.printf("include %q;\n", name)
+		}
+	}
+	for _, t := range types {
+		f.printf("\n")
+		f.formatType(t)
+	}
+	for _, r := range resources {
+		f.printf("\n")
+		f.formatResource(r)
+	}
+	return f.buf.String()
+}
+
+func (f *schemaFormatter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&f.buf, format, args...)
+}
+
+// comment prints the comment as // lines, rewrapped to fit the columns.
+func (f *schemaFormatter) comment(comment string, indent string) {
+	comment = strings.Join(strings.Fields(comment), " ")
+	if comment == "" {
+		return
+	}
+	line := indent + "//"
+	for _, word := range strings.Split(comment, " ") {
+		if len(line)+1+len(word) > formatColumns && len(line) > len(indent)+2 {
+			f.printf("%s\n", line)
+			line = indent + "//"
+		}
+		line += " " + word
+	}
+	f.printf("%s\n", line)
+}
+
+func trailingComment(comment string) string {
+	comment = strings.Join(strings.Fields(comment), " ")
+	if comment == "" {
+		return ""
+	}
+	return " // " + comment
+}
+
+// options formats the options of a definition, followed by its annotations in name order.
+func formatOptions(options []string, annotations map[rdl.ExtendedAnnotation]string) string {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		if k != "x_included_from" {
+			keys = append(keys, string(k))
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := annotations[rdl.ExtendedAnnotation(k)]
+		if v == "" {
+			options = append(options, k)
+		} else {
+			options = append(options, fmt.Sprintf("%s=%q", k, v))
+		}
+	}
+	if len(options) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(options, ", ") + ")"
+}
+
+func formatTypeRef(tref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef) string {
+	switch tref {
+	case "Array":
+		if items != "" {
+			return fmt.Sprintf("Array<%s>", items)
+		}
+	case "Map":
+		if keys == "" {
+			keys = "String"
+		}
+		if items != "" {
+			return fmt.Sprintf("Map<%s,%s>", keys, items)
+		}
+	}
+	return string(tref)
+}
+
+func formatSize(options []string, size *int32, minSize *int32, maxSize *int32) []string {
+	if size != nil {
+		options = append(options, fmt.Sprintf("size=%d", *size))
+	}
+	if minSize != nil {
+		options = append(options, fmt.Sprintf("minSize=%d", *minSize))
+	}
+	if maxSize != nil {
+		options = append(options, fmt.Sprintf("maxSize=%d", *maxSize))
+	}
+	return options
+}
+
+// constraintOptions returns the constraints of the string, number, or bytes type, as options.
+func constraintOptions(t *rdl.Type) []string {
+	var options []string
+	switch t.Variant {
+	case rdl.TypeVariantStringTypeDef:
+		td := t.StringTypeDef
+		if td.Pattern != "" {
+			options = append(options, fmt.Sprintf("pattern=%q", td.Pattern))
+		}
+		if td.Values != nil {
+			quoted := make([]string, 0, len(td.Values))
+			for _, v := range td.Values {
+				quoted = append(quoted, fmt.Sprintf("%q", v))
+			}
+			options = append(options, "values=["+strings.Join(quoted, ",")+"]")
+		}
+		options = formatSize(options, nil, td.MinSize, td.MaxSize)
+	case rdl.TypeVariantNumberTypeDef:
+		td := t.NumberTypeDef
+		if td.Min != nil {
+			options = append(options, "min="+numericValueString(*td.Min))
+		}
+		if td.Max != nil {
+			options = append(options, "max="+numericValueString(*td.Max))
+		}
+	case rdl.TypeVariantBytesTypeDef:
+		td := t.BytesTypeDef
+		options = formatSize(options, td.Size, td.MinSize, td.MaxSize)
+	}
+	return options
+}
+
+func (f *schemaFormatter) literal(tref rdl.TypeRef, value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if f.registry.FindBaseType(tref) == rdl.BaseTypeEnum {
+			return v
+		}
+		return fmt.Sprintf("%q", v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case *rdl.Number:
+		return numericValueString(*v)
+	case rdl.Number:
+		return numericValueString(v)
+	}
+	return fmt.Sprint(value)
+}
+
+func (f *schemaFormatter) formatType(t *rdl.Type) {
+	switch t.Variant {
+	case rdl.TypeVariantStringTypeDef:
+		td := t.StringTypeDef
+		f.comment(td.Comment, "")
+		f.printf("type %s %s%s;\n", td.Name, td.Type, formatOptions(constraintOptions(t), td.Annotations))
+	case rdl.TypeVariantNumberTypeDef:
+		td := t.NumberTypeDef
+		f.comment(td.Comment, "")
+		f.printf("type %s %s%s;\n", td.Name, td.Type, formatOptions(constraintOptions(t), td.Annotations))
+	case rdl.TypeVariantBytesTypeDef:
+		td := t.BytesTypeDef
+		f.comment(td.Comment, "")
+		f.printf("type %s %s%s;\n", td.Name, td.Type, formatOptions(constraintOptions(t), td.Annotations))
+	case rdl.TypeVariantArrayTypeDef:
+		td := t.ArrayTypeDef
+		tref := string(td.Type)
+		if td.Type == "Array" {
+			tref = formatTypeRef(td.Type, td.Items, "")
+		}
+		f.comment(td.Comment, "")
+		f.printf("type %s %s%s;\n", td.Name, tref, formatOptions(formatSize(nil, td.Size, td.MinSize, td.MaxSize), td.Annotations))
+	case rdl.TypeVariantMapTypeDef:
+		td := t.MapTypeDef
+		tref := string(td.Type)
+		if td.Type == "Map" {
+			tref = formatTypeRef(td.Type, td.Items, td.Keys)
+		}
+		f.comment(td.Comment, "")
+		f.printf("type %s %s%s;\n", td.Name, tref, formatOptions(formatSize(nil, td.Size, td.MinSize, td.MaxSize), td.Annotations))
+	case rdl.TypeVariantUnionTypeDef:
+		td := t.UnionTypeDef
+		variants := make([]string, 0, len(td.Variants))
+		for _, v := range td.Variants {
+			variants = append(variants, string(v))
+		}
+		f.comment(td.Comment, "")
+		f.printf("type %s Union<%s>%s;\n", td.Name, strings.Join(variants, ","), formatOptions(nil, td.Annotations))
+	case rdl.TypeVariantEnumTypeDef:
+		td := t.EnumTypeDef
+		f.comment(td.Comment, "")
+		f.printf("type %s Enum%s {\n", td.Name, formatOptions(nil, td.Annotations))
+		for _, e := range td.Elements {
+			f.printf("    %s%s%s\n", e.Symbol, formatOptions(nil, e.Annotations), trailingComment(e.Comment))
+		}
+		f.printf("}\n")
+	case rdl.TypeVariantStructTypeDef:
+		td := t.StructTypeDef
+		f.comment(td.Comment, "")
+		f.printf("type %s %s%s {\n", td.Name, td.Type, formatOptions(nil, td.Annotations))
+		if td.Closed {
+			f.printf("    closed;\n")
+		}
+		for _, fd := range td.Fields {
+			var options []string
+			tref := fd.Type
+			if inline := f.inline[fd.Type]; inline != nil {
+				tref = inlineBaseType(inline)
+				options = constraintOptions(inline)
+			}
+			if fd.Optional {
+				options = append(options, "optional")
+			}
+			if fd.Default != nil {
+				options = append(options, "default="+f.literal(fd.Type, fd.Default))
+			}
+			f.printf("    %s %s%s;%s\n", formatTypeRef(tref, fd.Items, fd.Keys), fd.Name, formatOptions(options, fd.Annotations), trailingComment(fd.Comment))
+		}
+		f.printf("}\n")
+	case rdl.TypeVariantAliasTypeDef:
+		td := t.AliasTypeDef
+		f.comment(td.Comment, "")
+		f.printf("type %s %s%s;\n", td.Name, td.Type, formatOptions(nil, td.Annotations))
+	}
+}
+
+func (f *schemaFormatter) formatResource(r *rdl.Resource) {
+	path := r.Path
+	var query []string
+	for _, in := range r.Inputs {
+		if in.QueryParam != "" {
+			query = append(query, fmt.Sprintf("%s={%s}", in.QueryParam, in.Name))
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + strings.Join(query, "&")
+	}
+	var options []string
+	if r.Async != nil && *r.Async {
+		options = append(options, "async")
+	}
+	if r.Name != "" {
+		options = append(options, fmt.Sprintf("name=%s", r.Name))
+	}
+	f.comment(r.Comment, "")
+	f.printf("resource %s %s %q%s {\n", r.Type, r.Method, path, formatOptions(options, r.Annotations))
+	for _, in := range r.Inputs {
+		var options []string
+		if in.Header != "" {
+			options = append(options, fmt.Sprintf("header=%q", in.Header))
+		}
+		if in.Default != nil {
+			options = append(options, "default="+f.literal(in.Type, in.Default))
+		} else if in.Optional {
+			options = append(options, "optional")
+		}
+		f.printf("    %s %s%s;%s\n", in.Type, in.Name, formatOptions(options, in.Annotations), trailingComment(in.Comment))
+	}
+	for _, out := range r.Outputs {
+		options := []string{fmt.Sprintf("header=%q", out.Header), "out"}
+		if out.Optional {
+			options = append(options, "optional")
+		}
+		f.printf("    %s %s%s;%s\n", out.Type, out.Name, formatOptions(options, out.Annotations), trailingComment(out.Comment))
+	}
+	if r.Auth != nil {
+		if r.Auth.Action != "" {
+			if r.Auth.Domain != "" {
+				f.printf("    authorize(%q, %q, %q);\n", r.Auth.Action, r.Auth.Resource, r.Auth.Domain)
+			} else {
+				f.printf("    authorize(%q, %q);\n", r.Auth.Action, r.Auth.Resource)
+			}
+		} else if r.Auth.Authenticate {
+			f.printf("    authenticate;\n")
+		}
+	}
+	expected := "OK"
+	if r.Expected != "" {
+		expected = r.Expected
+	}
+	if len(r.Alternatives) > 0 {
+		expected += ", " + strings.Join(r.Alternatives, ", ")
+	}
+	f.printf("    expected %s;\n", expected)
+	if len(r.Exceptions) > 0 {
+		codes := make([]string, 0, len(r.Exceptions))
+		for code := range r.Exceptions {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		f.printf("    exceptions {\n")
+		for _, code := range codes {
+			e := r.Exceptions[code]
+			f.printf("        %s %s;%s\n", e.Type, code, trailingComment(e.Comment))
+		}
+		f.printf("    }\n")
+	}
+	f.printf("}\n")
+}
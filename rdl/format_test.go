@@ -0,0 +1,81 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const formatTestSchema = `name Sample;
+version 2;
+
+type Age Int32 (min=0);
+
+type Color Enum {
+    RED (x_wire="red", x_aliases="rouge,rot") // the red one
+    GREEN
+}
+
+// A contact
+type Contact Struct {
+    String name (maxSize=10, pattern="[a-z]+"); // the name
+    Age age (max=150, optional);
+    Float64 ratio (min=0.5, default=1);
+    String nick (values=["a","b"], x_foo="bar");
+    Color color (default=RED);
+    Array<String> tags;
+}
+
+type Other Struct {
+    String name (maxSize=5);
+}
+
+resource Contact GET "/contacts/{id}?n={n}" {
+    String id;
+    Int32 n (optional);
+    expected OK;
+}
+`
+
+func parseTestSchema(t *testing.T, dir string, name string, source string) *rdl.Schema {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := parseWithIncludePath(path, nil, false, true, false)
+	if err != nil {
+		t.Fatalf("cannot parse %s: %v\n%s", name, err, source)
+	}
+	return schema
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rdl-format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	schema := parseTestSchema(t, dir, "sample.rdl", formatTestSchema)
+	formatted := formatSchema(schema)
+	for _, s := range []string{"Contact_T", `RED (x_aliases="rouge,rot", x_wire="red")`} {
+		if want := strings.HasPrefix(s, "RED"); strings.Contains(formatted, s) != want {
+			t.Errorf("formatted schema contains %q: %v, want %v\n%s", s, !want, want, formatted)
+		}
+	}
+	reparsed := parseTestSchema(t, dir, "formatted.rdl", formatted)
+	want, _ := json.MarshalIndent(schema, "", "  ")
+	got, _ := json.MarshalIndent(reparsed, "", "  ")
+	if string(got) != string(want) {
+		t.Errorf("formatted schema parses differently\n%s\nwant:\n%s\ngot:\n%s", formatted, want, got)
+	}
+	if again := formatSchema(reparsed); again != formatted {
+		t.Errorf("formatting is not stable\n%s\nthen:\n%s", formatted, again)
+	}
+}
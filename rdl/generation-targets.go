@@ -0,0 +1,253 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//
+// The generation config can also declare the targets of a project, each a generation that rdl
+// generate would run, so that rdl generate --config rdl.yaml runs them all, instead of a Makefile
+// of long rdl invocations, e.g.
+//
+//   schemas:
+//     - schemas/*.rdl
+//   includePath:
+//     - schemas/common
+//   targets:
+//     go:
+//       generator: go-model,go-client
+//       output: gen/go
+//       options:
+//         validate: true
+//     java:
+//       generator: java-model
+//       output: gen/java/src/main/java
+//       schemas:
+//         - schemas/contacts.rdl
+//       ns: com.example.contacts
+//
+// A target takes the schemas of the config unless it lists its own, and sets the generate options
+// by their names: generator (or template), output (-o), ns, basePath (-b), lib (-l), preciseTypes
+// (-t), prefixEnums (-e), untaggedUnions (-u), options (-x), apiVersion, tags, and prune. The
+// paths are relative to the directory of the config file. With no schema arguments, rdl generate
+// runs the targets of the --config file, or of the rdl.yaml, rdl.yml, or rdl.json of the current
+// directory, all of them in the order of their names, or those named by the generator argument,
+// e.g. rdl generate go,java.
+//
+
+// GenerationTarget is a target of the generation config.
+type GenerationTarget struct {
+	Generator      string                  `json:"generator"`
+	Template       string                  `json:"template"`
+	Output         string                  `json:"output"`
+	Schemas        []string                `json:"schemas"`
+	Namespace      string                  `json:"ns"`
+	BasePath       string                  `json:"basePath"`
+	Lib            string                  `json:"lib"`
+	PreciseTypes   configBool              `json:"preciseTypes"`
+	PrefixEnums    configBool              `json:"prefixEnums"`
+	UntaggedUnions []string                `json:"untaggedUnions"`
+	Options        map[string]configString `json:"options"`
+	APIVersion     configString            `json:"apiVersion"`
+	Tags           string                  `json:"tags"`
+	Prune          configBool              `json:"prune"`
+}
+
+// configString is a string of the config, which JSON may also write as a number or a boolean,
+// e.g. the true of an option.
+type configString string
+
+func (s *configString) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v.(type) {
+	case string, bool, float64:
+		*s = configString(fmt.Sprint(v))
+		return nil
+	}
+	return fmt.Errorf("expected a string, not %s", b)
+}
+
+// configBool is a boolean of the config, which YAML writes as the string true or false.
+type configBool bool
+
+func (f *configBool) UnmarshalJSON(b []byte) error {
+	var s configString
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	switch s {
+	case "true":
+		*f = true
+	case "false":
+		*f = false
+	default:
+		return fmt.Errorf("expected true or false, not %s", b)
+	}
+	return nil
+}
+
+// projectConfigNames are the names of the config file generate looks for in the current
+// directory when it is not given schemas.
+var projectConfigNames = []string{"rdl.yaml", "rdl.yml", "rdl.json"}
+
+// findProjectConfig returns the config file of the current directory, if any.
+func findProjectConfig() string {
+	for _, name := range projectConfigNames {
+		if fi, err := os.Stat(name); err == nil && !fi.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
+
+// path returns the path of the config, relative to the directory of the config file.
+func (config *GenerationConfig) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(config.dir, p)
+}
+
+func (config *GenerationConfig) paths(list []string) []string {
+	var result []string
+	for _, p := range list {
+		result = append(result, config.path(p))
+	}
+	return result
+}
+
+// externalOptions returns the options of the target as the key=value of -x, in the order of
+// their keys.
+func (t *GenerationTarget) externalOptions() []string {
+	var options []string
+	for key, value := range t.Options {
+		options = append(options, key+"="+string(value))
+	}
+	sort.Strings(options)
+	return options
+}
+
+// selectedTargets returns the names of the targets to run, those of the comma-separated list,
+// or else all of them, in the order of their names.
+func (config *GenerationConfig) selectedTargets(list string) ([]string, error) {
+	if len(config.Targets) == 0 {
+		return nil, fmt.Errorf("No targets in the config")
+	}
+	if list != "" {
+		names := strings.Split(list, ",")
+		for _, name := range names {
+			if config.Targets[name] == nil {
+				return nil, fmt.Errorf("No target '%s' in the config", name)
+			}
+		}
+		return names, nil
+	}
+	var names []string
+	for name := range config.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// generateConfigTargets runs the targets of the config named in the list, or all of them. With
+// dryRun, it generates nothing, printing the diffs of the files the targets would change, and
+// tells whether there are any.
+func generateConfigTargets(banner string, config *GenerationConfig, list string, pretty bool, warning bool, strict bool, includePath []string, prune bool, dryRun bool) (bool, error) {
+	names, err := config.selectedTargets(list)
+	if err != nil {
+		return false, err
+	}
+	includePath = append(includePath, config.paths(config.IncludePath)...)
+	changed := false
+	for _, name := range names {
+		c, err := config.generateTarget(banner, config.Targets[name], pretty, warning, strict, includePath, prune, dryRun)
+		if err != nil {
+			return changed, fmt.Errorf("target %s: %v", name, err)
+		}
+		changed = changed || c
+	}
+	return changed, nil
+}
+
+func (config *GenerationConfig) generateTarget(banner string, t *GenerationTarget, pretty bool, warning bool, strict bool, includePath []string, prune bool, dryRun bool) (bool, error) {
+	generator := t.Generator
+	if t.Template != "" {
+		if generator != "" {
+			return false, fmt.Errorf("Cannot set both a generator and a template")
+		}
+		generator = templateFlavorPrefix + config.path(t.Template)
+	}
+	if generator == "" {
+		return false, fmt.Errorf("Missing generator (or template)")
+	}
+	patterns := t.Schemas
+	if len(patterns) == 0 {
+		patterns = config.Schemas
+	}
+	if len(patterns) == 0 {
+		return false, fmt.Errorf("Missing schemas")
+	}
+	files, err := schemaFiles(config.paths(patterns))
+	if err != nil {
+		return false, err
+	}
+	outdir := config.path(t.Output)
+	librdl := t.Lib
+	if librdl == "" {
+		librdl = RdlGoImport
+	}
+	options := t.externalOptions()
+	prune = prune || bool(t.Prune)
+	var regenerate func(dirName string) error
+	if len(files) > 1 {
+		schemas, err := readSchemaBatch(files, pretty, warning, strict, includePath)
+		if err != nil {
+			return false, err
+		}
+		regenerate = func(dirName string) error {
+			return generateBatch(banner, generator, dirName, librdl, bool(t.PrefixEnums), bool(t.PreciseTypes), t.Namespace, config, schemas, files, t.UntaggedUnions, t.BasePath, options, string(t.APIVersion), t.Tags)
+		}
+	} else {
+		schema, name, err := readSchema(files[0], pretty, warning, strict, includePath)
+		if err != nil {
+			return false, err
+		}
+		if schema.Name == "" {
+			schema.Name = name
+		}
+		if err = selectAPIVersion(schema, string(t.APIVersion)); err != nil {
+			return false, err
+		}
+		if err = selectTags(schema, t.Tags); err != nil {
+			return false, err
+		}
+		regenerate = func(dirName string) error {
+			return generateTargets(banner, generator, dirName, librdl, bool(t.PrefixEnums), bool(t.PreciseTypes), t.Namespace, config, schema, files[0], t.UntaggedUnions, t.BasePath, options)
+		}
+	}
+	if dryRun {
+		return dryRunGenerate(outdir, generator, prune, regenerate)
+	}
+	if outdir != "" {
+		//the output of a target is usually a directory of the build, which may not exist yet
+		if err := os.MkdirAll(outdir, 0755); err != nil {
+			return false, err
+		}
+	}
+	if err := regenerate(outdir); err != nil {
+		return false, err
+	}
+	return false, updateManifest(outdir, generator, takeGenerated(), prune)
+}
@@ -0,0 +1,149 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// A generic struct type is declared once, with x_type_params naming its type parameters, each
+// declared as a type of Any, and instantiated by the types derived from it with x_type_args:
+//
+//   type T Any;
+//   type Page Struct (x_type_params="T") {
+//       Array<T> items;
+//       String next (optional);
+//   }
+//   type ContactPage Page (x_type_args="Contact");
+//
+// A type parameter can be the items of an Array or Map field of the generic type, and nothing
+// else. The instantiations are expanded as the schema is parsed, to structs with the fields of
+// the generic type, the type arguments in place of its parameters, followed by their own: every
+// generator sees them as plain structs, and swagger defines them but not the generic type. The
+// expanded instantiations are annotated with x_template, the generic type they instantiate, for
+// go-model and java-model to make it a Go generic struct and a Java generic interface.
+//
+
+// templateParams returns the type parameters of the generic type, or nil if it is not one.
+func templateParams(t *rdl.Type) []string {
+	if t == nil || t.Variant != rdl.TypeVariantStructTypeDef {
+		return nil
+	}
+	return commaList(t.StructTypeDef.Annotations["x_type_params"])
+}
+
+// schemaTypeParams returns the type parameters of the generic types of the schema.
+func schemaTypeParams(schema *rdl.Schema) map[string]bool {
+	params := make(map[string]bool)
+	for _, t := range schema.Types {
+		for _, p := range templateParams(t) {
+			params[p] = true
+		}
+	}
+	return params
+}
+
+// templateOf returns the generic type the struct instantiates, and its type arguments, or "".
+func templateOf(st *rdl.StructTypeDef) (rdl.TypeRef, []string) {
+	template := st.Annotations["x_template"]
+	if template == "" {
+		return "", nil
+	}
+	return rdl.TypeRef(template), commaList(st.Annotations["x_type_args"])
+}
+
+// applyTypeArgs expands the instantiations of the generic types of the schema.
+func applyTypeArgs(schema *rdl.Schema) error {
+	reg := rdl.NewTypeRegistry(schema)
+	params := schemaTypeParams(schema)
+	for _, t := range schema.Types {
+		if t.Variant != rdl.TypeVariantStructTypeDef {
+			continue
+		}
+		st := t.StructTypeDef
+		own := make(map[string]bool)
+		for _, p := range templateParams(t) {
+			own[p] = true
+		}
+		for _, f := range st.Fields {
+			for _, ref := range []rdl.TypeRef{f.Type, f.Keys, f.Items} {
+				if params[string(ref)] && !(own[string(ref)] && ref == f.Items) {
+					return fmt.Errorf("Struct %s: field '%s' uses type parameter %s, which can only be the items of an Array or Map field of the generic type declaring it", st.Name, f.Name, ref)
+				}
+			}
+		}
+	}
+	for i, t := range schema.Types {
+		annotations := typeAnnotations(t)
+		args := commaList(annotations["x_type_args"])
+		if len(args) == 0 {
+			continue
+		}
+		tName, super, comment := rdl.TypeInfo(t)
+		template := reg.FindType(super)
+		tparams := templateParams(template)
+		if len(tparams) == 0 {
+			return fmt.Errorf("Type %s: x_type_args needs a generic type to instantiate, not %s", tName, super)
+		}
+		if len(args) != len(tparams) {
+			return fmt.Errorf("Type %s: %s takes %d type arguments, not %d", tName, super, len(tparams), len(args))
+		}
+		subst := make(map[rdl.TypeRef]rdl.TypeRef)
+		for j, arg := range args {
+			if reg.FindType(rdl.TypeRef(arg)) == nil {
+				return fmt.Errorf("Type %s: undefined type argument %s", tName, arg)
+			}
+			subst[rdl.TypeRef(tparams[j])] = rdl.TypeRef(arg)
+		}
+		var fields []*rdl.StructFieldDef
+		definedIn := make(map[rdl.Identifier]bool)
+		for _, f := range flattenedFields(reg, template) {
+			copied := *f
+			if arg, ok := subst[f.Items]; ok {
+				copied.Items = arg
+			}
+			fields = append(fields, &copied)
+			definedIn[f.Name] = true
+		}
+		if t.Variant == rdl.TypeVariantStructTypeDef {
+			for _, f := range t.StructTypeDef.Fields {
+				if definedIn[f.Name] {
+					return fmt.Errorf("Struct %s: field '%s' is also in %s", tName, f.Name, super)
+				}
+				fields = append(fields, f)
+			}
+		}
+		copiedAnnotations := make(map[rdl.ExtendedAnnotation]string)
+		for k, v := range annotations {
+			copiedAnnotations[k] = v
+		}
+		copiedAnnotations["x_template"] = string(super)
+		schema.Types[i] = &rdl.Type{
+			Variant: rdl.TypeVariantStructTypeDef,
+			StructTypeDef: &rdl.StructTypeDef{
+				Type:        "Struct",
+				Name:        tName,
+				Comment:     comment,
+				Annotations: copiedAnnotations,
+				Fields:      fields,
+				Closed:      template.StructTypeDef.Closed || (t.Variant == rdl.TypeVariantStructTypeDef && t.StructTypeDef.Closed),
+			},
+		}
+	}
+	return nil
+}
+
+// commaList returns the trimmed, non-empty items of the comma-separated list.
+func commaList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
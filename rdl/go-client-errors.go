@@ -0,0 +1,130 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"sort"
+	"strings"
+)
+
+//
+// With -x typed-errors=true, the go-client declares an error type for each type the resources
+// declare exceptions of, e.g. NotFoundInfoError for NotFoundInfo, holding the decoded body of the
+// response, and its methods return it for the status codes of those exceptions, rather than a
+// rdl.ResourceError. The callers can then tell the errors apart with errors.As, instead of
+// switching on the status code. The typed errors are also a rdl.ResourceError (embedded, and
+// found by errors.As), and errors.Is matches them with the rdl.ResourceError of their code, e.g.
+// errors.Is(err, rdl.ResourceError{Code: 404}).
+//
+
+// goExceptionTypes returns the types the resources of the schema declare exceptions of.
+func goExceptionTypes(reg rdl.TypeRegistry, schema *rdl.Schema) []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, r := range schema.Resources {
+		for _, e := range r.Exceptions {
+			if !seen[e.Type] && goTypedErrorBody(reg, e.Type, false) != "" {
+				seen[e.Type] = true
+				types = append(types, e.Type)
+			}
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// goTypedErrorName returns the name of the error type of the exceptions of the type.
+func goTypedErrorName(etype string) string {
+	return capitalize(strings.Replace(etype, ".", "_", -1)) + "Error"
+}
+
+// goTypedErrorBody returns the Go type of the body of the exceptions of the type, the
+// rdl.ResourceError of the library for ResourceError, unless the schema defines it, or "" if the
+// schema does not define the type.
+func goTypedErrorBody(reg rdl.TypeRegistry, etype string, precise bool) string {
+	if reg.FindType(rdl.TypeRef(etype)) == nil {
+		if etype == "ResourceError" {
+			return "rdl.ResourceError"
+		}
+		return ""
+	}
+	return goType(reg, rdl.TypeRef(etype), false, "", "", precise, true)
+}
+
+// typedErrorDecls returns the declarations of the error types of the exceptions of the resources.
+func (gen *clientGenerator) typedErrorDecls() string {
+	s := ""
+	for _, etype := range goExceptionTypes(gen.registry, gen.schema) {
+		name := goTypedErrorName(etype)
+		s += "\n//\n// " + name + " is the error of the requests failing with a status their resource declares a\n"
+		s += "// " + etype + " exception for, with the Body of the response. It is also the rdl.ResourceError of\n"
+		s += "// its code, for errors.As and errors.Is.\n//\n"
+		s += "type " + name + " struct {\n"
+		s += "\trdl.ResourceError\n"
+		s += "\tBody " + goTypedErrorBody(gen.registry, etype, gen.precise) + "\n"
+		s += "}\n\n"
+		s += "func new" + name + "(code int, contentBytes []byte) error {\n"
+		s += "\te := &" + name + "{}\n"
+		s += "\tjson.Unmarshal(contentBytes, &e.ResourceError)\n"
+		s += "\tjson.Unmarshal(contentBytes, &e.Body)\n"
+		s += "\te.Code = code\n"
+		s += "\tif e.Message == \"\" {\n"
+		s += "\t\te.Message = string(contentBytes)\n"
+		s += "\t}\n"
+		s += "\treturn e\n"
+		s += "}\n\n"
+		s += "// Is tells whether the target is a rdl.ResourceError of the same code.\n"
+		s += "func (e *" + name + ") Is(target error) bool {\n"
+		s += "\tswitch t := target.(type) {\n"
+		s += "\tcase rdl.ResourceError:\n"
+		s += "\t\treturn t.Code == e.Code\n"
+		s += "\tcase *rdl.ResourceError:\n"
+		s += "\t\treturn t.Code == e.Code\n"
+		s += "\t}\n"
+		s += "\treturn false\n"
+		s += "}\n\n"
+		s += "// As sets the target to the rdl.ResourceError of the error, if it is a *rdl.ResourceError.\n"
+		s += "func (e *" + name + ") As(target interface{}) bool {\n"
+		s += "\tif t, ok := target.(*rdl.ResourceError); ok {\n"
+		s += "\t\t*t = e.ResourceError\n"
+		s += "\t\treturn true\n"
+		s += "\t}\n"
+		s += "\treturn false\n"
+		s += "}\n"
+	}
+	return s
+}
+
+// goTypedErrorCases returns the cases of the switch on the status code of the response returning
+// the typed errors of the exceptions of the resource, once the body is read by the prelude, at the
+// indentation. The codes the resource expects are left to their own case.
+func goTypedErrorCases(reg rdl.TypeRegistry, r *rdl.Resource, indent string, prelude string, errorReturn string) string {
+	expected := map[string]bool{r.Expected: true}
+	for _, alt := range r.Alternatives {
+		expected[alt] = true
+	}
+	codes := make(map[string][]string)
+	var etypes []string
+	for ecode, e := range r.Exceptions {
+		if expected[ecode] || goTypedErrorBody(reg, e.Type, false) == "" {
+			continue
+		}
+		if codes[e.Type] == nil {
+			etypes = append(etypes, e.Type)
+		}
+		codes[e.Type] = append(codes[e.Type], rdl.StatusCode(ecode))
+	}
+	sort.Strings(etypes)
+	s := ""
+	for _, etype := range etypes {
+		sort.Strings(codes[etype])
+		s += indent + "case " + strings.Join(codes[etype], ", ") + ":\n"
+		s += prelude
+		s += fmt.Sprintf("%s\terr = new%s(resp.StatusCode, contentBytes)\n", indent, goTypedErrorName(etype))
+		s += indent + "\t" + errorReturn + "\n"
+	}
+	return s
+}
@@ -0,0 +1,229 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+	"text/template"
+)
+
+//
+// The mock of the go client, generated next to it in <name>_mock.go. Mock<Name>Client implements
+// the <Name>ClientAPI interface of the client methods, so that the unit tests of its callers do
+// not need an HTTP server: each method returns the response set by On<Method>, or calls the
+// <Method>Func set, and the calls are recorded, to be checked against those expected.
+//
+
+const clientMockTemplate = `{{header}}
+
+package {{package}}
+
+import (
+	"context"
+	"fmt"
+	rdl "{{rdlruntime}}"
+	"io"
+	"reflect"
+	"sync"
+)
+
+var _ = rdl.BaseTypeAny
+var _ io.Reader
+
+// MockCall is a call of a method of a mock client, with its arguments other than the context.
+type MockCall struct {
+	Method string
+	Args   []interface{}
+}
+
+// MockReporter reports the failures of the expectations of a mock client, e.g. a *testing.T.
+type MockReporter interface {
+	Errorf(format string, args ...interface{})
+}
+
+// {{mock}} is a {{client}}API for the unit tests of its callers. Each method returns the
+// response set by its On<Method>, or calls its <Method>Func, and fails if neither is set. The
+// calls are recorded, and Verify checks them against those expected.
+type {{mock}} struct {
+{{range .Resources}}	{{funcField .}}
+{{end}}
+	mu       sync.Mutex
+	calls    []MockCall
+	expected []MockCall
+}
+
+var _ {{client}}API = (*{{mock}})(nil)
+
+// Expect adds a call of the method with the arguments, other than the context, to those Verify
+// expects, in order.
+func (mock *{{mock}}) Expect(method string, args ...interface{}) *{{mock}} {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.expected = append(mock.expected, MockCall{Method: method, Args: args})
+	return mock
+}
+
+// Calls returns the calls made so far, in order.
+func (mock *{{mock}}) Calls() []MockCall {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return append([]MockCall(nil), mock.calls...)
+}
+
+// Verify reports the calls made that differ from those expected, and those expected that were not
+// made.
+func (mock *{{mock}}) Verify(t MockReporter) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for i, call := range mock.calls {
+		if i >= len(mock.expected) {
+			t.Errorf("{{mock}}: unexpected call %d: %s%v", i+1, call.Method, call.Args)
+		} else if !mockCallMatches(call, mock.expected[i]) {
+			t.Errorf("{{mock}}: call %d: %s%v, expected %s%v", i+1, call.Method, call.Args, mock.expected[i].Method, mock.expected[i].Args)
+		}
+	}
+	for i := len(mock.calls); i < len(mock.expected); i++ {
+		t.Errorf("{{mock}}: missing call %d: %s%v", i+1, mock.expected[i].Method, mock.expected[i].Args)
+	}
+}
+
+// mockCallMatches tells whether the call is the one expected, a nil argument matching a nil pointer,
+// slice, or map.
+func mockCallMatches(call MockCall, expected MockCall) bool {
+	if call.Method != expected.Method || len(call.Args) != len(expected.Args) {
+		return false
+	}
+	for i, arg := range call.Args {
+		if !reflect.DeepEqual(arg, expected.Args[i]) && !(mockNil(arg) && mockNil(expected.Args[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func mockNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func (mock *{{mock}}) record(method string, args ...interface{}) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.calls = append(mock.calls, MockCall{Method: method, Args: args})
+}
+
+func (mock *{{mock}}) missing(method string) error {
+	return fmt.Errorf("{{mock}}: no response set for %s", method)
+}
+{{range .Resources}}
+// On{{name .}} makes {{name .}} return the response.
+func (mock *{{mock}}) {{onSig .}} *{{mock}} {
+{{onBody .}}
+}
+
+{{deprecated .}}func (mock *{{mock}}) {{mockSig .}} {
+{{mockBody .}}
+}
+{{end}}`
+
+// emitClientMock generates the mock of the client, to the writer.
+func (gen *clientGenerator) emitClientMock() error {
+	reg := gen.registry
+	params := func(r *rdl.Resource) []string {
+		_, params := goMethodName(reg, r, gen.precise)
+		return append([]string{"ctx context.Context"}, params...)
+	}
+	args := func(r *rdl.Resource) []string {
+		var names []string
+		for _, p := range params(r) {
+			names = append(names, p[:strings.Index(p, " ")])
+		}
+		return names
+	}
+	results := func(r *rdl.Resource) []string {
+		return append(goMethodResults(reg, r, gen.precise), "error")
+	}
+	name := func(r *rdl.Resource) string {
+		n, _ := goMethodName(reg, r, gen.precise)
+		return capitalize(n)
+	}
+	funcType := func(r *rdl.Resource) string {
+		res := results(r)
+		if len(res) == 1 {
+			return "func(" + strings.Join(params(r), ", ") + ") error"
+		}
+		return "func(" + strings.Join(params(r), ", ") + ") (" + strings.Join(res, ", ") + ")"
+	}
+	//the results are named r0, r1, ..., and err, for the method to return them as they are
+	namedResults := func(r *rdl.Resource) ([]string, []string) {
+		var names, decls []string
+		for i, t := range results(r) {
+			n := fmt.Sprintf("r%d", i)
+			if t == "error" {
+				n = "err"
+			}
+			names = append(names, n)
+			decls = append(decls, n+" "+t)
+		}
+		return names, decls
+	}
+	width := 0
+	for _, r := range gen.schema.Resources {
+		if len(name(r)) > width {
+			width = len(name(r))
+		}
+	}
+	funcMap := template.FuncMap{
+		"rdlruntime": func() string { return gen.librdl },
+		"header":     func() string { return generationHeader(gen.banner) },
+		"package":    func() string { return generationPackage(gen.schema, gen.ns) },
+		"client":     func() string { return gen.name + "Client" },
+		"mock":       func() string { return "Mock" + gen.name + "Client" },
+		"name":       name,
+		"deprecated": func(r *rdl.Resource) string { return goDeprecatedComment(r.Annotations, "") },
+		"funcField": func(r *rdl.Resource) string {
+			return fmt.Sprintf("%-*s %s", width+4, name(r)+"Func", funcType(r))
+		},
+		"onSig": func(r *rdl.Resource) string {
+			_, decls := namedResults(r)
+			return "On" + name(r) + "(" + strings.Join(decls, ", ") + ")"
+		},
+		"onBody": func(r *rdl.Resource) string {
+			names, _ := namedResults(r)
+			s := "\tmock." + name(r) + "Func = " + funcType(r) + " {\n"
+			s += "\t\treturn " + strings.Join(names, ", ") + "\n"
+			s += "\t}\n"
+			s += "\treturn mock"
+			return s
+		},
+		"mockSig": func(r *rdl.Resource) string {
+			_, decls := namedResults(r)
+			return name(r) + "(" + strings.Join(params(r), ", ") + ") (" + strings.Join(decls, ", ") + ")"
+		},
+		"mockBody": func(r *rdl.Resource) string {
+			n := name(r)
+			s := "\tmock.record(" + fmt.Sprintf("%q", n)
+			for _, a := range args(r)[1:] {
+				s += ", " + a
+			}
+			s += ")\n"
+			s += "\tif mock." + n + "Func == nil {\n"
+			s += "\t\terr = mock.missing(" + fmt.Sprintf("%q", n) + ")\n"
+			s += "\t\treturn\n"
+			s += "\t}\n"
+			s += "\treturn mock." + n + "Func(" + strings.Join(args(r), ", ") + ")"
+			return s
+		},
+	}
+	t := template.Must(template.New("mock").Funcs(funcMap).Parse(clientMockTemplate))
+	return t.Execute(gen.writer, gen.schema)
+}
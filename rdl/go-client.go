@@ -23,10 +23,13 @@ type clientGenerator struct {
 	precise     bool
 	ns          string
 	librdl      string
+	retry       bool
+	metrics     bool
+	typedErrors bool
 }
 
 // GenerateGoClient generates the client code to talk to the server.
-func GenerateGoClient(banner string, schema *rdl.Schema, outdir string, ns string, librdl string, prefixEnums bool, precise bool) error {
+func GenerateGoClient(banner string, schema *rdl.Schema, outdir string, ns string, librdl string, prefixEnums bool, precise bool, options []string) error {
 	name := strings.ToLower(string(schema.Name))
 	if strings.HasSuffix(outdir, ".go") {
 		name = filepath.Base(outdir)
@@ -41,8 +44,21 @@ func GenerateGoClient(banner string, schema *rdl.Schema, outdir string, ns strin
 	if file != nil {
 		defer file.Close()
 	}
-	gen := &clientGenerator{rdl.NewTypeRegistry(schema), schema, capitalize(string(schema.Name)), out, nil, banner, prefixEnums, precise, ns, librdl}
-	gen.emitClient()
+	gen := &clientGenerator{rdl.NewTypeRegistry(schema), schema, capitalize(string(schema.Name)), out, nil, banner, prefixEnums, precise, ns, librdl, goGenerationBoolOptionSet(options, "retry"), goGenerationBoolOptionSet(options, "metrics"), goGenerationBoolOptionSet(options, "typed-errors")}
+	gen.err = gen.emitClient()
+	out.Flush()
+	if gen.err != nil || file == nil {
+		return gen.err
+	}
+
+	//the mock of the client, in <name>_mock.go, see go-client-mock.go
+	out, mockFile, _, err := outputWriter(outdir, strings.TrimSuffix(name, ".go")+"_mock.go", ".go")
+	if err != nil {
+		return err
+	}
+	defer mockFile.Close()
+	gen.writer = out
+	gen.err = gen.emitClientMock()
 	out.Flush()
 	return gen.err
 }
@@ -52,16 +68,32 @@ const clientTemplate = `{{header}}
 package {{package}}
 
 import (
+{{- if streams}}
+	"bufio"
+{{- end}}
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+{{- if metrics}}
+	"github.com/prometheus/client_golang/prometheus"
+{{- end}}
 	rdl "{{rdlruntime}}"
 	"io"
 	"io/ioutil"
+{{- if multipart}}
+	"mime/multipart"
+{{- end}}
 	"net/http"
 	"net/url"
+{{- if multipart}}
+	"path/filepath"
+{{- end}}
 	"strconv"
 	"strings"
+{{- if etags}}
+	"sync"
+{{- end}}
 	"time"
 )
 
@@ -76,11 +108,35 @@ type {{client}} struct {
 	CredsHeader *string
 	CredsToken  *string
 	Timeout     time.Duration
-}
+
+	// HTTPClient, if set, is used for all requests instead of a client built from the
+	// Transport and Timeout.
+	HTTPClient *http.Client
+
+	// RequestHooks are called with each request before it is sent, e.g. to add headers
+	// derived from the request's context.
+	RequestHooks []func(req *http.Request) error
+{{if retry}}
+	// RetryPolicy, if set, retries the GET, PUT, and DELETE requests that fail.
+	RetryPolicy *RetryPolicy
+{{end}}{{if metrics}}
+	// Metrics, if set, counts and times the requests of the client, see NewClientMetrics.
+	Metrics *ClientMetrics
+{{end}}{{if etags}}
+	// ETags, if set, caches the responses of the resources annotated with x_etag, to request them
+	// again with If-None-Match, see ETagCache. The clients of NewClient cache up to 1000 URLs.
+	ETags *ETagCache
+{{end}}}
 
 // NewClient creates and returns a new HTTP client object for the {{.Name}} service
 func NewClient(url string, transport http.RoundTripper) {{client}} {
-	return {{client}}{url, transport, nil, nil, 0}
+	return {{client}}{URL: url, Transport: transport{{if etags}}, ETags: NewETagCache(1000){{end}}}
+}
+
+// NewClientWithHTTPClient creates and returns a new client object for the {{.Name}} service
+// that sends its requests with the given http.Client
+func NewClientWithHTTPClient(url string, hclient *http.Client) {{client}} {
+	return {{client}}{URL: url, HTTPClient: hclient{{if etags}}, ETags: NewETagCache(1000){{end}}}
 }
 
 // AddCredentials adds the credentials to the client for subsequent requests.
@@ -89,7 +145,123 @@ func (client *{{client}}) AddCredentials(header string, token string) {
 	client.CredsToken = &token
 }
 
+// AddRequestHook adds a function to be called with each request before it is sent.
+func (client *{{client}}) AddRequestHook(hook func(req *http.Request) error) {
+	client.RequestHooks = append(client.RequestHooks, hook)
+}
+{{if retry}}
+// RetryPolicy says how often, and after how long, a client retries the idempotent requests (GET,
+// PUT, and DELETE) that fail with a network error or one of the retryable status codes.
+type RetryPolicy struct {
+	MaxAttempts          int           // attempts in all, including the first
+	Backoff              time.Duration // delay before the first retry, doubled for each one after
+	MaxBackoff           time.Duration // if set, the longest delay
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy makes up to 3 attempts, retrying the transient errors the {{.Name}} resources
+// declare as exceptions, and the 502, 503, and 504 errors of proxies in front of the service.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:          3,
+		Backoff:              100 * time.Millisecond,
+		MaxBackoff:           5 * time.Second,
+		RetryableStatusCodes: []int{ {{- retryableStatusCodes -}} },
+	}
+}
+
+func (policy *RetryPolicy) retryable(code int) bool {
+	for _, c := range policy.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRetryPolicy makes the client retry failed idempotent requests. A nil policy turns retries off.
+func (client *{{client}}) SetRetryPolicy(policy *RetryPolicy) {
+	client.RetryPolicy = policy
+}
+{{end}}{{if metrics}}
+// ClientMetrics are the Prometheus metrics of the requests of clients to the {{.Name}} resources,
+// labeled with the name of their method, and the status code of the response, or "error" if
+// there is none.
+type ClientMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+// NewClientMetrics registers the metrics of the clients with the registry, or uses those already
+// registered with it, e.g. by another client of the process.
+func NewClientMetrics(reg prometheus.Registerer) (*ClientMetrics, error) {
+	service := prometheus.Labels{"service": "{{service}}"}
+	requests, err := registerClientCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "rdl_client_requests_total",
+		Help:        "The requests sent to the resources, by resource and status code.",
+		ConstLabels: service,
+	}, []string{"resource", "code"}))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := registerClientCollector(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "rdl_client_request_duration_seconds",
+		Help:        "The time taken by the requests sent to the resources, by resource and status code.",
+		ConstLabels: service,
+		Buckets:     prometheus.DefBuckets,
+	}, []string{"resource", "code"}))
+	if err != nil {
+		return nil, err
+	}
+	inFlight, err := registerClientCollector(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "rdl_client_requests_in_flight",
+		Help:        "The requests sent to the resources awaiting their response, by resource.",
+		ConstLabels: service,
+	}, []string{"resource"}))
+	if err != nil {
+		return nil, err
+	}
+	return &ClientMetrics{requests.(*prometheus.CounterVec), duration.(*prometheus.HistogramVec), inFlight.(*prometheus.GaugeVec)}, nil
+}
+
+func registerClientCollector(reg prometheus.Registerer, collector prometheus.Collector) (prometheus.Collector, error) {
+	if err := reg.Register(collector); err != nil {
+		if already, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return already.ExistingCollector, nil
+		}
+		return nil, err
+	}
+	return collector, nil
+}
+
+// clientResource is the key of the name of the resource method in the context of its requests.
+type clientResource struct{}
+
+// do sends the request, counting and timing it in the metrics of the client if it has some.
+func (client {{client}}) do(req *http.Request) (*http.Response, error) {
+	if client.Metrics == nil {
+		return client.getClient().Do(req)
+	}
+	name, _ := req.Context().Value(clientResource{}).(string)
+	inFlight := client.Metrics.inFlight.WithLabelValues(name)
+	inFlight.Inc()
+	defer inFlight.Dec()
+	start := time.Now()
+	resp, err := client.getClient().Do(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	client.Metrics.requests.WithLabelValues(name, code).Inc()
+	client.Metrics.duration.WithLabelValues(name, code).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+{{end}}
 func (client {{client}}) getClient() *http.Client {
+	if client.HTTPClient != nil {
+		return client.HTTPClient
+	}
 	var c *http.Client
 	if client.Transport != nil {
 		c = &http.Client{Transport: client.Transport}
@@ -112,110 +284,148 @@ func (client {{client}}) addAuthHeader(req *http.Request) {
 	}
 }
 
-func (client {{client}}) httpGet(url string, headers map[string]string) (*http.Response, error) {
-	hclient := client.getClient()
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+func (client {{client}}) httpDo(ctx context.Context, method string, url string, headers map[string]string, body []byte) (*http.Response, error) {
+{{- if retry}}
+	policy := client.RetryPolicy
+	idempotent := method == "GET" || method == "PUT" || method == "DELETE"
+	backoff := time.Duration(0)
+	if policy != nil {
+		backoff = policy.Backoff
 	}
-	client.addAuthHeader(req)
-    if headers != nil {
-		for k, v := range headers {
-			req.Header.Add(k, v)
+	for attempt := 1; ; attempt++ {
+		req, err := client.httpRequest(ctx, method, url, headers, body)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.{{do}}(req)
+		if policy == nil || !idempotent || attempt >= policy.MaxAttempts || ctx.Err() != nil || (err == nil && !policy.retryable(resp.StatusCode)) {
+			return resp, err
+		}
+		delay := backoff
+		if err == nil {
+			if seconds, e := strconv.Atoi(resp.Header.Get("Retry-After")); e == nil && time.Duration(seconds)*time.Second > delay {
+				delay = time.Duration(seconds) * time.Second
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
 		}
+		if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+			delay = policy.MaxBackoff
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
 	}
-	return hclient.Do(req)
 }
 
-func (client {{client}}) httpDelete(url string, headers map[string]string) (*http.Response, error) {
-	hclient := client.getClient()
-	req, err := http.NewRequest("DELETE", url, nil)
+func (client {{client}}) httpRequest(ctx context.Context, method string, url string, headers map[string]string, body []byte) (*http.Request, error) {
+{{- end}}
+	var contentReader io.Reader
+	if body != nil {
+		contentReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, contentReader)
 	if err != nil {
 		return nil, err
 	}
+	if body != nil {
+		req.Header.Add("Content-type", "application/json")
+	}
 	client.addAuthHeader(req)
-    if headers != nil {
-		for k, v := range headers {
-			req.Header.Add(k, v)
+	for k, v := range headers {
+		req.Header.Add(k, v)
+	}
+	for _, hook := range client.RequestHooks {
+		err = hook(req)
+		if err != nil {
+			return nil, err
 		}
 	}
-	return hclient.Do(req)
+{{- if retry}}
+	return req, nil
+{{- else}}
+	return client.{{do}}(req)
+{{- end}}
 }
 
-func (client {{client}}) httpPut(url string, headers map[string]string, body []byte) (*http.Response, error) {
-	contentReader := bytes.NewReader(body)
-	hclient := client.getClient()
-	req, err := http.NewRequest("PUT", url, contentReader)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-type", "application/json")
-	client.addAuthHeader(req)
-    if headers != nil {
-		for k, v := range headers {
-			req.Header.Add(k, v)
-		}
-	}
-	return hclient.Do(req)
+func (client {{client}}) httpGet(ctx context.Context, url string, headers map[string]string) (*http.Response, error) {
+	return client.httpDo(ctx, "GET", url, headers, nil)
 }
 
-func (client {{client}}) httpPost(url string, headers map[string]string, body []byte) (*http.Response, error) {
-	contentReader := bytes.NewReader(body)
-	hclient := client.getClient()
-	req, err := http.NewRequest("POST", url, contentReader)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-type", "application/json")
-	client.addAuthHeader(req)
-    if headers != nil {
-		for k, v := range headers {
-			req.Header.Add(k, v)
-		}
-	}
-	return hclient.Do(req)
+func (client {{client}}) httpDelete(ctx context.Context, url string, headers map[string]string) (*http.Response, error) {
+	return client.httpDo(ctx, "DELETE", url, headers, nil)
+}
+
+func (client {{client}}) httpPut(ctx context.Context, url string, headers map[string]string, body []byte) (*http.Response, error) {
+	return client.httpDo(ctx, "PUT", url, headers, body)
+}
+
+func (client {{client}}) httpPost(ctx context.Context, url string, headers map[string]string, body []byte) (*http.Response, error) {
+	return client.httpDo(ctx, "POST", url, headers, body)
+}
+
+func (client {{client}}) httpPatch(ctx context.Context, url string, headers map[string]string, body []byte) (*http.Response, error) {
+	return client.httpDo(ctx, "PATCH", url, headers, body)
 }
 
-func (client {{client}}) httpPatch(url string, headers map[string]string, body []byte) (*http.Response, error) {
-	contentReader := bytes.NewReader(body)
-	hclient := client.getClient()
-	req, err := http.NewRequest("PATCH", url, contentReader)
+func (client {{client}}) httpOptions(ctx context.Context, url string, headers map[string]string, body []byte) (*http.Response, error) {
+	return client.httpDo(ctx, "OPTIONS", url, headers, body)
+}
+
+{{if uploads}}// httpUpload sends the body as it is read, with the content type, rather than as JSON. It is not
+// retried, as the body cannot be read again.
+func (client {{client}}) httpUpload(ctx context.Context, method string, url string, headers map[string]string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
 		return nil, err
 	}
-	req.Header.Add("Content-type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	client.addAuthHeader(req)
-    if headers != nil {
-		for k, v := range headers {
-			req.Header.Add(k, v)
+	for k, v := range headers {
+		req.Header.Add(k, v)
+	}
+	for _, hook := range client.RequestHooks {
+		err = hook(req)
+		if err != nil {
+			req.Body.Close()
+			return nil, err
 		}
 	}
-	return hclient.Do(req)
+	return client.{{do}}(req)
 }
 
-func (client {{client}}) httpOptions(url string, headers map[string]string, body []byte) (*http.Response, error) {
-	var contentReader io.Reader = nil
-	if body != nil {
-		contentReader = bytes.NewReader(body)
-	}
-	hclient := client.getClient()
-	req, err := http.NewRequest("OPTIONS", url, contentReader)
-	if err != nil {
-		return nil, err
+{{end}}{{if multipart}}// multipartBody streams the content as the file part of a multipart/form-data body, named after
+// the field, with the base name of the file it is read from if it has one. It returns the body
+// and its content type.
+func multipartBody(field string, content io.Reader) (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	filename := field
+	if named, ok := content.(interface{ Name() string }); ok {
+		filename = filepath.Base(named.Name())
 	}
-	if contentReader != nil {
-		req.Header.Add("Content-type", "application/json")
-	}
-	client.addAuthHeader(req)
-    if headers != nil {
-		for k, v := range headers {
-			req.Header.Add(k, v)
+	go func() {
+		part, err := writer.CreateFormFile(field, filename)
+		if err == nil {
+			_, err = io.Copy(part, content)
 		}
-	}
-	return hclient.Do(req)
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, writer.FormDataContentType()
 }
 
-func encodeStringParam(name string, val string, def string) string {
+{{end}}func encodeStringParam(name string, val string, def string) string {
 	if val == def {
 		return ""
 	}
@@ -294,11 +504,62 @@ func encodeParams(objs ...string) string {
 	}
 	return "?" + s[1:]
 }
+{{if streams}}
+//
+// eventReader reads the data of the server-sent events of a streaming resource.
+//
+type eventReader struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+func newEventReader(body io.ReadCloser) *eventReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &eventReader{body: body, scanner: scanner}
+}
+
+// next returns the data of the next event, or io.EOF at the end of the stream.
+func (events *eventReader) next() ([]byte, error) {
+	var data []byte
+	seen := false
+	for events.scanner.Scan() {
+		line := events.scanner.Text()
+		if line == "" && seen {
+			return data, nil
+		}
+		if strings.HasPrefix(line, "data:") {
+			if seen {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(line[5:], " ")...)
+			seen = true
+		}
+	}
+	if err := events.scanner.Err(); err != nil {
+		return nil, err
+	}
+	if seen {
+		return data, nil
+	}
+	return nil, io.EOF
+}
+{{end}}{{if etags}}{{etag_cache}}{{end}}{{typed_errors}}
+// {{client}}API is the interface of the methods of {{client}} calling the {{.Name}} resources,
+// for its callers to depend on, and the Mock{{client}} of the _mock.go file to stand in for in
+// their tests.
+type {{client}}API interface {
+{{- range .Resources}}
+{{deprecated_method .}}	{{method_sig .}}
+{{- end}}
+}
+
+var _ {{client}}API = {{client}}{}
 {{range .Resources}}
-func (client {{client}}) {{method_sig .}} {
+{{deprecated .}}func (client {{client}}) {{method_sig .}} {
 {{method_body .}}
 }
-{{end}}`
+{{if stream .}}{{stream_type .}}{{end}}{{end}}{{header_parser}}`
 
 func (gen *clientGenerator) emitClient() error {
 	commentFun := func(s string) string {
@@ -323,41 +584,121 @@ func (gen *clientGenerator) emitClient() error {
 		return fmt.Sprintf("%s %s%s", fName, fType, fAnno)
 	}
 	funcMap := template.FuncMap{
-		"rdlruntime":  func() string { return gen.librdl },
-		"header":      func() string { return generationHeader(gen.banner) },
-		"package":     func() string { return generationPackage(gen.schema, gen.ns) },
-		"field":       fieldFun,
-		"flattened":   func(t *rdl.Type) []*rdl.StructFieldDef { return flattenedFields(gen.registry, t) },
-		"typeRef":     func(t *rdl.Type) string { return makeTypeRef(gen.registry, t, gen.precise) },
-		"basename":    basenameFunc,
-		"comment":     commentFun,
-		"method_sig":  func(r *rdl.Resource) string { return goMethodSignature(gen.registry, r, gen.precise) },
-		"method_body": func(r *rdl.Resource) string { return goMethodBody(gen.registry, r, gen.precise) },
+		"rdlruntime": func() string { return gen.librdl },
+		"header":     func() string { return generationHeader(gen.banner) },
+		"package":    func() string { return generationPackage(gen.schema, gen.ns) },
+		"field":      fieldFun,
+		"flattened":  func(t *rdl.Type) []*rdl.StructFieldDef { return flattenedFields(gen.registry, t) },
+		"typeRef":    func(t *rdl.Type) string { return makeTypeRef(gen.registry, t, gen.precise) },
+		"basename":   basenameFunc,
+		"comment":    commentFun,
+		"method_sig": func(r *rdl.Resource) string { return goMethodSignature(gen.registry, r, gen.precise) },
+		"deprecated": func(r *rdl.Resource) string { return goDeprecatedComment(r.Annotations, "") },
+		"deprecated_method": func(r *rdl.Resource) string {
+			return goDeprecatedComment(r.Annotations, "\t")
+		},
+		"method_body": func(r *rdl.Resource) string {
+			s := ""
+			if gen.metrics {
+				methName, _ := goMethodName(gen.registry, r, gen.precise)
+				s = fmt.Sprintf("\tctx = context.WithValue(ctx, clientResource{}, %q)\n", capitalize(methName))
+			}
+			if streamsEvents(r) {
+				return s + goStreamMethodBody(gen.registry, r, gen.precise, gen.typedErrors)
+			}
+			return s + goMethodBody(gen.registry, r, gen.precise, gen.typedErrors)
+		},
+		"streams":     func() bool { return anyStreamsEvents(gen.schema) },
+		"uploads":     func() bool { return anyUploads(gen.schema, false) || anyUploads(gen.schema, true) },
+		"multipart":   func() bool { return anyUploads(gen.schema, true) },
+		"stream":      streamsEvents,
+		"stream_type": func(r *rdl.Resource) string { return goEventStreamType(gen.registry, r, gen.precise) },
 		"client":      func() string { return gen.name + "Client" },
+		"retry":       func() bool { return gen.retry },
+		"metrics":     func() bool { return gen.metrics },
+		"service":     func() string { return gen.name },
+		"do": func() string {
+			if gen.metrics {
+				return "do"
+			}
+			return "getClient().Do"
+		},
+		"retryableStatusCodes": func() string {
+			return strings.Join(goRetryableStatusCodes(gen.schema), ", ")
+		},
+		"header_parser": func() string {
+			if anyParsedHeaders(gen.registry, gen.schema, gen.precise, true) {
+				return goHeaderParser("parseResponseHeader")
+			}
+			return ""
+		},
+		"etags":      func() bool { return anyETagged(gen.schema) },
+		"etag_cache": func() string { return goClientETagTemplate },
+		"typed_errors": func() string {
+			if gen.typedErrors {
+				return gen.typedErrorDecls()
+			}
+			return ""
+		},
 	}
 	t := template.Must(template.New("FOO").Funcs(funcMap).Parse(clientTemplate))
 	return t.Execute(gen.writer, gen.schema)
 }
 
+// goTransientErrors are the errors that retrying a request may get past.
+var goTransientErrors = []string{"REQUEST_TIMEOUT", "TOO_MANY_REQUESTS", "BAD_GATEWAY", "SERVICE_UNAVAILABLE", "GATEWAY_TIMEOUT"}
+
+// goRetryableStatusCodes returns the status codes of the transient errors the resources declare as
+// exceptions, and those of the errors a proxy may return for any service.
+func goRetryableStatusCodes(schema *rdl.Schema) []string {
+	retryable := map[string]bool{"BAD_GATEWAY": true, "SERVICE_UNAVAILABLE": true, "GATEWAY_TIMEOUT": true}
+	for _, r := range schema.Resources {
+		for ecode := range r.Exceptions {
+			retryable[ecode] = true
+		}
+	}
+	var codes []string
+	for _, e := range goTransientErrors {
+		if retryable[e] {
+			codes = append(codes, rdl.StatusCode(e))
+		}
+	}
+	return codes
+}
+
 func goMethodSignature(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
-	noContent := r.Expected == "NO_CONTENT" && r.Alternatives == nil
 	returnSpec := "error"
 	//fixme: no content *with* output headers
-	if !noContent {
-		gtype := goType(reg, r.Type, false, "", "", precise, true)
-		returnSpec = "(" + gtype
-		if r.Outputs != nil {
-			for _, o := range r.Outputs {
-				otype := goType(reg, o.Type, false, "", "", precise, true)
-				returnSpec += ", " + otype
-			}
-		}
-		returnSpec += ", error)"
+	if results := goMethodResults(reg, r, precise); len(results) > 0 {
+		returnSpec = "(" + strings.Join(results, ", ") + ", error)"
 	}
 	methName, params := goMethodName(reg, r, precise)
+	params = append([]string{"ctx context.Context"}, params...)
 	return capitalize(methName) + "(" + strings.Join(params, ", ") + ") " + returnSpec
 }
 
+// goMethodResults returns the types of the results of the client method, before its error.
+func goMethodResults(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) []string {
+	noContent := r.Expected == "NO_CONTENT" && r.Alternatives == nil
+	var results []string
+	if streamsEvents(r) {
+		n, _ := goMethodName(reg, r, precise)
+		results = append(results, "*"+capitalize(n)+"Stream")
+	} else if streamingResponseType(r) != "" {
+		//the caller reads the body, and closes it
+		results = append(results, "io.ReadCloser")
+		for _, o := range r.Outputs {
+			results = append(results, goType(reg, o.Type, false, "", "", precise, true))
+		}
+	} else if !noContent {
+		results = append(results, goType(reg, r.Type, false, "", "", precise, true))
+		for _, o := range r.Outputs {
+			results = append(results, goType(reg, o.Type, false, "", "", precise, true))
+		}
+	}
+	return results
+}
+
 func goLiteral(lit interface{}, baseType string) string {
 	if lit == nil {
 		if baseType == "Bool" {
@@ -416,7 +757,7 @@ func explodeURL(reg rdl.TypeRegistry, r *rdl.Resource) string {
 				} else {
 					def := goLiteral(v.Default, string(baseType))
 					if baseType == "Enum" {
-						def = "\"" + def + "\""
+						def = "\"" + enumWireValue(reg, v.Type, def) + "\""
 						item = "encodeStringParam(\"" + qp + "\", " + gk + ".String(), " + def + ")"
 					} else {
 						item = "encode" + string(baseType) + "Param(\"" + qp + "\", " + strings.ToLower(string(baseType)) + "(" + gk + "), " + def + ")"
@@ -439,12 +780,16 @@ func explodeURL(reg rdl.TypeRegistry, r *rdl.Resource) string {
 	return path
 }
 
-func goMethodBody(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
+func goMethodBody(reg rdl.TypeRegistry, r *rdl.Resource, precise bool, typedErrors bool) string {
 	rtype := goType(reg, r.Type, false, "", "", precise, true)
+	download := streamingResponseType(r)
+	if download != "" {
+		rtype = "io.ReadCloser"
+	}
 	dataDef := fmt.Sprintf("var data %s", rtype)
 	errorReturn := "return data, err"
 	dataReturn := "return data, nil"
-	noContent := r.Expected == "NO_CONTENT" && r.Alternatives == nil
+	noContent := r.Expected == "NO_CONTENT" && r.Alternatives == nil && download == ""
 	if noContent {
 		errorReturn = "return err"
 		dataReturn = "return nil"
@@ -455,35 +800,34 @@ func goMethodBody(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
 		eret := "return nil"
 		for _, o := range r.Outputs {
 			dret += ", " + goName(string(o.Name))
-			eret += ", \"\""
+			eret += ", " + goHeaderZero(reg, o.Type, precise)
 		}
 		dret += ", nil"
 		eret += ", err"
 		dataReturn = dret
 		errorReturn = eret
 	}
-	headers := map[string]rdl.Identifier{}
-	for _, in := range r.Inputs {
-		if in.Header != "" {
-			headers[in.Header] = in.Name
-		}
+	extra := map[string]string{}
+	if download != "" {
+		extra["Accept"] = fmt.Sprintf("%q", download+", application/json")
 	}
 	s := ""
 	if dataDef != "" {
 		s += "\t" + dataDef + "\n"
 	}
-	httpArg := "url, nil"
-	if len(headers) > 0 {
-		//not optimal: when the headers are empty ("") they are still included
-		httpArg = "url, headers"
-		s += "\theaders := map[string]string{\n"
-		for k, v := range headers {
-			s += fmt.Sprintf("\t\t%q: %s,\n", k, v)
-		}
-		s += "\t}\n"
+	httpArg := "ctx, url, nil"
+	if headers := goHeaderMap(reg, r, precise, extra); headers != "" {
+		httpArg = "ctx, url, headers"
+		s += headers
+	} else if cachesETags(r) {
+		httpArg = "ctx, url, headers"
+		s += "\theaders := map[string]string{}\n"
 	}
 	url := explodeURL(reg, r)
 	s += "\turl := client.URL + " + url + "\n"
+	if cachesETags(r) {
+		s += goETagRequest()
+	}
 	method := capitalize(strings.ToLower(r.Method))
 	assign := ":="
 	switch method {
@@ -498,6 +842,10 @@ func goMethodBody(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
 				break
 			}
 		}
+		if uploadContentType(r) != "" {
+			s += goUploadRequest(r, strings.TrimPrefix(httpArg, "ctx, url, "), goName(bodyParam))
+			break
+		}
 		s += "\tcontentBytes, err := json.Marshal(" + bodyParam + ")\n"
 		s += "\tif err != nil {\n\t\t" + errorReturn + "\n\t}\n"
 		s += "\tresp, err := client.http" + method + "(" + httpArg + ", contentBytes)\n"
@@ -521,9 +869,15 @@ func goMethodBody(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
 		}
 	}
 	s += "\tif err != nil {\n\t\t" + errorReturn + "\n\t}\n"
+	if download != "" {
+		return s + goDownloadResponse(reg, r, precise, typedErrors, dataReturn, errorReturn)
+	}
 	s += "\tcontentBytes, err " + assign + " ioutil.ReadAll(resp.Body)\n"
 	s += "\tresp.Body.Close()\n"
 	s += "\tif err != nil {\n\t\t" + errorReturn + "\n\t}\n"
+	if cachesETags(r) {
+		s += goETagResponse(r)
+	}
 	s += "\tswitch resp.StatusCode {\n"
 	//loop for all expected results
 	var expected []string
@@ -564,16 +918,14 @@ func goMethodBody(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
 	//here, define the output headers
 	if r.Outputs != nil {
 		for _, o := range r.Outputs {
-			otype := goType(reg, o.Type, false, "", "", precise, true)
-			header := fmt.Sprintf("resp.Header.Get(rdl.FoldHttpHeaderName(%q))", o.Header)
-			if otype != "string" {
-				header = otype + "(" + header + ")"
-			}
-			s += "\t\t" + goName(string(o.Name)) + " := " + header + "\n"
+			s += goHeaderResult(reg, o, precise, errorReturn, "\t\t")
 		}
 	}
 	s += "\t\t" + dataReturn + "\n"
 	//end loop
+	if typedErrors {
+		s += goTypedErrorCases(reg, r, "\t", "", errorReturn)
+	}
 	s += "\tdefault:\n"
 	s += "\t\tvar errobj rdl.ResourceError\n"
 	s += "\t\tjson.Unmarshal(contentBytes, &errobj)\n"
@@ -588,3 +940,124 @@ func goMethodBody(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
 
 	return s
 }
+
+// goDownloadResponse is the end of the body of the client method of a resource streaming its
+// response, returning the response body unread, for the caller to close, or the error it holds.
+func goDownloadResponse(reg rdl.TypeRegistry, r *rdl.Resource, precise bool, typedErrors bool, dataReturn string, errorReturn string) string {
+	expected := []string{rdl.StatusCode(r.Expected)}
+	for _, e := range r.Alternatives {
+		expected = append(expected, rdl.StatusCode(e))
+	}
+	s := "\tswitch resp.StatusCode {\n"
+	s += "\tcase " + strings.Join(expected, ", ") + ":\n"
+	s += "\t\tdata = resp.Body\n"
+	for _, o := range r.Outputs {
+		s += goHeaderResult(reg, o, precise, errorReturn, "\t\t")
+	}
+	s += "\t\t" + dataReturn + "\n"
+	if typedErrors {
+		s += goTypedErrorCases(reg, r, "\t", "\t\tcontentBytes, _ := ioutil.ReadAll(resp.Body)\n\t\tresp.Body.Close()\n", errorReturn)
+	}
+	s += "\tdefault:\n"
+	s += "\t\tcontentBytes, _ := ioutil.ReadAll(resp.Body)\n"
+	s += "\t\tresp.Body.Close()\n"
+	s += "\t\tvar errobj rdl.ResourceError\n"
+	s += "\t\tjson.Unmarshal(contentBytes, &errobj)\n"
+	s += "\t\tif errobj.Code == 0 {\n"
+	s += "\t\t\terrobj.Code = resp.StatusCode\n"
+	s += "\t\t}\n"
+	s += "\t\tif errobj.Message == \"\" {\n"
+	s += "\t\t\terrobj.Message = string(contentBytes)\n"
+	s += "\t\t}\n"
+	s += "\t\t" + errorReturn + "obj\n"
+	s += "\t}"
+	return s
+}
+
+// goEventStreamType is the iterator over the items a streaming resource sends.
+func goEventStreamType(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
+	n, _ := goMethodName(reg, r, precise)
+	name := capitalize(n) + "Stream"
+	rtype := goType(reg, r.Type, false, "", "", precise, true)
+	s := "\n//\n// " + name + " iterates over the " + string(r.Type) + " items sent by " + capitalize(n) + ", as long as Next\n"
+	s += "// returns true. Close it to stop reading early. The Timeout of the client limits how long the\n"
+	s += "// whole stream may take, use the context to limit the wait instead.\n//\n"
+	s += "type " + name + " struct {\n"
+	s += "\tevents *eventReader\n"
+	s += "\titem   " + rtype + "\n"
+	s += "\terr    error\n"
+	s += "}\n\n"
+	s += "// Next reads the next item, returning false at the end of the stream or on an error.\n"
+	s += "func (stream *" + name + ") Next() bool {\n"
+	s += "\tdata, err := stream.events.next()\n"
+	s += "\tif err != nil {\n"
+	s += "\t\tif err != io.EOF {\n"
+	s += "\t\t\tstream.err = err\n"
+	s += "\t\t}\n"
+	s += "\t\treturn false\n"
+	s += "\t}\n"
+	s += "\tvar item " + rtype + "\n"
+	s += "\tif err := json.Unmarshal(data, &item); err != nil {\n"
+	s += "\t\tstream.err = err\n"
+	s += "\t\treturn false\n"
+	s += "\t}\n"
+	s += "\tstream.item = item\n"
+	s += "\treturn true\n"
+	s += "}\n\n"
+	s += "// Item returns the item read by the last call to Next.\n"
+	s += "func (stream *" + name + ") Item() " + rtype + " {\n"
+	s += "\treturn stream.item\n"
+	s += "}\n\n"
+	s += "// Err returns the error that ended the stream, if any.\n"
+	s += "func (stream *" + name + ") Err() error {\n"
+	s += "\treturn stream.err\n"
+	s += "}\n\n"
+	s += "// Close closes the connection to the server.\n"
+	s += "func (stream *" + name + ") Close() error {\n"
+	s += "\treturn stream.events.body.Close()\n"
+	s += "}\n"
+	return s
+}
+
+// goStreamMethodBody is the body of the client method of a streaming resource, returning the
+// stream once the server has accepted the request.
+func goStreamMethodBody(reg rdl.TypeRegistry, r *rdl.Resource, precise bool, typedErrors bool) string {
+	n, _ := goMethodName(reg, r, precise)
+	s := goHeaderMap(reg, r, precise, map[string]string{"Accept": "\"text/event-stream, application/json\""})
+	body := "nil"
+	for _, in := range r.Inputs {
+		if in.Header == "" && !in.PathParam && in.QueryParam == "" {
+			body = "contentBytes"
+		}
+	}
+	s += "\turl := client.URL + " + explodeURL(reg, r) + "\n"
+	if body != "nil" {
+		for _, in := range r.Inputs {
+			if in.Header == "" && !in.PathParam && in.QueryParam == "" {
+				s += "\tcontentBytes, err := json.Marshal(" + string(in.Name) + ")\n"
+				s += "\tif err != nil {\n\t\treturn nil, err\n\t}\n"
+				break
+			}
+		}
+	}
+	s += "\tresp, err := client.httpDo(ctx, \"" + r.Method + "\", url, headers, " + body + ")\n"
+	s += "\tif err != nil {\n\t\treturn nil, err\n\t}\n"
+	s += "\tif resp.StatusCode != " + rdl.StatusCode(r.Expected) + " {\n"
+	s += "\t\tcontentBytes, _ := ioutil.ReadAll(resp.Body)\n"
+	s += "\t\tresp.Body.Close()\n"
+	if cases := goTypedErrorCases(reg, r, "\t\t", "", "return nil, err"); typedErrors && cases != "" {
+		s += "\t\tswitch resp.StatusCode {\n" + cases + "\t\t}\n"
+	}
+	s += "\t\tvar errobj rdl.ResourceError\n"
+	s += "\t\tjson.Unmarshal(contentBytes, &errobj)\n"
+	s += "\t\tif errobj.Code == 0 {\n"
+	s += "\t\t\terrobj.Code = resp.StatusCode\n"
+	s += "\t\t}\n"
+	s += "\t\tif errobj.Message == \"\" {\n"
+	s += "\t\t\terrobj.Message = string(contentBytes)\n"
+	s += "\t\t}\n"
+	s += "\t\treturn nil, errobj\n"
+	s += "\t}\n"
+	s += "\treturn &" + capitalize(n) + "Stream{events: newEventReader(resp.Body)}, nil"
+	return s
+}
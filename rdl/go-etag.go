@@ -0,0 +1,178 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// The GET resources annotated with x_etag (e.g. x_etag="true") make conditional requests without
+// code of their own in the handlers and callers. The go-server responds to them with an ETag, the
+// one of the ETag header output of the resource if it declares one and the handler sets it, or
+// else a hash of the JSON of the response, and with an empty 304 if the If-None-Match header of
+// the request has it. The go-client keeps the ETag and body of their last response by URL in its
+// ETags cache, sends the ETag in the If-None-Match header of the next request to the URL, and
+// decodes the cached body when the server responds 304, as if it had sent it again.
+//
+
+// etagged tells whether the resource is a GET annotated with x_etag, whose responses are cached
+// by their ETag. The resources streaming their response are not.
+func etagged(r *rdl.Resource) bool {
+	v, ok := r.Annotations["x_etag"]
+	return ok && v != "false" && strings.ToUpper(r.Method) == "GET" && !streamsEvents(r) && streamingResponseType(r) == ""
+}
+
+// anyETagged tells whether any resource of the schema is annotated with x_etag.
+func anyETagged(schema *rdl.Schema) bool {
+	for _, r := range schema.Resources {
+		if etagged(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// goETagArg returns the ETag the adaptor of go-server passes to writeETagged, the variable of the
+// string ETag header output of the resource, or "" for the hash of the response.
+func goETagArg(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
+	for _, out := range r.Outputs {
+		if strings.ToLower(out.Header) == "etag" && goParsedHeader(reg, out.Type, precise) == "" {
+			if goType(reg, out.Type, false, "", "", precise, true) != "string" {
+				return "string(" + string(out.Name) + ")"
+			}
+			return string(out.Name)
+		}
+	}
+	return "\"\""
+}
+
+// declaresIfNoneMatch tells whether the resource takes the If-None-Match header as an input, for
+// its caller to set rather than the cache of the client.
+func declaresIfNoneMatch(r *rdl.Resource) bool {
+	for _, in := range r.Inputs {
+		if strings.ToLower(in.Header) == "if-none-match" {
+			return true
+		}
+	}
+	return false
+}
+
+const goServerETagTemplate = `
+// writeETagged writes the data as the JSON response of a resource annotated with x_etag, with the
+// ETag given by its handler, or else a hash of the JSON, or an empty 304 response if the request
+// already has that ETag in its If-None-Match header.
+func writeETagged(writer http.ResponseWriter, request *http.Request, code int, data interface{}, etag string) {
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		rdl.JSONResponse(writer, 500, &rdl.ResourceError{Code: 500, Message: err.Error()})
+		return
+	}
+	if etag == "" {
+		sum := sha256.Sum256(b)
+		etag = "\"" + hex.EncodeToString(sum[:16]) + "\""
+	}
+	writer.Header().Set("ETag", etag)
+	if etagMatches(request.Header.Get("If-None-Match"), etag) {
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	fmt.Fprintf(writer, "%s\n", b)
+}
+
+// etagMatches tells whether the If-None-Match header has the ETag, comparing them weakly, i.e.
+// without their W/ prefix, as RFC 9110 requires for If-None-Match.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+`
+
+const goClientETagTemplate = `
+// ETagCache holds the ETag and body of the last response of each URL of the resources annotated
+// with x_etag, for the client to make their next requests conditional, with If-None-Match, and
+// reuse the body when the server responds 304. It is safe for concurrent use, and shared by the
+// copies of the client.
+type ETagCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]etagEntry
+}
+
+type etagEntry struct {
+	etag string
+	body []byte
+}
+
+// NewETagCache returns a cache of the responses of up to maxEntries URLs, or of any number if it
+// is 0. Once full, an arbitrary entry is evicted for each new one.
+func NewETagCache(maxEntries int) *ETagCache {
+	return &ETagCache{maxEntries: maxEntries, entries: make(map[string]etagEntry)}
+}
+
+func (cache *ETagCache) get(url string) (string, []byte) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	e := cache.entries[url]
+	return e.etag, e.body
+}
+
+func (cache *ETagCache) put(url string, etag string, body []byte) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if _, ok := cache.entries[url]; !ok && cache.maxEntries > 0 && len(cache.entries) >= cache.maxEntries {
+		for k := range cache.entries {
+			delete(cache.entries, k)
+			break
+		}
+	}
+	cache.entries[url] = etagEntry{etag, body}
+}
+`
+
+// cachesETags tells whether the client method of the resource caches its responses by their ETag,
+// unless the caller sets the If-None-Match header.
+func cachesETags(r *rdl.Resource) bool {
+	return etagged(r) && !declaresIfNoneMatch(r)
+}
+
+// goETagRequest returns the statements of the client method of the resource adding the ETag of
+// the cached response of the URL to the headers of the request.
+func goETagRequest() string {
+	s := "\tvar cachedETag string\n"
+	s += "\tvar cachedBody []byte\n"
+	s += "\tif client.ETags != nil {\n"
+	s += "\t\tif cachedETag, cachedBody = client.ETags.get(url); cachedETag != \"\" {\n"
+	s += "\t\t\theaders[\"If-None-Match\"] = cachedETag\n"
+	s += "\t\t}\n"
+	s += "\t}\n"
+	return s
+}
+
+// goETagResponse returns the statements of the client method of the resource taking the cached
+// body for that of a 304 response, and caching the body of a response with an ETag.
+func goETagResponse(r *rdl.Resource) string {
+	code := rdl.StatusCode(r.Expected)
+	s := "\tif resp.StatusCode == 304 && cachedETag != \"\" {\n"
+	s += "\t\tresp.StatusCode = " + code + "\n"
+	s += "\t\tcontentBytes = cachedBody\n"
+	s += "\t} else if resp.StatusCode == " + code + " && client.ETags != nil {\n"
+	s += "\t\tif etag := resp.Header.Get(\"ETag\"); etag != \"\" {\n"
+	s += "\t\t\tclient.ETags.put(url, etag, contentBytes)\n"
+	s += "\t\t}\n"
+	s += "\t}\n"
+	return s
+}
@@ -0,0 +1,343 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"sort"
+	"strings"
+)
+
+//
+// The header inputs and outputs of the resources have the Go types of their RDL types in the
+// handlers of go-server and the methods of go-client, not just strings: a Bool, number, Enum,
+// Timestamp, or UUID header is formatted as its JSON value would be (unquoted), and parsed back
+// when received. The server answers 400 to a request whose header does not parse as its type,
+// and the client fails with an error on a response whose header does not.
+// String headers are passed as they are, and checked by go-server against the pattern, values, and
+// sizes of their type. go-model declares a constant of the name of each header of the resources,
+// e.g. HeaderIfModifiedSince for If-Modified-Since.
+//
+
+// goHeaderParserTemplate is the function parsing a header into a pointer to the Go type of the
+// header, declared by the server and the client under their own name, as they can share a package.
+const goHeaderParserTemplate = `
+// %s parses the value of a header into v, a pointer to the Go type of the header. A
+// Timestamp is also parsed from an HTTP date, e.g. of If-Modified-Since.
+func %s(s string, v interface{}) error {
+	var err error
+	switch p := v.(type) {
+	case *bool:
+		*p, err = strconv.ParseBool(s)
+	case *int8:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 8)
+		*p = int8(n)
+	case *int16:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 16)
+		*p = int16(n)
+	case *int32:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 32)
+		*p = int32(n)
+	case *int64:
+		*p, err = strconv.ParseInt(s, 10, 64)
+	case *float32:
+		var f float64
+		f, err = strconv.ParseFloat(s, 32)
+		*p = float32(f)
+	case *float64:
+		*p, err = strconv.ParseFloat(s, 64)
+	case *rdl.Timestamp:
+		if *p, err = rdl.TimestampParse(s); err != nil {
+			if t, herr := http.ParseTime(s); herr == nil {
+				*p, err = rdl.Timestamp{Time: t}, nil
+			}
+		}
+	case *rdl.UUID:
+		if *p = rdl.ParseUUID(s); *p == nil {
+			err = fmt.Errorf("not a UUID: %%s", s)
+		}
+	case json.Unmarshaler:
+		err = p.UnmarshalJSON([]byte(strconv.Quote(s)))
+	default:
+		err = fmt.Errorf("cannot parse a header as %%T", v)
+	}
+	return err
+}
+`
+
+// goHeaderParser returns the declaration of the header parser of the name.
+func goHeaderParser(name string) string {
+	return fmt.Sprintf(goHeaderParserTemplate, name, name)
+}
+
+// goParsedHeader returns the Go type a header of the type is parsed into, before its conversion
+// to the type of the header, or "" if it is a string, which needs no parsing.
+func goParsedHeader(reg rdl.TypeRegistry, tref rdl.TypeRef, precise bool) string {
+	switch bt := reg.FindBaseType(tref); bt {
+	case rdl.BaseTypeString, rdl.BaseTypeSymbol:
+		return ""
+	case rdl.BaseTypeBool:
+		return "bool"
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return strings.ToLower(bt.String())
+	case rdl.BaseTypeTimestamp:
+		return "rdl.Timestamp"
+	case rdl.BaseTypeUUID:
+		return "rdl.UUID"
+	}
+	//an Enum, whose pointer is a json.Unmarshaler
+	return goType(reg, tref, false, "", "", precise, true)
+}
+
+// anyParsedHeaders tells whether the resources have a header input (or output, if out is set) of
+// a type that needs parsing.
+func anyParsedHeaders(reg rdl.TypeRegistry, schema *rdl.Schema, precise bool, out bool) bool {
+	for _, r := range schema.Resources {
+		headers := r.Inputs
+		if out {
+			headers = nil
+			for _, o := range r.Outputs {
+				headers = append(headers, &rdl.ResourceInput{Name: o.Name, Type: o.Type, Header: o.Header})
+			}
+		}
+		for _, in := range headers {
+			if in.Header != "" && goParsedHeader(reg, in.Type, precise) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// goHeaderString returns the expression of the value of the header to send, given that of its
+// Go type.
+func goHeaderString(reg rdl.TypeRegistry, tref rdl.TypeRef, precise bool, expr string) string {
+	if goParsedHeader(reg, tref, precise) != "" {
+		return "fmt.Sprint(" + expr + ")"
+	}
+	if goType(reg, tref, false, "", "", precise, true) != "string" {
+		return "string(" + expr + ")"
+	}
+	return expr
+}
+
+// goHeaderDefault returns the Go expression of the default value of a header of the type.
+func goHeaderDefault(reg rdl.TypeRegistry, tref rdl.TypeRef, precise bool, def interface{}) string {
+	if s, ok := def.(string); ok {
+		if reg.FindBaseType(tref) == rdl.BaseTypeEnum {
+			return "New" + goType(reg, tref, false, "", "", precise, true) + "(" + fmt.Sprintf("%q", s) + ")"
+		}
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(def)
+}
+
+// goHeaderValue returns the statements declaring the variable of the Go type of a header that
+// needs parsing, a pointer if it is optional, set to the default if there is one, then to the
+// value parsed from the string expression if it is not empty, at the indentation. If the value
+// does not parse, the onError statements run, with err set.
+func goHeaderValue(reg rdl.TypeRegistry, tref rdl.TypeRef, precise bool, optional bool, def interface{}, name string, src string, parser string, onError string, indent string) string {
+	gtype := goType(reg, tref, false, "", "", precise, true)
+	parsed := goParsedHeader(reg, tref, precise)
+	s := ""
+	switch {
+	case def != nil && optional:
+		s += indent + name + "Default := " + gtype + "(" + goHeaderDefault(reg, tref, precise, def) + ")\n"
+		s += indent + name + " := &" + name + "Default\n"
+	case def != nil:
+		s += indent + name + " := " + gtype + "(" + goHeaderDefault(reg, tref, precise, def) + ")\n"
+	case optional:
+		s += indent + "var " + name + " *" + gtype + "\n"
+	default:
+		s += indent + "var " + name + " " + gtype + "\n"
+	}
+	s += indent + "if h := " + src + "; h != \"\" {\n"
+	s += indent + "\tvar v " + parsed + "\n"
+	s += indent + "\tif err := " + parser + "(h, &v); err != nil {\n"
+	for _, line := range strings.Split(strings.TrimSuffix(onError, "\n"), "\n") {
+		s += indent + "\t\t" + line + "\n"
+	}
+	s += indent + "\t}\n"
+	value := "v"
+	if parsed != gtype {
+		value = gtype + "(v)"
+	}
+	if optional {
+		if value != "v" {
+			s += indent + "\tp := " + value + "\n"
+			value = "p"
+		}
+		s += indent + "\t" + name + " = &" + value + "\n"
+	} else {
+		s += indent + "\t" + name + " = " + value + "\n"
+	}
+	s += indent + "}\n"
+	return s
+}
+
+// goHeaderInput returns the statements of the adaptor of go-server declaring the variable of a
+// header input, answering 400 to a request whose value of the header is not valid for its type.
+func goHeaderInput(reg rdl.TypeRegistry, schemaName string, in *rdl.ResourceInput, name string, precise bool) string {
+	hname := in.Header
+	badRequest := fmt.Sprintf("rdl.JSONResponse(writer, http.StatusBadRequest, &rdl.ResourceError{Code: http.StatusBadRequest, Message: \"Bad %s header: \" + err.Error()})\nreturn\n", hname)
+	if goParsedHeader(reg, in.Type, precise) != "" {
+		return goHeaderValue(reg, in.Type, precise, in.Optional, in.Default, name, fmt.Sprintf("request.Header.Get(%q)", hname), "parseHeaderParam", badRequest, "\t")
+	}
+	s := ""
+	gtype := goType(reg, in.Type, false, "", "", precise, true)
+	value := name
+	if gtype != "string" {
+		value = name + "Value"
+	}
+	if in.Default != nil {
+		s += fmt.Sprintf("\t%s := rdl.HeaderParam(request, %q, %s)\n", value, hname, goHeaderDefault(reg, in.Type, precise, in.Default))
+	} else if in.Optional {
+		s += fmt.Sprintf("\t%s := rdl.OptionalHeaderParam(request, %q)\n", value, hname)
+	} else {
+		s += fmt.Sprintf("\t%s := rdl.HeaderParam(request, %q, \"\")\n", value, hname)
+	}
+	if t := reg.FindType(in.Type); t != nil && t.Variant == rdl.TypeVariantStringTypeDef && t.StringTypeDef.Name != "String" {
+		//the pattern, values, and sizes of the String type
+		indent := "\t"
+		if in.Optional {
+			s += "\tif " + value + " != \"\" {\n"
+			indent = "\t\t"
+		}
+		s += fmt.Sprintf("%sif val := rdl.Validate(%sSchema(), %q, %s); !val.Valid {\n", indent, schemaName, in.Type, value)
+		s += fmt.Sprintf("%s\trdl.JSONResponse(writer, http.StatusBadRequest, &rdl.ResourceError{Code: http.StatusBadRequest, Message: \"Bad %s header: \" + val.Error})\n", indent, hname)
+		s += indent + "\treturn\n"
+		s += indent + "}\n"
+		if in.Optional {
+			s += "\t}\n"
+		}
+	}
+	if value != name {
+		s += "\t" + name + " := " + gtype + "(" + value + ")\n"
+	}
+	return s
+}
+
+// goHeaderOutput returns the statement of the adaptor of go-server setting a header output of the
+// response to the value of the variable, unless it is optional and has no value, at the indentation.
+func goHeaderOutput(reg rdl.TypeRegistry, out *rdl.ResourceOutput, name string, precise bool, indent string) string {
+	set := "writer.Header().Set(\"" + out.Header + "\", " + goHeaderString(reg, out.Type, precise, name) + ")\n"
+	if !out.Optional {
+		return indent + set
+	}
+	cond := ""
+	switch goParsedHeader(reg, out.Type, precise) {
+	case "":
+		cond = name + " != \"\""
+	case "rdl.Timestamp":
+		cond = "!" + name + ".IsZero()"
+	case "rdl.UUID":
+		cond = name + " != nil"
+	default:
+		//a bool, number, or Enum, whose zero value is a value
+		return indent + set
+	}
+	return indent + "if " + cond + " {\n" + indent + "\t" + set + indent + "}\n"
+}
+
+// goHeaderMap returns the statements of go-client declaring the headers of the request of the
+// resource, with the extra ones, e.g. Accept. The optional headers of a type that needs parsing are
+// pointers, only added if set.
+func goHeaderMap(reg rdl.TypeRegistry, r *rdl.Resource, precise bool, extra map[string]string) string {
+	headers := map[string]string{}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	var optional []*rdl.ResourceInput
+	for _, in := range r.Inputs {
+		if in.Header == "" {
+			continue
+		}
+		if in.Optional && goParsedHeader(reg, in.Type, precise) != "" {
+			optional = append(optional, in)
+		} else {
+			headers[in.Header] = goHeaderString(reg, in.Type, precise, goName(string(in.Name)))
+		}
+	}
+	if len(headers) == 0 && len(optional) == 0 {
+		return ""
+	}
+	//not optimal: when the headers are empty ("") they are still included
+	s := "\theaders := map[string]string{\n"
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		s += fmt.Sprintf("\t\t%q: %s,\n", k, headers[k])
+	}
+	s += "\t}\n"
+	for _, in := range optional {
+		name := goName(string(in.Name))
+		s += "\tif " + name + " != nil {\n"
+		s += fmt.Sprintf("\t\theaders[%q] = %s\n", in.Header, goHeaderString(reg, in.Type, precise, "*"+name))
+		s += "\t}\n"
+	}
+	return s
+}
+
+// goHeaderResult returns the statements of go-client declaring the variable of a header output of
+// the response, returning with errorReturn if it does not parse as its type, at the indentation.
+func goHeaderResult(reg rdl.TypeRegistry, out *rdl.ResourceOutput, precise bool, errorReturn string, indent string) string {
+	name := goName(string(out.Name))
+	header := fmt.Sprintf("resp.Header.Get(rdl.FoldHttpHeaderName(%q))", out.Header)
+	if goParsedHeader(reg, out.Type, precise) != "" {
+		return goHeaderValue(reg, out.Type, precise, false, nil, name, header, "parseResponseHeader", errorReturn, indent)
+	}
+	if otype := goType(reg, out.Type, false, "", "", precise, true); otype != "string" {
+		header = otype + "(" + header + ")"
+	}
+	return indent + name + " := " + header + "\n"
+}
+
+// goHeaderZero returns the zero value of the Go type of a header output, for the results of a
+// client method failing.
+func goHeaderZero(reg rdl.TypeRegistry, tref rdl.TypeRef, precise bool) string {
+	switch goParsedHeader(reg, tref, precise) {
+	case "":
+		return "\"\""
+	case "bool":
+		return "false"
+	case "rdl.Timestamp":
+		return goType(reg, tref, false, "", "", precise, true) + "{}"
+	case "rdl.UUID":
+		return "nil"
+	}
+	return "0"
+}
+
+// goHeaderConstants returns the declarations of the constants of the names of the headers of the
+// resources, e.g. HeaderIfModifiedSince for If-Modified-Since.
+func goHeaderConstants(schema *rdl.Schema) string {
+	seen := make(map[string]bool)
+	var names [][]string
+	add := func(header string) {
+		name := "Header" + strings.Replace(strings.Title(strings.ToLower(strings.Replace(header, "-", " ", -1))), " ", "", -1)
+		if header != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, []string{name, fmt.Sprintf("= %q", header)})
+		}
+	}
+	for _, r := range schema.Resources {
+		for _, in := range r.Inputs {
+			add(in.Header)
+		}
+		for _, out := range r.Outputs {
+			add(out.Header)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return "\n//\n// The names of the headers of the resources.\n//\nconst (\n" + goAlignedFields(names) + ")\n"
+}
@@ -0,0 +1,182 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+//
+// Canonical JSON for go-model (-x canonical=true). Structs get a CanonicalJSON method returning
+// their JSON in the canonical form of RFC 8785 (JCS), byte for byte the same as that of the
+// canonicalJSON method java-model generates with the option, to sign or hash: the keys of the
+// objects sorted by their UTF-16 code units, no whitespace, strings escaping only the quote, the
+// backslash, and the control characters, and numbers in the shortest form of their float64 value,
+// as ECMAScript writes them, but integers, written as they are, not to lose the digits of Int64s.
+//
+
+func (gen *modelGenerator) emitStructCanonicalJSON(name rdl.TypeName) {
+	gen.canonicalJSON = true
+	gen.emit(fmt.Sprintf("\n//\n// CanonicalJSON returns the canonical JSON of the %s, with sorted keys and no whitespace, to sign or hash\n//\n", name))
+	gen.emit(fmt.Sprintf("func (p *%s) CanonicalJSON() ([]byte, error) {\n", name))
+	gen.emit("\treturn canonicalJSON(p)\n")
+	gen.emit("}\n")
+}
+
+// canonicalImports adds the imports of the canonicalJSON function, if the structs of the schema have
+// a CanonicalJSON method.
+func (gen *modelGenerator) canonicalImports(t *rdl.Type, imports map[string]string) {
+	if gen.canonical && t.Variant == rdl.TypeVariantStructTypeDef && templateParams(t) == nil {
+		for _, pkg := range []string{"bytes", "encoding/json", "fmt", "math", "sort", "strconv", "strings", "unicode/utf16"} {
+			imports[pkg] = ""
+		}
+	}
+}
+
+// emitCanonicalJSON emits the functions writing the canonical JSON of a value, if used.
+func (gen *modelGenerator) emitCanonicalJSON() {
+	if gen.canonicalJSON {
+		gen.emit(goCanonicalJSONTemplate)
+	}
+}
+
+const goCanonicalJSONTemplate = `
+//
+// canonicalJSON returns the JSON of the value in the canonical form of RFC 8785, but for integers,
+// written as they are
+//
+func canonicalJSON(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var value interface{}
+	if err := d.Decode(&value); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeCanonicalJSON(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case json.Number:
+		n, err := canonicalNumber(t)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(n)
+	case string:
+		writeCanonicalString(buf, t)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range t {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return canonicalKeyLess(keys[i], keys[j])
+		})
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, t[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	}
+	return nil
+}
+
+// canonicalKeyLess orders the keys by their UTF-16 code units, as Java compares strings.
+func canonicalKeyLess(a string, b string) bool {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"':
+			buf.WriteString("\\\"")
+		case '\\':
+			buf.WriteString("\\\\")
+		case '\b':
+			buf.WriteString("\\b")
+		case '\f':
+			buf.WriteString("\\f")
+		case '\n':
+			buf.WriteString("\\n")
+		case '\r':
+			buf.WriteString("\\r")
+		case '\t':
+			buf.WriteString("\\t")
+		default:
+			if c < 0x20 {
+				buf.WriteString(fmt.Sprintf("\\u%04x", c))
+			} else {
+				buf.WriteRune(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// canonicalNumber returns the integer as it is, and any other number as ECMAScript writes its
+// float64 value, e.g. 0.1, 100, 1e+21, or 1.5e-7.
+func canonicalNumber(n json.Number) (string, error) {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if s == "-0" {
+			return "0", nil
+		}
+		return s, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", err
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	if a := math.Abs(f); a >= 1e-6 && a < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	s = strconv.FormatFloat(f, 'e', -1, 64)
+	i := strings.IndexByte(s, 'e')
+	return s[:i+2] + strings.TrimLeft(s[i+2:], "0"), nil
+}
+`
@@ -0,0 +1,182 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// Deep copies for go-model (-x clone=true). Structs and unions get a Clone method returning a
+// pointer to a copy that shares no mutable state with the original, and named array and map
+// types get one returning a copy of the value. Any and Struct values are copied as the generic
+// JSON values (maps, slices, and scalars) they hold after unmarshalling.
+//
+
+func (gen *modelGenerator) emitStructClone(name rdl.TypeName, fields []*rdl.StructFieldDef, extra bool) {
+	gen.emit(fmt.Sprintf("\n//\n// Clone returns a deep copy of the %s\n//\n", name))
+	gen.emit(fmt.Sprintf("func (p *%s) Clone() *%s {\n", name, name))
+	gen.emit("\tif p == nil {\n\t\treturn nil\n\t}\n")
+	gen.emit("\tc := *p\n")
+	for _, f := range fields {
+		if nullableField(f) {
+			gen.emit(gen.cloneStatements("c."+capitalize(string(f.Name))+".Value", f.Type, f.Items, f.Keys, false, "\t", 1))
+			continue
+		}
+		gen.emit(gen.cloneStatements("c."+capitalize(string(f.Name)), f.Type, f.Items, f.Keys, gen.fieldPointer(f), "\t", 1))
+	}
+	if extra {
+		gen.cloneAny = true
+		gen.emit("\tif c.Extra != nil {\n")
+		gen.emit("\t\tc.Extra = cloneAny(c.Extra).(map[string]interface{})\n")
+		gen.emit("\t}\n")
+	}
+	gen.emit("\treturn &c\n")
+	gen.emit("}\n")
+}
+
+func (gen *modelGenerator) emitUnionClone(uName string, ut *rdl.UnionTypeDef) {
+	gen.emit(fmt.Sprintf("\n//\n// Clone returns a deep copy of the %s\n//\n", uName))
+	gen.emit(fmt.Sprintf("func (p *%s) Clone() *%s {\n", uName, uName))
+	gen.emit("\tif p == nil {\n\t\treturn nil\n\t}\n")
+	gen.emit("\tc := *p\n")
+	for _, v := range ut.Variants {
+		gen.emit(gen.cloneStatements("c."+capitalize(string(v)), v, "", "", true, "\t", 1))
+	}
+	gen.emit("\treturn &c\n")
+	gen.emit("}\n")
+}
+
+// emitCollectionClone emits the Clone method of a named array or map type.
+func (gen *modelGenerator) emitCollectionClone(name rdl.TypeName, tref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef) {
+	gen.emit(fmt.Sprintf("//\n// Clone returns a deep copy of the %s\n//\n", name))
+	gen.emit(fmt.Sprintf("func (p %s) Clone() %s {\n", name, name))
+	gen.emit("\tc := p\n")
+	gen.emit(gen.collectionCloneStatements("c", string(name), tref, items, keys, "\t", 1))
+	gen.emit("\treturn c\n")
+	gen.emit("}\n\n")
+}
+
+// cloneStatements returns the statements replacing the value of expr, a shallow copy, with a
+// deep copy. It is empty if the shallow copy shares nothing mutable.
+func (gen *modelGenerator) cloneStatements(expr string, tref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef, optional bool, indent string, depth int) string {
+	t := gen.registry.FindType(tref)
+	if t == nil {
+		return ""
+	}
+	bt := gen.registry.BaseType(t)
+	gtype := gen.goType(tref, optional, items, keys, true)
+	if (bt == rdl.BaseTypeStruct || bt == rdl.BaseTypeUnion) && strings.HasPrefix(gtype, "*") && !strings.HasSuffix(gtype, "Struct") {
+		return fmt.Sprintf("%s%s = %s.Clone()\n", indent, expr, expr)
+	}
+	if strings.HasPrefix(gtype, "*") {
+		v := fmt.Sprintf("v%d", depth)
+		s := fmt.Sprintf("%sif %s != nil {\n", indent, expr)
+		s += fmt.Sprintf("%s\t%s := *%s\n", indent, v, expr)
+		s += gen.cloneStatements(v, tref, items, keys, false, indent+"\t", depth+1)
+		s += fmt.Sprintf("%s\t%s = &%s\n", indent, expr, v)
+		s += indent + "}\n"
+		return s
+	}
+	switch bt {
+	case rdl.BaseTypeAny:
+		gen.cloneAny = true
+		return fmt.Sprintf("%s%s = cloneAny(%s)\n", indent, expr, expr)
+	case rdl.BaseTypeStruct:
+		gen.cloneAny = true
+		k := fmt.Sprintf("k%d", depth)
+		e := fmt.Sprintf("e%d", depth)
+		m := fmt.Sprintf("m%d", depth)
+		s := fmt.Sprintf("%sif %s != nil {\n", indent, expr)
+		s += fmt.Sprintf("%s\t%s := make(%s, len(%s))\n", indent, m, gtype, expr)
+		s += fmt.Sprintf("%s\tfor %s, %s := range %s {\n", indent, k, e, expr)
+		s += fmt.Sprintf("%s\t\t%s[%s] = cloneAny(%s)\n", indent, m, k, e)
+		s += indent + "\t}\n"
+		s += fmt.Sprintf("%s\t%s = %s\n", indent, expr, m)
+		s += indent + "}\n"
+		return s
+	case rdl.BaseTypeUUID:
+		if gen.googleUUIDs {
+			return "" //an array, copied with its struct
+		}
+		return fmt.Sprintf("%s%s = append(%s(nil), %s...)\n", indent, expr, gtype, expr)
+	case rdl.BaseTypeBytes:
+		s := fmt.Sprintf("%sif %s != nil {\n", indent, expr)
+		s += fmt.Sprintf("%s\t%s = append(make(%s, 0, len(%s)), %s...)\n", indent, expr, gtype, expr, expr)
+		s += indent + "}\n"
+		return s
+	case rdl.BaseTypeArray, rdl.BaseTypeMap:
+		if t.Variant == rdl.TypeVariantArrayTypeDef || t.Variant == rdl.TypeVariantMapTypeDef {
+			return fmt.Sprintf("%s%s = %s.Clone()\n", indent, expr, expr)
+		}
+		return gen.collectionCloneStatements(expr, gtype, tref, items, keys, indent, depth)
+	}
+	return ""
+}
+
+// collectionCloneStatements returns the statements replacing the slice or map held by expr with
+// a copy, cloning its items. Nil stays nil, empty stays empty, so the JSON is the same.
+func (gen *modelGenerator) collectionCloneStatements(expr string, name string, tref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef, indent string, depth int) string {
+	t := gen.registry.FindType(tref)
+	i := rdl.TypeRef("Any")
+	switch t.Variant {
+	case rdl.TypeVariantArrayTypeDef:
+		i = t.ArrayTypeDef.Items
+	case rdl.TypeVariantMapTypeDef:
+		i = t.MapTypeDef.Items
+	default:
+		if items != "" {
+			i = items
+		}
+	}
+	s := fmt.Sprintf("%sif %s != nil {\n", indent, expr)
+	if gen.registry.BaseType(t) == rdl.BaseTypeArray {
+		a := fmt.Sprintf("a%d", depth)
+		n := fmt.Sprintf("i%d", depth)
+		s += fmt.Sprintf("%s\t%s := make(%s, len(%s))\n", indent, a, name, expr)
+		s += fmt.Sprintf("%s\tcopy(%s, %s)\n", indent, a, expr)
+		if item := gen.cloneStatements(a+"["+n+"]", i, "", "", false, indent+"\t\t", depth+1); item != "" {
+			s += fmt.Sprintf("%s\tfor %s := range %s {\n", indent, n, a)
+			s += item
+			s += indent + "\t}\n"
+		}
+		s += fmt.Sprintf("%s\t%s = %s\n", indent, expr, a)
+	} else {
+		m := fmt.Sprintf("m%d", depth)
+		k := fmt.Sprintf("k%d", depth)
+		e := fmt.Sprintf("e%d", depth)
+		s += fmt.Sprintf("%s\t%s := make(%s, len(%s))\n", indent, m, name, expr)
+		s += fmt.Sprintf("%s\tfor %s, %s := range %s {\n", indent, k, e, expr)
+		s += gen.cloneStatements(e, i, "", "", false, indent+"\t\t", depth+1)
+		s += fmt.Sprintf("%s\t\t%s[%s] = %s\n", indent, m, k, e)
+		s += indent + "\t}\n"
+		s += fmt.Sprintf("%s\t%s = %s\n", indent, expr, m)
+	}
+	s += indent + "}\n"
+	return s
+}
+
+// emitCloneAny emits the function copying the generic JSON values of Any and Struct fields, if used.
+func (gen *modelGenerator) emitCloneAny() {
+	if !gen.cloneAny {
+		return
+	}
+	gen.emit("\n//\n// cloneAny returns a deep copy of a generic JSON value, i.e. of its maps and slices\n//\n")
+	gen.emit("func cloneAny(v interface{}) interface{} {\n")
+	gen.emit("\tswitch t := v.(type) {\n")
+	gen.emit("\tcase map[string]interface{}:\n")
+	gen.emit("\t\tm := make(map[string]interface{}, len(t))\n")
+	gen.emit("\t\tfor k, e := range t {\n\t\t\tm[k] = cloneAny(e)\n\t\t}\n")
+	gen.emit("\t\treturn m\n")
+	gen.emit("\tcase []interface{}:\n")
+	gen.emit("\t\ta := make([]interface{}, len(t))\n")
+	gen.emit("\t\tfor i, e := range t {\n\t\t\ta[i] = cloneAny(e)\n\t\t}\n")
+	gen.emit("\t\treturn a\n")
+	gen.emit("\tdefault:\n")
+	gen.emit("\t\treturn v\n")
+	gen.emit("\t}\n")
+	gen.emit("}\n")
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// Unknown fields in go-model (-x extrafields=true). An open struct, i.e. one not declared closed,
+// gets an Extra field holding the fields of the JSON object it is decoded from that are not in
+// the schema, and encodes them back after its own, so that a service passing the objects along
+// does not drop the fields added by a newer version of the schema.
+//
+
+// hasExtraFields tells whether the struct keeps the unknown fields of its JSON objects.
+func (gen *modelGenerator) hasExtraFields(st *rdl.StructTypeDef) bool {
+	return gen.extraFields && !st.Closed && patchTarget(st) == ""
+}
+
+// checkExtraFields fails if a field of the struct would be in the way of its Extra field.
+func (gen *modelGenerator) checkExtraFields(st *rdl.StructTypeDef, fields []*rdl.StructFieldDef) {
+	if !gen.hasExtraFields(st) {
+		return
+	}
+	for _, f := range fields {
+		if capitalize(string(f.Name)) == "Extra" {
+			gen.err = fmt.Errorf("Field '%s' of %s conflicts with the Extra field of -x extrafields=true", f.Name, st.Name)
+			return
+		}
+	}
+}
+
+// emitExtraField emits the Extra field of the struct, at the end of its fields.
+func (gen *modelGenerator) emitExtraField() {
+	gen.emit("\n\t// Extra holds the fields of the JSON object that are not in the schema, encoded back after the\n")
+	gen.emit("\t// others\n")
+	gen.emit("\tExtra map[string]interface{} `json:\"-\"`\n")
+}
+
+// extraFieldNames returns the quoted JSON names of the fields of the struct, which are not extra.
+func (gen *modelGenerator) extraFieldNames(st *rdl.StructTypeDef) string {
+	var names []string
+	for _, f := range flattenedFields(gen.registry, gen.registry.FindType(rdl.TypeRef(st.Name))) {
+		names = append(names, fmt.Sprintf("%q", f.Name))
+	}
+	return strings.Join(names, ", ")
+}
+
+// emitExtraFieldsMarshaller emits the MarshalJSON of the struct, adding its Extra fields.
+func (gen *modelGenerator) emitExtraFieldsMarshaller(st *rdl.StructTypeDef) {
+	name := capitalize(string(st.Name))
+	gen.extraJSON = true
+	gen.emit(fmt.Sprintf("\n//\n// MarshalJSON is defined for JSON encoding of a %s with its Extra fields\n//\n", name))
+	gen.emit(fmt.Sprintf("func (pTypeDef %s) MarshalJSON() ([]byte, error) {\n", name))
+	gen.emit(fmt.Sprintf("\tb, err := json.Marshal(raw%s(pTypeDef))\n", name))
+	gen.emit("\tif err == nil {\n")
+	gen.emit(fmt.Sprintf("\t\tb, err = withExtraJSONFields(b, pTypeDef.Extra, %s)\n", gen.extraFieldNames(st)))
+	gen.emit("\t}\n")
+	gen.emit("\treturn b, err\n")
+	gen.emit("}\n")
+}
+
+// emitExtraJSONFields emits the functions decoding and encoding the Extra fields, if used.
+func (gen *modelGenerator) emitExtraJSONFields() {
+	if !gen.extraJSON {
+		return
+	}
+	gen.emit("\n//\n// extraJSONFields returns the fields of the JSON object that are not the known ones, or nil\n//\n")
+	gen.emit("func extraJSONFields(b []byte, known ...string) (map[string]interface{}, error) {\n")
+	gen.emit("\tvar fields map[string]interface{}\n")
+	gen.emit("\tif err := json.Unmarshal(b, &fields); err != nil {\n")
+	gen.emit("\t\treturn nil, err\n")
+	gen.emit("\t}\n")
+	gen.emit("\tfor _, k := range known {\n")
+	gen.emit("\t\tdelete(fields, k)\n")
+	gen.emit("\t}\n")
+	gen.emit("\tif len(fields) == 0 {\n")
+	gen.emit("\t\treturn nil, nil\n")
+	gen.emit("\t}\n")
+	gen.emit("\treturn fields, nil\n")
+	gen.emit("}\n")
+	gen.emit("\n//\n// withExtraJSONFields adds the extra fields, other than the known ones, to the encoded JSON object\n//\n")
+	gen.emit("func withExtraJSONFields(b []byte, extra map[string]interface{}, known ...string) ([]byte, error) {\n")
+	gen.emit("\tfields := make(map[string]interface{}, len(extra))\n")
+	gen.emit("\tfor k, v := range extra {\n")
+	gen.emit("\t\tfields[k] = v\n")
+	gen.emit("\t}\n")
+	gen.emit("\tfor _, k := range known {\n")
+	gen.emit("\t\tdelete(fields, k)\n")
+	gen.emit("\t}\n")
+	gen.emit("\tif len(fields) == 0 {\n")
+	gen.emit("\t\treturn b, nil\n")
+	gen.emit("\t}\n")
+	gen.emit("\tx, err := json.Marshal(fields)\n")
+	gen.emit("\tif err != nil || len(b) == 2 {\n")
+	gen.emit("\t\treturn x, err\n")
+	gen.emit("\t}\n")
+	gen.emit("\treturn append(append(b[:len(b)-1], ','), x[1:]...), nil\n")
+	gen.emit("}\n")
+}
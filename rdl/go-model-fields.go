@@ -0,0 +1,229 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+//
+// How go-model represents struct fields. By default, optional fields of scalar types (bool,
+// numbers, Timestamp, UUID) are pointers, so that an unset field can be told from a zero value,
+// optional fields are omitted from the JSON when unset, and required fields are omitted when
+// they hold a zero value that is also their default. -x pointers=false makes optional fields
+// values, -x omitempty=false sends unset optional fields too (as null or a zero value), and
+// -x emitzero=true sends zero values of required fields, e.g. an explicit false. The x_go
+// annotation of a field sets this for the field alone, with a comma-separated list of pointer
+// or value, and omitempty or emitzero, e.g. x_go="value,emitzero".
+//
+// A field annotated x_nullable="true" is a Nullable, which tells a field set to null from one
+// absent from the JSON: it is omitted when not Present (by omitzero, which needs Go 1.24), and
+// sent as null when Null. It is never a pointer, and x_go does not apply to it.
+//
+// The slices and maps of required fields are made empty by the New<Type> constructor and Init,
+// which decoding JSON calls, and those of optional fields are left nil and omitted when empty.
+// With -x collections=empty, those of optional fields are made empty too, so that the code using
+// them does not panic on a nil map, and with -x collections=emit they are also sent when empty,
+// as [] or {}, and omitted only when nil (by omitzero). x_go="omitempty" or "emitzero" on such a
+// field omits it when empty or sends it as null when nil, as for the other fields.
+//
+
+// goCodecs returns the binary codecs to tag the struct fields for, e.g. -x cbor=true.
+func goCodecs(options []string) []string {
+	var codecs []string
+	for _, codec := range []string{"cbor", "msgpack"} {
+		if goGenerationBoolOptionSet(options, codec) {
+			codecs = append(codecs, codec)
+		}
+	}
+	return codecs
+}
+
+// codecTags returns the struct tags of the field for the binary codecs, e.g.
+// ` cbor:"name,omitempty"`.
+func (gen *modelGenerator) codecTags(name string, option string) string {
+	s := ""
+	for _, codec := range gen.codecs {
+		s += fmt.Sprintf(" %s:\"%s%s\"", codec, name, option)
+	}
+	return s
+}
+
+// goFieldRepresentation is how a struct field is declared in go-model.
+type goFieldRepresentation struct {
+	pointer   bool
+	omitempty bool
+	omitzero  bool
+}
+
+// fieldRepresentation returns the representation of the field, from the generation options and
+// its x_go annotation.
+func (gen *modelGenerator) fieldRepresentation(f *rdl.StructFieldDef) goFieldRepresentation {
+	if nullableField(f) {
+		return goFieldRepresentation{}
+	}
+	r := goFieldRepresentation{pointer: f.Optional && gen.pointers}
+	if gen.emptyCollection(f) && gen.collections == "emit" {
+		r.omitzero = true
+	} else if f.Optional {
+		r.omitempty = gen.omitempty
+	} else if f.Default != nil && !gen.emitZero {
+		r.omitempty = isZeroDefault(f.Default)
+	}
+	annotation, ok := f.Annotations["x_go"]
+	if !ok {
+		return r
+	}
+	for _, option := range strings.Split(annotation, ",") {
+		switch strings.TrimSpace(option) {
+		case "pointer":
+			r.pointer = f.Optional
+		case "value":
+			r.pointer = false
+		case "omitempty":
+			r.omitempty, r.omitzero = true, false
+		case "emitzero":
+			r.omitempty, r.omitzero = false, false
+		case "":
+		default:
+			if gen.err == nil {
+				gen.err = fmt.Errorf("Unknown x_go option '%s' for field '%s' (expected pointer, value, omitempty, or emitzero)", option, f.Name)
+			}
+		}
+	}
+	return r
+}
+
+// emptyCollection tells whether the field is an optional slice or map that New<Type> and Init
+// make empty, i.e. -x collections=empty or emit is set.
+func (gen *modelGenerator) emptyCollection(f *rdl.StructFieldDef) bool {
+	if !f.Optional || nullableField(f) || (gen.collections != "empty" && gen.collections != "emit") {
+		return false
+	}
+	switch gen.registry.FindBaseType(f.Type) {
+	case rdl.BaseTypeArray, rdl.BaseTypeMap:
+		return true
+	}
+	return false
+}
+
+// fieldPointer tells whether the field is declared as a pointer to its type.
+func (gen *modelGenerator) fieldPointer(f *rdl.StructFieldDef) bool {
+	return gen.fieldRepresentation(f).pointer
+}
+
+// goType returns the Go type of the RDL type in the model. With -x timestamp=time, a Timestamp is
+// a time.Time, which encodes to and decodes from the same RFC 3339 strings as an rdl.Timestamp,
+// and with -x uuid=google, a UUID is a uuid.UUID of github.com/google/uuid, which encodes to the
+// same strings as an rdl.UUID.
+func (gen *modelGenerator) goType(rdlType rdl.TypeRef, optional bool, items rdl.TypeRef, keys rdl.TypeRef, reference bool) string {
+	gtype := goType(gen.registry, rdlType, optional, items, keys, gen.precise, reference)
+	if gen.timeTimestamps {
+		gtype = goTimestampPattern.ReplaceAllString(gtype, "time.Time")
+	}
+	if gen.googleUUIDs {
+		gtype = goUUIDPattern.ReplaceAllString(gtype, "uuid.UUID")
+	}
+	return gtype
+}
+
+var goTimestampPattern = regexp.MustCompile(`\brdl\.Timestamp\b`)
+var goUUIDPattern = regexp.MustCompile(`\brdl\.UUID\b`)
+
+// fieldType returns the Go type of the field, as declared in its struct.
+func (gen *modelGenerator) fieldType(f *rdl.StructFieldDef) string {
+	if generic := gen.templateFieldType(f); generic != "" {
+		return generic
+	}
+	if nullableField(f) {
+		return "Nullable[" + gen.goType(f.Type, false, f.Items, f.Keys, true) + "]"
+	}
+	return gen.goType(f.Type, gen.fieldPointer(f), f.Items, f.Keys, true)
+}
+
+// hasNullableFields tells whether any struct of the schema has a nullable field.
+func (gen *modelGenerator) hasNullableFields() bool {
+	for _, t := range gen.schema.Types {
+		if t.Variant == rdl.TypeVariantStructTypeDef {
+			for _, f := range t.StructTypeDef.Fields {
+				if nullableField(f) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// emitNullable emits the Nullable type of the nullable fields.
+func (gen *modelGenerator) emitNullable() {
+	gen.emit(`
+//
+// Nullable holds the value of a nullable field. Present tells whether the field was in the
+// JSON, and Null whether it was null there. A field that is not Present is omitted from the
+// JSON (with Go 1.24 or later, by the omitzero of its tag), and one that is Null is sent as null.
+//
+type Nullable[T any] struct {
+	Present bool
+	Null    bool
+	Value   T
+}
+
+//
+// NullableOf returns a Nullable holding the value.
+//
+func NullableOf[T any](value T) Nullable[T] {
+	return Nullable[T]{Present: true, Value: value}
+}
+
+//
+// NullOf returns a Nullable set to null.
+//
+func NullOf[T any]() Nullable[T] {
+	return Nullable[T]{Present: true, Null: true}
+}
+
+//
+// IsZero tells whether the field is absent, for omitzero.
+//
+func (n Nullable[T]) IsZero() bool {
+	return !n.Present
+}
+
+//
+// MarshalJSON is defined for proper JSON encoding of a Nullable
+//
+func (n Nullable[T]) MarshalJSON() ([]byte, error) {
+	if n.Null || !n.Present {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.Value)
+}
+
+//
+// UnmarshalJSON is defined for proper JSON decoding of a Nullable
+//
+func (n *Nullable[T]) UnmarshalJSON(b []byte) error {
+	var zero T
+	n.Present = true
+	n.Null = string(b) == "null"
+	n.Value = zero
+	if n.Null {
+		return nil
+	}
+	return json.Unmarshal(b, &n.Value)
+}
+`)
+}
+
+// isZeroDefault tells whether the default value of a field is the zero value of its type, which
+// omitempty leaves out.
+func isZeroDefault(def interface{}) bool {
+	v := reflect.ValueOf(def)
+	return v.Interface() == reflect.Zero(v.Type()).Interface()
+}
@@ -0,0 +1,61 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// Generic types in go-model (see generics.go). The generic type is a Go 1.18 generic struct,
+// e.g. Page[T any], and each of its instantiations is defined on it, e.g.
+//
+//   type ContactPage Page[*Contact]
+//
+// with the methods of a struct, so the code written for a Page[T] works on all of them. The
+// type parameters, being placeholders, are not emitted.
+//
+
+// emitTemplate emits the generic struct of the generic type. Its methods are those of the
+// instantiations, which know their type arguments.
+func (gen *modelGenerator) emitTemplate(t *rdl.Type, params []string) {
+	st := t.StructTypeDef
+	gen.emitTypeComment(t)
+	name := fmt.Sprintf("%s[%s any]", st.Name, strings.Join(params, ", "))
+	gen.emitStructFields(flattenedFields(gen.registry, t), rdl.TypeName(name), st.Comment, gen.hasExtraFields(st))
+}
+
+// templateInstance returns the Go type of the generic struct instantiated by the struct, e.g.
+// Page[*Contact], or "" if it is not an instantiation or has fields of its own besides.
+func (gen *modelGenerator) templateInstance(st *rdl.StructTypeDef) string {
+	template, args := templateOf(st)
+	if template == "" {
+		return ""
+	}
+	t := gen.registry.FindType(template)
+	if t == nil || len(flattenedFields(gen.registry, t)) != len(st.Fields) {
+		return ""
+	}
+	var types []string
+	for _, arg := range args {
+		//the type of the items of an array of it, e.g. *Contact
+		types = append(types, strings.TrimPrefix(gen.goType("Array", false, rdl.TypeRef(arg), "", true), "[]"))
+	}
+	return fmt.Sprintf("%s[%s]", template, strings.Join(types, ", "))
+}
+
+// templateFieldType returns the type of a field of a generic struct with its items of a type
+// parameter, e.g. []T, or "" if its type is not generic.
+func (gen *modelGenerator) templateFieldType(f *rdl.StructFieldDef) string {
+	param := string(f.Items)
+	if !gen.typeParams[param] {
+		return ""
+	}
+	if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeMap {
+		return "map[" + gen.goType(f.Keys, false, "", "", true) + "]" + param
+	}
+	return "[]" + param
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+// emitApplyPatch emits the ApplyPatch method applying the merge patch type to the struct it
+// patches (see addPatchTypes). The fields of the patch are those of the struct, in order.
+func (gen *modelGenerator) emitApplyPatch(patch *rdl.StructTypeDef) {
+	name := patchTarget(patch)
+	t := gen.registry.FindType(rdl.TypeRef(name))
+	if t == nil {
+		gen.err = fmt.Errorf("Cannot find type '%s' patched by %s", name, patch.Name)
+		return
+	}
+	gen.emit(fmt.Sprintf("\n//\n// ApplyPatch applies the %s to the %s: the fields left out of the patch are\n", patch.Name, name))
+	gen.emit("// unchanged, those set to null are removed, and the others replaced, or merged for a nested patch.\n//\n")
+	gen.emit(fmt.Sprintf("func (p *%s) ApplyPatch(patch *%s) {\n", name, patch.Name))
+	for i, f := range flattenedFields(gen.registry, t) {
+		fname := capitalize(string(f.Name))
+		field := "patch." + fname
+		gen.emit(fmt.Sprintf("\tif %s.Present {\n", field))
+		switch {
+		case nullableField(f):
+			gen.emit(fmt.Sprintf("\t\tif %s.Null {\n", field))
+			gen.emit(fmt.Sprintf("\t\t\tp.%s = %s{}\n", fname, gen.fieldType(f)))
+			gen.emit("\t\t} else {\n")
+			gen.emit(fmt.Sprintf("\t\t\tp.%s = NullableOf(%s.Value)\n", fname, field))
+			gen.emit("\t\t}\n")
+		case patch.Fields[i].Type != f.Type:
+			ftype := strings.TrimPrefix(gen.goType(f.Type, false, f.Items, f.Keys, true), "*")
+			gen.emit(fmt.Sprintf("\t\tif %s.Null || %s.Value == nil {\n", field, field))
+			gen.emit(fmt.Sprintf("\t\t\tp.%s = nil\n", fname))
+			gen.emit("\t\t} else {\n")
+			gen.emit(fmt.Sprintf("\t\t\tif p.%s == nil {\n", fname))
+			gen.emit(fmt.Sprintf("\t\t\t\tp.%s = new(%s)\n", fname, ftype))
+			gen.emit("\t\t\t}\n")
+			gen.emit(fmt.Sprintf("\t\t\tp.%s.ApplyPatch(%s.Value)\n", fname, field))
+			gen.emit("\t\t}\n")
+		case gen.fieldType(f) != gen.goType(f.Type, false, f.Items, f.Keys, true):
+			//a pointer to a scalar
+			gen.emit(fmt.Sprintf("\t\tif %s.Null {\n", field))
+			gen.emit(fmt.Sprintf("\t\t\tp.%s = nil\n", fname))
+			gen.emit("\t\t} else {\n")
+			gen.emit(fmt.Sprintf("\t\t\tvalue := %s.Value\n", field))
+			gen.emit(fmt.Sprintf("\t\t\tp.%s = &value\n", fname))
+			gen.emit("\t\t}\n")
+		default:
+			//the value of a null field is the zero value
+			gen.emit(fmt.Sprintf("\t\tp.%s = %s.Value\n", fname, field))
+		}
+		gen.emit("\t}\n")
+	}
+	gen.emit("}\n")
+}
+
+// emitPatchMarshaller emits the MarshalJSON of the merge patch type, which leaves out the fields
+// that are not Present. The omitzero of their tags does so too, but only from Go 1.24 on: older
+// compilers ignore it, and the patch would then set every field left out to null, removing it.
+func (gen *modelGenerator) emitPatchMarshaller(patch *rdl.StructTypeDef) {
+	gen.patchJSON = true
+	gen.emit(fmt.Sprintf("\n//\n// MarshalJSON is defined for JSON encoding of a %s, leaving out the fields that are not Present\n//\n", patch.Name))
+	gen.emit(fmt.Sprintf("func (pTypeDef %s) MarshalJSON() ([]byte, error) {\n", patch.Name))
+	gen.emit("\tb := []byte{'{'}\n")
+	gen.emit("\tvar err error\n")
+	for _, f := range patch.Fields {
+		field := "pTypeDef." + capitalize(string(f.Name))
+		gen.emit(fmt.Sprintf("\tif %s.Present {\n", field))
+		gen.emit(fmt.Sprintf("\t\tif b, err = appendPatchJSONField(b, %q, %s); err != nil {\n", f.Name, field))
+		gen.emit("\t\t\treturn nil, err\n")
+		gen.emit("\t\t}\n")
+		gen.emit("\t}\n")
+	}
+	gen.emit("\treturn append(b, '}'), nil\n")
+	gen.emit("}\n")
+}
+
+// emitPatchJSONField emits the function the MarshalJSON of the merge patch types use, if any.
+func (gen *modelGenerator) emitPatchJSONField() {
+	if !gen.patchJSON {
+		return
+	}
+	gen.emit("\n//\n// appendPatchJSONField appends the field to the JSON object being encoded in b, still open\n//\n")
+	gen.emit("func appendPatchJSONField(b []byte, name string, value interface{}) ([]byte, error) {\n")
+	gen.emit("\tv, err := json.Marshal(value)\n")
+	gen.emit("\tif err != nil {\n")
+	gen.emit("\t\treturn nil, err\n")
+	gen.emit("\t}\n")
+	gen.emit("\tk, _ := json.Marshal(name)\n")
+	gen.emit("\tif len(b) > 1 {\n")
+	gen.emit("\t\tb = append(b, ',')\n")
+	gen.emit("\t}\n")
+	gen.emit("\treturn append(append(append(b, k...), ':'), v...), nil\n")
+	gen.emit("}\n")
+}
@@ -0,0 +1,151 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// The unions of go-model are structs with a field per variant and a Variant tag telling which
+// one is set. With -x unions=interface, a union holds its variant in a Value field instead, of a
+// sealed interface that only a struct per variant implements, e.g. for Union<Circle,Point>:
+//
+//   type ShapeValue interface { isShapeValue() }
+//   type ShapeCircle struct { Circle *Circle }
+//   type ShapePoint struct { Point *Point }
+//   type Shape struct { Value ShapeValue }
+//
+// so that the code handling a union is a type switch on its Value, which no other type can get
+// into, and which linters can check covers all the variants. The union stays a struct, so that
+// the fields, arrays, and maps of it are unchanged, and its MarshalJSON and UnmarshalJSON keep
+// the JSON of the tagged or untagged union. The cbor and msgpack tags do not apply to it.
+//
+
+// unionValueType returns the name of the struct holding the variant of the union.
+func unionValueType(uName string, v rdl.TypeRef) string {
+	return uName + capitalize(string(v))
+}
+
+func (gen *modelGenerator) emitUnionInterface(t *rdl.Type) {
+	tName, _, _ := rdl.TypeInfo(t)
+	ut := t.UnionTypeDef
+	uName := capitalize(string(tName))
+	var names []string
+	for _, v := range ut.Variants {
+		names = append(names, unionValueType(uName, v))
+	}
+	gen.emit(fmt.Sprintf("//\n// %sValue is the variant a %s holds, one of %s\n//\n", uName, uName, strings.Join(names, ", ")))
+	gen.emit(fmt.Sprintf("type %sValue interface {\n\tis%sValue()\n}\n", uName, uName))
+	for _, v := range ut.Variants {
+		uV := capitalize(string(v))
+		vName := unionValueType(uName, v)
+		gen.emit(fmt.Sprintf("\n//\n// %s is the %s variant of %s\n//\n", vName, v, uName))
+		gen.emit(fmt.Sprintf("type %s struct {\n\t%s %s\n}\n\n", vName, uV, gen.goType(v, true, "", "", true)))
+		gen.emit(fmt.Sprintf("func (%s) is%sValue() {}\n", vName, uName))
+	}
+	gen.emit("\n")
+	gen.emitTypeComment(t)
+	gen.emit(fmt.Sprintf("type %s struct {\n\tValue %sValue `rdl:\"union\"`\n}\n\n", uName, uName))
+
+	gen.emit(fmt.Sprintf("func (u %s) String() string {\n", uName))
+	gen.emit("\tswitch v := u.Value.(type) {\n")
+	for _, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("\tcase %s:\n", unionValueType(uName, v)))
+		gen.emit(fmt.Sprintf("\t\treturn fmt.Sprintf(\"%%v\", v.%s)\n", capitalize(string(v))))
+	}
+	gen.emit("\tdefault:\n")
+	gen.emit(fmt.Sprintf("\t\treturn \"<%s uninitialized>\"\n", uName))
+	gen.emit("\t}\n")
+	gen.emit("}\n\n")
+	gen.emit(fmt.Sprintf("//\n// Validate for %s\n//\n", uName))
+	gen.emit(fmt.Sprintf("func (p *%s) Validate() error {\n", uName))
+	gen.emit("\tswitch v := p.Value.(type) {\n")
+	for _, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("\tcase %s:\n", unionValueType(uName, v)))
+		gen.emit(fmt.Sprintf("\t\tif v.%s != nil {\n\t\t\treturn nil\n\t\t}\n", capitalize(string(v))))
+	}
+	gen.emit("\t}\n")
+	gen.emit(fmt.Sprintf("\treturn fmt.Errorf(\"%s: Missing required variant\")\n", uName))
+	gen.emit("}\n")
+
+	if gen.isUntaggedUnion(tName) {
+		gen.emitUntaggedUnionSerializer(ut, tName)
+	} else {
+		gen.emitUnionInterfaceSerializer(uName, ut)
+	}
+	if gen.clone {
+		gen.emitUnionInterfaceClone(uName, ut)
+	}
+}
+
+// emitUnionInterfaceSerializer emits the JSON methods of the tagged union, which reads and
+// writes an object with the variant as its only field, as the struct of the union does.
+func (gen *modelGenerator) emitUnionInterfaceSerializer(uName string, ut *rdl.UnionTypeDef) {
+	maxKeyLen := 0
+	for _, v := range ut.Variants {
+		if len(v) > maxKeyLen {
+			maxKeyLen = len(v)
+		}
+	}
+	maxVarLen := 0
+	for _, v := range ut.Variants {
+		if vType := gen.goType(v, true, "", "", true); len(vType) > maxVarLen {
+			maxVarLen = len(vType)
+		}
+	}
+	gen.emit(fmt.Sprintf("\ntype raw%s struct {\n", uName))
+	for _, v := range ut.Variants {
+		vType := gen.goType(v, true, "", "", true)
+		tag := fmt.Sprintf("`json:\"%s,omitempty\"`", v)
+		gen.emit(fmt.Sprintf("\t%s %s %s\n", leftJustified(capitalize(string(v)), maxKeyLen), leftJustified(vType, maxVarLen), tag))
+	}
+	gen.emit("}\n\n")
+	gen.emit(fmt.Sprintf("//\n// MarshalJSON for %s\n//\n", uName))
+	gen.emit(fmt.Sprintf("func (p %s) MarshalJSON() ([]byte, error) {\n", uName))
+	gen.emit(fmt.Sprintf("\tvar tmp raw%s\n", uName))
+	gen.emit("\tswitch v := p.Value.(type) {\n")
+	for _, v := range ut.Variants {
+		uV := capitalize(string(v))
+		gen.emit(fmt.Sprintf("\tcase %s:\n", unionValueType(uName, v)))
+		gen.emit(fmt.Sprintf("\t\ttmp.%s = v.%s\n", uV, uV))
+	}
+	gen.emit("\t}\n")
+	gen.emit("\treturn json.Marshal(tmp)\n")
+	gen.emit("}\n\n")
+	gen.emit(fmt.Sprintf("//\n// UnmarshalJSON for %s\n//\n", uName))
+	gen.emit(fmt.Sprintf("func (p *%s) UnmarshalJSON(b []byte) error {\n", uName))
+	gen.emit(fmt.Sprintf("\tvar tmp raw%s\n", uName))
+	gen.emit("\tif err := json.Unmarshal(b, &tmp); err != nil {\n")
+	gen.emit("\t\treturn err\n")
+	gen.emit("\t}\n")
+	gen.emit("\t")
+	for _, v := range ut.Variants {
+		uV := capitalize(string(v))
+		gen.emit(fmt.Sprintf("if tmp.%s != nil {\n\t\tp.Value = %s{%s: tmp.%s}\n\t} else ", uV, unionValueType(uName, v), uV, uV))
+	}
+	gen.emit(fmt.Sprintf("{\n\t\treturn fmt.Errorf(\"%s: Missing required variant\")\n\t}\n", uName))
+	gen.emit("\treturn nil\n")
+	gen.emit("}\n")
+}
+
+func (gen *modelGenerator) emitUnionInterfaceClone(uName string, ut *rdl.UnionTypeDef) {
+	gen.emit(fmt.Sprintf("\n//\n// Clone returns a deep copy of the %s\n//\n", uName))
+	gen.emit(fmt.Sprintf("func (p *%s) Clone() *%s {\n", uName, uName))
+	gen.emit("\tif p == nil {\n\t\treturn nil\n\t}\n")
+	gen.emit("\tc := *p\n")
+	cases := ""
+	for _, v := range ut.Variants {
+		if s := gen.cloneStatements("v."+capitalize(string(v)), v, "", "", true, "\t\t", 1); s != "" {
+			cases += fmt.Sprintf("\tcase %s:\n", unionValueType(uName, v)) + s + "\t\tc.Value = v\n"
+		}
+	}
+	if cases != "" {
+		gen.emit("\tswitch v := c.Value.(type) {\n" + cases + "\t}\n")
+	}
+	gen.emit("\treturn &c\n")
+	gen.emit("}\n")
+}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"github.com/ardielle/ardielle-go/rdl"
 	"path/filepath"
-	"reflect"
 	"sort"
 	"strings"
 )
@@ -29,10 +28,27 @@ type modelGenerator struct {
 	untaggedUnions []string
 	ns             string
 	rdl            bool
+	validate       bool
+	clone          bool
+	cloneAny       bool
+	pointers       bool
+	omitempty      bool
+	emitZero       bool
+	codecs         []string
+	typeParams     map[string]bool
+	extraFields    bool
+	extraJSON      bool
+	timeTimestamps bool
+	googleUUIDs    bool
+	canonical      bool
+	canonicalJSON  bool
+	collections    string
+	unionValues    bool
+	patchJSON      bool
 }
 
 // GenerateGoModel generates the model code for the types defined in the RDL schema.
-func GenerateGoModel(banner string, schema *rdl.Schema, outdir string, ns string, librdl string, prefixEnums bool, precise bool, untaggedUnions []string) error {
+func GenerateGoModel(banner string, schema *rdl.Schema, outdir string, ns string, librdl string, prefixEnums bool, precise bool, untaggedUnions []string, options []string) error {
 	name := strings.ToLower(string(schema.Name))
 	if strings.HasSuffix(outdir, ".go") {
 		name = filepath.Base(outdir)
@@ -40,6 +56,34 @@ func GenerateGoModel(banner string, schema *rdl.Schema, outdir string, ns string
 	} else {
 		name = name + "_model.go"
 	}
+	timestamps := goGenerationStringOptionSet(options, "timestamp")
+	switch timestamps {
+	case "", "rdl", "time":
+	default:
+		return fmt.Errorf("Unsupported timestamp option '%s' (expected rdl or time)", timestamps)
+	}
+	uuids := goGenerationStringOptionSet(options, "uuid")
+	switch uuids {
+	case "", "rdl", "google":
+	default:
+		return fmt.Errorf("Unsupported uuid option '%s' (expected rdl or google)", uuids)
+	}
+	collections := goGenerationStringOptionSet(options, "collections")
+	switch collections {
+	case "", "nil", "empty", "emit":
+	default:
+		return fmt.Errorf("Unsupported collections option '%s' (expected nil, empty, or emit)", collections)
+	}
+	unions := goGenerationStringOptionSet(options, "unions")
+	switch unions {
+	case "", "struct":
+	case "interface":
+		if len(goCodecs(options)) > 0 {
+			return fmt.Errorf("The unions=interface option does not support the cbor or msgpack tags")
+		}
+	default:
+		return fmt.Errorf("Unsupported unions option '%s' (expected struct or interface)", unions)
+	}
 	out, file, _, err := outputWriter(outdir, name, ".go")
 	if err != nil {
 		return err
@@ -47,12 +91,23 @@ func GenerateGoModel(banner string, schema *rdl.Schema, outdir string, ns string
 	if file != nil {
 		defer file.Close()
 	}
-	gen := &modelGenerator{rdl.NewTypeRegistry(schema), schema, out, librdl, prefixEnums, precise, nil, untaggedUnions, ns, schema.Name == "rdl"}
+	gen := &modelGenerator{rdl.NewTypeRegistry(schema), schema, out, librdl, prefixEnums, precise, nil, untaggedUnions, ns, schema.Name == "rdl", goGenerationBoolOptionSet(options, "validate"), goGenerationBoolOptionSet(options, "clone"), false,
+		!goGenerationBoolOptionUnset(options, "pointers"), !goGenerationBoolOptionUnset(options, "omitempty"), goGenerationBoolOptionSet(options, "emitzero"), goCodecs(options), schemaTypeParams(schema),
+		goGenerationBoolOptionSet(options, "extrafields"), false, timestamps == "time" && schema.Name != "rdl", uuids == "google" && schema.Name != "rdl",
+		goGenerationBoolOptionSet(options, "canonical"), false, collections, unions == "interface", false}
 	gen.emitHeader(banner)
 	if gen.err == nil {
+		if gen.hasNullableFields() {
+			gen.emitNullable()
+		}
 		for _, t := range schema.Types {
 			gen.emitType(t)
 		}
+		gen.emitCloneAny()
+		gen.emitExtraJSONFields()
+		gen.emitPatchJSONField()
+		gen.emitCanonicalJSON()
+		gen.emit(goHeaderConstants(schema))
 	}
 	out.Flush()
 	if gen.err == nil {
@@ -91,6 +146,8 @@ func (gen *modelGenerator) structHasFieldDefault(t *rdl.StructTypeDef) bool {
 			case rdl.BaseTypeArray, rdl.BaseTypeMap, rdl.BaseTypeStruct:
 				return true
 			}
+		} else if gen.emptyCollection(f) {
+			return true
 		}
 		if f.Default != nil {
 			switch gen.registry.FindBaseType(f.Type) {
@@ -143,6 +200,12 @@ func (gen *modelGenerator) requiredImports(t *rdl.Type, imports map[string]strin
 		if !gen.rdl {
 			imports[gen.librdl] = "rdl"
 		}
+		if b == rdl.BaseTypeTimestamp && gen.timeTimestamps {
+			imports["time"] = ""
+		}
+		if b == rdl.BaseTypeUUID && gen.googleUUIDs {
+			imports["github.com/google/uuid"] = ""
+		}
 	case rdl.BaseTypeEnum:
 		imports["encoding/json"] = ""
 		imports["fmt"] = ""
@@ -193,6 +256,23 @@ func (gen *modelGenerator) emitHeader(banner string) {
 	visited := make(map[rdl.TypeName]rdl.TypeName, 0)
 	for _, t := range gen.schema.Types {
 		gen.requiredImports(t, imports, visited)
+		gen.canonicalImports(t, imports)
+		if gen.checksOnDecode(t) {
+			imports["encoding/json"] = ""
+		}
+		if gen.validate || gen.checksOnDecode(t) {
+			pattern, values, minSize, maxSize := stringConstraints(gen.registry, t)
+			if pattern != "" {
+				imports["regexp"] = ""
+			}
+			min, max := numberConstraints(gen.registry, t)
+			if pattern != "" || values != nil || minSize != nil || maxSize != nil || min != nil || max != nil {
+				imports["fmt"] = ""
+			}
+			if gen.registry.BaseType(t) == rdl.BaseTypeStruct {
+				imports["fmt"] = ""
+			}
+		}
 	}
 	gen.emit(generationHeader(banner))
 	gen.emit("\n\npackage " + generationPackage(gen.schema, gen.ns) + "\n")
@@ -251,6 +331,9 @@ func (gen *modelGenerator) emitTypeComment(t *rdl.Type) {
 		s += " " + tComment
 	}
 	gen.emit(formatComment(s, 0, 80))
+	if reason, ok := deprecation(typeAnnotations(t)); ok {
+		gen.emit(formatComment("Deprecated: "+reason, 0, 80)[3:])
+	}
 }
 
 func goType(reg rdl.TypeRegistry, rdlType rdl.TypeRef, optional bool, items rdl.TypeRef, keys rdl.TypeRef, precise bool, reference bool) string {
@@ -290,6 +373,10 @@ func goType(reg rdl.TypeRegistry, rdlType rdl.TypeRef, optional bool, items rdl.
 			case rdl.BaseTypeTimestamp, rdl.BaseTypeUUID:
 				fullTypeName := rdlPrefix + cleanType
 				return prefix + fullTypeName
+			case rdl.BaseTypeBytes:
+				if lrdlType != "bytes" {
+					return cleanType
+				}
 			default:
 				if lrdlType == "struct" {
 					fullTypeName := rdlPrefix + cleanType
@@ -315,6 +402,9 @@ func goType(reg rdl.TypeRegistry, rdlType rdl.TypeRef, optional bool, items rdl.
 		return "string"
 	case rdl.BaseTypeSymbol:
 		return rdlPrefix + "Symbol"
+	case rdl.BaseTypeBytes:
+		//a slice, nil when unset, which encoding/json writes as a base64 string
+		return "[]byte"
 	case rdl.BaseTypeBool:
 		return prefix + "bool"
 	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
@@ -397,6 +487,9 @@ func (gen *modelGenerator) emitType(t *rdl.Type) {
 		bt := gen.registry.BaseType(t)
 		switch bt {
 		case rdl.BaseTypeAny:
+			if gen.typeParams[string(tName)] {
+				return
+			}
 			gen.emit("\n")
 			gen.emitTypeComment(t)
 			gen.emit(fmt.Sprintf("type %s interface{}\n", tName))
@@ -404,7 +497,19 @@ func (gen *modelGenerator) emitType(t *rdl.Type) {
 			if gen.precise {
 				gen.emit("\n")
 				gen.emitTypeComment(t)
-				gen.emit(fmt.Sprintf("type %s %s\n", tName, goType(gen.registry, rdl.TypeRef(bt.String()), false, "", "", gen.precise, false)))
+				gen.emit(fmt.Sprintf("type %s %s\n", tName, gen.goType(rdl.TypeRef(bt.String()), false, "", "", false)))
+			}
+			if gen.validate || gen.checksOnDecode(t) {
+				gen.emitConstraintValidator(t, tName, bt)
+			}
+			if gen.checksOnDecode(t) {
+				gen.emitStringUnmarshaller(tName)
+			}
+		case rdl.BaseTypeBytes:
+			if gen.precise && tName != "Bytes" {
+				gen.emit("\n")
+				gen.emitTypeComment(t)
+				gen.emit(fmt.Sprintf("type %s []byte\n", tName))
 			}
 		case rdl.BaseTypeStruct:
 			gen.emit("\n")
@@ -432,6 +537,10 @@ func goTypeName(name rdl.TypeName) rdl.TypeName {
 }
 
 func (gen *modelGenerator) emitUnion(t *rdl.Type) {
+	if gen.unionValues {
+		gen.emitUnionInterface(t)
+		return
+	}
 	tName, _, _ := rdl.TypeInfo(t)
 	ut := t.UnionTypeDef
 	uName := capitalize(string(tName))
@@ -456,15 +565,15 @@ func (gen *modelGenerator) emitUnion(t *rdl.Type) {
 	gen.emit(fmt.Sprintf("type %s struct {\n", uName))
 	s := leftJustified("Variant", maxKeyLen)
 	vtag := uName + "VariantTag"
-	gen.emit(fmt.Sprintf("\t%s %s `json:\"-\" rdl:\"union\"`\n", s, vtag))
+	gen.emit(fmt.Sprintf("\t%s %s `json:\"-\"%s rdl:\"union\"`\n", s, vtag, gen.codecTags("variant", "")))
 	maxVarLen := maxKeyLen + 1
 	if len(vtag) > maxVarLen {
 		maxVarLen = len(vtag)
 	}
 	for _, v := range ut.Variants {
 		uV := capitalize(string(v))
-		vType := goType(gen.registry, v, true, "", "", gen.precise, true)
-		tag := fmt.Sprintf("`json:\"%s,omitempty\"`", v)
+		vType := gen.goType(v, true, "", "", true)
+		tag := fmt.Sprintf("`json:\"%s,omitempty\"%s`", v, gen.codecTags(string(v), ",omitempty"))
 		s := leftJustified(uV, maxKeyLen)
 		gen.emit(fmt.Sprintf("\t%s %s %s\n", s, leftJustified(vType, maxVarLen), tag))
 	}
@@ -504,6 +613,9 @@ func (gen *modelGenerator) emitUnion(t *rdl.Type) {
 		gen.emit("\treturn p.Validate()\n")
 		gen.emit("}\n")
 	}
+	if gen.clone {
+		gen.emitUnionClone(uName, ut)
+	}
 }
 
 func (gen *modelGenerator) emitUntaggedUnionSerializer(ut *rdl.UnionTypeDef, uName rdl.TypeName) {
@@ -546,10 +658,14 @@ func (gen *modelGenerator) emitUntaggedUnionSerializer(ut *rdl.UnionTypeDef, uNa
 				gen.emit(fmt.Sprintf("\tif check%sStructFields(fields, %s) {\n", uName, names))
 				gen.emit(fmt.Sprintf("\t\tvar o %s\n", uV))
 				gen.emit("\t\tif err := json.Unmarshal(b, &o); err == nil {\n")
-				gen.emit(fmt.Sprintf("\t\t\tup := new(%s)\n", uName))
-				gen.emit(fmt.Sprintf("\t\t\tup.Variant = %sVariant%s\n", uName, uV))
-				gen.emit(fmt.Sprintf("\t\t\tup.%s = &o\n", uV))
-				gen.emit("\t\t\t*u = *up\n")
+				if gen.unionValues {
+					gen.emit(fmt.Sprintf("\t\t\tu.Value = %s{%s: &o}\n", unionValueType(string(uName), v), uV))
+				} else {
+					gen.emit(fmt.Sprintf("\t\t\tup := new(%s)\n", uName))
+					gen.emit(fmt.Sprintf("\t\t\tup.Variant = %sVariant%s\n", uName, uV))
+					gen.emit(fmt.Sprintf("\t\t\tup.%s = &o\n", uV))
+					gen.emit("\t\t\t*u = *up\n")
+				}
 				gen.emit("\t\t\treturn true\n")
 				gen.emit("\t\t}\n")
 				gen.emit("\t}\n")
@@ -582,11 +698,20 @@ func (gen *modelGenerator) emitUntaggedUnionSerializer(ut *rdl.UnionTypeDef, uNa
 
 	gen.emit(fmt.Sprintf("\n//\n// MarshalJSON for %s\n//\n", uName))
 	gen.emit(fmt.Sprintf("func (p %s) MarshalJSON() ([]byte, error) {\n", uName))
-	gen.emit("\tswitch p.Variant {\n")
+	if gen.unionValues {
+		gen.emit("\tswitch v := p.Value.(type) {\n")
+	} else {
+		gen.emit("\tswitch p.Variant {\n")
+	}
 	for _, v := range ut.Variants {
 		uV := capitalize(string(v))
-		gen.emit(fmt.Sprintf("\tcase %sVariant%s:\n", uName, uV))
-		gen.emit(fmt.Sprintf("\t\treturn json.Marshal(p.%s)\n", uV))
+		if gen.unionValues {
+			gen.emit(fmt.Sprintf("\tcase %s:\n", unionValueType(string(uName), v)))
+			gen.emit(fmt.Sprintf("\t\treturn json.Marshal(v.%s)\n", uV))
+		} else {
+			gen.emit(fmt.Sprintf("\tcase %sVariant%s:\n", uName, uV))
+			gen.emit(fmt.Sprintf("\t\treturn json.Marshal(p.%s)\n", uV))
+		}
 	}
 	gen.emit("\t}\n")
 	gen.emit(fmt.Sprintf("\treturn nil, fmt.Errorf(\"Cannot marshal uninitialized %s\")\n", uName))
@@ -620,11 +745,14 @@ func (gen *modelGenerator) emitArray(t *rdl.Type) {
 		case rdl.TypeVariantArrayTypeDef:
 			at := t.ArrayTypeDef
 			gen.emitTypeComment(t)
-			ftype := goType(gen.registry, at.Type, false, at.Items, "", gen.precise, false)
+			ftype := gen.goType(at.Type, false, at.Items, "", false)
 			gen.emit(fmt.Sprintf("type %s %s\n\n", at.Name, ftype))
+			if gen.clone {
+				gen.emitCollectionClone(at.Name, rdl.TypeRef(at.Name), at.Items, "")
+			}
 		default:
 			tName, tType, _ := rdl.TypeInfo(t)
-			gtype := goType(gen.registry, tType, false, "", "", gen.precise, false)
+			gtype := gen.goType(tType, false, "", "", false)
 			gen.emitTypeComment(t)
 			gen.emit(fmt.Sprintf("type %s %s\n\n", tName, gtype))
 		}
@@ -637,11 +765,14 @@ func (gen *modelGenerator) emitMap(t *rdl.Type) {
 		case rdl.TypeVariantMapTypeDef:
 			mt := t.MapTypeDef
 			gen.emitTypeComment(t)
-			ftype := goType(gen.registry, mt.Type, false, mt.Items, mt.Keys, gen.precise, false)
+			ftype := gen.goType(mt.Type, false, mt.Items, mt.Keys, false)
 			gen.emit(fmt.Sprintf("type %s %s\n\n", mt.Name, ftype))
+			if gen.clone {
+				gen.emitCollectionClone(mt.Name, rdl.TypeRef(mt.Name), mt.Items, mt.Keys)
+			}
 		default:
 			tName, tType, _ := rdl.TypeInfo(t)
-			gtype := goType(gen.registry, tType, false, "string", "", gen.precise, false)
+			gtype := gen.goType(tType, false, "string", "", false)
 			gen.emitTypeComment(t)
 			gen.emit(fmt.Sprintf("type %s %s\n\n", tName, gtype))
 		}
@@ -653,9 +784,27 @@ func (gen *modelGenerator) emitStruct(t *rdl.Type) {
 		switch t.Variant {
 		case rdl.TypeVariantStructTypeDef:
 			st := t.StructTypeDef
+			if params := templateParams(t); params != nil {
+				gen.emitTemplate(t, params)
+				return
+			}
 			flattened := flattenedFields(gen.registry, t)
+			for _, f := range flattened {
+				if nullableField(f) && f.Default != nil {
+					gen.err = fmt.Errorf("Field '%s' of %s is nullable, and cannot have a default", f.Name, st.Name)
+					return
+				}
+			}
+			gen.checkExtraFields(st, flattened)
+			if gen.err != nil {
+				return
+			}
 			gen.emitTypeComment(t)
-			gen.emitStructFields(flattened, st.Name, st.Comment)
+			if instance := gen.templateInstance(st); instance != "" {
+				gen.emit(fmt.Sprintf("type %s %s\n", st.Name, instance))
+			} else {
+				gen.emitStructFields(flattened, st.Name, st.Comment, gen.hasExtraFields(st))
+			}
 			init := gen.structHasFieldDefault(st)
 			gen.emit(fmt.Sprintf("\n//\n// New%s - creates an initialized %s instance, returns a pointer to it\n//\n", st.Name, st.Name))
 			gen.emit(fmt.Sprintf("func New%s(init ...*%s) *%s {\n", st.Name, st.Name, st.Name))
@@ -675,7 +824,20 @@ func (gen *modelGenerator) emitStruct(t *rdl.Type) {
 				gen.emitStructInitializer(st, flattened)
 			}
 			gen.emitStructUnmarshaller(st, init)
+			if gen.hasExtraFields(st) {
+				gen.emitExtraFieldsMarshaller(st)
+			}
 			gen.emitStructValidator(st, flattened)
+			if gen.clone {
+				gen.emitStructClone(st.Name, flattened, gen.hasExtraFields(st))
+			}
+			if gen.canonical {
+				gen.emitStructCanonicalJSON(st.Name)
+			}
+			if patchTarget(st) != "" {
+				gen.emitPatchMarshaller(st)
+				gen.emitApplyPatch(st)
+			}
 		case rdl.TypeVariantAliasTypeDef:
 			gen.emitTypeComment(t)
 			gen.emit(fmt.Sprintf("type %s rdl.Struct\n\n", t.AliasTypeDef.Name))
@@ -695,7 +857,13 @@ func (gen *modelGenerator) emitStructValidator(st *rdl.StructTypeDef, flattened
 	for _, f := range flattened {
 		fname := capitalize(string(f.Name))
 		ftype := string(f.Type)
-		if !f.Optional {
+		if nullableField(f) {
+			if !f.Optional {
+				gen.emit(fmt.Sprintf("\tif !pTypeDef.%s.Present {\n", fname))
+				gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: Missing required field: %s\")\n", st.Name, f.Name))
+				gen.emit("\t}\n")
+			}
+		} else if !f.Optional {
 			bt := gen.registry.FindBaseType(f.Type)
 			switch bt {
 			case rdl.BaseTypeString, rdl.BaseTypeSymbol:
@@ -711,21 +879,260 @@ func (gen *modelGenerator) emitStructValidator(st *rdl.StructTypeDef, flattened
 				gen.emit(fmt.Sprintf("\tif pTypeDef.%s.IsZero() {\n", fname))
 				gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: Missing required field: %s\")\n", st.Name, f.Name))
 				gen.emit("\t}\n")
-			case rdl.BaseTypeArray, rdl.BaseTypeMap, rdl.BaseTypeStruct, rdl.BaseTypeUUID:
+			case rdl.BaseTypeUUID:
+				if gen.googleUUIDs {
+					gen.emit(fmt.Sprintf("\tif pTypeDef.%s == uuid.Nil {\n", fname))
+				} else {
+					gen.emit(fmt.Sprintf("\tif pTypeDef.%s == nil {\n", fname))
+				}
+				gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: Missing required field: %s\")\n", st.Name, f.Name))
+				gen.emit("\t}\n")
+			case rdl.BaseTypeArray, rdl.BaseTypeMap, rdl.BaseTypeStruct, rdl.BaseTypeBytes:
 				gen.emit(fmt.Sprintf("\tif pTypeDef.%s == nil {\n", fname))
 				gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: Missing required field: %s\")\n", st.Name, f.Name))
 				gen.emit("\t}\n")
 			}
 		}
 	}
+	if gen.validate {
+		for _, f := range flattened {
+			context := fmt.Sprintf("%s.%s", st.Name, f.Name)
+			expr := "pTypeDef." + capitalize(string(f.Name))
+			pointer := gen.fieldPointer(f)
+			if nullableField(f) {
+				//a nullable field is checked when it holds a value
+				if checks := gen.constraintChecks(expr+".Value", f.Type, f.Items, f.Keys, false, context, nil, "\t", 1); checks != "" {
+					gen.emit(fmt.Sprintf("\tif %s.Present && !%s.Null {\n", expr, expr) + indentBlock(checks) + "\t}\n")
+				}
+				continue
+			}
+			switch gen.registry.FindBaseType(f.Type) {
+			case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+				checks := gen.constraintChecks(expr, f.Type, f.Items, f.Keys, pointer, context, nil, "\t", 1)
+				if f.Optional && !pointer && checks != "" {
+					//an optional value field is unset when zero
+					checks = fmt.Sprintf("\tif %s != 0 {\n", expr) + indentBlock(checks) + "\t}\n"
+				}
+				gen.emit(checks)
+			default:
+				gen.emit(gen.constraintChecks(expr, f.Type, f.Items, f.Keys, f.Optional, context, nil, "\t", 1))
+			}
+		}
+	}
+	gen.emit("\treturn nil\n")
+	gen.emit("}\n")
+}
+
+func (gen *modelGenerator) hasConstraints(tref rdl.TypeRef) bool {
+	if strings.HasPrefix(string(tref), "rdl.") {
+		return false
+	}
+	t := gen.registry.FindType(tref)
+	if t == nil {
+		return false
+	}
+	pattern, values, minSize, maxSize := stringConstraints(gen.registry, t)
+	min, max := numberConstraints(gen.registry, t)
+	return pattern != "" || values != nil || minSize != nil || maxSize != nil || min != nil || max != nil
+}
+
+// emitConstraintValidator emits a function checking a value against the pattern, values, and
+// size constraints of a string type, or the range of a numeric type. Struct validators call it
+// for each field of the type.
+func (gen *modelGenerator) emitConstraintValidator(t *rdl.Type, tName rdl.TypeName, bt rdl.BaseType) {
+	if !gen.hasConstraints(rdl.TypeRef(tName)) {
+		return
+	}
+	vtype := strings.ToLower(bt.String())
+	if bt == rdl.BaseTypeString || bt == rdl.BaseTypeSymbol {
+		vtype = "string"
+	}
+	pattern, values, minSize, maxSize := stringConstraints(gen.registry, t)
+	min, max := numberConstraints(gen.registry, t)
+	if pattern != "" {
+		gen.emit(fmt.Sprintf("\nvar pattern%s = regexp.MustCompile(%q)\n", tName, "^"+pattern+"$"))
+	}
+	gen.emit(fmt.Sprintf("\n//\n// validate%s - checks the value against the constraints of the %s type\n//\n", tName, tName))
+	gen.emit(fmt.Sprintf("func validate%s(v %s) error {\n", tName, vtype))
+	if minSize != nil {
+		gen.emit(fmt.Sprintf("\tif len(v) < %d {\n", *minSize))
+		gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: string too small (minimum size is %d)\")\n", tName, *minSize))
+		gen.emit("\t}\n")
+	}
+	if maxSize != nil {
+		gen.emit(fmt.Sprintf("\tif len(v) > %d {\n", *maxSize))
+		gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: string too large (maximum size is %d)\")\n", tName, *maxSize))
+		gen.emit("\t}\n")
+	}
+	if values != nil {
+		quoted := make([]string, 0, len(values))
+		for _, v := range values {
+			quoted = append(quoted, fmt.Sprintf("%q", v))
+		}
+		gen.emit("\tswitch v {\n")
+		gen.emit(fmt.Sprintf("\tcase %s:\n", strings.Join(quoted, ", ")))
+		gen.emit("\tdefault:\n")
+		gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: value mismatch (%%q)\", v)\n", tName))
+		gen.emit("\t}\n")
+	}
+	if pattern != "" {
+		gen.emit(fmt.Sprintf("\tif !pattern%s.MatchString(v) {\n", tName))
+		gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: pattern mismatch (%%q does not match %%s)\", v, pattern%s)\n", tName, tName))
+		gen.emit("\t}\n")
+	}
+	if min != nil {
+		gen.emit(fmt.Sprintf("\tif v < %s {\n", numericValueString(*min)))
+		gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: value too small (%%v < %s)\", v)\n", tName, numericValueString(*min)))
+		gen.emit("\t}\n")
+	}
+	if max != nil {
+		gen.emit(fmt.Sprintf("\tif v > %s {\n", numericValueString(*max)))
+		gen.emit(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s: value too large (%%v > %s)\", v)\n", tName, numericValueString(*max)))
+		gen.emit("\t}\n")
+	}
 	gen.emit("\treturn nil\n")
 	gen.emit("}\n")
 }
 
+// constraintChecks returns the code checking the value of expr against the constraints of its
+// type, descending into array items, map keys and values, and nested structs and unions. The
+// context (with its args) names the value in the error returned.
+func (gen *modelGenerator) constraintChecks(expr string, tref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef, optional bool, context string, args []string, indent string, depth int) string {
+	t := gen.registry.FindType(tref)
+	if t == nil {
+		return ""
+	}
+	fail := func(indent string, msg string, msgArgs ...string) string {
+		all := append(append([]string{}, args...), msgArgs...)
+		sargs := ""
+		if len(all) > 0 {
+			sargs = ", " + strings.Join(all, ", ")
+		}
+		return fmt.Sprintf("%sreturn fmt.Errorf(%q%s)\n", indent, context+": "+msg, sargs)
+	}
+	s := ""
+	switch bt := gen.registry.BaseType(t); bt {
+	case rdl.BaseTypeString, rdl.BaseTypeSymbol:
+		if gen.hasConstraints(tref) {
+			check := fmt.Sprintf("%sif err := validate%s(string(%s)); err != nil {\n", indent, goTypeName(rdl.TypeName(tref)), expr)
+			check += fail(indent+"\t", "%v", "err")
+			check += indent + "}\n"
+			if optional {
+				s = fmt.Sprintf("%sif %s != \"\" {\n", indent, expr) + indentBlock(check) + indent + "}\n"
+			} else {
+				s = check
+			}
+		}
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		if gen.hasConstraints(tref) {
+			vtype := strings.ToLower(bt.String())
+			val := expr
+			if optional {
+				val = "*" + expr
+			}
+			check := fmt.Sprintf("%sif err := validate%s(%s(%s)); err != nil {\n", indent, goTypeName(rdl.TypeName(tref)), vtype, val)
+			check += fail(indent+"\t", "%v", "err")
+			check += indent + "}\n"
+			if optional {
+				s = fmt.Sprintf("%sif %s != nil {\n", indent, expr) + indentBlock(check) + indent + "}\n"
+			} else {
+				s = check
+			}
+		}
+	case rdl.BaseTypeBytes:
+		size, minSize, maxSize := bytesConstraints(gen.registry, t)
+		s = gen.sizeChecks(expr, size, minSize, maxSize, indent+"\t", fail)
+		if s != "" {
+			//unset, a nil slice, is not checked
+			s = fmt.Sprintf("%sif %s != nil {\n", indent, expr) + s + indent + "}\n"
+		}
+	case rdl.BaseTypeStruct, rdl.BaseTypeUnion:
+		if t.Variant == rdl.TypeVariantStructTypeDef || t.Variant == rdl.TypeVariantUnionTypeDef {
+			s = fmt.Sprintf("%sif %s != nil {\n", indent, expr)
+			s += fmt.Sprintf("%s\tif err := %s.Validate(); err != nil {\n", indent, expr)
+			s += fail(indent+"\t\t", "%v", "err")
+			s += indent + "\t}\n"
+			s += indent + "}\n"
+		}
+	case rdl.BaseTypeArray:
+		var minSize, maxSize, size *int32
+		if t.Variant == rdl.TypeVariantArrayTypeDef {
+			at := t.ArrayTypeDef
+			items, size, minSize, maxSize = at.Items, at.Size, at.MinSize, at.MaxSize
+		}
+		s = gen.sizeChecks(expr, size, minSize, maxSize, indent, fail)
+		if items != "" && items != "Any" {
+			i := fmt.Sprintf("i%d", depth)
+			item := fmt.Sprintf("item%d", depth)
+			inner := gen.constraintChecks(item, items, "", "", false, context+"[%d]", append(append([]string{}, args...), i), indent+"\t", depth+1)
+			if inner != "" {
+				s += fmt.Sprintf("%sfor %s, %s := range %s {\n", indent, i, item, expr) + inner + indent + "}\n"
+			}
+		}
+	case rdl.BaseTypeMap:
+		var minSize, maxSize, size *int32
+		if t.Variant == rdl.TypeVariantMapTypeDef {
+			mt := t.MapTypeDef
+			keys, items, size, minSize, maxSize = mt.Keys, mt.Items, mt.Size, mt.MinSize, mt.MaxSize
+		}
+		s = gen.sizeChecks(expr, size, minSize, maxSize, indent, fail)
+		k := fmt.Sprintf("k%d", depth)
+		v := fmt.Sprintf("v%d", depth)
+		elemArgs := append(append([]string{}, args...), k)
+		keyChecks, itemChecks := "", ""
+		if keys != "" && keys != "Any" {
+			keyChecks = gen.constraintChecks(k, keys, "", "", false, context+"[%v]", elemArgs, indent+"\t", depth+1)
+		}
+		if items != "" && items != "Any" {
+			itemChecks = gen.constraintChecks(v, items, "", "", false, context+"[%v]", elemArgs, indent+"\t", depth+1)
+		}
+		if itemChecks == "" {
+			v = "_"
+		}
+		if keyChecks != "" || itemChecks != "" {
+			s += fmt.Sprintf("%sfor %s, %s := range %s {\n", indent, k, v, expr) + keyChecks + itemChecks + indent + "}\n"
+		}
+	}
+	return s
+}
+
+func (gen *modelGenerator) sizeChecks(expr string, size *int32, minSize *int32, maxSize *int32, indent string, fail func(string, string, ...string) string) string {
+	s := ""
+	if size != nil {
+		s += fmt.Sprintf("%sif len(%s) != %d {\n", indent, expr, *size)
+		s += fail(indent+"\t", fmt.Sprintf("size is not %d", *size))
+		s += indent + "}\n"
+	}
+	if minSize != nil {
+		s += fmt.Sprintf("%sif len(%s) < %d {\n", indent, expr, *minSize)
+		s += fail(indent+"\t", fmt.Sprintf("size is smaller than the minimum of %d", *minSize))
+		s += indent + "}\n"
+	}
+	if maxSize != nil {
+		s += fmt.Sprintf("%sif len(%s) > %d {\n", indent, expr, *maxSize)
+		s += fail(indent+"\t", fmt.Sprintf("size is larger than the maximum of %d", *maxSize))
+		s += indent + "}\n"
+	}
+	return s
+}
+
+func indentBlock(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "\t" + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
 func (gen *modelGenerator) emitStructInitializer(st *rdl.StructTypeDef, flattened []*rdl.StructFieldDef) {
 	gen.emit("\n//\n// Init - sets up the instance according to its default field values, if any\n//\n")
 	gen.emit(fmt.Sprintf("func (pTypeDef *%s) Init() *%s {\n", st.Name, st.Name))
 	for _, f := range flattened {
+		if nullableField(f) {
+			continue
+		}
 		fname := capitalize(string(f.Name))
 		isRdl := false
 		ftype := string(f.Type)
@@ -733,15 +1140,15 @@ func (gen *modelGenerator) emitStructInitializer(st *rdl.StructTypeDef, flattene
 			isRdl = true
 			ftype = capitalize(ftype[4:])
 		}
-		if !f.Optional {
+		if !f.Optional || gen.emptyCollection(f) {
 			switch gen.registry.FindBaseType(f.Type) {
 			case rdl.BaseTypeArray:
-				ftype := goType(gen.registry, f.Type, false, f.Items, f.Keys, gen.precise, true)
+				ftype := gen.goType(f.Type, false, f.Items, f.Keys, true)
 				gen.emit(fmt.Sprintf("\tif pTypeDef.%s == nil {\n", fname))
 				gen.emit(fmt.Sprintf("\t\tpTypeDef.%s = make(%s, 0)\n", fname, ftype))
 				gen.emit("\t}\n")
 			case rdl.BaseTypeMap:
-				ftype := goType(gen.registry, f.Type, false, f.Items, f.Keys, gen.precise, true)
+				ftype := gen.goType(f.Type, false, f.Items, f.Keys, true)
 				gen.emit(fmt.Sprintf("\tif pTypeDef.%s == nil {\n", fname))
 				gen.emit(fmt.Sprintf("\t\tpTypeDef.%s = make(%s)\n", fname, ftype))
 				gen.emit("\t}\n")
@@ -771,7 +1178,7 @@ func (gen *modelGenerator) emitStructInitializer(st *rdl.StructTypeDef, flattene
 				ndef = gen.literal(f.Default)
 			case rdl.BaseTypeBool:
 				ndef = gen.literal(f.Default)
-				if !f.Optional {
+				if !gen.fieldPointer(f) {
 					fdef = "false"
 				}
 			case rdl.BaseTypeEnum:
@@ -785,7 +1192,7 @@ func (gen *modelGenerator) emitStructInitializer(st *rdl.StructTypeDef, flattene
 			}
 			if fdef != ndef {
 				//if f.Optional && fdef == "nil" {
-				if f.Optional && pointerForOptional {
+				if gen.fieldPointer(f) && pointerForOptional {
 					gen.emit(fmt.Sprintf("\tif pTypeDef.%s == nil {\n", fname))
 					gen.emit(fmt.Sprintf("\t\td := %s\n", ndef))
 					gen.emit(fmt.Sprintf("\t\tpTypeDef.%s = &d\n", fname))
@@ -801,6 +1208,35 @@ func (gen *modelGenerator) emitStructInitializer(st *rdl.StructTypeDef, flattene
 	gen.emit("}\n")
 }
 
+// checksOnDecode tells if the precise Go type of a string type has a pattern or values to
+// check when decoding it from JSON, so that invalid values are rejected by its UnmarshalJSON.
+func (gen *modelGenerator) checksOnDecode(t *rdl.Type) bool {
+	if !gen.precise || gen.registry.BaseType(t) != rdl.BaseTypeString {
+		return false
+	}
+	tName, _, _ := rdl.TypeInfo(t)
+	if strings.HasPrefix(string(tName), "rdl.") {
+		return false
+	}
+	pattern, values, _, _ := stringConstraints(gen.registry, t)
+	return pattern != "" || values != nil
+}
+
+func (gen *modelGenerator) emitStringUnmarshaller(name rdl.TypeName) {
+	gen.emit(fmt.Sprintf("\n//\n// UnmarshalJSON is defined for proper JSON decoding of a %s, rejecting values that do\n// not satisfy its constraints\n//\n", name))
+	gen.emit(fmt.Sprintf("func (p *%s) UnmarshalJSON(b []byte) error {\n", name))
+	gen.emit("\tvar s string\n")
+	gen.emit("\terr := json.Unmarshal(b, &s)\n")
+	gen.emit("\tif err == nil {\n")
+	gen.emit(fmt.Sprintf("\t\terr = validate%s(s)\n", name))
+	gen.emit("\t}\n")
+	gen.emit("\tif err == nil {\n")
+	gen.emit(fmt.Sprintf("\t\t*p = %s(s)\n", name))
+	gen.emit("\t}\n")
+	gen.emit("\treturn err\n")
+	gen.emit("}\n")
+}
+
 func (gen *modelGenerator) emitStructUnmarshaller(st *rdl.StructTypeDef, init bool) {
 	name := capitalize(string(st.Name))
 	gen.emit(fmt.Sprintf("\ntype raw%s %s\n\n", name, name))
@@ -808,6 +1244,12 @@ func (gen *modelGenerator) emitStructUnmarshaller(st *rdl.StructTypeDef, init bo
 	gen.emit(fmt.Sprintf("func (pTypeDef *%s) UnmarshalJSON(b []byte) error {\n", name))
 	gen.emit(fmt.Sprintf("\tvar r raw%s\n", name))
 	gen.emit("\terr := json.Unmarshal(b, &r)\n")
+	if gen.hasExtraFields(st) {
+		gen.extraJSON = true
+		gen.emit("\tif err == nil {\n")
+		gen.emit(fmt.Sprintf("\t\tr.Extra, err = extraJSONFields(b, %s)\n", gen.extraFieldNames(st)))
+		gen.emit("\t}\n")
+	}
 	gen.emit("\tif err == nil {\n")
 	gen.emit(fmt.Sprintf("\t\to := %s(r)\n", name))
 	if init {
@@ -826,6 +1268,10 @@ func (gen *modelGenerator) emitEnum(t *rdl.Type) {
 		return
 	}
 	et := t.EnumTypeDef
+	if err := checkEnumNames(et); err != nil {
+		gen.err = err
+		return
+	}
 	name := capitalize(string(et.Name))
 	gen.emit(fmt.Sprintf("type %s int\n\n", name))
 	gen.emit(fmt.Sprintf("//\n// %s constants\n//\n", name))
@@ -841,21 +1287,44 @@ func (gen *modelGenerator) emitEnum(t *rdl.Type) {
 		if len(sym) > maxKeyLen {
 			maxKeyLen = len(sym)
 		}
+		gen.emit(goDeprecatedComment(elem.Annotations, "\t"))
 		gen.emit(fmt.Sprintf("\t%s\n", sym))
 	}
 	gen.emit(")\n\n")
 	gen.emit(fmt.Sprintf("var names%s = []string{\n", name))
 	for _, elem := range et.Elements {
-		symName := elem.Symbol
-		sym := string(symName)
+		sym := string(elem.Symbol)
 		if gen.prefixEnums {
 			sym = SnakeToCamel(sym) //go conventions, should do this even without prefixEnums. Test here first.
 			sym = name + sym
 		}
 		s := leftJustified(sym+":", maxKeyLen+1)
-		gen.emit(fmt.Sprintf("\t%s %q,\n", s, symName))
+		gen.emit(fmt.Sprintf("\t%s %q,\n", s, enumWireName(elem)))
 	}
 	gen.emit("}\n\n")
+	aliases := hasEnumReadNames(et)
+	if aliases {
+		gen.emit(fmt.Sprintf("//\n// aliases%s maps the other strings the constants are read from, but not written as\n//\n", name))
+		gen.emit(fmt.Sprintf("var aliases%s = map[string]%s{\n", name, name))
+		maxAliasLen := 0
+		for _, elem := range et.Elements {
+			for _, alias := range enumReadNames(elem) {
+				if n := len(fmt.Sprintf("%q", alias)); n > maxAliasLen {
+					maxAliasLen = n
+				}
+			}
+		}
+		for _, elem := range et.Elements {
+			sym := string(elem.Symbol)
+			if gen.prefixEnums {
+				sym = name + SnakeToCamel(sym)
+			}
+			for _, alias := range enumReadNames(elem) {
+				gen.emit(fmt.Sprintf("\t%s %s,\n", leftJustified(fmt.Sprintf("%q:", alias), maxAliasLen+1), sym))
+			}
+		}
+		gen.emit("}\n\n")
+	}
 	gen.emit(fmt.Sprintf("//\n// New%s - return a string representation of the enum\n//\n", name))
 	gen.emit(fmt.Sprintf("func New%s(init ...interface{}) %s {\n", name, name))
 	gen.emit("\tif len(init) == 1 {\n")
@@ -872,6 +1341,11 @@ func (gen *modelGenerator) emitEnum(t *rdl.Type) {
 	gen.emit(fmt.Sprintf("\t\t\t\t\treturn %s(i)\n", name))
 	gen.emit("\t\t\t\t}\n")
 	gen.emit("\t\t\t}\n")
+	if aliases {
+		gen.emit(fmt.Sprintf("\t\t\tif e, ok := aliases%s[v]; ok {\n", name))
+		gen.emit("\t\t\t\treturn e\n")
+		gen.emit("\t\t\t}\n")
+	}
 	gen.emit("\t\tdefault:\n")
 	gen.emit(fmt.Sprintf("\t\t\tpanic(\"Bad init value for %s enum\")\n", name))
 	gen.emit("\t\t}\n")
@@ -902,13 +1376,19 @@ func (gen *modelGenerator) emitEnum(t *rdl.Type) {
 	gen.emit("\t\t\t\treturn nil\n")
 	gen.emit("\t\t\t}\n")
 	gen.emit("\t\t}\n")
+	if aliases {
+		gen.emit(fmt.Sprintf("\t\tif v, ok := aliases%s[s]; ok {\n", name))
+		gen.emit("\t\t\t*e = v\n")
+		gen.emit("\t\t\treturn nil\n")
+		gen.emit("\t\t}\n")
+	}
 	gen.emit(fmt.Sprintf("\t\terr = fmt.Errorf(\"Bad enum symbol for type %s: %%s\", s)\n", name))
 	gen.emit("\t}\n")
 	gen.emit("\treturn err\n")
 	gen.emit("}\n")
 }
 
-func (gen *modelGenerator) emitStructFields(fields []*rdl.StructFieldDef, name rdl.TypeName, comment string) {
+func (gen *modelGenerator) emitStructFields(fields []*rdl.StructFieldDef, name rdl.TypeName, comment string, extra bool) {
 	gen.emit(fmt.Sprintf("type %s struct {\n", name))
 	if fields != nil {
 		fnames := make([]string, 0, len(fields))
@@ -923,14 +1403,13 @@ func (gen *modelGenerator) emitStructFields(fields []*rdl.StructFieldDef, name r
 			if flen > nameWidth {
 				nameWidth = flen
 			}
-			optional := f.Optional
-			ftype := goType(gen.registry, f.Type, optional, f.Items, f.Keys, gen.precise, true)
+			ftype := gen.fieldType(f)
 			ftypes = append(ftypes, ftype)
 			tlen := len(ftype)
 			if tlen > typeWidth {
 				typeWidth = tlen
 			}
-			if f.Comment != "" {
+			if _, ok := deprecation(f.Annotations); ok || f.Comment != "" {
 				hasComment = true
 			}
 		}
@@ -948,25 +1427,32 @@ func (gen *modelGenerator) emitStructFields(fields []*rdl.StructFieldDef, name r
 			option := ""
 			optional := ""
 			if f.Optional {
-				option = ",omitempty"
 				optional = " rdl:\"optional\""
 			} else if f.Default != nil {
 				defaultVal := fmt.Sprintf("%v", f.Default)
 				optional = fmt.Sprintf(" rdl:\"default=%s\"", defaultVal)
-				//omit empty only if the default value is the same as the zero value
-				v := reflect.ValueOf(f.Default)
-				if v.Interface() == reflect.Zero(v.Type()).Interface() {
-					//if f.Default.IsZero() {
-					option = ",omitempty"
-				}
 			}
-			fanno := "`json:\"" + string(f.Name) + option + "\"" + optional + "`"
+			if r := gen.fieldRepresentation(f); r.omitempty {
+				option = ",omitempty"
+			} else if r.omitzero || nullableField(f) && f.Optional {
+				option = ",omitzero"
+			}
+			fanno := "`json:\"" + string(f.Name) + option + "\"" + gen.codecTags(string(f.Name), option) + optional + "`"
 			if f.Comment != "" {
 				gen.emit("\n" + formatBlock(f.Comment, 0, 72, "\t// "))
 			}
+			if deprecated := goDeprecatedComment(f.Annotations, "\t"); deprecated != "" {
+				if f.Comment == "" {
+					gen.emit("\n")
+				}
+				gen.emit(deprecated)
+			}
 			gen.emit(fmt.Sprintf("\t%s%s%s\n", fname, ftype, fanno))
 			i++
 		}
+		if extra {
+			gen.emitExtraField()
+		}
 		gen.emit("}\n")
 	}
 }
@@ -91,7 +91,7 @@ func safeTypeVarName(rtype rdl.TypeRef) rdl.TypeName {
 
 func (gen *schemaGenerator) emitResource(rez *rdl.Resource) {
 	rTypeName := rez.Type
-	if rez.Method == "PUT" || rez.Method == "POST" {
+	if rez.Method == "PUT" || rez.Method == "POST" || rez.Method == "PATCH" {
 		for _, ri := range rez.Inputs {
 			if !ri.PathParam && ri.QueryParam == "" && ri.Header == "" {
 				rTypeName = ri.Type
@@ -0,0 +1,103 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// With -x requests=true, the methods of the go-server handler interface take a request struct
+// and return a response struct, instead of a parameter for each input and a result for each
+// output header, e.g. GetContact(context, *GetContactRequest) (*GetContactResponse, error).
+// The request holds the path and query parameters, headers, and body of the resource, and the
+// response its data and the headers to respond with, so that adding an input or output to the
+// resource does not change the signature every implementation has to match.
+//
+
+func goRequestTypeNames(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) (string, string) {
+	methName, _ := goMethodName(reg, r, precise)
+	name := capitalize(methName)
+	return name + "Request", name + "Response"
+}
+
+func goRequestMethodSignature(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
+	methName, _ := goMethodName(reg, r, precise)
+	request, response := goRequestTypeNames(reg, r, precise)
+	return capitalize(methName) + "(context *rdl.ResourceContext, request *" + request + ") (*" + response + ", error)"
+}
+
+// goRequestTypes returns the declarations of the request and response structs of the resource.
+func goRequestTypes(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
+	methName, _ := goMethodName(reg, r, precise)
+	request, response := goRequestTypeNames(reg, r, precise)
+	var inputs [][]string
+	for _, in := range r.Inputs {
+		if in.Context != "" { //legacy field, to be removed
+			continue
+		}
+		inputs = append(inputs, []string{capitalize(string(in.Name)), goServerInputType(reg, r, in, precise)})
+	}
+	var outputs [][]string
+	stream := streamsEvents(r)
+	if stream {
+		outputs = append(outputs, []string{"Data", "<-chan " + goType(reg, r.Type, false, "", "", precise, true)})
+	} else {
+		if streamingResponseType(r) != "" {
+			outputs = append(outputs, []string{"Data", "io.ReadCloser"})
+		} else if r.Expected != "NO_CONTENT" || len(r.Alternatives) > 0 {
+			outputs = append(outputs, []string{"Data", goType(reg, r.Type, false, "", "", precise, true)})
+		}
+		for _, out := range r.Outputs {
+			outputs = append(outputs, []string{capitalize(string(out.Name)), goType(reg, out.Type, false, "", "", precise, true)})
+		}
+	}
+	s := "//\n// " + request + " holds the path and query parameters, headers, and body of " + capitalize(methName) + ".\n//\n"
+	s += "type " + request + " struct {\n" + goAlignedFields(inputs) + "}\n\n"
+	s += "//\n// " + response + " holds the data " + capitalize(methName) + " responds with, and its headers.\n//\n"
+	s += "type " + response + " struct {\n" + goAlignedFields(outputs) + "}\n"
+	return s
+}
+
+func goAlignedFields(fields [][]string) string {
+	width := 0
+	for _, f := range fields {
+		if len(f[0]) > width {
+			width = len(f[0])
+		}
+	}
+	s := ""
+	for _, f := range fields {
+		s += fmt.Sprintf("\t%-*s %s\n", width, f[0], f[1])
+	}
+	return s
+}
+
+// goRequestCall returns the call of the handler method from the adaptor, which unpacks the
+// response into the variables the rest of the adaptor method writes the response from.
+func goRequestCall(r *rdl.Resource, methName string, fields []string, noContent bool) string {
+	var names, values []string
+	if !noContent {
+		names = append(names, "data")
+		values = append(values, "result.Data")
+	}
+	if !streamsEvents(r) {
+		for _, out := range r.Outputs {
+			names = append(names, string(out.Name))
+			values = append(values, "result."+capitalize(string(out.Name)))
+		}
+	}
+	request := "&" + methName + "Request{" + strings.Join(fields, ", ") + "}"
+	if len(names) == 0 {
+		return "\t_, err := adaptor.impl." + methName + "(context, " + request + ")\n"
+	}
+	s := "\tresult, err := adaptor.impl." + methName + "(context, " + request + ")\n"
+	s += "\tif result == nil {\n"
+	s += "\t\tresult = &" + methName + "Response{}\n"
+	s += "\t}\n"
+	s += "\t" + strings.Join(names, ", ") + " := " + strings.Join(values, ", ") + "\n"
+	return s
+}
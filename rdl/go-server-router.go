@@ -0,0 +1,154 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+)
+
+//
+// The go-server registers the handlers of its resources on a Router, an interface of the
+// generated code with an adapter for the router chosen with -x router=httptreemux|stdlib|chi|
+// gorilla (httptreemux by default). The paths are those of the schema, e.g. /contacts/{name},
+// and the handlers get the values of their path parameters in a map, whatever the router.
+// Setting the Router of the options to an adapter of an existing router, e.g.
+// ChiRouter{r}, serves the resources along with the other routes of the application.
+//
+
+// goServerRouters are the imports of the routers go-server has an adapter for.
+var goServerRouters = map[string]string{
+	"httptreemux": HttpTreeMuxGoImport,
+	"stdlib":      "",
+	"chi":         "github.com/go-chi/chi/v5",
+	"gorilla":     "github.com/gorilla/mux",
+}
+
+// goServerRouter returns the router of the -x router option, or an error if it has no adapter.
+func goServerRouter(options []string) (string, error) {
+	router := goGenerationStringOptionSet(options, "router")
+	if router == "" {
+		return "httptreemux", nil
+	}
+	if _, ok := goServerRouters[router]; !ok {
+		return "", fmt.Errorf("Unsupported router option '%s' (expected httptreemux, stdlib, chi, or gorilla)", router)
+	}
+	return router, nil
+}
+
+// goServerRouterAdapter returns the declaration of the Router adapter of the router.
+func goServerRouterAdapter(router string) string {
+	switch router {
+	case "stdlib":
+		return serveMuxRouterTemplate
+	case "chi":
+		return chiRouterTemplate
+	case "gorilla":
+		return gorillaRouterTemplate
+	}
+	return treeMuxRouterTemplate
+}
+
+const treeMuxRouterTemplate = `//
+// TreeMuxRouter adapts an httptreemux.TreeMux to Router.
+//
+type TreeMuxRouter struct {
+	*httptreemux.TreeMux
+}
+
+func newRouter() Router {
+	return TreeMuxRouter{httptreemux.New()}
+}
+
+func (router TreeMuxRouter) Handle(method string, path string, handler func(http.ResponseWriter, *http.Request, map[string]string)) {
+	path = strings.Replace(strings.Replace(path, "{", ":", -1), "}", "", -1)
+	router.TreeMux.Handle(method, path, handler)
+}
+
+func (router TreeMuxRouter) NotFound(handler http.HandlerFunc) {
+	router.TreeMux.NotFoundHandler = handler
+}
+`
+
+const serveMuxRouterTemplate = `//
+// ServeMuxRouter adapts an http.ServeMux to Router, with the method and wildcard
+// patterns of Go 1.22, which the go directive of the module must enable.
+//
+type ServeMuxRouter struct {
+	*http.ServeMux
+}
+
+func newRouter() Router {
+	return ServeMuxRouter{http.NewServeMux()}
+}
+
+func (router ServeMuxRouter) Handle(method string, path string, handler func(http.ResponseWriter, *http.Request, map[string]string)) {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, segment[1:len(segment)-1])
+		}
+	}
+	router.ServeMux.HandleFunc(method+" "+path, func(w http.ResponseWriter, r *http.Request) {
+		ps := make(map[string]string, len(names))
+		for _, name := range names {
+			ps[name] = r.PathValue(name)
+		}
+		handler(w, r, ps)
+	})
+}
+
+func (router ServeMuxRouter) NotFound(handler http.HandlerFunc) {
+	router.ServeMux.HandleFunc("/", handler)
+}
+`
+
+const chiRouterTemplate = `//
+// ChiRouter adapts a chi.Router to Router.
+//
+type ChiRouter struct {
+	chi.Router
+}
+
+func newRouter() Router {
+	return ChiRouter{chi.NewRouter()}
+}
+
+func (router ChiRouter) Handle(method string, path string, handler func(http.ResponseWriter, *http.Request, map[string]string)) {
+	router.Router.MethodFunc(method, path, func(w http.ResponseWriter, r *http.Request) {
+		ps := make(map[string]string)
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			for i, key := range rctx.URLParams.Keys {
+				ps[key] = rctx.URLParams.Values[i]
+			}
+		}
+		handler(w, r, ps)
+	})
+}
+
+func (router ChiRouter) NotFound(handler http.HandlerFunc) {
+	router.Router.NotFound(handler)
+}
+`
+
+const gorillaRouterTemplate = `//
+// GorillaRouter adapts a gorilla/mux Router to Router.
+//
+type GorillaRouter struct {
+	*mux.Router
+}
+
+func newRouter() Router {
+	return GorillaRouter{mux.NewRouter()}
+}
+
+func (router GorillaRouter) Handle(method string, path string, handler func(http.ResponseWriter, *http.Request, map[string]string)) {
+	router.Router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, mux.Vars(r))
+	}).Methods(method)
+}
+
+func (router GorillaRouter) NotFound(handler http.HandlerFunc) {
+	router.Router.NotFoundHandler = handler
+}
+`
@@ -24,10 +24,15 @@ type serverGenerator struct {
 	precise     bool
 	ns          string
 	librdl      string
+	otel        bool
+	lifecycle   bool
+	requests    bool
+	router      string
+	metrics     bool
 }
 
 // GenerateGoServer generates the server code for the RDL-defined service
-func GenerateGoServer(banner string, schema *rdl.Schema, outdir string, ns string, librdl string, prefixEnums bool, precise bool) error {
+func GenerateGoServer(banner string, schema *rdl.Schema, outdir string, ns string, librdl string, prefixEnums bool, precise bool, options []string) error {
 	name := strings.ToLower(string(schema.Name))
 	if strings.HasSuffix(outdir, ".go") {
 		name = filepath.Base(outdir)
@@ -42,8 +47,12 @@ func GenerateGoServer(banner string, schema *rdl.Schema, outdir string, ns strin
 	if file != nil {
 		defer file.Close()
 	}
+	router, err := goServerRouter(options)
+	if err != nil {
+		return err
+	}
 	reg := rdl.NewTypeRegistry(schema)
-	gen := &serverGenerator{reg, schema, capitalize(string(schema.Name)), out, nil, banner, prefixEnums, precise, ns, librdl}
+	gen := &serverGenerator{reg, schema, capitalize(string(schema.Name)), out, nil, banner, prefixEnums, precise, ns, librdl, goGenerationBoolOptionSet(options, "otel"), goGenerationBoolOptionSet(options, "lifecycle"), goGenerationBoolOptionSet(options, "requests"), router, goGenerationBoolOptionSet(options, "metrics")}
 	gen.processTemplate(serverTemplate)
 	out.Flush()
 	return gen.err
@@ -53,26 +62,112 @@ const serverTemplate = `{{header}}
 
 package {{package}}
 
-import (
+import ({{if lifecycle}}
+	"context"{{end}}{{if etags}}
+	"crypto/sha256"
+	"encoding/hex"{{end}}
 	"encoding/json"
-	"fmt"
-	"{{httptreemux}}"
-	rdl "{{rdlruntime}}"
+	"fmt"{{if routerImport}}
+	"{{routerImport}}"{{end}}{{if metrics}}
+	"github.com/prometheus/client_golang/prometheus"{{end}}
+	rdl "{{rdlruntime}}"{{if streamsIO}}
+	"io"{{end}}
 	"io/ioutil"
-	"log"
+	"log"{{if multipart}}
+	"mime/multipart"{{end}}
 	"net/http"
-	"net/url"
-	"strings"
+	"net/url"{{if lifecycle}}
+	"os"
+	"os/signal"{{end}}{{if or metrics headerParser}}
+	"strconv"{{end}}
+	"strings"{{if lifecycle}}
+	"sync/atomic"
+	"syscall"{{end}}{{if or lifecycle metrics}}
+	"time"{{end}}{{if otel}}
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"{{end}}
 )
 
 var _ = json.Marshal
 var _ = ioutil.Discard
 
+//
+// Middleware wraps the http.Handler serving the {{name}} resources, to add
+// behavior such as authentication, metrics or request logging around it.
+//
+type Middleware func(http.Handler) http.Handler
+
+//
+// Chain composes the given middleware into one. The first middleware is the
+// outermost, i.e. it sees the request first and the response last.
+//
+func Chain(middleware ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			h = middleware[i](h)
+		}
+		return h
+	}
+}
+
+//
+// Interceptor is called before the handler of a resource method runs, with the
+// name of that method as it appears in {{cName}}Handler. If it returns false, the
+// request is not dispatched, and the interceptor must have written the response.
+//
+type Interceptor func(method string, context *rdl.ResourceContext) bool
+
+//
+// Router registers the handlers of the {{name}} resources. The paths are those
+// of the schema, e.g. /contacts/{name}, and the handlers are passed the values
+// of their path parameters by name.
+//
+type Router interface {
+	http.Handler
+	Handle(method string, path string, handler func(http.ResponseWriter, *http.Request, map[string]string))
+	NotFound(handler http.HandlerFunc)
+}
+
+{{routerAdapter}}
+//
+// {{cName}}Options holds the optional hooks for the {{name}} server.
+// Interceptors are keyed by method name, the key "*" applies to all methods.
+//
+type {{cName}}Options struct {
+	Middleware   []Middleware
+	Interceptors map[string][]Interceptor
+
+	// Router, if set, is the router the resources are registered on, e.g. an
+	// existing one of the application, instead of a new one. Its handling of the
+	// requests for no resource is left alone.
+	Router Router{{if metrics}}
+
+	// Metrics, if set, is the Prometheus registry the metrics of the requests
+	// of the resources are registered with.
+	Metrics prometheus.Registerer{{end}}{{if lifecycle}}
+
+	// Ready, if set, gates readiness: /readyz fails while it returns false,
+	// e.g. until the connections to the backends of the service are up.
+	Ready func() bool{{end}}
+}
+
 //
 // Init initializes the {{name}} server with a service identity and an
 // implementation ({{cName}}Handler), and returns an http.Handler to serve it.
 //
 func Init(impl {{cName}}Handler, baseURL string, authz rdl.Authorizer, authns ...rdl.Authenticator) http.Handler {
+	return InitWithOptions(impl, baseURL, authz, nil, authns...)
+}
+
+//
+// InitWithOptions is like Init, but also installs the middleware and
+// interceptors from the options.
+//
+func InitWithOptions(impl {{cName}}Handler, baseURL string, authz rdl.Authorizer, options *{{cName}}Options, authns ...rdl.Authenticator) http.Handler {
 	for strings.HasSuffix(baseURL, "/") {
 		baseURL = baseURL[0 : len(baseURL)-1]
 	}
@@ -81,27 +176,216 @@ func Init(impl {{cName}}Handler, baseURL string, authz rdl.Authorizer, authns ..
 		log.Fatal(err)
 	}
 	b := u.Path
-	router := httptreemux.New()
-	adaptor := {{name}}Adaptor{impl, authz, authns, b}
-{{range .Resources}}
-	router.{{uMethod .}}(b+"{{methodPath .}}", func(w http.ResponseWriter, r *http.Request, ps map[string]string) {
-		adaptor.{{handlerName .}}(w, r, ps)
-	}){{end}}
-	router.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
-		rdl.JSONResponse(w, 404, rdl.ResourceError{Code: http.StatusNotFound, Message: "Not Found"})
-	}
-	log.Printf("Initialized {{name}} service at '%s'\n", baseURL)
+	var router Router
+	if options != nil && options.Router != nil {
+		router = options.Router
+	} else {
+		router = newRouter()
+		router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			rdl.JSONResponse(w, 404, rdl.ResourceError{Code: http.StatusNotFound, Message: "Not Found"})
+		})
+	}
+	adaptor := {{name}}Adaptor{impl, authz, authns, b, nil}
+	if options != nil {
+		adaptor.interceptors = options.Interceptors
+	}
+{{if metrics}}	var metrics *serverMetrics
+	if options != nil && options.Metrics != nil {
+		metrics, err = newServerMetrics(options.Metrics)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+{{end}}{{range .Resources}}
+	router.Handle("{{uMethod .}}", b+"{{routePath .}}", func(w http.ResponseWriter, r *http.Request, ps map[string]string) {
+{{dispatch .}}	}){{end}}
+{{if lifecycle}}	var ready func() bool
+	if options != nil {
+		ready = options.Ready
+	}
+	router.Handle("GET", "/healthz", func(w http.ResponseWriter, r *http.Request, ps map[string]string) {
+		rdl.JSONResponse(w, 200, map[string]string{"status": "ok"})
+	})
+	router.Handle("GET", "/readyz", func(w http.ResponseWriter, r *http.Request, ps map[string]string) {
+		if atomic.LoadInt32(&shuttingDown) != 0 || (ready != nil && !ready()) {
+			rdl.JSONResponse(w, 503, rdl.ResourceError{Code: http.StatusServiceUnavailable, Message: "Not Ready"})
+			return
+		}
+		rdl.JSONResponse(w, 200, map[string]string{"status": "ready"})
+	})
+{{end}}	log.Printf("Initialized {{name}} service at '%s'\n", baseURL)
+	if options != nil && len(options.Middleware) > 0 {
+		return Chain(options.Middleware...)(router)
+	}
 	return router
 }
 
+{{if lifecycle}}//
+// shuttingDown is set once Serve is told to stop. From then on /readyz fails,
+// so that load balancers stop sending requests while those in flight complete.
+//
+var shuttingDown int32
+
+//
+// Serve serves the handler returned by Init (or InitWithOptions) on the address,
+// until the process gets SIGTERM or SIGINT. It then fails /readyz, stops accepting
+// connections, and waits up to the timeout for the requests in flight to complete.
+//
+func Serve(addr string, handler http.Handler, timeout time.Duration) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(stop)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-stop:
+		log.Printf("Shutting down {{name}} service (%v)\n", sig)
+	}
+	atomic.StoreInt32(&shuttingDown, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+{{end}}{{if or otel metrics}}//
+// statusRecorder remembers the status code written by a handler.
+//
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+{{if streams}}
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+{{end}}
+{{end}}{{if metrics}}//
+// serverMetrics are the Prometheus metrics of the requests of the {{name}} resources,
+// labeled with the name of their method in {{cName}}Handler, and the status code of
+// the response.
+//
+type serverMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+//
+// newServerMetrics registers the metrics of the server with the registry, or uses
+// those already registered with it, e.g. by another server of the process.
+//
+func newServerMetrics(reg prometheus.Registerer) (*serverMetrics, error) {
+	service := prometheus.Labels{"service": "{{name}}"}
+	requests, err := registerServerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "rdl_server_requests_total",
+		Help:        "The requests of the resources, by resource and status code.",
+		ConstLabels: service,
+	}, []string{"resource", "code"}))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := registerServerCollector(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "rdl_server_request_duration_seconds",
+		Help:        "The time taken to respond to the requests of the resources, by resource and status code.",
+		ConstLabels: service,
+		Buckets:     prometheus.DefBuckets,
+	}, []string{"resource", "code"}))
+	if err != nil {
+		return nil, err
+	}
+	inFlight, err := registerServerCollector(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "rdl_server_requests_in_flight",
+		Help:        "The requests of the resources being handled, by resource.",
+		ConstLabels: service,
+	}, []string{"resource"}))
+	if err != nil {
+		return nil, err
+	}
+	return &serverMetrics{requests.(*prometheus.CounterVec), duration.(*prometheus.HistogramVec), inFlight.(*prometheus.GaugeVec)}, nil
+}
+
+func registerServerCollector(reg prometheus.Registerer, collector prometheus.Collector) (prometheus.Collector, error) {
+	if err := reg.Register(collector); err != nil {
+		if already, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return already.ExistingCollector, nil
+		}
+		return nil, err
+	}
+	return collector, nil
+}
+
 //
+// measure runs the handler of a resource, counting and timing its request. It just
+// runs it if there are no metrics.
+//
+func (metrics *serverMetrics) measure(name string, w http.ResponseWriter, r *http.Request, handler func(http.ResponseWriter, *http.Request)) {
+	if metrics == nil {
+		handler(w, r)
+		return
+	}
+	inFlight := metrics.inFlight.WithLabelValues(name)
+	inFlight.Inc()
+	defer inFlight.Dec()
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	handler(rec, r)
+	code := strconv.Itoa(rec.status)
+	metrics.requests.WithLabelValues(name, code).Inc()
+	metrics.duration.WithLabelValues(name, code).Observe(time.Since(start).Seconds())
+}
+
+{{end}}{{if otel}}//
+// tracer creates the spans of the {{name}} server, from the global TracerProvider.
+//
+var tracer = otel.Tracer("{{name}}")
+
+//
+// propagator extracts the trace context of incoming requests, i.e. the W3C
+// traceparent and tracestate headers, and baggage.
+//
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+//
+// traced runs the handler of a resource in a server span named after the resource
+// method, continuing the trace of the caller. The span is available to the handler
+// from the context of the request, i.e. context.Request.Context().
+//
+func traced(name string, route string, w http.ResponseWriter, r *http.Request, handler func(http.ResponseWriter, *http.Request)) {
+	ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		attribute.String("http.request.method", r.Method),
+		attribute.String("http.route", route),
+	))
+	defer span.End()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	handler(rec, r.WithContext(ctx))
+	span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+	if rec.status >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(rec.status))
+	}
+}
+
+{{end}}//
 // {{cName}}Handler is the interface that the service implementation must conform to
 //
-type {{cName}}Handler interface {{openBrace}}{{range .Resources}}
+type {{cName}}Handler interface {{openBrace}}{{range .Resources}}{{deprecated .}}
 	{{methodSig .}}{{end}}
 	Authenticate(context *rdl.ResourceContext) bool
 }
-
+{{if requests}}{{range .Resources}}
+{{requestTypes .}}{{end}}{{end}}
 //
 // {{name}}Adaptor - this adapts the http-oriented router calls to the non-http service handler.
 //
@@ -110,6 +394,21 @@ type {{name}}Adaptor struct {
 	authorizer     rdl.Authorizer
 	authenticators []rdl.Authenticator
 	endpoint       string
+	interceptors   map[string][]Interceptor
+}
+
+func (adaptor {{name}}Adaptor) intercept(method string, context *rdl.ResourceContext) bool {
+	for _, interceptor := range adaptor.interceptors["*"] {
+		if !interceptor(method, context) {
+			return false
+		}
+	}
+	for _, interceptor := range adaptor.interceptors[method] {
+		if !interceptor(method, context) {
+			return false
+		}
+	}
+	return true
 }
 
 func (adaptor {{name}}Adaptor) authenticate(context *rdl.ResourceContext) bool {
@@ -174,12 +473,30 @@ func floatFromString(s string) float64 {
 	_, _ = fmt.Sscanf(s, "%g", &n)
 	return n
 }
-{{range .Resources}}
+{{if streams}}
+//
+// writeEvent writes the item as the data of a server-sent event, and flushes it to the client.
+//
+func writeEvent(writer http.ResponseWriter, item interface{}) error {
+	j, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "data: %s\n\n", j)
+	if f, ok := writer.(http.Flusher); ok {
+		f.Flush()
+	}
+	return err
+}
+{{end}}{{range .Resources}}
 func (adaptor {{name}}Adaptor) {{handlerSig .}} {
 	context := &rdl.ResourceContext{Writer: writer, Request: request, Params: params, Principal: nil}
+	if !adaptor.intercept("{{cMethodName .}}", context) {
+		return
+	}
 {{handlerBody .}}
 }
-{{end}}`
+{{end}}{{headerParser}}{{if etags}}{{etagHelpers}}{{end}}`
 
 func makeTypeRef(reg rdl.TypeRegistry, t *rdl.Type, precise bool) string {
 	switch t.Variant {
@@ -233,25 +550,55 @@ func (gen *serverGenerator) processTemplate(templateSource string) error {
 		return fmt.Sprintf("%s %s%s", fName, fType, fAnno)
 	}
 	funcMap := template.FuncMap{
-		"httptreemux": func() string { return HttpTreeMuxGoImport },
-		"rdlruntime":  func() string { return gen.librdl },
-		"header":      func() string { return generationHeader(gen.banner) },
-		"package":     func() string { return generationPackage(gen.schema, gen.ns) },
-		"openBrace":   func() string { return "{" },
-		"field":       fieldFun,
-		"flattened":   func(t *rdl.Type) []*rdl.StructFieldDef { return flattenedFields(gen.registry, t) },
-		"typeRef":     func(t *rdl.Type) string { return makeTypeRef(gen.registry, t, gen.precise) },
-		"basename":    basenameFunc,
-		"comment":     commentFun,
-		"uMethod":     func(r *rdl.Resource) string { return strings.ToUpper(r.Method) },
-		"methodSig":   func(r *rdl.Resource) string { return goServerMethodSignature(gen.registry, r, gen.precise) },
+		"routerImport":  func() string { return goServerRouters[gen.router] },
+		"routerAdapter": func() string { return goServerRouterAdapter(gen.router) },
+		"otel":          func() bool { return gen.otel },
+		"metrics":       func() bool { return gen.metrics },
+		"dispatch":      func(r *rdl.Resource) string { return gen.dispatch(r) },
+		"lifecycle":     func() bool { return gen.lifecycle },
+		"streams":       func() bool { return anyStreamsEvents(gen.schema) },
+		"streamsIO":     func() bool { return anyUploads(gen.schema, false) || anyStreamingResponses(gen.schema) },
+		"multipart":     func() bool { return anyUploads(gen.schema, true) },
+		"routePath":     func(r *rdl.Resource) string { return strings.SplitN(r.Path, "?", 2)[0] },
+		"rdlruntime":    func() string { return gen.librdl },
+		"header":        func() string { return generationHeader(gen.banner) },
+		"package":       func() string { return generationPackage(gen.schema, gen.ns) },
+		"openBrace":     func() string { return "{" },
+		"field":         fieldFun,
+		"flattened":     func(t *rdl.Type) []*rdl.StructFieldDef { return flattenedFields(gen.registry, t) },
+		"typeRef":       func(t *rdl.Type) string { return makeTypeRef(gen.registry, t, gen.precise) },
+		"basename":      basenameFunc,
+		"comment":       commentFun,
+		"uMethod":       func(r *rdl.Resource) string { return strings.ToUpper(r.Method) },
+		"requests":      func() bool { return gen.requests },
+		"etags":         func() bool { return anyETagged(gen.schema) },
+		"etagHelpers":   func() string { return goServerETagTemplate },
+		"headerParser": func() string {
+			if anyParsedHeaders(gen.registry, gen.schema, gen.precise, false) {
+				return goHeaderParser("parseHeaderParam")
+			}
+			return ""
+		},
+		"deprecated": func(r *rdl.Resource) string {
+			if comment := goDeprecatedComment(r.Annotations, "\t"); comment != "" {
+				return "\n" + strings.TrimSuffix(comment, "\n")
+			}
+			return ""
+		},
+		"methodSig": func(r *rdl.Resource) string {
+			if gen.requests {
+				return goRequestMethodSignature(gen.registry, r, gen.precise)
+			}
+			return goServerMethodSignature(gen.registry, r, gen.precise)
+		},
+		"requestTypes": func(r *rdl.Resource) string { return goRequestTypes(gen.registry, r, gen.precise) },
 		"handlerName": func(r *rdl.Resource) string {
 			n, _ := goMethodName(gen.registry, r, gen.precise)
 			return uncapitalize(n) + "Handler"
 		},
 		"handlerSig": func(r *rdl.Resource) string { return goHandlerSignature(gen.registry, r, gen.precise) },
 		"handlerBody": func(r *rdl.Resource) string {
-			return goHandlerBody(gen.registry, gen.name, r, gen.precise, gen.prefixEnums)
+			return goHandlerBody(gen.registry, gen.name, r, gen.precise, gen.prefixEnums, gen.requests)
 		},
 		"client":     func() string { return gen.name + "Client" },
 		"server":     func() string { return gen.name + "Server" },
@@ -259,6 +606,10 @@ func (gen *serverGenerator) processTemplate(templateSource string) error {
 		"cName":      func() string { return capitalize(gen.name) },
 		"methodName": func(r *rdl.Resource) string { n, _ := goMethodName(gen.registry, r, gen.precise); return n },
 		"methodPath": func(r *rdl.Resource) string { return resourcePath(r) },
+		"cMethodName": func(r *rdl.Resource) string {
+			n, _ := goMethodName(gen.registry, r, gen.precise)
+			return capitalize(n)
+		},
 	}
 	t := template.Must(template.New(gen.name).Funcs(funcMap).Parse(templateSource))
 	return t.Execute(gen.writer, gen.schema)
@@ -294,9 +645,10 @@ const authorizeTemplate = `	if !adaptor.authorize(context, %q, %s) {
 	}
 `
 
-func goHandlerBody(reg rdl.TypeRegistry, name string, r *rdl.Resource, precise bool, prefixEnums bool) string {
+func goHandlerBody(reg rdl.TypeRegistry, schemaName string, r *rdl.Resource, precise bool, prefixEnums bool, requests bool) string {
 	s := ""
 	var fargs []string
+	var fields []string //the fields of the request struct, with requests
 	bodyName := ""
 	for _, in := range r.Inputs {
 		name := "arg" + capitalize(string(in.Name))
@@ -308,6 +660,7 @@ func goHandlerBody(reg rdl.TypeRegistry, name string, r *rdl.Resource, precise b
 				log.Printf("RDL error: queryparam '%s' must either be optional or have a default value\n", in.Name)
 			}
 			fargs = append(fargs, name)
+			fields = append(fields, capitalize(string(in.Name))+": "+name)
 		} else if in.PathParam {
 			bt := reg.BaseTypeName(in.Type)
 			switch bt {
@@ -333,24 +686,16 @@ func goHandlerBody(reg rdl.TypeRegistry, name string, r *rdl.Resource, precise b
 				}
 			}
 			fargs = append(fargs, name)
+			fields = append(fields, capitalize(string(in.Name))+": "+name)
 		} else if in.Header != "" {
-			hname := in.Header
-			def := ""
-			if in.Default != nil {
-				switch v := in.Default.(type) {
-				case string:
-					def = fmt.Sprintf("%q", v)
-				default:
-					panic(fmt.Sprintf("implement me, default value: %v", in))
-				}
-				s += "\t" + name + "Optional := " + def + "\n"
-				s += fmt.Sprintf("\t%s := rdl.HeaderParam(request, %q, %sOptional)\n", name, hname, name)
-			} else if in.Optional {
-				s += fmt.Sprintf("\t%s := rdl.OptionalHeaderParam(request, %q)\n", name, hname)
-			} else {
-				s += fmt.Sprintf("\t%s := rdl.HeaderParam(request, %q, \"\")\n", name, hname)
-			}
+			s += goHeaderInput(reg, schemaName, in, name, precise)
 			fargs = append(fargs, name)
+			fields = append(fields, capitalize(string(in.Name))+": "+name)
+		} else if isUploadBody(r, in) {
+			bodyName = name
+			s += goUploadHandlerInput(r, bodyName)
+			fargs = append(fargs, bodyName)
+			fields = append(fields, capitalize(string(in.Name))+": "+bodyName)
 		} else {
 			bodyName = name
 			s += "\tbody, oserr := ioutil.ReadAll(request.Body)\n"
@@ -366,6 +711,7 @@ func goHandlerBody(reg rdl.TypeRegistry, name string, r *rdl.Resource, precise b
 			s += "\t\treturn\n"
 			s += "\t}\n"
 			fargs = append(fargs, bodyName)
+			fields = append(fields, capitalize(string(in.Name))+": "+bodyName)
 		}
 	}
 	if r.Auth != nil {
@@ -403,12 +749,18 @@ func goHandlerBody(reg rdl.TypeRegistry, name string, r *rdl.Resource, precise b
 	if len(fargs) > 0 {
 		sargs = ", " + strings.Join(fargs, ", ")
 	}
+	stream := streamsEvents(r)
 	outHeaders := ""
-	for _, v := range r.Outputs {
-		outHeaders += ", " + string(v.Name)
+	if !stream {
+		for _, v := range r.Outputs {
+			outHeaders += ", " + string(v.Name)
+		}
 	}
-	noContent := r.Expected == "NO_CONTENT" && len(r.Alternatives) == 0
-	if noContent {
+	download := streamingResponseType(r)
+	noContent := r.Expected == "NO_CONTENT" && len(r.Alternatives) == 0 && !stream && download == ""
+	if requests {
+		s += goRequestCall(r, capitalize(methName), fields, noContent)
+	} else if noContent {
 		s += "\terr" + outHeaders + " := adaptor.impl." + capitalize(methName) + "(context" + sargs + ")\n"
 	} else {
 		s += "\tdata" + outHeaders + ", err := adaptor.impl." + capitalize(methName) + "(context" + sargs + ")\n"
@@ -418,9 +770,9 @@ func goHandlerBody(reg rdl.TypeRegistry, name string, r *rdl.Resource, precise b
 	s += "\t\tcase *rdl.ResourceError:\n"
 	//special case the 304 response, which MUST have an etag in it
 	for _, v := range r.Outputs {
-		if strings.ToLower(v.Header) == "etag" {
+		if strings.ToLower(v.Header) == "etag" && goParsedHeader(reg, v.Type, precise) == "" {
 			s += "\t\t\tif e.Code == 304 && " + string(v.Name) + " != \"\" {\n"
-			s += "\t\t\t\twriter.Header().Set(\"" + v.Header + "\", " + string(v.Name) + ")\n"
+			s += "\t\t\t\twriter.Header().Set(\"" + v.Header + "\", " + goHeaderString(reg, v.Type, precise, string(v.Name)) + ")\n"
 			s += "\t\t\t}\n"
 			break
 		}
@@ -431,21 +783,58 @@ func goHandlerBody(reg rdl.TypeRegistry, name string, r *rdl.Resource, precise b
 	s += "\t\t\trdl.JSONResponse(writer, 500, &rdl.ResourceError{Code: 500, Message: e.Error()})\n"
 	s += "\t\t}\n"
 	s += "\t} else {\n"
+	if stream {
+		//the handler sends the items on the channel, and closes it when done
+		s += "\t\twriter.Header().Set(\"Content-Type\", \"text/event-stream\")\n"
+		s += "\t\twriter.Header().Set(\"Cache-Control\", \"no-cache\")\n"
+		s += fmt.Sprintf("\t\twriter.WriteHeader(%s)\n", rdl.StatusCode(r.Expected))
+		s += "\t\tif f, ok := writer.(http.Flusher); ok {\n"
+		s += "\t\t\tf.Flush()\n"
+		s += "\t\t}\n"
+		s += "\t\tfor {\n"
+		s += "\t\t\tselect {\n"
+		s += "\t\t\tcase <-request.Context().Done():\n"
+		s += "\t\t\t\treturn\n"
+		s += "\t\t\tcase item, ok := <-data:\n"
+		s += "\t\t\t\tif !ok {\n"
+		s += "\t\t\t\t\treturn\n"
+		s += "\t\t\t\t}\n"
+		s += "\t\t\t\tif err := writeEvent(writer, item); err != nil {\n"
+		s += "\t\t\t\t\tlog.Println(\"*** Cannot write event:\", err)\n"
+		s += "\t\t\t\t\treturn\n"
+		s += "\t\t\t\t}\n"
+		s += "\t\t\t}\n"
+		s += "\t\t}\n"
+		s += "\t}\n"
+		return s
+	}
 	for _, v := range r.Outputs {
-		vname := string(v.Name)
-		if v.Optional {
-			s += "\t\tif " + vname + " != nil {\n"
-			s += "\t\t\twriter.Header().Set(\"" + v.Header + "\", " + vname + ")\n"
-			s += "\t\t}\n"
-		} else {
-			s += "\t\twriter.Header().Set(\"" + v.Header + "\", " + vname + ")\n"
-		}
+		s += goHeaderOutput(reg, v, string(v.Name), precise, "\t\t")
+	}
+	if download != "" {
+		//the handler returns the body unread, it is copied to the client as it is read
+		s += "\t\tif data != nil {\n"
+		s += "\t\t\tdefer data.Close()\n"
+		s += "\t\t}\n"
+		s += fmt.Sprintf("\t\twriter.Header().Set(\"Content-Type\", %q)\n", download)
+		s += fmt.Sprintf("\t\twriter.WriteHeader(%s)\n", rdl.StatusCode(r.Expected))
+		s += "\t\tif data != nil {\n"
+		s += "\t\t\tif _, err := io.Copy(writer, data); err != nil {\n"
+		s += "\t\t\t\tlog.Println(\"*** Cannot write response:\", err)\n"
+		s += "\t\t\t}\n"
+		s += "\t\t}\n"
+		s += "\t}\n"
+		return s
 	}
 	if noContent { //other non-content responses?
 		s += fmt.Sprintf("\t\twriter.WriteHeader(204)\n")
 	} else {
 		//fixme: handle alternative responses. How deos the handler pass them back?
-		s += fmt.Sprintf("\t\trdl.JSONResponse(writer, %s, data)\n", rdl.StatusCode(r.Expected))
+		if etagged(r) {
+			s += fmt.Sprintf("\t\twriteETagged(writer, request, %s, data, %s)\n", rdl.StatusCode(r.Expected), goETagArg(reg, r, precise))
+		} else {
+			s += fmt.Sprintf("\t\trdl.JSONResponse(writer, %s, data)\n", rdl.StatusCode(r.Expected))
+		}
 	}
 	s += "\t}\n"
 	return s
@@ -552,7 +941,17 @@ func goHandlerSignature(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) str
 func goServerMethodSignature(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) string {
 	noContent := r.Expected == "NO_CONTENT" && r.Alternatives == nil
 	returnSpec := "error"
-	if !noContent {
+	if streamsEvents(r) {
+		//the items are sent on the channel until it is closed, or the request's context is done
+		returnSpec = "(<-chan " + goType(reg, r.Type, false, "", "", precise, true) + ", error)"
+	} else if streamingResponseType(r) != "" {
+		//the body is copied to the client as it is read, then closed
+		outHeaders := ""
+		for _, v := range r.Outputs {
+			outHeaders += ", " + goType(reg, v.Type, false, "", "", precise, true)
+		}
+		returnSpec = "(io.ReadCloser" + outHeaders + ", error)"
+	} else if !noContent {
 		gtype := goType(reg, r.Type, false, "", "", precise, true)
 		outHeaders := ""
 		for _, v := range r.Outputs {
@@ -561,6 +960,19 @@ func goServerMethodSignature(reg rdl.TypeRegistry, r *rdl.Resource, precise bool
 		returnSpec = "(" + gtype + outHeaders + ", error)"
 	}
 	methName, params := goMethodName(reg, r, precise)
+	if consumesMultipart(r) {
+		//the client sends a stream, the server gets its parts
+		i := 0
+		for _, v := range r.Inputs {
+			if v.Context != "" { //legacy field, to be removed
+				continue
+			}
+			if isUploadBody(r, v) {
+				params[i] = goName(string(v.Name)) + " " + goUploadType(r, true)
+			}
+			i++
+		}
+	}
 	sparams := ""
 	if len(params) > 0 {
 		sparams = ", " + strings.Join(params, ", ")
@@ -577,13 +989,17 @@ func goMethodName(reg rdl.TypeRegistry, r *rdl.Resource, precise bool) (string,
 		}
 		k := v.Name
 		if v.QueryParam == "" && !v.PathParam && v.Header == "" {
-			bodyType = string(v.Type)
+			bodyType = string(unpatchedType(reg, v.Type))
 		}
 		optional := false
 		if v.Optional {
 			optional = true
 		}
-		params = append(params, goName(string(k))+" "+goType(reg, v.Type, optional, "", "", precise, true))
+		ptype := goType(reg, v.Type, optional, "", "", precise, true)
+		if isUploadBody(r, v) {
+			ptype = goUploadType(r, false)
+		}
+		params = append(params, goName(string(k))+" "+ptype)
 	}
 	return strings.ToLower(string(r.Method)) + bodyType, params
 }
@@ -596,3 +1012,28 @@ func goName(name string) string {
 		return name
 	}
 }
+
+// dispatch returns the body of the function the router calls for the resource, running its handler
+// in the span of -x otel=true, and counting and timing it in the metrics of -x metrics=true.
+func (gen *serverGenerator) dispatch(r *rdl.Resource) string {
+	n, _ := goMethodName(gen.registry, r, gen.precise)
+	name := capitalize(n)
+	call := "adaptor." + uncapitalize(n) + "Handler(w, r, ps)\n"
+	var wrappers []string
+	if gen.metrics {
+		wrappers = append(wrappers, fmt.Sprintf("metrics.measure(%q, w, r, func(w http.ResponseWriter, r *http.Request) {\n", name))
+	}
+	if gen.otel {
+		route := strings.SplitN(r.Path, "?", 2)[0]
+		wrappers = append(wrappers, fmt.Sprintf("traced(%q, %q, w, r, func(w http.ResponseWriter, r *http.Request) {\n", name, route))
+	}
+	s := ""
+	for i, wrapper := range wrappers {
+		s += strings.Repeat("\t", i+2) + wrapper
+	}
+	s += strings.Repeat("\t", len(wrappers)+2) + call
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		s += strings.Repeat("\t", i+2) + "})\n"
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// A resource annotated with x_consumes="multipart/form-data" or x_consumes="application/octet-stream"
+// (or any other type than application/json) takes its request body as a stream, whatever the
+// type of its body input, e.g. Bytes. The go-client method takes an io.Reader, sent as it is read:
+// as is for octet-stream, and as the file part of a form, named after the input, for multipart.
+// The go-server handler gets the body unbuffered: the io.Reader of the request body, or for
+// multipart the *multipart.Reader of its parts.
+//
+
+// uploadContentType returns the content type of the request body of the resource, if it takes
+// its body as a stream rather than as JSON, or "".
+func uploadContentType(r *rdl.Resource) string {
+	ct := strings.TrimSpace(r.Annotations["x_consumes"])
+	if ct == "" || ct == "application/json" {
+		return ""
+	}
+	return ct
+}
+
+// consumesMultipart tells whether the resource takes a multipart/form-data body.
+func consumesMultipart(r *rdl.Resource) bool {
+	return strings.HasPrefix(uploadContentType(r), "multipart/")
+}
+
+// isUploadBody tells whether the input is the body of a resource taking a stream.
+func isUploadBody(r *rdl.Resource, in *rdl.ResourceInput) bool {
+	return uploadContentType(r) != "" && !in.PathParam && in.QueryParam == "" && in.Header == "" && in.Context == ""
+}
+
+// goUploadType returns the Go type of the body of a resource taking a stream, for the client or
+// the server.
+func goUploadType(r *rdl.Resource, server bool) string {
+	if server && consumesMultipart(r) {
+		return "*multipart.Reader"
+	}
+	return "io.Reader"
+}
+
+// goServerInputType returns the Go type the server handler gets the input as.
+func goServerInputType(reg rdl.TypeRegistry, r *rdl.Resource, in *rdl.ResourceInput, precise bool) string {
+	if isUploadBody(r, in) {
+		return goUploadType(r, true)
+	}
+	return goType(reg, in.Type, in.Optional, "", "", precise, true)
+}
+
+// anyUploads tells whether any resource of the schema takes a stream, of the multipart kind or not.
+func anyUploads(schema *rdl.Schema, multipart bool) bool {
+	for _, r := range schema.Resources {
+		if uploadContentType(r) != "" && consumesMultipart(r) == multipart {
+			return true
+		}
+	}
+	return false
+}
+
+// goUploadRequest returns the client statements sending the body of a resource taking a stream.
+func goUploadRequest(r *rdl.Resource, headers string, body string) string {
+	ct := fmt.Sprintf("%q", uploadContentType(r))
+	s := ""
+	if consumesMultipart(r) {
+		s += fmt.Sprintf("\tupload, contentType := multipartBody(%q, %s)\n", body, body)
+		body = "upload"
+		ct = "contentType"
+	}
+	s += "\tresp, err := client.httpUpload(ctx, \"" + strings.ToUpper(r.Method) + "\", url, " + headers + ", " + ct + ", " + body + ")\n"
+	return s
+}
+
+// goUploadHandlerInput returns the server statements getting the body of a resource taking a
+// stream as the argument.
+func goUploadHandlerInput(r *rdl.Resource, name string) string {
+	if !consumesMultipart(r) {
+		return "\t" + name + " := request.Body\n"
+	}
+	s := "\t" + name + ", oserr := request.MultipartReader()\n"
+	s += "\tif oserr != nil {\n"
+	s += "\t\trdl.JSONResponse(writer, http.StatusBadRequest, rdl.ResourceError{Code: http.StatusBadRequest, Message: \"Bad request: \" + oserr.Error()})\n"
+	s += "\t\treturn\n"
+	s += "\t}\n"
+	return s
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/ardielle/ardielle-go/rdl"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -148,6 +149,198 @@ func flattenedFields(reg rdl.TypeRegistry, t *rdl.Type) []*rdl.StructFieldDef {
 	return addFields(reg, make([]*rdl.StructFieldDef, 0), t)
 }
 
+// typeAnnotations returns the extended annotations of the type, whatever its variant.
+func typeAnnotations(t *rdl.Type) map[rdl.ExtendedAnnotation]string {
+	switch t.Variant {
+	case rdl.TypeVariantAliasTypeDef:
+		return t.AliasTypeDef.Annotations
+	case rdl.TypeVariantStringTypeDef:
+		return t.StringTypeDef.Annotations
+	case rdl.TypeVariantNumberTypeDef:
+		return t.NumberTypeDef.Annotations
+	case rdl.TypeVariantArrayTypeDef:
+		return t.ArrayTypeDef.Annotations
+	case rdl.TypeVariantMapTypeDef:
+		return t.MapTypeDef.Annotations
+	case rdl.TypeVariantStructTypeDef:
+		return t.StructTypeDef.Annotations
+	case rdl.TypeVariantEnumTypeDef:
+		return t.EnumTypeDef.Annotations
+	case rdl.TypeVariantUnionTypeDef:
+		return t.UnionTypeDef.Annotations
+	case rdl.TypeVariantBytesTypeDef:
+		return t.BytesTypeDef.Annotations
+	}
+	return nil
+}
+
+// streamsEvents tells whether the resource streams its results to the client as server-sent
+// events, i.e. is annotated with x_stream="sse".
+func streamsEvents(r *rdl.Resource) bool {
+	return r.Annotations["x_stream"] == "sse"
+}
+
+// nullableField tells whether the field may be explicitly null, i.e. is annotated with
+// x_nullable="true". This is distinct from optional: an optional field may be absent, a
+// nullable one may be present with a null value, and a field may be both.
+func nullableField(f *rdl.StructFieldDef) bool {
+	v, ok := f.Annotations["x_nullable"]
+	return ok && v != "false"
+}
+
+// anyStreamsEvents tells whether any resource of the schema streams its results.
+func anyStreamsEvents(schema *rdl.Schema) bool {
+	for _, r := range schema.Resources {
+		if streamsEvents(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// streamingResponseType returns the content type of the response body of the resource if the
+// clients hand it to the caller unread, rather than decoding it, or "". The resource is annotated
+// with x_streaming_response, set to the content type, e.g. "application/x-ndjson", or to "true"
+// for "application/octet-stream".
+func streamingResponseType(r *rdl.Resource) string {
+	ct := strings.TrimSpace(r.Annotations["x_streaming_response"])
+	switch ct {
+	case "", "false":
+		return ""
+	case "true":
+		return "application/octet-stream"
+	}
+	return ct
+}
+
+// anyStreamingResponses tells whether any resource of the schema streams its response body.
+func anyStreamingResponses(schema *rdl.Schema) bool {
+	for _, r := range schema.Resources {
+		if streamingResponseType(r) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// stringConstraints returns the constraints of a string type, including those inherited from
+// the types it is derived from.
+func stringConstraints(reg rdl.TypeRegistry, t *rdl.Type) (string, []string, *int32, *int32) {
+	var pattern string
+	var values []string
+	var minSize, maxSize *int32
+	for t != nil {
+		switch t.Variant {
+		case rdl.TypeVariantStringTypeDef:
+			st := t.StringTypeDef
+			if pattern == "" {
+				pattern = st.Pattern
+			}
+			if values == nil {
+				values = st.Values
+			}
+			if minSize == nil {
+				minSize = st.MinSize
+			}
+			if maxSize == nil {
+				maxSize = st.MaxSize
+			}
+			t = reg.FindType(st.Type)
+		case rdl.TypeVariantAliasTypeDef:
+			t = reg.FindType(t.AliasTypeDef.Type)
+		default:
+			t = nil
+		}
+	}
+	return pattern, values, minSize, maxSize
+}
+
+// numberConstraints returns the range of a numeric type, including the bounds inherited from
+// the types it is derived from.
+func numberConstraints(reg rdl.TypeRegistry, t *rdl.Type) (*rdl.Number, *rdl.Number) {
+	var min, max *rdl.Number
+	for t != nil {
+		switch t.Variant {
+		case rdl.TypeVariantNumberTypeDef:
+			nt := t.NumberTypeDef
+			if min == nil {
+				min = nt.Min
+			}
+			if max == nil {
+				max = nt.Max
+			}
+			t = reg.FindType(nt.Type)
+		case rdl.TypeVariantAliasTypeDef:
+			t = reg.FindType(t.AliasTypeDef.Type)
+		default:
+			t = nil
+		}
+	}
+	return min, max
+}
+
+// bytesConstraints returns the size constraints of a bytes type, including those inherited from
+// the types it is derived from.
+func bytesConstraints(reg rdl.TypeRegistry, t *rdl.Type) (*int32, *int32, *int32) {
+	var size, minSize, maxSize *int32
+	for t != nil {
+		switch t.Variant {
+		case rdl.TypeVariantBytesTypeDef:
+			bt := t.BytesTypeDef
+			if size == nil {
+				size = bt.Size
+			}
+			if minSize == nil {
+				minSize = bt.MinSize
+			}
+			if maxSize == nil {
+				maxSize = bt.MaxSize
+			}
+			t = reg.FindType(bt.Type)
+		case rdl.TypeVariantAliasTypeDef:
+			t = reg.FindType(t.AliasTypeDef.Type)
+		default:
+			t = nil
+		}
+	}
+	return size, minSize, maxSize
+}
+
+func goGenerationBoolOptionSet(options []string, key string) bool {
+	for _, option := range options {
+		substrings := strings.SplitN(option, "=", 2)
+		if len(substrings) == 2 && substrings[0] == key {
+			value, err := strconv.ParseBool(substrings[1])
+			return err == nil && value
+		}
+	}
+	return false
+}
+
+// goGenerationStringOptionSet returns the value of the option, or "" if it is not set.
+func goGenerationStringOptionSet(options []string, key string) string {
+	for _, option := range options {
+		substrings := strings.SplitN(option, "=", 2)
+		if len(substrings) == 2 && substrings[0] == key {
+			return substrings[1]
+		}
+	}
+	return ""
+}
+
+// goGenerationBoolOptionUnset tells whether a boolean option that is on by default is turned off,
+// e.g. -x pointers=false.
+func goGenerationBoolOptionUnset(options []string, key string) bool {
+	for _, option := range options {
+		substrings := strings.SplitN(option, "=", 2)
+		if len(substrings) == 2 && substrings[0] == key {
+			value, err := strconv.ParseBool(substrings[1])
+			return err == nil && !value
+		}
+	}
+	return false
+}
+
 func capitalize(text string) string {
 	return strings.ToUpper(text[0:1]) + text[1:]
 }
@@ -182,6 +375,7 @@ func outputWriter(outdir string, name string, ext string) (*bufio.Writer, *os.Fi
 	if err != nil {
 		return nil, nil, "", err
 	}
+	recordGenerated(path)
 	writer := bufio.NewWriter(f)
 	return writer, f, sname, nil
 }
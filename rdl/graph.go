@@ -0,0 +1,225 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//
+// The graph command prints the dependency graph of a schema, to visualize it: a node for each
+// type and resource, and an edge from a type to each type of its fields, items, keys, variants,
+// and supertype, and from a resource to its type and the types of its inputs, outputs, and
+// exceptions. Only the types of the schema are nodes, not the base types. It is printed for
+// Graphviz (dot), Mermaid (a flowchart), or as JSON for other tools.
+//
+
+// SchemaGraph is the graph of the graph command, as printed with -o json.
+type SchemaGraph struct {
+	Name  string       `json:"name"`
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// GraphNode is a type, whose ID is its name, or a resource, whose ID is its method and path,
+// e.g. "GET /contacts/{name}".
+type GraphNode struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	BaseType string `json:"baseType,omitempty"`
+}
+
+// GraphEdge is a dependency of a node on a type. Its kind is one of field, items, keys, variant,
+// and supertype for a type, and type, input, output, and exception for a resource. The label is
+// the name of the field, input, or output, or the status of the exception.
+type GraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Kind  string `json:"kind"`
+	Label string `json:"label,omitempty"`
+}
+
+// printSchemaGraph prints the dependency graph of the schema in the format, dot, mermaid, or json.
+func printSchemaGraph(schema *rdl.Schema, format string) error {
+	g := schemaGraph(schema)
+	switch format {
+	case "", "dot":
+		fmt.Print(g.dot())
+	case "mermaid":
+		fmt.Print(g.mermaid())
+	case "json":
+		j, err := json.MarshalIndent(g, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", j)
+	default:
+		return fmt.Errorf("Unsupported graph format '%s' (expected dot, mermaid, or json)", format)
+	}
+	return nil
+}
+
+// schemaGraph builds the dependency graph of the schema.
+func schemaGraph(schema *rdl.Schema) *SchemaGraph {
+	reg := rdl.NewTypeRegistry(schema)
+	g := &SchemaGraph{Name: string(schema.Name), Nodes: []*GraphNode{}, Edges: []*GraphEdge{}}
+	inline := inlineFieldTypes(schema)
+	types := declaredTypes(schema, inline)
+	defined := make(map[rdl.TypeRef]bool)
+	for _, t := range types {
+		tName, _, _ := rdl.TypeInfo(t)
+		defined[rdl.TypeRef(tName)] = true
+		g.Nodes = append(g.Nodes, &GraphNode{ID: string(tName), Kind: "type", BaseType: reg.BaseType(t).String()})
+	}
+	for _, t := range types {
+		tName, _, _ := rdl.TypeInfo(t)
+		var edges []*GraphEdge
+		for ref, wheres := range inlineTypeRefs(t, inline) {
+			if !defined[ref] {
+				continue
+			}
+			for _, where := range wheres {
+				kind := where
+				label := ""
+				if strings.HasPrefix(where, "field ") {
+					kind, label = "field", strings.TrimPrefix(where, "field ")
+				}
+				edges = append(edges, &GraphEdge{From: string(tName), To: string(ref), Kind: kind, Label: label})
+			}
+		}
+		g.Edges = append(g.Edges, sortedGraphEdges(edges)...)
+	}
+	for _, r := range schema.Resources {
+		id := strings.ToUpper(r.Method) + " " + r.Path
+		g.Nodes = append(g.Nodes, &GraphNode{ID: id, Kind: "resource"})
+		var edges []*GraphEdge
+		use := func(kind string, label string, ref rdl.TypeRef) {
+			if defined[ref] {
+				edges = append(edges, &GraphEdge{From: id, To: string(ref), Kind: kind, Label: label})
+			}
+		}
+		use("type", "", r.Type)
+		for _, in := range r.Inputs {
+			use("input", string(in.Name), in.Type)
+		}
+		for _, out := range r.Outputs {
+			use("output", string(out.Name), out.Type)
+		}
+		for code, e := range r.Exceptions {
+			use("exception", code, rdl.TypeRef(e.Type))
+		}
+		g.Edges = append(g.Edges, sortedGraphEdges(edges)...)
+	}
+	return g
+}
+
+// sortedGraphEdges sorts the edges of a node, which come out of maps, by type, kind, and label.
+func sortedGraphEdges(edges []*GraphEdge) []*GraphEdge {
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Label < b.Label
+	})
+	return edges
+}
+
+// graphEdgeLabel is what an edge is labeled with in the drawings: the field, input, or output
+// name, the status of the exception, or else the kind of dependency.
+func graphEdgeLabel(e *GraphEdge) string {
+	switch {
+	case e.Kind == "supertype":
+		return "extends"
+	case e.Kind == "type":
+		return ""
+	case e.Label != "":
+		return e.Label
+	}
+	return e.Kind
+}
+
+// dot returns the graph in the Graphviz dot language. Resources are ellipses, supertypes are
+// dashed edges, and variants dotted ones.
+func (g *SchemaGraph) dot() string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace
+	quote := func(s string) string {
+		return `"` + escape(s) + `"`
+	}
+	s := "digraph " + quote(g.Name) + " {\n"
+	s += "    rankdir=LR;\n"
+	s += "    node [shape=box];\n"
+	for _, n := range g.Nodes {
+		if n.Kind == "resource" {
+			s += "    " + quote(n.ID) + " [shape=ellipse];\n"
+		} else {
+			s += "    " + quote(n.ID) + " [label=\"" + escape(n.ID) + "\\n" + n.BaseType + "\"];\n"
+		}
+	}
+	for _, e := range g.Edges {
+		var attrs []string
+		if label := graphEdgeLabel(e); label != "" {
+			attrs = append(attrs, "label="+quote(label))
+		}
+		switch e.Kind {
+		case "supertype":
+			attrs = append(attrs, "style=dashed")
+		case "variant":
+			attrs = append(attrs, "style=dotted")
+		}
+		s += "    " + quote(e.From) + " -> " + quote(e.To)
+		if len(attrs) > 0 {
+			s += " [" + strings.Join(attrs, ", ") + "]"
+		}
+		s += ";\n"
+	}
+	return s + "}\n"
+}
+
+var mermaidIDChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// mermaid returns the graph as a Mermaid flowchart. Resources are stadium shapes, and supertypes
+// and variants dotted edges.
+func (g *SchemaGraph) mermaid() string {
+	ids := make(map[string]string)
+	resources := 0
+	for _, n := range g.Nodes {
+		if n.Kind == "resource" {
+			resources++
+			ids[n.ID] = fmt.Sprintf("r%d", resources)
+		} else {
+			ids[n.ID] = "t_" + mermaidIDChars.ReplaceAllString(n.ID, "_")
+		}
+	}
+	text := func(s string) string {
+		return `"` + strings.Replace(s, `"`, "#quot;", -1) + `"`
+	}
+	s := "flowchart LR\n"
+	for _, n := range g.Nodes {
+		if n.Kind == "resource" {
+			s += "    " + ids[n.ID] + "([" + text(n.ID) + "])\n"
+		} else {
+			s += "    " + ids[n.ID] + "[" + text(n.ID+" ("+n.BaseType+")") + "]\n"
+		}
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Kind == "supertype" || e.Kind == "variant" {
+			arrow = "-.->"
+		}
+		if label := graphEdgeLabel(e); label != "" {
+			arrow += "|" + text(label) + "|"
+		}
+		s += "    " + ids[e.From] + " " + arrow + " " + ids[e.To] + "\n"
+	}
+	return s
+}
@@ -0,0 +1,214 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+//
+// The rdl parser resolves include and use directives relative to the including file only.
+// To support an include path (-I), the schema file and everything it transitively includes
+// are staged into a temporary directory, with each directive rewritten to name the staged
+// copy of the file it resolved to. Each file is staged once, no matter how often it is
+// included, and include cycles are reported instead of recursing forever.
+//
+
+var includeDirective = regexp.MustCompile(`^(\s*(?:include|use)\s+")([^"]+)(".*)$`)
+
+type includeResolver struct {
+	includePath []string
+	dir         string
+	staged      map[string]string //absolute path of the source file -> staged file name
+	sources     map[string]string //staged file name -> source file path
+	names       map[string]string //staged file name -> the name it was included as
+	stack       []string
+	prelude     string //directives prepended to the first line of the schema file
+}
+
+func parseWithIncludePath(schemaFile string, includePath []string, pretty bool, strict bool, warning bool) (*rdl.Schema, error) {
+	return parseWithSharedTypes(schemaFile, includePath, nil, pretty, strict, warning)
+}
+
+// parseWithSharedTypes parses the schema file as if it included each of the shared files, which
+// is how the schemas generated together get at the types of each other (see batch.go).
+func parseWithSharedTypes(schemaFile string, includePath []string, shared []string, pretty bool, strict bool, warning bool) (*rdl.Schema, error) {
+	if len(includePath) == 0 && len(shared) == 0 && !hasIncludeDirective(schemaFile) {
+		//nothing to resolve, so the errors name the file as it is
+		schema, err := rdl.ParseRDLFile(schemaFile, pretty, strict, warning)
+		if err != nil {
+			return nil, err
+		}
+		return expandSchema(schema)
+	}
+	dir, err := ioutil.TempDir("", "rdl-include")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	res := newIncludeResolver(includePath, dir)
+	var prelude []string
+	for _, file := range shared {
+		name, err := res.stage(file)
+		if err != nil {
+			return nil, err
+		}
+		res.names[name] = filepath.Base(file)
+		prelude = append(prelude, fmt.Sprintf("include %q; ", name))
+	}
+	//on the first line, so that the line numbers of errors stay right
+	res.prelude = strings.Join(prelude, "")
+	root, err := res.stage(schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := rdl.ParseRDLFile(filepath.Join(dir, root), pretty, strict, warning)
+	if err != nil {
+		return nil, res.sourceError(err)
+	}
+	res.restoreIncludedFrom(schema)
+	return expandSchema(schema)
+}
+
+// expandSchema applies the mixins and the type arguments of the parsed schema.
+func expandSchema(schema *rdl.Schema) (*rdl.Schema, error) {
+	if err := applyMixins(schema); err != nil {
+		return nil, err
+	}
+	if err := applyTypeArgs(schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// hasIncludeDirective tells whether the schema file includes or uses another file. If it cannot
+// be read, it is staged anyway, to report the error the same way.
+func hasIncludeDirective(schemaFile string) bool {
+	data, err := ioutil.ReadFile(schemaFile)
+	if err != nil {
+		return true
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if m := includeDirective.FindStringSubmatch(line); m != nil && m[2] != "rdl" {
+			return true
+		}
+	}
+	return false
+}
+
+func newIncludeResolver(includePath []string, dir string) *includeResolver {
+	return &includeResolver{
+		includePath: includePath,
+		dir:         dir,
+		staged:      make(map[string]string),
+		sources:     make(map[string]string),
+		names:       make(map[string]string),
+	}
+}
+
+// resolve finds the file named in a directive, looking next to the including file first,
+// then in each directory of the include path, in order.
+func (res *includeResolver) resolve(name string, fromDir string) (string, error) {
+	dirs := append([]string{fromDir}, res.includePath...)
+	for _, dir := range dirs {
+		path := name
+		if !filepath.IsAbs(name) {
+			path = filepath.Join(dir, name)
+		}
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return filepath.Abs(path)
+		}
+	}
+	return "", fmt.Errorf("Cannot find included file '%s' (searched %s)", name, strings.Join(dirs, ", "))
+}
+
+func (res *includeResolver) stage(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for i, p := range res.stack {
+		if p == abs {
+			return "", fmt.Errorf("Include cycle: %s", strings.Join(append(res.stack[i:], abs), " -> "))
+		}
+	}
+	if name, ok := res.staged[abs]; ok {
+		return name, nil
+	}
+	data, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	res.stack = append(res.stack, abs)
+	defer func() { res.stack = res.stack[:len(res.stack)-1] }()
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		m := includeDirective.FindStringSubmatch(line)
+		if m == nil || m[2] == "rdl" { //use "rdl" refers to the builtin schema
+			continue
+		}
+		target, err := res.resolve(m[2], filepath.Dir(abs))
+		if err != nil {
+			return "", fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		name, err := res.stage(target)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := res.names[name]; !ok {
+			res.names[name] = m[2]
+		}
+		lines[i] = m[1] + name + m[3]
+	}
+	if len(res.stack) == 1 {
+		lines[0] = res.prelude + lines[0]
+	}
+	name := fmt.Sprintf("%d-%s", len(res.staged), filepath.Base(abs))
+	res.staged[abs] = name
+	res.sources[name] = path
+	err = ioutil.WriteFile(filepath.Join(res.dir, name), []byte(strings.Join(lines, "\n")), 0644)
+	return name, err
+}
+
+// sourceError rewrites the staged file names in a parse error back to the original files, both
+// their paths and their bare names, which the parser reports most errors with.
+func (res *includeResolver) sourceError(err error) error {
+	var names []string
+	for name := range res.sources {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	//not within a longer name, e.g. 1-bad.rdl in 11-bad.rdl
+	staged := regexp.MustCompile(`(^|[^\w.-])(?:` + regexp.QuoteMeta(res.dir+string(filepath.Separator)) + `)?(` + strings.Join(names, "|") + `)`)
+	msg := staged.ReplaceAllStringFunc(err.Error(), func(s string) string {
+		m := staged.FindStringSubmatch(s)
+		return m[1] + res.sources[m[2]]
+	})
+	return fmt.Errorf("%s", msg)
+}
+
+// restoreIncludedFrom rewrites the x_included_from annotations the parser adds back to the
+// names the files were included as.
+func (res *includeResolver) restoreIncludedFrom(schema *rdl.Schema) {
+	restore := func(annotations map[rdl.ExtendedAnnotation]string) {
+		if name, ok := res.names[annotations["x_included_from"]]; ok {
+			annotations["x_included_from"] = name
+		}
+	}
+	for _, t := range schema.Types {
+		if annotations := typeAnnotations(t); annotations != nil {
+			restore(annotations)
+		}
+	}
+	for _, r := range schema.Resources {
+		if r.Annotations != nil {
+			restore(r.Annotations)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"github.com/ardielle/ardielle-go/rdl"
+	"regexp"
+	"strings"
+)
+
+//
+// The parser makes a type for each field of a struct declared with constraints, e.g. for
+// String name (maxSize=10) in Contact a type Contact_T1 String (maxSize=10), numbered across the
+// schema, which the field is then of. They are not written in the schema, so the commands
+// describing it (fmt, explain, lint, stats, graph, repl) leave them out, as types, and refer to the
+// types the fields were declared as instead.
+//
+
+// inlineFieldTypes returns the types the parser made for the fields declared with constraints,
+// by name.
+func inlineFieldTypes(schema *rdl.Schema) map[rdl.TypeRef]*rdl.Type {
+	reg := rdl.NewTypeRegistry(schema)
+	inline := make(map[rdl.TypeRef]*rdl.Type)
+	for _, t := range schema.Types {
+		if t.Variant != rdl.TypeVariantStructTypeDef {
+			continue
+		}
+		st := t.StructTypeDef
+		for _, fd := range st.Fields {
+			if !inlineTypeName.MatchString(string(fd.Type)) || !strings.HasPrefix(string(fd.Type), string(st.Name)+"_T") {
+				continue
+			}
+			ft := reg.FindType(fd.Type)
+			if ft == nil || inlineBaseType(ft) == "" {
+				continue
+			}
+			_, _, comment := rdl.TypeInfo(ft)
+			annotations := typeAnnotations(ft)
+			_, included := annotations["x_included_from"]
+			if comment == "" && (len(annotations) == 0 || len(annotations) == 1 && included) {
+				inline[fd.Type] = ft
+			}
+		}
+	}
+	return inline
+}
+
+var inlineTypeName = regexp.MustCompile(`^[A-Za-z_][A-Za-z_0-9]*_T[0-9]+$`)
+
+// declaredTypes returns the types of the schema, less those the parser made for the fields
+// declared with constraints.
+func declaredTypes(schema *rdl.Schema, inline map[rdl.TypeRef]*rdl.Type) []*rdl.Type {
+	var types []*rdl.Type
+	for _, t := range schema.Types {
+		if tName, _, _ := rdl.TypeInfo(t); inline[rdl.TypeRef(tName)] == nil {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// inlineBaseType returns the type the inline field type is derived from, i.e. the type of the field
+// as declared.
+func inlineBaseType(t *rdl.Type) rdl.TypeRef {
+	switch t.Variant {
+	case rdl.TypeVariantStringTypeDef:
+		return t.StringTypeDef.Type
+	case rdl.TypeVariantNumberTypeDef:
+		return t.NumberTypeDef.Type
+	case rdl.TypeVariantBytesTypeDef:
+		return t.BytesTypeDef.Type
+	}
+	return ""
+}
+
+// inlineTypeRefs returns the types the type refers to, as typeRefs does, but with the types of
+// the fields declared with constraints replaced by the types they were declared as.
+func inlineTypeRefs(t *rdl.Type, inline map[rdl.TypeRef]*rdl.Type) map[rdl.TypeRef][]string {
+	refs := make(map[rdl.TypeRef][]string)
+	for ref, wheres := range typeRefs(t) {
+		if it := inline[ref]; it != nil {
+			ref = inlineBaseType(it)
+		}
+		refs[ref] = append(refs[ref], wheres...)
+	}
+	return refs
+}
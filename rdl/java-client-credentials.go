@@ -0,0 +1,132 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+//
+// The credentials of the java client. A CredentialsProvider is asked for the auth headers of
+// each request to a resource that authenticates or authorizes, so that expiring tokens are
+// renewed as they go, and when such a request is rejected with 401 Unauthorized it is refreshed
+// and the request retried once with the new credentials. It can also hold the SSL context of the
+// client connections, for mutual TLS. addCredentials(header, token) sets a provider of the fixed
+// header.
+//
+
+const javaCredentialsProviderTemplate = `{{header}}
+package {{package}};
+import java.security.GeneralSecurityException;
+import java.security.KeyStore;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.Collections;
+import java.util.Map;
+import java.util.function.Supplier;
+import javax.net.ssl.KeyManagerFactory;
+import javax.net.ssl.SSLContext;
+
+//
+// CredentialsProvider gives the credentials of the requests of a client to the resources that
+// authenticate or authorize. When such a request is rejected with 401 Unauthorized, refresh is
+// called, and the request retried once if it returns true.
+//
+public interface CredentialsProvider {
+
+    // credentials returns the headers to add to the next request, e.g. its Authorization. It is
+    // called for every request, and throws an unchecked exception if they cannot be had.
+    Map<String, String> credentials();
+
+    // refresh renews the credentials after a request was rejected with them, and returns whether
+    // the request is to be retried.
+    default boolean refresh() {
+        return false;
+    }
+
+    // sslContext returns the SSL context of the client connections, e.g. with the client
+    // certificate for mutual TLS, or null for the default one.
+    default SSLContext sslContext() {
+        return null;
+    }
+
+    // header sends the fixed header, as addCredentials does.
+    static CredentialsProvider header(String name, String value) {
+        Map<String, String> headers = Collections.singletonMap(name, value);
+        return () -> headers;
+    }
+
+    // bearerToken sends the token in an Authorization: Bearer header, fetching a new one when it
+    // is about to expire or is rejected.
+    static CredentialsProvider bearerToken(Supplier<Token> fetch) {
+        return new BearerToken(fetch, Duration.ofSeconds(30));
+    }
+
+    // mutualTls authenticates the client with the certificate of the SSL context, and sends no
+    // headers.
+    static CredentialsProvider mutualTls(SSLContext context) {
+        return new CredentialsProvider() {
+            @Override
+            public Map<String, String> credentials() {
+                return Collections.emptyMap();
+            }
+
+            @Override
+            public SSLContext sslContext() {
+                return context;
+            }
+        };
+    }
+
+    // the SSL context with the client certificate and key of the key store
+    static CredentialsProvider mutualTls(KeyStore keyStore, char[] password) throws GeneralSecurityException {
+        KeyManagerFactory keyManagers = KeyManagerFactory.getInstance(KeyManagerFactory.getDefaultAlgorithm());
+        keyManagers.init(keyStore, password);
+        SSLContext context = SSLContext.getInstance("TLS");
+        context.init(keyManagers.getKeyManagers(), null, null);
+        return mutualTls(context);
+    }
+
+    // Token is an access token, and the time it expires at, or null if it is not known to.
+    final class Token {
+        private final String value;
+        private final Instant expiry;
+
+        public Token(String value, Instant expiry) {
+            this.value = value;
+            this.expiry = expiry;
+        }
+
+        public String getValue() {
+            return value;
+        }
+
+        public Instant getExpiry() {
+            return expiry;
+        }
+    }
+
+    final class BearerToken implements CredentialsProvider {
+        private final Supplier<Token> fetch;
+        private final Duration leeway;
+        private Token token;
+
+        // the token is fetched again once within leeway of its expiry
+        public BearerToken(Supplier<Token> fetch, Duration leeway) {
+            this.fetch = fetch;
+            this.leeway = leeway;
+        }
+
+        @Override
+        public synchronized Map<String, String> credentials() {
+            if (token == null || (token.getExpiry() != null && Instant.now().plus(leeway).isAfter(token.getExpiry()))) {
+                token = fetch.get();
+            }
+            return Collections.singletonMap("Authorization", "Bearer " + token.getValue());
+        }
+
+        @Override
+        public synchronized boolean refresh() {
+            token = null;
+            return true;
+        }
+    }
+}
+`
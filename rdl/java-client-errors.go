@@ -0,0 +1,139 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"github.com/ardielle/ardielle-go/rdl"
+	"sort"
+	"text/template"
+)
+
+//
+// With -x typed-errors=true, the java-client generates a subclass of ResourceException for each
+// type the resources declare exceptions of, e.g. QuotaInfoException for QuotaInfo, with the
+// decoded body of the response as its getError(), and its methods throw it for the status codes
+// of those exceptions. The callers can then catch the errors they handle by their class, instead
+// of switching on getCode(), and those catching ResourceException are unchanged.
+//
+
+const javaTypedExceptionTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+
+//
+// {{.Exception}} is the ResourceException the client throws for the status codes its resources
+// declare a {{.Type}} exception for, with the body of the response as its error.
+//
+public class {{.Exception}} extends ResourceException {
+
+    public {{.Exception}}(int code, {{.Type}} error) {
+        super(code, error);
+    }
+
+    static {{.Exception}} fromBody(int code, String body) {
+        return new {{.Exception}}(code, body == null || body.isEmpty() ? null : JSON.fromString(body, {{.Type}}.class));
+    }
+
+    public {{.Type}} getError() {
+        return getData({{.Type}}.class);
+    }
+}
+`
+
+// javaExceptionTypes returns the types the resources of the schema declare exceptions of.
+func javaExceptionTypes(reg rdl.TypeRegistry, schema *rdl.Schema) []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, r := range schema.Resources {
+		for _, e := range r.Exceptions {
+			if !seen[e.Type] && javaTypedErrorBody(reg, e.Type) != "" {
+				seen[e.Type] = true
+				types = append(types, e.Type)
+			}
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// javaTypedErrorBody returns the Java type of the body of the exceptions of the type, the
+// ResourceError generated with the client for ResourceError, or "" if the schema does not define
+// the type.
+func javaTypedErrorBody(reg rdl.TypeRegistry, etype string) string {
+	if reg.FindType(rdl.TypeRef(etype)) == nil {
+		if etype == "ResourceError" {
+			return "ResourceError"
+		}
+		return ""
+	}
+	return javaType(reg, rdl.TypeRef(etype), false, "", "")
+}
+
+// javaTypedExceptionName returns the name of the ResourceException of the exceptions of the type.
+func javaTypedExceptionName(reg rdl.TypeRegistry, etype string) string {
+	return javaTypedErrorBody(reg, etype) + "Exception"
+}
+
+// generateTypedExceptions writes the ResourceException subclass of each exception type.
+func (gen *javaClientGenerator) generateTypedExceptions(packageDir string) error {
+	for _, etype := range javaExceptionTypes(gen.registry, gen.schema) {
+		data := struct {
+			Exception string
+			Type      string
+		}{javaTypedExceptionName(gen.registry, etype), javaTypedErrorBody(gen.registry, etype)}
+		out, file, _, err := outputWriter(packageDir, data.Exception, ".java")
+		if err != nil {
+			return err
+		}
+		funcMap := template.FuncMap{
+			"header":  func() string { return javaGenerationHeader(gen.banner) },
+			"package": func() string { return javaGenerationPackage(gen.schema, gen.ns) },
+		}
+		t := template.Must(template.New(data.Exception).Funcs(funcMap).Parse(javaTypedExceptionTemplate))
+		err = t.Execute(out, data)
+		out.Flush()
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typedExceptionCases returns the cases of the switch on the status code of the response throwing
+// the typed exceptions of the resource, at the indentation, with the body decoded by transport
+// clients from the String of the response, and by the JAX-RS one with readEntity. The codes the
+// resource expects are left to their own case.
+func (gen *javaClientGenerator) typedExceptionCases(r *rdl.Resource, indent string, transport bool) string {
+	expected := map[string]bool{r.Expected: true}
+	for _, alt := range r.Alternatives {
+		expected[alt] = true
+	}
+	codes := make(map[string][]string)
+	var etypes []string
+	for ecode, e := range r.Exceptions {
+		if expected[ecode] || javaTypedErrorBody(gen.registry, e.Type) == "" {
+			continue
+		}
+		if codes[e.Type] == nil {
+			etypes = append(etypes, e.Type)
+		}
+		codes[e.Type] = append(codes[e.Type], rdl.StatusCode(ecode))
+	}
+	sort.Strings(etypes)
+	s := ""
+	for _, etype := range etypes {
+		sort.Strings(codes[etype])
+		for _, code := range codes[etype] {
+			s += indent + "case " + code + ":\n"
+		}
+		name := javaTypedExceptionName(gen.registry, etype)
+		if transport {
+			s += indent + "    throw " + name + ".fromBody(code, response.getBody());\n"
+		} else {
+			s += indent + "    throw new " + name + "(code, response.readEntity(" + javaTypedErrorBody(gen.registry, etype) + ".class));\n"
+		}
+	}
+	return s
+}
@@ -0,0 +1,148 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+	"text/template"
+)
+
+//
+// The java-client generates a <Client>Headers class with a constant of the name of each header of
+// the resources, e.g. IF_MODIFIED_SINCE for If-Modified-Since, and for each resource with header
+// outputs, a class of their typed values, read from the headers map its method fills, e.g.
+// new SampleHeaders.GetContact(headers).getLastModified(), rather than the strings of the map.
+//
+
+const javaClientHeadersTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+import java.util.List;
+import java.util.Map;
+import java.util.function.Function;
+
+//
+// {{.Class}} holds the names of the headers of the {{.Name}} resources, and the typed values
+// of the headers the client methods fill the headers map with.
+//
+public final class {{.Class}} {
+{{range .Constants}}
+    public static final String {{index . 0}} = "{{index . 1}}";{{end}}
+
+    private {{.Class}}() {
+    }
+{{range .Outputs}}
+    //
+    // {{.Class}} holds the headers {{.Method}} responds with. A header the response lacks is null.
+    //
+    public static final class {{.Class}} {
+{{range .Fields}}        private final {{.Type}} {{.Name}};
+{{end}}
+        public {{.Class}}(Map<String, List<String>> headers) {
+{{range .Fields}}            this.{{.Name}} = {{.Parse}};
+{{end}}        }
+{{range .Fields}}
+        public {{.Type}} {{.Getter}}() {
+            return {{.Name}};
+        }
+{{end}}    }
+{{end}}
+    static String first(Map<String, List<String>> headers, String name) {
+        List<String> values = headers == null ? null : headers.get(name);
+        return values == null || values.isEmpty() ? null : values.get(0);
+    }
+
+    static <T> T parse(String value, Function<String, T> parser) {
+        return value == null ? null : parser.apply(value);
+    }
+}
+`
+
+type javaHeaderField struct {
+	Name   string
+	Type   string
+	Getter string
+	Parse  string
+}
+
+type javaHeaderOutputs struct {
+	Class  string
+	Method string
+	Fields []javaHeaderField
+}
+
+// javaHeaderConstant returns the name of the constant of the header, e.g. IF_MODIFIED_SINCE.
+func javaHeaderConstant(header string) string {
+	return strings.ToUpper(strings.Replace(header, "-", "_", -1))
+}
+
+// javaHeaderParse returns the expression of the value of the Java type of the header, given that
+// of its string, which is null if the response lacks it.
+func javaHeaderParse(reg rdl.TypeRegistry, tref rdl.TypeRef, value string) string {
+	jtype := javaType(reg, tref, true, "", "")
+	switch reg.FindBaseType(tref) {
+	case rdl.BaseTypeString:
+		return value
+	case rdl.BaseTypeBool, rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return "parse(" + value + ", " + jtype + "::valueOf)"
+	}
+	//Symbol, Timestamp, UUID, and enums
+	return "parse(" + value + ", " + jtype + "::fromString)"
+}
+
+// generateClientHeaders writes the <Client>Headers class, if the resources have headers.
+func (gen *javaClientGenerator) generateClientHeaders(packageDir string) error {
+	data := struct {
+		Class     string
+		Name      string
+		Constants [][]string
+		Outputs   []*javaHeaderOutputs
+	}{Class: capitalize(gen.name) + "Headers", Name: string(gen.schema.Name)}
+	seen := make(map[string]bool)
+	constant := func(header string) {
+		if name := javaHeaderConstant(header); header != "" && !seen[name] {
+			seen[name] = true
+			data.Constants = append(data.Constants, []string{name, header})
+		}
+	}
+	for _, r := range gen.schema.Resources {
+		for _, in := range r.Inputs {
+			constant(in.Header)
+		}
+		if len(r.Outputs) == 0 || streamsEvents(r) {
+			continue
+		}
+		methName, _ := javaMethodName(gen.registry, r)
+		outputs := &javaHeaderOutputs{Class: capitalize(methName), Method: methName}
+		for _, out := range r.Outputs {
+			constant(out.Header)
+			name := javaName(out.Name)
+			outputs.Fields = append(outputs.Fields, javaHeaderField{
+				Name:   name,
+				Type:   javaType(gen.registry, out.Type, true, "", ""),
+				Getter: "get" + capitalize(string(out.Name)),
+				Parse:  javaHeaderParse(gen.registry, out.Type, fmt.Sprintf("first(headers, %q)", out.Name)),
+			})
+		}
+		data.Outputs = append(data.Outputs, outputs)
+	}
+	if len(data.Constants) == 0 {
+		return nil
+	}
+	out, file, _, err := outputWriter(packageDir, data.Class, ".java")
+	if err != nil {
+		return err
+	}
+	funcMap := template.FuncMap{
+		"header":  func() string { return javaGenerationHeader(gen.banner) },
+		"package": func() string { return javaGenerationPackage(gen.schema, gen.ns) },
+	}
+	t := template.Must(template.New(data.Class).Funcs(funcMap).Parse(javaClientHeadersTemplate))
+	err = t.Execute(out, data)
+	out.Flush()
+	file.Close()
+	return err
+}
@@ -0,0 +1,248 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//
+// With -x tests=true, java-client also generates <Name>ClientTest, a JUnit 5 test calling each
+// resource through the client, against the server at the rdl.client.url system property (by
+// default the java mock-server on localhost:4080). The requests are built from example values of
+// the input types, and the tests check the status of the response is one the resource expects.
+// For each exception the resource declares there is a disabled test, expecting the call to throw
+// a ResourceException with its status code, to enable once the server is set up to answer so.
+//
+
+// javaClientTest is the test of a resource: the call of its client method and what to expect.
+type javaClientTest struct {
+	Name       string
+	ResultType string //empty if the result is not checked
+	Call       string
+	Codes      string
+	Errors     []*javaClientErrorTest
+}
+
+type javaClientErrorTest struct {
+	Name   string
+	Code   string
+	Status string
+}
+
+func (gen *javaClientGenerator) clientTests() []*javaClientTest {
+	var tests []*javaClientTest
+	for _, r := range gen.schema.Resources {
+		methName, _ := javaMethodName(gen.registry, r)
+		var args []string
+		for _, in := range r.Inputs {
+			if in.Context != "" {
+				continue
+			}
+			if (in.QueryParam != "" || in.Header != "") && (in.Optional || in.Default != nil) {
+				args = append(args, "null")
+			} else {
+				args = append(args, gen.exampleValue(in.Type))
+			}
+		}
+		if len(r.Outputs) > 0 && !streamsEvents(r) {
+			args = append(args, "new java.util.HashMap<>()")
+		}
+		test := &javaClientTest{
+			Name: capitalize(methName),
+			Call: "client." + methName + "(" + strings.Join(args, ", ") + ")",
+		}
+		expected := append([]string{r.Expected}, r.Alternatives...)
+		codes := make([]string, 0, len(expected))
+		mayBeEmpty := false
+		for _, e := range expected {
+			codes = append(codes, rdl.StatusCode(e))
+			if e == "NO_CONTENT" || e == "NOT_MODIFIED" {
+				mayBeEmpty = true
+			}
+		}
+		test.Codes = strings.Join(codes, ", ")
+		if streamsEvents(r) {
+			test.ResultType = "EventStream<" + javaType(gen.registry, r.Type, true, "", "") + ">"
+		} else if streamingResponseType(r) != "" {
+			test.ResultType = "java.io.InputStream"
+		} else if !mayBeEmpty {
+			test.ResultType = javaType(gen.registry, r.Type, false, "", "")
+		}
+		var names []string
+		for code := range r.Exceptions {
+			names = append(names, code)
+		}
+		sort.Strings(names)
+		for _, code := range names {
+			test.Errors = append(test.Errors, &javaClientErrorTest{
+				Name:   javaClientTestSuffix(code),
+				Code:   code,
+				Status: rdl.StatusCode(code),
+			})
+		}
+		tests = append(tests, test)
+	}
+	return tests
+}
+
+// exampleValue returns a Java expression with a value of the type, to send in a test request.
+func (gen *javaClientGenerator) exampleValue(typeRef rdl.TypeRef) string {
+	t := gen.registry.FindType(typeRef)
+	if t == nil {
+		return "null"
+	}
+	jType := javaType(gen.registry, typeRef, true, "", "")
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeString:
+		return "\"example\""
+	case rdl.BaseTypeSymbol:
+		return "Symbol.intern(\"example\")"
+	case rdl.BaseTypeTimestamp:
+		return "Timestamp.fromCurrentTime()"
+	case rdl.BaseTypeUUID:
+		return "UUID.fromCurrentTime()"
+	case rdl.BaseTypeBool:
+		return "true"
+	case rdl.BaseTypeInt8:
+		return "(byte) 1"
+	case rdl.BaseTypeInt16:
+		return "(short) 1"
+	case rdl.BaseTypeInt32:
+		return "1"
+	case rdl.BaseTypeInt64:
+		return "1L"
+	case rdl.BaseTypeFloat32:
+		return "1.0f"
+	case rdl.BaseTypeFloat64:
+		return "1.0"
+	case rdl.BaseTypeBytes:
+		return "new byte[0]"
+	case rdl.BaseTypeArray:
+		return "new java.util.ArrayList<>()"
+	case rdl.BaseTypeMap:
+		return "new java.util.HashMap<>()"
+	case rdl.BaseTypeStruct:
+		return "new " + jType + "()"
+	case rdl.BaseTypeEnum:
+		return jType + ".values()[0]"
+	}
+	return "null"
+}
+
+// javaClientTestSuffix turns a status code name, e.g. NOT_FOUND, into the suffix of the name of
+// its test, e.g. NotFound.
+func javaClientTestSuffix(code string) string {
+	return capitalize(javaCodeMethodName(code))
+}
+
+func (gen *javaClientGenerator) generateClientTests(packageDir string) error {
+	out, file, _, err := outputWriter(packageDir, gen.name, "ClientTest.java")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	data := struct {
+		URL       string
+		Transport bool
+		Tests     []*javaClientTest
+	}{
+		URL:       fmt.Sprintf("http://localhost:4080%s", strings.TrimSuffix(gen.base, "/")),
+		Transport: gen.transport != "",
+		Tests:     gen.clientTests(),
+	}
+	funcMap := template.FuncMap{
+		"header":  func() string { return javaGenerationHeader(gen.banner) },
+		"package": func() string { return javaGenerationPackage(gen.schema, gen.ns) },
+		"cName":   func() string { return capitalize(gen.name) },
+		"defaultTransport": func() string {
+			if gen.transport == "apache" {
+				return "new ApacheHttpTransport()"
+			}
+			return "Transport.urlConnection()"
+		},
+	}
+	t := template.Must(template.New(gen.name + "ClientTest").Funcs(funcMap).Parse(javaEE(javaClientTestTemplate, gen.jakarta)))
+	err = t.Execute(out, data)
+	out.Flush()
+	return err
+}
+
+const javaClientTestTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+{{- if not .Transport}}
+import javax.ws.rs.client.ClientResponseFilter;
+{{- end}}
+import org.junit.jupiter.api.AfterEach;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Disabled;
+import org.junit.jupiter.api.Test;
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertNotNull;
+import static org.junit.jupiter.api.Assertions.assertThrows;
+import static org.junit.jupiter.api.Assertions.fail;
+
+//
+// {{cName}}ClientTest calls each resource through the {{cName}}Client, against the server at the
+// rdl.client.url system property, by default the mock server (rdl generate -x lang=java mock-server).
+// The requests are made of example values: fill in the ones the server expects. The tests of the
+// exceptions are disabled until the server is set up to answer with them.
+//
+public class {{cName}}ClientTest {
+    static final String URL = System.getProperty("rdl.client.url", "{{.URL}}");
+
+    {{cName}}Client client;
+    int status;
+
+    @BeforeEach
+    public void setUp() {
+{{- if .Transport}}
+        Transport transport = {{defaultTransport}};
+        client = new {{cName}}Client(URL, request -> {
+            Transport.Response response = transport.execute(request);
+            status = response.getStatus();
+            return response;
+        });
+{{- else}}
+        client = new {{cName}}Client(URL);
+        client.base.register((ClientResponseFilter) (request, response) -> status = response.getStatus());
+{{- end}}
+    }
+
+    @AfterEach
+    public void tearDown() {
+        client.close();
+    }
+
+    void assertStatus(int... expected) {
+        for (int code : expected) {
+            if (status == code) {
+                return;
+            }
+        }
+        fail("Unexpected status " + status + ", expected " + java.util.Arrays.toString(expected));
+    }
+{{range $t := .Tests}}
+    @Test
+    public void test{{$t.Name}}() {
+        {{if $t.ResultType}}{{$t.ResultType}} result = {{end}}{{$t.Call}};
+        assertStatus({{$t.Codes}});
+{{- if $t.ResultType}}
+        assertNotNull(result);
+{{- end}}
+    }
+{{range $t.Errors}}
+    @Disabled("set up the server to answer with {{.Code}}")
+    @Test
+    public void test{{$t.Name}}{{.Name}}() {
+        ResourceException e = assertThrows(ResourceException.class, () -> {{$t.Call}});
+        assertEquals({{.Status}}, e.getCode());
+    }
+{{end}}{{end}}}
+`
@@ -5,6 +5,7 @@ package main
 
 import (
 	"bufio"
+	"fmt"
 	"github.com/ardielle/ardielle-go/rdl"
 	"log"
 	"strings"
@@ -12,14 +13,17 @@ import (
 )
 
 type javaClientGenerator struct {
-	registry rdl.TypeRegistry
-	schema   *rdl.Schema
-	name     string
-	writer   *bufio.Writer
-	err      error
-	banner   string
-	ns       string
-	base     string
+	registry    rdl.TypeRegistry
+	schema      *rdl.Schema
+	name        string
+	writer      *bufio.Writer
+	err         error
+	banner      string
+	ns          string
+	base        string
+	transport   string
+	jakarta     bool
+	typedErrors bool
 }
 
 // GenerateJavaClient generates the client code to talk to the server
@@ -39,14 +43,119 @@ func GenerateJavaClient(banner string, schema *rdl.Schema, outdir string, ns str
 	if err != nil {
 		return err
 	}
-	gen := &javaClientGenerator{reg, schema, cName, out, nil, banner, ns, base}
-	gen.processTemplate(javaClientTemplate)
+	transport := javaGenerationStringOptionSet(options, "transport")
+	switch transport {
+	case "", "urlconnection", "apache":
+	default:
+		return fmt.Errorf("Unsupported java-client transport: %s", transport)
+	}
+	if anyStreamingResponses(schema) && (transport != "" || javaGenerationBoolOptionSet(options, "async")) {
+		//the transports read the whole body into a String, which is what x_streaming_response avoids
+		return fmt.Errorf("The resources with x_streaming_response need the JAX-RS java-client, without transport or async")
+	}
+	clientTemplate := javaClientTemplate
+	if transport != "" {
+		clientTemplate = javaTransportClientTemplate
+	}
+	gen := &javaClientGenerator{reg, schema, cName, out, nil, banner, ns, base, transport, javaGenerationBoolOptionSet(options, "jakarta"), javaGenerationBoolOptionSet(options, "typed-errors")}
+	gen.processTemplate(clientTemplate)
 	out.Flush()
 	file.Close()
 	if gen.err != nil {
 		return gen.err
 	}
 
+	if transport != "" {
+		//the Transport the client sends its requests on, with the Apache HttpClient one if chosen
+		for _, f := range []struct{ name, src string }{
+			{"Transport", javaTransportTemplate},
+			{"ApacheHttpTransport", javaApacheHttpTransportTemplate},
+		} {
+			if f.name == "ApacheHttpTransport" && transport != "apache" {
+				continue
+			}
+			out, file, _, err = outputWriter(packageDir, f.name, ".java")
+			if err != nil {
+				return err
+			}
+			gen.writer = out
+			gen.err = gen.processTemplate(f.src)
+			out.Flush()
+			file.Close()
+			if gen.err != nil {
+				return gen.err
+			}
+		}
+	}
+
+	if javaGenerationBoolOptionSet(options, "async") {
+		//the async client interface and its implementation, on an AsyncTransport
+		for _, f := range []struct{ name, src string }{
+			{"AsyncTransport", javaAsyncTransportTemplate},
+			{cName + "AsyncClient", javaAsyncClientTemplate},
+			{cName + "AsyncClientImpl", javaAsyncClientImplTemplate},
+		} {
+			out, file, _, err = outputWriter(packageDir, f.name, ".java")
+			if err != nil {
+				return err
+			}
+			gen.writer = out
+			gen.err = gen.processTemplate(f.src)
+			out.Flush()
+			file.Close()
+			if gen.err != nil {
+				return gen.err
+			}
+		}
+	}
+
+	if javaGenerationBoolOptionSet(options, "tests") {
+		//the JUnit tests of the client, see java-client-tests.go
+		if err := gen.generateClientTests(packageDir); err != nil {
+			return err
+		}
+	}
+
+	if anyStreamsEvents(schema) {
+		//EventStream - the iterator over the events of a streaming resource
+		out, file, _, err = outputWriter(packageDir, "EventStream", ".java")
+		if err != nil {
+			return err
+		}
+		gen.writer = out
+		gen.err = gen.processTemplate(javaEventStreamTemplate)
+		out.Flush()
+		file.Close()
+		if gen.err != nil {
+			return gen.err
+		}
+	}
+
+	//CredentialsProvider - the credentials of the requests, see java-client-credentials.go
+	out, file, _, err = outputWriter(packageDir, "CredentialsProvider", ".java")
+	if err != nil {
+		return err
+	}
+	gen.writer = out
+	gen.err = gen.processTemplate(javaCredentialsProviderTemplate)
+	out.Flush()
+	file.Close()
+	if gen.err != nil {
+		return gen.err
+	}
+
+	//the names of the headers, and the typed values of the header outputs, see java-client-headers.go
+	if err := gen.generateClientHeaders(packageDir); err != nil {
+		return err
+	}
+
+	if gen.typedErrors {
+		//the ResourceException of each type of the exceptions, see java-client-errors.go
+		if err := gen.generateTypedExceptions(packageDir); err != nil {
+			return err
+		}
+	}
+
 	//ResourceException - the throawable wrapper for alternate return types
 	out, file, _, err = outputWriter(packageDir, "ResourceException", ".java")
 	if err != nil {
@@ -75,16 +184,29 @@ func (gen *javaClientGenerator) processTemplate(templateSource string) error {
 		return formatComment(s, 0, 80)
 	}
 	funcMap := template.FuncMap{
-		"header":     func() string { return javaGenerationHeader(gen.banner) },
-		"package":    func() string { return javaGenerationPackage(gen.schema, gen.ns) },
-		"comment":    commentFun,
-		"methodSig":  func(r *rdl.Resource) string { return gen.clientMethodSignature(r) },
-		"methodBody": func(r *rdl.Resource) string { return gen.clientMethodBody(r) },
-		"name":       func() string { return gen.name },
-		"cName":      func() string { return capitalize(gen.name) },
-		"lName":      func() string { return uncapitalize(gen.name) },
-	}
-	t := template.Must(template.New(gen.name).Funcs(funcMap).Parse(templateSource))
+		"header":        func() string { return javaGenerationHeader(gen.banner) },
+		"package":       func() string { return javaGenerationPackage(gen.schema, gen.ns) },
+		"comment":       commentFun,
+		"methodSig":     func(r *rdl.Resource) string { return gen.clientMethodSignature(r) },
+		"methodBody":    func(r *rdl.Resource) string { return gen.clientMethodBody(r) },
+		"asyncSig":      func(r *rdl.Resource) string { return gen.asyncMethodSignature(r) },
+		"asyncBody":     func(r *rdl.Resource) string { return gen.transportMethodBody(r, true) },
+		"transportBody": func(r *rdl.Resource) string { return gen.transportMethodBody(r, false) },
+		"deprecated":    func(r *rdl.Resource) string { return javaDeprecated(r.Annotations, "    ") },
+		"defaultTransport": func(sslContext string) string {
+			if gen.transport == "apache" {
+				return "new ApacheHttpTransport(" + sslContext + ")"
+			}
+			if sslContext != "" {
+				return "Transport.urlConnection(null, " + sslContext + ")"
+			}
+			return "Transport.urlConnection()"
+		},
+		"name":  func() string { return gen.name },
+		"cName": func() string { return capitalize(gen.name) },
+		"lName": func() string { return uncapitalize(gen.name) },
+	}
+	t := template.Must(template.New(gen.name).Funcs(funcMap).Parse(javaEE(templateSource, gen.jakarta)))
 	return t.Execute(gen.writer, gen.schema)
 }
 
@@ -103,13 +225,14 @@ import com.yahoo.rdl.*;
 import javax.ws.rs.client.*;
 import javax.ws.rs.*;
 import javax.ws.rs.core.*;
+import java.util.Map;
+import java.util.function.Function;
 import javax.net.ssl.HostnameVerifier;
 
 public class {{cName}}Client {
     Client client;
     WebTarget base;
-    String credsHeader;
-    String credsToken;
+    CredentialsProvider credentials;
 
     public {{cName}}Client(String url) {
         client = ClientBuilder.newClient();
@@ -123,6 +246,16 @@ public class {{cName}}Client {
         base = client.target(url);
     }
 
+    public {{cName}}Client(String url, CredentialsProvider credentials) {
+        ClientBuilder builder = ClientBuilder.newBuilder();
+        if (credentials.sslContext() != null) {
+            builder = builder.sslContext(credentials.sslContext());
+        }
+        client = builder.build();
+        base = client.target(url);
+        this.credentials = credentials;
+    }
+
     public void close() {
         client.close();
     }
@@ -133,18 +266,396 @@ public class {{cName}}Client {
     }
 
     public {{cName}}Client addCredentials(String header, String token) {
-        credsHeader = header;
-        credsToken = token;
+        return setCredentials(CredentialsProvider.header(header, token));
+    }
+
+    public {{cName}}Client setCredentials(CredentialsProvider credentials) {
+        this.credentials = credentials;
         return this;
     }
+
+    // authorized sends the request with the credentials, and once more with refreshed ones if
+    // they are rejected.
+    Response authorized(Invocation.Builder invocationBuilder, Function<Invocation.Builder, Response> send) {
+        if (credentials == null) {
+            return send.apply(invocationBuilder);
+        }
+        Map<String, String> creds = credentials.credentials();
+        for (Map.Entry<String, String> e : creds.entrySet()) {
+            invocationBuilder = invocationBuilder.header(e.getKey(), e.getValue());
+        }
+        Response response = send.apply(invocationBuilder);
+        if (response.getStatus() == 401 && credentials.refresh()) {
+            response.close();
+            for (String name : creds.keySet()) {
+                invocationBuilder = invocationBuilder.header(name, null); //removes it
+            }
+            for (Map.Entry<String, String> e : credentials.credentials().entrySet()) {
+                invocationBuilder = invocationBuilder.header(e.getKey(), e.getValue());
+            }
+            response = send.apply(invocationBuilder);
+        }
+        return response;
+    }
 {{range .Resources}}
-    {{methodSig .}} {
+{{deprecated .}}    {{methodSig .}} {
         {{methodBody .}}
     }
 {{end}}
 }
 `
 
+const javaTransportClientTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+import java.io.IOException;
+import java.io.UncheckedIOException;
+import java.net.URLEncoder;
+import java.nio.charset.StandardCharsets;
+import java.util.HashMap;
+import java.util.Map;
+
+public class {{cName}}Client {
+    Transport transport;
+    String base;
+    CredentialsProvider credentials;
+
+    public {{cName}}Client(String url) {
+        this(url, {{defaultTransport ""}});
+    }
+
+    public {{cName}}Client(String url, CredentialsProvider credentials) {
+        this(url, {{defaultTransport "credentials.sslContext()"}});
+        this.credentials = credentials;
+    }
+
+    public {{cName}}Client(String url, Transport transport) {
+        this.transport = transport;
+        this.base = url.endsWith("/") ? url.substring(0, url.length() - 1) : url;
+    }
+
+    public void close() {
+        try {
+            transport.close();
+        } catch (IOException e) {
+            throw new UncheckedIOException(e);
+        }
+    }
+
+    public {{cName}}Client addCredentials(String header, String token) {
+        return setCredentials(CredentialsProvider.header(header, token));
+    }
+
+    public {{cName}}Client setCredentials(CredentialsProvider credentials) {
+        this.credentials = credentials;
+        return this;
+    }
+
+    static String encode(Object value) {
+        return URLEncoder.encode(String.valueOf(value), StandardCharsets.UTF_8).replace("+", "%20");
+    }
+
+    static ResourceException error(int code, String body) {
+        if (body == null || body.isEmpty()) {
+            return new ResourceException(code);
+        }
+        return new ResourceException(code, JSON.fromString(body, ResourceError.class));
+    }
+
+    Transport.Response execute(String method, String url, Map<String, String> headers, String body) {
+        try {
+            return transport.execute(new Transport.Request(method, url, headers, body));
+        } catch (IOException e) {
+            throw new UncheckedIOException(e);
+        }
+    }
+
+    // authorized sends the request with the credentials, and once more with refreshed ones if
+    // they are rejected.
+    Transport.Response authorized(String method, String url, Map<String, String> headers, String body) {
+        if (credentials == null) {
+            return execute(method, url, headers, body);
+        }
+        Map<String, String> h = new HashMap<>(headers);
+        h.putAll(credentials.credentials());
+        Transport.Response response = execute(method, url, h, body);
+        if (response.getStatus() == 401 && credentials.refresh()) {
+            h = new HashMap<>(headers);
+            h.putAll(credentials.credentials());
+            response = execute(method, url, h, body);
+        }
+        return response;
+    }
+{{range .Resources}}
+{{deprecated .}}    {{methodSig .}} {
+{{transportBody .}}    }
+{{end}}
+}
+`
+
+const javaTransportTemplate = `{{header}}
+package {{package}};
+import java.io.Closeable;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.InterruptedIOException;
+import java.io.OutputStream;
+import java.net.HttpURLConnection;
+import java.net.URL;
+import java.nio.charset.StandardCharsets;
+import java.util.Collections;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.function.Supplier;
+import javax.net.ssl.HostnameVerifier;
+import javax.net.ssl.HttpsURLConnection;
+import javax.net.ssl.SSLContext;
+
+//
+// Transport sends the HTTP requests of a client. The default transport is built on
+// HttpURLConnection, other HTTP clients (e.g. OkHttp) can be adapted by implementing execute.
+// withHeader and withRetries wrap a transport to add auth headers and retries.
+//
+public interface Transport extends Closeable {
+
+    Response execute(Request request) throws IOException;
+
+    @Override
+    default void close() throws IOException {
+    }
+
+    final class Request {
+        private final String method;
+        private final String url;
+        private final Map<String, String> headers;
+        private final String body;
+
+        public Request(String method, String url, Map<String, String> headers, String body) {
+            this.method = method;
+            this.url = url;
+            this.headers = headers == null ? Collections.emptyMap() : headers;
+            this.body = body;
+        }
+
+        public String getMethod() {
+            return method;
+        }
+
+        public String getUrl() {
+            return url;
+        }
+
+        public Map<String, String> getHeaders() {
+            return headers;
+        }
+
+        public String getBody() {
+            return body;
+        }
+
+        public Request withHeader(String name, String value) {
+            Map<String, String> h = new HashMap<>(headers);
+            h.put(name, value);
+            return new Request(method, url, h, body);
+        }
+    }
+
+    final class Response {
+        private final int status;
+        private final Map<String, List<String>> headers;
+        private final String body;
+
+        public Response(int status, Map<String, List<String>> headers, String body) {
+            this.status = status;
+            this.headers = headers == null ? Collections.emptyMap() : headers;
+            this.body = body;
+        }
+
+        public int getStatus() {
+            return status;
+        }
+
+        public String getBody() {
+            return body;
+        }
+
+        public String getHeader(String name) {
+            for (Map.Entry<String, List<String>> e : headers.entrySet()) {
+                if (e.getKey() != null && e.getKey().equalsIgnoreCase(name) && !e.getValue().isEmpty()) {
+                    return e.getValue().get(0);
+                }
+            }
+            return null;
+        }
+    }
+
+    // withHeader adds the header to every request, with the value current at the time, e.g. a
+    // token that is refreshed as it expires. A null value leaves the request as it is.
+    default Transport withHeader(String name, Supplier<String> value) {
+        Transport next = this;
+        return new Transport() {
+            @Override
+            public Response execute(Request request) throws IOException {
+                String v = value.get();
+                return next.execute(v == null ? request : request.withHeader(name, v));
+            }
+
+            @Override
+            public void close() throws IOException {
+                next.close();
+            }
+        };
+    }
+
+    // withRetries retries requests other than POST and PATCH that fail with an IOException or a
+    // 502, 503, or 504 status, making up to maxAttempts in all, and doubling the delay each time.
+    default Transport withRetries(int maxAttempts, long delayMillis) {
+        Transport next = this;
+        return new Transport() {
+            @Override
+            public Response execute(Request request) throws IOException {
+                boolean idempotent = !"POST".equals(request.getMethod()) && !"PATCH".equals(request.getMethod());
+                long delay = delayMillis;
+                for (int attempt = 1; ; attempt++) {
+                    boolean last = !idempotent || attempt >= maxAttempts;
+                    try {
+                        Response response = next.execute(request);
+                        int status = response.getStatus();
+                        if (last || (status != 502 && status != 503 && status != 504)) {
+                            return response;
+                        }
+                    } catch (IOException e) {
+                        if (last) {
+                            throw e;
+                        }
+                    }
+                    try {
+                        Thread.sleep(delay);
+                    } catch (InterruptedException e) {
+                        Thread.currentThread().interrupt();
+                        throw new InterruptedIOException("Interrupted while retrying " + request.getUrl());
+                    }
+                    delay *= 2;
+                }
+            }
+
+            @Override
+            public void close() throws IOException {
+                next.close();
+            }
+        };
+    }
+
+    static Transport urlConnection() {
+        return urlConnection(null);
+    }
+
+    static Transport urlConnection(HostnameVerifier hostnameVerifier) {
+        return urlConnection(hostnameVerifier, null);
+    }
+
+    // the SSL context, if not null, is that of the HTTPS connections, e.g. for mutual TLS
+    static Transport urlConnection(HostnameVerifier hostnameVerifier, SSLContext sslContext) {
+        return request -> {
+            HttpURLConnection conn = (HttpURLConnection) new URL(request.getUrl()).openConnection();
+            if (hostnameVerifier != null && conn instanceof HttpsURLConnection) {
+                ((HttpsURLConnection) conn).setHostnameVerifier(hostnameVerifier);
+            }
+            if (sslContext != null && conn instanceof HttpsURLConnection) {
+                ((HttpsURLConnection) conn).setSSLSocketFactory(sslContext.getSocketFactory());
+            }
+            if ("PATCH".equals(request.getMethod())) {
+                //HttpURLConnection does not support PATCH
+                conn.setRequestMethod("POST");
+                conn.setRequestProperty("X-HTTP-Method-Override", "PATCH");
+            } else {
+                conn.setRequestMethod(request.getMethod());
+            }
+            request.getHeaders().forEach(conn::setRequestProperty);
+            if (request.getBody() != null) {
+                conn.setDoOutput(true);
+                conn.setRequestProperty("Content-Type", "application/json");
+                try (OutputStream out = conn.getOutputStream()) {
+                    out.write(request.getBody().getBytes(StandardCharsets.UTF_8));
+                }
+            }
+            int status = conn.getResponseCode();
+            String body = null;
+            try (InputStream in = status >= 400 ? conn.getErrorStream() : conn.getInputStream()) {
+                if (in != null) {
+                    body = new String(in.readAllBytes(), StandardCharsets.UTF_8);
+                }
+            }
+            return new Response(status, conn.getHeaderFields(), body);
+        };
+    }
+}
+`
+
+const javaApacheHttpTransportTemplate = `{{header}}
+package {{package}};
+import java.io.IOException;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+import javax.net.ssl.SSLContext;
+import org.apache.http.Header;
+import org.apache.http.HttpEntity;
+import org.apache.http.client.methods.CloseableHttpResponse;
+import org.apache.http.client.methods.RequestBuilder;
+import org.apache.http.entity.ContentType;
+import org.apache.http.entity.StringEntity;
+import org.apache.http.impl.client.CloseableHttpClient;
+import org.apache.http.impl.client.HttpClients;
+import org.apache.http.util.EntityUtils;
+
+//
+// ApacheHttpTransport sends the requests of a client with Apache HttpClient 4.
+//
+public class ApacheHttpTransport implements Transport {
+    private final CloseableHttpClient client;
+
+    public ApacheHttpTransport() {
+        this(HttpClients.createDefault());
+    }
+
+    // the SSL context, if not null, is that of the HTTPS connections, e.g. for mutual TLS
+    public ApacheHttpTransport(SSLContext sslContext) {
+        this(sslContext == null ? HttpClients.createDefault() : HttpClients.custom().setSSLContext(sslContext).build());
+    }
+
+    public ApacheHttpTransport(CloseableHttpClient client) {
+        this.client = client;
+    }
+
+    @Override
+    public Response execute(Request request) throws IOException {
+        RequestBuilder builder = RequestBuilder.create(request.getMethod()).setUri(request.getUrl());
+        request.getHeaders().forEach(builder::addHeader);
+        if (request.getBody() != null) {
+            builder.setEntity(new StringEntity(request.getBody(), ContentType.APPLICATION_JSON));
+        }
+        try (CloseableHttpResponse response = client.execute(builder.build())) {
+            Map<String, List<String>> headers = new HashMap<>();
+            for (Header h : response.getAllHeaders()) {
+                headers.computeIfAbsent(h.getName(), k -> new ArrayList<>()).add(h.getValue());
+            }
+            HttpEntity entity = response.getEntity();
+            String body = entity == null ? null : EntityUtils.toString(entity, StandardCharsets.UTF_8);
+            return new Response(response.getStatusLine().getStatusCode(), headers, body);
+        }
+    }
+
+    @Override
+    public void close() throws IOException {
+        client.close();
+    }
+}
+`
+
 func (gen *javaClientGenerator) clientMethodSignature(r *rdl.Resource) string {
 	reg := gen.registry
 	returnType := javaType(reg, r.Type, false, "", "")
@@ -153,6 +664,13 @@ func (gen *javaClientGenerator) clientMethodSignature(r *rdl.Resource) string {
 	if len(params) > 0 {
 		sparams = strings.Join(params, ", ")
 	}
+	if streamsEvents(r) {
+		return "public EventStream<" + javaType(reg, r.Type, true, "", "") + "> " + methName + "(" + sparams + ")"
+	}
+	if streamingResponseType(r) != "" {
+		//the caller reads the response body, and closes it
+		returnType = "java.io.InputStream"
+	}
 	if len(r.Outputs) > 0 {
 		if sparams == "" {
 			sparams = "java.util.Map<String,java.util.List<String>> headers"
@@ -191,12 +709,17 @@ func (gen *javaClientGenerator) clientMethodBody(r *rdl.Resource) string {
 	if q != "" {
 		s += q
 	}
-	s += "\n        Invocation.Builder invocationBuilder = target.request(\"application/json\");"
+	if streamsEvents(r) {
+		s += "\n        Invocation.Builder invocationBuilder = target.request(\"text/event-stream\", \"application/json\");"
+	} else if ct := streamingResponseType(r); ct != "" {
+		s += "\n        Invocation.Builder invocationBuilder = target.request(\"" + ct + "\", \"application/json\");"
+	} else {
+		s += "\n        Invocation.Builder invocationBuilder = target.request(\"application/json\");"
+	}
+	authorized := false
 	if r.Auth != nil {
 		if r.Auth.Authenticate || (r.Auth.Action != "" && r.Auth.Resource != "") {
-			s += "\n        if (credsHeader != null) {"
-			s += "\n            invocationBuilder = invocationBuilder.header(credsHeader, credsToken);"
-			s += "\n        }"
+			authorized = true
 		} else {
 			log.Println("*** Badly formed auth spec in resource input:", r)
 		}
@@ -205,12 +728,28 @@ func (gen *javaClientGenerator) clientMethodBody(r *rdl.Resource) string {
 		s += h
 	}
 	s += "\n"
+	builder := "invocationBuilder"
+	if authorized {
+		//sent with the credentials, and sent again if they are refreshed
+		builder = "b"
+		s += "        Response response = authorized(invocationBuilder, b -> "
+	} else {
+		s += "        Response response = "
+	}
 	switch r.Method {
 	case "PUT", "POST":
-		s += "        Response response = invocationBuilder." + strings.ToLower(r.Method) + "(javax.ws.rs.client.Entity.entity(" + entityName + ", \"application/json\"));\n"
+		s += builder + "." + strings.ToLower(r.Method) + "(" + javaEE("javax.ws.rs.client.Entity", gen.jakarta) + ".entity(" + entityName + ", \"application/json\"))"
+	case "PATCH":
+		//JAX-RS has no patch(), and the default connector of Jersey refuses PATCH without its workaround
+		s += builder + ".property(\"jersey.config.client.httpUrlConnection.setMethodWorkaround\", true)\n"
+		s += "            .method(\"PATCH\", " + javaEE("javax.ws.rs.client.Entity", gen.jakarta) + ".entity(" + entityName + ", \"application/json\"))"
 	default:
-		s += "        Response response = invocationBuilder." + strings.ToLower(r.Method) + "();\n"
+		s += builder + "." + strings.ToLower(r.Method) + "()"
+	}
+	if authorized {
+		s += ")"
 	}
+	s += ";\n"
 	s += "        int code = response.getStatus();\n"
 	s += "        switch (code) {\n"
 
@@ -232,14 +771,20 @@ func (gen *javaClientGenerator) clientMethodBody(r *rdl.Resource) string {
 	for _, expCode := range expected {
 		s += "        case " + expCode + ":\n"
 	}
-	if len(r.Outputs) > 0 {
+	if len(r.Outputs) > 0 && !streamsEvents(r) {
 		s += "            if (headers != null) {\n"
 		for _, out := range r.Outputs {
 			s += "                headers.put(\"" + string(out.Name) + "\", java.util.Arrays.asList((String)response.getHeaders().getFirst(\"" + out.Header + "\")));\n"
 		}
 		s += "            }\n"
 	}
-	if noContent {
+	if streamsEvents(r) {
+		//the events are read from the response as the caller iterates
+		s += "            return new EventStream<>(response.readEntity(java.io.InputStream.class), " + javaType(reg, r.Type, true, "", "") + ".class);\n"
+	} else if streamingResponseType(r) != "" {
+		//read as the caller reads it, closing the input stream releases the connection
+		s += "            return response.readEntity(java.io.InputStream.class);\n"
+	} else if noContent {
 		s += "            return null;\n"
 	} else {
 		if couldBeNoContent || couldBeNotModified {
@@ -249,6 +794,9 @@ func (gen *javaClientGenerator) clientMethodBody(r *rdl.Resource) string {
 		}
 		s += "            return response.readEntity(" + returnType + ".class);\n"
 	}
+	if gen.typedErrors {
+		s += gen.typedExceptionCases(r, "        ", false)
+	}
 	s += "        default:\n"
 	if r.Exceptions != nil {
 		s += "            throw new ResourceException(code, response.readEntity(ResourceError.class));\n"
@@ -270,3 +818,394 @@ func (gen *javaClientGenerator) responseCondition(noContent, notModified bool) s
 	}
 	return s
 }
+
+const javaAsyncTransportTemplate = `{{header}}
+package {{package}};
+import java.net.URI;
+import java.net.http.HttpClient;
+import java.net.http.HttpRequest;
+import java.net.http.HttpResponse;
+import java.util.Collections;
+import java.util.List;
+import java.util.Map;
+import java.util.concurrent.CompletableFuture;
+
+//
+// AsyncTransport sends the HTTP requests of an async client. The default transport
+// is built on java.net.http.HttpClient, other HTTP clients can be adapted to it.
+//
+public interface AsyncTransport {
+
+    CompletableFuture<Response> send(String method, String url, Map<String, String> headers, String body);
+
+    final class Response {
+        private final int status;
+        private final Map<String, List<String>> headers;
+        private final String body;
+
+        public Response(int status, Map<String, List<String>> headers, String body) {
+            this.status = status;
+            this.headers = headers == null ? Collections.emptyMap() : headers;
+            this.body = body;
+        }
+
+        public int getStatus() {
+            return status;
+        }
+
+        public String getBody() {
+            return body;
+        }
+
+        public String getHeader(String name) {
+            for (Map.Entry<String, List<String>> e : headers.entrySet()) {
+                if (e.getKey() != null && e.getKey().equalsIgnoreCase(name) && !e.getValue().isEmpty()) {
+                    return e.getValue().get(0);
+                }
+            }
+            return null;
+        }
+    }
+
+    static AsyncTransport httpClient() {
+        return httpClient(HttpClient.newHttpClient());
+    }
+
+    static AsyncTransport httpClient(HttpClient client) {
+        return (method, url, headers, body) -> {
+            HttpRequest.Builder request = HttpRequest.newBuilder(URI.create(url))
+                .method(method, body == null ? HttpRequest.BodyPublishers.noBody() : HttpRequest.BodyPublishers.ofString(body));
+            headers.forEach(request::header);
+            if (body != null) {
+                request.header("Content-Type", "application/json");
+            }
+            return client.sendAsync(request.build(), HttpResponse.BodyHandlers.ofString())
+                .thenApply(response -> new Response(response.statusCode(), response.headers().map(), response.body()));
+        };
+    }
+}
+`
+
+const javaAsyncClientTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+import java.util.List;
+import java.util.Map;
+import java.util.concurrent.CompletableFuture;
+
+public interface {{cName}}AsyncClient {
+
+    {{cName}}AsyncClient addCredentials(String header, String token);
+
+    {{cName}}AsyncClient setCredentials(CredentialsProvider credentials);
+{{range .Resources}}
+{{deprecated .}}    {{asyncSig .}};
+{{end}}
+}
+`
+
+const javaAsyncClientImplTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+import java.net.URLEncoder;
+import java.net.http.HttpClient;
+import java.nio.charset.StandardCharsets;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.concurrent.CompletableFuture;
+
+public class {{cName}}AsyncClientImpl implements {{cName}}AsyncClient {
+    AsyncTransport transport;
+    String base;
+    CredentialsProvider credentials;
+
+    public {{cName}}AsyncClientImpl(String url) {
+        this(url, AsyncTransport.httpClient());
+    }
+
+    public {{cName}}AsyncClientImpl(String url, CredentialsProvider credentials) {
+        this(url, credentials.sslContext() == null ? AsyncTransport.httpClient()
+            : AsyncTransport.httpClient(HttpClient.newBuilder().sslContext(credentials.sslContext()).build()));
+        this.credentials = credentials;
+    }
+
+    public {{cName}}AsyncClientImpl(String url, AsyncTransport transport) {
+        this.transport = transport;
+        this.base = url.endsWith("/") ? url.substring(0, url.length() - 1) : url;
+    }
+
+    @Override
+    public {{cName}}AsyncClient addCredentials(String header, String token) {
+        return setCredentials(CredentialsProvider.header(header, token));
+    }
+
+    @Override
+    public {{cName}}AsyncClient setCredentials(CredentialsProvider credentials) {
+        this.credentials = credentials;
+        return this;
+    }
+
+    static String encode(Object value) {
+        return URLEncoder.encode(String.valueOf(value), StandardCharsets.UTF_8).replace("+", "%20");
+    }
+
+    static ResourceException error(int code, String body) {
+        if (body == null || body.isEmpty()) {
+            return new ResourceException(code);
+        }
+        return new ResourceException(code, JSON.fromString(body, ResourceError.class));
+    }
+
+    // authorized sends the request with the credentials, and once more with refreshed ones if
+    // they are rejected. The credentials are asked for on the calling thread, and those of the
+    // retry on the one completing the first response.
+    CompletableFuture<AsyncTransport.Response> authorized(String method, String url, Map<String, String> headers, String body) {
+        if (credentials == null) {
+            return transport.send(method, url, headers, body);
+        }
+        Map<String, String> h = new HashMap<>(headers);
+        h.putAll(credentials.credentials());
+        return transport.send(method, url, h, body).thenCompose(response -> {
+            if (response.getStatus() == 401 && credentials.refresh()) {
+                Map<String, String> retry = new HashMap<>(headers);
+                retry.putAll(credentials.credentials());
+                return transport.send(method, url, retry, body);
+            }
+            return CompletableFuture.completedFuture(response);
+        });
+    }
+{{range .Resources}}
+    @Override
+{{deprecated .}}    public {{asyncSig .}} {
+{{asyncBody .}}    }
+{{end}}
+}
+`
+
+const javaEventStreamTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+import java.io.BufferedReader;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.InputStreamReader;
+import java.io.Reader;
+import java.io.UncheckedIOException;
+import java.nio.charset.StandardCharsets;
+import java.util.Iterator;
+import java.util.NoSuchElementException;
+
+//
+// EventStream iterates over the server-sent events of a streaming resource, decoding the
+// data of each event as JSON. The JAX-RS client reads the events as they arrive, the
+// Transport clients only once the whole response is in. Close it if not read to the end.
+//
+public class EventStream<T> implements Iterator<T>, AutoCloseable {
+    private final BufferedReader reader;
+    private final Class<T> type;
+    private T next;
+    private boolean done;
+
+    public EventStream(InputStream in, Class<T> type) {
+        this(new InputStreamReader(in, StandardCharsets.UTF_8), type);
+    }
+
+    public EventStream(Reader in, Class<T> type) {
+        this.reader = new BufferedReader(in);
+        this.type = type;
+    }
+
+    @Override
+    public boolean hasNext() {
+        if (next == null && !done) {
+            next = read();
+        }
+        return next != null;
+    }
+
+    @Override
+    public T next() {
+        if (!hasNext()) {
+            throw new NoSuchElementException();
+        }
+        T item = next;
+        next = null;
+        return item;
+    }
+
+    @Override
+    public void close() {
+        done = true;
+        try {
+            reader.close();
+        } catch (IOException e) {
+            //nothing more to read either way
+        }
+    }
+
+    //read the next event with data, joining its data lines. Comments and other fields are skipped.
+    private T read() {
+        try {
+            StringBuilder data = null;
+            for (String line = reader.readLine(); line != null; line = reader.readLine()) {
+                if (line.isEmpty()) {
+                    if (data != null) {
+                        return JSON.fromString(data.toString(), type);
+                    }
+                } else if (line.startsWith("data:")) {
+                    String value = line.substring(5);
+                    if (value.startsWith(" ")) {
+                        value = value.substring(1);
+                    }
+                    if (data == null) {
+                        data = new StringBuilder(value);
+                    } else {
+                        data.append('\n').append(value);
+                    }
+                }
+            }
+            done = true;
+            return data == null ? null : JSON.fromString(data.toString(), type);
+        } catch (IOException e) {
+            done = true;
+            throw new UncheckedIOException(e);
+        }
+    }
+}
+`
+
+// asyncMethodSignature is the signature of the client method, without modifiers. It takes
+// the same parameters as the blocking client, and returns a future of the boxed result type.
+func (gen *javaClientGenerator) asyncMethodSignature(r *rdl.Resource) string {
+	returnType := javaType(gen.registry, r.Type, true, "", "")
+	methName, params := javaMethodName(gen.registry, r)
+	if streamsEvents(r) {
+		returnType = "EventStream<" + returnType + ">"
+	} else if len(r.Outputs) > 0 {
+		params = append(params, "Map<String,List<String>> headers")
+	}
+	return "CompletableFuture<" + returnType + "> " + methName + "(" + strings.Join(params, ", ") + ")"
+}
+
+// transportMethodBody is the body of a client method sending its request on a Transport, or on an
+// AsyncTransport, returning a future of the result.
+func (gen *javaClientGenerator) transportMethodBody(r *rdl.Resource, async bool) string {
+	returnType := javaType(gen.registry, r.Type, true, "", "")
+	vars := make(map[string]string)
+	for _, in := range r.Inputs {
+		vars[string(in.Name)] = javaName(in.Name)
+	}
+	//the path template, with the path params encoded in place
+	path := gen.resourcePath(r)
+	s := "        StringBuilder url = new StringBuilder(base)"
+	for path != "" {
+		i := strings.Index(path, "{")
+		j := strings.Index(path, "}")
+		if i < 0 || j < i {
+			s += ".append(\"" + path + "\")"
+			break
+		}
+		if i > 0 {
+			s += ".append(\"" + path[:i] + "\")"
+		}
+		s += ".append(encode(" + vars[path[i+1:j]] + "))"
+		path = path[j+1:]
+	}
+	s += ";\n"
+	q := ""
+	h := ""
+	body := "null"
+	for _, in := range r.Inputs {
+		iname := javaName(in.Name)
+		if in.PathParam {
+			continue
+		} else if in.QueryParam != "" {
+			q += "        if (" + iname + " != null) {\n"
+			q += "            url.append(sep).append(\"" + in.QueryParam + "=\").append(encode(" + iname + "));\n"
+			q += "            sep = \"&\";\n"
+			q += "        }\n"
+		} else if in.Header != "" {
+			h += "        if (" + iname + " != null) {\n"
+			h += "            requestHeaders.put(\"" + in.Header + "\", String.valueOf(" + iname + "));\n"
+			h += "        }\n"
+		} else if r.Method == "PUT" || r.Method == "POST" || r.Method == "PATCH" { //the entity
+			body = "JSON.string(" + iname + ")"
+		}
+	}
+	if q != "" {
+		s += "        String sep = \"?\";\n" + q
+	}
+	s += "        Map<String, String> requestHeaders = new HashMap<>();\n"
+	if streamsEvents(r) {
+		s += "        requestHeaders.put(\"Accept\", \"text/event-stream, application/json\");\n"
+	} else {
+		s += "        requestHeaders.put(\"Accept\", \"application/json\");\n"
+	}
+	s += h
+	//the requests to resources that authenticate or authorize are sent with the credentials
+	send := "execute"
+	if async {
+		send = "transport.send"
+	}
+	if r.Auth != nil && (r.Auth.Authenticate || (r.Auth.Action != "" && r.Auth.Resource != "")) {
+		send = "authorized"
+	}
+	indent := "        "
+	if async {
+		s += "        return " + send + "(\"" + r.Method + "\", url.toString(), requestHeaders, " + body + ").thenApply(response -> {\n"
+		indent += "    "
+	} else {
+		s += "        Transport.Response response = " + send + "(\"" + r.Method + "\", url.toString(), requestHeaders, " + body + ");\n"
+	}
+	s += indent + "int code = response.getStatus();\n"
+	s += indent + "switch (code) {\n"
+
+	//same handling of the expected results as the blocking client
+	expected := []string{rdl.StatusCode(r.Expected)}
+	couldBeNoContent := "NO_CONTENT" == r.Expected
+	couldBeNotModified := "NOT_MODIFIED" == r.Expected
+	noContent := couldBeNoContent && r.Alternatives == nil
+	for _, e := range r.Alternatives {
+		if "NO_CONTENT" == e {
+			couldBeNoContent = true
+		}
+		if "NOT_MODIFIED" == e {
+			couldBeNotModified = true
+		}
+		expected = append(expected, rdl.StatusCode(e))
+	}
+	for _, expCode := range expected {
+		s += indent + "case " + expCode + ":\n"
+	}
+	if len(r.Outputs) > 0 && !streamsEvents(r) {
+		s += indent + "    if (headers != null) {\n"
+		for _, out := range r.Outputs {
+			s += indent + "        headers.put(\"" + string(out.Name) + "\", java.util.Arrays.asList(response.getHeader(\"" + out.Header + "\")));\n"
+		}
+		s += indent + "    }\n"
+	}
+	if streamsEvents(r) {
+		//the transports read the whole response body, so the events are all there by now
+		s += indent + "    return new EventStream<>(new java.io.StringReader(response.getBody()), " + returnType + ".class);\n"
+	} else if noContent {
+		s += indent + "    return null;\n"
+	} else {
+		if couldBeNoContent || couldBeNotModified {
+			s += indent + "    if (" + gen.responseCondition(couldBeNoContent, couldBeNotModified) + ") {\n"
+			s += indent + "        return null;\n"
+			s += indent + "    }\n"
+		}
+		s += indent + "    return JSON.fromString(response.getBody(), " + returnType + ".class);\n"
+	}
+	if gen.typedErrors {
+		s += gen.typedExceptionCases(r, indent, true)
+	}
+	s += indent + "default:\n"
+	s += indent + "    throw error(code, response.getBody());\n"
+	s += indent + "}\n"
+	if async {
+		s += "        });\n"
+	}
+	return s
+}
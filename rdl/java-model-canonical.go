@@ -0,0 +1,186 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"text/template"
+)
+
+//
+// Canonical JSON for java-model (-x canonical=true). Structs get a canonicalJSON method returning
+// the UTF-8 bytes of their JSON in the canonical form the CanonicalJSON method of go-model writes,
+// to sign or hash. The generated FooCanonical class writes it from the generic JSON values of the
+// struct, those Jackson reads back from its JSON, or with -x jackson=false, those of toJSONValue.
+//
+
+const javaModelCanonicalTemplate = `{{header}}
+package {{package}};
+import java.math.BigDecimal;
+import java.math.BigInteger;
+import java.nio.charset.StandardCharsets;
+import java.util.Map;
+import java.util.TreeMap;
+
+//
+// {{cName}} - writes the canonical JSON of the {{name}} types, to sign or hash: the keys of the
+// objects sorted by their UTF-16 code units, no whitespace, strings escaping only the quote, the
+// backslash, and the control characters, integers as they are, and other numbers in the shortest
+// form of their double value, as ECMAScript writes them, i.e. RFC 8785 but for the integers.
+//
+public final class {{cName}} {
+
+    private {{cName}}() {
+    }
+
+    public static byte[] write(Object value) {
+        StringBuilder sb = new StringBuilder();
+        writeValue(sb, value);
+        return sb.toString().getBytes(StandardCharsets.UTF_8);
+    }
+
+    static void writeValue(StringBuilder sb, Object value) {
+        if (value == null) {
+            sb.append("null");
+        } else if (value instanceof Boolean) {
+            sb.append(value);
+        } else if (value instanceof Number) {
+            writeNumber(sb, (Number) value);
+        } else if (value instanceof Map) {
+            Map<String, Object> sorted = new TreeMap<String, Object>();
+            for (Map.Entry<?, ?> e : ((Map<?, ?>) value).entrySet()) {
+                sorted.put(String.valueOf(e.getKey()), e.getValue());
+            }
+            sb.append('{');
+            String sep = "";
+            for (Map.Entry<String, Object> e : sorted.entrySet()) {
+                sb.append(sep);
+                writeString(sb, e.getKey());
+                sb.append(':');
+                writeValue(sb, e.getValue());
+                sep = ",";
+            }
+            sb.append('}');
+        } else if (value instanceof Iterable) {
+            sb.append('[');
+            String sep = "";
+            for (Object item : (Iterable<?>) value) {
+                sb.append(sep);
+                writeValue(sb, item);
+                sep = ",";
+            }
+            sb.append(']');
+        } else {
+            writeString(sb, value.toString());
+        }
+    }
+
+    static void writeNumber(StringBuilder sb, Number n) {
+        if (n instanceof Byte || n instanceof Short || n instanceof Integer || n instanceof Long || n instanceof BigInteger) {
+            sb.append(n);
+            return;
+        }
+        // a float by its decimal string, the one its JSON has
+        double d = Double.parseDouble(n.toString());
+        if (Double.isNaN(d) || Double.isInfinite(d)) {
+            throw new IllegalArgumentException("Cannot write " + n + " as JSON");
+        }
+        if (d == 0) {
+            sb.append('0');
+            return;
+        }
+        BigDecimal b = new BigDecimal(Double.toString(d)).stripTrailingZeros();
+        double a = Math.abs(d);
+        if (a >= 1e-6 && a < 1e21) {
+            sb.append(b.toPlainString());
+            return;
+        }
+        String digits = b.unscaledValue().abs().toString();
+        int exp = digits.length() - 1 - b.scale();
+        if (d < 0) {
+            sb.append('-');
+        }
+        sb.append(digits.charAt(0));
+        if (digits.length() > 1) {
+            sb.append('.').append(digits, 1, digits.length());
+        }
+        sb.append('e').append(exp < 0 ? '-' : '+').append(Math.abs(exp));
+    }
+
+    static void writeString(StringBuilder sb, String s) {
+        sb.append('"');
+        for (int i = 0; i < s.length(); i++) {
+            char c = s.charAt(i);
+            switch (c) {
+            case '"':
+                sb.append("\\\"");
+                break;
+            case '\\':
+                sb.append("\\\\");
+                break;
+            case '\b':
+                sb.append("\\b");
+                break;
+            case '\f':
+                sb.append("\\f");
+                break;
+            case '\n':
+                sb.append("\\n");
+                break;
+            case '\r':
+                sb.append("\\r");
+                break;
+            case '\t':
+                sb.append("\\t");
+                break;
+            default:
+                if (c < 0x20) {
+                    sb.append(String.format("\\u%04x", (int) c));
+                } else {
+                    sb.append(c);
+                }
+            }
+        }
+        sb.append('"');
+    }
+}
+`
+
+func javaGenerateCanonical(banner string, schema *rdl.Schema, packageDir string, ns string) error {
+	cName := capitalize(string(schema.Name)) + "Canonical"
+	out, file, _, err := outputWriter(packageDir, cName, ".java")
+	if err != nil {
+		return err
+	}
+	funcMap := template.FuncMap{
+		"header":  func() string { return javaGenerationHeader(banner) },
+		"package": func() string { return javaGenerationPackage(schema, ns) },
+		"cName":   func() string { return cName },
+		"name":    func() string { return string(schema.Name) },
+	}
+	t := template.Must(template.New(cName).Funcs(funcMap).Parse(javaModelCanonicalTemplate))
+	err = t.Execute(out, schema)
+	out.Flush()
+	file.Close()
+	return err
+}
+
+// emitCanonicalJSON emits the canonicalJSON method of a struct class or record.
+func (gen *javaModelGenerator) emitCanonicalJSON() {
+	cc := capitalize(string(gen.schema.Name)) + "Canonical"
+	gen.emit("\n    public byte[] canonicalJSON() {\n")
+	if !gen.jackson {
+		gen.emit(fmt.Sprintf("        return %s.write(toJSONValue());\n", cc))
+		gen.emit("    }\n")
+		return
+	}
+	gen.emit("        try {\n")
+	gen.emit("            com.fasterxml.jackson.databind.ObjectMapper mapper = new com.fasterxml.jackson.databind.ObjectMapper().findAndRegisterModules();\n")
+	gen.emit(fmt.Sprintf("            return %s.write(mapper.readValue(mapper.writeValueAsString(this), Object.class));\n", cc))
+	gen.emit("        } catch (java.io.IOException e) {\n")
+	gen.emit("            throw new java.io.UncheckedIOException(e);\n")
+	gen.emit("        }\n")
+	gen.emit("    }\n")
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+//
+// The collections of java-model. Array and map fields are declared as a List and a Map, which
+// Jackson reads into an ArrayList and a LinkedHashMap unless told otherwise. With -x list=LinkedList
+// or -x map=HashMap, LinkedHashMap, or TreeMap (e.g. to keep the keys sorted), the fields are read
+// into that class instead: Jackson is told so with @JsonDeserialize(as = ...) on the field, the
+// fromJSON methods of jackson=false read into it, and records and immutable classes copy into it.
+// Only the field itself is, the lists and maps nested in it are Jackson's defaults.
+//
+// With -x unmodifiable=true, the collections returned are unmodifiable views: the getters of
+// getsetters and getters-setters wrap the field, and immutable classes keep an unmodifiable copy,
+// as records always do. It needs one of those, the fields of the other classes are public.
+//
+
+// collectionClass returns the class the array or map field is read into, or "" if the field is
+// neither, or nullable, i.e. an Optional.
+func (gen *javaModelGenerator) collectionClass(f *rdl.StructFieldDef) string {
+	if nullableField(f) {
+		return ""
+	}
+	switch gen.registry.FindBaseType(f.Type) {
+	case rdl.BaseTypeArray:
+		if gen.listImpl != "" {
+			return "java.util." + gen.listImpl
+		}
+		return "java.util.ArrayList"
+	case rdl.BaseTypeMap:
+		if gen.mapImpl != "" {
+			return "java.util." + gen.mapImpl
+		}
+		return "java.util.LinkedHashMap"
+	}
+	return ""
+}
+
+// hasCollectionImpl tells whether the class of the array or map field was set by the list or map
+// option.
+func (gen *javaModelGenerator) hasCollectionImpl(f *rdl.StructFieldDef) bool {
+	switch gen.registry.FindBaseType(f.Type) {
+	case rdl.BaseTypeArray:
+		return gen.listImpl != ""
+	case rdl.BaseTypeMap:
+		return gen.mapImpl != ""
+	}
+	return false
+}
+
+// unmodifiableView returns the expression wrapping the value of the array or map field in an
+// unmodifiable view.
+func (gen *javaModelGenerator) unmodifiableView(f *rdl.StructFieldDef, expr string) string {
+	if gen.registry.FindBaseType(f.Type) == rdl.BaseTypeMap {
+		return "java.util.Collections.unmodifiableMap(" + expr + ")"
+	}
+	return "java.util.Collections.unmodifiableList(" + expr + ")"
+}
+
+// collectionCopy returns the expression copying the value of the array or map field into its
+// class, as an unmodifiable view if unmodifiable, or "" if the field is neither.
+func (gen *javaModelGenerator) collectionCopy(f *rdl.StructFieldDef, expr string, unmodifiable bool) string {
+	class := gen.collectionClass(f)
+	if class == "" {
+		return ""
+	}
+	copy := "new " + class + "<>(" + expr + ")"
+	if unmodifiable {
+		copy = gen.unmodifiableView(f, copy)
+	}
+	return copy
+}
+
+// deserializeAs returns the Jackson annotation reading the array or map field of a class into the
+// class set by the list or map option, or "" if there is none. Records and immutable classes copy
+// the collections in their constructor instead.
+func (gen *javaModelGenerator) deserializeAs(f *rdl.StructFieldDef) string {
+	if !gen.jackson || gen.records || gen.immutable || !gen.hasCollectionImpl(f) {
+		return ""
+	}
+	class := gen.collectionClass(f)
+	if class == "" {
+		return ""
+	}
+	return "@com.fasterxml.jackson.databind.annotation.JsonDeserialize(as = " + class + ".class)"
+}
+
+// returned returns the expression a getter returns the field as, an unmodifiable view of it if
+// it is an array or map field and the unmodifiable option is set.
+func (gen *javaModelGenerator) returned(f *rdl.StructFieldDef, fname string) string {
+	if !gen.unmodifiable || gen.collectionClass(f) == "" {
+		return fname
+	}
+	return fname + " == null ? null : " + gen.unmodifiableView(f, fname)
+}
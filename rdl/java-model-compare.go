@@ -0,0 +1,201 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strconv"
+	"strings"
+)
+
+//
+// Natural ordering for java-model. A struct annotated with x_compare, a comma-separated list of
+// its fields, e.g. x_compare="lastName,firstName", implements Comparable, comparing the fields
+// in that order, with nulls first. -x comparable=true makes every struct Comparable, comparing
+// its fields of ordered types (strings, numbers, Bool, Timestamp, UUID, Symbol, enums, and
+// Comparable structs) in the order they are declared. The ordering is not consistent with
+// equals, which compares all the fields.
+//
+// The enums compared this way carry an int value to be ordered by, instead of their ordinal, so
+// that adding or reordering constants does not change the order. An element takes the value of
+// its x_value annotation, or else the value following the previous one, starting at 0.
+//
+
+// comparedFields returns the fields the struct is ordered by, and whether it is Comparable.
+func (gen *javaModelGenerator) comparedFields(t *rdl.Type) ([]*rdl.StructFieldDef, bool) {
+	if !gen.isComparableStruct(t) {
+		return nil, false
+	}
+	st := t.StructTypeDef
+	fields := flattenedFields(gen.registry, t)
+	annotation, ok := st.Annotations["x_compare"]
+	if !ok {
+		var compared []*rdl.StructFieldDef
+		for _, f := range fields {
+			if gen.isOrderedField(f) {
+				compared = append(compared, f)
+			}
+		}
+		return compared, true
+	}
+	var compared []*rdl.StructFieldDef
+	for _, name := range strings.Split(annotation, ",") {
+		name = strings.TrimSpace(name)
+		var field *rdl.StructFieldDef
+		for _, f := range fields {
+			if string(f.Name) == name {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			gen.err = fmt.Errorf("Unknown field '%s' in the x_compare annotation of %s", name, st.Name)
+			return nil, false
+		}
+		if !gen.isOrderedField(field) {
+			gen.err = fmt.Errorf("Field '%s' of %s cannot be compared, as its type %s has no order", name, st.Name, field.Type)
+			return nil, false
+		}
+		compared = append(compared, field)
+	}
+	return compared, true
+}
+
+// isComparableStruct tells whether the class of the struct type implements Comparable.
+func (gen *javaModelGenerator) isComparableStruct(t *rdl.Type) bool {
+	if t == nil || t.Variant != rdl.TypeVariantStructTypeDef || t.StructTypeDef.Name == "Struct" || patchTarget(t.StructTypeDef) != "" {
+		return false
+	}
+	_, ok := t.StructTypeDef.Annotations["x_compare"]
+	return ok || gen.comparable
+}
+
+// isOrderedField tells whether the values of the field have an order to compare them by.
+func (gen *javaModelGenerator) isOrderedField(f *rdl.StructFieldDef) bool {
+	if nullableField(f) {
+		return false
+	}
+	switch gen.registry.FindBaseType(f.Type) {
+	case rdl.BaseTypeString, rdl.BaseTypeSymbol, rdl.BaseTypeUUID, rdl.BaseTypeTimestamp, rdl.BaseTypeBool, rdl.BaseTypeEnum,
+		rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return true
+	case rdl.BaseTypeStruct:
+		return gen.isComparableStruct(gen.registry.FindType(f.Type))
+	}
+	return false
+}
+
+// hasEnumValues tells whether the enum carries values to be ordered by: with -x comparable=true,
+// if one of its elements has an x_value, or if a field of a struct with x_compare is compared by it.
+func (gen *javaModelGenerator) hasEnumValues(et *rdl.EnumTypeDef) bool {
+	if gen.comparable {
+		return true
+	}
+	for _, elem := range et.Elements {
+		if _, ok := elem.Annotations["x_value"]; ok {
+			return true
+		}
+	}
+	for _, t := range gen.schema.Types {
+		if t.Variant != rdl.TypeVariantStructTypeDef {
+			continue
+		}
+		annotation, ok := t.StructTypeDef.Annotations["x_compare"]
+		if !ok {
+			continue
+		}
+		fields := flattenedFields(gen.registry, t)
+		for _, name := range strings.Split(annotation, ",") {
+			for _, f := range fields {
+				if string(f.Name) == strings.TrimSpace(name) {
+					if ft := gen.registry.FindType(f.Type); ft != nil && ft.Variant == rdl.TypeVariantEnumTypeDef && ft.EnumTypeDef.Name == et.Name {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// enumValues returns the values of the elements of the enum.
+func (gen *javaModelGenerator) enumValues(et *rdl.EnumTypeDef) []int {
+	values := make([]int, 0, len(et.Elements))
+	next := 0
+	for _, elem := range et.Elements {
+		if v, ok := elem.Annotations["x_value"]; ok {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				gen.err = fmt.Errorf("Bad x_value '%s' of %s.%s, expected an integer", v, et.Name, elem.Symbol)
+				return nil
+			}
+			next = n
+		}
+		values = append(values, next)
+		next++
+	}
+	return values
+}
+
+// emitCompareTo emits the compareTo method of a Comparable struct.
+func (gen *javaModelGenerator) emitCompareTo(cName string, fields []*rdl.StructFieldDef) {
+	gen.emit("\n    @Override\n")
+	gen.emit(fmt.Sprintf("    public int compareTo(%s another) {\n", cName))
+	if len(fields) == 0 {
+		gen.emit("        return 0;\n")
+		gen.emit("    }\n")
+		return
+	}
+	for i, f := range fields {
+		fname := javaFieldName(f.Name)
+		expr := gen.compareExpr(f, fname, "another."+fname)
+		switch {
+		case i == len(fields)-1:
+			gen.emit(fmt.Sprintf("        return %s;\n", expr))
+		case i == 0:
+			gen.emit(fmt.Sprintf("        int c = %s;\n", expr))
+		default:
+			gen.emit(fmt.Sprintf("        c = %s;\n", expr))
+		}
+		if i < len(fields)-1 {
+			gen.emit("        if (c != 0) {\n")
+			gen.emit("            return c;\n")
+			gen.emit("        }\n")
+		}
+	}
+	gen.emit("    }\n")
+}
+
+// compareExpr returns the expression comparing two values of the field.
+func (gen *javaModelGenerator) compareExpr(f *rdl.StructFieldDef, a string, b string) string {
+	bt := gen.registry.FindBaseType(f.Type)
+	if gen.isFieldPrimitiveType(f) && !(gen.records && f.Default != nil) {
+		boxed := map[rdl.BaseType]string{
+			rdl.BaseTypeBool:    "Boolean",
+			rdl.BaseTypeInt8:    "Byte",
+			rdl.BaseTypeInt16:   "Short",
+			rdl.BaseTypeInt32:   "Integer",
+			rdl.BaseTypeInt64:   "Long",
+			rdl.BaseTypeFloat32: "Float",
+			rdl.BaseTypeFloat64: "Double",
+		}[bt]
+		return fmt.Sprintf("%s.compare(%s, %s)", boxed, a, b)
+	}
+	jtype := gen.javaType(f.Type, true, f.Items, f.Keys)
+	order := "java.util.Comparator.<" + jtype + ">naturalOrder()"
+	switch bt {
+	case rdl.BaseTypeTimestamp:
+		if !gen.instant {
+			order = "java.util.Comparator.comparingLong(Timestamp::millis)"
+		}
+	case rdl.BaseTypeUUID, rdl.BaseTypeSymbol:
+		order = "java.util.Comparator.comparing(Object::toString)"
+	case rdl.BaseTypeEnum:
+		if t := gen.registry.FindType(f.Type); t != nil && t.Variant == rdl.TypeVariantEnumTypeDef && gen.hasEnumValues(t.EnumTypeDef) {
+			order = "java.util.Comparator.comparingInt(" + jtype + "::value)"
+		}
+	}
+	return fmt.Sprintf("java.util.Comparator.nullsFirst(%s).compare(%s, %s)", order, a, b)
+}
@@ -0,0 +1,106 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// Generic types in java-model. The generic type is a generic interface of the getters of its
+// fields, e.g. Page<T> with List<T> getItems(), and the classes of its instantiations implement
+// it, e.g. ContactPage implements Page<Contact>, so that code can handle the pages of any type.
+// The getters are those of the -x options: the record accessors, the bean getters, the getters
+// of -x getsetters=true, or else ones generated for the purpose.
+//
+
+// emitTemplateInterface emits the generic interface of the generic struct type.
+func (gen *javaModelGenerator) emitTemplateInterface(t *rdl.Type, params []string) {
+	st := t.StructTypeDef
+	own := make(map[rdl.TypeRef]bool)
+	for _, p := range params {
+		own[rdl.TypeRef(p)] = true
+	}
+	gen.emit(javaDeprecated(st.Annotations, ""))
+	gen.emit(fmt.Sprintf("public interface %s<%s> {\n", st.Name, strings.Join(params, ", ")))
+	for i, f := range flattenedFields(gen.registry, t) {
+		ftype := gen.fieldType(f)
+		if own[f.Items] {
+			switch gen.registry.FindBaseType(f.Type) {
+			case rdl.BaseTypeArray:
+				ftype = "List<" + string(f.Items) + ">"
+			case rdl.BaseTypeMap:
+				ftype = "Map<" + gen.javaType(f.Keys, true, "", "") + ", " + string(f.Items) + ">"
+			}
+		}
+		getter, rtype, _ := gen.templateGetter(f, ftype)
+		if i > 0 {
+			gen.emit("\n")
+		}
+		gen.emit(javaDoc(f.Comment, f.Annotations, "    "))
+		gen.emit(fmt.Sprintf("    %s %s();\n", rtype, getter))
+	}
+	gen.emit("}\n")
+}
+
+// templateInterface returns the generic interface the class of the struct implements, e.g.
+// Page<Contact>, or "" if it instantiates no generic type.
+func (gen *javaModelGenerator) templateInterface(st *rdl.StructTypeDef) string {
+	template, args := templateOf(st)
+	if template == "" {
+		return ""
+	}
+	var targs []string
+	for _, arg := range args {
+		targs = append(targs, gen.javaType(rdl.TypeRef(arg), true, "", ""))
+	}
+	return fmt.Sprintf("%s<%s>", template, strings.Join(targs, ", "))
+}
+
+// templateGetter returns the name and the return type of the getter of the field in the generic
+// interface, and whether the class already has it.
+func (gen *javaModelGenerator) templateGetter(f *rdl.StructFieldDef, ftype string) (string, string, bool) {
+	fname := javaFieldName(f.Name)
+	switch {
+	case gen.records:
+		return fname, ftype, true
+	case gen.getSetters:
+		if f.Optional && gen.optionals && !nullableField(f) {
+			return "get" + capitalize(fname), "java.util.Optional<" + ftype + ">", true
+		}
+		return "get" + capitalize(fname), ftype, true
+	case gen.beans:
+		return javaBeanGetter(ftype, fname), ftype, true
+	}
+	return "get" + capitalize(fname), ftype, false
+}
+
+// emitTemplateGetters emits the getters of the generic interface the class implements that it
+// does not already have.
+func (gen *javaModelGenerator) emitTemplateGetters(st *rdl.StructTypeDef, fields []*rdl.StructFieldDef) {
+	if gen.templateInterface(st) == "" {
+		return
+	}
+	template, _ := templateOf(st)
+	inherited := make(map[rdl.Identifier]bool)
+	for _, f := range flattenedFields(gen.registry, gen.registry.FindType(template)) {
+		inherited[f.Name] = true
+	}
+	for _, f := range fields {
+		if !inherited[f.Name] {
+			continue
+		}
+		ftype := gen.fieldType(f)
+		getter, rtype, provided := gen.templateGetter(f, ftype)
+		if provided {
+			continue
+		}
+		if gen.jackson {
+			gen.emit("\n    @com.fasterxml.jackson.annotation.JsonIgnore")
+		}
+		gen.emit(fmt.Sprintf("\n    @Override\n    public %s %s() {\n        return %s;\n    }\n", rtype, getter, javaFieldName(f.Name)))
+	}
+}
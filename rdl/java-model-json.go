@@ -0,0 +1,601 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+	"text/template"
+)
+
+//
+// Framework-free JSON for java-model (-x jackson=false). The Jackson annotations and the Jackson
+// union deserializer are left out, and structs and unions get toJSON/fromJSON methods instead.
+// They convert to and from the generic JSON values (Map, List, String, Number, Boolean) that the
+// generated FooJson class writes and parses.
+//
+
+const javaModelJSONTemplate = `{{header}}
+package {{package}};
+import java.util.ArrayList;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.function.Function;
+
+//
+// {{cName}} - writes and parses JSON for the {{name}} types, without depending on a JSON library.
+// Objects parse to a Map, arrays to a List, and numbers to a Long or Double.
+//
+public final class {{cName}} {
+
+    private {{cName}}() {
+    }
+
+    public static String write(Object value) {
+        StringBuilder sb = new StringBuilder();
+        writeValue(sb, value);
+        return sb.toString();
+    }
+
+    public static Object parse(String json) {
+        Parser p = new Parser(json);
+        Object value = p.value();
+        p.space();
+        if (p.pos != json.length()) {
+            throw p.error("unexpected data after the value");
+        }
+        return value;
+    }
+
+    static void writeValue(StringBuilder sb, Object value) {
+        if (value == null) {
+            sb.append("null");
+        } else if (value instanceof Boolean || value instanceof Number) {
+            sb.append(value);
+        } else if (value instanceof Map) {
+            sb.append('{');
+            String sep = "";
+            for (Map.Entry<?, ?> e : ((Map<?, ?>) value).entrySet()) {
+                sb.append(sep);
+                writeString(sb, String.valueOf(e.getKey()));
+                sb.append(':');
+                writeValue(sb, e.getValue());
+                sep = ",";
+            }
+            sb.append('}');
+        } else if (value instanceof Iterable) {
+            sb.append('[');
+            String sep = "";
+            for (Object item : (Iterable<?>) value) {
+                sb.append(sep);
+                writeValue(sb, item);
+                sep = ",";
+            }
+            sb.append(']');
+        } else {
+            writeString(sb, value.toString());
+        }
+    }
+
+    static void writeString(StringBuilder sb, String s) {
+        sb.append('"');
+        for (int i = 0; i < s.length(); i++) {
+            char c = s.charAt(i);
+            switch (c) {
+            case '"':
+                sb.append("\\\"");
+                break;
+            case '\\':
+                sb.append("\\\\");
+                break;
+            case '\n':
+                sb.append("\\n");
+                break;
+            case '\r':
+                sb.append("\\r");
+                break;
+            case '\t':
+                sb.append("\\t");
+                break;
+            default:
+                if (c < 0x20) {
+                    sb.append(String.format("\\u%04x", (int) c));
+                } else {
+                    sb.append(c);
+                }
+            }
+        }
+        sb.append('"');
+    }
+
+    // writeList converts the items of a list to JSON values, nulls stay null.
+    static <T> List<Object> writeList(List<T> list, Function<T, Object> f) {
+        List<Object> result = new ArrayList<Object>(list.size());
+        for (T item : list) {
+            result.add(item == null ? null : f.apply(item));
+        }
+        return result;
+    }
+
+    // writeMap converts the values of a map to JSON values, and its keys to strings.
+    static <K, V> Map<String, Object> writeMap(Map<K, V> map, Function<V, Object> f) {
+        Map<String, Object> result = new LinkedHashMap<String, Object>();
+        for (Map.Entry<K, V> e : map.entrySet()) {
+            result.put(String.valueOf(e.getKey()), e.getValue() == null ? null : f.apply(e.getValue()));
+        }
+        return result;
+    }
+
+    // readList converts a JSON array to a list, nulls stay null.
+    static <T> List<T> readList(Object value, Function<Object, T> f) {
+        if (!(value instanceof List)) {
+            throw new IllegalArgumentException("Expected a JSON array, got: " + value);
+        }
+        List<?> list = (List<?>) value;
+        List<T> result = {{newList}};
+        for (Object item : list) {
+            result.add(item == null ? null : f.apply(item));
+        }
+        return result;
+    }
+
+    // readMap converts a JSON object to a map, converting its keys and values.
+    static <K, V> Map<K, V> readMap(Object value, Function<String, K> fk, Function<Object, V> fv) {
+        Map<String, Object> map = readObject(value, "map");
+        Map<K, V> result = {{newMap}};
+        for (Map.Entry<String, Object> e : map.entrySet()) {
+            result.put(fk.apply(e.getKey()), e.getValue() == null ? null : fv.apply(e.getValue()));
+        }
+        return result;
+    }
+
+    @SuppressWarnings("unchecked")
+    static Map<String, Object> readObject(Object value, String type) {
+        if (!(value instanceof Map)) {
+            throw new IllegalArgumentException("Expected a JSON object for " + type + ", got: " + value);
+        }
+        return (Map<String, Object>) value;
+    }
+
+    static String readString(Object value) {
+        if (!(value instanceof String)) {
+            throw new IllegalArgumentException("Expected a JSON string, got: " + value);
+        }
+        return (String) value;
+    }
+
+    static Number readNumber(Object value) {
+        if (!(value instanceof Number)) {
+            throw new IllegalArgumentException("Expected a JSON number, got: " + value);
+        }
+        return (Number) value;
+    }
+
+    static Boolean readBoolean(Object value) {
+        if (!(value instanceof Boolean)) {
+            throw new IllegalArgumentException("Expected a JSON boolean, got: " + value);
+        }
+        return (Boolean) value;
+    }
+
+    private static final class Parser {
+        final String s;
+        int pos;
+
+        Parser(String s) {
+            this.s = s;
+        }
+
+        IllegalArgumentException error(String msg) {
+            return new IllegalArgumentException("Bad JSON at offset " + pos + ": " + msg);
+        }
+
+        void space() {
+            while (pos < s.length() && Character.isWhitespace(s.charAt(pos))) {
+                pos++;
+            }
+        }
+
+        boolean consume(char c) {
+            space();
+            if (pos < s.length() && s.charAt(pos) == c) {
+                pos++;
+                return true;
+            }
+            return false;
+        }
+
+        void expect(char c) {
+            if (!consume(c)) {
+                throw error("expected '" + c + "'");
+            }
+        }
+
+        Object value() {
+            space();
+            if (pos >= s.length()) {
+                throw error("unexpected end of input");
+            }
+            char c = s.charAt(pos);
+            switch (c) {
+            case '{':
+                pos++;
+                Map<String, Object> map = new LinkedHashMap<String, Object>();
+                if (!consume('}')) {
+                    do {
+                        space();
+                        String key = string();
+                        expect(':');
+                        map.put(key, value());
+                    } while (consume(','));
+                    expect('}');
+                }
+                return map;
+            case '[':
+                pos++;
+                List<Object> list = new ArrayList<Object>();
+                if (!consume(']')) {
+                    do {
+                        list.add(value());
+                    } while (consume(','));
+                    expect(']');
+                }
+                return list;
+            case '"':
+                return string();
+            default:
+                if (s.startsWith("true", pos)) {
+                    pos += 4;
+                    return Boolean.TRUE;
+                } else if (s.startsWith("false", pos)) {
+                    pos += 5;
+                    return Boolean.FALSE;
+                } else if (s.startsWith("null", pos)) {
+                    pos += 4;
+                    return null;
+                }
+                return number();
+            }
+        }
+
+        String string() {
+            if (pos >= s.length() || s.charAt(pos) != '"') {
+                throw error("expected a string");
+            }
+            pos++;
+            StringBuilder sb = new StringBuilder();
+            while (pos < s.length()) {
+                char c = s.charAt(pos++);
+                if (c == '"') {
+                    return sb.toString();
+                } else if (c != '\\') {
+                    sb.append(c);
+                } else if (pos < s.length()) {
+                    c = s.charAt(pos++);
+                    switch (c) {
+                    case 'b':
+                        sb.append('\b');
+                        break;
+                    case 'f':
+                        sb.append('\f');
+                        break;
+                    case 'n':
+                        sb.append('\n');
+                        break;
+                    case 'r':
+                        sb.append('\r');
+                        break;
+                    case 't':
+                        sb.append('\t');
+                        break;
+                    case 'u':
+                        if (pos + 4 > s.length()) {
+                            throw error("bad unicode escape");
+                        }
+                        sb.append((char) Integer.parseInt(s.substring(pos, pos + 4), 16));
+                        pos += 4;
+                        break;
+                    default:
+                        sb.append(c);
+                    }
+                }
+            }
+            throw error("unterminated string");
+        }
+
+        Number number() {
+            int start = pos;
+            boolean integral = true;
+            while (pos < s.length() && "+-0123456789.eE".indexOf(s.charAt(pos)) >= 0) {
+                char c = s.charAt(pos++);
+                if (c == '.' || c == 'e' || c == 'E') {
+                    integral = false;
+                }
+            }
+            String n = s.substring(start, pos);
+            try {
+                if (integral) {
+                    return Long.parseLong(n);
+                }
+                return Double.parseDouble(n);
+            } catch (NumberFormatException e) {
+                throw error("bad value '" + n + "'");
+            }
+        }
+    }
+}
+`
+
+// javaGenerateJSON generates the <Name>Json class, which reads the arrays and maps of the model
+// into the classes of the list and map options, if set.
+func javaGenerateJSON(banner string, schema *rdl.Schema, packageDir string, ns string, listImpl string, mapImpl string) error {
+	cName := capitalize(string(schema.Name)) + "Json"
+	out, file, _, err := outputWriter(packageDir, cName, ".java")
+	if err != nil {
+		return err
+	}
+	newList := "new ArrayList<T>(list.size())"
+	if listImpl != "" && listImpl != "ArrayList" {
+		newList = "new java.util." + listImpl + "<T>()"
+	}
+	newMap := "new LinkedHashMap<K, V>()"
+	if mapImpl != "" && mapImpl != "LinkedHashMap" {
+		newMap = "new java.util." + mapImpl + "<K, V>()"
+	}
+	funcMap := template.FuncMap{
+		"header":  func() string { return javaGenerationHeader(banner) },
+		"package": func() string { return javaGenerationPackage(schema, ns) },
+		"cName":   func() string { return cName },
+		"name":    func() string { return string(schema.Name) },
+		"newList": func() string { return newList },
+		"newMap":  func() string { return newMap },
+	}
+	t := template.Must(template.New(cName).Funcs(funcMap).Parse(javaModelJSONTemplate))
+	err = t.Execute(out, schema)
+	out.Flush()
+	file.Close()
+	return err
+}
+
+func (gen *javaModelGenerator) jsonClass() string {
+	return capitalize(string(gen.schema.Name)) + "Json"
+}
+
+// qualifiedType returns the java type, qualified by its package. Union fields are named like
+// their types, so in the static methods of a union the simple name would refer to the field.
+func (gen *javaModelGenerator) qualifiedType(tref rdl.TypeRef) string {
+	jtype := gen.javaType(tref, true, "", "")
+	if !gen.qualify {
+		return jtype
+	}
+	switch gen.registry.FindBaseType(tref) {
+	case rdl.BaseTypeSymbol, rdl.BaseTypeTimestamp, rdl.BaseTypeUUID:
+		if jtype == "Symbol" || jtype == "Timestamp" || jtype == "UUID" {
+			return "com.yahoo.rdl." + jtype
+		}
+		if strings.HasPrefix(jtype, "java.") {
+			return jtype
+		}
+	}
+	if pack := javaGenerationPackage(gen.schema, gen.ns); pack != "" {
+		return pack + "." + jtype
+	}
+	return jtype
+}
+
+// toJSONValue returns the expression converting the non-null value of expr to a JSON value.
+func (gen *javaModelGenerator) toJSONValue(expr string, tref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef, depth int) string {
+	t := gen.registry.FindType(tref)
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeString, rdl.BaseTypeBool, rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64, rdl.BaseTypeAny:
+		return expr
+	case rdl.BaseTypeStruct:
+		if gen.javaType(tref, true, "", "") == "Object" {
+			return expr
+		}
+		return expr + ".toJSONValue()"
+	case rdl.BaseTypeUnion:
+		return expr + ".toJSONValue()"
+	case rdl.BaseTypeBytes:
+		return "java.util.Base64.getEncoder().encodeToString(" + expr + ")"
+	case rdl.BaseTypeArray:
+		i := gen.collectionItems(t, items)
+		e := fmt.Sprintf("e%d", depth)
+		conv := gen.toJSONValue(e, i, "", "", depth+1)
+		if conv == e {
+			return expr
+		}
+		return fmt.Sprintf("%s.writeList(%s, %s -> %s)", gen.jsonClass(), expr, e, conv)
+	case rdl.BaseTypeMap:
+		i := gen.collectionItems(t, items)
+		e := fmt.Sprintf("e%d", depth)
+		return fmt.Sprintf("%s.writeMap(%s, %s -> %s)", gen.jsonClass(), expr, e, gen.toJSONValue(e, i, "", "", depth+1))
+	default: //Symbol, Timestamp, UUID, and enums are strings
+		return expr + ".toString()"
+	}
+}
+
+// fromJSONValue returns the expression converting the non-null JSON value of expr to the java type.
+func (gen *javaModelGenerator) fromJSONValue(expr string, tref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef, depth int) string {
+	t := gen.registry.FindType(tref)
+	jc := gen.jsonClass()
+	switch gen.registry.BaseType(t) {
+	case rdl.BaseTypeAny:
+		return expr
+	case rdl.BaseTypeString:
+		return fmt.Sprintf("%s.readString(%s)", jc, expr)
+	case rdl.BaseTypeBytes:
+		return fmt.Sprintf("java.util.Base64.getDecoder().decode(%s.readString(%s))", jc, expr)
+	case rdl.BaseTypeBool:
+		return fmt.Sprintf("%s.readBoolean(%s)", jc, expr)
+	case rdl.BaseTypeInt8:
+		return fmt.Sprintf("%s.readNumber(%s).byteValue()", jc, expr)
+	case rdl.BaseTypeInt16:
+		return fmt.Sprintf("%s.readNumber(%s).shortValue()", jc, expr)
+	case rdl.BaseTypeInt32:
+		return fmt.Sprintf("%s.readNumber(%s).intValue()", jc, expr)
+	case rdl.BaseTypeInt64:
+		return fmt.Sprintf("%s.readNumber(%s).longValue()", jc, expr)
+	case rdl.BaseTypeFloat32:
+		return fmt.Sprintf("%s.readNumber(%s).floatValue()", jc, expr)
+	case rdl.BaseTypeFloat64:
+		return fmt.Sprintf("%s.readNumber(%s).doubleValue()", jc, expr)
+	case rdl.BaseTypeStruct:
+		jtype := gen.javaType(tref, true, "", "")
+		if jtype == "Object" {
+			return expr
+		}
+		return fmt.Sprintf("%s.fromJSONValue(%s)", gen.qualifiedType(tref), expr)
+	case rdl.BaseTypeUnion:
+		return fmt.Sprintf("%s.fromJSONValue(%s)", gen.qualifiedType(tref), expr)
+	case rdl.BaseTypeArray:
+		i := gen.collectionItems(t, items)
+		e := fmt.Sprintf("e%d", depth)
+		return fmt.Sprintf("%s.readList(%s, %s -> %s)", jc, expr, e, gen.fromJSONValue(e, i, "", "", depth+1))
+	case rdl.BaseTypeMap:
+		i := gen.collectionItems(t, items)
+		k := rdl.TypeRef("String")
+		if t.Variant == rdl.TypeVariantMapTypeDef {
+			k = t.MapTypeDef.Keys
+		} else if keys != "" {
+			k = keys
+		}
+		kv := fmt.Sprintf("k%d", depth)
+		kconv := kv
+		if gen.javaType(k, true, "", "") != "String" {
+			kconv = gen.fromJSONValue(kv, k, "", "", depth+1)
+		}
+		e := fmt.Sprintf("e%d", depth)
+		return fmt.Sprintf("%s.readMap(%s, %s -> %s, %s -> %s)", jc, expr, kv, kconv, e, gen.fromJSONValue(e, i, "", "", depth+1))
+	default: //Symbol, Timestamp, UUID, and enums are strings
+		return fmt.Sprintf("%s.fromString(%s.readString(%s))", gen.qualifiedType(tref), jc, expr)
+	}
+}
+
+// collectionItems returns the type of the items of an array or map type, or of an inline one.
+func (gen *javaModelGenerator) collectionItems(t *rdl.Type, items rdl.TypeRef) rdl.TypeRef {
+	switch t.Variant {
+	case rdl.TypeVariantArrayTypeDef:
+		return t.ArrayTypeDef.Items
+	case rdl.TypeVariantMapTypeDef:
+		return t.MapTypeDef.Items
+	}
+	if items != "" {
+		return items
+	}
+	return "Any"
+}
+
+// emitStructJSON emits the toJSON and fromJSON methods of a struct class or record.
+func (gen *javaModelGenerator) emitStructJSON(cName string, fields []*rdl.StructFieldDef, init bool) {
+	jc := gen.jsonClass()
+	gen.emit("\n    public String toJSON() {\n")
+	gen.emit(fmt.Sprintf("        return %s.write(toJSONValue());\n", jc))
+	gen.emit("    }\n")
+	gen.emit("\n    public java.util.Map<String, Object> toJSONValue() {\n")
+	gen.emit("        java.util.Map<String, Object> m = new java.util.LinkedHashMap<String, Object>();\n")
+	for _, f := range fields {
+		fname := javaFieldName(f.Name)
+		conv := gen.toJSONValue(fname, f.Type, f.Items, f.Keys, 1)
+		if gen.javaType(f.Type, f.Optional, f.Items, f.Keys) != gen.javaType(f.Type, true, f.Items, f.Keys) {
+			gen.emit(fmt.Sprintf("        m.put(%q, %s);\n", f.Name, conv))
+		} else {
+			gen.emit(fmt.Sprintf("        if (%s != null) {\n", fname))
+			gen.emit(fmt.Sprintf("            m.put(%q, %s);\n", f.Name, conv))
+			gen.emit("        }\n")
+		}
+	}
+	gen.emit("        return m;\n")
+	gen.emit("    }\n")
+	gen.emit(fmt.Sprintf("\n    public static %s fromJSON(String json) {\n", cName))
+	gen.emit(fmt.Sprintf("        return fromJSONValue(%s.parse(json));\n", jc))
+	gen.emit("    }\n")
+	gen.emit(fmt.Sprintf("\n    public static %s fromJSONValue(Object value) {\n", cName))
+	gen.emit("        if (value == null) {\n            return null;\n        }\n")
+	gen.emit(fmt.Sprintf("        java.util.Map<String, Object> m = %s.readObject(value, %q);\n", jc, cName))
+	target := "o."
+	if gen.records {
+		target = ""
+		for _, f := range fields {
+			ftype := gen.fieldType(f)
+			zero := "null"
+			switch ftype {
+			case "boolean":
+				zero = "false"
+			case "byte", "short", "int", "long", "float", "double":
+				zero = "0"
+			}
+			gen.emit(fmt.Sprintf("        %s %s = %s;\n", ftype, javaFieldName(f.Name), zero))
+		}
+	} else {
+		gen.emit(fmt.Sprintf("        %s o = new %s();\n", cName, cName))
+	}
+	if len(fields) > 0 {
+		gen.emit("        Object v;\n")
+	}
+	for _, f := range fields {
+		gen.emit(fmt.Sprintf("        if ((v = m.get(%q)) != null) {\n", f.Name))
+		gen.emit(fmt.Sprintf("            %s%s = %s;\n", target, javaFieldName(f.Name), gen.fromJSONValue("v", f.Type, f.Items, f.Keys, 1)))
+		gen.emit("        }\n")
+	}
+	if gen.records {
+		args := make([]string, 0, len(fields))
+		for _, f := range fields {
+			args = append(args, javaFieldName(f.Name))
+		}
+		gen.emit(fmt.Sprintf("        return new %s(%s);\n", cName, strings.Join(args, ", ")))
+	} else if init {
+		gen.emit("        return o.init();\n")
+	} else {
+		gen.emit("        return o;\n")
+	}
+	gen.emit("    }\n")
+}
+
+// emitUnionJSON emits the toJSON and fromJSON methods of a union, i.e. a JSON object with the
+// name of the variant as its only key.
+func (gen *javaModelGenerator) emitUnionJSON(uName string, ut *rdl.UnionTypeDef) {
+	jc := gen.jsonClass()
+	gen.emit("\n    public String toJSON() {\n")
+	gen.emit(fmt.Sprintf("        return %s.write(toJSONValue());\n", jc))
+	gen.emit("    }\n")
+	gen.emit("\n    public java.util.Map<String, Object> toJSONValue() {\n")
+	gen.emit("        java.util.Map<String, Object> m = new java.util.LinkedHashMap<String, Object>();\n")
+	gen.emit("        if (variant != null) {\n")
+	gen.emit("            switch (variant) {\n")
+	for _, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("            case %s:\n", v))
+		gen.emit(fmt.Sprintf("                m.put(%q, %s);\n", v, gen.toJSONValue(string(v), v, "", "", 1)))
+		gen.emit("                break;\n")
+	}
+	gen.emit("            }\n")
+	gen.emit("        }\n")
+	gen.emit("        return m;\n")
+	gen.emit("    }\n")
+	gen.emit(fmt.Sprintf("\n    public static %s fromJSON(String json) {\n", uName))
+	gen.emit(fmt.Sprintf("        return fromJSONValue(%s.parse(json));\n", jc))
+	gen.emit("    }\n")
+	gen.emit(fmt.Sprintf("\n    public static %s fromJSONValue(Object value) {\n", uName))
+	gen.emit("        if (value == null) {\n            return null;\n        }\n")
+	gen.emit(fmt.Sprintf("        java.util.Map<String, Object> m = %s.readObject(value, %q);\n", jc, uName))
+	gen.emit("        if (m.size() != 1) {\n")
+	gen.emit(fmt.Sprintf("            throw new IllegalArgumentException(\"Cannot deserialize %s - expected exactly one variant\");\n", uName))
+	gen.emit("        }\n")
+	gen.emit("        java.util.Map.Entry<String, Object> e = m.entrySet().iterator().next();\n")
+	gen.emit("        if (e.getValue() != null) {\n")
+	gen.emit("            Object v = e.getValue();\n")
+	gen.emit("            switch (e.getKey()) {\n")
+	gen.qualify = true
+	for _, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("            case %q:\n", v))
+		gen.emit(fmt.Sprintf("                return %s.%s(%s);\n", uName, unionFactory(v), gen.fromJSONValue("v", v, "", "", 1)))
+	}
+	gen.qualify = false
+	gen.emit("            }\n")
+	gen.emit("        }\n")
+	gen.emit(fmt.Sprintf("        throw new IllegalArgumentException(\"Cannot deserialize %s - bad type variant: \" + e.getKey());\n", uName))
+	gen.emit("    }\n")
+}
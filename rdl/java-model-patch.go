@@ -0,0 +1,136 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+// emitApplyTo emits the applyTo method of a merge patch type (see addPatchTypes), applying the
+// patch to an instance of the struct it patches. A class is updated in place, and a record, or an
+// immutable class, copied with the patched fields.
+func (gen *javaModelGenerator) emitApplyTo(patch *rdl.StructTypeDef) {
+	name := patchTarget(patch)
+	t := gen.registry.FindType(rdl.TypeRef(name))
+	if t == nil {
+		gen.err = fmt.Errorf("Cannot find type '%s' patched by %s", name, patch.Name)
+		return
+	}
+	fields := flattenedFields(gen.registry, t)
+	gen.emit("\n    //\n    // applyTo applies the patch to the target, or to a new " + string(name) + " if it is null: the fields\n")
+	gen.emit("    // left out of the patch are unchanged, those set to null are removed, and the others replaced,\n")
+	copied := gen.records || gen.immutable
+	if copied {
+		gen.emit("    // or merged for a nested patch. It returns the patched copy of the target.\n    //\n")
+	} else {
+		gen.emit("    // or merged for a nested patch. It returns the target.\n    //\n")
+	}
+	gen.emit(fmt.Sprintf("    public %s applyTo(%s target) {\n", name, name))
+	if copied {
+		for _, f := range fields {
+			fname := javaFieldName(f.Name)
+			current := "target." + fname + "()"
+			if gen.immutable {
+				current = gen.targetGetter(f)
+			}
+			gen.emit(fmt.Sprintf("        %s %s = target == null ? %s : %s;\n", gen.fieldType(f), fname, gen.zeroLiteral(f), current))
+		}
+	} else {
+		gen.emit("        if (target == null) {\n")
+		gen.emit(fmt.Sprintf("            target = new %s();\n", name))
+		gen.emit("        }\n")
+	}
+	for i, f := range fields {
+		fname := javaFieldName(f.Name)
+		var value string
+		switch {
+		case nullableField(f):
+			value = fmt.Sprintf("this.%s.isPresent() ? this.%s : null", fname, fname)
+		case patch.Fields[i].Type != f.Type:
+			current := fname
+			if !copied {
+				current = gen.targetGetter(f)
+			}
+			value = fmt.Sprintf("this.%s.isPresent() ? this.%s.get().applyTo(%s) : null", fname, fname, current)
+		default:
+			value = fmt.Sprintf("this.%s.orElse(%s)", fname, gen.zeroLiteral(f))
+		}
+		gen.emit(fmt.Sprintf("        if (this.%s != null) {\n", fname))
+		if copied {
+			gen.emit(fmt.Sprintf("            %s = %s;\n", fname, value))
+		} else {
+			gen.emit("            " + gen.targetSetter(f, value) + "\n")
+		}
+		gen.emit("        }\n")
+	}
+	if copied {
+		args := make([]string, 0, len(fields))
+		for _, f := range fields {
+			args = append(args, javaFieldName(f.Name))
+		}
+		gen.emit(fmt.Sprintf("        return new %s(%s);\n", name, strings.Join(args, ", ")))
+	} else {
+		gen.emit("        return target;\n")
+	}
+	gen.emit("    }\n")
+}
+
+// zeroLiteral returns the value a field of a primitive type is left with when removed, or null
+// for the others.
+func (gen *javaModelGenerator) zeroLiteral(f *rdl.StructFieldDef) string {
+	if !gen.isFieldPrimitiveType(f) || (gen.records && f.Default != nil) {
+		return "null"
+	}
+	switch gen.registry.FindBaseType(f.Type) {
+	case rdl.BaseTypeBool:
+		return "false"
+	case rdl.BaseTypeInt8:
+		return "(byte) 0"
+	case rdl.BaseTypeInt16:
+		return "(short) 0"
+	case rdl.BaseTypeInt64:
+		return "0L"
+	case rdl.BaseTypeFloat32:
+		return "0f"
+	case rdl.BaseTypeFloat64:
+		return "0d"
+	}
+	return "0"
+}
+
+// targetPrivate tells whether the field of a class is private, to access through its accessors.
+func (gen *javaModelGenerator) targetPrivate(f *rdl.StructFieldDef) bool {
+	return gen.beans || (f.Optional && gen.optionals && !nullableField(f))
+}
+
+// targetGetter returns the expression of the value of the field of the target.
+func (gen *javaModelGenerator) targetGetter(f *rdl.StructFieldDef) string {
+	fname := javaFieldName(f.Name)
+	optional := f.Optional && gen.optionals && !nullableField(f)
+	switch {
+	case !gen.targetPrivate(f):
+		return "target." + fname
+	case gen.getSetters && optional:
+		return "target.get" + capitalize(fname) + "().orElse(null)"
+	case gen.getSetters:
+		return "target.get" + capitalize(fname) + "()"
+	case gen.beans:
+		return "target." + javaBeanGetter(gen.fieldType(f), fname) + "()"
+	}
+	return "target." + fname + "().orElse(null)"
+}
+
+// targetSetter returns the statement setting the field of the target to the value.
+func (gen *javaModelGenerator) targetSetter(f *rdl.StructFieldDef, value string) string {
+	fname := javaFieldName(f.Name)
+	switch {
+	case !gen.targetPrivate(f):
+		return "target." + fname + " = " + value + ";"
+	case gen.getSetters || gen.beans:
+		return "target.set" + capitalize(fname) + "(" + value + ");"
+	}
+	return "target." + fname + "(" + value + ");"
+}
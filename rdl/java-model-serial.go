@@ -0,0 +1,44 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"hash/fnv"
+	"strings"
+)
+
+//
+// Java serialization for java-model (-x serializable=true). The classes and records of structs,
+// and the classes of unions, implement java.io.Serializable, for caches and session replication
+// that need it. Their serialVersionUID is a hash of the type name and of the names and types of
+// its fields, so that it stays the same across generations until the fields change.
+//
+
+// serialVersionUID returns the serialVersionUID of the type with the fields, or of the union
+// with the variants.
+func serialVersionUID(name string, fields []*rdl.StructFieldDef, variants []rdl.TypeRef) int64 {
+	parts := []string{name}
+	for _, f := range fields {
+		part := fmt.Sprintf("%s:%s<%s,%s>", f.Name, f.Type, f.Keys, f.Items)
+		if f.Optional {
+			part += "?"
+		}
+		parts = append(parts, part)
+	}
+	for _, v := range variants {
+		parts = append(parts, string(v))
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(parts, ";")))
+	return int64(h.Sum64())
+}
+
+// emitSerialVersionUID emits the serialVersionUID field of a Serializable class or record.
+func (gen *javaModelGenerator) emitSerialVersionUID(name string, fields []*rdl.StructFieldDef, variants []rdl.TypeRef) {
+	if gen.serializable {
+		gen.emit(fmt.Sprintf("    private static final long serialVersionUID = %dL;\n", serialVersionUID(name, fields, variants)))
+	}
+}
@@ -5,20 +5,40 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"github.com/ardielle/ardielle-go/rdl"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 )
 
 type javaModelGenerator struct {
-	registry   rdl.TypeRegistry
-	schema     *rdl.Schema
-	name       string
-	writer     *bufio.Writer
-	err        error
-	ns         string
-	jackson    bool
-	getSetters bool
+	registry     rdl.TypeRegistry
+	schema       *rdl.Schema
+	name         string
+	writer       *bufio.Writer
+	err          error
+	ns           string
+	jackson      bool
+	getSetters   bool
+	beans        bool
+	builder      bool
+	records      bool
+	validation   string
+	optionals    bool
+	strict       bool
+	qualify      bool
+	comparable   bool
+	serializable bool
+	instant      bool
+	utilUUIDs    bool
+	immutable    bool
+	canonical    bool
+	listImpl     string
+	mapImpl      string
+	unmodifiable bool
 }
 
 // GenerateJavaModel generates the model code for the types defined in the RDL schema.
@@ -28,17 +48,84 @@ func GenerateJavaModel(banner string, schema *rdl.Schema, outdir string, ns stri
 		return err
 	}
 	getSetters := javaGenerationBoolOptionSet(options, "getsetters")
+	beans := javaGenerationBoolOptionSet(options, "getters-setters")
+	builder := javaGenerationBoolOptionSet(options, "builder")
+	records := javaGenerationBoolOptionSet(options, "records")
+	validation := javaGenerationStringOptionSet(options, "validation")
+	switch validation {
+	case "", "javax", "jakarta":
+	default:
+		return fmt.Errorf("Unsupported validation option '%s' (expected javax or jakarta)", validation)
+	}
+	if validation == "javax" && javaGenerationBoolOptionSet(options, "jakarta") {
+		return fmt.Errorf("The validation=javax option conflicts with jakarta=true, use validation=jakarta")
+	}
+	optionals := javaGenerationBoolOptionSet(options, "optionals")
+	if optionals && records {
+		return fmt.Errorf("The optionals option cannot be used with records")
+	}
+	if beans && (records || getSetters) {
+		return fmt.Errorf("The getters-setters option cannot be used with records or getsetters")
+	}
+	jackson := javaGenerationStringOptionSet(options, "jackson") != "false"
+	immutable := javaGenerationBoolOptionSet(options, "immutable")
+	if immutable && (records || getSetters || beans) {
+		return fmt.Errorf("The immutable option cannot be used with records, getsetters, or getters-setters")
+	}
+	if immutable && !jackson {
+		return fmt.Errorf("The immutable option needs Jackson, it cannot be used with jackson=false")
+	}
+	strict := javaGenerationBoolOptionSet(options, "strict")
+	canonical := javaGenerationBoolOptionSet(options, "canonical")
+	comparable := javaGenerationBoolOptionSet(options, "comparable")
+	serializable := javaGenerationBoolOptionSet(options, "serializable")
+	timestamps := javaGenerationStringOptionSet(options, "timestamp")
+	switch timestamps {
+	case "", "rdl", "instant":
+	default:
+		return fmt.Errorf("Unsupported timestamp option '%s' (expected rdl or instant)", timestamps)
+	}
+	instant := timestamps == "instant"
+	if instant && !jackson {
+		return fmt.Errorf("The timestamp=instant option needs Jackson, it cannot be used with jackson=false")
+	}
+	uuids := javaGenerationStringOptionSet(options, "uuid")
+	switch uuids {
+	case "", "rdl", "java":
+	default:
+		return fmt.Errorf("Unsupported uuid option '%s' (expected rdl or java)", uuids)
+	}
+	listImpl := javaGenerationStringOptionSet(options, "list")
+	switch listImpl {
+	case "", "ArrayList", "LinkedList":
+	default:
+		return fmt.Errorf("Unsupported list option '%s' (expected ArrayList or LinkedList)", listImpl)
+	}
+	mapImpl := javaGenerationStringOptionSet(options, "map")
+	switch mapImpl {
+	case "", "HashMap", "LinkedHashMap", "TreeMap":
+	default:
+		return fmt.Errorf("Unsupported map option '%s' (expected HashMap, LinkedHashMap, or TreeMap)", mapImpl)
+	}
+	unmodifiable := javaGenerationBoolOptionSet(options, "unmodifiable")
+	if unmodifiable && !(records || immutable || getSetters || beans) {
+		return fmt.Errorf("The unmodifiable option needs records, immutable, getsetters, or getters-setters, as the fields are otherwise public")
+	}
 	registry := rdl.NewTypeRegistry(schema)
+	var types []*rdl.Type
 	for _, t := range schema.Types {
 		tName, _, _ := rdl.TypeInfo(t)
-		if strings.HasPrefix(string(tName), "rdl.") {
-			continue
-		}
-		err := generateJavaType(banner, schema, registry, packageDir, t, ns, getSetters)
-		if err != nil {
-			return err
+		if !strings.HasPrefix(string(tName), "rdl.") {
+			types = append(types, t)
 		}
 	}
+	//each type has its own file and generator, so they can be written concurrently
+	err = runConcurrently(len(types), runtime.GOMAXPROCS(0), func(i int) error {
+		return generateJavaType(banner, schema, registry, packageDir, types[i], ns, getSetters, beans, builder, records, validation, optionals, jackson, strict, comparable, serializable, instant, uuids == "java", immutable, canonical, listImpl, mapImpl, unmodifiable)
+	})
+	if err != nil {
+		return err
+	}
 	cName := capitalize(string(schema.Name)) + "Schema"
 	out, file, _, err := outputWriter(packageDir, cName, ".java")
 	if err != nil {
@@ -50,10 +137,19 @@ func GenerateJavaModel(banner string, schema *rdl.Schema, outdir string, ns stri
 	if err != nil {
 		return err
 	}
+	if canonical {
+		err = javaGenerateCanonical(banner, schema, packageDir, ns)
+		if err != nil {
+			return err
+		}
+	}
+	if !jackson {
+		return javaGenerateJSON(banner, schema, packageDir, ns, listImpl, mapImpl)
+	}
 	return nil
 }
 
-func generateJavaType(banner string, schema *rdl.Schema, registry rdl.TypeRegistry, outdir string, t *rdl.Type, ns string, getSetters bool) error {
+func generateJavaType(banner string, schema *rdl.Schema, registry rdl.TypeRegistry, outdir string, t *rdl.Type, ns string, getSetters bool, beans bool, builder bool, records bool, validation string, optionals bool, jackson bool, strict bool, comparable bool, serializable bool, instant bool, utilUUIDs bool, immutable bool, canonical bool, listImpl string, mapImpl string, unmodifiable bool) error {
 	tName, _, _ := rdl.TypeInfo(t)
 	bt := registry.BaseType(t)
 	switch bt {
@@ -71,7 +167,7 @@ func generateJavaType(banner string, schema *rdl.Schema, registry rdl.TypeRegist
 	if file != nil {
 		defer file.Close()
 	}
-	gen := &javaModelGenerator{registry, schema, string(tName), out, nil, ns, true, getSetters}
+	gen := &javaModelGenerator{registry, schema, string(tName), out, nil, ns, jackson, getSetters, beans, builder, records, validation, optionals, strict, false, comparable, serializable, instant, utilUUIDs, immutable, canonical, listImpl, mapImpl, unmodifiable}
 	gen.emitHeader(banner, ns, bt, t)
 	switch bt {
 	case rdl.BaseTypeStruct:
@@ -104,7 +200,7 @@ func (gen *javaModelGenerator) emit(s string) {
 func (gen *javaModelGenerator) isFieldPrimitiveType(f *rdl.StructFieldDef) bool {
 	switch gen.registry.FindBaseType(f.Type) {
 	case rdl.BaseTypeBool, rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
-		return !f.Optional
+		return !f.Optional && !nullableField(f)
 	default:
 		return false
 	}
@@ -145,6 +241,15 @@ func (gen *javaModelGenerator) addIndirectImports(t *rdl.Type, types map[string]
 				types["java.util.List"] = 1
 			}
 		}
+	case rdl.TypeVariantUnionTypeDef:
+		for _, v := range t.UnionTypeDef.Variants {
+			switch gen.registry.FindBaseType(v) {
+			case rdl.BaseTypeMap:
+				types["java.util.Map"] = 1
+			case rdl.BaseTypeArray:
+				types["java.util.List"] = 1
+			}
+		}
 	}
 }
 
@@ -152,7 +257,12 @@ func (gen *javaModelGenerator) indirectImports(t *rdl.Type) string {
 	s := ""
 	types := make(map[string]int)
 	gen.addIndirectImports(t, types)
-	for k, _ := range types {
+	names := make([]string, 0, len(types))
+	for k := range types {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
 		s += "import " + k + ";\n"
 	}
 	return s
@@ -187,6 +297,24 @@ func (gen *javaModelGenerator) emitHeader(banner string, ns string, bt rdl.BaseT
 			gen.emit("import com.fasterxml.jackson.databind.annotation.JsonSerialize;\n")
 		}
 	}
+	if gen.validation != "" && bt == rdl.BaseTypeStruct {
+		used := make(map[string]bool)
+		for _, f := range flattenedFields(gen.registry, t) {
+			for _, a := range gen.validationAnnotations(f) {
+				used[strings.SplitN(a[1:], "(", 2)[0]] = true
+			}
+		}
+		for _, name := range []string{"Valid", "DecimalMax", "DecimalMin", "Max", "Min", "NotNull", "Pattern", "Size"} {
+			if !used[name] {
+				continue
+			}
+			if name == "Valid" {
+				gen.emit("import " + gen.validation + ".validation.Valid;\n")
+			} else {
+				gen.emit("import " + gen.validation + ".validation.constraints." + name + ";\n")
+			}
+		}
+	}
 }
 
 func (gen *javaModelGenerator) emitTypeComment(t *rdl.Type) {
@@ -196,6 +324,58 @@ func (gen *javaModelGenerator) emitTypeComment(t *rdl.Type) {
 		s += " " + tComment
 	}
 	gen.emit(formatComment(s, 0, 80))
+	if reason, ok := deprecation(typeAnnotations(t)); ok {
+		gen.emit(formatComment("Deprecated: "+reason, 0, 80)[3:])
+	}
+}
+
+// javadoc returns the comment as a Javadoc block at the indent, wrapped to fit in 100 columns, or
+// "" if there is no comment, so that IDEs show the documentation of the schema on hover.
+func javadoc(comment string, indent string) string {
+	comment = strings.TrimSpace(strings.Replace(comment, "*/", "*&#47;", -1))
+	if comment == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	buf.WriteString(indent + "/**\n")
+	line := ""
+	for _, word := range strings.Fields(comment) {
+		if line != "" && len(indent)+3+len(line)+1+len(word) > 100 {
+			buf.WriteString(indent + " * " + line + "\n")
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	buf.WriteString(indent + " * " + line + "\n")
+	buf.WriteString(indent + " */\n")
+	return buf.String()
+}
+
+// javaDoc returns the Javadoc of a definition with its comment and annotations, followed by
+// @Deprecated if it is deprecated, the reason ending the Javadoc in a @deprecated tag.
+func javaDoc(comment string, annotations map[rdl.ExtendedAnnotation]string, indent string) string {
+	reason, ok := deprecation(annotations)
+	if !ok {
+		return javadoc(comment, indent)
+	}
+	doc := javadoc(comment, indent)
+	tag := javadoc("@deprecated "+reason, indent)
+	if doc != "" {
+		tag = strings.TrimSuffix(doc, indent+" */\n") + indent + " *\n" + strings.TrimPrefix(tag, indent+"/**\n")
+	}
+	return tag + javaDeprecated(annotations, indent)
+}
+
+// typeComment returns the comment of the type the reference names, if any.
+func (gen *javaModelGenerator) typeComment(tref rdl.TypeRef) string {
+	if t := gen.registry.FindType(tref); t != nil {
+		_, _, comment := rdl.TypeInfo(t)
+		return comment
+	}
+	return ""
 }
 
 func javaType(reg rdl.TypeRegistry, rdlType rdl.TypeRef, optional bool, items rdl.TypeRef, keys rdl.TypeRef) string {
@@ -211,6 +391,9 @@ func javaType(reg rdl.TypeRegistry, rdlType rdl.TypeRef, optional bool, items rd
 		return "String"
 	case rdl.BaseTypeSymbol, rdl.BaseTypeTimestamp, rdl.BaseTypeUUID:
 		return string(rdlType)
+	case rdl.BaseTypeBytes:
+		//Jackson reads and writes it as a base64 string
+		return "byte[]"
 	case rdl.BaseTypeBool:
 		if optional {
 			return "Boolean"
@@ -299,13 +482,20 @@ func (gen *javaModelGenerator) emitUnion(t *rdl.Type) {
 		case rdl.TypeVariantUnionTypeDef:
 			gen.emitTypeComment(t)
 			ut := t.UnionTypeDef
+			gen.emit(javaDeprecated(ut.Annotations, ""))
 			tName := ut.Name
 			uName := capitalize(string(tName))
 			if gen.jackson {
 				gen.emit("@JsonSerialize(include = JsonSerialize.Inclusion.NON_NULL)\n")
 				gen.emit(fmt.Sprintf("@JsonDeserialize(using = %s.%sJsonDeserializer.class)\n", uName, uName))
 			}
-			gen.emit(fmt.Sprintf("public final class %s {\n", uName))
+			if gen.serializable {
+				gen.emit(fmt.Sprintf("public final class %s implements java.io.Serializable {\n", uName))
+				gen.emitSerialVersionUID(uName, nil, ut.Variants)
+				gen.emit("\n")
+			} else {
+				gen.emit(fmt.Sprintf("public final class %s {\n", uName))
+			}
 			gen.emit(fmt.Sprintf("    public enum %sVariant {\n", uName))
 			for i, vtype := range ut.Variants {
 				if i == 0 {
@@ -316,10 +506,13 @@ func (gen *javaModelGenerator) emitUnion(t *rdl.Type) {
 				gen.emit(fmt.Sprintf("%s", vtype))
 			}
 			gen.emit("\n    }\n\n")
-			gen.emit("    @com.fasterxml.jackson.annotation.JsonIgnore\n")
+			if gen.jackson {
+				gen.emit("    @com.fasterxml.jackson.annotation.JsonIgnore\n")
+			}
 			gen.emit(fmt.Sprintf("    public %sVariant variant;\n\n", uName))
 			for _, v := range ut.Variants {
-				vtype := javaType(gen.registry, v, true, "", "")
+				vtype := gen.javaType(v, true, "", "")
+				gen.emit(javadoc(gen.typeComment(v), "    "))
 				gen.emit(fmt.Sprintf("    @RdlOptional public %s %s;\n", vtype, v))
 			}
 
@@ -341,138 +534,71 @@ func (gen *javaModelGenerator) emitUnion(t *rdl.Type) {
 			gen.emit("        return false;\n")
 			gen.emit("    }\n\n")
 
-			gen.emit(fmt.Sprintf("\n    public static class %sJsonDeserializer extends JsonDeserializer<%s> {\n", uName, uName))
-			gen.emit("        @Override\n")
-			gen.emit(fmt.Sprintf("        public %s deserialize(JsonParser jp, DeserializationContext ctxt) throws IOException, JsonProcessingException {\n", uName))
-			gen.emit("            JsonToken tok = jp.nextToken();\n")
-			gen.emit("            if (tok != JsonToken.FIELD_NAME) {\n")
-			gen.emit(fmt.Sprintf("                throw new IOException(\"Cannot deserialize %s - no valid variant present\");\n", uName))
+			gen.emit("    @Override\n    public int hashCode() {\n")
+			gen.emit("        return java.util.Objects.hash(variant")
+			for _, fname := range ut.Variants {
+				gen.emit(fmt.Sprintf(", %s", fname))
+			}
+			gen.emit(");\n")
+			gen.emit("    }\n\n")
+
+			gen.emit("    @Override\n    public String toString() {\n")
+			gen.emit("        if (variant != null) {\n")
+			gen.emit("            switch (variant) {\n")
+			for _, v := range ut.Variants {
+				gen.emit(fmt.Sprintf("            case %s:\n", v))
+				if gen.isSensitiveType(v) {
+					gen.emit(fmt.Sprintf("                return \"%s{%s=***}\";\n", uName, v))
+				} else {
+					gen.emit(fmt.Sprintf("                return \"%s{%s=\" + %s + \"}\";\n", uName, v, v))
+				}
+			}
 			gen.emit("            }\n")
-			gen.emit("            String svariant = jp.getCurrentName();\n")
-			gen.emit("            tok = jp.nextToken();\n")
-			gen.emit(fmt.Sprintf("            %s t = null;\n", uName))
-
-			var boolVariants []rdl.TypeRef
-			var numberVariants []rdl.TypeRef
-			var stringVariants []rdl.TypeRef
-			var arrayVariants []rdl.TypeRef
-			var objectVariants []rdl.TypeRef
-
-			mapVariants := make(map[string]rdl.BaseType)
-			for _, vtype := range ut.Variants {
-				t := gen.registry.FindType(vtype)
-				if t == nil || t.Variant == 0 {
-					gen.err = fmt.Errorf("Cannot find type '%v'", vtype)
+			gen.emit("        }\n")
+			gen.emit(fmt.Sprintf("        return \"%s{}\";\n", uName))
+			gen.emit("    }\n\n")
+
+			if gen.jackson {
+				gen.emitUnionDeserializer(uName, ut)
+				if gen.err != nil {
 					return
 				}
-				bt := gen.registry.BaseType(t)
-				mapVariants[string(vtype)] = bt
-				switch bt {
-				case rdl.BaseTypeString, rdl.BaseTypeSymbol, rdl.BaseTypeTimestamp, rdl.BaseTypeUUID, rdl.BaseTypeEnum:
-					stringVariants = append(stringVariants, vtype)
-				case rdl.BaseTypeBool:
-					boolVariants = append(boolVariants, vtype)
-				case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
-					numberVariants = append(numberVariants, vtype)
-				case rdl.BaseTypeArray:
-					arrayVariants = append(arrayVariants, vtype)
-				case rdl.BaseTypeMap, rdl.BaseTypeStruct:
-					objectVariants = append(objectVariants, vtype)
-				}
 			}
-			if numberVariants != nil {
-				gen.emit("            if (tok == JsonToken.VALUE_NUMBER_INT || tok == JsonToken.VALUE_NUMBER_FLOAT) {\n")
-				gen.emit("                switch (svariant) {\n")
-				for _, v := range numberVariants {
-					vtype := javaType(gen.registry, v, true, "", "")
-					gen.emit(fmt.Sprintf("                case %q:\n", v))
-					s := vtype
-					if s == "Integer" {
-						s = "Int"
-					}
-					gen.emit(fmt.Sprintf("                    t = new %s(jp.get%sValue());\n", uName, s))
-					gen.emit("                    break;\n")
-				}
-				gen.emit("               default:\n")
-				gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - bad type variant: \" + svariant);\n", uName))
-				gen.emit("                }\n")
-				gen.emit("                tok = jp.nextToken();\n")
-				gen.emit("                return t;\n")
-				gen.emit("            }\n")
-			}
-			if stringVariants != nil {
-				gen.emit("            if (tok == JsonToken.VALUE_STRING) {\n")
-				gen.emit("                switch (svariant) {\n")
-				for _, v := range stringVariants {
-					gen.emit(fmt.Sprintf("                case %q:\n", v))
-					vtype := javaType(gen.registry, v, true, "", "")
-					if vtype == "String" {
-						gen.emit(fmt.Sprintf("                    t = new %s(jp.getText());\n", uName))
-					} else {
-						gen.emit(fmt.Sprintf("                    t = new %s(%s.%s.fromString(jp.getText()));\n", uName, gen.ns, v))
-					}
-					gen.emit("                    break;\n")
-				}
-				gen.emit("                default:\n")
-				gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - bad type variant: \" + svariant);\n", uName))
-				gen.emit("                }\n")
-				gen.emit("                tok = jp.nextToken();\n")
-				gen.emit("                return t;\n")
-				gen.emit("            }\n")
-			}
-			if boolVariants != nil {
-				gen.emit("            if (tok == JsonToken.VALUE_TRUE || tok == JsonToken.VALUE_FALSE) {\n")
-				gen.emit("                switch (svariant) {\n")
-				for _, v := range boolVariants {
-					gen.emit(fmt.Sprintf("                case %q:\n", v))
-					gen.emit(fmt.Sprintf("                    t = new %s(jp.getBooleanValue());\n", uName))
-					gen.emit("                    break;\n")
-				}
-				gen.emit("                default:\n")
-				gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - bad type variant: \" + svariant);\n", uName))
-				gen.emit("                }\n")
-				gen.emit("                tok = jp.nextToken();\n")
-				gen.emit("                return t;\n")
-				gen.emit("            }\n")
-			}
-			if arrayVariants != nil {
-				//gen.emit("            if tok == JsonToken.START_ARRAY {
-				panic("NYI - union of arrays")
-			}
-			if objectVariants != nil {
-				gen.emit("            if (tok == JsonToken.START_OBJECT) {\n")
-				gen.emit("                switch (svariant) {\n")
-				for _, v := range objectVariants {
-					vtype := javaType(gen.registry, v, true, "", "")
-					gen.emit(fmt.Sprintf("                case %q:\n", vtype))
-					gen.emit(fmt.Sprintf("                    t = new %s(jp.readValueAs(%s.class));\n", uName, vtype))
-					gen.emit("                    break;\n")
-				}
-				gen.emit("                default:\n")
-				gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - bad type variant: \" + svariant);\n", uName))
-				gen.emit("                }\n")
-				gen.emit("                if (t != null) {\n")
-				gen.emit("                    tok = jp.nextToken();\n")
-				gen.emit("                    if (tok == JsonToken.END_OBJECT) {\n")
-				gen.emit("                        return t;\n")
-				gen.emit("                    }\n")
-				gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - more than one variant present\");\n", uName))
-				gen.emit("                }\n")
-				gen.emit("            }\n")
-			}
-			gen.emit(fmt.Sprintf("            throw new IOException(\"Cannot deserialize %s - no variant present\");\n", uName))
-			gen.emit("        }\n")
-			gen.emit("    }\n")
+			if !gen.jackson {
+				gen.emitUnionJSON(uName, ut)
+			}
 
 			gen.emit(fmt.Sprintf("\n    public %s() {\n    }\n", uName))
+			//a constructor per variant, unless another variant erases to the same type, e.g.
+			//List<String> and List<Point>, which only the factories tell apart
+			erased := make(map[string]int)
+			for _, v := range ut.Variants {
+				erased[javaErasure(gen.javaType(v, true, "", ""))]++
+			}
 			for _, v := range ut.Variants {
-				vtype := javaType(gen.registry, v, true, "", "")
+				vtype := gen.javaType(v, true, "", "")
 				vname := uncapitalize(string(v))
-				gen.emit(fmt.Sprintf("\n    public %s(%s %s) {\n", uName, vtype, vname))
+				if erased[javaErasure(vtype)] > 1 {
+					continue
+				}
+				gen.emit("\n" + javadoc(gen.typeComment(v), "    "))
+				gen.emit(fmt.Sprintf("    public %s(%s %s) {\n", uName, vtype, vname))
 				gen.emit(fmt.Sprintf("        this.variant = %sVariant.%s;\n", uName, v))
 				gen.emit(fmt.Sprintf("        this.%s = %s;\n", v, vname))
 				gen.emit("    }\n")
 			}
+			for _, v := range ut.Variants {
+				vtype := gen.javaType(v, true, "", "")
+				vname := uncapitalize(string(v))
+				gen.emit(fmt.Sprintf("\n    //\n    // %s returns a %s holding its %s variant\n    //\n", unionFactory(v), uName, v))
+				gen.emit(fmt.Sprintf("    public static %s %s(%s %s) {\n", uName, unionFactory(v), vtype, vname))
+				gen.emit(fmt.Sprintf("        %s u = new %s();\n", uName, uName))
+				gen.emit(fmt.Sprintf("        u.variant = %sVariant.%s;\n", uName, v))
+				gen.emit(fmt.Sprintf("        u.%s = %s;\n", v, vname))
+				gen.emit("        return u;\n")
+				gen.emit("    }\n")
+			}
+			gen.emitUnionAccessors(uName, ut)
 			if false {
 				gen.emit("\n    public String toString() {\n")
 				gen.emit("        switch (variant) {\n")
@@ -491,6 +617,206 @@ func (gen *javaModelGenerator) emitUnion(t *rdl.Type) {
 	}
 }
 
+// unionFactory returns the name of the static method making a union holding the variant.
+func unionFactory(v rdl.TypeRef) string {
+	return "of" + capitalize(string(v))
+}
+
+// javaErasure returns the type the Java type erases to, e.g. java.util.List for
+// java.util.List<String>, which two overloads cannot both take.
+func javaErasure(jtype string) string {
+	if i := strings.Index(jtype, "<"); i >= 0 {
+		return jtype[:i]
+	}
+	return jtype
+}
+
+// emitUnionAccessors emits, for each variant Foo of the union, isFoo() telling whether the union
+// holds it and asFoo() returning it, and the Visitor interface that accept(Visitor) calls the
+// visitFoo method of for the variant the union holds, so that a caller handling every variant is
+// checked by the compiler rather than by a switch on the variant field.
+func (gen *javaModelGenerator) emitUnionAccessors(uName string, ut *rdl.UnionTypeDef) {
+	for _, v := range ut.Variants {
+		vtype := gen.javaType(v, true, "", "")
+		gen.emit(fmt.Sprintf("\n    //\n    // is%s tells whether the union holds its %s variant\n    //\n", v, v))
+		if gen.jackson {
+			gen.emit("    @com.fasterxml.jackson.annotation.JsonIgnore\n")
+		}
+		gen.emit(fmt.Sprintf("    public boolean is%s() {\n        return variant == %sVariant.%s;\n    }\n", v, uName, v))
+		gen.emit(fmt.Sprintf("\n    //\n    // as%s returns the %s variant, failing if the union holds another one\n    //\n", v, v))
+		gen.emit(fmt.Sprintf("    public %s as%s() {\n", vtype, v))
+		gen.emit(fmt.Sprintf("        if (variant != %sVariant.%s) {\n", uName, v))
+		gen.emit(fmt.Sprintf("            throw new IllegalStateException(\"%s holds \" + variant + \", not %s\");\n", uName, v))
+		gen.emit("        }\n")
+		gen.emit(fmt.Sprintf("        return %s;\n", v))
+		gen.emit("    }\n")
+	}
+	gen.emit(fmt.Sprintf("\n    //\n    // Visitor - handles each variant of the %s, returning an R\n    //\n", uName))
+	gen.emit("    public interface Visitor<R> {\n")
+	for _, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("        R visit%s(%s %s);\n", v, gen.javaType(v, true, "", ""), uncapitalize(string(v))))
+	}
+	gen.emit("    }\n")
+	gen.emit("\n    //\n    // accept calls the method of the visitor for the variant the union holds, and returns its result\n    //\n")
+	gen.emit("    public <R> R accept(Visitor<R> visitor) {\n")
+	gen.emit("        if (variant != null) {\n")
+	gen.emit("            switch (variant) {\n")
+	for _, v := range ut.Variants {
+		gen.emit(fmt.Sprintf("            case %s:\n                return visitor.visit%s(%s);\n", v, v, v))
+	}
+	gen.emit("            }\n")
+	gen.emit("        }\n")
+	gen.emit(fmt.Sprintf("        throw new IllegalStateException(\"%s holds no variant\");\n", uName))
+	gen.emit("    }\n")
+}
+
+// emitUnionDeserializer emits the Jackson deserializer of the union, choosing the variant by
+// the name of the only field of the JSON object and the kind of its value.
+func (gen *javaModelGenerator) emitUnionDeserializer(uName string, ut *rdl.UnionTypeDef) {
+	gen.emit(fmt.Sprintf("\n    public static class %sJsonDeserializer extends JsonDeserializer<%s> {\n", uName, uName))
+	gen.emit("        @Override\n")
+	gen.emit(fmt.Sprintf("        public %s deserialize(JsonParser jp, DeserializationContext ctxt) throws IOException, JsonProcessingException {\n", uName))
+	gen.emit("            JsonToken tok = jp.nextToken();\n")
+	gen.emit("            if (tok != JsonToken.FIELD_NAME) {\n")
+	gen.emit(fmt.Sprintf("                throw new IOException(\"Cannot deserialize %s - no valid variant present\");\n", uName))
+	gen.emit("            }\n")
+	gen.emit("            String svariant = jp.getCurrentName();\n")
+	gen.emit("            tok = jp.nextToken();\n")
+	gen.emit(fmt.Sprintf("            %s t = null;\n", uName))
+
+	var boolVariants []rdl.TypeRef
+	var numberVariants []rdl.TypeRef
+	var stringVariants []rdl.TypeRef
+	var arrayVariants []rdl.TypeRef
+	var objectVariants []rdl.TypeRef
+
+	mapVariants := make(map[string]rdl.BaseType)
+	for _, vtype := range ut.Variants {
+		t := gen.registry.FindType(vtype)
+		if t == nil || t.Variant == 0 {
+			gen.err = fmt.Errorf("Cannot find type '%v'", vtype)
+			return
+		}
+		bt := gen.registry.BaseType(t)
+		mapVariants[string(vtype)] = bt
+		switch bt {
+		case rdl.BaseTypeString, rdl.BaseTypeSymbol, rdl.BaseTypeTimestamp, rdl.BaseTypeUUID, rdl.BaseTypeEnum:
+			stringVariants = append(stringVariants, vtype)
+		case rdl.BaseTypeBool:
+			boolVariants = append(boolVariants, vtype)
+		case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+			numberVariants = append(numberVariants, vtype)
+		case rdl.BaseTypeArray:
+			arrayVariants = append(arrayVariants, vtype)
+		case rdl.BaseTypeMap, rdl.BaseTypeStruct:
+			objectVariants = append(objectVariants, vtype)
+		}
+	}
+	if numberVariants != nil {
+		gen.emit("            if (tok == JsonToken.VALUE_NUMBER_INT || tok == JsonToken.VALUE_NUMBER_FLOAT) {\n")
+		gen.emit("                switch (svariant) {\n")
+		for _, v := range numberVariants {
+			vtype := gen.javaType(v, true, "", "")
+			gen.emit(fmt.Sprintf("                case %q:\n", v))
+			s := vtype
+			if s == "Integer" {
+				s = "Int"
+			}
+			gen.emit(fmt.Sprintf("                    t = %s.%s(jp.get%sValue());\n", uName, unionFactory(v), s))
+			gen.emit("                    break;\n")
+		}
+		gen.emit("               default:\n")
+		gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - bad type variant: \" + svariant);\n", uName))
+		gen.emit("                }\n")
+		gen.emit("                tok = jp.nextToken();\n")
+		gen.emit("                return t;\n")
+		gen.emit("            }\n")
+	}
+	if stringVariants != nil {
+		gen.emit("            if (tok == JsonToken.VALUE_STRING) {\n")
+		gen.emit("                switch (svariant) {\n")
+		for _, v := range stringVariants {
+			gen.emit(fmt.Sprintf("                case %q:\n", v))
+			vtype := gen.javaType(v, true, "", "")
+			if vtype == "String" {
+				gen.emit(fmt.Sprintf("                    t = %s.%s(jp.getText());\n", uName, unionFactory(v)))
+			} else {
+				gen.emit(fmt.Sprintf("                    t = %s.%s(%s.%s.fromString(jp.getText()));\n", uName, unionFactory(v), gen.ns, v))
+			}
+			gen.emit("                    break;\n")
+		}
+		gen.emit("                default:\n")
+		gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - bad type variant: \" + svariant);\n", uName))
+		gen.emit("                }\n")
+		gen.emit("                tok = jp.nextToken();\n")
+		gen.emit("                return t;\n")
+		gen.emit("            }\n")
+	}
+	if boolVariants != nil {
+		gen.emit("            if (tok == JsonToken.VALUE_TRUE || tok == JsonToken.VALUE_FALSE) {\n")
+		gen.emit("                switch (svariant) {\n")
+		for _, v := range boolVariants {
+			gen.emit(fmt.Sprintf("                case %q:\n", v))
+			gen.emit(fmt.Sprintf("                    t = %s.%s(jp.getBooleanValue());\n", uName, unionFactory(v)))
+			gen.emit("                    break;\n")
+		}
+		gen.emit("                default:\n")
+		gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - bad type variant: \" + svariant);\n", uName))
+		gen.emit("                }\n")
+		gen.emit("                tok = jp.nextToken();\n")
+		gen.emit("                return t;\n")
+		gen.emit("            }\n")
+	}
+	if arrayVariants != nil {
+		gen.emit("            if (tok == JsonToken.START_ARRAY) {\n")
+		gen.emit("                switch (svariant) {\n")
+		for _, v := range arrayVariants {
+			vtype := gen.javaType(v, true, "", "")
+			gen.emit(fmt.Sprintf("                case %q:\n", v))
+			gen.emit(fmt.Sprintf("                    t = %s.%s(jp.readValueAs(new com.fasterxml.jackson.core.type.TypeReference<%s>() {}));\n", uName, unionFactory(v), vtype))
+			gen.emit("                    break;\n")
+		}
+		gen.emit("                default:\n")
+		gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - bad type variant: \" + svariant);\n", uName))
+		gen.emit("                }\n")
+		gen.emit("                tok = jp.nextToken();\n")
+		gen.emit("                if (tok == JsonToken.END_OBJECT) {\n")
+		gen.emit("                    return t;\n")
+		gen.emit("                }\n")
+		gen.emit(fmt.Sprintf("                throw new IOException(\"Cannot deserialize %s - more than one variant present\");\n", uName))
+		gen.emit("            }\n")
+	}
+	if objectVariants != nil {
+		gen.emit("            if (tok == JsonToken.START_OBJECT) {\n")
+		gen.emit("                switch (svariant) {\n")
+		for _, v := range objectVariants {
+			vtype := gen.javaType(v, true, "", "")
+			gen.emit(fmt.Sprintf("                case %q:\n", v))
+			if vtype != javaErasure(vtype) {
+				//a map, whose class literal cannot say its key and item types
+				gen.emit(fmt.Sprintf("                    t = %s.%s(jp.readValueAs(new com.fasterxml.jackson.core.type.TypeReference<%s>() {}));\n", uName, unionFactory(v), vtype))
+			} else {
+				gen.emit(fmt.Sprintf("                    t = %s.%s(jp.readValueAs(%s.class));\n", uName, unionFactory(v), vtype))
+			}
+			gen.emit("                    break;\n")
+		}
+		gen.emit("                default:\n")
+		gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - bad type variant: \" + svariant);\n", uName))
+		gen.emit("                }\n")
+		gen.emit("                if (t != null) {\n")
+		gen.emit("                    tok = jp.nextToken();\n")
+		gen.emit("                    if (tok == JsonToken.END_OBJECT) {\n")
+		gen.emit("                        return t;\n")
+		gen.emit("                    }\n")
+		gen.emit(fmt.Sprintf("                    throw new IOException(\"Cannot deserialize %s - more than one variant present\");\n", uName))
+		gen.emit("                }\n")
+		gen.emit("            }\n")
+	}
+	gen.emit(fmt.Sprintf("            throw new IOException(\"Cannot deserialize %s - no variant present\");\n", uName))
+	gen.emit("        }\n")
+	gen.emit("    }\n")
+}
+
 func (gen *javaModelGenerator) literal(lit interface{}) string {
 	switch v := lit.(type) {
 	case string:
@@ -518,11 +844,11 @@ func (gen *javaModelGenerator) emitArray(t *rdl.Type) {
 		case rdl.TypeVariantArrayTypeDef:
 			at := t.ArrayTypeDef
 			gen.emitTypeComment(t)
-			ftype := javaType(gen.registry, at.Type, false, at.Items, "")
+			ftype := gen.javaType(at.Type, false, at.Items, "")
 			gen.emit(fmt.Sprintf("type %s %s\n\n", at.Name, ftype))
 		default:
 			tName, tType, _ := rdl.TypeInfo(t)
-			gtype := javaType(gen.registry, tType, false, "", "")
+			gtype := gen.javaType(tType, false, "", "")
 			gen.emitTypeComment(t)
 			gen.emit(fmt.Sprintf("type %s %s\n\n", tName, gtype))
 		}
@@ -534,10 +860,56 @@ func (gen *javaModelGenerator) emitStruct(t *rdl.Type, cName string) {
 		switch t.Variant {
 		case rdl.TypeVariantStructTypeDef:
 			st := t.StructTypeDef
+			if params := templateParams(t); len(params) > 0 {
+				gen.emitTypeComment(t)
+				gen.emitTemplateInterface(t, params)
+				return
+			}
 			f := flattenedFields(gen.registry, t)
+			for _, field := range f {
+				if nullableField(field) {
+					if field.Default != nil {
+						gen.err = fmt.Errorf("Field '%s' of %s is nullable, and cannot have a default", field.Name, st.Name)
+						return
+					}
+					if !gen.jackson {
+						gen.err = fmt.Errorf("Field '%s' of %s is nullable, which needs -x jackson=true", field.Name, st.Name)
+						return
+					}
+				}
+			}
 			gen.emitTypeComment(t)
-			gen.emitStructFields(f, st.Name, st.Comment, cName, st.Closed)
-			if gen.structHasFieldDefault(st) {
+			if gen.records {
+				gen.emit(gen.recordJavadoc(f))
+				gen.emit(javaDeprecated(st.Annotations, ""))
+				gen.emitUnknownFields(st)
+				gen.emitPropertyOrder(f)
+				gen.emitStructRecord(f, cName, gen.implemented(t, cName))
+				if !gen.jackson {
+					gen.emitStructJSON(cName, f, false)
+				}
+				if gen.canonical {
+					gen.emitCanonicalJSON()
+				}
+				if gen.builder {
+					gen.emitStructBuilder(f, cName)
+				}
+				if patchTarget(st) != "" {
+					gen.emitApplyTo(st)
+				}
+				if compared, ok := gen.comparedFields(t); ok {
+					gen.emitCompareTo(cName, compared)
+				}
+				gen.emitTemplateGetters(st, f)
+				gen.emit("}\n")
+				return
+			}
+			gen.emit(javaDeprecated(st.Annotations, ""))
+			gen.emitUnknownFields(st)
+			gen.emitPropertyOrder(f)
+			gen.emitStructFields(f, st.Name, st.Comment, cName, st.Closed, gen.implemented(t, cName))
+			gen.emitExtraFields(st)
+			if gen.structHasFieldDefault(st) && !gen.immutable {
 				gen.emit("\n    //\n    // sets up the instance according to its default field values, if any\n    //\n")
 				gen.emit(fmt.Sprintf("    public %s init() {\n", st.Name))
 				for _, f := range f {
@@ -550,12 +922,33 @@ func (gen *javaModelGenerator) emitStruct(t *rdl.Type, cName string) {
 				gen.emit("        return this;\n")
 				gen.emit("    }\n")
 			}
+			if !gen.jackson {
+				gen.emitStructJSON(cName, f, gen.structHasFieldDefault(st))
+			}
+			if gen.canonical {
+				gen.emitCanonicalJSON()
+			}
+			if gen.builder {
+				gen.emitStructBuilder(f, cName)
+			}
+			if patchTarget(st) != "" {
+				gen.emitApplyTo(st)
+			}
+			if compared, ok := gen.comparedFields(t); ok {
+				gen.emitCompareTo(cName, compared)
+			}
+			gen.emitTemplateGetters(st, f)
 			gen.emit("}\n")
 		case rdl.TypeVariantAliasTypeDef:
 			gen.emitTypeComment(t)
 			at := t.AliasTypeDef
 			var fields []*rdl.StructFieldDef
-			gen.emitStructFields(fields, at.Name, at.Comment, cName, false)
+			gen.emit(javaDeprecated(at.Annotations, ""))
+			var implements []string
+			if gen.serializable {
+				implements = append(implements, "java.io.Serializable")
+			}
+			gen.emitStructFields(fields, at.Name, at.Comment, cName, false, implements)
 			gen.emit("}\n")
 		default:
 			panic(fmt.Sprintf("Unreasonable struct typedef: %v", t.Variant))
@@ -563,12 +956,428 @@ func (gen *javaModelGenerator) emitStruct(t *rdl.Type, cName string) {
 	}
 }
 
+// emitUnknownFields emits how Jackson treats the fields of the JSON object that are not in the
+// closed struct, i.e. it fails on them, or in the open record, i.e. it ignores them, as a record
+// cannot keep them as the class of an open struct does.
+func (gen *javaModelGenerator) emitUnknownFields(st *rdl.StructTypeDef) {
+	if !gen.jackson {
+		return
+	}
+	if st.Closed {
+		gen.emit("@com.fasterxml.jackson.annotation.JsonIgnoreProperties(ignoreUnknown = false)\n")
+	} else if gen.records {
+		gen.emit("@com.fasterxml.jackson.annotation.JsonIgnoreProperties(ignoreUnknown = true)\n")
+	}
+}
+
+// emitPropertyOrder emits the order Jackson writes the fields of the struct in, that of the schema,
+// the inherited fields first, as are the parameters of the constructors and the fields of the
+// Builder, so that the JSON of the instances is the same from one generation to the next.
+func (gen *javaModelGenerator) emitPropertyOrder(fields []*rdl.StructFieldDef) {
+	if !gen.jackson || len(fields) == 0 {
+		return
+	}
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		names = append(names, fmt.Sprintf("%q", f.Name))
+	}
+	gen.emit("@com.fasterxml.jackson.annotation.JsonPropertyOrder({" + strings.Join(names, ", ") + "})\n")
+}
+
+// emitExtraFields emits, for the class of an open struct with Jackson, the map of the fields of
+// the JSON object that are not in the schema, so that they are written back as they were read.
+// They are not compared by equals.
+func (gen *javaModelGenerator) emitExtraFields(st *rdl.StructTypeDef) {
+	if !gen.jackson || st.Closed {
+		return
+	}
+	gen.emit("\n    //\n    // the fields of the JSON object that are not in the schema\n    //\n")
+	gen.emit("    private java.util.Map<String, Object> extraFields;\n")
+	gen.emit("\n    @com.fasterxml.jackson.annotation.JsonAnySetter\n")
+	gen.emit("    public void extraField(String name, Object value) {\n")
+	gen.emit("        if (extraFields == null) {\n")
+	gen.emit("            extraFields = new java.util.LinkedHashMap<>();\n")
+	gen.emit("        }\n")
+	gen.emit("        extraFields.put(name, value);\n")
+	gen.emit("    }\n")
+	gen.emit("\n    @com.fasterxml.jackson.annotation.JsonAnyGetter\n")
+	gen.emit("    public java.util.Map<String, Object> extraFields() {\n")
+	gen.emit("        return extraFields == null ? java.util.Collections.emptyMap() : extraFields;\n")
+	gen.emit("    }\n")
+}
+
+// implemented returns the interfaces the class of the struct implements.
+func (gen *javaModelGenerator) implemented(t *rdl.Type, cName string) []string {
+	var implements []string
+	if gen.isComparableStruct(t) {
+		implements = append(implements, "Comparable<"+cName+">")
+	}
+	if template := gen.templateInterface(t.StructTypeDef); template != "" {
+		implements = append(implements, template)
+	}
+	if gen.serializable {
+		implements = append(implements, "java.io.Serializable")
+	}
+	return implements
+}
+
+func (gen *javaModelGenerator) defaultLiteral(f *rdl.StructFieldDef) string {
+	switch gen.registry.FindBaseType(f.Type) {
+	case rdl.BaseTypeEnum:
+		return fmt.Sprintf("%s.%v", f.Type, f.Default)
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32:
+		return fmt.Sprintf("%d", int64(f.Default.(float64)))
+	case rdl.BaseTypeInt64:
+		return fmt.Sprintf("%dL", int64(f.Default.(float64)))
+	case rdl.BaseTypeFloat32:
+		return fmt.Sprintf("%gf", f.Default.(float64))
+	case rdl.BaseTypeFloat64:
+		return fmt.Sprintf("%gd", f.Default.(float64))
+	}
+	return gen.literal(f.Default)
+}
+
+// emitStructBuilder emits a nested static Builder class for the struct. Field defaults are
+// applied up front, and build() refuses to produce an instance with a required field unset.
+func (gen *javaModelGenerator) emitStructBuilder(fields []*rdl.StructFieldDef, cName string) {
+	gen.emit("\n    public static Builder builder() {\n        return new Builder();\n    }\n")
+	gen.emit(fmt.Sprintf("\n    //\n    // Builder - constructs %s instances, applying field defaults and checking required fields\n    //\n", cName))
+	gen.emit("    public static class Builder {\n")
+	for _, f := range fields {
+		fname := javaFieldName(f.Name)
+		ftype := gen.fieldType(f)
+		if f.Default != nil {
+			gen.emit(fmt.Sprintf("        private %s %s = %s;\n", ftype, fname, gen.defaultLiteral(f)))
+		} else {
+			gen.emit(fmt.Sprintf("        private %s %s;\n", ftype, fname))
+		}
+	}
+	for _, f := range fields {
+		fname := javaFieldName(f.Name)
+		ftype := gen.fieldType(f)
+		gen.emit("\n" + javaDoc(f.Comment, f.Annotations, "        "))
+		gen.emit(fmt.Sprintf("        public Builder %s(%s %s) {\n            this.%s = %s;\n            return this;\n        }\n", fname, ftype, fname, fname, fname))
+	}
+	gen.emit(fmt.Sprintf("\n        public %s build() {\n", cName))
+	for _, f := range fields {
+		if f.Optional || f.Default != nil || gen.isFieldPrimitiveType(f) {
+			continue
+		}
+		fname := javaFieldName(f.Name)
+		gen.emit(fmt.Sprintf("            if (this.%s == null) {\n", fname))
+		gen.emit(fmt.Sprintf("                throw new IllegalStateException(\"%s: required field '%s' is not set\");\n", cName, f.Name))
+		gen.emit("            }\n")
+	}
+	if gen.records || gen.immutable {
+		args := make([]string, 0, len(fields))
+		for _, f := range fields {
+			args = append(args, "this."+javaFieldName(f.Name))
+		}
+		gen.emit(fmt.Sprintf("            return new %s(%s);\n", cName, strings.Join(args, ", ")))
+	} else {
+		gen.emit(fmt.Sprintf("            %s built = new %s();\n", cName, cName))
+		for _, f := range fields {
+			fname := javaFieldName(f.Name)
+			gen.emit(fmt.Sprintf("            built.%s = this.%s;\n", fname, fname))
+		}
+		gen.emit("            return built;\n")
+	}
+	gen.emit("        }\n")
+	gen.emit("    }\n")
+}
+
+// javaType returns the java type of the RDL type in the model. With -x timestamp=instant, a
+// Timestamp is a java.time.Instant, which Jackson maps to the same ISO 8601 strings with the
+// JavaTimeModule registered, and with -x uuid=java, a UUID is a java.util.UUID, which Jackson
+// and the toJSON/fromJSON methods of jackson=false map to the same strings as the rdl UUID.
+func (gen *javaModelGenerator) javaType(rdlType rdl.TypeRef, optional bool, items rdl.TypeRef, keys rdl.TypeRef) string {
+	jtype := javaType(gen.registry, rdlType, optional, items, keys)
+	if gen.instant {
+		jtype = javaTimestampPattern.ReplaceAllString(jtype, "java.time.Instant")
+	}
+	if gen.utilUUIDs {
+		jtype = javaUUIDPattern.ReplaceAllString(jtype, "java.util.UUID")
+	}
+	return jtype
+}
+
+var javaTimestampPattern = regexp.MustCompile(`\bTimestamp\b`)
+var javaUUIDPattern = regexp.MustCompile(`\bUUID\b`)
+
+// instantFormat returns the Jackson annotation writing the Instant of the field as a string
+// rather than as a number, or "" if the field holds no Instant.
+func (gen *javaModelGenerator) instantFormat(f *rdl.StructFieldDef) string {
+	if gen.instant && javaTimestampPattern.MatchString(javaType(gen.registry, f.Type, true, f.Items, f.Keys)) {
+		return "@com.fasterxml.jackson.annotation.JsonFormat(shape = com.fasterxml.jackson.annotation.JsonFormat.Shape.STRING)"
+	}
+	return ""
+}
+
+// fieldType returns the java type of a struct field. Record components with a default are
+// boxed, so that the compact constructor can tell when the field was left unset. A field with
+// x_nullable is a java.util.Optional: null when the field is absent, and Optional.empty() when it
+// is present as an explicit null. Jackson needs the Jdk8Module registered to map them so.
+func (gen *javaModelGenerator) fieldType(f *rdl.StructFieldDef) string {
+	if nullableField(f) {
+		return "java.util.Optional<" + gen.javaType(f.Type, true, f.Items, f.Keys) + ">"
+	}
+	return gen.javaType(f.Type, f.Optional || (gen.records && f.Default != nil), f.Items, f.Keys)
+}
+
+// recordJavadoc returns the Javadoc of a record, documenting its commented components with @param
+// tags, as components cannot carry Javadoc of their own.
+func (gen *javaModelGenerator) recordJavadoc(fields []*rdl.StructFieldDef) string {
+	var params []string
+	for _, f := range fields {
+		if comment := strings.TrimSpace(f.Comment); comment != "" {
+			params = append(params, javadoc("@param "+javaFieldName(f.Name)+" "+comment, ""))
+		}
+	}
+	if params == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	buf.WriteString("/**\n")
+	for _, p := range params {
+		lines := strings.Split(strings.TrimSuffix(p, "\n"), "\n")
+		buf.WriteString(strings.Join(lines[1:len(lines)-1], "\n") + "\n")
+	}
+	buf.WriteString(" */\n")
+	return buf.String()
+}
+
+// emitStructRecord emits the struct as an immutable record. The compact canonical constructor
+// applies field defaults, rejects missing required fields, and makes unmodifiable copies of
+// array and map fields. The closing brace is left to the caller.
+func (gen *javaModelGenerator) emitStructRecord(fields []*rdl.StructFieldDef, cName string, implements []string) {
+	if gen.jackson {
+		gen.emit("@JsonSerialize(include = JsonSerialize.Inclusion.NON_DEFAULT)\n")
+	}
+	gen.emit(fmt.Sprintf("public record %s(", cName))
+	for i, f := range fields {
+		if i > 0 {
+			gen.emit(",")
+		}
+		gen.emit("\n    ")
+		if _, ok := deprecation(f.Annotations); ok {
+			gen.emit("@Deprecated ")
+		}
+		if f.Optional {
+			gen.emit("@RdlOptional ")
+		}
+		if gen.jackson {
+			gen.emit(fmt.Sprintf("@com.fasterxml.jackson.annotation.JsonProperty(%q) ", f.Name))
+			if nullableField(f) {
+				gen.emit(javaNullableInclusion + " ")
+			}
+			if format := gen.instantFormat(f); format != "" {
+				gen.emit(format + " ")
+			}
+		}
+		for _, a := range gen.validationAnnotations(f) {
+			gen.emit(a + " ")
+		}
+		gen.emit(fmt.Sprintf("%s %s", gen.fieldType(f), javaFieldName(f.Name)))
+	}
+	if len(implements) > 0 {
+		gen.emit(") implements " + strings.Join(implements, ", ") + " {\n")
+	} else {
+		gen.emit(") {\n")
+	}
+	gen.emitSerialVersionUID(cName, fields, nil)
+	if len(fields) == 0 {
+		return
+	}
+	gen.emit(fmt.Sprintf("\n    public %s {\n", cName))
+	for _, f := range fields {
+		fname := javaFieldName(f.Name)
+		if f.Default != nil {
+			gen.emit(fmt.Sprintf("        if (%s == null) {\n", fname))
+			gen.emit(fmt.Sprintf("            %s = %s;\n", fname, gen.defaultLiteral(f)))
+			gen.emit("        }\n")
+		} else if !f.Optional && !gen.isFieldPrimitiveType(f) {
+			gen.emit(fmt.Sprintf("        if (%s == null) {\n", fname))
+			gen.emit(fmt.Sprintf("            throw new IllegalArgumentException(\"%s: required field '%s' is missing\");\n", cName, f.Name))
+			gen.emit("        }\n")
+		}
+		copy := gen.collectionCopy(f, fname, true)
+		if copy != "" {
+			if f.Optional {
+				copy = fname + " == null ? null : " + copy
+			}
+			gen.emit(fmt.Sprintf("        %s = %s;\n", fname, copy))
+		}
+	}
+	gen.emit("    }\n")
+	for _, f := range fields {
+		if gen.isSensitive(f) || gen.isBytesField(f) {
+			//the generated toString of a record would print every component, and arrays by reference
+			gen.emit("\n")
+			gen.emitToString(cName, fields)
+			break
+		}
+	}
+	for _, f := range fields {
+		if gen.isBytesField(f) {
+			gen.emitRecordEquals(cName, fields)
+			break
+		}
+	}
+}
+
+// isBytesField tells whether the field is a byte[], which equals, hashCode, and toString must
+// treat by its contents rather than by reference.
+func (gen *javaModelGenerator) isBytesField(f *rdl.StructFieldDef) bool {
+	return !nullableField(f) && gen.registry.FindBaseType(f.Type) == rdl.BaseTypeBytes
+}
+
+// hashExpr returns the expression the generated hashCode hashes the field by: a byte[] by its
+// contents, as equals compares them.
+func (gen *javaModelGenerator) hashExpr(f *rdl.StructFieldDef) string {
+	fname := javaFieldName(f.Name)
+	if gen.isBytesField(f) {
+		return "java.util.Arrays.hashCode(" + fname + ")"
+	}
+	return fname
+}
+
+// emitRecordEquals emits the equals and hashCode of a record with byte[] components, which the
+// generated ones compare by reference.
+func (gen *javaModelGenerator) emitRecordEquals(cName string, fields []*rdl.StructFieldDef) {
+	gen.emit("\n    @Override\n    public boolean equals(Object another) {\n")
+	gen.emit("        if (this == another) {\n            return true;\n        }\n")
+	gen.emit(fmt.Sprintf("        if (!(another instanceof %s)) {\n            return false;\n        }\n", cName))
+	gen.emit(fmt.Sprintf("        %s a = (%s) another;\n", cName, cName))
+	var conditions []string
+	hashed := make([]string, 0, len(fields))
+	for _, f := range fields {
+		fname := javaFieldName(f.Name)
+		hashed = append(hashed, gen.hashExpr(f))
+		if gen.isBytesField(f) {
+			conditions = append(conditions, fmt.Sprintf("java.util.Arrays.equals(%s, a.%s)", fname, fname))
+		} else if gen.isFieldPrimitiveType(f) && f.Default == nil {
+			conditions = append(conditions, fmt.Sprintf("%s == a.%s", fname, fname))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("java.util.Objects.equals(%s, a.%s)", fname, fname))
+		}
+	}
+	gen.emit("        return " + strings.Join(conditions, "\n            && ") + ";\n")
+	gen.emit("    }\n")
+	gen.emit("\n    @Override\n    public int hashCode() {\n")
+	gen.emit(fmt.Sprintf("        return java.util.Objects.hash(%s);\n", strings.Join(hashed, ", ")))
+	gen.emit("    }\n")
+}
+
+// validationAnnotations returns the Bean Validation annotations for the constraints of the
+// field's type. Required fields without a default are @NotNull, and fields holding structs or
+// unions are @Valid, so that validation cascades into them.
+func (gen *javaModelGenerator) validationAnnotations(f *rdl.StructFieldDef) []string {
+	if gen.validation == "" {
+		return nil
+	}
+	var annotations []string
+	if !f.Optional && f.Default == nil && !gen.isFieldPrimitiveType(f) {
+		annotations = append(annotations, "@NotNull")
+	}
+	t := gen.registry.FindType(f.Type)
+	if t == nil || nullableField(f) {
+		//the constraints apply to the value, not to the Optional holding it
+		return annotations
+	}
+	sizeAnnotation := func(size, minSize, maxSize *int32) {
+		if size != nil {
+			minSize, maxSize = size, size
+		}
+		if minSize != nil && maxSize != nil {
+			annotations = append(annotations, fmt.Sprintf("@Size(min = %d, max = %d)", *minSize, *maxSize))
+		} else if minSize != nil {
+			annotations = append(annotations, fmt.Sprintf("@Size(min = %d)", *minSize))
+		} else if maxSize != nil {
+			annotations = append(annotations, fmt.Sprintf("@Size(max = %d)", *maxSize))
+		}
+	}
+	cascade := func(items rdl.TypeRef) {
+		switch gen.registry.FindBaseType(items) {
+		case rdl.BaseTypeStruct, rdl.BaseTypeUnion:
+			if items != "Struct" {
+				annotations = append(annotations, "@Valid")
+			}
+		}
+	}
+	switch bt := gen.registry.BaseType(t); bt {
+	case rdl.BaseTypeString:
+		pattern, values, minSize, maxSize := stringConstraints(gen.registry, t)
+		if values != nil {
+			quoted := make([]string, 0, len(values))
+			for _, v := range values {
+				quoted = append(quoted, regexp.QuoteMeta(v))
+			}
+			annotations = append(annotations, fmt.Sprintf("@Pattern(regexp = %q)", strings.Join(quoted, "|")))
+		}
+		if pattern != "" {
+			annotations = append(annotations, fmt.Sprintf("@Pattern(regexp = %q)", pattern))
+		}
+		sizeAnnotation(nil, minSize, maxSize)
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+		suffix := ""
+		if bt == rdl.BaseTypeInt64 {
+			suffix = "L"
+		}
+		min, max := numberConstraints(gen.registry, t)
+		if min != nil {
+			annotations = append(annotations, fmt.Sprintf("@Min(%s%s)", numericValueString(*min), suffix))
+		}
+		if max != nil {
+			annotations = append(annotations, fmt.Sprintf("@Max(%s%s)", numericValueString(*max), suffix))
+		}
+	case rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		min, max := numberConstraints(gen.registry, t)
+		if min != nil {
+			annotations = append(annotations, fmt.Sprintf("@DecimalMin(%q)", numericValueString(*min)))
+		}
+		if max != nil {
+			annotations = append(annotations, fmt.Sprintf("@DecimalMax(%q)", numericValueString(*max)))
+		}
+	case rdl.BaseTypeBytes:
+		sizeAnnotation(bytesConstraints(gen.registry, t))
+	case rdl.BaseTypeArray:
+		items := f.Items
+		if t.Variant == rdl.TypeVariantArrayTypeDef {
+			at := t.ArrayTypeDef
+			items = at.Items
+			sizeAnnotation(at.Size, at.MinSize, at.MaxSize)
+		}
+		cascade(items)
+	case rdl.BaseTypeMap:
+		items := f.Items
+		if t.Variant == rdl.TypeVariantMapTypeDef {
+			mt := t.MapTypeDef
+			items = mt.Items
+			sizeAnnotation(mt.Size, mt.MinSize, mt.MaxSize)
+		}
+		cascade(items)
+	case rdl.BaseTypeStruct, rdl.BaseTypeUnion:
+		cascade(f.Type)
+	}
+	return annotations
+}
+
 func (gen *javaModelGenerator) emitEnum(t *rdl.Type) {
 	if gen.err != nil {
 		return
 	}
 	et := t.EnumTypeDef
+	if err := checkEnumNames(et); err != nil {
+		gen.err = err
+		return
+	}
+	gen.emit(javaDeprecated(et.Annotations, ""))
 	name := capitalize(string(et.Name))
+	var values []int
+	if gen.hasEnumValues(et) {
+		values = gen.enumValues(et)
+	}
+	wire := hasEnumWireNames(et)
 	gen.emit(fmt.Sprintf("public enum %s {", name))
 	for i, elem := range et.Elements {
 		sym := elem.Symbol
@@ -577,20 +1386,86 @@ func (gen *javaModelGenerator) emitEnum(t *rdl.Type) {
 		} else {
 			gen.emit("\n")
 		}
-		gen.emit(fmt.Sprintf("    %s", sym))
+		gen.emit(javaDoc(elem.Comment, elem.Annotations, "    "))
+		var args []string
+		if values != nil {
+			args = append(args, fmt.Sprint(values[i]))
+		}
+		if wire {
+			args = append(args, fmt.Sprintf("%q", enumWireName(elem)))
+		}
+		if args != nil {
+			gen.emit(fmt.Sprintf("    %s(%s)", sym, strings.Join(args, ", ")))
+		} else {
+			gen.emit(fmt.Sprintf("    %s", sym))
+		}
 	}
 	gen.emit(";\n")
-	gen.emit(fmt.Sprintf("\n    public static %s fromString(String v) {\n", name))
+	if values != nil || wire {
+		var params []string
+		gen.emit("\n")
+		if values != nil {
+			gen.emit("    private final int value;\n")
+			params = append(params, "int value")
+		}
+		if wire {
+			gen.emit("    private final String wire;\n")
+			params = append(params, "String wire")
+		}
+		gen.emit(fmt.Sprintf("\n    %s(%s) {\n", name, strings.Join(params, ", ")))
+		if values != nil {
+			gen.emit("        this.value = value;\n")
+		}
+		if wire {
+			gen.emit("        this.wire = wire;\n")
+		}
+		gen.emit("    }\n")
+	}
+	if values != nil {
+		gen.emit("\n    //\n    // value returns the value the constant is ordered by, which unlike its ordinal does not change\n")
+		gen.emit("    // as constants are added or reordered\n    //\n")
+		gen.emit("    public int value() {\n        return value;\n    }\n")
+	}
+	if wire {
+		gen.emit("\n    //\n    // toString returns the string the constant is written as, which may differ from its name\n    //\n")
+		gen.emit("    @Override\n")
+		if gen.jackson {
+			gen.emit("    @com.fasterxml.jackson.annotation.JsonValue\n")
+		}
+		gen.emit("    public String toString() {\n        return wire;\n    }\n")
+	}
+	aliases := hasEnumReadNames(et)
+	gen.emit("\n")
+	if gen.jackson && (wire || aliases) {
+		gen.emit("    @com.fasterxml.jackson.annotation.JsonCreator\n")
+	}
+	gen.emit(fmt.Sprintf("    public static %s fromString(String v) {\n", name))
 	gen.emit(fmt.Sprintf("        for (%s e : values()) {\n", name))
 	gen.emit("            if (e.toString().equals(v)) {\n")
 	gen.emit("                return e;\n")
 	gen.emit("            }\n")
 	gen.emit("        }\n")
+	if aliases {
+		gen.emit("        switch (v == null ? \"\" : v) {\n")
+		for _, elem := range et.Elements {
+			for _, alias := range enumReadNames(elem) {
+				gen.emit(fmt.Sprintf("        case %q:\n", alias))
+			}
+			if len(enumReadNames(elem)) > 0 {
+				gen.emit(fmt.Sprintf("            return %s;\n", elem.Symbol))
+			}
+		}
+		gen.emit("        }\n")
+	}
 	gen.emit(fmt.Sprintf("        throw new IllegalArgumentException(\"Invalid string representation for %s: \" + v);\n", name))
 	gen.emit("    }\n")
 	gen.emit("}\n")
 }
 
+// javaNullableInclusion overrides the NON_DEFAULT inclusion of the class for a nullable field, so
+// that Optional.empty() is written as null, and only a null field is left out.
+const javaNullableInclusion = "@com.fasterxml.jackson.annotation.JsonInclude(com.fasterxml.jackson.annotation.JsonInclude.Include.NON_NULL)"
+
 func javaFieldName(n rdl.Identifier) string {
 	if n == "default" {
 		return "_default"
@@ -598,7 +1473,7 @@ func javaFieldName(n rdl.Identifier) string {
 	return string(n)
 }
 
-func (gen *javaModelGenerator) emitStructFields(fields []*rdl.StructFieldDef, name rdl.TypeName, comment string, cName string, bfinal bool) {
+func (gen *javaModelGenerator) emitStructFields(fields []*rdl.StructFieldDef, name rdl.TypeName, comment string, cName string, bfinal bool, implements []string) {
 	if gen.jackson {
 		gen.emit("@JsonSerialize(include = JsonSerialize.Inclusion.NON_DEFAULT)\n")
 	}
@@ -606,7 +1481,15 @@ func (gen *javaModelGenerator) emitStructFields(fields []*rdl.StructFieldDef, na
 	if bfinal {
 		sfinal = "final "
 	}
-	gen.emit(fmt.Sprintf("public %sclass %s {\n", sfinal, name))
+	if len(implements) > 0 {
+		gen.emit(fmt.Sprintf("public %sclass %s implements %s {\n", sfinal, name, strings.Join(implements, ", ")))
+	} else {
+		gen.emit(fmt.Sprintf("public %sclass %s {\n", sfinal, name))
+	}
+	gen.emitSerialVersionUID(string(name), fields, nil)
+	if gen.serializable && len(fields) > 0 {
+		gen.emit("\n")
+	}
 	if fields != nil {
 		fnames := make([]string, 0, len(fields))
 		ftypes := make([]string, 0, len(fields))
@@ -614,25 +1497,82 @@ func (gen *javaModelGenerator) emitStructFields(fields []*rdl.StructFieldDef, na
 			fname := javaFieldName(f.Name)
 			fnames = append(fnames, fname)
 			optional := f.Optional
-			ftype := javaType(gen.registry, f.Type, optional, f.Items, f.Keys)
+			ftype := gen.fieldType(f)
 			ftypes = append(ftypes, ftype)
-			if fname != string(f.Name) {
+			//with optionals or getters-setters, fields are private, so Jackson needs to be told about them
+			private := (optional && gen.optionals && !nullableField(f)) || gen.beans
+			gen.emit(javaDoc(f.Comment, f.Annotations, "    "))
+			if gen.jackson && (fname != string(f.Name) || private) {
 				gen.emit(fmt.Sprintf("    @com.fasterxml.jackson.annotation.JsonProperty(%q)\n", f.Name))
 			}
+			if gen.jackson && nullableField(f) {
+				gen.emit("    " + javaNullableInclusion + "\n")
+			}
+			if format := gen.instantFormat(f); format != "" {
+				gen.emit("    " + format + "\n")
+			}
+			if as := gen.deserializeAs(f); as != "" {
+				gen.emit("    " + as + "\n")
+			}
 			if optional {
 				gen.emit("    @RdlOptional\n")
 			}
-			gen.emit(fmt.Sprintf("    public %s %s;\n", ftype, fname))
+			for _, a := range gen.validationAnnotations(f) {
+				gen.emit("    " + a + "\n")
+			}
+			final := ""
+			if gen.immutable {
+				final = "final "
+			}
+			if private {
+				gen.emit(fmt.Sprintf("    private %s%s %s;\n", final, ftype, fname))
+			} else {
+				gen.emit(fmt.Sprintf("    public %s%s %s;\n", final, ftype, fname))
+			}
 		}
 		gen.emit("\n")
-		for i := range fields {
+		if gen.immutable {
+			gen.emitCreatorConstructor(fields, ftypes, name, cName)
+		} else if gen.strict {
+			gen.emitStrictConstructors(fields, ftypes, name, cName)
+		}
+		for i, f := range fields {
 			fname := fnames[i]
 			ftype := ftypes[i]
-			if gen.getSetters {
-				gen.emit(fmt.Sprintf("    public %s set%s(%s %s) {\n        this.%s = %s;\n        return this;\n    }\n", cName, capitalize(fname), ftype, fname, fname, fname))
-				gen.emit(fmt.Sprintf("    public %s get%s() {\n        return %s;\n    }\n", ftype, capitalize(fname), fname))
+			doc := javaDoc(f.Comment, f.Annotations, "    ")
+			if gen.immutable {
+				if f.Optional && gen.optionals && !nullableField(f) {
+					gen.emit(doc)
+					gen.emitOptionalGetter(fname, ftype, fname)
+				}
+			} else if gen.getSetters {
+				gen.emit(doc)
+				gen.emit(fmt.Sprintf("    public %s set%s(%s %s) {\n", cName, capitalize(fname), ftype, fname))
+				gen.emitNullCheck(f, cName, "        ")
+				gen.emit(fmt.Sprintf("        this.%s = %s;\n        return this;\n    }\n", fname, fname))
+				gen.emit(doc)
+				if f.Optional && gen.optionals && !nullableField(f) {
+					gen.emitOptionalGetter("get"+capitalize(fname), ftype, gen.returned(f, fname))
+				} else {
+					gen.emit(fmt.Sprintf("    public %s get%s() {\n        return %s;\n    }\n", ftype, capitalize(fname), gen.returned(f, fname)))
+				}
 			} else {
-				gen.emit(fmt.Sprintf("    public %s %s(%s %s) {\n        this.%s = %s;\n        return this;\n    }\n", cName, fname, ftype, fname, fname, fname))
+				gen.emit(doc)
+				gen.emit(fmt.Sprintf("    public %s %s(%s %s) {\n", cName, fname, ftype, fname))
+				gen.emitNullCheck(f, cName, "        ")
+				gen.emit(fmt.Sprintf("        this.%s = %s;\n        return this;\n    }\n", fname, fname))
+				if gen.beans {
+					gen.emit(doc)
+					gen.emit(fmt.Sprintf("    public %s %s() {\n        return %s;\n    }\n", ftype, javaBeanGetter(ftype, fname), gen.returned(f, fname)))
+					gen.emit(doc)
+					gen.emit(fmt.Sprintf("    public void set%s(%s %s) {\n", capitalize(fname), ftype, fname))
+					gen.emitNullCheck(f, cName, "        ")
+					gen.emit(fmt.Sprintf("        this.%s = %s;\n    }\n", fname, fname))
+				}
+				if f.Optional && gen.optionals && !nullableField(f) {
+					gen.emit(doc)
+					gen.emitOptionalGetter(fname, ftype, gen.returned(f, fname))
+				}
 			}
 		}
 		gen.emit("\n")
@@ -642,11 +1582,14 @@ func (gen *javaModelGenerator) emitStructFields(fields []*rdl.StructFieldDef, na
 		gen.emit("                return false;\n")
 		gen.emit("            }\n")
 		gen.emit(fmt.Sprintf("            %s a = (%s) another;\n", name, name))
+		hashed := make([]string, 0, len(fields))
 		for _, f := range fields {
 			fname := javaFieldName(f.Name)
-			fnames = append(fnames, fname)
+			hashed = append(hashed, gen.hashExpr(f))
 			if gen.isFieldPrimitiveType(f) {
 				gen.emit(fmt.Sprintf("            if (%s != a.%s) {\n", fname, fname))
+			} else if gen.isBytesField(f) {
+				gen.emit(fmt.Sprintf("            if (!java.util.Arrays.equals(%s, a.%s)) {\n", fname, fname))
 			} else {
 				gen.emit(fmt.Sprintf("            if (%s == null ? a.%s != null : !%s.equals(a.%s)) {\n", fname, fname, fname, fname))
 			}
@@ -656,5 +1599,155 @@ func (gen *javaModelGenerator) emitStructFields(fields []*rdl.StructFieldDef, na
 		gen.emit("        }\n")
 		gen.emit("        return true;\n")
 		gen.emit("    }\n")
+		gen.emit("\n")
+		gen.emit("    @Override\n    public int hashCode() {\n")
+		gen.emit(fmt.Sprintf("        return java.util.Objects.hash(%s);\n", strings.Join(hashed, ", ")))
+		gen.emit("    }\n")
+		gen.emit("\n")
+		gen.emitToString(cName, fields)
+	}
+}
+
+// isStrictField tells whether the field is checked for null in strict mode, i.e. it is required,
+// has no default, and is not of a primitive type.
+func (gen *javaModelGenerator) isStrictField(f *rdl.StructFieldDef) bool {
+	return gen.strict && !f.Optional && f.Default == nil && !gen.isFieldPrimitiveType(f)
+}
+
+// emitNullCheck emits the statement rejecting a null value for the field, if it is checked.
+func (gen *javaModelGenerator) emitNullCheck(f *rdl.StructFieldDef, cName string, indent string) {
+	if !gen.isStrictField(f) {
+		return
+	}
+	fname := javaFieldName(f.Name)
+	gen.emit(fmt.Sprintf("%sif (%s == null) {\n", indent, fname))
+	gen.emit(fmt.Sprintf("%s    throw new IllegalArgumentException(\"%s: required field '%s' cannot be null\");\n", indent, cName, f.Name))
+	gen.emit(indent + "}\n")
+}
+
+// emitStrictConstructors emits, for a struct with required fields, a constructor taking them
+// and rejecting nulls, besides the no-argument constructor that deserialization relies on.
+func (gen *javaModelGenerator) emitStrictConstructors(fields []*rdl.StructFieldDef, ftypes []string, name rdl.TypeName, cName string) {
+	var params []string
+	var required []*rdl.StructFieldDef
+	for i, f := range fields {
+		if !f.Optional && f.Default == nil {
+			params = append(params, ftypes[i]+" "+javaFieldName(f.Name))
+			required = append(required, f)
+		}
+	}
+	if len(required) == 0 {
+		return
+	}
+	gen.emit(fmt.Sprintf("    public %s() {\n    }\n\n", name))
+	gen.emit(fmt.Sprintf("    public %s(%s) {\n", name, strings.Join(params, ", ")))
+	for _, f := range required {
+		gen.emitNullCheck(f, cName, "        ")
+	}
+	for _, f := range required {
+		fname := javaFieldName(f.Name)
+		gen.emit(fmt.Sprintf("        this.%s = %s;\n", fname, fname))
+	}
+	gen.emit("    }\n\n")
+}
+
+// emitCreatorConstructor emits the constructor of an immutable class (-x immutable=true), taking
+// all of its fields, which Jackson deserializes it with as the fields are final. A field with a
+// default is passed boxed, so that the default applies when it is null, e.g. absent from the JSON,
+// and in strict mode a required field cannot be null.
+func (gen *javaModelGenerator) emitCreatorConstructor(fields []*rdl.StructFieldDef, ftypes []string, name rdl.TypeName, cName string) {
+	if len(fields) == 0 {
+		return
+	}
+	params := make([]string, 0, len(fields))
+	for i, f := range fields {
+		ptype := ftypes[i]
+		if f.Default != nil {
+			ptype = gen.javaType(f.Type, true, f.Items, f.Keys)
+		}
+		params = append(params, fmt.Sprintf("@com.fasterxml.jackson.annotation.JsonProperty(%q) %s %s", f.Name, ptype, javaFieldName(f.Name)))
+	}
+	gen.emit("    @com.fasterxml.jackson.annotation.JsonCreator(mode = com.fasterxml.jackson.annotation.JsonCreator.Mode.PROPERTIES)\n")
+	gen.emit(fmt.Sprintf("    public %s(\n            %s) {\n", name, strings.Join(params, ",\n            ")))
+	for _, f := range fields {
+		gen.emitNullCheck(f, cName, "        ")
+	}
+	for _, f := range fields {
+		fname := javaFieldName(f.Name)
+		if f.Default != nil {
+			gen.emit(fmt.Sprintf("        this.%s = %s == null ? %s : %s;\n", fname, fname, gen.defaultLiteral(f), fname))
+		} else if copy := gen.collectionCopy(f, fname, gen.unmodifiable); copy != "" && (gen.unmodifiable || gen.hasCollectionImpl(f)) {
+			gen.emit(fmt.Sprintf("        this.%s = %s == null ? null : %s;\n", fname, fname, copy))
+		} else {
+			gen.emit(fmt.Sprintf("        this.%s = %s;\n", fname, fname))
+		}
+	}
+	gen.emit("    }\n")
+}
+
+// isSensitive returns true if the value of the field must not be printed, i.e. the field or its
+// type has the x_sensitive annotation.
+func (gen *javaModelGenerator) isSensitive(f *rdl.StructFieldDef) bool {
+	if v, ok := f.Annotations["x_sensitive"]; ok {
+		return v != "false"
+	}
+	return gen.isSensitiveType(f.Type)
+}
+
+func (gen *javaModelGenerator) isSensitiveType(tref rdl.TypeRef) bool {
+	for t := gen.registry.FindType(tref); t != nil; {
+		if v, ok := typeAnnotations(t)["x_sensitive"]; ok {
+			return v != "false"
+		}
+		switch t.Variant {
+		case rdl.TypeVariantStringTypeDef:
+			t = gen.registry.FindType(t.StringTypeDef.Type)
+		case rdl.TypeVariantAliasTypeDef:
+			t = gen.registry.FindType(t.AliasTypeDef.Type)
+		default:
+			t = nil
+		}
+	}
+	return false
+}
+
+// emitToString emits a toString() listing the fields, with the values of sensitive fields
+// printed as "***", so that logging an instance does not leak them.
+func (gen *javaModelGenerator) emitToString(cName string, fields []*rdl.StructFieldDef) {
+	gen.emit("    @Override\n    public String toString() {\n")
+	gen.emit(fmt.Sprintf("        return \"%s{\"\n", cName))
+	for i, f := range fields {
+		sep := ""
+		if i > 0 {
+			sep = ", "
+		}
+		if gen.isSensitive(f) {
+			gen.emit(fmt.Sprintf("            + \"%s%s=***\"\n", sep, f.Name))
+		} else if gen.isBytesField(f) {
+			gen.emit(fmt.Sprintf("            + \"%s%s=\" + java.util.Arrays.toString(%s)\n", sep, f.Name, javaFieldName(f.Name)))
+		} else {
+			gen.emit(fmt.Sprintf("            + \"%s%s=\" + %s\n", sep, f.Name, javaFieldName(f.Name)))
+		}
+	}
+	gen.emit("            + \"}\";\n")
+	gen.emit("    }\n")
+}
+
+// emitOptionalGetter emits the getter of an optional field as a java.util.Optional. The getter
+// is hidden from Jackson, the field itself is serialized, so the JSON is the same whether or not
+// the ObjectMapper has the Jdk8Module registered.
+// javaBeanGetter returns the name of the JavaBean getter of the field, i.e. isX for a boolean
+// and getX for anything else.
+func javaBeanGetter(ftype string, fname string) string {
+	if ftype == "boolean" {
+		return "is" + capitalize(fname)
+	}
+	return "get" + capitalize(fname)
+}
+
+func (gen *javaModelGenerator) emitOptionalGetter(getter string, ftype string, value string) {
+	if gen.jackson {
+		gen.emit("    @com.fasterxml.jackson.annotation.JsonIgnore\n")
 	}
+	gen.emit(fmt.Sprintf("    public java.util.Optional<%s> %s() {\n        return java.util.Optional.ofNullable(%s);\n    }\n", ftype, getter, value))
 }
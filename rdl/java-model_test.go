@@ -0,0 +1,85 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const arrayUnionSchema = `name Sample;
+
+type Point Struct {
+    Int32 x;
+    Int32 y;
+}
+
+type Names Array<String>;
+type Points Array<Point>;
+type Counts Map<String,Int32>;
+type PointsByName Map<String,Point>;
+
+type Thing Union<Names,Points,Counts,PointsByName,Point>;
+`
+
+// generatedJavaClass generates the java-model of the schema, and returns the source of the class.
+func generatedJavaClass(t *testing.T, source string, class string, options []string) string {
+	dir, err := ioutil.TempDir("", "rdl-java-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	schema := parseTestSchema(t, dir, "sample.rdl", source)
+	out := filepath.Join(dir, "out")
+	if err := GenerateJavaModel("rdl", schema, out, "", options); err != nil {
+		t.Fatal(err)
+	}
+	var java []byte
+	err = filepath.Walk(out, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Name() == class+".java" {
+			java, err = ioutil.ReadFile(path)
+		}
+		return err
+	})
+	if err != nil || java == nil {
+		t.Fatalf("no %s.java generated: %v", class, err)
+	}
+	return string(java)
+}
+
+var javaUnionConstructor = regexp.MustCompile(`(?m)^    public Thing\(([^ )]+)`)
+
+func TestJavaUnionOfArrays(t *testing.T) {
+	for _, options := range [][]string{nil, {"jackson=false"}} {
+		java := generatedJavaClass(t, arrayUnionSchema, "Thing", options)
+		//the variants of the same erased type, e.g. List<String> and List<Point>, cannot both
+		//have a constructor
+		erased := make(map[string]bool)
+		for _, m := range javaUnionConstructor.FindAllStringSubmatch(java, -1) {
+			if e := javaErasure(m[1]); erased[e] {
+				t.Errorf("%v: two constructors of Thing take a %s\n%s", options, e, java)
+			} else {
+				erased[e] = true
+			}
+		}
+		for _, want := range []string{
+			"public static Thing ofNames(List<String> names)",
+			"public static Thing ofPoints(List<Point> points)",
+			"public static Thing ofCounts(Map<String, Integer> counts)",
+			"public static Thing ofPointsByName(Map<String, Point> pointsByName)",
+			"public Thing(Point point)",
+		} {
+			if !strings.Contains(java, want) {
+				t.Errorf("%v: Thing has no %s\n%s", options, want, java)
+			}
+		}
+		if strings.Contains(java, ">.class") {
+			t.Errorf("%v: Thing takes the class literal of a generic type\n%s", options, java)
+		}
+	}
+}
@@ -210,7 +210,7 @@ func javaGenerateStructTypeConstructor(reg rdl.TypeRegistry, t *rdl.StructTypeDe
 
 func javaGenerateResourceConstructor(reg rdl.TypeRegistry, rez *rdl.Resource) string {
 	rTypeName := rez.Type
-	if rez.Method == "PUT" || rez.Method == "POST" {
+	if rez.Method == "PUT" || rez.Method == "POST" || rez.Method == "PATCH" {
 		for _, ri := range rez.Inputs {
 			if !ri.PathParam && ri.QueryParam == "" && ri.Header == "" {
 				rTypeName = ri.Type
@@ -0,0 +1,174 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"github.com/ardielle/ardielle-go/rdl"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//
+// The exceptions of the java server. For each type the resources declare as an exception, a
+// FooException class (a ResourceException holding a Foo) is generated, with a factory method for
+// each status code it is declared with, e.g. FooException.notFound(foo). The generated resources
+// answer the exceptions a handler throws with the status codes declared for them, and a
+// <Name>ExceptionMapper (JAX-RS) or <Name>ControllerAdvice (Spring) does the same for those
+// thrown outside of them, e.g. by filters or when completing an async request.
+//
+
+// javaServerException is an exception type of the schema, with the codes it is declared with.
+type javaServerException struct {
+	Type  string
+	Class string
+	Codes []string
+}
+
+// javaServerExceptions returns the exception types the resources declare, sorted by name.
+func javaServerExceptions(schema *rdl.Schema) []*javaServerException {
+	byType := make(map[string]*javaServerException)
+	for _, r := range schema.Resources {
+		for code, e := range r.Exceptions {
+			x, ok := byType[e.Type]
+			if !ok {
+				x = &javaServerException{Type: e.Type, Class: e.Type + "Exception"}
+				byType[e.Type] = x
+			}
+			if !containsString(x.Codes, code) {
+				x.Codes = append(x.Codes, code)
+			}
+		}
+	}
+	names := make([]string, 0, len(byType))
+	for name := range byType {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	exceptions := make([]*javaServerException, 0, len(names))
+	for _, name := range names {
+		x := byType[name]
+		sort.Strings(x.Codes)
+		exceptions = append(exceptions, x)
+	}
+	return exceptions
+}
+
+// javaCodeMethodName turns a status code name, e.g. NOT_FOUND, into the name of its factory
+// method, e.g. notFound.
+func javaCodeMethodName(code string) string {
+	words := strings.Split(strings.ToLower(code), "_")
+	for i := 1; i < len(words); i++ {
+		words[i] = capitalize(words[i])
+	}
+	return strings.Join(words, "")
+}
+
+func javaServerGenerateExceptions(banner string, schema *rdl.Schema, packageDir string, ns string, spring bool, jakarta bool) error {
+	funcMap := template.FuncMap{
+		"header":     func() string { return javaGenerationHeader(banner) },
+		"package":    func() string { return javaGenerationPackage(schema, ns) },
+		"cName":      func() string { return capitalize(string(schema.Name)) },
+		"methodName": javaCodeMethodName,
+	}
+	for _, x := range javaServerExceptions(schema) {
+		out, file, _, err := outputWriter(packageDir, x.Class, ".java")
+		if err != nil {
+			return err
+		}
+		t := template.Must(template.New(x.Class).Funcs(funcMap).Parse(javaServerExceptionTemplate))
+		err = t.Execute(out, x)
+		out.Flush()
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	cName := capitalize(string(schema.Name))
+	name, source := "ExceptionMapper", javaServerExceptionMapperTemplate
+	if spring {
+		name, source = "ControllerAdvice", javaServerControllerAdviceTemplate
+	}
+	out, file, _, err := outputWriter(packageDir, cName, name+".java")
+	if err != nil {
+		return err
+	}
+	t := template.Must(template.New(name).Funcs(funcMap).Parse(javaEE(source, jakarta)))
+	err = t.Execute(out, schema)
+	out.Flush()
+	file.Close()
+	return err
+}
+
+const javaServerExceptionTemplate = `{{header}}
+package {{package}};
+
+//
+// {{.Class}} - a ResourceException with a {{.Type}} as its data
+//
+public class {{.Class}} extends ResourceException {
+
+    public {{.Class}}(int code, {{.Type}} data) {
+        super(code, data);
+    }
+
+    public {{.Type}} get{{.Type}}() {
+        return ({{.Type}}) getData();
+    }
+{{range .Codes}}
+    public static {{$.Class}} {{methodName .}}({{$.Type}} data) {
+        return new {{$.Class}}({{.}}, data);
+    }
+{{end}}}
+`
+
+const javaServerExceptionMapperTemplate = `{{header}}
+package {{package}};
+import javax.ws.rs.core.MediaType;
+import javax.ws.rs.core.Response;
+import javax.ws.rs.ext.ExceptionMapper;
+import javax.ws.rs.ext.Provider;
+
+//
+// {{cName}}ExceptionMapper - responds to a ResourceException with its status code and data
+//
+@Provider
+public class {{cName}}ExceptionMapper implements ExceptionMapper<ResourceException> {
+
+    @Override
+    public Response toResponse(ResourceException e) {
+        int code = e.getCode();
+        Object data = e.getData();
+        if (data == null) {
+            data = new ResourceError().code(code).message(ResourceException.codeToString(code));
+        }
+        return Response.status(code).entity(data).type(MediaType.APPLICATION_JSON).build();
+    }
+}
+`
+
+const javaServerControllerAdviceTemplate = `{{header}}
+package {{package}};
+import org.springframework.http.MediaType;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.ControllerAdvice;
+import org.springframework.web.bind.annotation.ExceptionHandler;
+
+//
+// {{cName}}ControllerAdvice - responds to a ResourceException with its status code and data
+//
+@ControllerAdvice
+public class {{cName}}ControllerAdvice {
+
+    @ExceptionHandler(ResourceException.class)
+    public ResponseEntity<Object> resourceException(ResourceException e) {
+        int code = e.getCode();
+        Object data = e.getData();
+        if (data == null) {
+            data = new ResourceError().code(code).message(ResourceException.codeToString(code));
+        }
+        return ResponseEntity.status(code).contentType(MediaType.APPLICATION_JSON).body(data);
+    }
+}
+`
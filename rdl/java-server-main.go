@@ -0,0 +1,194 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"github.com/ardielle/ardielle-go/rdl"
+	"text/template"
+)
+
+//
+// The runnable main class of the java server (-x main=true). The generated FooMain runs the
+// JAX-RS resources in an embedded Jetty 9.4 server, with command line flags for the HTTP and
+// HTTPS ports and the keystore, so that a jar with FooMain as its Main-Class runs the service
+// with java -jar. The implementation of FooHandler is loaded by class name, FooHandlerImpl in
+// the package of the server unless --handler names another, and needs a public no-arg
+// constructor.
+//
+
+const javaServerMainTemplate = `{{header}}
+package {{package}};
+import org.eclipse.jetty.http.HttpVersion;
+import org.eclipse.jetty.server.HttpConfiguration;
+import org.eclipse.jetty.server.HttpConnectionFactory;
+import org.eclipse.jetty.server.SecureRequestCustomizer;
+import org.eclipse.jetty.server.Server;
+import org.eclipse.jetty.server.ServerConnector;
+import org.eclipse.jetty.server.SslConnectionFactory;
+import org.eclipse.jetty.servlet.ServletContextHandler;
+import org.eclipse.jetty.servlet.ServletHolder;
+import org.eclipse.jetty.util.ssl.SslContextFactory;
+import org.glassfish.hk2.utilities.binding.AbstractBinder;
+import org.glassfish.jersey.server.ResourceConfig;
+import org.glassfish.jersey.servlet.ServletContainer;
+
+//
+// {{cName}}Main runs the {{cName}} service in an embedded Jetty server:
+//
+//   java -jar {{name}}.jar [--host HOST] [--port PORT] [--tls-port PORT --keystore FILE
+//       [--keystore-password PASSWORD]] [--handler CLASS]
+//
+// The port defaults to 8080, and 0 turns plain HTTP off. With --tls-port, HTTPS is served with
+// the key in the keystore, whose password defaults to the KEYSTORE_PASSWORD environment variable.
+// The handler is an implementation of {{cName}}Handler with a public no-arg constructor,
+// {{package}}.{{cName}}HandlerImpl by default.
+//
+public class {{cName}}Main {
+    String host;
+    int port = 8080;
+    int tlsPort;
+    String keystore;
+    String keystorePassword = System.getenv("KEYSTORE_PASSWORD");
+    String handlerClass = "{{package}}.{{cName}}HandlerImpl";
+
+    public static void main(String[] args) {
+        {{cName}}Main main = new {{cName}}Main();
+        try {
+            main.parse(args);
+        } catch (IllegalArgumentException e) {
+            System.err.println("*** " + e.getMessage());
+            usage();
+            System.exit(2);
+        }
+        try {
+            Server server = main.server(main.handler());
+            server.start();
+            server.join();
+        } catch (Exception e) {
+            System.err.println("*** " + e);
+            System.exit(1);
+        }
+    }
+
+    static void usage() {
+        System.err.println("usage: {{cName}}Main [--host HOST] [--port PORT] [--tls-port PORT --keystore FILE [--keystore-password PASSWORD]] [--handler CLASS]");
+    }
+
+    void parse(String[] args) {
+        for (int i = 0; i < args.length; i++) {
+            String flag = args[i];
+            if (flag.equals("-h") || flag.equals("--help")) {
+                usage();
+                System.exit(0);
+            }
+            if (i + 1 >= args.length) {
+                throw new IllegalArgumentException("Missing value for " + flag);
+            }
+            String value = args[++i];
+            switch (flag) {
+            case "--host":
+                host = value;
+                break;
+            case "--port":
+                port = portNumber(flag, value);
+                break;
+            case "--tls-port":
+                tlsPort = portNumber(flag, value);
+                break;
+            case "--keystore":
+                keystore = value;
+                break;
+            case "--keystore-password":
+                keystorePassword = value;
+                break;
+            case "--handler":
+                handlerClass = value;
+                break;
+            default:
+                throw new IllegalArgumentException("Unknown flag " + flag);
+            }
+        }
+        if (tlsPort > 0 && keystore == null) {
+            throw new IllegalArgumentException("--tls-port needs a --keystore");
+        }
+        if (port == 0 && tlsPort == 0) {
+            throw new IllegalArgumentException("No port to serve on");
+        }
+    }
+
+    static int portNumber(String flag, String value) {
+        try {
+            int port = Integer.parseInt(value);
+            if (port >= 0 && port <= 65535) {
+                return port;
+            }
+        } catch (NumberFormatException e) {
+            //reported below
+        }
+        throw new IllegalArgumentException("Bad port for " + flag + ": " + value);
+    }
+
+    {{cName}}Handler handler() throws ReflectiveOperationException {
+        return Class.forName(handlerClass).asSubclass({{cName}}Handler.class).getDeclaredConstructor().newInstance();
+    }
+
+    Server server({{cName}}Handler impl) {
+        Server server = new Server();
+        HttpConfiguration http = new HttpConfiguration();
+        if (port > 0) {
+            ServerConnector connector = new ServerConnector(server, new HttpConnectionFactory(http));
+            connector.setHost(host);
+            connector.setPort(port);
+            server.addConnector(connector);
+        }
+        if (tlsPort > 0) {
+            SslContextFactory.Server ssl = new SslContextFactory.Server();
+            ssl.setKeyStorePath(keystore);
+            if (keystorePassword != null) {
+                ssl.setKeyStorePassword(keystorePassword);
+            }
+            HttpConfiguration https = new HttpConfiguration(http);
+            https.setSecurePort(tlsPort);
+            https.addCustomizer(new SecureRequestCustomizer());
+            ServerConnector connector = new ServerConnector(server,
+                new SslConnectionFactory(ssl, HttpVersion.HTTP_1_1.asString()),
+                new HttpConnectionFactory(https));
+            connector.setHost(host);
+            connector.setPort(tlsPort);
+            server.addConnector(connector);
+        }
+        ServletContextHandler context = new ServletContextHandler();
+        context.setContextPath("");
+        ResourceConfig config = new ResourceConfig({{cName}}Resources.class, {{cName}}ExceptionMapper.class).register(new AbstractBinder() {
+            @Override
+            protected void configure() {
+                bind(impl).to({{cName}}Handler.class);
+            }
+        });
+        context.addServlet(new ServletHolder(new ServletContainer(config)), "/*");
+        server.setHandler(context);
+        server.setStopAtShutdown(true);
+        return server;
+    }
+}
+`
+
+// javaServerGenerateMain generates the FooMain class running the server in an embedded Jetty.
+func javaServerGenerateMain(banner string, schema *rdl.Schema, packageDir string, cName string, ns string) error {
+	out, file, _, err := outputWriter(packageDir, cName, "Main.java")
+	if err != nil {
+		return err
+	}
+	funcMap := template.FuncMap{
+		"header":  func() string { return javaGenerationHeader(banner) },
+		"package": func() string { return javaGenerationPackage(schema, ns) },
+		"cName":   func() string { return cName },
+		"name":    func() string { return string(schema.Name) },
+	}
+	t := template.Must(template.New(cName).Funcs(funcMap).Parse(javaServerMainTemplate))
+	err = t.Execute(out, schema)
+	out.Flush()
+	file.Close()
+	return err
+}
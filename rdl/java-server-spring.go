@@ -0,0 +1,371 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"os"
+	"sort"
+	"strings"
+)
+
+//
+// The Spring MVC flavor of the java server (-x server-flavor=spring). The handler interface,
+// ResourceContext, and the model classes are shared with the JAX-RS flavor, only the glue
+// between the HTTP layer and the handler differs.
+//
+
+const javaServerSpringTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+import java.util.*;
+import javax.servlet.http.HttpServletRequest;
+import javax.servlet.http.HttpServletResponse;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.http.MediaType;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;{{asyncImports}}
+
+@RestController
+@RequestMapping("{{rootPath}}")
+public class {{cName}}Controller {
+{{range .Resources}}
+{{deprecated .}}    {{springHandlerSig .}} {{openBrace}}
+{{springHandlerBody .}}    }
+{{end}}
+
+    ResponseEntity<Object> typedException(int code, ResourceException e, Class<?> eClass) {
+        Object data = e.getData();
+        Object entity = eClass.isInstance(data) ? data : null;
+        if (entity != null) {
+            return ResponseEntity.status(code).body(entity);
+        } else {
+            return ResponseEntity.status(code).build();
+        }
+    }
+{{if stages}}{{resourceException}}{{end}}{{if streams}}
+    void writeEvents(java.io.OutputStream out, Iterator<?> events) throws java.io.IOException {
+{{writeEvents}}    }
+{{end}}
+    @Autowired private {{cName}}Handler delegate;
+
+}
+`
+
+const javaServerSpringResultTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+import java.util.*;
+import org.springframework.http.ResponseEntity;
+
+public final class {{rName}} {
+    private ResourceContext context;
+    private ResponseEntity<Object> response;
+
+    {{rName}}(ResourceContext context) {
+        this.context = context;
+    }
+
+    public boolean isAsync() {
+        return false;
+    }
+
+    public void done(int code, {{cName}} {{name}}{{range headerParamsSig}}, {{.}}{{end}}) {
+        this.response = ResponseEntity.status(code){{headerAssign}}
+            .body((Object) {{name}});
+    }
+
+    public void done(int code) {
+        done(code, new ResourceError().code(code).message(ResourceException.codeToString(code)){{range headerParams}}, ""{{end}});
+    }
+{{if headerParams}}
+    public void done(int code{{range headerParamsSig}}, {{.}}{{end}}) {
+        done(code, new ResourceError().code(code).message(ResourceException.codeToString(code)){{range headerParams}}, {{.}}{{end}});
+    }
+{{end}}
+    public void done(int code, Object entity{{range headerParamsSig}}, {{.}}{{end}}) {
+        //to do: check if the exception is declared, and that the entity is of the declared type
+        this.response = ResponseEntity.status(code){{headerAssign}}
+            .body(entity);
+    }
+
+    ResponseEntity<Object> response() {
+        if (response == null) {
+            return ResponseEntity.noContent().build();
+        }
+        return response;
+    }
+
+}
+`
+
+const javaServerSpringAsyncResultTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+import java.util.*;
+import java.util.concurrent.Executors;
+import java.util.concurrent.ScheduledExecutorService;
+import java.util.concurrent.TimeUnit;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.context.request.async.DeferredResult;
+
+public final class {{rName}} {
+    private DeferredResult<ResponseEntity<Object>> async;
+    private ResourceContext context;{{pathParamsDecls}}
+    private int code; //normal result
+    private int timeoutCode;
+
+    {{rName}}(ResourceContext context, {{range pathParamsSig}}{{.}}, {{end}}DeferredResult<ResponseEntity<Object>> async) {
+        this.context = context;
+        this.async = async;{{pathParamsAssign}}
+        this.code = 0;
+        this.timeoutCode = 0;
+    }
+
+    public boolean isAsync() {
+        return async != null;
+    }
+
+    public void done(int code, {{cName}} {{name}}{{range headerParamsSig}}, {{.}}{{end}}) {
+        async.setResult(ResponseEntity.status(code){{headerAssign}}
+            .body((Object) {{name}}));
+    }
+
+    public void done(int code) {
+        done(code, new ResourceError().code(code).message(ResourceException.codeToString(code)){{range headerParams}}, ""{{end}});
+    }
+{{if headerParams}}
+    public void done(int code{{range headerParamsSig}}, {{.}}{{end}}) {
+        done(code, new ResourceError().code(code).message(ResourceException.codeToString(code)){{range headerParams}}, {{.}}{{end}});
+    }
+{{end}}
+    public void done(int code, Object entity{{range headerParamsSig}}, {{.}}{{end}}) {
+        this.code = code;
+        //to do: check if the exception is declared, and that the entity is of the declared type
+        async.setResult(ResponseEntity.status(code){{headerAssign}}
+            .body(entity));
+    }
+
+    private static Map<String, Set<{{rName}}>> waiters = new HashMap<String, Set<{{rName}}>>();
+    private static ScheduledExecutorService timer = Executors.newSingleThreadScheduledExecutor();
+
+    public void wait({{range pathParamsSig}}{{.}}, {{end}}int timeout, int normalStatus, int timeoutStatus) {
+        this.code = normalStatus;
+        this.timeoutCode = timeoutStatus;
+        synchronized (waiters) {
+            Set<{{rName}}> s = waiters.get({{pathParamsKey}});
+            if (s == null) {
+                s = new HashSet<{{rName}}>();
+                waiters.put({{pathParamsKey}}, s);
+            }
+            s.add(this);
+        }
+        timer.schedule(() -> handleTimeout(), timeout, TimeUnit.SECONDS);
+    }
+
+    void handleTimeout() {
+        //the timeout is per-request.
+        boolean waiting = false;
+        synchronized (waiters) {
+            Set<{{rName}}> s = waiters.get({{pathParamsKey}});
+            if (s != null) {
+                waiting = s.remove(this);
+            }
+        }
+        if (waiting) {
+            done(timeoutCode);
+        }
+    }
+
+    //this get called to notifyAll of changed state
+    public static void notify({{range pathParamsSig}}{{.}}, {{end}}{{resultSig}}) {
+        Collection<{{rName}}> results = null;
+        synchronized (waiters) {
+            results = waiters.remove({{pathParamsKey}});
+        }
+        if (results != null) {
+            for ({{rName}} result : results) {
+                result.done(result.code, {{resultArgs}});
+            }
+        }
+    }
+}
+`
+
+func (gen *javaServerGenerator) springHandlerSignature(r *rdl.Resource) string {
+	reg := gen.registry
+	returnType := "ResponseEntity<Object>"
+	if r.Async != nil && *r.Async && !streamsEvents(r) {
+		returnType = "DeferredResult<ResponseEntity<Object>>"
+	} else if gen.returnsStage(r) {
+		returnType = stageType("ResponseEntity<Object>")
+	}
+	var params []string
+	for _, v := range r.Inputs {
+		if v.Context != "" { //ignore these ones
+			fmt.Fprintln(os.Stderr, "Warning: v1 style context param ignored:", v.Name, v.Context)
+			continue
+		}
+		k := v.Name
+		pdecl := ""
+		if v.QueryParam != "" {
+			pdecl = fmt.Sprintf("@RequestParam(value = %q, required = false", v.QueryParam)
+			if v.Default != nil {
+				pdecl += ", defaultValue = " + defaultValueString(v.Default)
+			}
+			pdecl += ") "
+		} else if v.PathParam {
+			pdecl = fmt.Sprintf("@PathVariable(%q) ", k)
+		} else if v.Header != "" {
+			pdecl = fmt.Sprintf("@RequestHeader(value = %q, required = false) ", v.Header)
+		} else {
+			pdecl = "@RequestBody "
+		}
+		ptype := javaType(reg, v.Type, true, "", "")
+		params = append(params, pdecl+ptype+" "+javaName(k))
+	}
+	params = append(params, "HttpServletRequest request", "HttpServletResponse response")
+	spec := fmt.Sprintf("@RequestMapping(method = RequestMethod.%s, value = %q, produces = MediaType.APPLICATION_JSON_VALUE", strings.ToUpper(r.Method), gen.resourcePath(r))
+	if streamsEvents(r) {
+		spec = strings.Replace(spec, "produces = MediaType.APPLICATION_JSON_VALUE", "produces = {\"text/event-stream\", MediaType.APPLICATION_JSON_VALUE}", 1)
+	}
+	switch r.Method {
+	case "POST", "PUT", "PATCH":
+		spec += ", consumes = MediaType.APPLICATION_JSON_VALUE"
+	}
+	spec += ")\n"
+	methName, _ := javaMethodName(reg, r)
+	return spec + "    public " + returnType + " " + methName + "(" + strings.Join(params, ", ") + ")"
+}
+
+func (gen *javaServerGenerator) springHandlerBody(r *rdl.Resource) string {
+	stream := streamsEvents(r)
+	async := r.Async != nil && *r.Async && !stream
+	resultWrapper := (len(r.Outputs) > 0 || async) && !stream
+	stage := gen.returnsStage(r)
+	returnType := javaType(gen.registry, r.Type, false, "", "")
+	s := ""
+	if async {
+		s += "        DeferredResult<ResponseEntity<Object>> asyncResp = new DeferredResult<ResponseEntity<Object>>();\n"
+	}
+	s += "        try {\n"
+	s += "            ResourceContext context = this.delegate.newResourceContext(request, response);\n"
+	s += gen.handlerAuth(r)
+	if call := gen.validatorCall(r); call != "" {
+		s += call
+		s += "            if (invalid != null) {\n"
+		if async {
+			s += "                asyncResp.setResult(ResponseEntity.status(ResourceException.BAD_REQUEST).body((Object) invalid));\n"
+			s += "                return asyncResp;\n"
+		} else if stage {
+			s += "                return java.util.concurrent.CompletableFuture.completedFuture(ResponseEntity.status(ResourceException.BAD_REQUEST).body((Object) invalid));\n"
+		} else {
+			s += "                return ResponseEntity.status(ResourceException.BAD_REQUEST).body((Object) invalid);\n"
+		}
+		s += "            }\n"
+	}
+	var fargs []string
+	for _, in := range r.Inputs {
+		if in.Context == "" {
+			fargs = append(fargs, javaName(in.Name))
+		}
+	}
+	methName, _ := javaMethodName(gen.registry, r)
+	sargs := ""
+	if len(fargs) > 0 {
+		sargs = ", " + strings.Join(fargs, ", ")
+	}
+	if stream {
+		s += "            Iterator<" + javaType(gen.registry, r.Type, true, "", "") + "> events = this.delegate." + methName + "(context" + sargs + ");\n"
+		s += "            response.setContentType(\"text/event-stream\");\n"
+		s += "            response.setHeader(\"Cache-Control\", \"no-cache\");\n"
+		s += "            try {\n"
+		s += "                writeEvents(response.getOutputStream(), events);\n"
+		s += "            } catch (java.io.IOException ioe) {\n"
+		s += "                //the client went away\n"
+		s += "            }\n"
+		s += "            return null;\n"
+	} else if resultWrapper {
+		rName := capitalize(methName) + "Result"
+		if async {
+			pathParamsArgs := ""
+			for _, a := range gen.makePathParamsArgs(r) {
+				pathParamsArgs += a + ", "
+			}
+			s += "            " + rName + " result = new " + rName + "(context, " + pathParamsArgs + "asyncResp);\n"
+		} else {
+			s += "            " + rName + " result = new " + rName + "(context);\n"
+		}
+		s += "            this.delegate." + methName + "(context" + sargs + ", result);\n"
+		if async {
+			s += "            return asyncResp;\n"
+		} else {
+			s += "            return result.response();\n"
+		}
+	} else if stage {
+		s += "            return this.delegate." + methName + "(context" + sargs + ").thenApply(e -> "
+		if r.Expected == "NO_CONTENT" && r.Alternatives == nil {
+			s += "ResponseEntity.status(ResourceException.NO_CONTENT).<Object>build())"
+		} else {
+			s += "ResponseEntity.status(ResourceException." + r.Expected + ").body((Object) e))"
+		}
+		s += ".exceptionally(x -> {\n"
+		s += "                ResourceException e = resourceException(x);\n"
+		s += "                int code = e.getCode();\n"
+		s += "                ResponseEntity<Object> failure;\n"
+		s += gen.springFailureSwitch(r, methName, returnType, "                ")
+		s += "                return failure;\n"
+		s += "            });\n"
+	} else {
+		s += "            " + returnType + " e = this.delegate." + methName + "(context" + sargs + ");\n"
+		if r.Expected == "NO_CONTENT" && r.Alternatives == nil {
+			s += "            return ResponseEntity.status(ResourceException.NO_CONTENT).build();\n"
+		} else {
+			s += "            return ResponseEntity.status(ResourceException." + r.Expected + ").body((Object) e);\n"
+		}
+	}
+	s += "        } catch (ResourceException e) {\n"
+	s += "            int code = e.getCode();\n"
+	s += "            ResponseEntity<Object> failure;\n"
+	s += gen.springFailureSwitch(r, methName, returnType, "            ")
+	if async {
+		s += "            asyncResp.setResult(failure);\n"
+		s += "            return asyncResp;\n"
+	} else if stage {
+		s += "            return java.util.concurrent.CompletableFuture.completedFuture(failure);\n"
+	} else {
+		s += "            return failure;\n"
+	}
+	s += "        }\n"
+	return s
+}
+
+// springFailureSwitch returns the switch on the code of the ResourceException e, setting failure
+// to the response of its declared type, at the indentation.
+func (gen *javaServerGenerator) springFailureSwitch(r *rdl.Resource, methName string, returnType string, indent string) string {
+	s := indent + "switch (code) {\n"
+	if len(r.Alternatives) > 0 {
+		for _, alt := range r.Alternatives {
+			s += indent + "case ResourceException." + alt + ":\n"
+		}
+		s += indent + "    failure = typedException(code, e, " + returnType + ".class);\n"
+		s += indent + "    break;\n"
+	}
+	ecodes := make([]string, 0, len(r.Exceptions))
+	for ecode := range r.Exceptions {
+		ecodes = append(ecodes, ecode)
+	}
+	sort.Strings(ecodes)
+	for _, ecode := range ecodes {
+		s += indent + "case ResourceException." + ecode + ":\n"
+		s += indent + "    failure = typedException(code, e, " + r.Exceptions[ecode].Type + ".class);\n"
+		s += indent + "    break;\n"
+	}
+	s += indent + "default:\n"
+	s += indent + "    System.err.println(\"*** Warning: undeclared exception (\" + code + \") for resource " + methName + "\");\n"
+	s += indent + "    failure = typedException(code, e, ResourceError.class);\n"
+	s += indent + "}\n"
+	return s
+}
@@ -0,0 +1,401 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+	"text/template"
+)
+
+//
+// The request validator of the java server (-x validate=true). The generated FooValidator class
+// checks the parameters and body of each request against the patterns, sizes, and ranges of
+// their RDL types, and for missing required fields, before the handler is invoked. A request
+// that fails is answered with a 400 and a ResourceError listing every violation found.
+//
+
+const javaServerValidatorTemplate = `{{header}}
+package {{package}};
+import com.yahoo.rdl.*;
+import java.util.*;
+import java.util.regex.Pattern;
+
+public final class {{cName}}Validator {
+{{patterns}}
+    private {{cName}}Validator() {
+    }
+{{resourceChecks}}{{typeChecks}}
+    static ResourceError invalid(List<String> errors) {
+        if (errors.isEmpty()) {
+            return null;
+        }
+        return new ResourceError().code(ResourceException.BAD_REQUEST).message(String.join("; ", errors));
+    }
+}
+`
+
+type javaValidatorGenerator struct {
+	registry   rdl.TypeRegistry
+	schema     *rdl.Schema
+	name       string
+	banner     string
+	ns         string
+	records    bool
+	getSetters bool
+	beans      bool
+	optionals  bool
+	needs      map[rdl.TypeRef]bool
+	depth      int
+}
+
+func javaServerGenerateValidator(banner string, schema *rdl.Schema, reg rdl.TypeRegistry, packageDir string, cName string, ns string, options []string) error {
+	out, file, _, err := outputWriter(packageDir, cName, "Validator.java")
+	if err != nil {
+		return err
+	}
+	gen := &javaValidatorGenerator{
+		registry:   reg,
+		schema:     schema,
+		name:       cName,
+		banner:     banner,
+		ns:         ns,
+		records:    javaGenerationBoolOptionSet(options, "records"),
+		getSetters: javaGenerationBoolOptionSet(options, "getsetters"),
+		beans:      javaGenerationBoolOptionSet(options, "getters-setters"),
+		optionals:  javaGenerationBoolOptionSet(options, "optionals"),
+		needs:      make(map[rdl.TypeRef]bool),
+	}
+	funcMap := template.FuncMap{
+		"header":         func() string { return javaGenerationHeader(gen.banner) },
+		"package":        func() string { return javaGenerationPackage(gen.schema, gen.ns) },
+		"cName":          func() string { return gen.name },
+		"patterns":       gen.patterns,
+		"resourceChecks": gen.resourceChecks,
+		"typeChecks":     gen.typeChecks,
+	}
+	t := template.Must(template.New(cName).Funcs(funcMap).Parse(javaServerValidatorTemplate))
+	err = t.Execute(out, schema)
+	out.Flush()
+	file.Close()
+	return err
+}
+
+// resolve returns the type an alias refers to, Java has no aliases.
+func (gen *javaValidatorGenerator) resolve(tref rdl.TypeRef) (rdl.TypeRef, *rdl.Type) {
+	t := gen.registry.FindType(tref)
+	for t != nil && t.Variant == rdl.TypeVariantAliasTypeDef {
+		tref = t.AliasTypeDef.Type
+		t = gen.registry.FindType(tref)
+	}
+	return tref, t
+}
+
+// needsCheck returns true if values of the type have constraints or required fields to check.
+// Structs always do, a recursive reference is assumed to be checked while the check is pending.
+func (gen *javaValidatorGenerator) needsCheck(tref rdl.TypeRef) bool {
+	tref, t := gen.resolve(tref)
+	if t == nil || strings.HasPrefix(string(tref), "rdl.") {
+		return false
+	}
+	if b, ok := gen.needs[tref]; ok {
+		return b
+	}
+	gen.needs[tref] = true
+	b := false
+	switch t.Variant {
+	case rdl.TypeVariantStringTypeDef, rdl.TypeVariantNumberTypeDef:
+		pattern, values, minSize, maxSize := stringConstraints(gen.registry, t)
+		min, max := numberConstraints(gen.registry, t)
+		b = pattern != "" || values != nil || minSize != nil || maxSize != nil || min != nil || max != nil
+	case rdl.TypeVariantArrayTypeDef:
+		at := t.ArrayTypeDef
+		b = at.MinSize != nil || at.MaxSize != nil || gen.needsCheck(at.Items)
+	case rdl.TypeVariantMapTypeDef:
+		mt := t.MapTypeDef
+		b = mt.MinSize != nil || mt.MaxSize != nil || gen.needsCheck(mt.Keys) || gen.needsCheck(mt.Items)
+	case rdl.TypeVariantStructTypeDef:
+		b = t.StructTypeDef.Name != "Struct"
+	}
+	gen.needs[tref] = b
+	return b
+}
+
+// checkedTypes returns the names of the types that get a check method, in schema order.
+func (gen *javaValidatorGenerator) checkedTypes() []*rdl.Type {
+	var types []*rdl.Type
+	for _, t := range gen.schema.Types {
+		tName, _, _ := rdl.TypeInfo(t)
+		if t.Variant != rdl.TypeVariantAliasTypeDef && gen.needsCheck(rdl.TypeRef(tName)) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func (gen *javaValidatorGenerator) patterns() string {
+	s := ""
+	for _, t := range gen.checkedTypes() {
+		if t.Variant == rdl.TypeVariantStringTypeDef {
+			pattern, _, _, _ := stringConstraints(gen.registry, t)
+			if pattern != "" {
+				s += fmt.Sprintf("    private static final Pattern PATTERN_%s = Pattern.compile(%q);\n", t.StringTypeDef.Name, "^"+pattern+"$")
+			}
+		}
+	}
+	return s
+}
+
+// resourceNeedsCheck returns true if the resource has a body or a parameter with constraints.
+func (gen *javaValidatorGenerator) resourceNeedsCheck(r *rdl.Resource) bool {
+	for _, in := range r.Inputs {
+		if in.Context != "" {
+			continue
+		}
+		if gen.needsCheck(in.Type) || (!in.PathParam && in.QueryParam == "" && in.Header == "") {
+			return true
+		}
+	}
+	return false
+}
+
+func (gen *javaValidatorGenerator) resourceChecks() string {
+	s := ""
+	for _, r := range gen.schema.Resources {
+		if !gen.resourceNeedsCheck(r) {
+			continue
+		}
+		methName, _ := javaMethodName(gen.registry, r)
+		var params []string
+		checks := ""
+		for _, in := range r.Inputs {
+			if in.Context != "" {
+				continue
+			}
+			pname := javaName(in.Name)
+			params = append(params, javaType(gen.registry, in.Type, true, "", "")+" "+pname)
+			label := string(in.Name)
+			if in.QueryParam != "" {
+				label = in.QueryParam
+			} else if in.Header != "" {
+				label = in.Header
+			} else if !in.PathParam && !in.Optional {
+				checks += fmt.Sprintf("        if (%s == null) {\n", pname)
+				checks += fmt.Sprintf("            errors.add(\"%s: missing request body\");\n", label)
+				checks += "        }\n"
+			}
+			checks += gen.checkValue(pname, in.Type, "", "", fmt.Sprintf("%q", label), "        ")
+		}
+		s += "\n"
+		s += fmt.Sprintf("    public static ResourceError %s(%s) {\n", methName, strings.Join(params, ", "))
+		s += "        List<String> errors = new ArrayList<String>();\n"
+		s += checks
+		s += "        return invalid(errors);\n"
+		s += "    }\n"
+	}
+	return s
+}
+
+// checkValue returns the statements checking the value of expr, named by the Java string
+// expression context in errors. Inline array items and map keys and values are iterated over.
+func (gen *javaValidatorGenerator) checkValue(expr string, tref rdl.TypeRef, items rdl.TypeRef, keys rdl.TypeRef, context string, indent string) string {
+	tref, t := gen.resolve(tref)
+	if t == nil {
+		return ""
+	}
+	if t.Variant != rdl.TypeVariantBaseType {
+		if !gen.needsCheck(tref) {
+			return ""
+		}
+		return fmt.Sprintf("%scheck%s(errors, %s, %s);\n", indent, tref, context, expr)
+	}
+	elements := gen.checkElements(expr, gen.registry.BaseType(t), items, keys, context, indent+"    ")
+	if elements == "" {
+		return ""
+	}
+	return fmt.Sprintf("%sif (%s != null) {\n", indent, expr) + elements + indent + "}\n"
+}
+
+// checkElements returns the statements checking the items of an array, or the keys and items of a map.
+func (gen *javaValidatorGenerator) checkElements(expr string, bt rdl.BaseType, items rdl.TypeRef, keys rdl.TypeRef, context string, indent string) string {
+	gen.depth++
+	defer func() { gen.depth-- }()
+	s := ""
+	switch bt {
+	case rdl.BaseTypeArray:
+		if items == "" || !gen.needsCheck(items) {
+			return ""
+		}
+		i := fmt.Sprintf("i%d", gen.depth)
+		s += fmt.Sprintf("%sfor (int %s = 0; %s < %s.size(); %s++) {\n", indent, i, i, expr, i)
+		s += gen.checkValue(expr+".get("+i+")", items, "", "", context+" + \"[\" + "+i+" + \"]\"", indent+"    ")
+		s += indent + "}\n"
+	case rdl.BaseTypeMap:
+		if (items == "" || !gen.needsCheck(items)) && (keys == "" || !gen.needsCheck(keys)) {
+			return ""
+		}
+		e := fmt.Sprintf("e%d", gen.depth)
+		ktype, itype := "Object", "Object"
+		if keys != "" {
+			ktype = javaType(gen.registry, keys, true, "", "")
+		}
+		if items != "" {
+			itype = javaType(gen.registry, items, true, "", "")
+		}
+		s += fmt.Sprintf("%sfor (Map.Entry<%s, %s> %s : %s.entrySet()) {\n", indent, ktype, itype, e, expr)
+		if keys != "" {
+			s += gen.checkValue(e+".getKey()", keys, "", "", context+" + \"[\" + "+e+".getKey() + \"]\"", indent+"    ")
+		}
+		if items != "" {
+			s += gen.checkValue(e+".getValue()", items, "", "", context+" + \"[\" + "+e+".getKey() + \"]\"", indent+"    ")
+		}
+		s += indent + "}\n"
+	}
+	return s
+}
+
+// fieldAccess returns the expression reading the field of the struct v, as the java-model
+// generator declares it with the same options.
+func (gen *javaValidatorGenerator) fieldAccess(f *rdl.StructFieldDef) string {
+	fname := javaFieldName(f.Name)
+	if gen.records {
+		return "v." + fname + "()"
+	}
+	if gen.beans {
+		return "v." + javaBeanGetter(javaType(gen.registry, f.Type, f.Optional, f.Items, f.Keys), fname) + "()"
+	}
+	if f.Optional && gen.optionals {
+		if gen.getSetters {
+			return "v.get" + capitalize(fname) + "().orElse(null)"
+		}
+		return "v." + fname + "().orElse(null)"
+	}
+	return "v." + fname
+}
+
+func (gen *javaValidatorGenerator) typeChecks() string {
+	s := ""
+	for _, t := range gen.checkedTypes() {
+		tName, _, _ := rdl.TypeInfo(t)
+		jtype := javaType(gen.registry, rdl.TypeRef(tName), true, "", "")
+		s += "\n"
+		s += fmt.Sprintf("    static void check%s(List<String> errors, String context, %s v) {\n", tName, jtype)
+		s += "        if (v == null) {\n            return;\n        }\n"
+		switch t.Variant {
+		case rdl.TypeVariantStringTypeDef:
+			s += gen.stringChecks(t)
+		case rdl.TypeVariantNumberTypeDef:
+			s += gen.numberChecks(t)
+		case rdl.TypeVariantArrayTypeDef:
+			at := t.ArrayTypeDef
+			s += gen.sizeChecks("v.size()", "array", at.MinSize, at.MaxSize)
+			s += gen.checkElements("v", rdl.BaseTypeArray, at.Items, "", "context", "        ")
+		case rdl.TypeVariantMapTypeDef:
+			mt := t.MapTypeDef
+			s += gen.sizeChecks("v.size()", "map", mt.MinSize, mt.MaxSize)
+			s += gen.checkElements("v", rdl.BaseTypeMap, mt.Items, mt.Keys, "context", "        ")
+		case rdl.TypeVariantStructTypeDef:
+			for _, f := range flattenedFields(gen.registry, t) {
+				expr := gen.fieldAccess(f)
+				fcontext := fmt.Sprintf("context + \".%s\"", f.Name)
+				if !f.Optional && !javaPrimitiveType(gen.registry, f.Type) {
+					s += fmt.Sprintf("        if (%s == null) {\n", expr)
+					s += fmt.Sprintf("            errors.add(context + \".%s: missing required field\");\n", f.Name)
+					s += "        }\n"
+				}
+				s += gen.checkValue(expr, f.Type, f.Items, f.Keys, fcontext, "        ")
+			}
+		}
+		s += "    }\n"
+	}
+	return s
+}
+
+func (gen *javaValidatorGenerator) sizeChecks(expr string, kind string, minSize *int32, maxSize *int32) string {
+	s := ""
+	if minSize != nil {
+		s += fmt.Sprintf("        if (%s < %d) {\n", expr, *minSize)
+		s += fmt.Sprintf("            errors.add(context + \": %s too small (minimum size is %d)\");\n", kind, *minSize)
+		s += "        }\n"
+	}
+	if maxSize != nil {
+		s += fmt.Sprintf("        if (%s > %d) {\n", expr, *maxSize)
+		s += fmt.Sprintf("            errors.add(context + \": %s too large (maximum size is %d)\");\n", kind, *maxSize)
+		s += "        }\n"
+	}
+	return s
+}
+
+func (gen *javaValidatorGenerator) stringChecks(t *rdl.Type) string {
+	pattern, values, minSize, maxSize := stringConstraints(gen.registry, t)
+	s := gen.sizeChecks("v.length()", "string", minSize, maxSize)
+	if values != nil {
+		quoted := make([]string, 0, len(values))
+		for _, v := range values {
+			quoted = append(quoted, fmt.Sprintf("%q", v))
+		}
+		s += fmt.Sprintf("        if (!Arrays.asList(%s).contains(v)) {\n", strings.Join(quoted, ", "))
+		s += "            errors.add(context + \": value mismatch (\" + v + \")\");\n"
+		s += "        }\n"
+	}
+	if pattern != "" {
+		s += fmt.Sprintf("        if (!PATTERN_%s.matcher(v).matches()) {\n", t.StringTypeDef.Name)
+		s += fmt.Sprintf("            errors.add(context + \": pattern mismatch (\" + v + \" does not match %s)\");\n", strings.Trim(fmt.Sprintf("%q", pattern), "\""))
+		s += "        }\n"
+	}
+	return s
+}
+
+func (gen *javaValidatorGenerator) numberChecks(t *rdl.Type) string {
+	min, max := numberConstraints(gen.registry, t)
+	suffix := ""
+	if gen.registry.BaseType(t) == rdl.BaseTypeInt64 {
+		suffix = "L"
+	}
+	s := ""
+	if min != nil {
+		lit := numericValueString(*min) + suffix
+		s += fmt.Sprintf("        if (v < %s) {\n", lit)
+		s += fmt.Sprintf("            errors.add(context + \": value too small (\" + v + \" < %s)\");\n", numericValueString(*min))
+		s += "        }\n"
+	}
+	if max != nil {
+		lit := numericValueString(*max) + suffix
+		s += fmt.Sprintf("        if (v > %s) {\n", lit)
+		s += fmt.Sprintf("            errors.add(context + \": value too large (\" + v + \" > %s)\");\n", numericValueString(*max))
+		s += "        }\n"
+	}
+	return s
+}
+
+// javaPrimitiveType returns true if a required field of the type is a Java primitive, i.e. never null.
+func javaPrimitiveType(reg rdl.TypeRegistry, tref rdl.TypeRef) bool {
+	switch reg.FindBaseType(tref) {
+	case rdl.BaseTypeBool, rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		return true
+	default:
+		return false
+	}
+}
+
+// validatorCall returns the statements, run before the handler is invoked, calling the validator
+// of the resource and binding its result to 'invalid'. It is empty if nothing needs checking.
+func (gen *javaServerGenerator) validatorCall(r *rdl.Resource) string {
+	if !gen.validate {
+		return ""
+	}
+	v := &javaValidatorGenerator{registry: gen.registry, schema: gen.schema, needs: make(map[rdl.TypeRef]bool)}
+	if !v.resourceNeedsCheck(r) {
+		return ""
+	}
+	methName, _ := javaMethodName(gen.registry, r)
+	var args []string
+	for _, in := range r.Inputs {
+		if in.Context == "" {
+			args = append(args, javaName(in.Name))
+		}
+	}
+	return fmt.Sprintf("            ResourceError invalid = %sValidator.%s(%s);\n", gen.name, methName, strings.Join(args, ", "))
+}
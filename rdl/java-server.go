@@ -9,6 +9,7 @@ import (
 	"github.com/ardielle/ardielle-go/rdl"
 	"log"
 	"os"
+	"sort"
 	"strings"
 	"text/template"
 )
@@ -23,6 +24,10 @@ type javaServerGenerator struct {
 	ns       string
 	async    bool
 	base     string
+	spring   bool
+	validate bool
+	jakarta  bool
+	stages   bool
 }
 
 // GenerateJavaServer generates the server code for the RDL-defined service
@@ -34,6 +39,23 @@ func GenerateJavaServer(banner string, schema *rdl.Schema, outdir string, ns str
 	}
 	cName := capitalize(string(schema.Name))
 
+	spring := false
+	switch flavor := javaGenerationStringOptionSet(options, "server-flavor"); flavor {
+	case "", "jaxrs":
+	case "spring":
+		spring = true
+	default:
+		return fmt.Errorf("Unsupported java-server flavor: %s", flavor)
+	}
+
+	validate := javaGenerationBoolOptionSet(options, "validate")
+	jakarta := javaGenerationBoolOptionSet(options, "jakarta")
+	runnable := javaGenerationBoolOptionSet(options, "main")
+	if runnable && spring {
+		return fmt.Errorf("The main option is for the JAX-RS java-server, Spring Boot applications have their own")
+	}
+
+	stages := javaGenerationBoolOptionSet(options, "async")
 	async := false
 	for _, r := range schema.Resources {
 		if r.Async != nil && *r.Async {
@@ -47,16 +69,18 @@ func GenerateJavaServer(banner string, schema *rdl.Schema, outdir string, ns str
 	if err != nil {
 		return err
 	}
-	gen := &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, async, base}
+	gen := &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, async, base, spring, validate, jakarta, stages}
 	gen.processTemplate(javaServerHandlerTemplate)
 	out.Flush()
 	file.Close()
 
 	for _, r := range schema.Resources {
-		if r.Async != nil && *r.Async {
-			javaServerMakeAsyncResultModel(banner, schema, reg, outdir, r, ns, base)
+		if streamsEvents(r) {
+			//the handler returns the events, there is nothing to hold a result
+		} else if r.Async != nil && *r.Async {
+			javaServerMakeAsyncResultModel(banner, schema, reg, outdir, r, ns, base, spring, jakarta)
 		} else if len(r.Outputs) > 0 {
-			javaServerMakeResultModel(banner, schema, reg, outdir, r, ns, base)
+			javaServerMakeResultModel(banner, schema, reg, outdir, r, ns, base, spring, jakarta)
 		}
 	}
 
@@ -66,7 +90,7 @@ func GenerateJavaServer(banner string, schema *rdl.Schema, outdir string, ns str
 	if err != nil {
 		return err
 	}
-	gen = &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, async, base}
+	gen = &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, async, base, spring, validate, jakarta, stages}
 	gen.processTemplate(javaServerContextTemplate)
 	out.Flush()
 	file.Close()
@@ -74,12 +98,36 @@ func GenerateJavaServer(banner string, schema *rdl.Schema, outdir string, ns str
 		return gen.err
 	}
 
+	if validate {
+		//FooValidator, checking requests against the constraints of the schema
+		err = javaServerGenerateValidator(banner, schema, reg, packageDir, cName, ns, options)
+		if err != nil {
+			return err
+		}
+	}
+
+	if spring {
+		//FooController Spring MVC glue. Spring Boot applications bootstrap themselves, so no FooServer
+		out, file, _, err = outputWriter(packageDir, cName, "Controller.java")
+		if err != nil {
+			return err
+		}
+		gen = &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, async, base, spring, validate, jakarta, stages}
+		gen.processTemplate(javaServerSpringTemplate)
+		out.Flush()
+		file.Close()
+		if gen.err != nil {
+			return gen.err
+		}
+		return javaServerGenerateErrors(banner, schema, packageDir, ns, spring, jakarta)
+	}
+
 	//FooResources Jax-RS glue
 	out, file, _, err = outputWriter(packageDir, cName, "Resources.java")
 	if err != nil {
 		return err
 	}
-	gen = &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, async, base}
+	gen = &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, async, base, spring, validate, jakarta, stages}
 	gen.processTemplate(javaServerTemplate)
 	out.Flush()
 	file.Close()
@@ -98,17 +146,27 @@ func GenerateJavaServer(banner string, schema *rdl.Schema, outdir string, ns str
 	if err != nil {
 		return err
 	}
-	gen = &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, async, base}
+	gen = &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, async, base, spring, validate, jakarta, stages}
 	gen.processTemplate(javaServerInitTemplate)
 	out.Flush()
 	file.Close()
 	if gen.err != nil {
 		return gen.err
 	}
+	if runnable {
+		//FooMain - a runnable main class serving FooServer's resources on Jetty, with port and TLS flags
+		err = javaServerGenerateMain(banner, schema, packageDir, cName, ns)
+		if err != nil {
+			return err
+		}
+	}
+	return javaServerGenerateErrors(banner, schema, packageDir, ns, spring, jakarta)
+}
 
+func javaServerGenerateErrors(banner string, schema *rdl.Schema, packageDir string, ns string, spring bool, jakarta bool) error {
 	//ResourceException - the throawable wrapper for alternate return types
-	s = "ResourceException"
-	out, file, _, err = outputWriter(packageDir, s, ".java")
+	s := "ResourceException"
+	out, file, _, err := outputWriter(packageDir, s, ".java")
 	if err != nil {
 		return err
 	}
@@ -128,10 +186,15 @@ func GenerateJavaServer(banner string, schema *rdl.Schema, outdir string, ns str
 	err = javaGenerateResourceError(banner, schema, out, ns)
 	out.Flush()
 	file.Close()
-	return err
+	if err != nil {
+		return err
+	}
+
+	//FooException for each exception type, and the mapper of ResourceException to responses
+	return javaServerGenerateExceptions(banner, schema, packageDir, ns, spring, jakarta)
 }
 
-func javaServerMakeAsyncResultModel(banner string, schema *rdl.Schema, reg rdl.TypeRegistry, outdir string, r *rdl.Resource, ns string, base string) error {
+func javaServerMakeAsyncResultModel(banner string, schema *rdl.Schema, reg rdl.TypeRegistry, outdir string, r *rdl.Resource, ns string, base string, spring bool, jakarta bool) error {
 	cName := capitalize(string(r.Type))
 	packageDir, err := javaGenerationDir(outdir, schema, ns)
 	if err != nil {
@@ -144,7 +207,7 @@ func javaServerMakeAsyncResultModel(banner string, schema *rdl.Schema, reg rdl.T
 		return err
 	}
 	rType := javaType(reg, rdl.TypeRef(r.Type), false, "", "")
-	gen := &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, true, base}
+	gen := &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, true, base, spring, false, jakarta, false}
 	funcMap := template.FuncMap{
 		"header":     func() string { return javaGenerationHeader(gen.banner) },
 		"package":    func() string { return javaGenerationPackage(gen.schema, ns) },
@@ -164,14 +227,18 @@ func javaServerMakeAsyncResultModel(banner string, schema *rdl.Schema, reg rdl.T
 		"headerParamsSig":  func() []string { return gen.makeHeaderParamsSig(r) },
 		"headerAssign":     func() string { return gen.makeHeaderAssign(r) },
 	}
-	t := template.Must(template.New(gen.name).Funcs(funcMap).Parse(javaServerAsyncResultTemplate))
+	templateSource := javaServerAsyncResultTemplate
+	if spring {
+		templateSource = javaServerSpringAsyncResultTemplate
+	}
+	t := template.Must(template.New(gen.name).Funcs(funcMap).Parse(javaEE(templateSource, gen.jakarta)))
 	err = t.Execute(gen.writer, gen.schema)
 	out.Flush()
 	file.Close()
 	return err
 }
 
-func javaServerMakeResultModel(banner string, schema *rdl.Schema, reg rdl.TypeRegistry, outdir string, r *rdl.Resource, ns string, base string) error {
+func javaServerMakeResultModel(banner string, schema *rdl.Schema, reg rdl.TypeRegistry, outdir string, r *rdl.Resource, ns string, base string, spring bool, jakarta bool) error {
 	cName := capitalize(string(r.Type))
 	packageDir, err := javaGenerationDir(outdir, schema, ns)
 	if err != nil {
@@ -184,7 +251,7 @@ func javaServerMakeResultModel(banner string, schema *rdl.Schema, reg rdl.TypeRe
 		return err
 	}
 	rType := javaType(reg, rdl.TypeRef(r.Type), false, "", "")
-	gen := &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, false, base}
+	gen := &javaServerGenerator{reg, schema, cName, out, nil, banner, ns, false, base, spring, false, jakarta, false}
 	funcMap := template.FuncMap{
 		"header":     func() string { return javaGenerationHeader(gen.banner) },
 		"package":    func() string { return javaGenerationPackage(gen.schema, ns) },
@@ -204,7 +271,11 @@ func javaServerMakeResultModel(banner string, schema *rdl.Schema, reg rdl.TypeRe
 		"headerParamsSig":  func() []string { return gen.makeHeaderParamsSig(r) },
 		"headerAssign":     func() string { return gen.makeHeaderAssign(r) },
 	}
-	t := template.Must(template.New(gen.name).Funcs(funcMap).Parse(javaServerResultTemplate))
+	templateSource := javaServerResultTemplate
+	if spring {
+		templateSource = javaServerSpringResultTemplate
+	}
+	t := template.Must(template.New(gen.name).Funcs(funcMap).Parse(javaEE(templateSource, gen.jakarta)))
 	err = t.Execute(gen.writer, gen.schema)
 	out.Flush()
 	file.Close()
@@ -343,7 +414,7 @@ import javax.servlet.http.HttpServletResponse;
 // {{cName}}Handler is the interface that the service implementation must implement
 //
 public interface {{cName}}Handler {{openBrace}} {{range .Resources}}
-    {{methodSig .}};{{end}}
+{{deprecated .}}    {{methodSig .}};{{end}}
     public ResourceContext newResourceContext(HttpServletRequest request, HttpServletResponse response);
 }
 `
@@ -532,7 +603,7 @@ public class {{cName}}Server {
             Server server = new Server(port);
             ServletContextHandler handler = new ServletContextHandler();
             handler.setContextPath("");
-            ResourceConfig config = new ResourceConfig({{cName}}Resources.class).register(new Binder());
+            ResourceConfig config = new ResourceConfig({{cName}}Resources.class, {{cName}}ExceptionMapper.class).register(new Binder());
             handler.addServlet(new ServletHolder(new ServletContainer(config)), "/*");
             server.setHandler(handler);
             server.start();
@@ -564,7 +635,7 @@ import javax.inject.Inject;{{asyncImports}}
 @Path("{{rootPath}}")
 public class {{cName}}Resources {
 {{range .Resources}}
-    @{{uMethod .}}
+{{deprecated .}}    @{{uMethod .}}
     @Path("{{methodPath .}}")
     {{handlerSig .}} {{openBrace}}
 {{handlerBody .}}    }
@@ -579,7 +650,10 @@ public class {{cName}}Resources {
             return new WebApplicationException(code);
         }
     }
-
+{{if stages}}{{resourceException}}{{end}}{{if streams}}
+    void writeEvents(java.io.OutputStream out, Iterator<?> events) throws java.io.IOException {
+{{writeEvents}}    }
+{{end}}
     @Inject private {{cName}}Handler delegate;
     @Context private HttpServletRequest request;
     @Context private HttpServletResponse response;
@@ -587,6 +661,37 @@ public class {{cName}}Resources {
 }
 `
 
+// javaServerResourceExceptionMethod returns the ResourceException a CompletionStage of a handler
+// failed with, rethrowing any other failure.
+const javaServerResourceExceptionMethod = `
+    ResourceException resourceException(Throwable x) {
+        Throwable cause = (x instanceof java.util.concurrent.CompletionException && x.getCause() != null) ? x.getCause() : x;
+        if (cause instanceof ResourceException) {
+            return (ResourceException) cause;
+        }
+        throw (cause instanceof RuntimeException) ? (RuntimeException) cause : new java.util.concurrent.CompletionException(cause);
+    }
+`
+
+// javaServerWriteEventsBody writes each event of a streaming resource as a server-sent event,
+// flushing it to the client right away.
+const javaServerWriteEventsBody = `        try {
+            while (events.hasNext()) {
+                String event = "data: " + JSON.string(events.next()) + "\n\n";
+                out.write(event.getBytes(java.nio.charset.StandardCharsets.UTF_8));
+                out.flush();
+            }
+        } finally {
+            if (events instanceof AutoCloseable) {
+                try {
+                    ((AutoCloseable) events).close();
+                } catch (Exception e) {
+                    //the stream is over either way
+                }
+            }
+        }
+`
+
 func makeJavaTypeRef(reg rdl.TypeRegistry, t *rdl.Type) string {
 	switch t.Variant {
 	case rdl.TypeVariantAliasTypeDef:
@@ -649,6 +754,7 @@ func (gen *javaServerGenerator) processTemplate(templateSource string) error {
 		"comment":     commentFun,
 		"uMethod":     func(r *rdl.Resource) string { return strings.ToUpper(r.Method) },
 		"methodSig":   func(r *rdl.Resource) string { return gen.serverMethodSignature(r) },
+		"deprecated":  func(r *rdl.Resource) string { return javaDeprecated(r.Annotations, "    ") },
 		"handlerSig":  func(r *rdl.Resource) string { return gen.handlerSignature(r) },
 		"handlerBody": func(r *rdl.Resource) string { return gen.handlerBody(r) },
 		"client":      func() string { return gen.name + "Client" },
@@ -663,12 +769,21 @@ func (gen *javaServerGenerator) processTemplate(templateSource string) error {
 		},
 		"asyncImports": func() string {
 			if gen.async {
-				return "\nimport javax.ws.rs.container.AsyncResponse;\nimport javax.ws.rs.container.Suspended;"
+				if gen.spring {
+					return "\nimport org.springframework.web.context.request.async.DeferredResult;"
+				}
+				return javaEE("\nimport javax.ws.rs.container.AsyncResponse;\nimport javax.ws.rs.container.Suspended;", gen.jakarta)
 			}
 			return ""
 		},
+		"streams":           func() bool { return anyStreamsEvents(gen.schema) },
+		"stages":            func() bool { return gen.anyReturnsStage() },
+		"writeEvents":       func() string { return javaServerWriteEventsBody },
+		"resourceException": func() string { return javaServerResourceExceptionMethod },
+		"springHandlerSig":  func(r *rdl.Resource) string { return gen.springHandlerSignature(r) },
+		"springHandlerBody": func(r *rdl.Resource) string { return gen.springHandlerBody(r) },
 	}
-	t := template.Must(template.New(gen.name).Funcs(funcMap).Parse(templateSource))
+	t := template.Must(template.New(gen.name).Funcs(funcMap).Parse(javaEE(templateSource, gen.jakarta)))
 	return t.Execute(gen.writer, gen.schema)
 }
 
@@ -682,8 +797,9 @@ func (gen *javaServerGenerator) resourcePath(r *rdl.Resource) string {
 }
 
 func (gen *javaServerGenerator) handlerBody(r *rdl.Resource) string {
-	async := r.Async != nil && *r.Async
-	resultWrapper := len(r.Outputs) > 0 || async
+	stream := streamsEvents(r)
+	async := r.Async != nil && *r.Async && !stream
+	resultWrapper := (len(r.Outputs) > 0 || async) && !stream
 	returnType := "void"
 	if !resultWrapper {
 		returnType = javaType(gen.registry, r.Type, false, "", "")
@@ -692,27 +808,12 @@ func (gen *javaServerGenerator) handlerBody(r *rdl.Resource) string {
 	s += "            ResourceContext context = this.delegate.newResourceContext(this.request, this.response);\n"
 	var fargs []string
 	bodyName := ""
-	if r.Auth != nil {
-		if r.Auth.Authenticate {
-			s += "            context.authenticate();\n"
-		} else if r.Auth.Action != "" && r.Auth.Resource != "" {
-			resource := r.Auth.Resource
-			i := strings.Index(resource, "{")
-			for i >= 0 {
-				j := strings.Index(resource[i:], "}")
-				if j < 0 {
-					break
-				}
-				j += i
-				resource = resource[0:i] + "\" + " + resource[i+1:j] + " + \"" + resource[j+1:]
-				i = strings.Index(resource, "{")
-			}
-			resource = "\"" + resource + "\""
-			s += fmt.Sprintf("            context.authorize(%q, %s, null);\n", r.Auth.Action, resource)
-			//what about the domain variant?
-		} else {
-			log.Println("*** Badly formed auth spec in resource input:", r)
-		}
+	s += gen.handlerAuth(r)
+	if call := gen.validatorCall(r); call != "" {
+		s += call
+		s += "            if (invalid != null) {\n"
+		s += "                throw new WebApplicationException(Response.status(ResourceException.BAD_REQUEST).entity(invalid).build());\n"
+		s += "            }\n"
 	}
 	for _, in := range r.Inputs {
 		name := string(in.Name)
@@ -735,7 +836,10 @@ func (gen *javaServerGenerator) handlerBody(r *rdl.Resource) string {
 	if len(fargs) > 0 {
 		sargs = ", " + strings.Join(fargs, ", ")
 	}
-	if resultWrapper {
+	if stream {
+		s += "            Iterator<" + javaType(gen.registry, r.Type, true, "", "") + "> events = this.delegate." + methName + "(context" + sargs + ");\n"
+		s += "            return output -> writeEvents(output, events);\n"
+	} else if resultWrapper {
 		a := "null"
 		if async {
 			a = "asyncResp"
@@ -753,6 +857,16 @@ func (gen *javaServerGenerator) handlerBody(r *rdl.Resource) string {
 		}
 		sargs += ", result"
 		s += "            this.delegate." + methName + "(context" + sargs + ");\n"
+	} else if gen.returnsStage(r) {
+		s += "            return this.delegate." + methName + "(context" + sargs + ")"
+		if r.Expected == "NO_CONTENT" && r.Alternatives == nil {
+			s += ".<" + javaType(gen.registry, r.Type, true, "", "") + ">thenApply(e -> null)"
+		}
+		s += ".exceptionally(x -> {\n"
+		s += "                ResourceException e = resourceException(x);\n"
+		s += "                int code = e.getCode();\n"
+		s += gen.exceptionSwitch(r, methName, returnType, "                ")
+		s += "            });\n"
 	} else {
 		s += "            " + returnType + " e = this.delegate." + methName + "(context" + sargs + ");\n"
 		noContent := r.Expected == "NO_CONTENT" && r.Alternatives == nil
@@ -769,25 +883,86 @@ func (gen *javaServerGenerator) handlerBody(r *rdl.Resource) string {
 	}
 	s += "        } catch (ResourceException e) {\n"
 	s += "            int code = e.getCode();\n"
-	s += "            switch (code) {\n"
+	s += gen.exceptionSwitch(r, methName, returnType, "            ")
+	s += "        }\n"
+	return s
+}
+
+// exceptionSwitch returns the switch on the code of the ResourceException e, throwing the
+// WebApplicationException of its declared type, at the indentation.
+func (gen *javaServerGenerator) exceptionSwitch(r *rdl.Resource, methName string, returnType string, indent string) string {
+	s := indent + "switch (code) {\n"
 	if len(r.Alternatives) > 0 {
 		for _, alt := range r.Alternatives {
-			s += "            case ResourceException." + alt + ":\n"
+			s += indent + "case ResourceException." + alt + ":\n"
 		}
-		s += "                throw typedException(code, e, " + returnType + ".class);\n"
+		s += indent + "    throw typedException(code, e, " + returnType + ".class);\n"
 	}
 	if r.Exceptions != nil && len(r.Exceptions) > 0 {
-		for ecode, edef := range r.Exceptions {
-			etype := edef.Type
-			s += "            case ResourceException." + ecode + ":\n"
-			s += "                throw typedException(code, e, " + etype + ".class);\n"
+		ecodes := make([]string, 0, len(r.Exceptions))
+		for ecode := range r.Exceptions {
+			ecodes = append(ecodes, ecode)
+		}
+		sort.Strings(ecodes)
+		for _, ecode := range ecodes {
+			etype := r.Exceptions[ecode].Type
+			s += indent + "case ResourceException." + ecode + ":\n"
+			s += indent + "    throw typedException(code, e, " + etype + ".class);\n"
+		}
+	}
+	s += indent + "default:\n"
+	s += indent + "    System.err.println(\"*** Warning: undeclared exception (\" + code + \") for resource " + methName + "\");\n"
+	s += indent + "    throw typedException(code, e, ResourceError.class);\n" //? really
+	s += indent + "}\n"
+	return s
+}
+
+// returnsStage tells whether the handler of the resource returns a CompletionStage of its result
+// (-x async=true), to complete it without holding a thread. Resources with a result wrapper,
+// i.e. with outputs or async, and streaming ones keep their handlers.
+func (gen *javaServerGenerator) returnsStage(r *rdl.Resource) bool {
+	return gen.stages && !streamsEvents(r) && len(r.Outputs) == 0 && (r.Async == nil || !*r.Async)
+}
+
+// anyReturnsStage tells whether the handler of any resource returns a CompletionStage.
+func (gen *javaServerGenerator) anyReturnsStage() bool {
+	for _, r := range gen.schema.Resources {
+		if gen.returnsStage(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// stageType returns the CompletionStage of the type.
+func stageType(jtype string) string {
+	return "java.util.concurrent.CompletionStage<" + jtype + ">"
+}
+
+func (gen *javaServerGenerator) handlerAuth(r *rdl.Resource) string {
+	s := ""
+	if r.Auth != nil {
+		if r.Auth.Authenticate {
+			s += "            context.authenticate();\n"
+		} else if r.Auth.Action != "" && r.Auth.Resource != "" {
+			resource := r.Auth.Resource
+			i := strings.Index(resource, "{")
+			for i >= 0 {
+				j := strings.Index(resource[i:], "}")
+				if j < 0 {
+					break
+				}
+				j += i
+				resource = resource[0:i] + "\" + " + resource[i+1:j] + " + \"" + resource[j+1:]
+				i = strings.Index(resource, "{")
+			}
+			resource = "\"" + resource + "\""
+			s += fmt.Sprintf("            context.authorize(%q, %s, null);\n", r.Auth.Action, resource)
+			//what about the domain variant?
+		} else {
+			log.Println("*** Badly formed auth spec in resource input:", r)
 		}
 	}
-	s += "            default:\n"
-	s += "                System.err.println(\"*** Warning: undeclared exception (\" + code + \") for resource " + methName + "\");\n"
-	s += "                throw typedException(code, e, ResourceError.class);\n" //? really
-	s += "            }\n"
-	s += "        }\n"
 	return s
 }
 
@@ -829,11 +1004,15 @@ func (gen *javaServerGenerator) handlerSignature(r *rdl.Resource) string {
 	returnType := javaType(gen.registry, r.Type, false, "", "")
 	reg := gen.registry
 	var params []string
-	if r.Async != nil && *r.Async {
+	if streamsEvents(r) {
+		returnType = "StreamingOutput"
+	} else if r.Async != nil && *r.Async {
 		params = append(params, "@Suspended AsyncResponse asyncResp")
 		returnType = "void"
 	} else if len(r.Outputs) > 0 {
 		returnType = "void"
+	} else if gen.returnsStage(r) {
+		returnType = stageType(javaType(reg, r.Type, true, "", ""))
 	}
 	for _, v := range r.Inputs {
 		if v.Context != "" { //ignore these ones
@@ -853,8 +1032,11 @@ func (gen *javaServerGenerator) handlerSignature(r *rdl.Resource) string {
 		params = append(params, pdecl+ptype+" "+javaName(k))
 	}
 	spec := "@Produces(MediaType.APPLICATION_JSON)\n"
+	if streamsEvents(r) {
+		spec = "@Produces({\"text/event-stream\", MediaType.APPLICATION_JSON})\n"
+	}
 	switch r.Method {
-	case "POST", "PUT":
+	case "POST", "PUT", "PATCH":
 		spec += "    @Consumes(MediaType.APPLICATION_JSON)\n"
 	}
 
@@ -864,33 +1046,44 @@ func (gen *javaServerGenerator) handlerSignature(r *rdl.Resource) string {
 
 func defaultValueAnnotation(val interface{}) string {
 	if val != nil {
-		switch v := val.(type) {
-		case string:
-			return fmt.Sprintf("@DefaultValue(%q) ", v)
-		case int8:
-			return fmt.Sprintf("@DefaultValue(\"%d\") ", v)
-		case int16:
-			return fmt.Sprintf("@DefaultValue(\"%d\") ", v)
-		case int32:
-			return fmt.Sprintf("@DefaultValue(\"%d\") ", v)
-		case int64:
-			return fmt.Sprintf("@DefaultValue(\"%d\") ", v)
-		case float32:
-			return fmt.Sprintf("@DefaultValue(\"%g\") ", v)
-		case float64:
-			return fmt.Sprintf("@DefaultValue(\"%g\") ", v)
-		default:
-			return fmt.Sprintf("@DefaultValue(\"%v\") ", v)
-		}
+		return "@DefaultValue(" + defaultValueString(val) + ") "
 	}
 	return ""
 }
 
+func defaultValueString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case int8:
+		return fmt.Sprintf("\"%d\"", v)
+	case int16:
+		return fmt.Sprintf("\"%d\"", v)
+	case int32:
+		return fmt.Sprintf("\"%d\"", v)
+	case int64:
+		return fmt.Sprintf("\"%d\"", v)
+	case float32:
+		return fmt.Sprintf("\"%g\"", v)
+	case float64:
+		return fmt.Sprintf("\"%g\"", v)
+	default:
+		return fmt.Sprintf("\"%v\"", v)
+	}
+}
+
 func (gen *javaServerGenerator) handlerReturnType(r *rdl.Resource, methName string, returnType string) string {
+	if streamsEvents(r) {
+		//the events are written as the iterator produces them, until it has no more
+		return "Iterator<" + javaType(gen.registry, r.Type, true, "", "") + ">"
+	}
 	if len(r.Outputs) > 0 || (r.Async != nil && *r.Async) {
 		//return capitalize(methName) + "Result"
 		return "void"
 	}
+	if gen.returnsStage(r) {
+		return stageType(javaType(gen.registry, r.Type, true, "", ""))
+	}
 	return returnType
 }
 
@@ -921,7 +1114,7 @@ func javaMethodName(reg rdl.TypeRegistry, r *rdl.Resource) (string, []string) {
 		}
 		k := v.Name
 		if v.QueryParam == "" && !v.PathParam && v.Header == "" {
-			bodyType = string(safeTypeVarName(v.Type))
+			bodyType = string(safeTypeVarName(unpatchedType(reg, v.Type)))
 		}
 		//rest_core always uses the boxed type
 		optional := true
@@ -46,6 +46,19 @@ func javaGenerationBoolOptionSet(options []string, key string) bool {
 	return false
 }
 
+// javaEE returns the template source with the Java EE packages it uses, JAX-RS, Servlet, and
+// Inject, in the jakarta namespace of Jakarta EE 9 and later, if jakarta is set, or else as they
+// are, in the javax namespace.
+func javaEE(source string, jakarta bool) string {
+	if !jakarta {
+		return source
+	}
+	for _, pkg := range []string{"ws.rs.", "servlet.", "inject."} {
+		source = strings.Replace(source, "javax."+pkg, "jakarta."+pkg, -1)
+	}
+	return source
+}
+
 func javaGenerationStringOptionSet(options []string, key string) string {
 	for _, option := range options {
 		substrings := strings.SplitN(option, "=", 2)
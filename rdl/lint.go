@@ -0,0 +1,309 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+//
+// The lint command checks a schema for style and correctness problems. Each rule has a default
+// severity (off, warning, or error), which a config file or the command line can override.
+//
+
+type lintSeverity string
+
+const (
+	lintOff     lintSeverity = "off"
+	lintWarning lintSeverity = "warning"
+	lintError   lintSeverity = "error"
+)
+
+type lintRule struct {
+	name     string
+	severity lintSeverity
+	comment  string
+	check    func(lint *linter)
+}
+
+var lintRules = []*lintRule{
+	{"unused-type", lintWarning, "types that no other type or resource refers to", lintUnusedTypes},
+	{"missing-comment", lintWarning, "types and resources without a comment", lintMissingComments},
+	{"missing-exceptions", lintWarning, "resources that declare no exceptions", lintMissingExceptions},
+	{"naming-case", lintWarning, "type names not in UpperCamelCase, names not in the case used by most of the schema", lintNamingCase},
+	{"duplicate-path", lintError, "resources with the same method and path template", lintDuplicatePaths},
+}
+
+// LintConfig is the format of the lint config file, e.g. {"rules": {"missing-comment": "off"}}
+type LintConfig struct {
+	Rules map[string]lintSeverity `json:"rules"`
+}
+
+type lintFinding struct {
+	rule     string
+	severity lintSeverity
+	message  string
+}
+
+type linter struct {
+	schema   *rdl.Schema
+	rule     *lintRule
+	severity map[string]lintSeverity
+	findings []*lintFinding
+	inline   map[rdl.TypeRef]*rdl.Type //the types of the fields declared with constraints
+}
+
+func (lint *linter) report(format string, args ...interface{}) {
+	lint.findings = append(lint.findings, &lintFinding{lint.rule.name, lint.severity[lint.rule.name], fmt.Sprintf(format, args...)})
+}
+
+func lintSeverities(configFile string, overrides []string) (map[string]lintSeverity, error) {
+	severity := make(map[string]lintSeverity)
+	for _, rule := range lintRules {
+		severity[rule.name] = rule.severity
+	}
+	set := func(name string, sev lintSeverity) error {
+		if _, ok := severity[name]; !ok {
+			return fmt.Errorf("Unknown lint rule '%s'", name)
+		}
+		switch sev {
+		case lintOff, lintWarning, lintError:
+			severity[name] = sev
+			return nil
+		}
+		return fmt.Errorf("Bad severity '%s' for lint rule '%s' (expected off, warning, or error)", sev, name)
+	}
+	if configFile != "" {
+		data, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		var config LintConfig
+		err = json.Unmarshal(data, &config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", configFile, err)
+		}
+		for name, sev := range config.Rules {
+			if err := set(name, sev); err != nil {
+				return nil, fmt.Errorf("%s: %v", configFile, err)
+			}
+		}
+	}
+	for _, override := range overrides {
+		kv := strings.SplitN(override, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Bad lint rule setting '%s' (expected rule=severity)", override)
+		}
+		if err := set(kv[0], lintSeverity(kv[1])); err != nil {
+			return nil, err
+		}
+	}
+	return severity, nil
+}
+
+// lint checks the schema with every enabled rule, prints the findings, and returns an error if
+// any of them has error severity.
+func lint(schema *rdl.Schema, schemaFile string, configFile string, overrides []string) error {
+	severity, err := lintSeverities(configFile, overrides)
+	if err != nil {
+		return err
+	}
+	l := &linter{schema: schema, severity: severity, inline: inlineFieldTypes(schema)}
+	for _, rule := range lintRules {
+		if severity[rule.name] != lintOff {
+			l.rule = rule
+			rule.check(l)
+		}
+	}
+	errors := 0
+	for _, f := range l.findings {
+		fmt.Printf("%s: %s: [%s] %s\n", schemaFile, f.severity, f.rule, f.message)
+		if f.severity == lintError {
+			errors++
+		}
+	}
+	if errors > 0 {
+		return fmt.Errorf("%s: %d lint error(s)", schemaFile, errors)
+	}
+	return nil
+}
+
+// lintIncluded is true for types defined in an included schema file, those are linted with
+// the file that defines them.
+func lintIncluded(t *rdl.Type) bool {
+	_, ok := typeAnnotations(t)["x_included_from"]
+	return ok
+}
+
+func lintUnusedTypes(lint *linter) {
+	for _, t := range unusedTypes(lint.schema) {
+		tName, _, _ := rdl.TypeInfo(t)
+		if !lintIncluded(t) {
+			lint.report("type '%s' is not used", tName)
+		}
+	}
+}
+
+// unusedTypes returns the types of the schema that no other type or resource refers to. The
+// types the parser made for the fields declared with constraints are not among them, and refer
+// to the types those fields were declared as.
+func unusedTypes(schema *rdl.Schema) []*rdl.Type {
+	used := make(map[rdl.TypeRef]bool)
+	use := func(refs ...rdl.TypeRef) {
+		for _, ref := range refs {
+			used[ref] = true
+		}
+	}
+	for _, t := range schema.Types {
+		switch t.Variant {
+		case rdl.TypeVariantStructTypeDef:
+			st := t.StructTypeDef
+			use(st.Type)
+			for _, f := range st.Fields {
+				use(f.Type, f.Items, f.Keys)
+			}
+			//the structs it mixes in, and the generic type it instantiates with its type
+			//arguments, whose fields it has once the schema is expanded
+			for _, name := range mixinNames(st) {
+				use(rdl.TypeRef(name))
+			}
+			template, args := templateOf(st)
+			use(template)
+			for _, arg := range args {
+				use(rdl.TypeRef(arg))
+			}
+		case rdl.TypeVariantArrayTypeDef:
+			use(t.ArrayTypeDef.Type, t.ArrayTypeDef.Items)
+		case rdl.TypeVariantMapTypeDef:
+			use(t.MapTypeDef.Type, t.MapTypeDef.Keys, t.MapTypeDef.Items)
+		case rdl.TypeVariantUnionTypeDef:
+			use(t.UnionTypeDef.Type)
+			use(t.UnionTypeDef.Variants...)
+		default:
+			_, tType, _ := rdl.TypeInfo(t)
+			use(tType)
+		}
+	}
+	for _, r := range schema.Resources {
+		use(r.Type)
+		for _, in := range r.Inputs {
+			use(in.Type)
+		}
+		for _, out := range r.Outputs {
+			use(out.Type)
+		}
+		for _, e := range r.Exceptions {
+			use(rdl.TypeRef(e.Type))
+		}
+	}
+	var unused []*rdl.Type
+	for _, t := range declaredTypes(schema, inlineFieldTypes(schema)) {
+		tName, _, _ := rdl.TypeInfo(t)
+		if !used[rdl.TypeRef(tName)] {
+			unused = append(unused, t)
+		}
+	}
+	return unused
+}
+
+func lintMissingComments(lint *linter) {
+	for _, t := range declaredTypes(lint.schema, lint.inline) {
+		tName, _, tComment := rdl.TypeInfo(t)
+		if tComment == "" && !lintIncluded(t) {
+			lint.report("type '%s' has no comment", tName)
+		}
+	}
+	for _, r := range lint.schema.Resources {
+		if r.Comment == "" {
+			lint.report("resource %s %s has no comment", r.Method, r.Path)
+		}
+	}
+}
+
+func lintMissingExceptions(lint *linter) {
+	for _, r := range lint.schema.Resources {
+		if len(r.Exceptions) == 0 {
+			lint.report("resource %s %s declares no exceptions", r.Method, r.Path)
+		}
+	}
+}
+
+func isUpperCamelCase(name string) bool {
+	return name != "" && unicode.IsUpper(rune(name[0])) && !strings.Contains(name, "_")
+}
+
+func lintNamingCase(lint *linter) {
+	type named struct {
+		kind string
+		name string
+	}
+	var names []named
+	for _, t := range declaredTypes(lint.schema, lint.inline) {
+		tName, _, _ := rdl.TypeInfo(t)
+		if lintIncluded(t) {
+			continue
+		}
+		if !isUpperCamelCase(string(tName)) {
+			lint.report("type name '%s' is not UpperCamelCase", tName)
+		}
+		if t.Variant == rdl.TypeVariantStructTypeDef {
+			for _, f := range t.StructTypeDef.Fields {
+				names = append(names, named{"field '" + string(tName) + "." + string(f.Name) + "'", string(f.Name)})
+			}
+		}
+	}
+	for _, r := range lint.schema.Resources {
+		for _, in := range r.Inputs {
+			names = append(names, named{fmt.Sprintf("input '%s' of resource %s %s", in.Name, r.Method, r.Path), string(in.Name)})
+		}
+	}
+	//the case used by most field and input names is the one the others should follow
+	snake := 0
+	for _, n := range names {
+		if strings.Contains(n.name, "_") {
+			snake++
+		}
+	}
+	useSnake := snake*2 > len(names)
+	for _, n := range names {
+		isSnake := strings.Contains(n.name, "_") || strings.ToLower(n.name) == n.name
+		isCamel := !strings.Contains(n.name, "_") && unicode.IsLower(rune(n.name[0]))
+		if useSnake && !isSnake {
+			lint.report("%s is not snake_case, like most names in the schema", n.kind)
+		} else if !useSnake && !isCamel {
+			lint.report("%s is not lowerCamelCase, like most names in the schema", n.kind)
+		}
+	}
+}
+
+var lintPathVariable = regexp.MustCompile(`{[^}]*}`)
+
+func lintDuplicatePaths(lint *linter) {
+	seen := make(map[string][]*rdl.Resource)
+	for _, r := range lint.schema.Resources {
+		path := r.Path
+		if i := strings.Index(path, "?"); i >= 0 {
+			path = path[:i]
+		}
+		key := strings.ToUpper(r.Method) + " " + lintPathVariable.ReplaceAllString(strings.TrimSuffix(path, "/"), "{}")
+		duplicate := false
+		for _, first := range seen[key] {
+			//the variants of a resource for different API versions share their path
+			if sharesAPIVersion(r, first) {
+				lint.report("resource %s %s has the same path template as %s %s", r.Method, r.Path, first.Method, first.Path)
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			seen[key] = append(seen[key], r)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// lintFindings returns the messages of the rule for the schema.
+func lintFindings(t *testing.T, source string, ruleName string) []string {
+	dir, err := ioutil.TempDir("", "rdl-lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	schema := parseTestSchema(t, dir, "sample.rdl", source)
+	severity, err := lintSeverities("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &linter{schema: schema, severity: severity, inline: inlineFieldTypes(schema)}
+	for _, rule := range lintRules {
+		if rule.name == ruleName {
+			l.rule = rule
+			rule.check(l)
+		}
+	}
+	var messages []string
+	for _, f := range l.findings {
+		messages = append(messages, f.message)
+	}
+	return messages
+}
+
+func TestLintUnusedTypesMixin(t *testing.T) {
+	messages := lintFindings(t, `name Sample;
+
+// the audit fields
+type Audit Struct {
+    Timestamp created;
+}
+
+// a contact
+type Contact Struct (x_mixin="Audit") {
+    String name;
+}
+
+// a leftover
+type Unused String;
+
+// get a contact
+resource Contact GET "/contacts/{name}" {
+    String name;
+    expected OK;
+}
+`, "unused-type")
+	if len(messages) != 1 || !strings.Contains(messages[0], "'Unused'") {
+		t.Errorf("unused types are %v, want Unused only", messages)
+	}
+}
+
+func TestLintUnusedTypesTemplate(t *testing.T) {
+	messages := lintFindings(t, `name Sample;
+
+// a type parameter
+type T Any;
+
+// a page of items
+type Page Struct (x_type_params="T") {
+    Array<T> items;
+    String next (optional);
+}
+
+// a contact
+type Contact Struct {
+    String name;
+}
+
+// a page of contacts
+type ContactPage Page (x_type_args="Contact");
+
+// a leftover
+type Unused String;
+
+// list the contacts
+resource ContactPage GET "/contacts" {
+    expected OK;
+}
+`, "unused-type")
+	if len(messages) != 1 || !strings.Contains(messages[0], "'Unused'") {
+		t.Errorf("unused types are %v, want Unused only", messages)
+	}
+}
@@ -0,0 +1,455 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//
+// The lsp command runs a language server for rdl files, talking the Language Server Protocol
+// (JSON-RPC with Content-Length framing) over stdin and stdout. Each time a document is opened
+// or changed, its text is parsed as the schema file would be (its includes resolved next to the
+// file, then on the -I path), and the parse error, if any, is published as a diagnostic. The
+// server answers go-to-definition of a type name with the position of its definition (see
+// positions.go), hover with the resolved definition of the type (as the explain command prints
+// it), and completion with the names of the types the document can refer to.
+//
+
+var parseErrorLine = regexp.MustCompile(`^(?:Error|Warning)\((?:(.*):)?(\d+)\): (.*)$`)
+var includeErrorLine = regexp.MustCompile(`^(.*):(\d+): (.*)$`)
+var stagedFileName = regexp.MustCompile(`^\d+-(.*)$`)
+
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+// lspDocument is an open document, with what was learned of it from its last parse.
+type lspDocument struct {
+	uri       string
+	path      string
+	text      string
+	schema    *rdl.Schema //from the last successful parse
+	positions *schemaPositions
+	tempFile  string
+}
+
+type lspServer struct {
+	in          *bufio.Reader
+	out         io.Writer
+	strict      bool
+	includePath []string
+	documents   map[string]*lspDocument
+	shutdown    bool
+}
+
+// serveLSP runs the language server until the client asks it to exit.
+func serveLSP(in io.Reader, out io.Writer, strict bool, includePath []string) error {
+	s := &lspServer{
+		in:          bufio.NewReader(in),
+		out:         out,
+		strict:      strict,
+		includePath: includePath,
+		documents:   make(map[string]*lspDocument),
+	}
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("Exit without shutdown")
+			}
+			return nil
+		}
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			continue //a notification
+		}
+		reply := &lspMessage{JSONRPC: "2.0", ID: msg.ID, Result: result, Error: rerr}
+		if rerr == nil && result == nil {
+			reply.Result = json.RawMessage("null")
+		}
+		if err := s.write(reply); err != nil {
+			return err
+		}
+	}
+}
+
+// read reads the next message, framed by its Content-Length header.
+func (s *lspServer) read() (*lspMessage, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if i := strings.Index(line, ":"); i > 0 && strings.EqualFold(line[:i], "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[i+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("Bad Content-Length header: %s", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("Missing Content-Length header")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	var msg lspMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *lspServer) write(msg *lspMessage) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(&lspMessage{Method: method, Params: data})
+}
+
+func (s *lspServer) handle(msg *lspMessage) (interface{}, *lspError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, //full text on each change
+				"definitionProvider": true,
+				"hoverProvider":      true,
+				"completionProvider": map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{"name": "rdl"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		for _, doc := range s.documents {
+			s.close(doc)
+		}
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params lspTextDocumentPosition
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+		if doc, ok := s.documents[params.TextDocument.URI]; ok {
+			s.close(doc)
+			delete(s.documents, doc.uri)
+			s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": doc.uri, "diagnostics": []lspDiagnostic{}})
+		}
+		return nil, nil
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion":
+		var params lspTextDocumentPosition
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		switch msg.Method {
+		case "textDocument/definition":
+			return s.definition(doc, params.Position), nil
+		case "textDocument/hover":
+			return s.hover(doc, params.Position), nil
+		default:
+			return s.completion(doc), nil
+		}
+	}
+	if msg.ID != nil {
+		return nil, &lspError{Code: -32601, Message: "Method not found: " + msg.Method}
+	}
+	return nil, nil
+}
+
+// update reparses the document with its new text, and publishes the diagnostics of the parse.
+func (s *lspServer) update(uri string, text string) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		doc = &lspDocument{uri: uri, path: lspPath(uri)}
+		s.documents[uri] = doc
+	}
+	doc.text = text
+	diagnostics := s.parse(doc)
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diagnostics})
+}
+
+// parse parses the text of the document from a temporary copy, which resolves its includes
+// from the directory of the document first.
+func (s *lspServer) parse(doc *lspDocument) []lspDiagnostic {
+	diagnostics := []lspDiagnostic{}
+	if doc.tempFile == "" {
+		dir, err := ioutil.TempDir("", "rdl-lsp")
+		if err != nil {
+			return append(diagnostics, lspFileDiagnostic(err.Error()))
+		}
+		doc.tempFile = filepath.Join(dir, filepath.Base(doc.path))
+	}
+	if err := ioutil.WriteFile(doc.tempFile, []byte(doc.text), 0644); err != nil {
+		return append(diagnostics, lspFileDiagnostic(err.Error()))
+	}
+	includePath := append([]string{filepath.Dir(doc.path)}, s.includePath...)
+	if positions, err := scanSchemaPositions(doc.tempFile, includePath); err == nil {
+		doc.positions = positions
+	}
+	schema, err := parseWithIncludePath(doc.tempFile, includePath, false, s.strict, false)
+	if err != nil {
+		return append(diagnostics, s.diagnostic(doc, err))
+	}
+	if schema.Name == "" {
+		base := filepath.Base(doc.path)
+		schema.Name = rdl.Identifier(strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+	doc.schema = schema
+	return diagnostics
+}
+
+// diagnostic turns a parse error into a diagnostic on its line of the document. An error in an
+// included file is reported on the first line.
+func (s *lspServer) diagnostic(doc *lspDocument, err error) lspDiagnostic {
+	msg := strings.TrimSpace(strings.Replace(err.Error(), doc.tempFile, doc.path, -1))
+	file, line, text := "", 0, msg
+	if m := parseErrorLine.FindStringSubmatch(msg); m != nil {
+		file, text = m[1], m[3]
+		line, _ = strconv.Atoi(m[2])
+		if sm := stagedFileName.FindStringSubmatch(file); sm != nil {
+			file = sm[1]
+		}
+		if file != "" && file != filepath.Base(doc.path) {
+			line, text = 0, msg
+		}
+	} else if m := includeErrorLine.FindStringSubmatch(msg); m != nil && m[1] == doc.path {
+		line, _ = strconv.Atoi(m[2])
+		text = m[3]
+	}
+	d := lspFileDiagnostic(text)
+	if line > 0 {
+		lines := strings.Split(doc.text, "\n")
+		end := 0
+		if line <= len(lines) {
+			end = len([]rune(lines[line-1]))
+		}
+		d.Range = lspRange{Start: lspPosition{Line: line - 1}, End: lspPosition{Line: line - 1, Character: end}}
+	}
+	return d
+}
+
+func lspFileDiagnostic(msg string) lspDiagnostic {
+	return lspDiagnostic{Severity: 1, Source: "rdl", Message: msg}
+}
+
+func (s *lspServer) close(doc *lspDocument) {
+	if doc.tempFile != "" {
+		os.RemoveAll(filepath.Dir(doc.tempFile))
+		doc.tempFile = ""
+	}
+}
+
+// definition returns the location of the definition of the type named at the position.
+func (s *lspServer) definition(doc *lspDocument, pos lspPosition) interface{} {
+	word := lspWordAt(doc.text, pos)
+	if word == "" || doc.positions == nil {
+		return nil
+	}
+	p, ok := doc.positions.types[word]
+	if !ok {
+		return nil
+	}
+	uri := lspURI(p.File)
+	if p.File == doc.tempFile {
+		uri = doc.uri
+	}
+	start := lspPosition{Line: p.Line - 1, Character: p.Column - 1}
+	end := lspPosition{Line: p.Line - 1, Character: p.Column - 1 + len([]rune(word))}
+	return lspLocation{URI: uri, Range: lspRange{Start: start, End: end}}
+}
+
+// hover returns the resolved definition of the type named at the position, from the last
+// successful parse of the document.
+func (s *lspServer) hover(doc *lspDocument, pos lspPosition) interface{} {
+	word := lspWordAt(doc.text, pos)
+	if word == "" || doc.schema == nil {
+		return nil
+	}
+	reg := rdl.NewTypeRegistry(doc.schema)
+	t := reg.FindType(rdl.TypeRef(word))
+	if t == nil {
+		return nil
+	}
+	e := newTypeExplainer(doc.schema, reg)
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": "```\n" + e.explain(t) + "```"},
+	}
+}
+
+// completion returns the base types and the types defined by the document or its includes,
+// as of its last parse.
+func (s *lspServer) completion(doc *lspDocument) interface{} {
+	names := make(map[string]bool)
+	for bt := rdl.BaseTypeBool; bt <= rdl.BaseTypeAny; bt++ {
+		if bt != rdl.BaseTypeEnum {
+			names[bt.String()] = true
+		}
+	}
+	if doc.positions != nil {
+		for name := range doc.positions.types {
+			names[name] = true
+		}
+	}
+	if doc.schema != nil {
+		for _, t := range doc.schema.Types {
+			name, _, _ := rdl.TypeInfo(t)
+			names[string(name)] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	items := make([]map[string]interface{}, 0, len(sorted))
+	for _, name := range sorted {
+		items = append(items, map[string]interface{}{"label": name, "kind": 7}) //Class
+	}
+	return items
+}
+
+// lspWordAt returns the name at the position of the text, where the character is counted in
+// runes (which is what clients count for text without characters beyond the BMP).
+func lspWordAt(text string, pos lspPosition) string {
+	lines := strings.Split(text, "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return ""
+	}
+	line := []rune(lines[pos.Line])
+	isName := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+	start, end := pos.Character, pos.Character
+	if start > len(line) {
+		return ""
+	}
+	for start > 0 && isName(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isName(line[end]) {
+		end++
+	}
+	return string(line[start:end])
+}
+
+func lspPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+func lspURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
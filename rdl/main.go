@@ -14,13 +14,20 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // BuildDate is set when building to contain the build date
 var BuildDate string
 
 func usage() {
-	msg := `
+	fmt.Fprintf(os.Stderr, usageText)
+	os.Exit(0)
+}
+
+// usageText is the help of the command, also read for the generators and -x options it lists by
+// the completion scripts.
+const usageText = `
 Usage: rdl [OPTIONS] COMMAND [arg...]
 
 Parse and process an RDL file.
@@ -29,44 +36,348 @@ Options:
   -p           show errors and non-exported results in a prettier way (default is false)
   -w           suppress warnings (default is false)
   -s           parse in strict mode (default is false)
+  -I dir       add the directory to the search path for included files (repeatable)
 
 Commands:
   help
   version
-  parse <schemafile.rdl>
-  validate <datafile.json> <schemafile.rdl> [<typename>]
-  generate [-elt] [-o <outfile>] <generator> <schema.rdl>
+  parse [--positions] <schemafile.rdl>
+  validate [-t <typename>] <schemafile.rdl> <datafile.json>
+  generate [-elt] [-o <outfile> | -o -] [--single-file] [--config <file>] [--watch | --dry-run] [--prune] [--api-version <v>] [--tags <tags>] (<generator> | --template <dir>) <schema.rdl>...
+  generate [--config <file>] [--dry-run] [--prune] [<target>,...]
+  lint [-c <config.json>] [-r <rule>=<severity>] <schema.rdl>
+  fmt [-w | -d] <schema.rdl>
+  explain <schema.rdl> <typename>
+  stats [--json] <schema.rdl>
+  graph [-o dot | -o mermaid | -o json] <schema.rdl>
+  verify-compat [-H <header>] [-p <name>=<value>] [--annotated] <schema.rdl> <url>
+  lsp
+  repl <schema.rdl>
+  completion bash|zsh|fish
 
 Generator Options:
   -o path         Use the directory or file as output for generation. Default is stdout.
   -b path         Specify the base path of the URL for server and client generators.
   -e              Generate Enum constants prefixed by the type name to avoid collisions (default is false)
   --ns namespace  Use the specified namespace for code generation. Default is to use the namespace in the schema.
+  --config file   Map the namespaces of schemas to the package of each target language, overriding --ns.
+                  See Namespace Config below.
   -t              Generate precise type models, i.e. model string and numeric subtypes in Go (default is false)
+                  String types with a pattern or values then reject invalid values in their UnmarshalJSON.
   -l package      Generate code that imports this package as 'rdl' for base type impl (instead of standard rdl library)
   -u type         Generate the specified union type to JSON serialize as an untagged union. Default is a tagged.
+  gen1,gen2       Run several generators in one invocation, e.g. go-model,go-client. With -o they run concurrently.
+  --prune         Delete the files a previous run of the generator wrote to the -o directory that this run did not.
+                  The files each generator writes are listed in the .rdl-manifest.json of the directory.
+  --watch         Keep running, regenerating the -o output whenever the schema or a file it includes changes.
+                  Only the files whose content changed are rewritten, and each run prints a summary of them.
+  --dry-run       Print a unified diff of the -o files the generation would change, writing nothing, and exit
+                  with status 1 if there are any, e.g. to check in CI that generated code is up to date.
+  -o -            Write the generated files to stdout as a tar stream, e.g. to pipe them to tar -x -C dir.
+  --single-file   Write the generated files as one, to the -o file or stdout: a Go file of their package, with
+                  their imports merged, if they are Go files, or else their concatenation, each file preceded
+                  by a comment naming it.
+  --tags t1,!t2   Generate only the resources with one of the tags of their x_tags, and none of those prefixed
+                  with !. See Resource Tags below.
+  --template dir  Render the Go templates of the directory instead of running a generator. See --template below.
+  --api-version v Generate only the resources of this API version, i.e. those annotated with x_version="v"
+                  (or a list like x_version="1,2") and those without the annotation. See API Versions below.
   -x key=value    Set options for external generator, e.g. -x e=true -xfoo=bar will send -e true --foo bar to external generator.
 
+Go Generator Options (set with -x key=value):
+  validate=true   Check pattern, size, and range constraints in the generated Validate methods of go-model
+  clone=true      Generate deep copy Clone methods for the structs, unions, arrays, and maps of go-model
+  canonical=true  Generate a CanonicalJSON method for the structs of go-model, returning their JSON with sorted keys
+                  and no whitespace (RFC 8785, but for integers) to sign or hash, the same bytes as java-model's
+  retry=true      Let go-client retry idempotent requests on transient errors, as set by a RetryPolicy
+  typed-errors=true  Return a <Type>Error holding the decoded body from go-client for the exceptions of each
+                  type, also the rdl.ResourceError of its code for errors.As and errors.Is
+  otel=true       Wrap the handlers of go-server in OpenTelemetry spans, continuing the trace of incoming traceparent headers
+  lifecycle=true  Add /healthz and /readyz to go-server, with a Ready hook gating readiness, and a Serve function shutting down gracefully on SIGTERM
+  metrics=true    Count and time the requests of go-server and go-client in Prometheus metrics, by resource and status,
+                  registered with the Metrics registry of the server options, or of NewClientMetrics for the Metrics of the client
+  requests=true   Make the go-server handler methods take a <Method>Request struct of the inputs and return a <Method>Response
+  router=chi      Register the go-server resources on a chi router rather than an httptreemux one (or router=gorilla,
+                  or router=stdlib for the http.ServeMux patterns of Go 1.22), through a Router interface whose adapter
+                  can wrap an existing router of the application, set in the Router of the options
+  pointers=false  Declare optional bool, number, Timestamp, and UUID fields as values rather than pointers in go-model
+  omitempty=false Send unset optional fields of go-model structs (as null or a zero value) instead of omitting them
+  emitzero=true   Send required fields of go-model structs that hold a zero default, e.g. an explicit false or 0
+  collections=empty  Make the optional slices and maps of go-model structs empty in New<Type> and Init, rather than nil
+                  (collections=emit also sends them when empty, as [] or {}, omitting them only when nil, which needs Go 1.24)
+                  A field annotated x_go="pointer|value,omitempty|emitzero" overrides these, e.g. x_go="value,emitzero"
+  cbor=true       Add cbor struct tags, matching the json ones, to the structs and unions of go-model
+  msgpack=true    Add msgpack struct tags, matching the json ones, to the structs and unions of go-model
+  unions=interface  Make each union of go-model hold its variant in a Value field, of a sealed <Union>Value interface
+                  implemented by a <Union><Variant> struct per variant, for type switches. The JSON is unchanged
+  extrafields=true  Keep the unknown fields of the JSON objects of open go-model structs in an Extra map, encoded back
+  timestamp=time  Declare Timestamp fields as time.Time rather than rdl.Timestamp in go-model
+  uuid=google     Declare UUID fields as uuid.UUID of github.com/google/uuid rather than rdl.UUID in go-model
+
+Java Generator Options (set with -x key=value):
+  getsetters=true Generate get/set accessors instead of fluent setters in java-model
+  getters-setters=true  Make struct fields private, with JavaBean getX()/setX() accessors besides the fluent setters in java-model
+  builder=true    Generate a nested static Builder class for each struct in java-model
+  records=true    Generate immutable Java 17 records instead of classes for structs in java-model
+  immutable=true  Make the struct classes of java-model immutable, with final fields set by a @JsonCreator constructor (no setters)
+  optionals=true  Make optional struct fields private, with java.util.Optional getters in java-model
+  strict=true     Reject null for required fields in the setters of java-model, and add a constructor taking them
+  validation=javax  Annotate struct fields with Bean Validation constraints in java-model (or validation=jakarta)
+  jakarta=true    Import the jakarta.ws.rs, jakarta.servlet, and jakarta.inject packages of Jakarta EE 9+ instead of
+                  the javax ones in java-server and java-client, e.g. for Spring Boot 3 (with validation=jakarta in java-model)
+  jackson=false   Generate toJSON/fromJSON methods and a <Name>Json class instead of Jackson annotations in java-model
+  comparable=true Make the structs of java-model Comparable by their fields, and give enums values to order by
+                  A struct annotated x_compare="lastName,firstName" is Comparable by those fields alone, without the option
+  canonical=true  Generate a canonicalJSON method for the structs of java-model, returning the bytes of the
+                  canonical JSON that the CanonicalJSON method of go-model returns, and a <Name>Canonical class
+  serializable=true  Make the struct and union classes of java-model java.io.Serializable, with a serialVersionUID
+                  hashed from the names and types of their fields, stable until they change
+  timestamp=instant  Declare Timestamp fields as java.time.Instant in java-model, written as ISO 8601 strings
+                  (the ObjectMapper needs the JavaTimeModule of jackson-datatype-jsr310 registered)
+  uuid=java       Declare UUID fields as java.util.UUID rather than com.yahoo.rdl.UUID in java-model
+  list=LinkedList  Read the array fields of java-model into a LinkedList rather than an ArrayList
+  map=TreeMap     Read the map fields of java-model into a TreeMap, to keep their keys sorted, or a HashMap or
+                  LinkedHashMap (the default, which keeps their order)
+  unmodifiable=true  Return the array and map fields of java-model as unmodifiable views, from the getters of
+                  getsetters or getters-setters, and the fields of immutable classes
+  transport=urlconnection  Generate a java-client sending its requests on a pluggable Transport (or transport=apache)
+  typed-errors=true  Throw a <Type>Exception, a ResourceException with the decoded body as its getError(), from
+                  java-client for the exceptions of each type
+  async=true      Also generate an <Name>AsyncClient interface returning CompletableFuture in java-client
+                  and make the java-server handlers return CompletionStage, but those of resources with
+                  outputs, (async) or streams
+  tests=true      Also generate a JUnit <Name>ClientTest in java-client, calling each resource against the mock-server
+                  (or the -Drdl.client.url server) and checking its status, with disabled tests of its exceptions
+  server-flavor=spring  Generate Spring MVC controllers instead of JAX-RS resources in java-server
+  validate=true   Check request parameters and bodies against the schema constraints before the handler in java-server
+  main=true       Also generate a runnable <Name>Main in java-server, serving the resources on an embedded Jetty
+                  with --port, --tls-port, and --keystore flags, and loading the <Name>HandlerImpl (or --handler)
+
+Lint Rules (set with -r rule=severity, or {"rules": {"rule": "severity"}} in the -c config file):
+  unused-type         types that no other type or resource refers to (default: warning)
+  missing-comment     types and resources without a comment (default: warning)
+  missing-exceptions  resources that declare no exceptions (default: warning)
+  naming-case         type names not in UpperCamelCase, names not in the case used by most of the schema (default: warning)
+  duplicate-path      resources with the same method and path template (default: error)
+
 Generators (accepted arguments to the generate command):
   json        Generate the JSON representation of the schema
-  markdown    Generate the markdown representation of the schema and its comments
+  markdown    Generate the markdown representation of the schema and its comments (-x split=true for a page per type and resource group)
   go-model    Generate the Go code for the types in the schema
-  go-client   Generate the Go code for a client to the resources in the schema
+  go-client   Generate the Go code for a client to the resources in the schema, with a mock of it in <name>_client_mock.go
   go-server   Generate the Go code for a server implementation  of the resources in the schema
   java-model  Generate the Java code for the types in the schema
   java-client Generate the Java code for a client to the resources in the schema
   java-server Generate the Java code for a server implementation  of the resources in the schema
   swagger     Generage the swagger resource for the schema. If the outfile is an endpoint, serve it via HTTP.
+              Resources that authenticate or authorize require an apiKey header (-x security-header=<name>), or with
+              -x security=oauth2 -x oauth2-url=<url>, the oauth2 scope "<action>:<resource>" of their authorize.
+              Operations are grouped by their x_tags (or x_tag), or else the first segment of their path, have
+              the resource name (or e.g. getContact) as operationId, and link the x_docs URL of their resource
+              or type as externalDocs.
+  python-model Generate Python 3 dataclasses for the types in the schema (-x pydantic=true for pydantic models)
+  mock-server  Generate a runnable mock HTTP server for the resources in the schema (-x lang=go|java)
+  html        Generate static HTML documentation for the schema (-x tryit=true -x url=<base> for request forms)
+  testdata    Generate example JSON instances of the types in testdata/, with Go and JUnit round trip tests for them (-x lang=go|java)
+  scala-model Generate Scala case classes for the types in the schema (-x json=circe|play for JSON codecs)
+  sql         Generate the CREATE TABLE statements for the struct types annotated x_db_table (-x dialect=postgres|mysql)
+  ruby-model  Generate Ruby classes for the types in the schema, with attribute readers, from_json/to_json,
+              enum modules, and validation of the constraints of the types
+  dart-model  Generate Dart classes for the types in the schema, null safe, with fromJson/toJson and enums
+  cpp-model   Generate a C++17 header of structs for the types in the schema, with std::optional, std::variant
+              for unions, enum classes, and nlohmann::json conversions (-x namespace=<ns> for the C++ namespace)
+  elixir-model Generate Elixir structs with typespecs for the types in the schema, enums as atoms, validation
+              of the constraints of the types, and Jason encoding and decoding
+  terraform   Generate a Terraform provider managing the resources of the schema through go-client (-x client=<import path>)
   legacy      Generate the legacy (RDL v1) JSON representation of the schema
 
+  x-<name>    Invoke the generator plugin named 'rdl-gen-<name>', searched for in your $PATH. The
+              plugin reads the JSON representation of the schema from stdin, and writes a manifest of
+              the generated files, i.e. {"files": [{"name": "path", "content": "..."}]}, to stdout.
+              The files are written to the -o directory (or stdout). Options set by -x are passed as flags.
+
   <name>      Invoke an external generator named 'rdl-gen-<name>', searched for in your $PATH. The
               generator is passed the -o flag if it was set, and the JSON representation of the schema
               is written to its stdin.
 
+  --template <dir>  Instead of a generator, render the Go templates (text/template) of the directory, e.g.
+              rdl generate --template tmpl/ -o out schema.rdl. Each <path>.tmpl is rendered as <path> once for
+              the schema, and those under types/ and resources/ once for each type and resource, with .Schema,
+              .Registry, .Type, .Resource, and .Name. The paths are templates too, e.g. types/{{.Name}}.md.tmpl,
+              and files named _*.tmpl only define templates for the others. The functions besides the builtin
+              ones are capitalize, uncapitalize, lower, upper, kebab, join, replace, typeName, superType,
+              typeComment, baseType, fields, findType, goType, javaType, and dict and mapType for type tables,
+              e.g. mapType (dict "String" "TEXT" "Int32" "INTEGER" "Struct" "JSON") .Type. goType and javaType
+              return the type go-model and java-model declare a field as, e.g. goType . in {{range fields .Type}},
+              or a type as, given its items and keys if any, e.g. goType .Type, or javaType "Map" "Int32" "String".
+
+Streaming Resources:
+  A resource annotated with x_stream="sse" responds with server-sent events, each the JSON of one item
+  of the resource type. The go-server handler returns a channel of the items and java-server one an
+  Iterator, and the go-client and java-client methods return a stream to read the items from.
+
+Upload Resources:
+  A resource annotated with x_consumes="application/octet-stream" or x_consumes="multipart/form-data"
+  takes its request body as a stream, e.g. of a type Upload Bytes. The go-client method takes an
+  io.Reader, sent as it is read (for multipart, as the file part of a form named after the input).
+  The go-server handler gets the unbuffered request body as an io.Reader, or a *multipart.Reader.
+
+Streaming Responses:
+  A resource annotated with x_streaming_response="true" (for application/octet-stream) or set to the
+  content type, e.g. x_streaming_response="application/x-ndjson", hands its response body to the
+  caller unread, for large downloads not to be buffered in memory. The go-client method returns an
+  io.ReadCloser and the java-client one an InputStream (JAX-RS only, not with transport or async),
+  to be closed by the caller. The go-server handler returns an io.ReadCloser, copied to the client.
+
+Conditional Requests:
+  A GET resource annotated with x_etag="true" is cached by its ETag. The go-server responds with the
+  ETag the handler returns in the ETag header output of the resource, or else a hash of the JSON,
+  and with an empty 304 to a request whose If-None-Match has it. The go-client keeps the last response
+  of each URL in its ETags cache, sends its ETag with If-None-Match, and decodes the cached body when
+  the server responds 304, unless the resource takes If-None-Match as an input for the caller to set.
+
+Enum Elements:
+  An enum element annotated with x_wire="red" is written as that string instead of its symbol,
+  so that constants can be renamed without changing the wire format. Those of x_aliases="A,B" are
+  read as the element but never written, and x_deprecated="reason" marks it deprecated (@Deprecated
+  in java-model, a Deprecated comment in go-model). swagger lists the wire values in its enums, and
+  python-model, scala-model, ruby-model, dart-model, cpp-model, and elixir-model write and read
+  the enums by their wire names and aliases too.
+
+Deprecation:
+  A type, field, enum element, or resource annotated with x_deprecated="reason" is marked deprecated:
+  a "Deprecated:" comment in go-model, go-client, and go-server, @Deprecated in the Java generators,
+  deprecated: true on swagger operations (x-deprecated on definitions and properties, which swagger
+  2.0 cannot deprecate), and struck through in markdown and html. The other generators ignore it.
+
+Mixins:
+  A struct annotated with x_mixin="Audit,Paging" includes the fields of those struct types, before
+  its own as inherited fields are, so that common field sets are declared once. The schema is
+  flattened as it is parsed, and every generator sees the fields as the struct's own.
+
+Generic Types:
+  A struct annotated with x_type_params="T", T declared as "type T Any;", is generic, and a type
+  derived from it with x_type_args="Contact" instantiates it, the type argument becoming the items
+  of its Array<T> and Map<K,T> fields. The instantiations are expanded to plain structs as the
+  schema is parsed. go-model makes the generic type a Go generic struct, Page[T any], and java-model
+  a generic interface of getters that the instantiations implement. swagger defines only the
+  instantiations.
+
+API Versions:
+  The v1 and v2 variants of a resource can be declared side by side in one schema, annotated with
+  x_version="1" and x_version="2", and each version generated with --api-version into its own package
+  (with --ns) or output directory. A numeric version becomes the version of the schema, so the default
+  base path of java-server and java-client gets its /v<n> prefix.
+
+Resource Tags:
+  A resource annotated with x_tags="public" or x_tags="public,admin" is part of those surfaces of the API,
+  and generate --tags public keeps only the resources tagged public, so the public and the admin clients,
+  servers, and swagger come from one schema. A tag prefixed with ! drops its resources instead, e.g.
+  --tags '!admin' keeps the others, untagged ones included. swagger lists the operations under their tags.
+
+Nullable Fields:
+  A struct field annotated with x_nullable tells an explicit null apart from the field being absent.
+  In go-model it is a Nullable[T] with Present and Null flags, in java-model (with Jackson and its
+  Jdk8Module) a java.util.Optional that is null when absent and empty when null, and in swagger it
+  is marked x-nullable. Nullable fields cannot have a default.
+
+Bytes Fields:
+  A Bytes field, or one of a type derived from Bytes, e.g. "type Thumbnail Bytes (maxSize=65536)", is sent as
+  a base64 string. It is a []byte in go-model (a named []byte type with -t), a byte[] in java-model, read
+  and written by Jackson, or by java.util.Base64 with jackson=false, and a string of format byte in swagger.
+  Its size constraints are checked by the Validate methods of go-model (with validate=true), are @Size
+  constraints in java-model (with validation=javax), and are the lengths of the base64 string in swagger.
+
+Closed Structs:
+  With Jackson, java-model fails to read a closed struct, e.g. "type Point Struct (closed) {...}",
+  from a JSON object with fields not in the schema. The class of an open struct keeps them in its
+  extraFields() map instead, which Jackson writes back, and the record of an open struct ignores them.
+
+PATCH Resources:
+  A PATCH resource taking a struct, e.g. Contact, applies a JSON merge patch (RFC 7396) to it. The
+  Go and Java generators and swagger make it take a ContactPatch, with every field of Contact nullable,
+  so that a field left out of the request is unchanged and one set to null is removed. go-model adds
+  the ApplyPatch method to Contact, and java-model the applyTo method to ContactPatch, to apply the
+  patch. The MarshalJSON of a ContactPatch leaves out the fields that are not set, with any Go version.
+
+Batch Generation:
+  generate takes several schemas, as files, directories of .rdl files, or patterns like 'schemas/*.rdl'.
+  A schema may use the types defined by another schema of the batch without including it: they are
+  resolved from that schema, without its resources. With -o, each schema is generated into the
+  subdirectory named after its package (the Java generators lay out their output by namespace).
+
+Language Server:
+  lsp runs a Language Server Protocol server over stdin and stdout, for editors to configure as the
+  server of .rdl files. It reports parse errors as diagnostics, and offers go-to-definition and hover
+  (the resolved definition, as explain prints it) on type names, and completion of type names.
+
+Schema REPL:
+  repl loads the schema and reads queries from stdin, one per line, prompting for them on a terminal:
+  types and resources list them, or those whose name contains some text, find searches the names of
+  the types, fields, and resources, and explain, fields, constraints, and refs print what explain does
+  of a type, or a part of it. A line with just a type name explains it. help lists the queries.
+
+Shell Completion:
+  completion prints a script completing the commands, options, generators (also the rdl-gen-* ones in
+  your $PATH), and -x options of rdl, e.g. source <(rdl completion bash) in ~/.bashrc, rdl completion zsh
+  > _rdl in a directory of $fpath, or rdl completion fish > ~/.config/fish/completions/rdl.fish.
+
+Source Positions:
+  parse --positions prints the schema as JSON, adding a "position" with the file, line, and column
+  it is defined at to each type, field, and resource, for editors and other tools to map them back.
+
+Namespace Config:
+  generate --config reads a JSON or YAML file mapping the namespace of each schema to a package per
+  language, the first word of the generator name (go, java, python, ts, ...). The Go generators use
+  the package name at the end of the path (skipping a major version like v2), the Java generators the
+  package, and the external generators get it as the namespace of the schema. Unlisted targets use
+  --ns or the schema namespace, and a config can serve a batch of schemas, unlike --ns.
+    namespaces:
+      com.example.contacts:
+        java: com.example.contacts.api
+        go: github.com/example/contacts/api/v2
+
+Generation Targets:
+  The --config file may also declare the targets of a project, each a generation with its generator,
+  output, and options, for one generate to run them all, e.g. rdl generate --config rdl.yaml. Without
+  schemas, generate runs the targets of the --config file, or of the rdl.yaml, rdl.yml, or rdl.json of
+  the current directory, all of them or those named, e.g. rdl generate go,java. A target sets the
+  generate options by name: generator (or template), output, schemas, ns, basePath, lib, preciseTypes,
+  prefixEnums, untaggedUnions, options (the -x ones), apiVersion, tags, and prune. Its schemas default
+  to those of the config, and paths are relative to the config file.
+    schemas:
+      - schemas/*.rdl
+    targets:
+      go:
+        generator: go-model,go-client
+        output: gen/go
+        options:
+          validate: true
+
+Schema Stats:
+  stats prints the types by base type, the resources by method, the fields of each struct, the deepest
+  nesting of types, the unused types, and the fan-in and fan-out of each type, as text or (with --json)
+  as JSON, to track the growth of a schema across releases.
+
+Dependency Graph:
+  graph prints the types and resources of the schema as the nodes of a graph, with an edge from each type
+  to the types of its fields, items, keys, variants, and supertype, and from each resource to its type and
+  the types of its inputs, outputs, and exceptions, labeled with the field, input, or output name, or the
+  exception status. It is printed for Graphviz (-o dot, the default), e.g. rdl graph sample.rdl | dot -Tsvg,
+  as a Mermaid flowchart (-o mermaid), or as JSON (-o json) with the nodes and the kind of each edge.
+
+Compatibility Check:
+  verify-compat sends a request to each GET resource of the schema at the base URL of a live server, e.g.
+  http://localhost:4080/api, and reports the responses whose status the resource does not declare, or whose
+  body is not valid for the type of the resource (or of the exception of the status), as validate checks it.
+  The path parameters, and the query parameters and headers to send, are set with -p name=value, or for a
+  resource by x_verify="name=joe&verbose=true". x_verify="false" skips a resource, and --annotated probes
+  only those annotated with x_verify, e.g. x_verify="true". -H adds a header to all, e.g. for credentials.
+  The enums of the bodies are checked against their wire names (x_wire), so that a server still writing an
+  old wire name is reported, while a symbol renamed with its wire name kept is not. The enum values of -p and
+  x_verify may be given as symbols, and are sent as their wire names.
+
 `
-	fmt.Fprintf(os.Stderr, msg)
-	os.Exit(0)
-}
 
 func main() {
 	banner := "rdl (development version)"
@@ -82,6 +393,7 @@ func main() {
 	pretty := app.BoolOpt("p pretty", false, "show errors and non-exported results in a prettier way")
 	warning := app.BoolOpt("w nowarn", false, "suppress warnings")
 	strict := app.BoolOpt("s strict", false, "parse in strict mode")
+	includePath := app.StringsOpt("I", []string{}, "add the directory to the search path for included files")
 
 	app.Command("help", "Print extended help information and exit", func(cmd *cli.Cmd) {
 		usage()
@@ -93,21 +405,121 @@ func main() {
 	})
 
 	app.Command("parse", "parse the specified rdl file, to check syntax", func(cmd *cli.Cmd) {
+		positions := cmd.BoolOpt("positions", false, "print the schema as JSON, with the source file, line, and column of each type, field, and resource")
 		schemaFile := cmd.StringArg("FILE", "", "the rdl file defining the schema")
-		cmd.Spec = "FILE"
+		cmd.Spec = "[--positions] FILE"
+		cmd.Action = func() {
+			schema, _ := parse(*schemaFile, *pretty, *warning, *strict, *includePath)
+			if *positions {
+				exitOnError(printSchemaPositions(schema, *schemaFile, *includePath))
+			}
+		}
+	})
+
+	app.Command("lsp", "run a language server for rdl files over stdin and stdout", func(cmd *cli.Cmd) {
 		cmd.Action = func() {
-			parse(*schemaFile, *pretty, *warning, *strict)
+			exitOnError(serveLSP(os.Stdin, os.Stdout, *strict, *includePath))
+		}
+	})
+
+	app.Command("repl", "load the schema and query its types and resources interactively", func(cmd *cli.Cmd) {
+		schemaFile := cmd.StringArg("FILE", "", "the rdl file defining the schema")
+		cmd.Action = func() {
+			schema, name := parse(*schemaFile, *pretty, *warning, *strict, *includePath)
+			if schema.Name == "" {
+				schema.Name = name
+			}
+			exitOnError(runREPL(schema, os.Stdin, os.Stdout, isTerminal(os.Stdin)))
+		}
+	})
+
+	app.Command("completion", "print the completion script of rdl for bash, zsh, or fish", func(cmd *cli.Cmd) {
+		shell := cmd.StringArg("SHELL", "", "the shell: bash, zsh, or fish")
+		cmd.Action = func() {
+			exitOnError(printCompletion(*shell, os.Stdout))
 		}
 	})
 
 	app.Command("validate", "validate the specified data file for adherence to the schema", func(cmd *cli.Cmd) {
-		dataFile := cmd.StringArg("DATA", "", "a JSON file containing the data")
+		dataType := cmd.StringOpt("t type", "", "the name of the type in the schema for the data. By default, it is guessed")
+		files := cmd.StringsArg("FILES", []string{}, "the rdl file defining the schema and the JSON data file, or (as before) the data file, the rdl file, and optional type name")
+		cmd.Spec = "[-t] FILES..."
+		cmd.Action = func() {
+			schemaFile, dataFile, typename, err := validateArgs(*files, *dataType)
+			exitOnError(err)
+			schema, _ := parse(schemaFile, *pretty, *warning, *strict, *includePath)
+			exitOnError(validateData(schema, dataFile, typename, *pretty))
+		}
+	})
+
+	app.Command("lint", "check the schema for style and correctness problems", func(cmd *cli.Cmd) {
+		configFile := cmd.StringOpt("c config", "", "a JSON file setting the severity of lint rules, e.g. {\"rules\": {\"missing-comment\": \"off\"}}")
+		rules := cmd.StringsOpt("r rule", []string{}, "set the severity (off, warning, or error) of a lint rule, e.g. -r missing-comment=off")
+		schemaFile := cmd.StringArg("FILE", "", "the rdl file defining the schema")
+		cmd.Spec = "[-c] [-r...] FILE"
+		cmd.Action = func() {
+			schema, _ := parse(*schemaFile, *pretty, *warning, *strict, *includePath)
+			exitOnError(lint(schema, *schemaFile, *configFile, *rules))
+		}
+	})
+
+	app.Command("stats", "print the counts, nesting, and dependencies of the types and resources of the schema", func(cmd *cli.Cmd) {
+		asJSON := cmd.BoolOpt("json", false, "print the stats as JSON")
+		schemaFile := cmd.StringArg("FILE", "", "the rdl file defining the schema")
+		cmd.Spec = "[--json] FILE"
+		cmd.Action = func() {
+			schema, name := parse(*schemaFile, *pretty, *warning, *strict, *includePath)
+			if schema.Name == "" {
+				schema.Name = name
+			}
+			exitOnError(printSchemaStats(schema, *asJSON))
+		}
+	})
+
+	app.Command("graph", "print the dependency graph of the types and resources of the schema", func(cmd *cli.Cmd) {
+		format := cmd.StringOpt("o", "dot", "the format of the graph: dot, mermaid, or json")
+		schemaFile := cmd.StringArg("FILE", "", "the rdl file defining the schema")
+		cmd.Spec = "[-o] FILE"
+		cmd.Action = func() {
+			schema, name := parse(*schemaFile, *pretty, *warning, *strict, *includePath)
+			if schema.Name == "" {
+				schema.Name = name
+			}
+			exitOnError(printSchemaGraph(schema, *format))
+		}
+	})
+
+	app.Command("verify-compat", "check that the responses of the GET resources of a live server match the schema", func(cmd *cli.Cmd) {
+		headers := cmd.StringsOpt("H header", []string{}, "a header to send with each request, e.g. -H 'Authorization: Bearer xyz'")
+		params := cmd.StringsOpt("p param", []string{}, "the value of a path parameter, or of a query parameter or header input, e.g. -p name=joe")
+		annotated := cmd.BoolOpt("annotated", false, "probe only the resources annotated with x_verify")
+		schemaFile := cmd.StringArg("FILE", "", "the rdl file defining the schema")
+		baseURL := cmd.StringArg("URL", "", "the base URL of the server, e.g. http://localhost:4080/api")
+		cmd.Spec = "[-H...] [-p...] [--annotated] FILE URL"
+		cmd.Action = func() {
+			schema, _ := parse(*schemaFile, *pretty, *warning, *strict, *includePath)
+			exitOnError(verifyCompat(schema, *baseURL, *headers, *params, *annotated))
+		}
+	})
+
+	app.Command("explain", "print the resolved definition of a type of the schema", func(cmd *cli.Cmd) {
 		schemaFile := cmd.StringArg("FILE", "", "the rdl file defining the schema")
-		dataType := cmd.StringArg("TYPENAME", "", "the name of the type in the schema for the data. By default, it is guessed")
-		cmd.Spec = "DATA FILE [TYPENAME]"
+		typeName := cmd.StringArg("TYPE", "", "the name of the type to explain")
+		cmd.Spec = "FILE TYPE"
 		cmd.Action = func() {
-			schema, _ := parse(*schemaFile, *pretty, *warning, *strict)
-			validate(schema, *dataFile, *dataType, *pretty)
+			schema, _ := parse(*schemaFile, *pretty, *warning, *strict, *includePath)
+			exitOnError(explainType(schema, *typeName))
+		}
+	})
+
+	app.Command("fmt", "print the schema in canonical rdl formatting", func(cmd *cli.Cmd) {
+		write := cmd.BoolOpt("w write", false, "write the result to the schema file instead of stdout")
+		diff := cmd.BoolOpt("d diff", false, "print a diff of the changes instead of the result")
+		schemaFile := cmd.StringArg("FILE", "", "the rdl file defining the schema")
+		cmd.Spec = "[-w | -d] FILE"
+		cmd.Action = func() {
+			schema, _ := parse(*schemaFile, *pretty, *warning, *strict, *includePath)
+			exitOnError(formatSchemaFile(schema, *schemaFile, *write, *diff))
 		}
 	})
 
@@ -118,23 +530,159 @@ func main() {
 		untaggedUnions := cmd.StringsOpt("u", []string{}, "make this union type JSON serialize as an untagged union")
 		prefixEnums := cmd.BoolOpt("e", false, "Prefixes enum constant names with their typename (default = false)")
 		ns := cmd.StringOpt("ns", "", "Namespace for the code generation (default = schema namespace)")
+		configFile := cmd.StringOpt("config", "", "a JSON or YAML file mapping the namespaces of schemas to the package of each target language, overriding --ns")
 		basePath := cmd.StringOpt("b", "", "Specify the base path of the URL for java server and client generators (default = schema name, snake-cased)")
 		externalOptions := cmd.StringsOpt("x", []string{}, "Set options for external generator, e.g. -x e=true -xfoo=bar will send -e true --foo bar to external generator")
+		prune := cmd.BoolOpt("prune", false, "delete the files generated by a previous run that this run no longer generates")
+		watch := cmd.BoolOpt("watch", false, "keep running, regenerating the output whenever the schema or a file it includes changes")
+		dryRun := cmd.BoolOpt("dry-run", false, "print a diff of the files the generation would change instead of writing them, and exit with status 1 if there are any")
+		singleFile := cmd.BoolOpt("single-file", false, "write the generated files as one file, to -o or stdout: a Go file of their package if they are Go files, or else their concatenation")
+		apiVersion := cmd.StringOpt("api-version", "", "generate only the resources of this API version, as declared by their x_version annotations")
+		tags := cmd.StringOpt("tags", "", "generate only the resources with one of these comma-separated x_tags, or without those prefixed with !")
+		templateDir := cmd.StringOpt("template", "", "render the Go templates of this directory, for the schema and each of its types and resources, instead of running a generator")
 		generator := cmd.StringArg("GENERATOR", "", "the generator to use")
-		schemaFile := cmd.StringArg("FILE", "", "the rdl file defining the schema")
+		schemaArgs := cmd.StringsArg("FILES", []string{}, "the rdl files defining the schemas, or directories or patterns of them")
+		cmd.Spec = "[OPTIONS] [GENERATOR] [FILES...]"
 		cmd.Action = func() {
-			schema, name := parse(*schemaFile, *pretty, *warning, *strict)
+			if len(*schemaArgs) == 0 && *templateDir == "" {
+				//without schemas, run the targets of the config, those GENERATOR names if set
+				if *configFile == "" {
+					*configFile = findProjectConfig()
+				}
+				if *configFile == "" {
+					exitOnError(fmt.Errorf("Missing FILES (or the targets of a --config, rdl.yaml, or rdl.json)"))
+				}
+				if *outfile != "" || *preciseTypes || *librdl != RdlGoImport || len(*untaggedUnions) > 0 || *prefixEnums || *ns != "" || *basePath != "" || len(*externalOptions) > 0 || *watch || *singleFile || *apiVersion != "" || *tags != "" {
+					exitOnError(fmt.Errorf("The targets of %s set their generate options in it, only --prune and --dry-run apply to them", *configFile))
+				}
+				config, err := readGenerationConfig(*configFile)
+				exitOnError(err)
+				exitOnChanges(generateConfigTargets(banner, config, *generator, *pretty, *warning, *strict, *includePath, *prune, *dryRun))
+				return
+			}
+			if *templateDir != "" {
+				//with --template, there is no generator, what was taken for one is a schema
+				if *generator != "" {
+					*schemaArgs = append([]string{*generator}, *schemaArgs...)
+				}
+				*generator = templateFlavorPrefix + *templateDir
+			} else if *generator == "" {
+				exitOnError(fmt.Errorf("Missing GENERATOR (or --template)"))
+			}
+			files, err := schemaFiles(*schemaArgs)
+			exitOnError(err)
+			var config *GenerationConfig
+			if *configFile != "" {
+				config, err = readGenerationConfig(*configFile)
+				exitOnError(err)
+			}
+			if *dryRun && *watch {
+				exitOnError(fmt.Errorf("Cannot --watch with --dry-run"))
+			}
+			bundle := *outfile == "-" || *singleFile
+			if bundle && (*watch || *dryRun || *prune) {
+				exitOnError(fmt.Errorf("Cannot --watch, --dry-run, or --prune with -o - or --single-file"))
+			}
+			if len(files) > 1 {
+				if *watch {
+					exitOnError(fmt.Errorf("Cannot --watch several schemas"))
+				}
+				schemas, err := readSchemaBatch(files, *pretty, *warning, *strict, *includePath)
+				exitOnError(err)
+				if *dryRun {
+					exitOnChanges(dryRunGenerate(*outfile, *generator, *prune, func(dirName string) error {
+						return generateBatch(banner, *generator, dirName, *librdl, *prefixEnums, *preciseTypes, *ns, config, schemas, files, *untaggedUnions, *basePath, *externalOptions, *apiVersion, *tags)
+					}))
+					return
+				}
+				if bundle {
+					exitOnError(bundleGenerate(*outfile, *singleFile, func(dirName string) error {
+						return generateBatch(banner, *generator, dirName, *librdl, *prefixEnums, *preciseTypes, *ns, config, schemas, files, *untaggedUnions, *basePath, *externalOptions, *apiVersion, *tags)
+					}))
+					if *outfile != "" && *outfile != "-" {
+						exitOnError(updateManifest(*outfile, *generator, takeGenerated(), false))
+					}
+					return
+				}
+				exitOnError(generateBatch(banner, *generator, *outfile, *librdl, *prefixEnums, *preciseTypes, *ns, config, schemas, files, *untaggedUnions, *basePath, *externalOptions, *apiVersion, *tags))
+				exitOnError(updateManifest(*outfile, *generator, takeGenerated(), *prune))
+				return
+			}
+			schemaFile := files[0]
+			if *watch {
+				exitOnError(watchSchema(schemaFile, *includePath, *outfile, func(dirName string) error {
+					schema, name, err := readSchema(schemaFile, *pretty, *warning, *strict, *includePath)
+					if err != nil {
+						return err
+					}
+					if schema.Name == "" {
+						schema.Name = name
+					}
+					if err = selectAPIVersion(schema, *apiVersion); err != nil {
+						return err
+					}
+					if err = selectTags(schema, *tags); err != nil {
+						return err
+					}
+					err = generateTargets(banner, *generator, dirName, *librdl, *prefixEnums, *preciseTypes, *ns, config, schema, schemaFile, *untaggedUnions, *basePath, *externalOptions)
+					takeGenerated() //the files are in a scratch directory, the watch records what it copies
+					return err
+				}, func(files []string) error {
+					return updateManifest(*outfile, *generator, files, *prune)
+				}))
+				return
+			}
+			schema, name := parse(schemaFile, *pretty, *warning, *strict, *includePath)
 			if schema.Name == "" {
 				schema.Name = name
 			}
-			generate(banner, *generator, *outfile, *librdl, *prefixEnums, *preciseTypes, *ns, schema, *schemaFile, *untaggedUnions, *basePath, *externalOptions)
+			exitOnError(selectAPIVersion(schema, *apiVersion))
+			exitOnError(selectTags(schema, *tags))
+			if *dryRun {
+				exitOnChanges(dryRunGenerate(*outfile, *generator, *prune, func(dirName string) error {
+					return generateTargets(banner, *generator, dirName, *librdl, *prefixEnums, *preciseTypes, *ns, config, schema, schemaFile, *untaggedUnions, *basePath, *externalOptions)
+				}))
+				return
+			}
+			if bundle {
+				exitOnError(bundleGenerate(*outfile, *singleFile, func(dirName string) error {
+					return generateTargets(banner, *generator, dirName, *librdl, *prefixEnums, *preciseTypes, *ns, config, schema, schemaFile, *untaggedUnions, *basePath, *externalOptions)
+				}))
+				if *outfile != "" && *outfile != "-" {
+					exitOnError(updateManifest(*outfile, *generator, takeGenerated(), false))
+				}
+				return
+			}
+			exitOnError(generateTargets(banner, *generator, *outfile, *librdl, *prefixEnums, *preciseTypes, *ns, config, schema, schemaFile, *untaggedUnions, *basePath, *externalOptions))
+			exitOnError(updateManifest(*outfile, *generator, takeGenerated(), *prune))
 		}
 	})
-	app.Run(os.Args)
+	app.Run(stdoutArgs(os.Args))
 	os.Exit(0)
 }
 
-func parse(schemaFile string, pretty bool, warning bool, strict bool) (*rdl.Schema, rdl.Identifier) {
+// stdoutArgs joins "-o -" into "-o=-", as the command line parser takes a lone "-" for an
+// argument rather than the value of the option.
+func stdoutArgs(args []string) []string {
+	var joined []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-o" && i+1 < len(args) && args[i+1] == "-" {
+			joined = append(joined, "-o=-")
+			i++
+		} else {
+			joined = append(joined, args[i])
+		}
+	}
+	return joined
+}
+
+func parse(schemaFile string, pretty bool, warning bool, strict bool, includePath []string) (*rdl.Schema, rdl.Identifier) {
+	schema, name, err := readSchema(schemaFile, pretty, warning, strict, includePath)
+	exitOnError(err)
+	return schema, name
+}
+
+func readSchema(schemaFile string, pretty bool, warning bool, strict bool, includePath []string) (*rdl.Schema, rdl.Identifier, error) {
 	var err error
 	var schema *rdl.Schema
 	file := filepath.Base(schemaFile)
@@ -143,46 +691,40 @@ func parse(schemaFile string, pretty bool, warning bool, strict bool) (*rdl.Sche
 	switch ext {
 	case ".json":
 		data, err := ioutil.ReadFile(schemaFile)
-		exitOnError(err)
+		if err != nil {
+			return nil, "", err
+		}
 		err = json.Unmarshal(data, &schema)
 		//to do: an option to validate this against schema.rdl. The Schema type is closed, but
 		//go's json reader (to a struct) just ignores fields it can't use, so we dont' get an error.
-		exitOnError(err)
+		if err != nil {
+			return nil, "", err
+		}
 	default:
-		schema, err = rdl.ParseRDLFile(schemaFile, pretty, strict, warning)
-		exitOnError(err)
-	}
-	return schema, rdl.Identifier(name)
-}
-
-func validate(schema *rdl.Schema, filename string, typename string, pretty bool) {
-	data, err := readData(schema, filename, typename)
-	if err == nil {
-		validation := rdl.Validate(schema, typename, data)
-		if validation.Error != "" {
-			err = fmt.Errorf("Validation error (%s): %s", validation.Context, validation.Error)
-		} else {
-			if pretty {
-				j, err := json.MarshalIndent(validation, "", "    ")
-				if err == nil {
-					fmt.Println(string(j))
-				}
-			}
+		schema, err = parseWithIncludePath(schemaFile, includePath, pretty, strict, warning)
+		if err != nil {
+			return nil, "", err
 		}
 	}
-	exitOnError(err)
+	return schema, rdl.Identifier(name), nil
 }
 
-func readData(schema *rdl.Schema, filename string, typename string) (interface{}, error) {
-	bytes, err := ioutil.ReadFile(filename)
-	if err == nil {
-		var data interface{}
-		err = json.Unmarshal(bytes, &data)
-		if err == nil {
-			return data, nil
+// validateArgs sorts out the arguments of the validate command, which takes the schema and
+// data files, in that order, with -t for the type. The original form, with the data file first
+// and the type name as a third argument, is still accepted.
+func validateArgs(files []string, typename string) (string, string, string, error) {
+	switch len(files) {
+	case 2:
+		if typename == "" && !strings.HasSuffix(files[0], ".rdl") {
+			return files[1], files[0], "", nil
+		}
+		return files[0], files[1], typename, nil
+	case 3:
+		if typename == "" {
+			return files[1], files[0], files[2], nil
 		}
 	}
-	return nil, err
+	return "", "", "", fmt.Errorf("Usage: rdl validate [-t <typename>] <schemafile.rdl> <datafile.json>")
 }
 
 func ensureExtension(name string, ext string) string {
@@ -195,17 +737,117 @@ func ensureExtension(name string, ext string) string {
 	return name + ext
 }
 
-func generate(banner string, flavor string, dirName string, librdl string, prefixEnums bool, preciseTypes bool, ns string, schema *rdl.Schema, srcFile string, untaggedUnions []string, base string, externalOptions []string) {
+// generateTargets runs each of the comma-separated generators, e.g. go-model,go-client. With an
+// output directory, which is created if need be, they run concurrently, each with its own copy of
+// the schema. On stdout they run one at a time, so that their output is not interleaved.
+func generateTargets(banner string, flavors string, dirName string, librdl string, prefixEnums bool, preciseTypes bool, ns string, config *GenerationConfig, schema *rdl.Schema, srcFile string, untaggedUnions []string, base string, externalOptions []string) error {
+	targets := strings.Split(flavors, ",")
+	if len(targets) == 1 {
+		return generateOutput(banner, flavors, dirName, librdl, prefixEnums, preciseTypes, ns, config, schema, srcFile, untaggedUnions, base, externalOptions)
+	}
+	schemas := make([]*rdl.Schema, len(targets))
+	for i := range targets {
+		copied, err := copySchema(schema)
+		if err != nil {
+			return err
+		}
+		schemas[i] = copied
+	}
+	workers := len(targets)
+	if dirName == "" {
+		workers = 1
+	} else if err := os.MkdirAll(dirName, 0755); err != nil {
+		//the Java generators create it, but the others expect it, so do it before they race
+		return err
+	}
+	return runConcurrently(len(targets), workers, func(i int) error {
+		err := generateOutput(banner, targets[i], dirName, librdl, prefixEnums, preciseTypes, ns, config, schemas[i], srcFile, untaggedUnions, base, externalOptions)
+		if err != nil {
+			return fmt.Errorf("%s: %v", targets[i], err)
+		}
+		return nil
+	})
+}
+
+// copySchema returns a deep copy of the schema, so that generators can run side by side. The
+// defaults of the fields and inputs, which are scalars, are shared with the schema, to keep the
+// types the parser gave them, e.g. an enum symbol, which JSON would turn into a plain string.
+func copySchema(schema *rdl.Schema) (*rdl.Schema, error) {
+	j, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var copied *rdl.Schema
+	if err = json.Unmarshal(j, &copied); err != nil {
+		return nil, err
+	}
+	for i, t := range schema.Types {
+		if t.Variant == rdl.TypeVariantStructTypeDef {
+			for j, f := range t.StructTypeDef.Fields {
+				copied.Types[i].StructTypeDef.Fields[j].Default = f.Default
+			}
+		}
+	}
+	for i, r := range schema.Resources {
+		for j, in := range r.Inputs {
+			copied.Resources[i].Inputs[j].Default = in.Default
+		}
+	}
+	return copied, nil
+}
+
+// runConcurrently calls fn with each index below n, on up to the given number of goroutines. It
+// returns the error of the lowest index that failed, so the error reported does not depend on
+// the scheduling.
+func runConcurrently(n int, workers int, fn func(int) error) error {
+	errs := make([]error, n)
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(i)
+			<-slots
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchFlavors are the generators that make the PATCH resources take merge patch types (see
+// addPatchTypes), and emit them. The others generate the schema as written.
+var patchFlavors = map[string]bool{
+	"go-model": true, "go-client": true, "go-server": true,
+	"java-model": true, "java-client": true, "java-server": true,
+	"swagger": true,
+}
+
+func generateOutput(banner string, flavor string, dirName string, librdl string, prefixEnums bool, preciseTypes bool, ns string, config *GenerationConfig, schema *rdl.Schema, srcFile string, untaggedUnions []string, base string, externalOptions []string) error {
+	if patchFlavors[flavor] {
+		if err := addPatchTypes(schema); err != nil {
+			return err
+		}
+	}
+	ns = config.targetNamespace(schema, flavor, ns)
 	var err error
 	switch flavor {
 	case "json":
+		before := snapshotOutput(dirName)
 		err = rdl.ExportToJSON(schema, dirName)
+		recordChangedOutput(dirName, before)
 	case "go-model":
-		err = GenerateGoModel(banner, schema, dirName, ns, librdl, prefixEnums, preciseTypes, untaggedUnions)
+		err = GenerateGoModel(banner, schema, dirName, ns, librdl, prefixEnums, preciseTypes, untaggedUnions, externalOptions)
 	case "go-server":
-		err = GenerateGoServer(banner, schema, dirName, ns, librdl, prefixEnums, preciseTypes)
+		err = GenerateGoServer(banner, schema, dirName, ns, librdl, prefixEnums, preciseTypes, externalOptions)
 	case "go-client":
-		err = GenerateGoClient(banner, schema, dirName, ns, librdl, prefixEnums, preciseTypes)
+		err = GenerateGoClient(banner, schema, dirName, ns, librdl, prefixEnums, preciseTypes, externalOptions)
 	case "java-model":
 		err = GenerateJavaModel(banner, schema, dirName, ns, externalOptions)
 	case "java-server":
@@ -213,9 +855,20 @@ func generate(banner string, flavor string, dirName string, librdl string, prefi
 	case "java-client":
 		err = GenerateJavaClient(banner, schema, dirName, ns, base, externalOptions)
 	default:
-		err = generateExternally(flavor, dirName, schema, srcFile, externalOptions)
+		if mapped, ok := config.mappedNamespace(schema, flavor); ok {
+			schema.Namespace = rdl.NamespacedIdentifier(mapped)
+		}
+		if strings.HasPrefix(flavor, templateFlavorPrefix) {
+			err = generateFromTemplates(flavor[len(templateFlavorPrefix):], dirName, schema)
+		} else if strings.HasPrefix(flavor, "x-") {
+			err = generateWithPlugin(flavor[2:], dirName, schema, srcFile, externalOptions)
+		} else {
+			before := snapshotOutput(dirName)
+			err = generateExternally(flavor, dirName, schema, srcFile, externalOptions)
+			recordChangedOutput(dirName, before)
+		}
 	}
-	exitOnError(err)
+	return err
 }
 
 func exitOnError(err error) {
@@ -234,6 +887,13 @@ func generateExternally(flavor string, dirName string, schema *rdl.Schema, srcFi
 	}
 	argv = append(argv, "-s")
 	argv = append(argv, srcFile)
+	argv = append(argv, externalArgs(options)...)
+	return callSubcommand(cmd, argv, schema)
+}
+
+// externalArgs converts the -x key=value options to the flags passed to an external generator.
+func externalArgs(options []string) []string {
+	var argv []string
 	for _, option := range options {
 		substrings := strings.SplitN(option, "=", 2)
 		if len(substrings[0]) > 1 {
@@ -245,7 +905,7 @@ func generateExternally(flavor string, dirName string, schema *rdl.Schema, srcFi
 			argv = append(argv, substrings[1])
 		}
 	}
-	return callSubcommand(cmd, argv, schema)
+	return argv
 }
 
 func callSubcommand(command string, argv []string, schema *rdl.Schema) error {
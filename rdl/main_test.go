@@ -0,0 +1,94 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const enumDefaultSchema = `name Sample;
+
+type Color Enum {
+    RED
+    GREEN
+}
+
+type Contact Struct {
+    String name;
+    Color color (optional, default=RED);
+}
+
+resource Contact GET "/contacts/{name}" {
+    String name;
+    expected OK;
+}
+`
+
+// buildGoPackage builds the Go package in the directory against the rdl package the tests are
+// built with, and the other modules it imports as found in the module cache, or skips the test if
+// they cannot be found.
+func buildGoPackage(t *testing.T, dir string) {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/ardielle/ardielle-go").Output()
+	if err != nil || len(out) == 0 {
+		t.Skipf("cannot locate github.com/ardielle/ardielle-go: %v", err)
+	}
+	gomod := fmt.Sprintf("module sample\n\ngo 1.20\n\nrequire github.com/ardielle/ardielle-go v0.0.0\n\nreplace github.com/ardielle/ardielle-go => %s\n", strings.TrimSpace(string(out)))
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modcache, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	if err != nil {
+		t.Skipf("cannot locate the module cache: %v", err)
+	}
+	proxy := "file://" + filepath.ToSlash(filepath.Join(strings.TrimSpace(string(modcache)), "cache", "download"))
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY="+proxy, "GOSUMDB=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if strings.Contains(string(out), "cannot find module") {
+			t.Skipf("the modules the generated code imports are not in the module cache\n%s", out)
+		}
+		t.Fatalf("generated code does not build: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateTargetsEnumDefault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rdl-generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	schema := parseTestSchema(t, dir, "sample.rdl", enumDefaultSchema)
+	generate := func(flavors string) string {
+		out := filepath.Join(dir, strings.Replace(flavors, ",", "-", -1))
+		if err := os.Mkdir(out, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := generateTargets("rdl", flavors, out, RdlGoImport, false, false, "", nil, schema, filepath.Join(dir, "sample.rdl"), nil, "", nil); err != nil {
+			t.Fatalf("%s: %v", flavors, err)
+		}
+		return out
+	}
+	//the generators run side by side on copies of the schema, which must generate the same model
+	model, err := ioutil.ReadFile(filepath.Join(generate("go-model"), "sample_model.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, flavors := range []string{"go-model,go-client", "go-model,go-server"} {
+		copied, err := ioutil.ReadFile(filepath.Join(generate(flavors), "sample_model.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(copied) != string(model) {
+			t.Errorf("%s: the model differs from that of go-model alone\n%s", flavors, copied)
+		}
+	}
+	buildGoPackage(t, filepath.Join(dir, "go-model-go-client"))
+}
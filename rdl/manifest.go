@@ -0,0 +1,162 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//
+// Generating to an output directory records the files written in its .rdl-manifest.json, keyed
+// by the generator argument, e.g. {"generators": {"go-model": ["sample_model.go", ...]}}. The
+// built-in generators and plugins report each file they create. External generators write
+// their files themselves, so for them the output directory is compared before and after.
+//
+// With --prune, the files the manifest listed for the generator that it did not produce this
+// time, i.e. the output of renamed or removed types, are deleted. Without it they stay listed,
+// so that a later --prune still finds them. A file listed by another generator is never deleted.
+//
+
+const manifestName = ".rdl-manifest.json"
+
+// GenerationManifest lists the files each generator wrote, relative to the output directory.
+type GenerationManifest struct {
+	Generators map[string][]string `json:"generators"`
+}
+
+var generated struct {
+	sync.Mutex
+	files []string
+}
+
+// recordGenerated notes a file written by a generator. Generators may run concurrently.
+func recordGenerated(path string) {
+	generated.Lock()
+	generated.files = append(generated.files, path)
+	generated.Unlock()
+}
+
+// takeGenerated returns the files recorded since the last call.
+func takeGenerated() []string {
+	generated.Lock()
+	defer generated.Unlock()
+	files := generated.files
+	generated.files = nil
+	return files
+}
+
+// outputRoot returns the directory generated files go to, given the -o file or directory.
+func outputRoot(dirName string) string {
+	if fi, err := os.Stat(dirName); (err == nil && !fi.IsDir()) || (err != nil && filepath.Ext(dirName) != "") {
+		return filepath.Dir(dirName)
+	}
+	return dirName
+}
+
+// snapshotOutput stamps the files under the output directory, to find the files an external
+// generator writes.
+func snapshotOutput(dirName string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	if dirName == "" {
+		return stamps
+	}
+	filepath.Walk(outputRoot(dirName), func(path string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			stamps[path] = fileStamp{fi.ModTime(), fi.Size()}
+		}
+		return nil
+	})
+	return stamps
+}
+
+// recordChangedOutput records the files under the output directory that are new or changed
+// since the snapshot.
+func recordChangedOutput(dirName string, before map[string]fileStamp) {
+	for path, stamp := range snapshotOutput(dirName) {
+		old, ok := before[path]
+		if filepath.Base(path) != manifestName && (!ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size) {
+			recordGenerated(path)
+		}
+	}
+}
+
+// updateManifest records the files the generator wrote in the manifest of the output directory,
+// deleting the files it no longer generates if pruning.
+func updateManifest(dirName string, generator string, files []string, prune bool) error {
+	if dirName == "" {
+		return nil
+	}
+	root, err := filepath.Abs(outputRoot(dirName))
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, manifestName)
+	manifest := &GenerationManifest{}
+	if data, err := ioutil.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return fmt.Errorf("Cannot read %s: %v", path, err)
+		}
+	}
+	if manifest.Generators == nil {
+		manifest.Generators = make(map[string][]string)
+	}
+	current := make(map[string]bool)
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil {
+			if _, ok := localFileName(filepath.ToSlash(rel)); ok {
+				current[filepath.ToSlash(rel)] = true
+			}
+		}
+	}
+	previous := manifest.Generators[generator]
+	delete(manifest.Generators, generator)
+	others := make(map[string]bool)
+	for _, names := range manifest.Generators {
+		for _, name := range names {
+			others[name] = true
+		}
+	}
+	for _, name := range previous {
+		local, ok := localFileName(name)
+		if !ok || current[name] {
+			continue
+		}
+		stale := filepath.Join(root, local)
+		if _, err := os.Stat(stale); err != nil {
+			continue
+		}
+		if !prune || others[name] {
+			current[name] = !others[name]
+			continue
+		}
+		if err := os.Remove(stale); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Removed stale file %s\n", filepath.Join(outputRoot(dirName), local))
+	}
+	var names []string
+	for name, keep := range current {
+		if keep {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	manifest.Generators[generator] = names
+	j, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(strings.TrimSpace(string(j))+"\n"), 0644)
+}
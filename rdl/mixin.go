@@ -0,0 +1,107 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+//
+// A struct annotated with x_mixin, set to a comma-separated list of struct types, includes their
+// fields, so that the field sets common to many types are declared once:
+//
+//   type Audit Struct {
+//       Timestamp created;
+//       String createdBy;
+//   }
+//   type Contact Struct (x_mixin="Audit,Paging") {
+//       String name;
+//   }
+//
+// The fields of the mixins, with those they inherit or mix in themselves, come before the own
+// fields of the struct, in the order the mixins are listed, as inherited fields do. The schema
+// is flattened as it is parsed, so that every generator sees the fields as the struct's own.
+//
+
+// applyMixins adds the fields of its mixins to each struct of the schema annotated with x_mixin.
+func applyMixins(schema *rdl.Schema) error {
+	reg := rdl.NewTypeRegistry(schema)
+	done := make(map[rdl.TypeName]bool)
+	active := make(map[rdl.TypeName]bool)
+	var apply func(st *rdl.StructTypeDef) error
+	//applyChain applies the mixins of the struct type, and of those it derives from
+	applyChain := func(t *rdl.Type) error {
+		for t != nil && t.Variant == rdl.TypeVariantStructTypeDef {
+			if err := apply(t.StructTypeDef); err != nil {
+				return err
+			}
+			if t.StructTypeDef.Type == "Struct" {
+				break
+			}
+			t = reg.FindType(t.StructTypeDef.Type)
+		}
+		return nil
+	}
+	apply = func(st *rdl.StructTypeDef) error {
+		if done[st.Name] || len(mixinNames(st)) == 0 {
+			return nil
+		}
+		if active[st.Name] {
+			return fmt.Errorf("Struct %s: x_mixin cycle", st.Name)
+		}
+		active[st.Name] = true
+		defer delete(active, st.Name)
+		//the fields it inherits cannot be mixed in again, nor can its own
+		definedIn := make(map[rdl.Identifier]string)
+		if st.Type != "Struct" {
+			super := reg.FindType(st.Type)
+			if err := applyChain(super); err != nil {
+				return err
+			}
+			for _, f := range flattenedFields(reg, super) {
+				definedIn[f.Name] = string(st.Type)
+			}
+		}
+		var fields []*rdl.StructFieldDef
+		for _, name := range mixinNames(st) {
+			t := reg.FindType(rdl.TypeRef(name))
+			if t == nil || t.Variant != rdl.TypeVariantStructTypeDef {
+				return fmt.Errorf("Struct %s: x_mixin '%s' is not a struct type", st.Name, name)
+			}
+			if err := applyChain(t); err != nil {
+				return err
+			}
+			for _, f := range flattenedFields(reg, t) {
+				if other, ok := definedIn[f.Name]; ok {
+					return fmt.Errorf("Struct %s: field '%s' of %s is also in %s", st.Name, f.Name, name, other)
+				}
+				definedIn[f.Name] = name
+				copied := *f
+				fields = append(fields, &copied)
+			}
+		}
+		for _, f := range st.Fields {
+			if other, ok := definedIn[f.Name]; ok {
+				return fmt.Errorf("Struct %s: field '%s' is also in %s", st.Name, f.Name, other)
+			}
+		}
+		st.Fields = append(fields, st.Fields...)
+		done[st.Name] = true
+		return nil
+	}
+	for _, t := range schema.Types {
+		if t.Variant == rdl.TypeVariantStructTypeDef {
+			if err := apply(t.StructTypeDef); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mixinNames returns the struct types listed in the x_mixin annotation of the struct.
+func mixinNames(st *rdl.StructTypeDef) []string {
+	return commaList(st.Annotations["x_mixin"])
+}
@@ -0,0 +1,291 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//
+// generate --config reads a JSON or YAML file mapping the namespaces of schemas to the package
+// of each target language, overriding --ns for the targets it lists, e.g.
+//
+//   namespaces:
+//     com.example.contacts:
+//       java: com.example.contacts.api
+//       go: github.com/example/contacts/api
+//       ts: "@example/contacts"
+//
+// The language of a generator is the first word of its name: go for go-model, java for
+// java-client, python for python-model, and so on. The Go generators take the name of the
+// package at the end of the path (api above, and for .../api/v2 too), the Java ones the
+// package, and the external generators get it as the namespace of the schema.
+//
+
+// GenerationConfig is the format of the generation config file. Besides the namespaces, it may
+// declare the targets of a project, see generation-targets.go.
+type GenerationConfig struct {
+	Namespaces  map[string]map[string]string `json:"namespaces"`
+	Schemas     []string                     `json:"schemas"`
+	IncludePath []string                     `json:"includePath"`
+	Targets     map[string]*GenerationTarget `json:"targets"`
+	dir         string                       //the directory of the file, which its paths are relative to
+}
+
+// readGenerationConfig reads the config file, as YAML if it is named .yaml or .yml, and as JSON
+// otherwise.
+func readGenerationConfig(file string) (*GenerationConfig, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		obj, err := parseSimpleYAML(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if data, err = json.Marshal(obj); err != nil {
+			return nil, err
+		}
+	}
+	var config GenerationConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	config.dir = filepath.Dir(file)
+	return &config, nil
+}
+
+// generatorLanguage returns the language of the generator, the first word of its name.
+func generatorLanguage(flavor string) string {
+	flavor = strings.TrimPrefix(flavor, "x-")
+	if i := strings.Index(flavor, "-"); i > 0 {
+		return flavor[:i]
+	}
+	return flavor
+}
+
+// mappedNamespace returns the namespace the config maps the namespace of the schema to for the
+// language of the generator, if any.
+func (config *GenerationConfig) mappedNamespace(schema *rdl.Schema, flavor string) (string, bool) {
+	if config == nil {
+		return "", false
+	}
+	mapped, ok := config.Namespaces[string(schema.Namespace)][generatorLanguage(flavor)]
+	return mapped, ok && mapped != ""
+}
+
+// targetNamespace returns the namespace the generator generates the schema in, as the --ns of
+// the Go and Java generators: the one the config maps it to, or else ns.
+func (config *GenerationConfig) targetNamespace(schema *rdl.Schema, flavor string, ns string) string {
+	mapped, ok := config.mappedNamespace(schema, flavor)
+	if !ok {
+		return ns
+	}
+	switch generatorLanguage(flavor) {
+	case "go":
+		return goPackageName(mapped)
+	case "java":
+		return mapped
+	}
+	return ns
+}
+
+var goMajorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// goPackageName returns the name of the Go package at the end of the import path, skipping a
+// major version suffix, e.g. api for github.com/example/contacts/api/v2.
+func goPackageName(path string) string {
+	elems := strings.Split(strings.Trim(path, "/"), "/")
+	name := elems[len(elems)-1]
+	if goMajorVersion.MatchString(name) && len(elems) > 1 {
+		name = elems[len(elems)-2]
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// parseSimpleYAML parses the subset of YAML the config needs: nested mappings by indentation,
+// with plain or quoted scalars, lists of scalars, and comments.
+func parseSimpleYAML(src string) (map[string]interface{}, error) {
+	type level struct {
+		indent int
+		obj    map[string]interface{}
+	}
+	root := make(map[string]interface{})
+	stack := []level{{-1, root}}
+	var pending string //the key of a mapping whose entries are to follow
+	var list *yamlList //the list being read, if any
+	for n, line := range strings.Split(src, "\n") {
+		text := strings.TrimRight(stripYAMLComment(line), " \t\r")
+		if strings.TrimSpace(text) == "" || text == "---" {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		if strings.HasPrefix(strings.TrimLeft(text, " "), "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot indent YAML", n+1)
+		}
+		entry := strings.TrimSpace(text)
+		isItem := entry == "-" || strings.HasPrefix(entry, "- ")
+		if pending != "" && isItem && indent >= stack[len(stack)-1].indent {
+			//the entries of a list may be indented as its key
+			list = &yamlList{stack[len(stack)-1].obj, pending, indent, []interface{}{}}
+			pending = ""
+		}
+		if list != nil {
+			if isItem && indent == list.indent {
+				item, err := yamlItem(entry)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", n+1, err)
+				}
+				list.items = append(list.items, item)
+				continue
+			}
+			list.obj[list.key] = list.items
+			list = nil
+		}
+		if pending != "" {
+			if indent <= stack[len(stack)-1].indent {
+				stack[len(stack)-1].obj[pending] = nil
+			} else {
+				obj := make(map[string]interface{})
+				stack[len(stack)-1].obj[pending] = obj
+				stack = append(stack, level{indent, obj})
+			}
+			pending = ""
+		}
+		for indent < stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		if indent != stack[len(stack)-1].indent && len(stack) > 1 {
+			return nil, fmt.Errorf("line %d: bad indentation", n+1)
+		}
+		if len(stack) == 1 {
+			stack[0].indent = indent
+		}
+		key, value, err := splitYAMLEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		if value == "" {
+			pending = key
+			continue
+		}
+		scalar, err := yamlScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		stack[len(stack)-1].obj[key] = scalar
+	}
+	if list != nil {
+		list.obj[list.key] = list.items
+	}
+	if pending != "" {
+		stack[len(stack)-1].obj[pending] = nil
+	}
+	return root, nil
+}
+
+// yamlList is a list of scalars, the value of the key of the mapping.
+type yamlList struct {
+	obj    map[string]interface{}
+	key    string
+	indent int
+	items  []interface{}
+}
+
+// yamlItem returns the scalar of a "- value" entry of a list.
+func yamlItem(entry string) (interface{}, error) {
+	value := strings.TrimSpace(strings.TrimPrefix(entry, "-"))
+	if value == "" {
+		return nil, fmt.Errorf("empty list items are not supported")
+	}
+	if !strings.HasPrefix(value, "\"") && !strings.HasPrefix(value, "'") {
+		if strings.Contains(value, ": ") || strings.HasSuffix(value, ":") {
+			return nil, fmt.Errorf("lists of mappings are not supported")
+		}
+	}
+	return yamlScalar(value)
+}
+
+// stripYAMLComment removes a comment from the line, i.e. a # at its start or after a space,
+// outside of quotes.
+func stripYAMLComment(line string) string {
+	quote := rune(0)
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitYAMLEntry splits a "key: value" entry, the key possibly quoted.
+func splitYAMLEntry(entry string) (string, string, error) {
+	if strings.HasPrefix(entry, "- ") || entry == "-" {
+		return "", "", fmt.Errorf("a list must be the value of a key")
+	}
+	i := -1
+	if entry[0] == '"' || entry[0] == '\'' {
+		end := strings.IndexByte(entry[1:], entry[0])
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		if rest := entry[end+2:]; strings.HasPrefix(rest, ":") {
+			i = end + 2
+		}
+	} else {
+		for j := 0; j < len(entry); j++ {
+			if entry[j] == ':' && (j == len(entry)-1 || entry[j+1] == ' ') {
+				i = j
+				break
+			}
+		}
+	}
+	if i < 0 {
+		return "", "", fmt.Errorf("expected key: value")
+	}
+	key, err := yamlScalar(strings.TrimSpace(entry[:i]))
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprint(key), strings.TrimSpace(entry[i+1:]), nil
+}
+
+// yamlScalar returns the value of a scalar, unquoting it if it is quoted. Unquoted, it is a
+// string, as the config holds names only.
+func yamlScalar(s string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(s, "\""):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("unterminated quoted value")
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	case strings.HasPrefix(s, "{") || strings.HasPrefix(s, "["):
+		return nil, fmt.Errorf("flow collections are not supported")
+	}
+	return s, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+//
+// A PATCH resource taking a struct, e.g. Contact, applies a JSON merge patch (RFC 7396) to it:
+// the fields left out of the request are unchanged, those set to null are removed, and the others
+// are replaced, or merged if they hold structs themselves. Taking the struct itself, the
+// resource could not tell a field left out from one set to a zero value, so the generators make
+// it take a ContactPatch instead, added to the schema with every field of Contact optional and
+// nullable (see x_nullable), and its struct fields patches of their own. go-model gives Contact
+// an ApplyPatch method, and java-model gives ContactPatch an applyTo method, to apply one.
+//
+
+// patchTypeName returns the name of the merge patch type of a struct type.
+func patchTypeName(name rdl.TypeName) rdl.TypeName {
+	return name + "Patch"
+}
+
+// patchTarget returns the name of the struct type a merge patch type applies to, or "" if the
+// type is not a merge patch.
+func patchTarget(st *rdl.StructTypeDef) rdl.TypeName {
+	return rdl.TypeName(st.Annotations["x_patch"])
+}
+
+// unpatchedType returns the struct type a merge patch type applies to, or else the type itself,
+// so that the PATCH resource taking the patch of a Contact is still named after Contact.
+func unpatchedType(reg rdl.TypeRegistry, tref rdl.TypeRef) rdl.TypeRef {
+	if t := reg.FindType(tref); t != nil && t.Variant == rdl.TypeVariantStructTypeDef {
+		if name := patchTarget(t.StructTypeDef); name != "" {
+			return rdl.TypeRef(name)
+		}
+	}
+	return tref
+}
+
+// patchableType returns the struct type a merge patch can be made of, or nil if the type is
+// not a struct with fields.
+func patchableType(reg rdl.TypeRegistry, tref rdl.TypeRef) *rdl.Type {
+	t := reg.FindType(tref)
+	if t == nil || t.Variant != rdl.TypeVariantStructTypeDef || t.StructTypeDef.Name == "Struct" {
+		return nil
+	}
+	return t
+}
+
+// patchBody returns the input of the resource holding its request body, or nil if it has none.
+func patchBody(r *rdl.Resource) *rdl.ResourceInput {
+	for _, in := range r.Inputs {
+		if !in.PathParam && in.QueryParam == "" && in.Header == "" && in.Context == "" {
+			return in
+		}
+	}
+	return nil
+}
+
+// addPatchTypes adds to the schema the merge patch types of the structs taken by its PATCH
+// resources, each following the struct it patches, and makes the resources take them instead.
+func addPatchTypes(schema *rdl.Schema) error {
+	reg := rdl.NewTypeRegistry(schema)
+	patches := make(map[rdl.TypeName]*rdl.Type)
+	var addPatch func(t *rdl.Type) (rdl.TypeName, error)
+	addPatch = func(t *rdl.Type) (rdl.TypeName, error) {
+		st := t.StructTypeDef
+		name := patchTypeName(st.Name)
+		if _, ok := patches[st.Name]; ok {
+			return name, nil
+		}
+		if reg.FindType(rdl.TypeRef(name)) != nil {
+			return "", fmt.Errorf("Cannot add the merge patch type of %s, as %s is already defined", st.Name, name)
+		}
+		patch := &rdl.StructTypeDef{
+			Type:        "Struct",
+			Name:        name,
+			Comment:     fmt.Sprintf("A JSON merge patch of %s: the fields left out are unchanged, those set to null are removed, and the others replaced.", st.Name),
+			Annotations: map[rdl.ExtendedAnnotation]string{"x_patch": string(st.Name)},
+			Closed:      st.Closed,
+		}
+		patches[st.Name] = &rdl.Type{Variant: rdl.TypeVariantStructTypeDef, StructTypeDef: patch}
+		for _, f := range flattenedFields(reg, t) {
+			annotations := map[rdl.ExtendedAnnotation]string{"x_nullable": "true"}
+			for k, v := range f.Annotations {
+				if k != "x_go" && k != "x_nullable" {
+					annotations[k] = v
+				}
+			}
+			field := &rdl.StructFieldDef{
+				Name:        f.Name,
+				Type:        f.Type,
+				Optional:    true,
+				Comment:     f.Comment,
+				Items:       f.Items,
+				Keys:        f.Keys,
+				Annotations: annotations,
+			}
+			//a nullable field is replaced as a whole, as the patch cannot tell null from absent in it
+			if ft := patchableType(reg, f.Type); ft != nil && !nullableField(f) {
+				fname, err := addPatch(ft)
+				if err != nil {
+					return "", err
+				}
+				field.Type = rdl.TypeRef(fname)
+			}
+			patch.Fields = append(patch.Fields, field)
+		}
+		return name, nil
+	}
+	for _, r := range schema.Resources {
+		if r.Method != "PATCH" {
+			continue
+		}
+		body := patchBody(r)
+		if body == nil {
+			continue
+		}
+		t := patchableType(reg, body.Type)
+		if t == nil || patchTarget(t.StructTypeDef) != "" {
+			continue
+		}
+		name, err := addPatch(t)
+		if err != nil {
+			return err
+		}
+		body.Type = rdl.TypeRef(name)
+	}
+	if len(patches) == 0 {
+		return nil
+	}
+	var types []*rdl.Type
+	for _, t := range schema.Types {
+		types = append(types, t)
+		if t.Variant == rdl.TypeVariantStructTypeDef {
+			if patch, ok := patches[t.StructTypeDef.Name]; ok {
+				types = append(types, patch)
+			}
+		}
+	}
+	schema.Types = types
+	return nil
+}
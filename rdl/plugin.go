@@ -0,0 +1,99 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+//
+// Generator plugins are invoked with 'rdl generate x-<name>'. Like other external generators,
+// the plugin is an executable named 'rdl-gen-<name>' that reads the JSON representation of the
+// schema from stdin and is passed the -x options as flags. Instead of writing files itself, a
+// plugin writes a PluginManifest to stdout, and rdl writes the files in it to the output
+// directory. A plugin reports failure by exiting with a non-zero status, with the message on stderr.
+//
+
+// PluginManifest is the output of a generator plugin, e.g. {"files": [{"name": "x/y.txt", "content": "..."}]}
+type PluginManifest struct {
+	Files []*PluginFile `json:"files"`
+}
+
+// PluginFile is a generated file, named by a path relative to the output directory.
+type PluginFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+func generateWithPlugin(name string, dirName string, schema *rdl.Schema, srcFile string, options []string) error {
+	command := "rdl-gen-" + name
+	argv := append([]string{"-s", srcFile}, externalArgs(options)...)
+	j, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(command, argv...)
+	cmd.Stdin = bytes.NewReader(j)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Plugin %s failed: %v", command, err)
+	}
+	var manifest PluginManifest
+	err = json.Unmarshal(stdout.Bytes(), &manifest)
+	if err != nil {
+		return fmt.Errorf("Plugin %s did not write a valid manifest: %v", command, err)
+	}
+	return writePluginFiles(command, dirName, manifest.Files)
+}
+
+// writePluginFiles writes the files under the output directory, or to stdout if there is none.
+// File names may not be absolute or refer outside of the output directory.
+func writePluginFiles(command string, dirName string, files []*PluginFile) error {
+	for _, file := range files {
+		name, ok := localFileName(file.Name)
+		if !ok {
+			return fmt.Errorf("Plugin %s generated a file with a bad name: '%s'", command, file.Name)
+		}
+		if dirName == "" {
+			fmt.Print(file.Content)
+			continue
+		}
+		path := filepath.Join(dirName, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		recordGenerated(path)
+		_, err = out.WriteString(file.Content)
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localFileName converts a slash-separated relative file name to a local path, reporting whether
+// it stays below the directory it is relative to.
+func localFileName(fileName string) (string, bool) {
+	name := filepath.Clean(filepath.FromSlash(fileName))
+	if fileName == "" || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return name, true
+}
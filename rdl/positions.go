@@ -0,0 +1,370 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//
+// parse --positions prints the schema as JSON, with the source position (file, line, and
+// column) of each type, field, and resource, so that tools can map the entities back to the
+// lines that define them. The parser does not keep positions, so the schema file and the files
+// it includes are scanned for the definitions: a type is found at its name, a field at its name
+// in the struct body, and a resource at its resource keyword.
+//
+
+type sourcePosition struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type sourceToken struct {
+	text   string
+	line   int
+	column int
+}
+
+type resourcePosition struct {
+	method string
+	path   string
+	pos    *sourcePosition
+	used   bool
+}
+
+type schemaPositions struct {
+	types     map[string]*sourcePosition
+	fields    map[string]map[string]*sourcePosition
+	resources []*resourcePosition
+}
+
+// printSchemaPositions prints the schema parsed from the file as JSON, annotated with the
+// positions of its definitions.
+func printSchemaPositions(schema *rdl.Schema, schemaFile string, includePath []string) error {
+	if filepath.Ext(schemaFile) == ".json" {
+		return fmt.Errorf("Source positions need the rdl file of the schema, not %s", schemaFile)
+	}
+	positions, err := scanSchemaPositions(schemaFile, includePath)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	if types, ok := obj["types"].([]interface{}); ok {
+		for _, t := range types {
+			positions.annotateType(t)
+		}
+	}
+	if resources, ok := obj["resources"].([]interface{}); ok {
+		for _, r := range resources {
+			positions.annotateResource(r)
+		}
+	}
+	j, err := json.MarshalIndent(obj, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", j)
+	return nil
+}
+
+// scanSchemaPositions scans the schema file and the files it includes, resolved as the parser
+// sees them, for the positions of their definitions. A definition found in an earlier file
+// wins, the schema file coming first.
+func scanSchemaPositions(schemaFile string, includePath []string) (*schemaPositions, error) {
+	dir, err := ioutil.TempDir("", "rdl-positions")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	res := newIncludeResolver(includePath, dir)
+	root, err := res.stage(schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{schemaFile}
+	var included []string
+	for name := range res.sources {
+		if name != root {
+			included = append(included, name)
+		}
+	}
+	sort.Strings(included)
+	for _, name := range included {
+		files = append(files, res.sources[name])
+	}
+	positions := &schemaPositions{
+		types:  make(map[string]*sourcePosition),
+		fields: make(map[string]map[string]*sourcePosition),
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		positions.scan(file, tokenizeSource(string(data)))
+	}
+	return positions, nil
+}
+
+// tokenizeSource splits the source into names, quoted strings, and single punctuation
+// characters, dropping whitespace and comments.
+func tokenizeSource(src string) []sourceToken {
+	var tokens []sourceToken
+	runes := []rune(src)
+	line, column := 1, 1
+	i := 0
+	advance := func() {
+		if runes[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+		i++
+	}
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			advance()
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				advance()
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			advance()
+			advance()
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				advance()
+			}
+			if i < len(runes) {
+				advance()
+				advance()
+			}
+		case r == '"':
+			tok := sourceToken{line: line, column: column}
+			start := i
+			advance()
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					advance()
+				}
+				advance()
+			}
+			if i < len(runes) {
+				advance()
+			}
+			tok.text = string(runes[start:i])
+			tokens = append(tokens, tok)
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			tok := sourceToken{line: line, column: column}
+			start := i
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '.' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				advance()
+			}
+			tok.text = string(runes[start:i])
+			tokens = append(tokens, tok)
+		default:
+			tokens = append(tokens, sourceToken{text: string(r), line: line, column: column})
+			advance()
+		}
+	}
+	return tokens
+}
+
+// scan records the definitions in the tokens of the file.
+func (p *schemaPositions) scan(file string, tokens []sourceToken) {
+	at := func(tok sourceToken) *sourcePosition {
+		return &sourcePosition{File: file, Line: tok.line, Column: tok.column}
+	}
+	for i := 0; i < len(tokens); {
+		switch {
+		case tokens[i].text == "type" && i+2 < len(tokens):
+			name := tokens[i+1].text
+			if _, ok := p.types[name]; !ok {
+				p.types[name] = at(tokens[i+1])
+				body := bodyStart(tokens, i+2)
+				if body >= 0 && tokens[i+2].text != "enum" {
+					fields := make(map[string]*sourcePosition)
+					for _, f := range fieldNames(tokens, body+1) {
+						if _, ok := fields[f.text]; !ok {
+							fields[f.text] = at(f)
+						}
+					}
+					p.fields[name] = fields
+				}
+			}
+		case tokens[i].text == "resource" && i+3 < len(tokens):
+			path, err := strconv.Unquote(tokens[i+3].text)
+			if err == nil {
+				//the parsed path leaves out the query
+				if q := strings.Index(path, "?"); q >= 0 {
+					path = path[:q]
+				}
+				p.resources = append(p.resources, &resourcePosition{method: strings.ToUpper(tokens[i+2].text), path: path, pos: at(tokens[i])})
+			}
+		}
+		i = statementEnd(tokens, i)
+	}
+}
+
+// bodyStart returns the index of the brace opening the body of the definition whose tokens
+// start at i, or -1 if it has none.
+func bodyStart(tokens []sourceToken, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(", "<":
+			depth++
+		case ")", ">":
+			depth--
+		case ";":
+			if depth == 0 {
+				return -1
+			}
+		case "{":
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// fieldNames returns the name tokens of the fields of the struct body starting at i, each field
+// being a type (possibly parameterized, as in Array<String>) followed by its name.
+func fieldNames(tokens []sourceToken, i int) []sourceToken {
+	var names []sourceToken
+	for i < len(tokens) && tokens[i].text != "}" {
+		j := i + 1
+		if j < len(tokens) && tokens[j].text == "<" {
+			for depth := 0; j < len(tokens); j++ {
+				if tokens[j].text == "<" {
+					depth++
+				} else if tokens[j].text == ">" {
+					depth--
+					if depth == 0 {
+						j++
+						break
+					}
+				}
+			}
+		}
+		if j < len(tokens) && isSourceName(tokens[j].text) {
+			names = append(names, tokens[j])
+		}
+		for depth := 0; j < len(tokens); j++ {
+			t := tokens[j].text
+			if t == "(" {
+				depth++
+			} else if t == ")" {
+				depth--
+			} else if depth == 0 && (t == ";" || t == "}") {
+				break
+			}
+		}
+		if j < len(tokens) && tokens[j].text == ";" {
+			j++
+		}
+		i = j
+	}
+	return names
+}
+
+// statementEnd returns the index of the token after the top level statement starting at i,
+// which ends with a semicolon or the brace closing its body.
+func statementEnd(tokens []sourceToken, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(", "{":
+			depth++
+		case ")":
+			depth--
+		case "}":
+			depth--
+			if depth <= 0 {
+				return i + 1
+			}
+		case ";":
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+func isSourceName(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// annotateType adds the positions of the type and its fields to the JSON of a type, which is
+// an object holding its variant.
+func (p *schemaPositions) annotateType(t interface{}) {
+	variant, ok := t.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, v := range variant {
+		def, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := def["name"].(string)
+		if pos, ok := p.types[name]; ok {
+			def["position"] = pos
+		}
+		fields, _ := def["fields"].([]interface{})
+		for _, f := range fields {
+			if field, ok := f.(map[string]interface{}); ok {
+				fieldName, _ := field["name"].(string)
+				if pos, ok := p.fields[name][fieldName]; ok {
+					field["position"] = pos
+				}
+			}
+		}
+	}
+}
+
+// annotateResource adds its position to the JSON of a resource, matched by method and path.
+// Resources with the same method and path, e.g. in different API versions, are matched in the
+// order they are defined.
+func (p *schemaPositions) annotateResource(r interface{}) {
+	res, ok := r.(map[string]interface{})
+	if !ok {
+		return
+	}
+	method, _ := res["method"].(string)
+	path, _ := res["path"].(string)
+	for _, rp := range p.resources {
+		if !rp.used && rp.method == strings.ToUpper(method) && rp.path == path {
+			rp.used = true
+			res["position"] = rp.pos
+			return
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+//
+// The repl command loads a schema once and answers queries about it, one per line, to find one's
+// way around a large schema: the types and resources, those whose names contain some text, and for
+// a type, what explain prints of it, or only its fields, constraints, or the types and resources
+// that refer to it.
+//
+
+const replHelp = `Commands:
+  types [text]        list the types, or those whose name contains the text
+  resources [text]    list the resources, or those whose method, path, or name contains the text
+  find <text>         list the types, fields, and resources whose name contains the text
+  explain <type>      print the resolved definition of the type, as rdl explain does (or just <type>)
+  fields <type>       print the fields of the struct type, the inherited ones first
+  constraints <type>  print the constraints of the type, its own and inherited ones
+  refs <type>         print the types and resources that refer to the type
+  help                print this help
+  quit                exit, as does the end of the input
+`
+
+type schemaREPL struct {
+	schema   *rdl.Schema
+	registry rdl.TypeRegistry
+	inline   map[rdl.TypeRef]*rdl.Type //the types of the fields declared with constraints
+	out      io.Writer
+}
+
+// runREPL evaluates the commands read from in, printing their results to out. When interactive,
+// it prompts for each command.
+func runREPL(schema *rdl.Schema, in io.Reader, out io.Writer, interactive bool) error {
+	r := &schemaREPL{schema: schema, registry: rdl.NewTypeRegistry(schema), inline: inlineFieldTypes(schema), out: out}
+	if interactive {
+		fmt.Fprintf(out, "schema %s: %d types, %d resources. Type help for the commands.\n", schema.Name, len(declaredTypes(schema, r.inline)), len(schema.Resources))
+	}
+	scanner := bufio.NewScanner(in)
+	for {
+		if interactive {
+			fmt.Fprint(out, "rdl> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 {
+			continue
+		}
+		if words[0] == "quit" || words[0] == "exit" {
+			return nil
+		}
+		if err := r.eval(words[0], words[1:]); err != nil {
+			fmt.Fprintf(out, "*** %v\n", err)
+		}
+	}
+	if interactive {
+		fmt.Fprintln(out)
+	}
+	return scanner.Err()
+}
+
+// isTerminal tells whether the file is a terminal rather than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (r *schemaREPL) eval(command string, args []string) error {
+	arg := strings.Join(args, " ")
+	switch command {
+	case "help":
+		fmt.Fprint(r.out, replHelp)
+	case "types":
+		r.listTypes(arg)
+	case "resources":
+		r.listResources(arg)
+	case "find":
+		if arg == "" {
+			return fmt.Errorf("Usage: find <text>")
+		}
+		r.find(arg)
+	case "explain", "fields", "constraints", "refs":
+		if arg == "" {
+			return fmt.Errorf("Usage: %s <type>", command)
+		}
+		t, err := r.findType(arg)
+		if err != nil {
+			return err
+		}
+		return r.describe(command, t)
+	default:
+		if len(args) == 0 {
+			if t, err := r.findType(command); err == nil {
+				return r.describe("explain", t)
+			}
+		}
+		return fmt.Errorf("Unknown command or type '%s', type help for the commands", command)
+	}
+	return nil
+}
+
+// findType returns the named type, or the one type whose name is the same but for the case.
+func (r *schemaREPL) findType(name string) (*rdl.Type, error) {
+	if t := r.registry.FindType(rdl.TypeRef(name)); t != nil {
+		return t, nil
+	}
+	var found *rdl.Type
+	for _, t := range r.schema.Types {
+		tName, _, _ := rdl.TypeInfo(t)
+		if strings.EqualFold(string(tName), name) {
+			if found != nil {
+				return nil, fmt.Errorf("Type '%s' is not defined in the schema", name)
+			}
+			found = t
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("Type '%s' is not defined in the schema", name)
+	}
+	return found, nil
+}
+
+func (r *schemaREPL) describe(command string, t *rdl.Type) error {
+	e := newTypeExplainer(r.schema, r.registry)
+	tName, _, _ := rdl.TypeInfo(t)
+	switch command {
+	case "explain":
+		fmt.Fprint(r.out, e.explain(t))
+	case "fields":
+		if t.Variant != rdl.TypeVariantStructTypeDef {
+			return fmt.Errorf("Type '%s' is not a struct", tName)
+		}
+		e.fields(t, tName)
+		fmt.Fprint(r.out, e.f.buf.String())
+	case "constraints":
+		constraints := e.constraints(t, tName)
+		if len(constraints) == 0 {
+			fmt.Fprintf(r.out, "%s has no constraints\n", tName)
+		}
+		for _, c := range constraints {
+			fmt.Fprintf(r.out, "    %s\n", c)
+		}
+	case "refs":
+		refs := e.references(tName)
+		if len(refs) == 0 {
+			fmt.Fprintf(r.out, "%s is not referenced by any type or resource\n", tName)
+		}
+		for _, ref := range refs {
+			fmt.Fprintf(r.out, "    %s\n", ref)
+		}
+	}
+	return nil
+}
+
+func containsFold(s string, text string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(text))
+}
+
+// listTypes prints the types whose name contains the text, with the chain of types each derives
+// from and the first line of its comment.
+func (r *schemaREPL) listTypes(text string) {
+	e := newTypeExplainer(r.schema, r.registry)
+	w := tabwriter.NewWriter(r.out, 0, 8, 2, ' ', 0)
+	for _, t := range declaredTypes(r.schema, r.inline) {
+		tName, _, tComment := rdl.TypeInfo(t)
+		if !containsFold(string(tName), text) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s", tName, strings.Join(e.chain(t)[1:], " -> "))
+		if comment := firstLine(tComment); comment != "" {
+			fmt.Fprintf(w, "\t%s", comment)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+func firstLine(comment string) string {
+	return strings.TrimSpace(strings.SplitN(comment, "\n", 2)[0])
+}
+
+func (r *schemaREPL) listResources(text string) {
+	w := tabwriter.NewWriter(r.out, 0, 8, 2, ' ', 0)
+	for _, res := range r.schema.Resources {
+		if containsFold(res.Method+" "+res.Path+" "+string(res.Name), text) {
+			r.printResource(w, res)
+		}
+	}
+	w.Flush()
+}
+
+func (r *schemaREPL) printResource(w io.Writer, res *rdl.Resource) {
+	fmt.Fprintf(w, "%s\t%s\t%s", res.Method, res.Path, res.Type)
+	if res.Name != "" {
+		fmt.Fprintf(w, "\t(%s)", res.Name)
+	}
+	fmt.Fprintln(w)
+}
+
+// find prints the types, fields, and resources whose name contains the text.
+func (r *schemaREPL) find(text string) {
+	w := tabwriter.NewWriter(r.out, 0, 8, 2, ' ', 0)
+	for _, t := range declaredTypes(r.schema, r.inline) {
+		tName, _, _ := rdl.TypeInfo(t)
+		if containsFold(string(tName), text) {
+			fmt.Fprintf(w, "type\t%s\n", tName)
+		}
+		if t.Variant == rdl.TypeVariantStructTypeDef {
+			for _, f := range t.StructTypeDef.Fields {
+				if containsFold(string(f.Name), text) {
+					ftype := f.Type
+					if inline := r.inline[f.Type]; inline != nil {
+						ftype = inlineBaseType(inline)
+					}
+					fmt.Fprintf(w, "field\t%s.%s\t%s\n", tName, f.Name, formatTypeRef(ftype, f.Items, f.Keys))
+				}
+			}
+		}
+	}
+	for _, res := range r.schema.Resources {
+		if containsFold(res.Path+" "+string(res.Name), text) {
+			fmt.Fprint(w, "resource\t")
+			r.printResource(w, res)
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"strings"
+)
+
+//
+// Resources can be tagged with the surfaces of the API they are part of, e.g. x_tags="public" or
+// x_tags="public,admin", and generate --tags keeps only the resources with one of the tags, so
+// that the public and the admin clients, servers, and swagger come from the same schema. A tag
+// prefixed with ! drops the resources with it instead, e.g. --tags '!admin' keeps all the others,
+// untagged ones included.
+//
+
+// resourceTags returns the tags listed in the x_tags annotation of the resource.
+func resourceTags(r *rdl.Resource) []string {
+	var tags []string
+	for _, tag := range strings.Split(r.Annotations["x_tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// hasTags tells whether the resource has one of the tags, and none of the excluded ones.
+func hasTags(r *rdl.Resource, tags []string, excluded []string) bool {
+	rtags := make(map[string]bool)
+	for _, tag := range resourceTags(r) {
+		rtags[tag] = true
+	}
+	for _, tag := range excluded {
+		if rtags[tag] {
+			return false
+		}
+	}
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		if rtags[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// selectTags drops the resources of the schema that do not have one of the tags of the
+// comma-separated list, or that have one of the excluded ones, e.g. "public,!deprecated".
+func selectTags(schema *rdl.Schema, tagList string) error {
+	if strings.TrimSpace(tagList) == "" {
+		return nil
+	}
+	var tags, excluded []string
+	for _, tag := range strings.Split(tagList, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "!") {
+			excluded = append(excluded, strings.TrimSpace(tag[1:]))
+		} else if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	var resources []*rdl.Resource
+	for _, r := range schema.Resources {
+		if hasTags(r, tags, excluded) {
+			resources = append(resources, r)
+		}
+	}
+	if len(resources) == 0 && len(schema.Resources) > 0 {
+		return fmt.Errorf("No resource of the schema is tagged for --tags %s", tagList)
+	}
+	schema.Resources = resources
+	return nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+//
+// The stats command reports the size and shape of a schema, to track its growth across releases
+// and spot refactoring targets: the types by base type, the resources by method, the fields of
+// each struct (including inherited ones), the deepest nesting of types, the unused types, and for
+// each type its fan-in (the types and resources referring to it) and fan-out (the types it
+// refers to). Nesting follows fields, items, keys, and variants, not supertypes, and only the
+// types of the schema count, not the base types.
+//
+
+// SchemaStats is the report of the stats command, as printed with --json.
+type SchemaStats struct {
+	Name              string              `json:"name"`
+	Types             int                 `json:"types"`
+	TypesByBaseType   map[string]int      `json:"typesByBaseType"`
+	Resources         int                 `json:"resources"`
+	ResourcesByMethod map[string]int      `json:"resourcesByMethod"`
+	FieldsPerStruct   map[string]int      `json:"fieldsPerStruct"`
+	DeepestNesting    int                 `json:"deepestNesting"`
+	DeepestPath       []string            `json:"deepestPath,omitempty"`
+	UnusedTypes       []string            `json:"unusedTypes"`
+	Dependencies      []*TypeDependencies `json:"dependencies"`
+}
+
+// TypeDependencies is the fan-in and fan-out of a type.
+type TypeDependencies struct {
+	Type   string `json:"type"`
+	FanIn  int    `json:"fanIn"`
+	FanOut int    `json:"fanOut"`
+}
+
+// printSchemaStats prints the stats of the schema, as text or JSON.
+func printSchemaStats(schema *rdl.Schema, asJSON bool) error {
+	stats := schemaStats(schema)
+	if asJSON {
+		j, err := json.MarshalIndent(stats, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", j)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "schema %s\n", stats.Name)
+	fmt.Fprintf(w, "\ntypes: %d\n", stats.Types)
+	printStatsCounts(w, stats.TypesByBaseType)
+	fmt.Fprintf(w, "\nresources: %d\n", stats.Resources)
+	printStatsCounts(w, stats.ResourcesByMethod)
+	fmt.Fprintf(w, "\nfields per struct:\n")
+	printStatsCounts(w, stats.FieldsPerStruct)
+	if stats.DeepestPath != nil {
+		fmt.Fprintf(w, "\ndeepest nesting: %d (%s)\n", stats.DeepestNesting, strings.Join(stats.DeepestPath, " > "))
+	} else {
+		fmt.Fprintf(w, "\ndeepest nesting: 0\n")
+	}
+	if len(stats.UnusedTypes) > 0 {
+		fmt.Fprintf(w, "\nunused types: %s\n", strings.Join(stats.UnusedTypes, ", "))
+	} else {
+		fmt.Fprintf(w, "\nunused types: none\n")
+	}
+	fmt.Fprintf(w, "\ndependencies:\n")
+	fmt.Fprintf(w, "  TYPE\tFAN-IN\tFAN-OUT\n")
+	for _, d := range stats.Dependencies {
+		fmt.Fprintf(w, "  %s\t%d\t%d\n", d.Type, d.FanIn, d.FanOut)
+	}
+	return w.Flush()
+}
+
+func printStatsCounts(w *tabwriter.Writer, counts map[string]int) {
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %s\t%d\n", k, counts[k])
+	}
+}
+
+// schemaStats computes the stats of the schema.
+func schemaStats(schema *rdl.Schema) *SchemaStats {
+	reg := rdl.NewTypeRegistry(schema)
+	inline := inlineFieldTypes(schema)
+	types := declaredTypes(schema, inline)
+	stats := &SchemaStats{
+		Name:              string(schema.Name),
+		Types:             len(types),
+		TypesByBaseType:   make(map[string]int),
+		Resources:         len(schema.Resources),
+		ResourcesByMethod: make(map[string]int),
+		FieldsPerStruct:   make(map[string]int),
+		UnusedTypes:       []string{},
+	}
+	defined := make(map[rdl.TypeRef]*rdl.Type)
+	for _, t := range types {
+		tName, _, _ := rdl.TypeInfo(t)
+		defined[rdl.TypeRef(tName)] = t
+		stats.TypesByBaseType[reg.BaseType(t).String()]++
+		if t.Variant == rdl.TypeVariantStructTypeDef {
+			stats.FieldsPerStruct[string(tName)] = len(flattenedFields(reg, t))
+		}
+	}
+	for _, r := range schema.Resources {
+		stats.ResourcesByMethod[strings.ToUpper(r.Method)]++
+	}
+	for _, t := range unusedTypes(schema) {
+		tName, _, _ := rdl.TypeInfo(t)
+		stats.UnusedTypes = append(stats.UnusedTypes, string(tName))
+	}
+	sort.Strings(stats.UnusedTypes)
+
+	fanIn := make(map[rdl.TypeRef]int)
+	fanOut := make(map[rdl.TypeRef]int)
+	for _, t := range types {
+		tName, _, _ := rdl.TypeInfo(t)
+		for ref := range inlineTypeRefs(t, inline) {
+			if _, ok := defined[ref]; ok && ref != rdl.TypeRef(tName) {
+				fanOut[rdl.TypeRef(tName)]++
+				fanIn[ref]++
+			}
+		}
+	}
+	for _, r := range schema.Resources {
+		refs := map[rdl.TypeRef]bool{r.Type: true}
+		for _, in := range r.Inputs {
+			refs[in.Type] = true
+		}
+		for _, out := range r.Outputs {
+			refs[out.Type] = true
+		}
+		for _, e := range r.Exceptions {
+			refs[rdl.TypeRef(e.Type)] = true
+		}
+		for ref := range refs {
+			if _, ok := defined[ref]; ok {
+				fanIn[ref]++
+			}
+		}
+	}
+	for _, t := range types {
+		tName, _, _ := rdl.TypeInfo(t)
+		ref := rdl.TypeRef(tName)
+		stats.Dependencies = append(stats.Dependencies, &TypeDependencies{Type: string(tName), FanIn: fanIn[ref], FanOut: fanOut[ref]})
+	}
+	sort.Slice(stats.Dependencies, func(i, j int) bool {
+		return stats.Dependencies[i].Type < stats.Dependencies[j].Type
+	})
+
+	nesting := &typeNesting{defined: defined, inline: inline, paths: make(map[rdl.TypeRef][]string), visiting: make(map[rdl.TypeRef]bool)}
+	for _, t := range types {
+		tName, _, _ := rdl.TypeInfo(t)
+		path := nesting.path(rdl.TypeRef(tName))
+		if len(path) > stats.DeepestNesting || (len(path) == stats.DeepestNesting && len(path) > 0 && strings.Join(path, " ") < strings.Join(stats.DeepestPath, " ")) {
+			stats.DeepestNesting = len(path)
+			stats.DeepestPath = path
+		}
+	}
+	return stats
+}
+
+// typeNesting finds the deepest chain of types nested in each other, through the fields, items,
+// keys, and variants of the types.
+type typeNesting struct {
+	defined  map[rdl.TypeRef]*rdl.Type
+	inline   map[rdl.TypeRef]*rdl.Type
+	paths    map[rdl.TypeRef][]string
+	visiting map[rdl.TypeRef]bool
+}
+
+// path returns the longest chain of nested types starting at the type. A type nested in itself,
+// directly or not, ends the chain.
+func (n *typeNesting) path(ref rdl.TypeRef) []string {
+	if p, ok := n.paths[ref]; ok {
+		return p
+	}
+	t := n.defined[ref]
+	if t == nil || n.visiting[ref] {
+		return nil
+	}
+	n.visiting[ref] = true
+	var deepest []string
+	for nested, wheres := range inlineTypeRefs(t, n.inline) {
+		contained := false
+		for _, where := range wheres {
+			contained = contained || where != "supertype"
+		}
+		if !contained {
+			continue
+		}
+		p := n.path(nested)
+		if len(p) > len(deepest) || (len(p) == len(deepest) && len(p) > 0 && p[0] < deepest[0]) {
+			deepest = p
+		}
+	}
+	n.visiting[ref] = false
+	p := append([]string{string(ref)}, deepest...)
+	n.paths[ref] = p
+	return p
+}
@@ -0,0 +1,273 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//
+// generate --template <dir> renders the Go templates (text/template) of the directory, for one-off
+// targets not worth a generator, e.g. an Ansible inventory or a CSV data dictionary. The files
+// named *.tmpl are rendered, as their path less the .tmpl suffix:
+//
+//   <dir>/<path>.tmpl             once for the schema
+//   <dir>/types/<path>.tmpl       once for each type, as <path>
+//   <dir>/resources/<path>.tmpl   once for each resource, as <path>
+//
+// The path is itself a template, e.g. types/{{.Name}}.md.tmpl. Files whose name starts with _
+// are not rendered, but the templates they define, like those of the other files, can be used
+// by all of them. The templates are passed a TemplateData, and have the functions of
+// templateFuncs besides the builtin ones.
+//
+
+// templateFlavorPrefix is the generator flavor of --template, followed by the directory.
+const templateFlavorPrefix = "template:"
+
+// TemplateData is the data the templates are executed with. Type is set for the templates of
+// types, and Resource for those of resources. Name is the name of the type, or of the resource
+// (its x_name, or else its method and type, e.g. GetContact), or else of the schema.
+type TemplateData struct {
+	Schema   *rdl.Schema
+	Registry rdl.TypeRegistry
+	Type     *rdl.Type
+	Resource *rdl.Resource
+	Name     string
+}
+
+// templateFuncs returns the functions the templates can call, besides the builtin ones.
+func templateFuncs(reg rdl.TypeRegistry) template.FuncMap {
+	return template.FuncMap{
+		"capitalize":   capitalize,
+		"uncapitalize": uncapitalize,
+		"lower":        strings.ToLower,
+		"upper":        strings.ToUpper,
+		"join":         strings.Join,
+		"replace":      strings.Replace,
+		"kebab":        camelSnakeToKebab,
+		//the name, supertype, comment, base type, and struct fields of a type
+		"typeName": func(t *rdl.Type) string {
+			name, _, _ := rdl.TypeInfo(t)
+			return string(name)
+		},
+		"superType": func(t *rdl.Type) string {
+			_, super, _ := rdl.TypeInfo(t)
+			return string(super)
+		},
+		"typeComment": func(t *rdl.Type) string {
+			_, _, comment := rdl.TypeInfo(t)
+			return comment
+		},
+		"baseType": func(tref interface{}) string {
+			return reg.FindBaseType(templateTypeRef(tref)).String()
+		},
+		"fields": func(t *rdl.Type) []*rdl.StructFieldDef {
+			if t == nil || reg.BaseType(t) != rdl.BaseTypeStruct {
+				return nil
+			}
+			return flattenedFields(reg, t)
+		},
+		"findType": func(tref interface{}) *rdl.Type {
+			return reg.FindType(templateTypeRef(tref))
+		},
+		//the types go-model and java-model declare a field as, or a type, given its items and keys
+		"goType": func(v interface{}, itemsKeys ...interface{}) (string, error) {
+			tref, optional, items, keys, err := templateFieldType("goType", v, itemsKeys)
+			if err != nil {
+				return "", err
+			}
+			return goType(reg, tref, optional, items, keys, false, true), nil
+		},
+		"javaType": func(v interface{}, itemsKeys ...interface{}) (string, error) {
+			tref, optional, items, keys, err := templateFieldType("javaType", v, itemsKeys)
+			if err != nil {
+				return "", err
+			}
+			return javaType(reg, tref, optional, items, keys), nil
+		},
+		//a mapping table of the target, e.g. (dict "String" "TEXT" "Int32" "INTEGER"), and the
+		//type it maps a type to
+		"dict": templateDict,
+		"mapType": func(table map[string]string, tref interface{}) (string, error) {
+			return templateMapType(reg, table, templateTypeRef(tref))
+		},
+	}
+}
+
+// templateTypeRef returns the type a template refers to, by a TypeRef, a TypeName, a string, or
+// the Type itself.
+func templateTypeRef(v interface{}) rdl.TypeRef {
+	if v == nil {
+		return ""
+	}
+	if t, ok := v.(*rdl.Type); ok {
+		name, _, _ := rdl.TypeInfo(t)
+		return rdl.TypeRef(name)
+	}
+	return rdl.TypeRef(fmt.Sprint(v))
+}
+
+// templateFieldType returns the type of the struct field, e.g. goType ., or the type given with
+// its items and keys, if any, e.g. goType "Array" "String".
+func templateFieldType(fn string, v interface{}, itemsKeys []interface{}) (rdl.TypeRef, bool, rdl.TypeRef, rdl.TypeRef, error) {
+	if fd, ok := v.(*rdl.StructFieldDef); ok {
+		if len(itemsKeys) > 0 {
+			return "", false, "", "", fmt.Errorf("%s of a field takes no items or keys", fn)
+		}
+		return fd.Type, fd.Optional, fd.Items, fd.Keys, nil
+	}
+	if len(itemsKeys) > 2 {
+		return "", false, "", "", fmt.Errorf("%s takes a field, or a type and its items and keys", fn)
+	}
+	var items, keys rdl.TypeRef
+	if len(itemsKeys) > 0 {
+		items = templateTypeRef(itemsKeys[0])
+	}
+	if len(itemsKeys) > 1 {
+		keys = templateTypeRef(itemsKeys[1])
+	}
+	return templateTypeRef(v), false, items, keys, nil
+}
+
+// templateDict returns the map of the alternating keys and values.
+func templateDict(pairs ...string) (map[string]string, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict needs pairs of keys and values")
+	}
+	m := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		m[pairs[i]] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// templateMapType returns what the table maps the type to: the entry of its name, or else of the
+// nearest of its supertypes, or else of its base type, e.g. Struct.
+func templateMapType(reg rdl.TypeRegistry, table map[string]string, tref rdl.TypeRef) (string, error) {
+	for name := tref; name != ""; {
+		if mapped, ok := table[string(name)]; ok {
+			return mapped, nil
+		}
+		t := reg.FindType(name)
+		if t == nil {
+			break
+		}
+		_, super, _ := rdl.TypeInfo(t)
+		if rdl.TypeRef(super) == name {
+			break
+		}
+		name = rdl.TypeRef(super)
+	}
+	if mapped, ok := table[reg.FindBaseType(tref).String()]; ok {
+		return mapped, nil
+	}
+	return "", fmt.Errorf("The type table maps no type of %s", tref)
+}
+
+// generateFromTemplates renders the templates of the directory for the schema, writing the files
+// to the output directory, or to stdout if there is none.
+func generateFromTemplates(templateDir string, dirName string, schema *rdl.Schema) error {
+	reg := rdl.NewTypeRegistry(schema)
+	funcs := templateFuncs(reg)
+	tmpl := template.New("").Funcs(funcs)
+	var names []string
+	err := filepath.Walk(templateDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !strings.HasSuffix(path, ".tmpl") {
+			return err
+		}
+		rel, err := filepath.Rel(templateDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if _, err := tmpl.New(rel).Parse(string(src)); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(filepath.Base(rel), "_") {
+			names = append(names, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("No templates (*.tmpl) in %s", templateDir)
+	}
+	sort.Strings(names)
+	var files []*PluginFile
+	for _, name := range names {
+		for _, data := range templateDataFor(name, schema, reg) {
+			file, err := renderTemplate(tmpl, funcs, name, data)
+			if err != nil {
+				return err
+			}
+			files = append(files, file)
+		}
+	}
+	return writePluginFiles("template", dirName, files)
+}
+
+// templateDataFor returns the data to render the template with, once for the schema or for
+// each of its types or resources.
+func templateDataFor(name string, schema *rdl.Schema, reg rdl.TypeRegistry) []*TemplateData {
+	var data []*TemplateData
+	switch {
+	case strings.HasPrefix(name, "types/"):
+		for _, t := range schema.Types {
+			tName, _, _ := rdl.TypeInfo(t)
+			data = append(data, &TemplateData{Schema: schema, Registry: reg, Type: t, Name: string(tName)})
+		}
+	case strings.HasPrefix(name, "resources/"):
+		for _, r := range schema.Resources {
+			rName := string(r.Name)
+			if rName == "" {
+				methName, _ := goMethodName(reg, r, false)
+				rName = capitalize(methName)
+			}
+			data = append(data, &TemplateData{Schema: schema, Registry: reg, Resource: r, Name: rName})
+		}
+	default:
+		data = append(data, &TemplateData{Schema: schema, Registry: reg, Name: string(schema.Name)})
+	}
+	return data
+}
+
+// renderTemplate renders the template with the data, as the file named by its path, which is
+// itself a template.
+func renderTemplate(tmpl *template.Template, funcs template.FuncMap, name string, data *TemplateData) (*PluginFile, error) {
+	path := strings.TrimSuffix(name, ".tmpl")
+	if strings.HasPrefix(path, "types/") || strings.HasPrefix(path, "resources/") {
+		path = path[strings.Index(path, "/")+1:]
+	}
+	pathTmpl, err := template.New(name).Funcs(funcs).Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	var buf bytes.Buffer
+	if err := pathTmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	fileName := buf.String()
+	if _, ok := localFileName(fileName); !ok {
+		return nil, fmt.Errorf("%s: bad file name '%s' for %s", name, fileName, data.Name)
+	}
+	buf.Reset()
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return &PluginFile{Name: fileName, Content: buf.String()}, nil
+}
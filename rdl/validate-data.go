@@ -0,0 +1,366 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//
+// The validate command checks a JSON document against a type of the schema. Unlike rdl.Validate,
+// which stops at the first problem, it reports every violation, each located by a JSON pointer
+// (RFC 6901) into the document.
+//
+
+type dataViolation struct {
+	pointer string
+	message string
+}
+
+type dataChecker struct {
+	registry   rdl.TypeRegistry
+	patterns   map[string]*regexp.Regexp
+	violations []*dataViolation
+	written    bool //the data was written by a server, so its enums are in their wire names only
+}
+
+func (c *dataChecker) report(pointer string, format string, args ...interface{}) {
+	c.violations = append(c.violations, &dataViolation{pointer, fmt.Sprintf(format, args...)})
+}
+
+// validateData checks the data file against the type, or against the type it best matches if
+// typename is empty, prints the violations, and returns an error if there are any.
+func validateData(schema *rdl.Schema, dataFile string, typename string, pretty bool) error {
+	raw, err := ioutil.ReadFile(dataFile)
+	if err != nil {
+		return err
+	}
+	var data interface{}
+	err = json.Unmarshal(raw, &data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", dataFile, err)
+	}
+	registry := rdl.NewTypeRegistry(schema)
+	check := func(t *rdl.Type) []*dataViolation {
+		c := &dataChecker{registry: registry, patterns: make(map[string]*regexp.Regexp)}
+		c.check(t, "", "", data, "")
+		return c.violations
+	}
+	var violations []*dataViolation
+	if typename != "" {
+		t := registry.FindType(rdl.TypeRef(typename))
+		if t == nil {
+			return fmt.Errorf("No such type in schema: %s", typename)
+		}
+		violations = check(t)
+	} else {
+		//as with rdl.Validate, the type defined last that the data matches is the guess
+		for i := len(schema.Types) - 1; i >= 0 && typename == ""; i-- {
+			if len(check(schema.Types[i])) == 0 {
+				tName, _, _ := rdl.TypeInfo(schema.Types[i])
+				typename = string(tName)
+			}
+		}
+		if typename == "" {
+			return fmt.Errorf("%s: Cannot determine the type of the data in the schema", dataFile)
+		}
+	}
+	for _, v := range violations {
+		fmt.Printf("%s#%s: %s\n", dataFile, v.pointer, v.message)
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%s: %d violation(s) of type %s", dataFile, len(violations), typename)
+	}
+	if pretty {
+		fmt.Printf("%s: valid %s\n", dataFile, typename)
+	}
+	return nil
+}
+
+func jsonPointerToken(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
+
+func jsonTypeName(data interface{}) string {
+	switch data.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", data)
+}
+
+func (c *dataChecker) mismatch(pointer string, expected string, data interface{}) {
+	c.report(pointer, "expected %s, found %s", expected, jsonTypeName(data))
+}
+
+func (c *dataChecker) pattern(pattern string) *regexp.Regexp {
+	re, ok := c.patterns[pattern]
+	if !ok {
+		re, _ = regexp.Compile("^" + pattern + "$")
+		c.patterns[pattern] = re
+	}
+	return re
+}
+
+// check checks the data at the pointer against the type. The items and keys are those of the
+// struct field holding the data, for fields declared as Array<items> or Map<keys,items>.
+func (c *dataChecker) check(t *rdl.Type, items rdl.TypeRef, keys rdl.TypeRef, data interface{}, pointer string) {
+	for t != nil && t.Variant == rdl.TypeVariantAliasTypeDef {
+		t = c.registry.FindType(t.AliasTypeDef.Type)
+	}
+	if t == nil {
+		return
+	}
+	tName, _, _ := rdl.TypeInfo(t)
+	switch bt := c.registry.BaseType(t); bt {
+	case rdl.BaseTypeAny:
+	case rdl.BaseTypeBool:
+		if _, ok := data.(bool); !ok {
+			c.mismatch(pointer, "a boolean", data)
+		}
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+		n, ok := data.(float64)
+		if !ok {
+			c.mismatch(pointer, "a number", data)
+			return
+		}
+		switch bt {
+		case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64:
+			if n != math.Trunc(n) {
+				c.report(pointer, "%v is not an integer, as %s requires", n, tName)
+			}
+		}
+		min, max := numberConstraints(c.registry, t)
+		if min != nil && n < numberFloat(*min) {
+			c.report(pointer, "%v is less than the minimum of %s (%s)", n, tName, numericValueString(*min))
+		}
+		if max != nil && n > numberFloat(*max) {
+			c.report(pointer, "%v is greater than the maximum of %s (%s)", n, tName, numericValueString(*max))
+		}
+	case rdl.BaseTypeString:
+		s, ok := data.(string)
+		if !ok {
+			c.mismatch(pointer, "a string", data)
+			return
+		}
+		pattern, values, minSize, maxSize := stringConstraints(c.registry, t)
+		if minSize != nil && len(s) < int(*minSize) {
+			c.report(pointer, "string is shorter than the minSize of %s (%d)", tName, *minSize)
+		}
+		if maxSize != nil && len(s) > int(*maxSize) {
+			c.report(pointer, "string is longer than the maxSize of %s (%d)", tName, *maxSize)
+		}
+		if values != nil && !containsString(values, s) {
+			c.report(pointer, "%q is not one of the values of %s", s, tName)
+		}
+		if pattern != "" {
+			if re := c.pattern(pattern); re != nil && !re.MatchString(s) {
+				c.report(pointer, "%q does not match the pattern of %s /%s/", s, tName, pattern)
+			}
+		}
+	case rdl.BaseTypeSymbol:
+		if _, ok := data.(string); !ok {
+			c.mismatch(pointer, "a symbol string", data)
+		}
+	case rdl.BaseTypeUUID:
+		s, ok := data.(string)
+		if !ok {
+			c.mismatch(pointer, "a UUID string", data)
+		} else if rdl.ParseUUID(s) == nil {
+			c.report(pointer, "%q is not a valid UUID", s)
+		}
+	case rdl.BaseTypeTimestamp:
+		s, ok := data.(string)
+		if !ok {
+			c.mismatch(pointer, "a timestamp string", data)
+		} else if _, err := rdl.TimestampParse(s); err != nil {
+			c.report(pointer, "%q is not a valid timestamp", s)
+		}
+	case rdl.BaseTypeBytes:
+		s, ok := data.(string)
+		if !ok {
+			c.mismatch(pointer, "a base64 string", data)
+		} else if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			c.report(pointer, "not valid base64 data")
+		}
+	case rdl.BaseTypeEnum:
+		s, ok := data.(string)
+		if !ok {
+			c.mismatch(pointer, "a "+string(tName)+" string", data)
+			return
+		}
+		//an element is written as its wire name, and read from its symbol and aliases too
+		var wire, names []string
+		if t.Variant == rdl.TypeVariantEnumTypeDef {
+			for _, e := range t.EnumTypeDef.Elements {
+				wire = append(wire, enumWireName(e))
+				names = append(names, enumWireName(e))
+				if !c.written {
+					names = append(names, enumReadNames(e)...)
+				}
+			}
+		}
+		if !containsString(names, s) {
+			c.report(pointer, "%q is not a %s (expected one of %s)", s, tName, strings.Join(wire, ", "))
+		}
+	case rdl.BaseTypeArray:
+		a, ok := data.([]interface{})
+		if !ok {
+			c.mismatch(pointer, "an array", data)
+			return
+		}
+		if t.Variant == rdl.TypeVariantArrayTypeDef {
+			at := t.ArrayTypeDef
+			items = at.Items
+			c.checkSize(pointer, "array", len(a), tName, at.Size, at.MinSize, at.MaxSize)
+		}
+		if it := c.registry.FindType(items); it != nil {
+			for i, item := range a {
+				c.check(it, "", "", item, fmt.Sprintf("%s/%d", pointer, i))
+			}
+		}
+	case rdl.BaseTypeMap:
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			c.mismatch(pointer, "an object", data)
+			return
+		}
+		if t.Variant == rdl.TypeVariantMapTypeDef {
+			mt := t.MapTypeDef
+			items, keys = mt.Items, mt.Keys
+			c.checkSize(pointer, "map", len(m), tName, mt.Size, mt.MinSize, mt.MaxSize)
+		}
+		kt := c.registry.FindType(keys)
+		it := c.registry.FindType(items)
+		for _, k := range sortedKeys(m) {
+			p := pointer + "/" + jsonPointerToken(k)
+			if kt != nil {
+				switch c.registry.BaseType(kt) {
+				case rdl.BaseTypeString, rdl.BaseTypeSymbol, rdl.BaseTypeEnum, rdl.BaseTypeUUID:
+					c.check(kt, "", "", k, p)
+				}
+			}
+			if it != nil {
+				c.check(it, "", "", m[k], p)
+			}
+		}
+	case rdl.BaseTypeStruct:
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			c.mismatch(pointer, "a "+string(tName)+" object", data)
+			return
+		}
+		if t.Variant != rdl.TypeVariantStructTypeDef {
+			return
+		}
+		fields := flattenedFields(c.registry, t)
+		declared := make(map[string]bool)
+		for _, f := range fields {
+			declared[string(f.Name)] = true
+			p := pointer + "/" + jsonPointerToken(string(f.Name))
+			v, present := m[string(f.Name)]
+			if !present || v == nil {
+				if !f.Optional && f.Default == nil {
+					c.report(p, "required field '%s' of %s is missing", f.Name, tName)
+				}
+				continue
+			}
+			c.check(c.registry.FindType(f.Type), f.Items, f.Keys, v, p)
+		}
+		if t.StructTypeDef.Closed {
+			for _, k := range sortedKeys(m) {
+				if !declared[k] {
+					c.report(pointer+"/"+jsonPointerToken(k), "field '%s' is not allowed in the closed struct %s", k, tName)
+				}
+			}
+		}
+	case rdl.BaseTypeUnion:
+		m, ok := data.(map[string]interface{})
+		if !ok || len(m) != 1 {
+			c.report(pointer, "expected a %s object with exactly one variant", tName)
+			return
+		}
+		for k, v := range m {
+			if t.Variant != rdl.TypeVariantUnionTypeDef || !containsString(typeRefStrings(t.UnionTypeDef.Variants), k) {
+				c.report(pointer+"/"+jsonPointerToken(k), "'%s' is not a variant of %s", k, tName)
+				continue
+			}
+			c.check(c.registry.FindType(rdl.TypeRef(k)), "", "", v, pointer+"/"+jsonPointerToken(k))
+		}
+	}
+}
+
+func (c *dataChecker) checkSize(pointer string, kind string, n int, tName rdl.TypeName, size *int32, minSize *int32, maxSize *int32) {
+	if size != nil && n != int(*size) {
+		c.report(pointer, "%s has %d items, %s requires %d", kind, n, tName, *size)
+	}
+	if minSize != nil && n < int(*minSize) {
+		c.report(pointer, "%s has %d items, fewer than the minSize of %s (%d)", kind, n, tName, *minSize)
+	}
+	if maxSize != nil && n > int(*maxSize) {
+		c.report(pointer, "%s has %d items, more than the maxSize of %s (%d)", kind, n, tName, *maxSize)
+	}
+}
+
+func numberFloat(n rdl.Number) float64 {
+	switch n.Variant {
+	case rdl.NumberVariantInt8:
+		return float64(*n.Int8)
+	case rdl.NumberVariantInt16:
+		return float64(*n.Int16)
+	case rdl.NumberVariantInt32:
+		return float64(*n.Int32)
+	case rdl.NumberVariantInt64:
+		return float64(*n.Int64)
+	case rdl.NumberVariantFloat32:
+		return float64(*n.Float32)
+	case rdl.NumberVariantFloat64:
+		return *n.Float64
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func typeRefStrings(refs []rdl.TypeRef) []string {
+	s := make([]string, 0, len(refs))
+	for _, r := range refs {
+		s = append(s, string(r))
+	}
+	return s
+}
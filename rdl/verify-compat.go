@@ -0,0 +1,244 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ardielle/ardielle-go/rdl"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+//
+// The verify-compat command is a smoke test that a deployed service still matches its schema. It
+// sends a request to each GET resource of the schema at the base URL, and checks that the status
+// of the response is one the resource declares, and that its body is valid for the type of the
+// resource, or of the exception declared for the status, as the validate command checks data.
+//
+// The values of the path parameters, and of the headers and query parameters to send, are set
+// with -p name=value, or for a resource by its x_verify annotation, e.g. x_verify="name=joe". A
+// resource annotated with x_verify="false" is not probed, and with --annotated only the resources
+// annotated with x_verify (e.g. x_verify="true") are, for a subset known to be safe to call.
+//
+
+// verifyCompat probes the resources of the schema at the base URL, prints the outcome of each,
+// and returns an error if any does not match the schema.
+func verifyCompat(schema *rdl.Schema, baseURL string, headers []string, params []string, annotated bool) error {
+	registry := rdl.NewTypeRegistry(schema)
+	values, err := verifyParams(params)
+	if err != nil {
+		return err
+	}
+	header := make(http.Header)
+	for _, h := range headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("Bad header '%s' (expected Name: value)", h)
+		}
+		header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	probed, failed := 0, 0
+	for _, r := range schema.Resources {
+		if strings.ToUpper(r.Method) != "GET" {
+			continue
+		}
+		verify, ok := r.Annotations["x_verify"]
+		if verify == "false" || (annotated && !ok) {
+			continue
+		}
+		name := r.Method + " " + r.Path
+		if reason := verifySkipReason(r); reason != "" {
+			fmt.Printf("%s: skipped, %s\n", name, reason)
+			continue
+		}
+		if verify == "true" {
+			verify = ""
+		}
+		resourceValues, err := verifyParams(strings.Split(verify, "&"))
+		if err != nil {
+			return fmt.Errorf("%s: bad x_verify: %v", name, err)
+		}
+		for k, v := range values {
+			if _, ok := resourceValues[k]; !ok {
+				resourceValues[k] = v
+			}
+		}
+		req, missing, err := verifyRequest(registry, r, baseURL, header, resourceValues)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if req == nil {
+			fmt.Printf("%s: skipped, no value for %s (set with -p %s=value)\n", name, missing, missing)
+			continue
+		}
+		probed++
+		problems := verifyResponse(registry, r, client, req)
+		if len(problems) == 0 {
+			fmt.Printf("%s: ok\n", name)
+			continue
+		}
+		failed++
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", name, p)
+		}
+	}
+	if probed == 0 {
+		return fmt.Errorf("No resource of the schema was probed")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of the %d resources probed at %s do not match the schema", failed, probed, baseURL)
+	}
+	return nil
+}
+
+// verifyParams returns the values of the name=value pairs.
+func verifyParams(pairs []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, pair := range pairs {
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("Bad parameter '%s' (expected name=value)", pair)
+		}
+		values[strings.TrimSpace(kv[0])] = kv[1]
+	}
+	return values, nil
+}
+
+// verifySkipReason tells why the response of the resource cannot be checked as JSON, if it cannot.
+func verifySkipReason(r *rdl.Resource) string {
+	if streamsEvents(r) {
+		return "it streams events"
+	}
+	if contentType := streamingResponseType(r); contentType != "" {
+		return "it responds with " + contentType
+	}
+	return ""
+}
+
+var verifyPathParam = regexp.MustCompile(`{([^}]*)}`)
+
+// verifyRequest returns the request probing the resource, or nil and the name of the first path
+// parameter there is no value for. The values of enum parameters may be given as their symbols,
+// and are sent as their wire names.
+func verifyRequest(registry rdl.TypeRegistry, r *rdl.Resource, baseURL string, header http.Header, values map[string]string) (*http.Request, string, error) {
+	inputs := make(map[string]string)
+	for _, in := range r.Inputs {
+		if v, ok := values[string(in.Name)]; ok {
+			inputs[string(in.Name)] = enumWireValue(registry, in.Type, v)
+		}
+	}
+	values = inputs
+	path := strings.SplitN(r.Path, "?", 2)[0]
+	missing := ""
+	path = verifyPathParam.ReplaceAllStringFunc(path, func(param string) string {
+		name := param[1 : len(param)-1]
+		v, ok := values[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return url.PathEscape(v)
+	})
+	if missing != "" {
+		return nil, missing, nil
+	}
+	query := url.Values{}
+	for _, in := range r.Inputs {
+		if v, ok := values[string(in.Name)]; ok && in.QueryParam != "" {
+			query.Set(in.QueryParam, v)
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", baseURL+path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	for _, in := range r.Inputs {
+		if v, ok := values[string(in.Name)]; ok && in.Header != "" {
+			req.Header.Set(in.Header, v)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	return req, "", nil
+}
+
+// verifyResponse sends the request, and returns how its response does not match the resource.
+func verifyResponse(registry rdl.TypeRegistry, r *rdl.Resource, client *http.Client, req *http.Request) []string {
+	resp, err := client.Do(req)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	code := fmt.Sprint(resp.StatusCode)
+	var tref rdl.TypeRef
+	for _, expected := range append([]string{r.Expected}, r.Alternatives...) {
+		if rdl.StatusCode(expected) == code {
+			if expected == "NO_CONTENT" || expected == "NOT_MODIFIED" {
+				if len(body) > 0 {
+					return []string{fmt.Sprintf("status %s has a body", code)}
+				}
+				return nil
+			}
+			tref = r.Type
+		}
+	}
+	if tref == "" {
+		for ecode, e := range r.Exceptions {
+			if rdl.StatusCode(ecode) == code {
+				tref = rdl.TypeRef(e.Type)
+			}
+		}
+	}
+	if tref == "" {
+		return []string{fmt.Sprintf("status %s is neither expected nor a declared exception (%s)", code, verifyDeclaredCodes(r))}
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return []string{fmt.Sprintf("status %s: the body is not JSON: %v", code, err)}
+	}
+	t := registry.FindType(tref)
+	if t == nil {
+		return nil
+	}
+	c := &dataChecker{registry: registry, patterns: make(map[string]*regexp.Regexp), written: true}
+	c.check(t, "", "", data, "")
+	var problems []string
+	for _, v := range c.violations {
+		problems = append(problems, fmt.Sprintf("status %s, %s#%s: %s", code, tref, v.pointer, v.message))
+	}
+	return problems
+}
+
+// verifyDeclaredCodes returns the status codes the resource declares, e.g. "200, 304, 404".
+func verifyDeclaredCodes(r *rdl.Resource) string {
+	codes := []string{rdl.StatusCode(r.Expected)}
+	for _, alt := range r.Alternatives {
+		codes = append(codes, rdl.StatusCode(alt))
+	}
+	var ecodes []string
+	for ecode := range r.Exceptions {
+		ecodes = append(ecodes, rdl.StatusCode(ecode))
+	}
+	sort.Strings(ecodes)
+	return strings.Join(append(codes, ecodes...), ", ")
+}
@@ -0,0 +1,177 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+//
+// generate --watch polls the schema file and everything it includes, and regenerates once they
+// have stopped changing for a moment, so that an editor saving several files (or saving in steps)
+// triggers a single run. Each run generates into a scratch directory, and only the files whose
+// content differs from what is already in the output are written, so tools watching the output
+// only see real changes. Errors are reported and the watch goes on.
+//
+
+const (
+	watchPollInterval = 500 * time.Millisecond
+	watchQuietPeriod  = 300 * time.Millisecond
+)
+
+// watchSchema runs regenerate, passing it the directory (or file) to generate to, every time the
+// schema changes, then passes the output files to generated. It only returns if the output cannot
+// be watched at all.
+func watchSchema(schemaFile string, includePath []string, outfile string, regenerate func(string) error, generated func([]string) error) error {
+	if outfile == "" {
+		return fmt.Errorf("generate --watch needs an output file or directory (-o)")
+	}
+	sources := []string{schemaFile}
+	for {
+		if files := schemaSources(schemaFile, includePath); files != nil {
+			sources = files
+		}
+		stamps := modTimes(sources)
+		files, err := regenerateChanged(outfile, regenerate)
+		if err == nil {
+			err = generated(files)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "*** %v\n", err)
+		}
+		fmt.Fprintf(os.Stderr, "Watching %d file(s) for changes...\n", len(sources))
+		waitForChange(stamps)
+	}
+}
+
+// schemaSources returns the schema file and the files it transitively includes, or nil if the
+// includes cannot be resolved right now.
+func schemaSources(schemaFile string, includePath []string) []string {
+	if filepath.Ext(schemaFile) == ".json" {
+		return []string{schemaFile}
+	}
+	dir, err := ioutil.TempDir("", "rdl-watch")
+	if err != nil {
+		return nil
+	}
+	defer os.RemoveAll(dir)
+	res := newIncludeResolver(includePath, dir)
+	if _, err := res.stage(schemaFile); err != nil {
+		return nil
+	}
+	var files []string
+	for path := range res.staged {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// modTimes stamps each file with its modification time and size. A missing file gets the zero
+// stamp, so its deletion and reappearance both count as changes.
+func modTimes(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		var stamp fileStamp
+		if fi, err := os.Stat(file); err == nil {
+			stamp = fileStamp{fi.ModTime(), fi.Size()}
+		}
+		stamps[file] = stamp
+	}
+	return stamps
+}
+
+func sameStamps(a map[string]fileStamp, b map[string]fileStamp) bool {
+	for file, stamp := range a {
+		if !b[file].modTime.Equal(stamp.modTime) || b[file].size != stamp.size {
+			return false
+		}
+	}
+	return true
+}
+
+// waitForChange blocks until one of the stamped files changes, and then until they have all
+// been left alone for the quiet period.
+func waitForChange(stamps map[string]fileStamp) {
+	files := make([]string, 0, len(stamps))
+	for file := range stamps {
+		files = append(files, file)
+	}
+	for sameStamps(stamps, modTimes(files)) {
+		time.Sleep(watchPollInterval)
+	}
+	last := modTimes(files)
+	for {
+		time.Sleep(watchQuietPeriod)
+		current := modTimes(files)
+		if sameStamps(last, current) {
+			return
+		}
+		last = current
+	}
+}
+
+// regenerateChanged generates into a scratch directory, then copies the files whose content
+// changed to the output and prints a summary of them. It returns all the output files.
+func regenerateChanged(outfile string, regenerate func(string) error) ([]string, error) {
+	start := time.Now()
+	tmp, err := ioutil.TempDir("", "rdl-watch")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	root := outputRoot(outfile)
+	target := tmp
+	if root != outfile {
+		target = filepath.Join(tmp, filepath.Base(outfile))
+	}
+	if err := regenerate(target); err != nil {
+		return nil, err
+	}
+	var files, rewritten []string
+	unchanged := 0
+	err = filepath.Walk(tmp, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(tmp, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(root, rel)
+		files = append(files, dest)
+		if old, err := ioutil.ReadFile(dest); err == nil && bytes.Equal(old, data) {
+			unchanged++
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		rewritten = append(rewritten, dest)
+		return ioutil.WriteFile(dest, data, 0644)
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "[%s] Regenerated in %v: %d file(s) rewritten, %d unchanged\n", start.Format("15:04:05"), time.Since(start).Round(time.Millisecond), len(rewritten), unchanged)
+	for _, path := range rewritten {
+		fmt.Fprintf(os.Stderr, "  %s\n", path)
+	}
+	return files, nil
+}